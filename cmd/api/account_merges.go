@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+)
+
+type MergeAccountsPayload struct {
+	SourceID int64  `json:"source_id" validate:"required"`
+	TargetID int64  `json:"target_id" validate:"required,nefield=SourceID"`
+	Reason   string `json:"reason" validate:"max=2000"`
+}
+
+// MergeAccounts godoc
+//
+//	@Summary		Merge accounts
+//	@Description	Moves the posts, comments, follows, reactions and bookmarks of a duplicate account to the target account and deactivates the duplicate. Where both have the same follow, reaction or bookmark the target's is kept
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MergeAccountsPayload	true	"Accounts"
+//	@Success		201		{object}	store.AccountMerge
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"Account not found or already merged"
+//	@Failure		409		{object}	error	"Account under legal hold"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/merges [post]
+func (app *application) mergeAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MergeAccountsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := getUserFromContext(r)
+	merge := &store.AccountMerge{
+		SourceID: payload.SourceID,
+		TargetID: payload.TargetID,
+		MergedBy: admin.ID,
+		Reason:   payload.Reason,
+	}
+	ctx := r.Context()
+	if err := app.store.AccountMerges.Merge(ctx, merge); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrUnderLegalHold):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("user.merge", admin.ID, "merge_id", merge.ID, "source_id", merge.SourceID, "target_id", merge.TargetID,
+		"posts", merge.Posts, "comments", merge.Comments, "follows", merge.Follows, "reactions", merge.Reactions)
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, merge.SourceID)
+		app.cacheStorage.Users.Delete(ctx, merge.TargetID)
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, merge); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAccountMerges godoc
+//
+//	@Summary		List account merges
+//	@Description	Past merges newest first, with what each one moved
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.AccountMerge
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/merges [get]
+func (app *application) listAccountMergesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	merges, err := app.store.AccountMerges.List(r.Context(), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, merges); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccountMerges(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	merges := &store.MockAccountMergeStore{}
+	app.store.AccountMerges = merges
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should keep merging to admins", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/merges", `{"source_id":2,"target_id":3}`).Code)
+	})
+
+	t.Run("should merge a duplicate account once", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/merges", `{"source_id":2,"target_id":2}`).Code)
+		rr := request(t, http.MethodPost, "/v1/admin/merges", `{"source_id":2,"target_id":3,"reason":"duplicate signup"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := decodeData[store.AccountMerge](t, rr.Body.String()); got.MergedBy != 42 || got.TargetID != 3 {
+			t.Errorf("unexpected merge %+v", got)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/admin/merges", `{"source_id":2,"target_id":3}`).Code)
+
+		rr = request(t, http.MethodGet, "/v1/admin/merges", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.AccountMerge](t, rr.Body.String()); len(got) != 1 {
+			t.Errorf("expected 1 merge, got %d", len(got))
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultRetentionWeeks = 12
+
+// GetPlatformActivity godoc
+//
+//	@Summary		Platform activity
+//	@Description	Daily and monthly active users, signups and posts per day, as aggregated by the nightly analytics job
+//	@Tags			admin
+//	@Produce		json
+//	@Param			days	query		int	false	"Days to look back (default 30, max 365)"
+//	@Success		200		{object}	[]store.PlatformDay
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/analytics/activity [get]
+func (app *application) getPlatformActivityHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := parseInsightsSince(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	days, err := app.store.Analytics.PlatformDaily(r.Context(), since)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, days); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetRetentionCohorts godoc
+//
+//	@Summary		Retention cohorts
+//	@Description	Weekly signup cohorts with the number of members active in each following week
+//	@Tags			admin
+//	@Produce		json
+//	@Param			weeks	query		int	false	"Cohorts to return (default 12, max 52)"
+//	@Success		200		{object}	[]store.RetentionCohort
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/analytics/retention [get]
+func (app *application) getRetentionCohortsHandler(w http.ResponseWriter, r *http.Request) {
+	weeks := defaultRetentionWeeks
+	if raw := r.URL.Query().Get("weeks"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		weeks = n
+	}
+	if err := Validate.Var(weeks, "gte=1,lte=52"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cohorts, err := app.store.Analytics.RetentionCohorts(r.Context(), time.Now().AddDate(0, 0, -7*weeks))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, cohorts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetTopHashtags godoc
+//
+//	@Summary		Top hashtags
+//	@Description	Most used post tags over the look-back window
+//	@Tags			admin
+//	@Produce		json
+//	@Param			days	query		int	false	"Days to look back (default 30, max 365)"
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Success		200		{object}	[]store.HashtagCount
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/analytics/hashtags [get]
+func (app *application) getTopHashtagsHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := parseInsightsSince(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	limit, _, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.store.Analytics.TopHashtags(r.Context(), since, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// aggregatePlatformAnalytics is the job behind the admin analytics, it rolls
+// up every finished UTC day not aggregated yet.
+func (app *application) aggregatePlatformAnalytics(ctx context.Context) error {
+	return app.aggregateAnalytics(ctx, store.AnalyticsPlatform, app.store.Analytics.AggregatePlatform)
+}
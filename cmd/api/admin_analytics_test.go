@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminAnalytics(t *testing.T) {
+	t.Run("should not allow regular users", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		mux := app.mount()
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"activity", "retention", "hashtags"} {
+			req, err := http.NewRequest(http.MethodGet, "/v1/admin/analytics/"+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusForbidden, rr.Code)
+		}
+	})
+
+	t.Run("should validate the look-back for admins", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		app.store.Users = &adminUserStore{}
+		mux := app.mount()
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, path := range []string{"activity?days=0", "retention?weeks=53", "hashtags?limit=500"} {
+			req, err := http.NewRequest(http.MethodGet, "/v1/admin/analytics/"+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+	})
+}
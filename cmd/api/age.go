@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"time"
+)
+
+// ageConfig sets the minimum age to register and the age from which users
+// see age restricted posts.
+type ageConfig struct {
+	minAge   int
+	adultAge int
+}
+
+const birthdateLayout = "2006-01-02"
+
+// ageOn returns the age in whole years on the given day.
+func ageOn(birthdate, now time.Time) int {
+	years := now.Year() - birthdate.Year()
+	if now.Month() < birthdate.Month() || (now.Month() == birthdate.Month() && now.Day() < birthdate.Day()) {
+		years--
+	}
+	return years
+}
+
+// checkBirthdate validates a YYYY-MM-DD birthdate against the minimum age.
+func (app *application) checkBirthdate(raw string) error {
+	birthdate, err := time.Parse(birthdateLayout, raw)
+	if err != nil {
+		return errors.New("birthdate must be YYYY-MM-DD")
+	}
+	now := time.Now()
+	if birthdate.After(now) || birthdate.Year() < 1900 {
+		return errors.New("birthdate is out of range")
+	}
+	if ageOn(birthdate, now) < app.config.age.minAge {
+		return fmt.Errorf("you must be at least %d years old", app.config.age.minAge)
+	}
+	return nil
+}
+
+// isAdult reports whether the user is known to be an adult. Users without a
+// birthdate, and anonymous visitors, are not.
+func (app *application) isAdult(user *store.User) bool {
+	if user == nil {
+		return false
+	}
+	return app.adultBirthdate(user.Birthdate)
+}
+
+// adultBirthdate reports whether someone born on the YYYY-MM-DD birthdate is
+// an adult today, false when it is unknown.
+func (app *application) adultBirthdate(raw *string) bool {
+	if raw == nil {
+		return false
+	}
+	birthdate, err := time.Parse(birthdateLayout, *raw)
+	if err != nil {
+		return false
+	}
+	return ageOn(birthdate, time.Now()) >= app.config.age.adultAge
+}
+
+// canViewAgeRestricted reports whether the viewer may see the post.
+func (app *application) canViewAgeRestricted(viewer *store.User, post *store.Post) bool {
+	return !post.AgeRestricted || post.UserID == viewer.ID || app.isAdult(viewer)
+}
+
+type SetBirthdatePayload struct {
+	Birthdate string `json:"birthdate" validate:"required"`
+}
+
+// SetBirthdate godoc
+//
+//	@Summary		Set birthdate
+//	@Description	Records the birthdate of an account created before it was collected. It can only be set once
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body	SetBirthdatePayload	true	"Birthdate as YYYY-MM-DD"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		409	{object}	error	"Birthdate already set"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/birthdate [put]
+func (app *application) setBirthdateHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SetBirthdatePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.checkBirthdate(payload.Birthdate); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+	if err := app.store.Users.SetBirthdate(ctx, user.ID, payload.Birthdate); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, errors.New("birthdate is already set"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type restrictedPostStore struct {
+	store.MockPostStore
+}
+
+func (m *restrictedPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: 7, AgeRestricted: true}, nil
+}
+
+type adultUserStore struct {
+	store.MockUserStore
+}
+
+func (m *adultUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	birthdate := "1990-01-01"
+	return &store.User{ID: id, Role: &store.Role{Name: "user", Level: 1}, Birthdate: &birthdate}, nil
+}
+
+func TestAgeGating(t *testing.T) {
+	app := NewTestApplication(t, config{age: ageConfig{minAge: 13, adultAge: 18}})
+	app.store.Posts = &restrictedPostStore{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should reject birthdates below the minimum age", func(t *testing.T) {
+		young := time.Now().AddDate(-12, 0, 0).Format(birthdateLayout)
+		if err := app.checkBirthdate(young); err == nil {
+			t.Errorf("expected %s to be rejected", young)
+		}
+		if err := app.checkBirthdate("2000-02-30"); err == nil {
+			t.Error("expected an invalid date to be rejected")
+		}
+		if err := app.checkBirthdate("2000-02-29"); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("should hide age restricted posts from users without a birthdate", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/posts/1", ""))
+	})
+
+	t.Run("should show age restricted posts to adults", func(t *testing.T) {
+		app.store.Users = &adultUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/posts/1", ""))
+	})
+
+	t.Run("should set the birthdate of older accounts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/users/me/birthdate", `{"birthdate":"2001-05-04"}`))
+		underage := time.Now().AddDate(-10, 0, 0).Format(birthdateLayout)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/users/me/birthdate", `{"birthdate":"`+underage+`"}`))
+	})
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// announcementsConfig enables the X-Announcement header, refreshed from the
+// database every refreshInterval rather than per request.
+type announcementsConfig struct {
+	header          bool
+	refreshInterval time.Duration
+}
+
+// announcementBanner holds the newest running announcement for the header,
+// nil when there is none.
+type announcementBanner struct {
+	latest atomic.Pointer[store.Announcement]
+}
+
+// refreshAnnouncements reloads the banner. It runs as a job and after admins
+// change announcements.
+func (app *application) refreshAnnouncements(ctx context.Context) error {
+	if app.banner == nil {
+		return nil
+	}
+	active, err := app.store.Announcements.ListActive(ctx, 0)
+	if err != nil {
+		return err
+	}
+	var latest *store.Announcement
+	if len(active) > 0 {
+		latest = &active[0]
+	}
+	app.banner.latest.Store(latest)
+	return nil
+}
+
+// AnnouncementHeaderMiddleware tells clients about the newest running
+// announcement as "X-Announcement: <id>; severity=<severity>", so they know
+// when to fetch /v1/announcements. Dismissals aren't considered here.
+func (app *application) AnnouncementHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := app.banner.latest.Load(); a != nil && (a.EndsAt == nil || a.EndsAt.After(time.Now())) {
+			w.Header().Set("X-Announcement", fmt.Sprintf("%d; severity=%s", a.ID, a.Severity))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListAnnouncements godoc
+//
+//	@Summary		List announcements
+//	@Description	Running announcements the authenticated user hasn't dismissed, newest first
+//	@Tags			announcements
+//	@Produce		json
+//	@Success		200	{object}	[]store.Announcement
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/announcements [get]
+func (app *application) listAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	announcements, err := app.store.Announcements.ListActive(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, announcements); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DismissAnnouncement godoc
+//
+//	@Summary		Dismiss an announcement
+//	@Description	Stops returning the announcement to the authenticated user
+//	@Tags			announcements
+//	@Param			announcementID	path	int	true	"Announcement ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/announcements/{announcementID}/dismiss [post]
+func (app *application) dismissAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "announcementID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.Announcements.Dismiss(r.Context(), id, getUserFromContext(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type CreateAnnouncementPayload struct {
+	Title    string     `json:"title" validate:"required,max=200"`
+	Body     string     `json:"body" validate:"max=5000"`
+	Severity string     `json:"severity" validate:"omitempty,oneof=info warning critical"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   *time.Time `json:"ends_at"`
+}
+
+// CreateAnnouncement godoc
+//
+//	@Summary		Create an announcement
+//	@Description	Schedules a notice for every user. It starts now unless starts_at is given and runs until ends_at or until deleted
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAnnouncementPayload	true	"Announcement"
+//	@Success		201		{object}	store.Announcement
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/announcements [post]
+func (app *application) createAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAnnouncementPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	a := &store.Announcement{
+		Title:     payload.Title,
+		Body:      payload.Body,
+		Severity:  payload.Severity,
+		EndsAt:    payload.EndsAt,
+		CreatedBy: getUserFromContext(r).ID,
+	}
+	if a.Severity == "" {
+		a.Severity = store.AnnouncementInfo
+	}
+	start := time.Now()
+	if payload.StartsAt != nil {
+		a.StartsAt = *payload.StartsAt
+		start = a.StartsAt
+	}
+	if a.EndsAt != nil && !a.EndsAt.After(start) {
+		app.badRequestResponse(w, r, errors.New("ends_at must be after starts_at"))
+		return
+	}
+
+	ctx := r.Context()
+	if err := app.store.Announcements.Create(ctx, a); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.refreshAnnouncements(ctx); err != nil {
+		app.logger.Warnw("error refreshing announcement banner", "error", err.Error())
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, a); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAllAnnouncements godoc
+//
+//	@Summary		List all announcements
+//	@Description	Every announcement including scheduled and expired ones, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Announcement
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/announcements [get]
+func (app *application) listAllAnnouncementsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	announcements, err := app.store.Announcements.List(r.Context(), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, announcements); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteAnnouncement godoc
+//
+//	@Summary		Delete an announcement
+//	@Description	Removes the announcement for everyone
+//	@Tags			admin
+//	@Param			announcementID	path	int	true	"Announcement ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/announcements/{announcementID} [delete]
+func (app *application) deleteAnnouncementHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "announcementID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Announcements.Delete(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.refreshAnnouncements(ctx); err != nil {
+		app.logger.Warnw("error refreshing announcement banner", "error", err.Error())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
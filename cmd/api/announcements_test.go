@@ -0,0 +1,59 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnouncements(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	announcements := &store.MockAnnouncementStore{}
+	app.store.Announcements = announcements
+	app.banner = &announcementBanner{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should keep managing announcements to admins", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/announcements", `{"title":"maintenance"}`).Code)
+	})
+
+	t.Run("should announce and let users dismiss", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/announcements", `{"title":"maintenance","severity":"loud"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/announcements",
+			`{"title":"maintenance","starts_at":"2030-01-02T00:00:00Z","ends_at":"2030-01-01T00:00:00Z"}`).Code)
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/announcements", `{"title":"maintenance","severity":"warning"}`).Code)
+		app.store.Users = &store.MockUserStore{}
+
+		rr := request(t, http.MethodGet, "/v1/announcements/", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := rr.Header().Get("X-Announcement"); got != "1; severity=warning" {
+			t.Errorf("expected the banner header for announcement 1, got %q", got)
+		}
+		if got := decodeData[[]store.Announcement](t, rr.Body.String()); len(got) != 1 {
+			t.Fatalf("expected 1 announcement, got %d", len(got))
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/announcements/9/dismiss", "").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPost, "/v1/announcements/1/dismiss", "").Code)
+		rr = request(t, http.MethodGet, "/v1/announcements/", "")
+		if got := decodeData[[]store.Announcement](t, rr.Body.String()); len(got) != 0 {
+			t.Errorf("expected the dismissed announcement to be gone, got %d", len(got))
+		}
+	})
+}
@@ -6,22 +6,37 @@ import (
 	"expvar"
 	"fmt"
 	"gopher_social/internal/auth"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/crosspost"
 	"gopher_social/internal/env"
+	"gopher_social/internal/events"
+	"gopher_social/internal/github"
 	"gopher_social/internal/mailer"
+	"gopher_social/internal/markup"
+	"gopher_social/internal/metrics"
+	"gopher_social/internal/playground"
+	"gopher_social/internal/push"
 	"gopher_social/internal/ratelimiter"
+	"gopher_social/internal/relme"
+	"gopher_social/internal/rtc"
+	"gopher_social/internal/scan"
+	"gopher_social/internal/search"
 	"gopher_social/internal/store"
 	"gopher_social/internal/store/cache"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"gopher_social/docs"
 
-	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/go-webauthn/webauthn/webauthn"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 	"go.uber.org/zap"
 )
@@ -34,18 +49,238 @@ type application struct {
 	mailer        mailer.Client
 	authenticator auth.Authenticator
 	rateLimiter   ratelimiter.Limiter
+	// botRateLimiter is the rate limit class of service accounts, keyed by
+	// account rather than IP.
+	botRateLimiter ratelimiter.Limiter
+	// followImportLimiter caps following imports per user, each can follow
+	// thousands of accounts at once.
+	followImportLimiter ratelimiter.Limiter
+	markup              *markup.Renderer
+	webauthn            *webauthn.WebAuthn
+	blobStore           blob.Store
+	archiveStore        blob.Store
+	mediaSigner         *blob.Signer
+	// feedMetrics records GetUserFeed latency and row counts, published at
+	// /debug/vars as feed_query.
+	feedMetrics *metrics.QueryRecorder
+	// routeMetrics records request latency per route, published at
+	// /debug/vars as routes.
+	routeMetrics *metrics.QueryRecorder
+	routeLabels  routeLabels
+	// mailBreaker trips when the email provider keeps failing, mail is then
+	// queued in the outbox.
+	mailBreaker *breaker.Breaker
+	// searchIndex is nil unless the external search backend is enabled.
+	searchIndex search.Index
+	// mediaScanner checks uploads, nil when no scanner is configured.
+	mediaScanner *scan.Pipeline
+	// relme fetches the links of profile fields to verify them.
+	relme *relme.Checker
+	// playground shares runnable Go snippets, nil when it is disabled.
+	playground *playground.Client
+	// github looks up the repositories posts link to for repo cards.
+	github *github.Client
+	// rtc opens the media rooms of spaces, nil when spaces carry metadata
+	// only.
+	rtc rtc.Provider
+	// crosspost publishes to the networks users connect.
+	crosspost crosspost.Router
+	// captures records request and response pairs for debugging, nil when
+	// it is disabled.
+	captures *debugCapture
+	// waiters are the notification long polls waiting on this instance.
+	waiters notificationWaiters
+	// maintenance holds the maintenance windows in effect.
+	maintenance maintenanceState
+	// instance holds the instance settings and content limits in effect.
+	instance instanceState
+	// banner backs the X-Announcement header, nil when it is disabled.
+	banner *announcementBanner
+	// terms holds the current terms users must have accepted, nil skips the
+	// check.
+	terms *termsGate
+	// push sends mobile push notifications, nil when no platform is
+	// configured.
+	push push.Router
+	// hotKeys counts user lookups for cache warming, nil when it is
+	// disabled. warming is set while the startup warm-up runs.
+	hotKeys *hotKeys
+	warming atomic.Bool
+	// events delivers domain events published by handlers to the
+	// subsystems subscribed in subscribeEvents.
+	events events.Publisher
+	// broker receives the domain events relayed from the outbox, nil when
+	// no broker is configured.
+	broker events.Broker
 }
 type config struct {
-	addr        string
-	db          dbConfig
-	env         string
-	version     string
-	apiURL      string
-	mail        mailConfig
-	frontendURL string
-	auth        authConfig
-	redisCfg    redisConfig
-	rateLimiter ratelimiter.Config
+	addr                string
+	db                  dbConfig
+	env                 string
+	version             string
+	apiURL              string
+	jsonEncoder         string
+	mail                mailConfig
+	frontendURL         string
+	auth                authConfig
+	redisCfg            redisConfig
+	rateLimiter         ratelimiter.Config
+	botRateLimiter      ratelimiter.Config
+	followImportLimiter ratelimiter.Config
+	securityHeaders     securityHeadersConfig
+	tls                 tlsConfig
+	webauthn            webauthnConfig
+	media               mediaConfig
+	posts               postsConfig
+	notifications       notificationsConfig
+	analytics           analyticsConfig
+	breakers            breakersConfig
+	requestTimeouts     requestTimeoutsConfig
+	concurrency         concurrencyConfig
+	// publicCacheTTL is how long the anonymous /public pages are cached.
+	publicCacheTTL time.Duration
+	search         searchConfig
+	hashtags       hashtagsConfig
+	risk           riskConfig
+	announcements  announcementsConfig
+	maintenance    maintenanceConfig
+	instance       instanceConfig
+	debugCapture   debugCaptureConfig
+	terms          termsConfig
+	age            ageConfig
+	push           pushConfig
+	retention      retentionConfig
+	archive        archiveConfig
+	cacheWarm      cacheWarmConfig
+	broker         brokerConfig
+	partitions     partitionsConfig
+	counters       countersConfig
+	recovery       recoveryConfig
+	emoji          emojiConfig
+	profileFields  profileFieldsConfig
+	badges         badgesConfig
+	events         eventsConfig
+	listings       listingsConfig
+	playground     playgroundConfig
+	github         githubConfig
+	crosspost      crosspostConfig
+	emailPosts     emailPostsConfig
+	seo            seoConfig
+	pages          pagesConfig
+	spaces         spacesConfig
+	stories        storiesConfig
+	oauth          oauthConfig
+}
+
+// searchConfig enables the external search backend. Post changes reach it
+// through the outbox, drained by the indexer every interval.
+type searchConfig struct {
+	enabled  bool
+	url      string
+	apiKey   string
+	index    string
+	interval time.Duration
+}
+
+// concurrencyConfig caps requests in flight, zero means no cap. The feed cap
+// applies on top of the global one.
+type concurrencyConfig struct {
+	global     int
+	feed       int
+	retryAfter time.Duration
+}
+
+// requestTimeoutsConfig sets the request context deadline per route class. A
+// route class can only tighten the default, never extend it.
+type requestTimeoutsConfig struct {
+	defaultTimeout time.Duration
+	feed           time.Duration
+}
+
+// breakersConfig tunes the circuit breakers around Redis and the mailer:
+// they open after threshold consecutive failures and retry after cooldown.
+type breakersConfig struct {
+	threshold int
+	cooldown  time.Duration
+}
+
+type analyticsConfig struct {
+	enabled  bool
+	interval time.Duration
+	// engagementWindow is how far back comments and reactions count towards
+	// a follower's engagement score.
+	engagementWindow time.Duration
+	// timeout bounds a whole aggregation run, backfilled days included.
+	timeout time.Duration
+	// backfillDays caps how many missed days a run catches up on.
+	backfillDays int
+}
+
+type notificationsConfig struct {
+	followBackEnabled   bool
+	followBackInterval  time.Duration
+	followBackLookback  time.Duration
+	followBackDailyCap  int
+	followBackBatchSize int
+	// pollMaxWait caps how long a long poll is held, below the server's
+	// write timeout. pollRecheck is how often it checks the database, for
+	// notifications created on other instances, zero relies on wake ups
+	// alone. Open polls count against
+	// the global concurrency cap, pollMaxWaiters keeps them from taking
+	// all of it.
+	pollMaxWait    time.Duration
+	pollRecheck    time.Duration
+	pollMaxWaiters int
+}
+
+type postsConfig struct {
+	// duplicateMode is one of duplicatePostsOff, duplicatePostsWarn or
+	// duplicatePostsDeny.
+	duplicateMode   string
+	duplicateWindow time.Duration
+}
+
+type mediaConfig struct {
+	dir            string
+	baseURL        string
+	signingSecret  string
+	urlExp         time.Duration
+	maxUploadBytes int64
+	scan           mediaScanConfig
+}
+
+// mediaScanConfig enables upload scanning: hashFile lists SHA-256 hashes of
+// known bad files, url is an external classifier. With failClosed a scanner
+// error quarantines the upload instead of letting it through.
+type mediaScanConfig struct {
+	hashFile   string
+	url        string
+	apiKey     string
+	threshold  float64
+	failClosed bool
+}
+
+type webauthnConfig struct {
+	rpID    string
+	rpName  string
+	origins []string
+}
+
+type securityHeadersConfig struct {
+	enabled        bool
+	csp            string
+	swaggerCSP     string
+	referrerPolicy string
+	hstsMaxAge     time.Duration
+}
+
+type tlsConfig struct {
+	certFile string
+	keyFile  string
+}
+
+func (c tlsConfig) enabled() bool {
+	return c.certFile != "" && c.keyFile != ""
 }
 
 type redisConfig struct {
@@ -53,15 +288,41 @@ type redisConfig struct {
 	pw      string
 	db      int
 	enabled bool
+	// compat keeps cached values readable by the previous release while a
+	// cache schema bump rolls out.
+	compat bool
 }
 type authConfig struct {
-	basic basicConfig
-	token tokenConfig
+	basic           basicConfig
+	token           tokenConfig
+	cookie          cookieConfig
+	antiEnumeration bool
+	sudoWindow      time.Duration
+	// impersonationTTL is how long admins' impersonation tokens last.
+	impersonationTTL time.Duration
+	// sessionTouch is how stale a session's last seen time may get before a
+	// request updates it.
+	sessionTouch time.Duration
+}
+type cookieConfig struct {
+	enabled  bool
+	name     string
+	csrfName string
+	domain   string
+	secure   bool
+	clients  []string
 }
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret    string
+	exp       time.Duration
+	iss       string
+	keys      []string
+	activeKID string
+	// retired are "kid@time" specs of keys rotated out at time, they
+	// validate the tokens they signed for grace, by default the token
+	// lifetime.
+	retired []string
+	grace   time.Duration
 }
 type basicConfig struct {
 	user string
@@ -85,6 +346,23 @@ type dbConfig struct {
 	maxOpenConns int
 	maxIdleConns int
 	maxIdleTime  string
+	// maxLifetime recycles connections, "0" keeps them forever.
+	maxLifetime     string
+	connectAttempts int
+	readTimeout     time.Duration
+	writeTimeout    time.Duration
+	feedTimeout     time.Duration
+	// explainSlow logs the plan of feed and search queries slower than it,
+	// zero turns it off.
+	explainSlow time.Duration
+	// prepare reuses prepared statements for the hot queries.
+	prepare bool
+	// statementBudget is how many statements a request may run before it is
+	// logged as a likely N+1 query, zero turns the check off. It is meant
+	// for development and staging. enforceBudget fails the statements past
+	// the budget instead, so the request errors out.
+	statementBudget int
+	enforceBudget   bool
 }
 
 func (app *application) mount() *chi.Mux {
@@ -92,24 +370,51 @@ func (app *application) mount() *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{env.GetString("CORS_ALLOWED_ORIGIN", "http://localhost:5173")},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: false,
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "X-Client-ID"},
+		ExposedHeaders:   []string{"Link", "X-Announcement"},
+		AllowCredentials: app.config.auth.cookie.enabled,
 		MaxAge:           300, // Maximum value not ignored by any of major browsers
 	}))
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Logger)
+	r.Use(app.RouteMetricsMiddleware)
+	if app.config.db.statementBudget > 0 {
+		r.Use(app.StatementBudgetMiddleware)
+	}
+
+	if app.config.securityHeaders.enabled {
+		r.Use(app.SecurityHeadersMiddleware)
+	}
+
+	if app.captures != nil {
+		r.Use(app.DebugCaptureMiddleware)
+	}
+	r.Use(app.MaintenanceMiddleware)
 
 	if app.config.rateLimiter.Enabled {
 		r.Use(app.RateLimiterMiddleware)
 	}
 
-	r.Use(middleware.Timeout(60 * time.Second))
+	if app.banner != nil {
+		r.Use(app.AnnouncementHeaderMiddleware)
+	}
+
+	// Every request context carries a deadline so slow queries give the
+	// connection back, feeds get a tighter one on their routes.
+	r.Use(requestDeadline(app.config.requestTimeouts.defaultTimeout))
+	r.Use(app.limitInFlight(app.config.concurrency.global))
+	r.Use(app.AuthorsMiddleware)
+
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+	if app.config.pages.enabled {
+		app.mountPages(r)
+	}
 
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
+		r.Get("/health/ready", app.readinessHandler)
 		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
 
 		docsUrl := fmt.Sprintf("%s/swagger/doc.json", app.config.addr)
@@ -119,7 +424,9 @@ func (app *application) mount() *chi.Mux {
 
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
 			r.Post("/", app.createPostHandler)
+			r.Get("/", app.getPostsByIDsHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.postsContextMiddleware)
@@ -130,27 +437,285 @@ func (app *application) mount() *chi.Mux {
 				r.Route("/comments", func(r chi.Router) {
 					r.Post("/", app.createCommentHandler)
 					r.Get("/", app.getCommentsHandler)
+					r.Post("/{commentID}/hide", app.checkPostOwnership("moderator", app.hideCommentHandler))
+					r.Delete("/{commentID}/hide", app.checkPostOwnership("moderator", app.unhideCommentHandler))
+					r.Put("/{commentID}/reactions", app.reactToCommentHandler)
+					r.Delete("/{commentID}/reactions", app.removeCommentReactionHandler)
 				})
+				r.Get("/body", app.getPostBodyHandler)
+				r.Get("/insights", app.checkPostAuthor(app.getPostInsightsHandler))
+				r.Get("/crossposts", app.checkPostAuthor(app.getPostCrosspostsHandler))
+				r.Get("/reactions", app.listPostReactorsHandler)
+				r.Put("/reactions", app.reactToPostHandler)
+				r.Delete("/reactions", app.removeReactionHandler)
+				r.Put("/bookmark", app.bookmarkPostHandler)
+				r.Delete("/bookmark", app.unbookmarkPostHandler)
+				r.Put("/accepted-answer", app.acceptAnswerHandler)
+				r.Delete("/accepted-answer", app.unacceptAnswerHandler)
+			})
+		})
+		r.Post("/inbound/email", app.inboundEmailHandler)
+		r.Route("/media", func(r chi.Router) {
+			r.Get("/files/*", app.serveMediaHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.requireScope(store.ScopeResourceMedia))
+				r.Post("/", app.uploadMediaHandler)
+				r.Get("/{mediaID}", app.getMediaHandler)
+				r.Get("/{mediaID}/file", app.getOwnMediaFileHandler)
 			})
 		})
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/confirm-email/{token}", app.confirmEmailChangeHandler)
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.requireScope(store.ScopeResourceUsers))
 				r.Get("/", app.getUserHandler)
 				r.Put("/follow", app.followUserHandler)
 				r.Put("/unfollow", app.unfollowUserHandler)
+				r.Put("/subscribe", app.subscribeUserHandler)
+				r.Put("/unsubscribe", app.unsubscribeUserHandler)
 			})
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.Use(app.requireScope(store.ScopeResourcePosts))
+				r.Get("/feed/position", app.getFeedPositionHandler)
+				r.Put("/feed/position", app.setFeedPositionHandler)
+				r.Group(func(r chi.Router) {
+					r.Use(requestDeadline(app.config.requestTimeouts.feed))
+					r.Use(app.limitInFlight(app.config.concurrency.feed))
+					r.Get("/feed", app.getUserFeedHandler)
+					r.Get("/feed/updates", app.getFeedUpdatesHandler)
+					r.Get("/explore", app.getExploreFeedHandler)
+				})
+			})
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireScope(store.ScopeResourceNotifications))
+					r.Get("/notifications", app.getNotificationsHandler)
+					r.Put("/notifications/read", app.markNotificationsReadHandler)
+					r.Put("/notifications/preferences", app.setNotificationPreferencesHandler)
+					r.Put("/devices", app.registerPushDeviceHandler)
+					r.Get("/devices", app.listPushDevicesHandler)
+					r.Delete("/devices/{deviceID}", app.deletePushDeviceHandler)
+				})
+				r.Group(func(r chi.Router) {
+					r.Use(app.requireScope(store.ScopeResourceUsers))
+					r.Put("/languages", app.setPreferredLanguagesHandler)
+					r.Put("/locale", app.setLocaleHandler)
+					r.Put("/fields", app.setProfileFieldsHandler)
+					r.Put("/content-warnings", app.setContentWarningPrefHandler)
+					r.Put("/birthdate", app.setBirthdateHandler)
+					r.Put("/retention", app.setPostRetentionHandler)
+					r.Get("/insights", app.getFollowerInsightsHandler)
+					r.Get("/moderation-cases", app.listMyModerationCasesHandler)
+					r.Get("/terms", app.listAcceptedTermsHandler)
+					r.Post("/accept-terms", app.acceptTermsHandler)
+					r.With(app.RequireSudo).Patch("/email", app.changeEmailHandler)
+					r.Get("/sessions", app.listSessionsHandler)
+					r.Delete("/sessions/{sessionID}", app.revokeSessionHandler)
+					r.Get("/post-by-email", app.getEmailPostAddressHandler)
+					r.With(app.RequireSudo).Put("/post-by-email", app.rotateEmailPostAddressHandler)
+					r.Delete("/post-by-email", app.deleteEmailPostAddressHandler)
+					r.Get("/crosspost", app.listCrosspostAccountsHandler)
+					r.With(app.RequireSudo).Put("/crosspost/{network}", app.connectCrosspostHandler)
+					r.Patch("/crosspost/{network}", app.updateCrosspostHandler)
+					r.Delete("/crosspost/{network}", app.disconnectCrosspostHandler)
+					r.Get("/following/export", app.exportFollowingHandler)
+					r.Post("/following/import", app.importFollowingHandler)
+					r.With(app.RequireSudo).Put("/passwordless", app.setPasswordlessHandler)
+					r.Get("/recovery/contacts", app.listRecoveryContactsHandler)
+					r.With(app.RequireSudo).Put("/recovery/contacts", app.setRecoveryContactsHandler)
+					r.Delete("/recovery", app.cancelRecoveryHandler)
+					r.Get("/recovery/requests", app.listRecoveryRequestsHandler)
+					r.With(app.RequireSudo).Post("/recovery/requests/{requestID}/approve", app.approveRecoveryHandler)
+					r.With(app.RequireSudo).Delete("/", app.deleteAccountHandler)
+				})
 			})
 
 		})
+		r.Route("/events", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Post("/", app.createEventHandler)
+			r.Get("/", app.listUpcomingEventsHandler)
+			r.Route("/{eventID}", func(r chi.Router) {
+				r.Get("/", app.getEventHandler)
+				r.Put("/rsvp", app.rsvpEventHandler)
+				r.Delete("/rsvp", app.removeRSVPHandler)
+				r.Get("/attendees", app.listEventAttendeesHandler)
+				r.Post("/cancel", app.cancelEventHandler)
+			})
+		})
+		r.Route("/stories", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Post("/", app.createStoryHandler)
+			r.Get("/feed", app.getStoriesFeedHandler)
+			r.Get("/{storyID}", app.viewStoryHandler)
+			r.Get("/{storyID}/viewers", app.listStoryViewersHandler)
+		})
+		r.Route("/spaces", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Post("/", app.createSpaceHandler)
+			r.Get("/", app.listSpacesHandler)
+			r.Route("/{spaceID}", func(r chi.Router) {
+				r.Get("/", app.getSpaceHandler)
+				r.Post("/start", app.startSpaceHandler)
+				r.Post("/stop", app.stopSpaceHandler)
+				r.Post("/join", app.joinSpaceHandler)
+				r.Post("/leave", app.leaveSpaceHandler)
+				r.Put("/cohosts/{userID}", app.setSpaceCohostHandler)
+				r.Delete("/cohosts/{userID}", app.removeSpaceCohostHandler)
+			})
+		})
+		r.Route("/questions", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Get("/unanswered", app.listUnansweredHandler)
+		})
+		r.Route("/listings", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Post("/", app.createListingHandler)
+			r.Get("/", app.listListingsHandler)
+			r.Get("/{listingID}", app.getListingHandler)
+		})
 		//public routes
+		r.Route("/hashtags", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Get("/trending", app.getTrendingHashtagsHandler)
+			r.Get("/{tag}/related", app.getRelatedHashtagsHandler)
+		})
+		r.Route("/search", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourcePosts))
+			r.Use(requestDeadline(app.config.requestTimeouts.feed))
+			r.Use(app.limitInFlight(app.config.concurrency.feed))
+			r.Get("/posts", app.searchPostsHandler)
+		})
+		r.Route("/notifications", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourceNotifications))
+			r.With(app.limitInFlight(app.config.notifications.pollMaxWaiters)).Get("/poll", app.pollNotificationsHandler)
+			r.Post("/seen", app.markNotificationsSeenHandler)
+			r.Post("/{notificationID}/seen", app.markNotificationSeenHandler)
+		})
+		r.Get("/terms", app.getTermsHandler)
+		r.Get("/instance", app.getInstanceHandler)
+		r.Get("/emoji", app.listEmojiHandler)
+		r.Get("/emoji/{shortcode}", app.serveEmojiHandler)
+		r.Route("/announcements", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireScope(store.ScopeResourceUsers))
+			r.Get("/", app.listAnnouncementsHandler)
+			r.Post("/{announcementID}/dismiss", app.dismissAnnouncementHandler)
+		})
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/recovery", app.startRecoveryHandler)
+			r.Post("/recovery/redeem", app.redeemRecoveryHandler)
+			r.Post("/logout", app.logoutHandler)
+		})
+		r.With(app.AuthTokenMiddleware).Post("/auth/sudo", app.sudoHandler)
+		r.Route("/oauth", func(r chi.Router) {
+			r.Get("/authorize", app.getOAuthConsentHandler)
+			r.Post("/token", app.oauthTokenHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Post("/authorize", app.authorizeOAuthHandler)
+				r.Post("/clients", app.registerOAuthClientHandler)
+				r.Get("/clients", app.listOAuthClientsHandler)
+				r.Delete("/clients/{clientID}", app.revokeOAuthClientHandler)
+				r.Get("/grants", app.listOAuthGrantsHandler)
+				r.Delete("/grants/{clientID}", app.revokeOAuthGrantHandler)
+			})
+		})
+		r.Route("/public", func(r chi.Router) {
+			r.Use(app.cacheResponse(app.config.publicCacheTTL))
+			r.Get("/explore", app.publicExploreHandler)
+			r.Get("/trending", app.publicTrendingHandler)
+			r.Get("/tags/{tag}", app.publicTagHandler)
+			r.Get("/users/{userID}", app.publicProfileHandler)
+			r.Get("/posts/{postID}", app.publicPostHandler)
+			r.Get("/sitemap.xml", app.sitemapIndexHandler)
+			r.Get("/sitemaps/{section}-{page}.xml", app.sitemapHandler)
+		})
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.With(app.requireScope(store.ScopeResourceUsers)).Post("/appeals", app.createAppealHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.requireRole("moderator"))
+				r.Get("/users", app.listRiskyUsersHandler)
+				r.Get("/users/{userID}/risk", app.getUserRiskHandler)
+				r.Delete("/users/{userID}/risk", app.clearUserRiskHandler)
+				r.Post("/users/{userID}/notes", app.createUserNoteHandler)
+				r.Get("/users/{userID}/notes", app.listUserNotesHandler)
+				r.Post("/posts/{postID}/notes", app.createPostNoteHandler)
+				r.Get("/posts/{postID}/notes", app.listPostNotesHandler)
+				r.Get("/appeals", app.listAppealsHandler)
+				r.Put("/appeals/{caseID}", app.resolveAppealHandler)
+				r.Get("/media", app.listMediaForReviewHandler)
+				r.Get("/media/{mediaID}/file", app.getMediaForReviewHandler)
+				r.Put("/media/{mediaID}", app.reviewMediaHandler)
+				r.Get("/listings", app.listPendingListingsHandler)
+				r.Put("/listings/{listingID}", app.reviewListingHandler)
+			})
+		})
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.requireRole("admin"))
+			r.Get("/analytics/activity", app.getPlatformActivityHandler)
+			r.Get("/analytics/retention", app.getRetentionCohortsHandler)
+			r.Get("/analytics/hashtags", app.getTopHashtagsHandler)
+			r.Post("/holds", app.placeLegalHoldHandler)
+			r.Get("/holds", app.listLegalHoldsHandler)
+			r.Delete("/holds/{holdID}", app.releaseLegalHoldHandler)
+			r.Get("/holds/{holdID}/export", app.exportLegalHoldHandler)
+			r.Get("/archive/posts", app.listArchivedPostsHandler)
+			r.Post("/archive/posts/{postID}/restore", app.restoreArchivedPostHandler)
+			r.Post("/cache/warm", app.warmCacheHandler)
+			if app.captures != nil {
+				r.With(app.RequireSudo).Post("/debug-capture", app.startDebugCaptureHandler)
+				r.Get("/debug-capture", app.getDebugCaptureHandler)
+				r.Delete("/debug-capture", app.stopDebugCaptureHandler)
+			}
+			r.Post("/maintenance", app.createMaintenanceHandler)
+			r.Get("/maintenance", app.listMaintenanceHandler)
+			r.Delete("/maintenance/{windowID}", app.endMaintenanceHandler)
+			r.Post("/announcements", app.createAnnouncementHandler)
+			r.Get("/announcements", app.listAllAnnouncementsHandler)
+			r.Delete("/announcements/{announcementID}", app.deleteAnnouncementHandler)
+			r.Post("/emoji", app.uploadEmojiHandler)
+			r.Delete("/emoji/{shortcode}", app.deleteEmojiHandler)
+			r.Post("/terms", app.publishTermsHandler)
+			r.Put("/instance", app.updateInstanceHandler)
+			r.Get("/terms", app.listTermsHandler)
+			r.Post("/merges", app.mergeAccountsHandler)
+			r.Get("/merges", app.listAccountMergesHandler)
+			r.With(app.RequireSudo).Post("/service-accounts", app.createServiceAccountHandler)
+			r.Get("/service-accounts/{userID}/keys", app.listAPIKeysHandler)
+			r.With(app.RequireSudo).Post("/service-accounts/{userID}/keys", app.createAPIKeyHandler)
+			r.With(app.RequireSudo).Delete("/service-accounts/{userID}/keys/{keyID}", app.revokeAPIKeyHandler)
+			r.With(app.RequireSudo).Post("/users/{userID}/impersonate", app.impersonateHandler)
+			r.Put("/users/{userID}/badges/{badge}", app.grantBadgeHandler)
+			r.Delete("/users/{userID}/badges/{badge}", app.revokeBadgeHandler)
+		})
+		r.Route("/auth/webauthn", func(r chi.Router) {
+			r.Post("/login/begin", app.beginPasskeyLoginHandler)
+			r.Post("/login/finish", app.finishPasskeyLoginHandler)
+			r.Group(func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.With(app.RequireSudo).Post("/register/begin", app.beginPasskeyRegistrationHandler)
+				r.With(app.RequireSudo).Post("/register/finish", app.finishPasskeyRegistrationHandler)
+				r.Get("/credentials", app.listPasskeysHandler)
+				r.With(app.RequireSudo).Delete("/credentials/{credentialID}", app.deletePasskeyHandler)
+			})
 		})
 
 	})
@@ -180,8 +745,13 @@ func (app *application) run(mux *chi.Mux) error {
 		shutdown <- srv.Shutdown(ctx)
 	}()
 
-	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env)
-	err := srv.ListenAndServe()
+	app.logger.Infow("server has started", "addr", app.config.addr, "env", app.config.env, "tls", app.config.tls.enabled())
+	var err error
+	if app.config.tls.enabled() {
+		err = srv.ListenAndServeTLS(app.config.tls.certFile, app.config.tls.keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
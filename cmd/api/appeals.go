@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// openModerationCase records an enforcement action so the affected user can
+// appeal it, and tells them about it. It never fails the action itself.
+func (app *application) openModerationCase(ctx context.Context, c *store.ModerationCase) {
+	if err := app.store.ModerationCases.Create(ctx, c); err != nil {
+		app.logger.Errorw("error opening moderation case", "user_id", c.UserID, "action", c.Action, "error", err.Error())
+		return
+	}
+	app.notifyModeration(ctx, c, store.NotificationModerationAction)
+}
+
+// notifyModeration notifies the user of their case. The user is their own
+// actor, moderators stay anonymous to the people they act on.
+func (app *application) notifyModeration(ctx context.Context, c *store.ModerationCase, notificationType string) {
+	n := []store.Notification{{UserID: c.UserID, ActorID: c.UserID, Type: notificationType}}
+	if err := app.createNotifications(ctx, n); err != nil {
+		app.logger.Errorw("error notifying moderation case", "case_id", c.ID, "error", err.Error())
+	}
+}
+
+// redactModerators hides who handled the cases before they go to the user.
+func redactModerators(cases []store.ModerationCase) {
+	for i := range cases {
+		cases[i].ModeratorID = 0
+		cases[i].ResolvedBy = 0
+	}
+}
+
+// ListMyModerationCases godoc
+//
+//	@Summary		List moderation cases
+//	@Description	Enforcement actions taken against the authenticated user, newest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.ModerationCase
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/moderation-cases [get]
+func (app *application) listMyModerationCasesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cases, err := app.store.ModerationCases.ListByUser(r.Context(), getUserFromContext(r).ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	redactModerators(cases)
+	if err := app.jsonResponse(w, http.StatusOK, cases); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type CreateAppealPayload struct {
+	CaseID int64  `json:"case_id" validate:"required"`
+	Text   string `json:"text" validate:"required,max=2000"`
+}
+
+// CreateAppeal godoc
+//
+//	@Summary		Appeal a moderation action
+//	@Description	Files an appeal of one of the authenticated user's moderation cases, once per case
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateAppealPayload	true	"Appeal"
+//	@Success		201		{object}	store.ModerationCase
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Already appealed"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/appeals [post]
+func (app *application) createAppealHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateAppealPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	c, err := app.store.ModerationCases.Appeal(r.Context(), payload.CaseID, getUserFromContext(r).ID, payload.Text)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrAppealExists):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	c.ModeratorID, c.ResolvedBy = 0, 0
+	if err := app.jsonResponse(w, http.StatusCreated, c); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAppeals godoc
+//
+//	@Summary		Appeal review queue
+//	@Description	Appeals in the given status, oldest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			status	query		string	false	"pending (default), upheld or overturned"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.ModerationCase
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/appeals [get]
+func (app *application) listAppealsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = store.AppealPending
+	}
+	if err := Validate.Var(status, "oneof=pending upheld overturned"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cases, err := app.store.ModerationCases.ListAppeals(r.Context(), status, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, cases); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ResolveAppealPayload struct {
+	Decision string `json:"decision" validate:"required,oneof=upheld overturned"`
+	Note     string `json:"note" validate:"max=2000"`
+}
+
+// ResolveAppeal godoc
+//
+//	@Summary		Decide an appeal
+//	@Description	Upholds or overturns a pending appeal and notifies the user. Overturning unhides a hidden comment, removed posts are gone for good
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			caseID	path		int						true	"Case ID"
+//	@Param			payload	body		ResolveAppealPayload	true	"Decision"
+//	@Success		200		{object}	store.ModerationCase
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Appeal is not pending"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/appeals/{caseID} [put]
+func (app *application) resolveAppealHandler(w http.ResponseWriter, r *http.Request) {
+	caseID, err := strconv.ParseInt(chi.URLParam(r, "caseID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload ResolveAppealPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	moderator := getUserFromContext(r)
+	c, err := app.store.ModerationCases.Resolve(ctx, caseID, moderator.ID, payload.Decision, payload.Note)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrAppealNotPending):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	if c.AppealStatus == store.AppealOverturned && c.Action == store.ModerationCommentHidden {
+		if err := app.store.Comments.SetHidden(ctx, c.SubjectID, false, moderator.ID); err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	app.notifyModeration(ctx, c, store.NotificationAppealResolved)
+
+	if err := app.jsonResponse(w, http.StatusOK, c); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
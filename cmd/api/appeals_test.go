@@ -0,0 +1,53 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAppeals(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	cases := &store.MockModerationCaseStore{Cases: []store.ModerationCase{
+		{ID: 1, UserID: 42, Action: store.ModerationCommentHidden, SubjectType: store.CaseSubjectComment, SubjectID: 9, ModeratorID: 3},
+		{ID: 2, UserID: 7, Action: store.ModerationPostRemoved, SubjectType: store.CaseSubjectPost, SubjectID: 5, ModeratorID: 3},
+	}}
+	app.store.ModerationCases = cases
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should let users appeal their own cases once", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/moderation/appeals", `{"case_id":2,"text":"not mine"}`))
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/moderation/appeals", `{"case_id":1,"text":"it was a joke"}`))
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPost, "/v1/moderation/appeals", `{"case_id":1,"text":"again"}`))
+	})
+
+	t.Run("should keep the review queue to moderators", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/moderation/appeals", ""))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPut, "/v1/moderation/appeals/1", `{"decision":"overturned"}`))
+	})
+
+	t.Run("should resolve pending appeals", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/moderation/appeals", ""))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/moderation/appeals/1", `{"decision":"maybe"}`))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/moderation/appeals/1", `{"decision":"overturned","note":"satire"}`))
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPut, "/v1/moderation/appeals/1", `{"decision":"upheld"}`))
+		if cases.Cases[0].AppealStatus != store.AppealOverturned {
+			t.Errorf("expected the appeal to be overturned, got %q", cases.Cases[0].AppealStatus)
+		}
+	})
+}
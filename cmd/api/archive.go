@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// archiveConfig moves posts older than after years to cold storage in dir.
+type archiveConfig struct {
+	enabled   bool
+	after     int
+	dir       string
+	interval  time.Duration
+	batchSize int
+}
+
+func archiveKey(postID int64) string {
+	return fmt.Sprintf("archive/posts/%d.json.gz", postID)
+}
+
+// putArchive writes the archive gzipped to the archive store.
+func (app *application) putArchive(ctx context.Context, postID int64, archive *store.PostArchive) (string, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(archive); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+	key := archiveKey(postID)
+	if err := app.archiveStore.Put(ctx, key, &buf); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (app *application) getArchive(ctx context.Context, key string) (*store.PostArchive, error) {
+	rc, err := app.archiveStore.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var archive store.PostArchive
+	if err := json.NewDecoder(zr).Decode(&archive); err != nil {
+		return nil, err
+	}
+	return &archive, nil
+}
+
+// archivePosts moves one batch of old posts to cold storage, then drops the
+// archives of users that have since been deleted.
+func (app *application) archivePosts(ctx context.Context) error {
+	cutoff := time.Now().AddDate(-app.config.archive.after, 0, 0)
+	ids, err := app.store.Archive.Candidates(ctx, cutoff, app.config.archive.batchSize)
+	if err != nil {
+		return err
+	}
+	archived := 0
+	for _, id := range ids {
+		err := app.store.Archive.Archive(ctx, id, func(ctx context.Context, archive *store.PostArchive) (string, error) {
+			return app.putArchive(ctx, id, archive)
+		})
+		switch {
+		case err == nil:
+			archived++
+		case errors.Is(err, store.ErrRecordNotFound), errors.Is(err, store.ErrUnderLegalHold), errors.Is(err, store.ErrNotArchivable):
+		default:
+			app.logger.Errorw("archiving post", "post_id", id, "error", err)
+		}
+	}
+	if archived > 0 {
+		app.logger.Infow("archived posts", "count", archived)
+	}
+
+	orphans, err := app.store.Archive.PruneOrphans(ctx, app.config.archive.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, a := range orphans {
+		if err := app.archiveStore.Delete(ctx, a.BlobKey); err != nil {
+			app.logger.Errorw("deleting archive", "key", a.BlobKey, "error", err)
+		}
+	}
+	return nil
+}
+
+// ListArchivedPosts godoc
+//
+//	@Summary		List archived posts
+//	@Description	Posts moved to cold storage, newest archive first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			user_id	query		int	false	"Only this author's posts"
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.ArchivedPost
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/archive/posts [get]
+func (app *application) listArchivedPostsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var userID int64
+	if v := r.URL.Query().Get("user_id"); v != "" {
+		userID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+	posts, err := app.store.Archive.List(r.Context(), userID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RestoreArchivedPost godoc
+//
+//	@Summary		Restore an archived post
+//	@Description	Brings the post back from cold storage with its comments and reactions, under its original ID
+//	@Tags			admin
+//	@Param			postID	path		int		true	"Post ID"
+//	@Success		204		{string}	string	"Restored"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/archive/posts/{postID}/restore [post]
+func (app *application) restoreArchivedPostHandler(w http.ResponseWriter, r *http.Request) {
+	postID, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	tombstone, err := app.store.Archive.Get(ctx, postID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	archive, err := app.getArchive(ctx, tombstone.BlobKey)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Archive.Restore(ctx, postID, archive); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.archiveStore.Delete(ctx, tombstone.BlobKey); err != nil {
+		app.logger.Errorw("deleting archive", "key", tombstone.BlobKey, "error", err)
+	}
+	app.auditLog("post.restore", getUserFromContext(r).ID, "post_id", postID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/store"
+	"net/http"
+	"testing"
+)
+
+func TestPostArchive(t *testing.T) {
+	app := NewTestApplication(t, config{archive: archiveConfig{after: 3, batchSize: 10}})
+	archiveStore, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.archiveStore = archiveStore
+	archive := &store.MockArchiveStore{Posts: []int64{7, 8}}
+	app.store.Archive = archive
+	app.store.Users = &serviceUserStore{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should move old posts to cold storage", func(t *testing.T) {
+		if err := app.archivePosts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(archive.Posts) != 0 || len(archive.Archived) != 2 {
+			t.Fatalf("unexpected archive %+v", archive)
+		}
+		stored, err := app.getArchive(context.Background(), archiveKey(7))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(stored.Post) != `{"id":7,"user_id":1}` || string(stored.Comments) != `[{"id":1,"post_id":7}]` {
+			t.Fatalf("unexpected stored archive %+v", stored)
+		}
+	})
+
+	t.Run("should list archived posts", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/archive/posts?user_id=1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.ArchivedPost](t, rr.Body.String()); len(got) != 2 {
+			t.Fatalf("got %d archived posts, want 2", len(got))
+		}
+	})
+
+	restore := func(t *testing.T, path string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should restore a post and drop its archive", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, restore(t, "/v1/admin/archive/posts/7/restore"))
+		if len(archive.Restored) != 1 || archive.Restored[0] != 7 {
+			t.Fatalf("unexpected restored posts %v", archive.Restored)
+		}
+		if _, err := app.getArchive(context.Background(), archiveKey(7)); err == nil {
+			t.Fatal("archive blob should be deleted after restore")
+		}
+	})
+
+	t.Run("should not restore a post twice", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, restore(t, "/v1/admin/archive/posts/7/restore"))
+	})
+}
+
+func TestPostArchiveKeepsRowShape(t *testing.T) {
+	app := NewTestApplication(t, config{archive: archiveConfig{after: 3, batchSize: 10}})
+	archiveStore, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.archiveStore = archiveStore
+	app.store.Users = &serviceUserStore{}
+
+	// The rows as row_to_json writes them, with every column of the tables.
+	rows := store.PostArchive{
+		Post:       []byte(`{"id":9,"title":"How do I close a channel?","user_id":1,"content":"See below","created_at":"2022-03-01T10:00:00+00:00","tags":["go","channels"],"updated_at":"2022-03-02T10:00:00+00:00","version":2,"lang":"en","content_warning":"","kind":"question","fingerprint":"ab12","comments_count":2,"reactions_count":1,"has_media":false,"on_hold":false,"age_restricted":false,"views_count":40,"accepted_answer_id":12,"expires_at":null}`),
+		Body:       []byte(`null`),
+		Comments:   []byte(`[{"id":11,"post_id":9,"user_id":2,"content":"Which one?","created_at":"2022-03-01T11:00:00+00:00","parent_id":null,"hidden_at":null,"hidden_by":null,"is_answer":false},{"id":12,"post_id":9,"user_id":3,"content":"The sender closes it","created_at":"2022-03-01T12:00:00+00:00","parent_id":11,"hidden_at":null,"hidden_by":null,"is_answer":true}]`),
+		Reactions:  []byte(`[{"subject_type":"post","subject_id":9,"user_id":2,"type":"like","created_at":"2022-03-01T11:00:00+00:00"}]`),
+		Snippets:   []byte(`[{"id":5,"post_id":9,"position":0,"language":"go","content":"close(ch)","playground_url":""}]`),
+		RepoCard:   []byte(`null`),
+		Crossposts: []byte(`[]`),
+	}
+	archive := &store.MockArchiveStore{Posts: []int64{9}, Rows: map[int64]store.PostArchive{9: rows}}
+	app.store.Archive = archive
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.archivePosts(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, "/v1/admin/archive/posts/9/restore", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, app.mount())
+	checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+	if len(archive.RestoredArchives) != 1 {
+		t.Fatalf("got %d restored archives, want 1", len(archive.RestoredArchives))
+	}
+	got := archive.RestoredArchives[0]
+	for name, pair := range map[string][2][]byte{
+		"post":       {rows.Post, got.Post},
+		"body":       {rows.Body, got.Body},
+		"comments":   {rows.Comments, got.Comments},
+		"reactions":  {rows.Reactions, got.Reactions},
+		"snippets":   {rows.Snippets, got.Snippets},
+		"repo_card":  {rows.RepoCard, got.RepoCard},
+		"crossposts": {rows.Crossposts, got.Crossposts},
+	} {
+		if string(pair[0]) != string(pair[1]) {
+			t.Errorf("%s changed on the way through cold storage:\n got %s\nwant %s", name, pair[1], pair[0])
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+// auditLog records a privileged action. Entries go through the structured
+// logger with a fixed "audit" message so they can be filtered and shipped
+// separately from request logs.
+func (app *application) auditLog(action string, actorID int64, kv ...any) {
+	args := append([]any{"action", action, "actor_id", actorID}, kv...)
+	app.logger.Infow("audit", args...)
+}
@@ -3,7 +3,9 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"gopher_social/internal/i18n"
 	"gopher_social/internal/mailer"
 	"gopher_social/internal/store"
 	"net/http"
@@ -17,6 +19,12 @@ type RegisterUserPayload struct {
 	Username string `json:"username" validate:"required,max=100"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=255"`
+	// Birthdate is YYYY-MM-DD and must meet the minimum age.
+	Birthdate string `json:"birthdate" validate:"required"`
+	// Locale defaults to the best match for Accept-Language and Timezone
+	// to UTC.
+	Locale   string `json:"locale" validate:"omitempty,max=10"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 type UserWithToken struct {
@@ -24,6 +32,15 @@ type UserWithToken struct {
 	Token string `json:"token"`
 }
 
+// registrationAcceptedMessage is returned for every registration attempt when
+// anti-enumeration is enabled, so the response can't reveal whether the
+// email or username was already taken.
+const registrationAcceptedMessage = "If the details are valid, a confirmation email is on its way"
+
+// dummyUser is compared against when a login email is unknown, so a missing
+// account costs the same bcrypt round as a wrong password.
+var dummyUser, _ = store.NewUser("", "", uuid.New().String())
+
 // registerUserHandler godoc
 //
 //	@Summary		Register a user
@@ -33,6 +50,7 @@ type UserWithToken struct {
 //	@Produce		json
 //	@Param			user	body		RegisterUserPayload	true	"User credentials"
 //	@Success		201		{object}	UserWithToken
+//	@Success		202		{string}	string	"Accepted (anti-enumeration mode)"
 //	@Failure		400		{object}	error
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
@@ -47,9 +65,22 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	if err := app.checkBirthdate(payload.Birthdate); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.Locale == "" {
+		payload.Locale = requestLocale(r)
+	} else if !i18n.Supported(payload.Locale) {
+		app.badRequestResponse(w, r, errUnsupportedLocale)
+		return
+	}
 	user := &store.User{
-		Username: payload.Username,
-		Email:    payload.Email,
+		Username:  payload.Username,
+		Email:     payload.Email,
+		Birthdate: &payload.Birthdate,
+		Locale:    payload.Locale,
+		Timezone:  payload.Timezone,
 		Role: &store.Role{
 			Name: "user",
 		},
@@ -68,9 +99,11 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	err := app.store.Users.CreateAndInvite(ctx, user, hashToken, app.config.mail.exp)
 	if err != nil {
 		switch err {
-		case store.ErrDuplicateEmail:
-			app.badRequestResponse(w, r, err)
-		case store.ErrDuplicateUsername:
+		case store.ErrDuplicateEmail, store.ErrDuplicateUsername:
+			if app.config.auth.antiEnumeration {
+				app.notifyDuplicateRegistration(w, r, user, err)
+				return
+			}
 			app.badRequestResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -78,13 +111,14 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.recordSignupSignals(ctx, user)
+
 	userWIthToken := UserWithToken{
 		User:  user,
 		Token: plainToken,
 	}
 	activationURL := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
 
-	isProdEnv := app.config.env == "production"
 	vars := struct {
 		Username      string
 		ActivationURL string
@@ -92,8 +126,8 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Username:      user.Username,
 		ActivationURL: activationURL,
 	}
-	// send mail
-	status, err := app.mailer.Send(mailer.UserWelcomeTemplate, user.Username, user.Email, vars, !isProdEnv)
+	// send mail, or queue it while the provider is down
+	queued, err := app.sendMail(ctx, mailer.UserWelcomeTemplate, user.Locale, user.Username, user.Email, vars)
 	if err != nil {
 		app.logger.Errorw("error sending welcome email", "error", err.Error())
 
@@ -105,12 +139,46 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		app.internalServerError(w, r, err)
 		return
 	}
-	app.logger.Infow("Email sent", "status code", status)
+	app.logger.Infow("welcome email sent", "queued", queued)
+	if app.config.auth.antiEnumeration {
+		if err := app.jsonResponse(w, http.StatusAccepted, registrationAcceptedMessage); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 	if err := app.jsonResponse(w, http.StatusCreated, userWIthToken); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
 
+// notifyDuplicateRegistration answers a registration for an existing email or
+// username exactly like a successful one, and tells the owner of the supplied
+// address what happened by email instead.
+func (app *application) notifyDuplicateRegistration(w http.ResponseWriter, r *http.Request, user *store.User, cause error) {
+	field := "email"
+	if cause == store.ErrDuplicateUsername {
+		field = "username"
+	}
+	app.logger.Warnw("duplicate registration attempt", "field", field, "error", cause.Error())
+
+	vars := struct {
+		Username string
+		Field    string
+		LoginURL string
+	}{
+		Username: user.Username,
+		Field:    field,
+		LoginURL: fmt.Sprintf("%s/login", app.config.frontendURL),
+	}
+	if _, err := app.sendMail(r.Context(), mailer.AccountExistsTemplate, user.Locale, user.Username, user.Email, vars); err != nil {
+		app.logger.Errorw("error sending account exists email", "error", err.Error())
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, registrationAcceptedMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 type CreateUserTokenPayload struct {
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
@@ -123,10 +191,11 @@ type CreateUserTokenPayload struct {
 //	@Tags			authentication
 //	@Accept			json
 //	@Produce		json
-//	@Param			payload	body		CreateUserTokenPayload	true	"User credentials"
-//	@Success		200		{object}	string
-//	@Failure		400		{object}	error
-//	@Failure		500		{object}	error
+//	@Param			payload		body		CreateUserTokenPayload	true	"User credentials"
+//	@Param			X-Client-ID	header		string					false	"Client ID, cookie session clients get an HttpOnly cookie"
+//	@Success		200			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -144,6 +213,9 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 	if err != nil {
 		switch err {
 		case store.ErrRecordNotFound:
+			if app.config.auth.antiEnumeration {
+				_ = dummyUser.Password.Compare(payload.Password)
+			}
 			app.unauthorizedErrorResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -155,22 +227,112 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		app.unauthorizedErrorResponse(w, r, err)
 		return
 	}
+	if user.Passwordless {
+		app.unauthorizedErrorResponse(w, r, errors.New("password login is disabled for this account"))
+		return
+	}
+	app.issueToken(w, r, user)
+}
+
+// issueToken signs a token for the user and sends it to the client, either in
+// the body or as session cookies depending on the client.
+func (app *application) issueToken(w http.ResponseWriter, r *http.Request, user *store.User) {
+	expiresAt := time.Now().Add(app.config.auth.token.exp)
+	sid, err := app.startSession(r, user, expiresAt)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 	// generate token ->add claims
 	claims := jwt.MapClaims{
 		"sub": user.ID,
-		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
+		"sid": sid,
+		"exp": expiresAt.Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
 		"iss": app.config.auth.token.iss,
 		"aud": app.config.auth.token.iss,
+		// every issued token follows a fresh authentication, RequireSudo
+		// compares against this
+		"auth_time": time.Now().Unix(),
 	}
 	token, err := app.authenticator.GenerateToken(claims)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	if app.usesCookieSession(r) {
+		csrfToken, err := app.setSessionCookies(w, token)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if err := app.jsonResponse(w, http.StatusOK, SessionResponse{CSRFToken: csrfToken}); err != nil {
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
 	//send it to client
 	if err := app.jsonResponse(w, http.StatusOK, token); err != nil {
 		app.internalServerError(w, r, err)
 	}
 }
+
+type SudoPayload struct {
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// sudoHandler godoc
+//
+//	@Summary		Re-authenticate (sudo mode)
+//	@Description	Confirms the password again and issues a fresh token that unlocks sensitive actions for a few minutes
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		SudoPayload	true	"Current password"
+//	@Success		200		{object}	string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/sudo [post]
+func (app *application) sudoHandler(w http.ResponseWriter, r *http.Request) {
+	var payload SudoPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	// the cached user carries no password hash, always go to the database
+	user, err := app.store.Users.GetByID(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if user.Passwordless {
+		app.unauthorizedErrorResponse(w, r, errors.New("passwordless accounts confirm with a passkey login"))
+		return
+	}
+	if err := user.Password.Compare(payload.Password); err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+	app.issueToken(w, r, user)
+}
+
+// jwksHandler godoc
+//
+//	@Summary		JSON Web Key Set
+//	@Description	Public keys used to verify issued tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	if err := writeJSON(w, http.StatusOK, app.authenticator.JWKS()); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"gopher_social/internal/auth"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestSigningKey(t *testing.T, kid string) *auth.Key {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := auth.NewKeyFromPEM(kid, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	now := time.Now()
+	claims := func(issuedAt time.Time) jwt.MapClaims {
+		return jwt.MapClaims{"sub": 1, "aud": "gophersocial", "iss": "gophersocial", "iat": issuedAt.Unix(), "exp": now.Add(time.Hour).Unix()}
+	}
+	sign := func(t *testing.T, a auth.Authenticator, c jwt.MapClaims) string {
+		t.Helper()
+		token, err := a.GenerateToken(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	rotated := func(t *testing.T, retired []string) (*auth.JWTAuthenticator, *auth.Key, *auth.Key) {
+		t.Helper()
+		oldKey, newKey := newTestSigningKey(t, "2026-09"), newTestSigningKey(t, "2026-10")
+		keys, err := auth.NewKeySet("2026-10", oldKey, newKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.RetireFromSpecs(retired, time.Hour, "secret"); err != nil {
+			t.Fatal(err)
+		}
+		return auth.NewJWTAuthenticatorWithKeys(keys, "gophersocial", "gophersocial"), oldKey, newKey
+	}
+	signedBy := func(t *testing.T, key *auth.Key, c jwt.MapClaims) string {
+		t.Helper()
+		keys, err := auth.NewKeySet(key.ID, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sign(t, auth.NewJWTAuthenticatorWithKeys(keys, "gophersocial", "gophersocial"), c)
+	}
+
+	t.Run("should validate the old key's tokens during the grace period", func(t *testing.T) {
+		a, oldKey, newKey := rotated(t, []string{"2026-09@" + now.Add(-time.Minute).Format(time.RFC3339)})
+		if _, err := a.ValidateToken(signedBy(t, oldKey, claims(now.Add(-time.Hour)))); err != nil {
+			t.Errorf("expected the old token to validate, got %v", err)
+		}
+		token := sign(t, a, claims(now))
+		if _, err := a.ValidateToken(token); err != nil {
+			t.Errorf("expected the new key's token to validate, got %v", err)
+		}
+		if parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{}); parsed.Header["kid"] != newKey.ID {
+			t.Errorf("expected tokens signed with the active key, got kid %v", parsed.Header["kid"])
+		}
+		if got := len(a.JWKS().Keys); got != 2 {
+			t.Errorf("expected both keys published, got %d", got)
+		}
+	})
+
+	t.Run("should reject tokens the old key signed after it was retired", func(t *testing.T) {
+		a, oldKey, _ := rotated(t, []string{"2026-09@" + now.Add(-time.Minute).Format(time.RFC3339)})
+		if _, err := a.ValidateToken(signedBy(t, oldKey, claims(now))); !errors.Is(err, auth.ErrRetiredKey) {
+			t.Errorf("expected ErrRetiredKey, got %v", err)
+		}
+	})
+
+	t.Run("should drop the old key after the grace period", func(t *testing.T) {
+		a, oldKey, _ := rotated(t, []string{"2026-09@" + now.Add(-2*time.Hour).Format(time.RFC3339)})
+		if _, err := a.ValidateToken(signedBy(t, oldKey, claims(now.Add(-3*time.Hour)))); !errors.Is(err, auth.ErrRetiredKey) {
+			t.Errorf("expected ErrRetiredKey, got %v", err)
+		}
+		if jwks := a.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "2026-10" {
+			t.Errorf("expected only the active key published, got %+v", jwks.Keys)
+		}
+	})
+
+	t.Run("should keep accepting the shared secret while it is retired", func(t *testing.T) {
+		legacy := sign(t, auth.NewJWTAuthenticator("secret", "gophersocial", "gophersocial"), claims(now.Add(-time.Hour)))
+		a, _, _ := rotated(t, []string{"default@" + now.Add(-time.Minute).Format(time.RFC3339)})
+		if _, err := a.ValidateToken(legacy); err != nil {
+			t.Errorf("expected the legacy token to validate, got %v", err)
+		}
+		a, _, _ = rotated(t, nil)
+		if _, err := a.ValidateToken(legacy); err == nil {
+			t.Error("expected the legacy token to be rejected once the secret is not retired")
+		}
+	})
+
+	t.Run("should refuse to retire the active key or a malformed spec", func(t *testing.T) {
+		keys, err := auth.NewKeySet("2026-10", newTestSigningKey(t, "2026-10"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.Retire("2026-10", now, time.Hour); !errors.Is(err, auth.ErrRetireActive) {
+			t.Errorf("expected ErrRetireActive, got %v", err)
+		}
+		if err := keys.RetireFromSpecs([]string{"2026-09"}, time.Hour, ""); !errors.Is(err, auth.ErrMalformedRetired) {
+			t.Errorf("expected ErrMalformedRetired, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"sync"
+)
+
+type authorsKey string
+
+const authorsCtx authorsKey = "authors"
+
+// authorCache memoizes author profiles for the lifetime of a request, so a
+// feed and its comment previews, or several lists in one response, load each
+// author once.
+type authorCache struct {
+	mu    sync.Mutex
+	users map[int64]store.User
+}
+
+// AuthorsMiddleware gives every request its own authorCache.
+func (app *application) AuthorsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), authorsCtx, &authorCache{users: make(map[int64]store.User)})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// loadAuthors returns the profiles of ids, fetching the ones not cached yet
+// in a single Users.GetByIDs call. Outside a request nothing is cached.
+func (app *application) loadAuthors(ctx context.Context, ids []int64) (map[int64]store.User, error) {
+	cache, ok := ctx.Value(authorsCtx).(*authorCache)
+	if !ok {
+		cache = &authorCache{users: make(map[int64]store.User)}
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var missing []int64
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if _, ok := cache.users[id]; !ok && !seen[id] {
+			missing = append(missing, id)
+		}
+		seen[id] = true
+	}
+	if len(missing) > 0 {
+		users, err := app.store.Users.GetByIDs(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			cache.users[u.ID] = u
+		}
+	}
+
+	authors := make(map[int64]store.User, len(seen))
+	for id := range seen {
+		if u, ok := cache.users[id]; ok {
+			authors[id] = u
+		}
+	}
+	return authors, nil
+}
+
+// hydrateFeedAuthors fills in the authors of the feed items and of their
+// comment previews.
+func (app *application) hydrateFeedAuthors(ctx context.Context, feed []store.PostWithMetadata) error {
+	ids := make([]int64, 0, len(feed))
+	for _, post := range feed {
+		ids = append(ids, post.UserID)
+		if post.TopComment != nil {
+			ids = append(ids, post.TopComment.UserID)
+		}
+	}
+	authors, err := app.loadAuthors(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range feed {
+		feed[i].User = authors[feed[i].UserID]
+		if c := feed[i].TopComment; c != nil {
+			c.User = authors[c.UserID]
+		}
+	}
+	return nil
+}
+
+func (app *application) hydratePostAuthors(ctx context.Context, posts []store.Post) error {
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.UserID
+	}
+	authors, err := app.loadAuthors(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].User = authors[posts[i].UserID]
+	}
+	return nil
+}
+
+func (app *application) hydrateCommentAuthors(ctx context.Context, comments []store.Comment) error {
+	ids := make([]int64, len(comments))
+	for i, c := range comments {
+		ids[i] = c.UserID
+	}
+	authors, err := app.loadAuthors(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].User = authors[comments[i].UserID]
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"slices"
+	"testing"
+)
+
+// batchUserStore records the IDs of every GetByIDs call.
+type batchUserStore struct {
+	store.MockUserStore
+	batches [][]int64
+}
+
+func (m *batchUserStore) GetByIDs(ctx context.Context, ids []int64) ([]store.User, error) {
+	m.batches = append(m.batches, slices.Clone(ids))
+	return m.MockUserStore.GetByIDs(ctx, ids)
+}
+
+func TestHydrateAuthors(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	users := &batchUserStore{}
+	app.store.Users = users
+	ctx := context.WithValue(context.Background(), authorsCtx, &authorCache{users: make(map[int64]store.User)})
+
+	feed := []store.PostWithMetadata{
+		{Post: store.Post{ID: 1, UserID: 1}},
+		{Post: store.Post{ID: 2, UserID: 2}, TopComment: &store.Comment{ID: 1, UserID: 3}},
+		{Post: store.Post{ID: 3, UserID: 1}},
+	}
+
+	t.Run("should load every author of a feed in one batch", func(t *testing.T) {
+		if err := app.hydrateFeedAuthors(ctx, feed); err != nil {
+			t.Fatal(err)
+		}
+		if len(users.batches) != 1 || !slices.Equal(users.batches[0], []int64{1, 2, 3}) {
+			t.Fatalf("unexpected batches %v", users.batches)
+		}
+		if feed[2].User.Username != "user1" || feed[1].TopComment.User.Username != "user3" {
+			t.Fatalf("authors not hydrated: %+v, %+v", feed[2].User, feed[1].TopComment.User)
+		}
+	})
+
+	t.Run("should only fetch authors not seen earlier in the request", func(t *testing.T) {
+		comments := []store.Comment{{ID: 2, UserID: 2}, {ID: 3, UserID: 4}}
+		if err := app.hydrateCommentAuthors(ctx, comments); err != nil {
+			t.Fatal(err)
+		}
+		if len(users.batches) != 2 || !slices.Equal(users.batches[1], []int64{4}) {
+			t.Fatalf("unexpected batches %v", users.batches)
+		}
+		if comments[0].User.Username != "user2" || comments[1].User.Username != "user4" {
+			t.Fatalf("authors not hydrated: %+v", comments)
+		}
+	})
+
+	t.Run("should not query when every author is cached", func(t *testing.T) {
+		posts := []store.Post{{ID: 4, UserID: 3}}
+		if err := app.hydratePostAuthors(ctx, posts); err != nil {
+			t.Fatal(err)
+		}
+		if len(users.batches) != 2 || posts[0].User.Username != "user3" {
+			t.Fatalf("unexpected batches %v, author %+v", users.batches, posts[0].User)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// badgesConfig paces the automated badges: every interval staff badges
+// follow roles and the first earlyAdopters accounts get theirs.
+type badgesConfig struct {
+	interval      time.Duration
+	earlyAdopters int
+}
+
+// assignBadges applies the automated badge criteria.
+func (app *application) assignBadges(ctx context.Context) error {
+	changed, err := app.store.Badges.Assign(ctx, "moderator", app.config.badges.earlyAdopters)
+	if err != nil {
+		return err
+	}
+	if app.config.redisCfg.enabled {
+		for _, id := range changed {
+			app.cacheStorage.Users.Delete(ctx, id)
+		}
+	}
+	if len(changed) > 0 {
+		app.logger.Infow("badges assigned", "users", len(changed))
+	}
+	return nil
+}
+
+// badgeParams reads and checks the user and badge of the badge routes.
+func badgeParams(r *http.Request) (int64, string, error) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+	badge := chi.URLParam(r, "badge")
+	if !store.ValidBadge(badge) {
+		return 0, "", fmt.Errorf("unknown badge %q", badge)
+	}
+	return userID, badge, nil
+}
+
+// GrantBadge godoc
+//
+//	@Summary		Grant a badge
+//	@Description	Gives the user verified, staff or early_adopter. Badges granted by admins are never revoked automatically
+//	@Tags			admin
+//	@Param			userID	path	int		true	"User ID"
+//	@Param			badge	path	string	true	"verified, staff or early_adopter"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/badges/{badge} [put]
+func (app *application) grantBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, badge, err := badgeParams(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	admin := getUserFromContext(r)
+	if err := app.store.Badges.Grant(ctx, userID, badge, admin.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("badge.grant", admin.ID, "user_id", userID, "badge", badge)
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, userID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeBadge godoc
+//
+//	@Summary		Revoke a badge
+//	@Description	Automated badges come back on the next run if the user still meets the criteria
+//	@Tags			admin
+//	@Param			userID	path	int		true	"User ID"
+//	@Param			badge	path	string	true	"verified, staff or early_adopter"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/badges/{badge} [delete]
+func (app *application) revokeBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, badge, err := badgeParams(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Badges.Revoke(ctx, userID, badge); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("badge.revoke", getUserFromContext(r).ID, "user_id", userID, "badge", badge)
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, userID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestBadges(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	badges := &store.MockBadgeStore{}
+	app.store.Badges = badges
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should only let admins grant badges", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPut, "/v1/admin/users/7/badges/verified"))
+	})
+
+	t.Run("should grant and revoke known badges", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/admin/users/7/badges/celebrity"))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/admin/users/7/badges/verified"))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/admin/users/7/badges/verified"))
+		if got := badges.Badges[7]; !slices.Equal(got, []string{store.BadgeVerified}) {
+			t.Errorf("expected user 7 to be verified once, got %v", got)
+		}
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/admin/users/7/badges/verified"))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/admin/users/7/badges/verified"))
+	})
+}
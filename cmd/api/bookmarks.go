@@ -0,0 +1,51 @@
+package main
+
+import "net/http"
+
+// BookmarkPost godoc
+//
+//	@Summary		Bookmark a post
+//	@Description	Saves a post to the authenticated user's bookmarks
+//	@Tags			posts
+//
+//	@Param			postID	path		int		true	"Post ID"
+//
+//	@Success		204		{string}	string	"Post bookmarked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [put]
+func (app *application) bookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+	if err := app.store.Bookmarks.Add(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnbookmarkPost godoc
+//
+//	@Summary		Remove a bookmark
+//	@Description	Removes a post from the authenticated user's bookmarks
+//	@Tags			posts
+//
+//	@Param			postID	path		int		true	"Post ID"
+//
+//	@Success		204		{string}	string	"Bookmark removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/bookmark [delete]
+func (app *application) unbookmarkPostHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	user := getUserFromContext(r)
+	if err := app.store.Bookmarks.Remove(r.Context(), post.ID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
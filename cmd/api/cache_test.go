@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/store"
+	"gopher_social/internal/store/cache"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var errRedisDown = errors.New("dial tcp 127.0.0.1:6379: connect: connection refused")
+
+// downUserCache behaves like a Redis that cannot be reached.
+type downUserCache struct {
+	gets int
+}
+
+func (c *downUserCache) Get(ctx context.Context, userID int64) (*store.User, error) {
+	c.gets++
+	return nil, errRedisDown
+}
+func (c *downUserCache) Set(ctx context.Context, user *store.User) error {
+	return errRedisDown
+}
+func (c *downUserCache) Delete(ctx context.Context, userID int64) error {
+	return errRedisDown
+}
+
+func TestRedisOutage(t *testing.T) {
+	withRedis := config{
+		redisCfg: redisConfig{
+			enabled: true,
+		},
+	}
+
+	t.Run("should serve authenticated requests from the database", func(t *testing.T) {
+		app := NewTestApplication(t, withRedis)
+		app.cacheStorage = &cache.Storage{Users: &downUserCache{}}
+		mux := app.mount()
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("should report failed reads as misses and stop calling Redis", func(t *testing.T) {
+		down := &downUserCache{}
+		users := cache.WithBreaker(&cache.Storage{Users: down}, breaker.New(2, time.Minute), zap.NewNop().Sugar()).Users
+		before := readErrorCount("users")
+
+		for i := 0; i < 4; i++ {
+			user, err := users.Get(context.Background(), 42)
+			if err != nil || user != nil {
+				t.Fatalf("expected a miss, got %v, %v", user, err)
+			}
+		}
+		if down.gets != 2 {
+			t.Errorf("expected the breaker to open after 2 failed reads, Redis was called %d times", down.gets)
+		}
+		if got := readErrorCount("users") - before; got != 4 {
+			t.Errorf("expected 4 counted read errors, got %d", got)
+		}
+	})
+}
+
+func readErrorCount(store string) int64 {
+	if v, ok := cache.ReadErrors.Get(store).(interface{ Value() int64 }); ok {
+		return v.Value()
+	}
+	return 0
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// cacheWarmConfig loads the size users looked up most lately into Redis
+// before an instance reports ready, so a fresh deploy doesn't send all of
+// them to Postgres at once. Lookups are counted in memory and added to the
+// shared ranking every interval.
+type cacheWarmConfig struct {
+	enabled  bool
+	size     int
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// maxTrackedUsers bounds the users counted between two flushes, lookups of
+// others are dropped until the next one.
+const maxTrackedUsers = 10000
+
+// hotKeys counts user lookups since the last flush.
+type hotKeys struct {
+	mu     sync.Mutex
+	counts map[int64]int
+}
+
+func newHotKeys() *hotKeys {
+	return &hotKeys{counts: make(map[int64]int)}
+}
+
+func (h *hotKeys) touch(userID int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.counts[userID]; ok || len(h.counts) < maxTrackedUsers {
+		h.counts[userID]++
+	}
+}
+
+func (h *hotKeys) drain() map[int64]int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	counts := h.counts
+	h.counts = make(map[int64]int)
+	return counts
+}
+
+// flushHotKeys is the job adding this instance's lookups to the ranking.
+func (app *application) flushHotKeys(ctx context.Context) error {
+	return app.cacheStorage.HotUsers.Record(ctx, app.hotKeys.drain())
+}
+
+type cacheWarmResult struct {
+	// Warmed users were loaded from the database, Cached ones were already
+	// in Redis and Missing ones no longer exist.
+	Warmed  int `json:"warmed"`
+	Cached  int `json:"cached"`
+	Missing int `json:"missing"`
+}
+
+// warmCache loads the hottest users into Redis one at a time, so warming
+// itself stays a trickle of queries. It stops early when ctx is done.
+func (app *application) warmCache(ctx context.Context) (cacheWarmResult, error) {
+	var result cacheWarmResult
+	ids, err := app.cacheStorage.HotUsers.Top(ctx, app.config.cacheWarm.size)
+	if err != nil {
+		return result, err
+	}
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if user, _ := app.cacheStorage.Users.Get(ctx, id); user != nil {
+			result.Cached++
+			continue
+		}
+		user, err := app.store.Users.GetByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, store.ErrRecordNotFound) {
+				result.Missing++
+				continue
+			}
+			return result, err
+		}
+		if err := app.cacheStorage.Users.Set(ctx, user); err != nil {
+			return result, err
+		}
+		result.Warmed++
+	}
+	return result, nil
+}
+
+// warmCacheOnStartup warms the cache while the instance reports itself as
+// warming, for at most the configured timeout. Failing to warm only costs
+// cache misses, so the instance turns ready either way.
+func (app *application) warmCacheOnStartup(ctx context.Context) {
+	defer app.warming.Store(false)
+
+	ctx, cancel := context.WithTimeout(ctx, app.config.cacheWarm.timeout)
+	defer cancel()
+	start := time.Now()
+	result, err := app.warmCache(ctx)
+	if err != nil {
+		app.logger.Warnw("cache warming incomplete", "warmed", result.Warmed, "error", err.Error())
+		return
+	}
+	app.logger.Infow("cache warmed", "warmed", result.Warmed, "cached", result.Cached, "elapsed_ms", time.Since(start).Milliseconds())
+}
+
+// WarmCache godoc
+//
+//	@Summary		Warm the cache
+//	@Description	Loads the users looked up most over the last hours into Redis, for instance after Redis was flushed
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	cacheWarmResult
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"Cache warming is disabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/cache/warm [post]
+func (app *application) warmCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if app.hotKeys == nil {
+		app.notFoundResponse(w, r, errors.New("cache warming is disabled"))
+		return
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), app.config.cacheWarm.timeout)
+	defer cancel()
+	result, err := app.warmCache(ctx)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.auditLog("cache.warm", getUserFromContext(r).ID, "warmed", result.Warmed)
+	if err := app.jsonResponse(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
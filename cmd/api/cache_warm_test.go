@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"gopher_social/internal/store/cache"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// memUserCache is a user cache that never expires.
+type memUserCache struct {
+	users map[int64]*store.User
+}
+
+func (c *memUserCache) Get(ctx context.Context, userID int64) (*store.User, error) {
+	return c.users[userID], nil
+}
+func (c *memUserCache) Set(ctx context.Context, user *store.User) error {
+	c.users[user.ID] = user
+	return nil
+}
+func (c *memUserCache) Delete(ctx context.Context, userID int64) error {
+	delete(c.users, userID)
+	return nil
+}
+
+func TestCacheWarming(t *testing.T) {
+	app := NewTestApplication(t, config{
+		redisCfg:  redisConfig{enabled: true},
+		cacheWarm: cacheWarmConfig{enabled: true, size: 10, timeout: time.Second},
+	})
+	users := &memUserCache{users: make(map[int64]*store.User)}
+	hot := &cache.MockHotUserStore{}
+	app.cacheStorage = &cache.Storage{Users: users, HotUsers: hot}
+	app.store.Users = &serviceUserStore{}
+	app.store.Schema = &store.MockSchemaStore{CurrentVersion: schemaVersion}
+	app.hotKeys = newHotKeys()
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(t *testing.T, method, path string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should rank the users looked up", func(t *testing.T) {
+		for range 3 {
+			checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/feed"))
+		}
+		if err := app.flushHotKeys(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if hot.Counts[42] != 3 {
+			t.Fatalf("got %d lookups of user 42, want 3", hot.Counts[42])
+		}
+	})
+
+	t.Run("should load the hottest users that are not cached", func(t *testing.T) {
+		delete(users.users, 42)
+		hot.Counts[7] = 5
+		hot.Counts[8] = 1
+		users.users[8] = &store.User{ID: 8}
+		result, err := app.warmCache(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != (cacheWarmResult{Warmed: 2, Cached: 1}) || users.users[7] == nil || users.users[42] == nil {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("should not be ready while warming", func(t *testing.T) {
+		app.warming.Store(true)
+		checkResponseCode(t, http.StatusServiceUnavailable, request(t, http.MethodGet, "/v1/health/ready"))
+		app.warming.Store(false)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/health/ready"))
+	})
+
+	t.Run("should warm on demand", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPost, "/v1/admin/cache/warm"))
+		app.hotKeys = nil
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/admin/cache/warm"))
+	})
+}
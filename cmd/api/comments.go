@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"gopher_social/internal/events"
 	"gopher_social/internal/store"
-	"log"
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type CreateCommentPayload struct {
-	Content string `json:"content"`
-	UserID  int64  `json:"user_id"`
-	PostID  int64  `json:"post_id"`
+	Content string `json:"content" validate:"required,max=100000"`
+	// ParentID makes the comment a reply to another comment on the post.
+	ParentID *int64 `json:"parent_id" validate:"omitempty,gte=1"`
+	// Answer posts the comment as an answer to a question, answers can't be
+	// replies.
+	Answer bool `json:"answer" validate:"excluded_with=ParentID"`
 }
 
 // func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
@@ -24,21 +32,53 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	log.Println("payload", payload)
 	if err := Validate.Struct(payload); err != nil {
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	comment := &store.Comment{
-		Content: payload.Content,
-		UserID:  payload.UserID,
-		PostID:  post.ID,
+	if err := checkLength("content", &payload.Content, app.contentLimits().MaxCommentLength); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.Answer && post.Kind != store.PostKindQuestion {
+		app.badRequestResponse(w, r, errors.New("only questions take answers"))
+		return
 	}
 	ctx := r.Context()
+	var parent *store.Comment
+	if payload.ParentID != nil {
+		var err error
+		parent, err = app.store.Comments.GetByID(ctx, *payload.ParentID)
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrRecordNotFound):
+				app.badRequestResponse(w, r, errors.New("parent comment not found"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		if parent.PostID != post.ID {
+			app.badRequestResponse(w, r, errors.New("parent comment belongs to another post"))
+			return
+		}
+	}
+	comment := &store.Comment{
+		Content:  payload.Content,
+		UserID:   getUserFromContext(r).ID,
+		PostID:   post.ID,
+		ParentID: payload.ParentID,
+		Answer:   payload.Answer,
+	}
 	if err := app.store.Comments.Create(ctx, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.events.Publish(ctx, events.CommentCreated{Post: post, Comment: comment, Parent: parent})
+	if err := app.renderComment(comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
@@ -47,11 +87,15 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	comments, err := app.visibleComments(r.Context(), post, getUserFromContext(r))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	if err := app.renderComments(comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
 	if err := app.jsonResponse(w, http.StatusOK, comments); err != nil {
 		app.internalServerError(w, r, err)
@@ -59,3 +103,119 @@ func (app *application) getCommentsHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 }
+
+// visibleComments loads the comments of a post for viewer, moderators also
+// get the comments hidden by the post author.
+func (app *application) visibleComments(ctx context.Context, post *store.Post, viewer *store.User) ([]store.Comment, error) {
+	isModerator, err := app.checkRolePrecedence(ctx, viewer, "moderator")
+	if err != nil {
+		return nil, err
+	}
+	comments, err := app.store.Comments.GetByPostID(ctx, post.ID, viewer.ID, isModerator)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.attachCommentReactions(ctx, comments); err != nil {
+		return nil, err
+	}
+	if err := app.hydrateCommentAuthors(ctx, comments); err != nil {
+		return nil, err
+	}
+	if post.AcceptedAnswerID != nil {
+		for i := range comments {
+			comments[i].Accepted = comments[i].ID == *post.AcceptedAnswerID
+		}
+	}
+	return comments, nil
+}
+
+// HideComment godoc
+//
+//	@Summary		Hide a comment
+//	@Description	Lets the post author hide a comment on their post. It stays visible to the comment's author and moderators.
+//	@Tags			posts
+//
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//
+//	@Success		204			{string}	string	"Comment hidden"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/hide [post]
+func (app *application) hideCommentHandler(w http.ResponseWriter, r *http.Request) {
+	app.setCommentHidden(w, r, true)
+}
+
+// UnhideComment godoc
+//
+//	@Summary		Unhide a comment
+//	@Description	Makes a previously hidden comment visible to everyone again
+//	@Tags			posts
+//
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//
+//	@Success		204			{string}	string	"Comment visible"
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/hide [delete]
+func (app *application) unhideCommentHandler(w http.ResponseWriter, r *http.Request) {
+	app.setCommentHidden(w, r, false)
+}
+
+func (app *application) setCommentHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	comment, ok := app.commentFromPost(w, r)
+	if !ok {
+		return
+	}
+	actor := getUserFromContext(r)
+	if err := app.store.Comments.SetHidden(r.Context(), comment.ID, hidden, actor.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// Post authors curate their own threads, only a moderator hiding the
+	// comment is enforcement.
+	if hidden && actor.ID != getPostFromCtx(r).UserID && actor.ID != comment.UserID {
+		app.openModerationCase(r.Context(), &store.ModerationCase{
+			UserID:      comment.UserID,
+			Action:      store.ModerationCommentHidden,
+			SubjectType: store.CaseSubjectComment,
+			SubjectID:   comment.ID,
+			ModeratorID: actor.ID,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// commentFromPost loads the {commentID} of the URL and checks that it belongs
+// to the post in context. It writes the error response itself and reports
+// whether the handler can go on.
+func (app *application) commentFromPost(w http.ResponseWriter, r *http.Request) (*store.Comment, bool) {
+	post := getPostFromCtx(r)
+	commentID, err := strconv.ParseInt(chi.URLParam(r, "commentID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+	comment, err := app.store.Comments.GetByID(r.Context(), commentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	if comment.PostID != post.ID {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// countersConfig shards the reaction and view counters of posts getting more
+// than shardThreshold updates a minute over shards rows, folded back into
+// the posts every compactInterval. A threshold of zero disables sharding.
+type countersConfig struct {
+	shardThreshold  int
+	shards          int
+	compactInterval time.Duration
+}
+
+// compactCounters is the job folding sharded counters back into their posts.
+// Until it runs, filters on posts.reactions_count lag behind hot posts.
+func (app *application) compactCounters(ctx context.Context) error {
+	n, err := app.store.Counters.Compact(ctx)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		app.logger.Debugw("compacted post counters", "posts", n)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"testing"
+)
+
+func TestCounterCompaction(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	counters := &store.MockCounterStore{Pending: 3}
+	app.store.Counters = counters
+
+	t.Run("should fold pending shards into their posts", func(t *testing.T) {
+		if err := app.compactCounters(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if counters.Pending != 0 {
+			t.Fatalf("got %d posts left to compact, want 0", counters.Pending)
+		}
+	})
+
+	t.Run("should do nothing without shards", func(t *testing.T) {
+		if err := app.compactCounters(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
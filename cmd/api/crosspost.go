@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/crosspost"
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// crosspostConfig paces mirroring posts to other networks: batchSize
+// deliveries every interval. A failed delivery is retried after backoff,
+// doubled each time, until it failed maxAttempts times.
+type crosspostConfig struct {
+	timeout     time.Duration
+	interval    time.Duration
+	batchSize   int
+	backoff     time.Duration
+	maxAttempts int
+}
+
+// defaultBlueskyServer is the PDS of accounts hosted by Bluesky itself.
+const defaultBlueskyServer = "https://bsky.social"
+
+type ConnectCrosspostPayload struct {
+	// Server is the Mastodon instance, or the Bluesky PDS which defaults to
+	// https://bsky.social.
+	Server string `json:"server" validate:"omitempty,url,max=255"`
+	// Handle is the Bluesky handle to log in as. Mastodon accounts are found
+	// from the token.
+	Handle string `json:"handle" validate:"max=255"`
+	// Secret is a Mastodon access token with the write:statuses scope or a
+	// Bluesky app password.
+	Secret string `json:"secret" validate:"required,max=1000"`
+	// Enabled mirrors public posts right away, the default.
+	Enabled *bool `json:"enabled"`
+}
+
+type UpdateCrosspostPayload struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+}
+
+// newCrosspostRouter builds the publishers of the supported networks. They
+// only reach public addresses, the servers are picked by users.
+func newCrosspostRouter(cfg crosspostConfig) crosspost.Router {
+	client := crosspost.NewClient(cfg.timeout, false)
+	return crosspost.Router{
+		crosspost.NetworkMastodon: crosspost.NewMastodon(client),
+		crosspost.NetworkBluesky:  crosspost.NewBluesky(client),
+	}
+}
+
+// crosspostable reports whether the post is mirrored to its author's
+// networks: public notes, articles and questions the author didn't opt out
+// of. Posts announcing spaces, events and the like only make sense here.
+func crosspostable(post *store.Post) bool {
+	switch post.Kind {
+	case store.PostKindNote, store.PostKindArticle, store.PostKindQuestion:
+	default:
+		return false
+	}
+	return !post.NoCrosspost && !post.AgeRestricted && !post.OnHold
+}
+
+// queueCrossposts queues a new post for the author's enabled accounts, the
+// crosspost job delivers them so posting doesn't wait on other networks.
+func (app *application) queueCrossposts(ctx context.Context, e events.PostCreated) error {
+	if !crosspostable(e.Post) {
+		return nil
+	}
+	_, err := app.store.Crossposts.Enqueue(ctx, e.Post.ID, e.Post.UserID)
+	return err
+}
+
+// deliverCrossposts publishes a batch of due deliveries. Rejected
+// credentials disable the account until the user connects it again.
+func (app *application) deliverCrossposts(ctx context.Context) error {
+	cfg := app.config.crosspost
+	due, err := app.store.Crossposts.Due(ctx, cfg.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, d := range due {
+		post, err := app.store.Posts.GetByID(ctx, d.PostID)
+		if errors.Is(err, store.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if post.OnHold {
+			if err := app.store.Crossposts.Failed(ctx, d.ID, "post is on hold", nil); err != nil {
+				return err
+			}
+			continue
+		}
+		remoteURL, err := app.publishCrosspost(ctx, d, post)
+		if err == nil {
+			if err := app.store.Crossposts.Delivered(ctx, d.ID, remoteURL); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var retryAt *time.Time
+		if d.Attempts+1 < cfg.maxAttempts && !errors.Is(err, crosspost.ErrUnauthorized) {
+			next := time.Now().Add(cfg.backoff << d.Attempts)
+			retryAt = &next
+		}
+		app.logger.Infow("error crossposting", "post_id", d.PostID, "network", d.Network, "attempt", d.Attempts+1, "error", err.Error())
+		if err := app.store.Crossposts.Failed(ctx, d.ID, err.Error(), retryAt); err != nil {
+			return err
+		}
+		if errors.Is(err, crosspost.ErrUnauthorized) {
+			err := app.store.Crossposts.SetEnabled(ctx, d.Account.UserID, d.Network, false)
+			if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (app *application) publishCrosspost(ctx context.Context, d store.CrosspostDelivery, post *store.Post) (string, error) {
+	publisher, err := app.crosspost.Publisher(d.Network)
+	if err != nil {
+		return "", err
+	}
+	acct := crosspost.Account{Server: d.Account.Server, Handle: d.Account.Handle, Secret: d.Account.Secret}
+	return publisher.Publish(ctx, acct, crosspost.Post{
+		Title:          post.Title,
+		Content:        post.Content,
+		ContentWarning: post.ContentWarning,
+		Link:           fmt.Sprintf("%s/posts/%d", app.config.frontendURL, post.ID),
+		Key:            fmt.Sprintf("crosspost-%d", d.ID),
+	})
+}
+
+// ListCrosspostAccounts godoc
+//
+//	@Summary		List connected networks
+//	@Description	The user's accounts on other networks their public posts are mirrored to
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.CrosspostAccount
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/crosspost [get]
+func (app *application) listCrosspostAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	accounts, err := app.store.Crossposts.Accounts(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, accounts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConnectCrosspostAccount godoc
+//
+//	@Summary		Connect a network
+//	@Description	Connects the user's account on mastodon or bluesky, replacing the one connected before. The credentials are checked with the network first. While enabled, new public notes, articles and questions are mirrored there unless posted with no_crosspost. Requires a recent login
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			network	path		string					true	"mastodon or bluesky"
+//	@Param			payload	body		ConnectCrosspostPayload	true	"Account"
+//	@Success		200		{object}	store.CrosspostAccount
+//	@Failure		400		{object}	error	"Invalid or rejected credentials"
+//	@Failure		403		{object}	error	"Sudo required"
+//	@Failure		404		{object}	error	"Unknown network"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/crosspost/{network} [put]
+func (app *application) connectCrosspostHandler(w http.ResponseWriter, r *http.Request) {
+	network := chi.URLParam(r, "network")
+	publisher, err := app.crosspost.Publisher(network)
+	if err != nil {
+		app.notFoundResponse(w, r, err)
+		return
+	}
+	var payload ConnectCrosspostPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	switch {
+	case network == crosspost.NetworkMastodon && payload.Server == "":
+		app.badRequestResponse(w, r, errors.New("server is required for mastodon"))
+		return
+	case network == crosspost.NetworkBluesky && payload.Handle == "":
+		app.badRequestResponse(w, r, errors.New("handle is required for bluesky"))
+		return
+	case network == crosspost.NetworkBluesky && payload.Server == "":
+		payload.Server = defaultBlueskyServer
+	}
+
+	acct := crosspost.Account{Server: payload.Server, Handle: payload.Handle, Secret: payload.Secret}
+	handle, err := publisher.Verify(r.Context(), acct)
+	if err != nil {
+		app.badRequestResponse(w, r, fmt.Errorf("could not verify the %s account: %w", network, err))
+		return
+	}
+	account := &store.CrosspostAccount{
+		UserID:  getUserFromContext(r).ID,
+		Network: network,
+		Server:  payload.Server,
+		Handle:  handle,
+		Secret:  payload.Secret,
+		Enabled: payload.Enabled == nil || *payload.Enabled,
+	}
+	if err := app.store.Crossposts.Connect(r.Context(), account); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, account); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UpdateCrosspostAccount godoc
+//
+//	@Summary		Turn mirroring on or off
+//	@Description	Pauses or resumes mirroring to a connected network. Posts queued while paused are delivered once resumed
+//	@Tags			users
+//	@Accept			json
+//	@Param			network	path	string					true	"mastodon or bluesky"
+//	@Param			payload	body	UpdateCrosspostPayload	true	"Enabled"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"Network not connected"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/crosspost/{network} [patch]
+func (app *application) updateCrosspostHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateCrosspostPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	err := app.store.Crossposts.SetEnabled(r.Context(), getUserFromContext(r).ID, chi.URLParam(r, "network"), *payload.Enabled)
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+	case err != nil:
+		app.internalServerError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DisconnectCrosspostAccount godoc
+//
+//	@Summary		Disconnect a network
+//	@Description	Forgets the account and drops the posts still waiting to be mirrored to it. Posts already mirrored stay up on the network
+//	@Tags			users
+//	@Param			network	path	string	true	"mastodon or bluesky"
+//	@Success		204
+//	@Failure		404	{object}	error	"Network not connected"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/crosspost/{network} [delete]
+func (app *application) disconnectCrosspostHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.store.Crossposts.Disconnect(r.Context(), getUserFromContext(r).ID, chi.URLParam(r, "network"))
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+	case err != nil:
+		app.internalServerError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetPostCrossposts godoc
+//
+//	@Summary		Cross-posting status
+//	@Description	Where the post was mirrored, with the link to each copy, or why it failed. Only the author can see it
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Success		200		{object}	[]store.CrosspostDelivery
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/crossposts [get]
+func (app *application) getPostCrosspostsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	deliveries, err := app.store.Crossposts.ForPosts(r.Context(), []int64{post.ID})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	list := deliveries[post.ID]
+	if list == nil {
+		list = []store.CrosspostDelivery{}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, list); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/crosspost"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// crosspostPostStore numbers the posts it creates and serves them back,
+// defaulting the kind like PostStore.
+type crosspostPostStore struct {
+	store.MockPostStore
+	posts []store.Post
+}
+
+func (m *crosspostPostStore) Create(ctx context.Context, post *store.Post) error {
+	post.ID = int64(len(m.posts) + 1)
+	if post.Kind == "" {
+		post.Kind = store.PostKindNote
+	}
+	m.posts = append(m.posts, *post)
+	return nil
+}
+
+func (m *crosspostPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	if id < 1 || int(id) > len(m.posts) {
+		return nil, store.ErrRecordNotFound
+	}
+	post := m.posts[id-1]
+	return &post, nil
+}
+
+func TestCrosspost(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []map[string]string
+	var records []string
+	mastodonStatus := http.StatusOK
+	// One server plays both a Mastodon instance and a Bluesky PDS.
+	network := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/v1/accounts/verify_credentials", "/api/v1/statuses":
+			if r.Header.Get("Authorization") != "Bearer mastodon-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Path == "/api/v1/accounts/verify_credentials" {
+				json.NewEncoder(w).Encode(map[string]string{"acct": "gopher"})
+				return
+			}
+			if mastodonStatus != http.StatusOK {
+				w.WriteHeader(mastodonStatus)
+				return
+			}
+			r.ParseForm()
+			statuses = append(statuses, map[string]string{
+				"status":  r.PostForm.Get("status"),
+				"spoiler": r.PostForm.Get("spoiler_text"),
+				"key":     r.Header.Get("Idempotency-Key"),
+			})
+			json.NewEncoder(w).Encode(map[string]string{"url": "https://mastodon.example/@gopher/1"})
+		case "/xrpc/com.atproto.server.createSession":
+			var creds map[string]string
+			json.NewDecoder(r.Body).Decode(&creds)
+			if creds["password"] != "app-password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"accessJwt": "jwt", "did": "did:plc:gopher", "handle": "gopher.bsky.social"})
+		case "/xrpc/com.atproto.repo.createRecord":
+			var body struct {
+				Record struct {
+					Text string `json:"text"`
+				} `json:"record"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			records = append(records, body.Record.Text)
+			json.NewEncoder(w).Encode(map[string]string{"uri": "at://did:plc:gopher/app.bsky.feed.post/3kabc"})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer network.Close()
+
+	app := NewTestApplication(t, config{
+		frontendURL: "https://gophers.example",
+		auth:        authConfig{sudoWindow: time.Minute},
+		crosspost:   crosspostConfig{batchSize: 10, backoff: time.Minute, maxAttempts: 2},
+	})
+	client := crosspost.NewClient(time.Second, true)
+	app.crosspost = crosspost.Router{
+		crosspost.NetworkMastodon: crosspost.NewMastodon(client),
+		crosspost.NetworkBluesky:  crosspost.NewBluesky(client),
+	}
+	crossposts := &store.MockCrosspostStore{}
+	app.store.Crossposts = crossposts
+	posts := &crosspostPostStore{}
+	app.store.Posts = posts
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, sudo bool, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{"sub": sub, "exp": exp}
+		if sudo {
+			claims["auth_time"] = time.Now().Unix()
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, claims))
+		return executeRequest(req, app.mount())
+	}
+	deliveries := func(t *testing.T, postID string) []store.CrosspostDelivery {
+		t.Helper()
+		rr := request(t, 42, false, http.MethodGet, "/v1/posts/"+postID+"/crossposts", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return decodeData[[]store.CrosspostDelivery](t, rr.Body.String())
+	}
+
+	t.Run("should verify accounts before connecting them", func(t *testing.T) {
+		mastodon := `{"server":"` + network.URL + `","secret":"mastodon-token"}`
+		checkResponseCode(t, http.StatusForbidden, request(t, 42, false, http.MethodPut, "/v1/users/me/crosspost/mastodon", mastodon).Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 42, true, http.MethodPut, "/v1/users/me/crosspost/myspace", mastodon).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, true, http.MethodPut, "/v1/users/me/crosspost/mastodon",
+			`{"server":"`+network.URL+`","secret":"wrong"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, true, http.MethodPut, "/v1/users/me/crosspost/bluesky",
+			`{"server":"`+network.URL+`","secret":"app-password"}`).Code)
+
+		rr := request(t, 42, true, http.MethodPut, "/v1/users/me/crosspost/mastodon", mastodon)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if strings.Contains(rr.Body.String(), "mastodon-token") {
+			t.Errorf("expected the token not to be shown back, got %s", rr.Body.String())
+		}
+		checkResponseCode(t, http.StatusOK, request(t, 42, true, http.MethodPut, "/v1/users/me/crosspost/bluesky",
+			`{"server":"`+network.URL+`","handle":"gopher.bsky.social","secret":"app-password"}`).Code)
+
+		rr = request(t, 42, false, http.MethodGet, "/v1/users/me/crosspost", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		got := decodeData[[]store.CrosspostAccount](t, rr.Body.String())
+		if len(got) != 2 || got[0].Handle != "gopher" || !got[0].Enabled || got[1].Handle != "gopher.bsky.social" {
+			t.Errorf("expected both accounts enabled, got %+v", got)
+		}
+	})
+
+	t.Run("should mirror public posts unless opted out", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, 42, false, http.MethodPost, "/v1/posts",
+			`{"title":"Go 2","content":"Generics are here","content_warning":"hype"}`).Code)
+		checkResponseCode(t, http.StatusCreated, request(t, 42, false, http.MethodPost, "/v1/posts",
+			`{"title":"Private","content":"Just here","no_crosspost":true}`).Code)
+		checkResponseCode(t, http.StatusCreated, request(t, 42, false, http.MethodPost, "/v1/posts",
+			`{"title":"Adults","content":"Only","age_restricted":true}`).Code)
+		if len(crossposts.Deliveries) != 2 {
+			t.Fatalf("expected the first post queued for both networks, got %+v", crossposts.Deliveries)
+		}
+
+		if err := app.deliverCrossposts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(statuses) != 1 || !strings.HasSuffix(statuses[0]["status"], "https://gophers.example/posts/1") ||
+			statuses[0]["spoiler"] != "hype" || statuses[0]["key"] == "" {
+			t.Errorf("expected a status linking back behind the CW, got %+v", statuses)
+		}
+		if len(records) != 1 || !strings.HasPrefix(records[0], "CW: hype") {
+			t.Errorf("expected a Bluesky post, got %q", records)
+		}
+
+		got := deliveries(t, "1")
+		if len(got) != 2 || got[0].Status != store.CrosspostDelivered || got[1].RemoteURL != "https://bsky.app/profile/gopher.bsky.social/post/3kabc" {
+			t.Errorf("expected both delivered, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, false, http.MethodGet, "/v1/posts/1/crossposts", "").Code)
+	})
+
+	t.Run("should retry failed deliveries and stop on rejected credentials", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, false, http.MethodPatch, "/v1/users/me/crosspost/bluesky", `{"enabled":false}`).Code)
+		mastodonStatus = http.StatusBadGateway
+		checkResponseCode(t, http.StatusCreated, request(t, 42, false, http.MethodPost, "/v1/posts", `{"title":"Retry","content":"me"}`).Code)
+		if err := app.deliverCrossposts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		got := deliveries(t, "4")
+		if len(got) != 1 || got[0].Status != store.CrosspostPending || got[0].Attempts != 1 || !got[0].NextAttemptAt.After(time.Now()) {
+			t.Fatalf("expected a pending retry, got %+v", got)
+		}
+
+		mastodonStatus = http.StatusUnauthorized
+		crossposts.Deliveries[2].NextAttemptAt = time.Now()
+		if err := app.deliverCrossposts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := deliveries(t, "4"); got[0].Status != store.CrosspostFailed {
+			t.Errorf("expected the delivery to fail, got %+v", got)
+		}
+		for _, a := range crossposts.Accts {
+			if a.Enabled {
+				t.Errorf("expected %s to be disabled, got %+v", a.Network, a)
+			}
+		}
+	})
+
+	t.Run("should disconnect accounts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, false, http.MethodDelete, "/v1/users/me/crosspost/mastodon", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 42, false, http.MethodDelete, "/v1/users/me/crosspost/mastodon", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 42, false, http.MethodPatch, "/v1/users/me/crosspost/mastodon", `{"enabled":true}`).Code)
+	})
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gopher_social/internal/store"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// debugCaptureConfig bounds what an admin can capture. Captures are kept
+// in memory by the instance that served the requests.
+type debugCaptureConfig struct {
+	enabled bool
+	// bufferSize is how many exchanges the ring buffer keeps.
+	bufferSize int
+	// maxBody caps each request and response body kept.
+	maxBody int
+	// maxDuration caps how long a capture runs.
+	maxDuration time.Duration
+}
+
+type captureKey string
+
+const captureCtx captureKey = "capture"
+
+// captureSession selects the requests to capture: those of UserID, those
+// under Path, or both when both are set.
+type captureSession struct {
+	UserID    int64     `json:"user_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	StartedBy int64     `json:"started_by"`
+	StartedAt time.Time `json:"started_at"`
+	EndsAt    time.Time `json:"ends_at"`
+}
+
+// capturedExchange is a sanitized request and response pair. Credentials
+// are redacted and bodies only kept when they could be sanitized.
+type capturedExchange struct {
+	Time            time.Time         `json:"time"`
+	RequestID       string            `json:"request_id"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	UserID          int64             `json:"user_id,omitempty"`
+	Status          int               `json:"status"`
+	DurationMs      int64             `json:"duration_ms"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+}
+
+// debugCapture keeps the exchanges matching the active session in a ring
+// buffer. Without a session the middleware costs one atomic load.
+type debugCapture struct {
+	cfg     debugCaptureConfig
+	session atomic.Pointer[captureSession]
+
+	mu      sync.Mutex
+	entries []capturedExchange
+	next    int
+}
+
+func newDebugCapture(cfg debugCaptureConfig) *debugCapture {
+	return &debugCapture{cfg: cfg, entries: make([]capturedExchange, 0, cfg.bufferSize)}
+}
+
+// active returns the session in effect, nil when none is or it ran out.
+func (c *debugCapture) active() *captureSession {
+	s := c.session.Load()
+	if s == nil || time.Now().After(s.EndsAt) {
+		return nil
+	}
+	return s
+}
+
+// start replaces the session and empties the buffer.
+func (c *debugCapture) start(s *captureSession) {
+	c.mu.Lock()
+	c.entries, c.next = c.entries[:0], 0
+	c.mu.Unlock()
+	c.session.Store(s)
+}
+
+func (c *debugCapture) stop() {
+	c.session.Store(nil)
+	c.mu.Lock()
+	c.entries, c.next = c.entries[:0], 0
+	c.mu.Unlock()
+}
+
+func (c *debugCapture) add(e capturedExchange) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) < c.cfg.bufferSize {
+		c.entries = append(c.entries, e)
+		return
+	}
+	c.entries[c.next] = e
+	c.next = (c.next + 1) % c.cfg.bufferSize
+}
+
+// list returns the captured exchanges, newest first.
+func (c *debugCapture) list() []capturedExchange {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]capturedExchange, 0, len(c.entries))
+	for i := range c.entries {
+		out = append(out, c.entries[(c.next+len(c.entries)-1-i)%len(c.entries)])
+	}
+	return out
+}
+
+// capturedBody keeps the first max bytes written to it and counts the rest.
+type capturedBody struct {
+	max  int
+	buf  []byte
+	size int
+}
+
+func (b *capturedBody) Write(p []byte) (int, error) {
+	b.size += len(p)
+	if room := b.max - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+	return len(p), nil
+}
+
+// captureUser is filled in by AuthTokenMiddleware, which runs after the
+// capture middleware decided to record the request.
+type captureUser struct {
+	id int64
+}
+
+// noteCaptureUser tells the capture middleware who made the request.
+func noteCaptureUser(ctx context.Context, userID int64) {
+	if u, ok := ctx.Value(captureCtx).(*captureUser); ok {
+		u.id = userID
+	}
+}
+
+// DebugCaptureMiddleware records the requests matching the active capture
+// session. The capture endpoints themselves are never recorded.
+func (app *application) DebugCaptureMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := app.captures.active()
+		if s == nil || s.Path != "" && !pathUnder(r.URL.Path, s.Path) || pathUnder(r.URL.Path, "/v1/admin/debug-capture") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		limit := app.captures.cfg.maxBody
+		reqBody := &capturedBody{max: limit}
+		if r.Body != nil {
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, reqBody), r.Body}
+		}
+		resBody := &capturedBody{max: limit}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		ww.Tee(resBody)
+		user := &captureUser{}
+		start := time.Now()
+		// Panics are recorded as the 500 the recoverer will answer, they
+		// are the bugs worth capturing.
+		defer func() {
+			p := recover()
+			if s.UserID == 0 || user.id == s.UserID {
+				app.captures.add(app.capturedExchange(r, ww, start, user.id, reqBody, resBody, p != nil))
+			}
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), captureCtx, user)))
+	})
+}
+
+func (app *application) capturedExchange(r *http.Request, ww middleware.WrapResponseWriter, start time.Time, userID int64, reqBody, resBody *capturedBody, panicked bool) capturedExchange {
+	status := ww.Status()
+	switch {
+	case panicked && status == 0:
+		status = http.StatusInternalServerError
+	case status == 0:
+		status = http.StatusOK
+	}
+	return capturedExchange{
+		Time:            start,
+		RequestID:       middleware.GetReqID(r.Context()),
+		Method:          r.Method,
+		Path:            sanitizePath(r),
+		Query:           sanitizeQuery(r.URL.Query()),
+		UserID:          userID,
+		Status:          status,
+		DurationMs:      time.Since(start).Milliseconds(),
+		RequestHeaders:  sanitizeHeaders(r.Header),
+		RequestBody:     sanitizeBody(r.Header.Get("Content-Type"), reqBody),
+		ResponseHeaders: sanitizeHeaders(ww.Header()),
+		ResponseBody:    sanitizeBody(ww.Header().Get("Content-Type"), resBody),
+	}
+}
+
+// sensitiveName reports whether a header, query parameter or JSON field
+// likely carries a credential.
+func sensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"authorization", "cookie", "password", "secret", "token", "key", "otp", "signature"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	// sig signs media links, too short to match as part of other names
+	return name == "sig"
+}
+
+// credentialValue reports whether a string is a token whatever it is stored
+// under: the token routes answer with a bare JWT as their data.
+func credentialValue(s string) bool {
+	if strings.HasPrefix(s, store.APIKeyPrefix) {
+		return true
+	}
+	// the base64url of a JWT header always starts with eyJ, i.e. {"
+	return strings.HasPrefix(s, "eyJ") && strings.Count(s, ".") == 2 && !strings.ContainsAny(s, " /+=")
+}
+
+// sensitiveParam reports whether a query parameter likely carries a
+// credential, the authorization code OAuth appends to redirect URIs
+// included.
+func sensitiveParam(name string) bool {
+	return sensitiveName(name) || strings.EqualFold(name, "code")
+}
+
+const redacted = "[redacted]"
+
+// sanitizePath redacts the path parameters with a sensitive name, such as
+// the token of /users/activate/{token}. The router has filled them in by
+// the time the exchange is recorded.
+func sanitizePath(r *http.Request) string {
+	path := r.URL.Path
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return path
+	}
+	for i, key := range rctx.URLParams.Keys {
+		if value := rctx.URLParams.Values[i]; value != "" && sensitiveName(key) {
+			path = strings.Replace(path, "/"+value, "/"+redacted, 1)
+		}
+	}
+	return path
+}
+
+func sanitizeHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveName(name) {
+			out[name] = redacted
+			continue
+		}
+		out[name] = strings.Join(values, ", ")
+	}
+	return out
+}
+
+func sanitizeQuery(q url.Values) string {
+	for name := range q {
+		if sensitiveParam(name) {
+			q[name] = []string{redacted}
+		}
+	}
+	return q.Encode()
+}
+
+// sanitizeBody keeps JSON bodies with their sensitive fields redacted.
+// Anything it can't redact, other content types or JSON cut off by the size
+// cap, is summarized instead.
+func sanitizeBody(contentType string, b *capturedBody) string {
+	if b.size == 0 {
+		return ""
+	}
+	if !strings.HasPrefix(contentType, "application/json") {
+		return summarizeBody(contentType, b.size)
+	}
+	if b.size > len(b.buf) {
+		return summarizeBody("truncated JSON", b.size)
+	}
+	var v any
+	if err := json.Unmarshal(b.buf, &v); err != nil {
+		return summarizeBody("malformed JSON", b.size)
+	}
+	out, err := json.Marshal(redactJSON(v))
+	if err != nil {
+		return summarizeBody("JSON", b.size)
+	}
+	return string(out)
+}
+
+func summarizeBody(kind string, size int) string {
+	if kind == "" {
+		kind = "unknown content"
+	}
+	return "[" + kind + ", " + strconv.Itoa(size) + " bytes]"
+}
+
+func redactJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if sensitiveName(k) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = redactJSON(field)
+		}
+	case []any:
+		for i := range v {
+			v[i] = redactJSON(v[i])
+		}
+	case string:
+		if credentialValue(v) {
+			return redacted
+		}
+		return sanitizeURL(v)
+	}
+	return v
+}
+
+// sanitizeURL redacts the sensitive query parameters of a string holding an
+// absolute URL, such as the redirect URI carrying an authorization code.
+// Other strings are returned as they are.
+func sanitizeURL(s string) string {
+	if !strings.Contains(s, "://") || !strings.Contains(s, "?") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.RawQuery == "" {
+		return s
+	}
+	u.RawQuery = sanitizeQuery(u.Query())
+	return u.String()
+}
+
+type StartDebugCapturePayload struct {
+	// UserID captures the requests of one user.
+	UserID int64 `json:"user_id" validate:"omitempty,min=1"`
+	// Path captures the routes under a prefix, e.g. /v1/posts.
+	Path    string `json:"path" validate:"omitempty,startswith=/,max=200"`
+	Minutes int    `json:"minutes" validate:"required,min=1"`
+}
+
+type debugCaptureResponse struct {
+	Session   *captureSession    `json:"session"`
+	Exchanges []capturedExchange `json:"exchanges"`
+}
+
+// StartDebugCapture godoc
+//
+//	@Summary		Start a debug capture
+//	@Description	Records sanitized request and response pairs of a user, a route prefix, or both, for the given minutes. Credentials are redacted and only JSON bodies are kept. Each instance captures the requests it serves, starting a capture replaces the running one and empties the buffer. Requires a recent authentication
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		StartDebugCapturePayload	true	"Capture"
+//	@Success		201		{object}	captureSession
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"Debug capture is disabled"
+//	@Security		ApiKeyAuth
+//	@Router			/admin/debug-capture [post]
+func (app *application) startDebugCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	var payload StartDebugCapturePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.UserID == 0 && payload.Path == "" {
+		app.badRequestResponse(w, r, errors.New("set user_id, path or both"))
+		return
+	}
+	duration := time.Duration(payload.Minutes) * time.Minute
+	if duration > app.captures.cfg.maxDuration {
+		app.badRequestResponse(w, r, errors.New("minutes is over the limit of "+app.captures.cfg.maxDuration.String()))
+		return
+	}
+
+	admin := getUserFromContext(r)
+	now := time.Now()
+	s := &captureSession{
+		UserID:    payload.UserID,
+		Path:      payload.Path,
+		StartedBy: admin.ID,
+		StartedAt: now,
+		EndsAt:    now.Add(duration),
+	}
+	app.captures.start(s)
+	app.auditLog("debug_capture.start", admin.ID, "user_id", s.UserID, "path", s.Path, "ends_at", s.EndsAt)
+	if err := app.jsonResponse(w, http.StatusCreated, s); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetDebugCapture godoc
+//
+//	@Summary		Read the debug capture
+//	@Description	The running or last session and its captured exchanges, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	debugCaptureResponse
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"Debug capture is disabled"
+//	@Security		ApiKeyAuth
+//	@Router			/admin/debug-capture [get]
+func (app *application) getDebugCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	res := debugCaptureResponse{Session: app.captures.session.Load(), Exchanges: app.captures.list()}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// StopDebugCapture godoc
+//
+//	@Summary		Stop the debug capture
+//	@Description	Ends the session and discards what it captured
+//	@Tags			admin
+//	@Success		204
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"Debug capture is disabled"
+//	@Security		ApiKeyAuth
+//	@Router			/admin/debug-capture [delete]
+func (app *application) stopDebugCaptureHandler(w http.ResponseWriter, r *http.Request) {
+	app.captures.stop()
+	app.auditLog("debug_capture.stop", getUserFromContext(r).ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestDebugCapture(t *testing.T) {
+	cfg := debugCaptureConfig{enabled: true, bufferSize: 2, maxBody: 1 << 10, maxDuration: time.Hour}
+	app := NewTestApplication(t, config{debugCapture: cfg, auth: authConfig{sudoWindow: time.Minute}})
+	app.captures = newDebugCapture(cfg)
+	app.store.Users = &adminUserStore{}
+	mux := app.mount()
+	exp := time.Now().Add(time.Hour).Unix()
+	adminToken := signTestToken(t, jwt.MapClaims{"sub": 1, "exp": exp, "auth_time": time.Now().Unix()})
+
+	request := func(t *testing.T, token, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should require a recent authentication to start", func(t *testing.T) {
+		staleToken := signTestToken(t, jwt.MapClaims{"sub": 1, "exp": exp})
+		checkResponseCode(t, http.StatusForbidden, request(t, staleToken, http.MethodPost, "/v1/admin/debug-capture", `{"user_id":7,"minutes":5}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, adminToken, http.MethodPost, "/v1/admin/debug-capture", `{"minutes":5}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, adminToken, http.MethodPost, "/v1/admin/debug-capture", `{"user_id":7,"minutes":120}`).Code)
+	})
+
+	t.Run("should capture the requests of the user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, adminToken, http.MethodPost, "/v1/admin/debug-capture", `{"user_id":7,"minutes":5}`).Code)
+		request(t, signTestToken(t, jwt.MapClaims{"sub": 7, "exp": exp}), http.MethodGet, "/v1/users/feed?limit=5", "")
+		request(t, signTestToken(t, jwt.MapClaims{"sub": 8, "exp": exp}), http.MethodGet, "/v1/users/feed", "")
+
+		rr := request(t, adminToken, http.MethodGet, "/v1/admin/debug-capture", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		got := decodeData[debugCaptureResponse](t, rr.Body.String())
+		if len(got.Exchanges) != 1 {
+			t.Fatalf("expected the one request of user 7, got %d", len(got.Exchanges))
+		}
+		e := got.Exchanges[0]
+		if e.UserID != 7 || e.Path != "/v1/users/feed" || e.Query != "limit=5" {
+			t.Errorf("unexpected exchange %+v", e)
+		}
+		if e.RequestHeaders["Authorization"] != redacted {
+			t.Errorf("expected the token to be redacted, got %q", e.RequestHeaders["Authorization"])
+		}
+	})
+
+	t.Run("should capture a route with credentials redacted", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, adminToken, http.MethodPost, "/v1/admin/debug-capture", `{"path":"/v1/authentication","minutes":5}`).Code)
+		request(t, "", http.MethodPost, "/v1/authentication/user", `{"username":"gopher","email":"a@b.c","password":"hunter22"}`)
+		request(t, adminToken, http.MethodGet, "/v1/users/feed", "")
+
+		got := decodeData[debugCaptureResponse](t, request(t, adminToken, http.MethodGet, "/v1/admin/debug-capture", "").Body.String())
+		if len(got.Exchanges) != 1 {
+			t.Fatalf("expected only the route's request, got %d", len(got.Exchanges))
+		}
+		body := got.Exchanges[0].RequestBody
+		if strings.Contains(body, "hunter22") || !strings.Contains(body, `"email":"a@b.c"`) {
+			t.Errorf("expected the password redacted, got %s", body)
+		}
+	})
+
+	t.Run("should keep the newest exchanges and discard them on stop", func(t *testing.T) {
+		for _, path := range []string{"/v1/authentication/a", "/v1/authentication/b", "/v1/authentication/c"} {
+			request(t, "", http.MethodGet, path, "")
+		}
+		got := decodeData[debugCaptureResponse](t, request(t, adminToken, http.MethodGet, "/v1/admin/debug-capture", "").Body.String())
+		if len(got.Exchanges) != 2 || got.Exchanges[0].Path != "/v1/authentication/c" || got.Exchanges[1].Path != "/v1/authentication/b" {
+			t.Errorf("expected the two newest exchanges, got %+v", got.Exchanges)
+		}
+
+		checkResponseCode(t, http.StatusNoContent, request(t, adminToken, http.MethodDelete, "/v1/admin/debug-capture", "").Code)
+		request(t, "", http.MethodGet, "/v1/authentication/d", "")
+		got = decodeData[debugCaptureResponse](t, request(t, adminToken, http.MethodGet, "/v1/admin/debug-capture", "").Body.String())
+		if got.Session != nil || len(got.Exchanges) != 0 {
+			t.Errorf("expected nothing after stopping, got %+v", got)
+		}
+	})
+
+	t.Run("should redact tokens in the path", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, adminToken, http.MethodPost, "/v1/admin/debug-capture", `{"path":"/v1/users/activate","minutes":5}`).Code)
+		request(t, "", http.MethodPut, "/v1/users/activate/s3cr3t-invite", "")
+
+		got := decodeData[debugCaptureResponse](t, request(t, adminToken, http.MethodGet, "/v1/admin/debug-capture", "").Body.String())
+		if len(got.Exchanges) != 1 || got.Exchanges[0].Path != "/v1/users/activate/"+redacted {
+			t.Errorf("expected the activation token redacted, got %+v", got.Exchanges)
+		}
+	})
+}
+
+type loginUserStore struct {
+	adminUserStore
+}
+
+func (m *loginUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	user := &store.User{ID: 7, Email: email}
+	if err := user.Password.Set("hunter22"); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func TestDebugCaptureLogin(t *testing.T) {
+	cfg := debugCaptureConfig{enabled: true, bufferSize: 2, maxBody: 1 << 10, maxDuration: time.Hour}
+	app := NewTestApplication(t, config{debugCapture: cfg, auth: authConfig{token: tokenConfig{exp: time.Hour}}})
+	app.captures = newDebugCapture(cfg)
+	app.captures.start(&captureSession{Path: "/v1/authentication", EndsAt: time.Now().Add(time.Minute)})
+	app.store.Users = &loginUserStore{}
+	mux := app.mount()
+
+	req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(`{"email":"a@b.c","password":"hunter22"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusOK, rr.Code)
+	token := decodeData[string](t, rr.Body.String())
+
+	exchanges := app.captures.list()
+	if len(exchanges) != 1 {
+		t.Fatalf("expected the login to be captured, got %d exchanges", len(exchanges))
+	}
+	if body := exchanges[0].ResponseBody; token == "" || strings.Contains(body, token) || !strings.Contains(body, redacted) {
+		t.Errorf("expected the issued token redacted, got %s", body)
+	}
+}
+
+func TestRedactJSONAuthorizationCode(t *testing.T) {
+	got := redactJSON(map[string]any{
+		"redirect_uri": "https://client.example/cb?code=abc123&state=xyz",
+		"name":         "client",
+	}).(map[string]any)
+	if uri := got["redirect_uri"].(string); strings.Contains(uri, "abc123") || !strings.Contains(uri, "state=xyz") {
+		t.Errorf("expected the code redacted, got %s", uri)
+	}
+	for _, s := range []string{"gsk_0123456789abcdef", "https://cdn.example/m/1.png?expires=1&sig=abc"} {
+		if got := redactJSON(s).(string); strings.Contains(got, "gsk_") || strings.Contains(got, "sig=abc") {
+			t.Errorf("expected %s redacted, got %s", s, got)
+		}
+	}
+	if got["name"] != "client" {
+		t.Errorf("expected other strings untouched, got %v", got["name"])
+	}
+}
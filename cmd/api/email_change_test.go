@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"gopher_social/internal/store"
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// emailChangeUserStore keeps user 42's pending email change, another account
+// owns taken@example.com.
+type emailChangeUserStore struct {
+	store.MockUserStore
+	email, pending, token string
+}
+
+func (m *emailChangeUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return &store.User{ID: id, Email: m.email, Role: &store.Role{Name: "user", Level: 1}}, nil
+}
+
+func (m *emailChangeUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	if email != "taken@example.com" {
+		return nil, store.ErrRecordNotFound
+	}
+	return &store.User{ID: 7, Email: email}, nil
+}
+
+func (m *emailChangeUserStore) RequestEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	m.pending, m.token = newEmail, token
+	return nil
+}
+
+func (m *emailChangeUserStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	hash := sha256.Sum256([]byte(token))
+	if m.token == "" || hex.EncodeToString(hash[:]) != m.token {
+		return 0, store.ErrRecordNotFound
+	}
+	m.email, m.pending, m.token = m.pending, "", ""
+	return 42, nil
+}
+
+// linkMailer keeps the confirmation links it was asked to send.
+type linkMailer struct {
+	to    []string
+	links []string
+}
+
+func (m *linkMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	m.to = append(m.to, email)
+	m.links = append(m.links, reflect.ValueOf(data).FieldByName("ConfirmURL").String())
+	return 200, nil
+}
+
+func TestEmailChange(t *testing.T) {
+	app := NewTestApplication(t, config{auth: authConfig{sudoWindow: time.Minute}, mail: mailConfig{exp: time.Hour}})
+	users := &emailChangeUserStore{email: "old@example.com"}
+	app.store.Users = users
+	mail := &linkMailer{}
+	app.mailer = mail
+	mux := app.mount()
+
+	request := func(t *testing.T, sudo bool, method, path, body string) int {
+		t.Helper()
+		claims := jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix()}
+		if sudo {
+			claims["auth_time"] = time.Now().Unix()
+		}
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, claims))
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should require a recent authentication", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, false, http.MethodPatch, "/v1/users/me/email", `{"email":"new@example.com"}`))
+	})
+
+	t.Run("should refuse taken and unchanged addresses", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, request(t, true, http.MethodPatch, "/v1/users/me/email", `{"email":"taken@example.com"}`))
+		checkResponseCode(t, http.StatusBadRequest, request(t, true, http.MethodPatch, "/v1/users/me/email", `{"email":"OLD@example.com"}`))
+		checkResponseCode(t, http.StatusBadRequest, request(t, true, http.MethodPatch, "/v1/users/me/email", `{"email":"nope"}`))
+		if len(mail.to) != 0 {
+			t.Errorf("expected no mail, sent to %v", mail.to)
+		}
+	})
+
+	t.Run("should swap the address only once the new one confirms", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, request(t, true, http.MethodPatch, "/v1/users/me/email", `{"email":"new@example.com"}`))
+		if len(mail.to) != 1 || mail.to[0] != "new@example.com" {
+			t.Fatalf("expected a confirmation mail to the new address, sent to %v", mail.to)
+		}
+		if users.email != "old@example.com" || users.pending != "new@example.com" {
+			t.Errorf("expected the change to be pending, email %q pending %q", users.email, users.pending)
+		}
+		token := path.Base(mail.links[0])
+		if users.token == token {
+			t.Error("expected only the token's hash to be stored")
+		}
+
+		checkResponseCode(t, http.StatusNotFound, request(t, false, http.MethodPut, "/v1/users/confirm-email/wrong", ""))
+		checkResponseCode(t, http.StatusNoContent, request(t, false, http.MethodPut, "/v1/users/confirm-email/"+token, ""))
+		if users.email != "new@example.com" {
+			t.Errorf("expected the new address, got %q", users.email)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, false, http.MethodPut, "/v1/users/confirm-email/"+token, ""))
+	})
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopher_social/internal/events"
+	"gopher_social/internal/inbound"
+	"gopher_social/internal/lang"
+	"gopher_social/internal/store"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// emailPostsConfig enables posting by email. The mail provider receives
+// mail for domain and posts it to the inbound webhook signed with secret.
+// With requireSenderAuth only mail that passed DMARC, or carries a DKIM
+// signature of the sender's domain, is accepted, as reported by the
+// receiving server named authservID.
+type emailPostsConfig struct {
+	domain            string
+	secret            string
+	maxBytes          int64
+	maxAttachments    int
+	requireSenderAuth bool
+	authservID        string
+}
+
+func (c emailPostsConfig) enabled() bool {
+	return c.domain != "" && c.secret != ""
+}
+
+// emailPostPrefix is the local part before the token, e.g.
+// post+3f2a...@in.example.com.
+const emailPostPrefix = "post+"
+
+type EmailPostAddressResponse struct {
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (app *application) emailPostAddress(a *store.EmailPostAddress) EmailPostAddressResponse {
+	return EmailPostAddressResponse{
+		Address:   emailPostPrefix + a.Token + "@" + app.config.emailPosts.domain,
+		CreatedAt: a.CreatedAt,
+	}
+}
+
+// addressToken finds the token among the recipients.
+func (app *application) addressToken(recipients []string) string {
+	suffix := "@" + strings.ToLower(app.config.emailPosts.domain)
+	for _, to := range recipients {
+		if local, ok := strings.CutSuffix(to, suffix); ok {
+			if token, ok := strings.CutPrefix(local, emailPostPrefix); ok && token != "" {
+				return token
+			}
+		}
+	}
+	return ""
+}
+
+// excerpt cuts text to at most n characters at a word boundary.
+func excerpt(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	cut := string([]rune(text)[:n-1])
+	if i := strings.LastIndexAny(cut, " \n"); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// GetEmailPostAddress godoc
+//
+//	@Summary		Post by email address
+//	@Description	The secret address the user emails posts to
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	EmailPostAddressResponse
+//	@Failure		404	{object}	error	"Posting by email is off"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/post-by-email [get]
+func (app *application) getEmailPostAddressHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.config.emailPosts.enabled() {
+		app.notFoundResponse(w, r, errors.New("posting by email is disabled"))
+		return
+	}
+	address, err := app.store.EmailPosts.Get(r.Context(), getUserFromContext(r).ID)
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+		return
+	case err != nil:
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, app.emailPostAddress(address)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RotateEmailPostAddress godoc
+//
+//	@Summary		Turn on posting by email
+//	@Description	Issues a new secret address, the previous one stops working. Mail sent to it from the account's email becomes a post: the subject the title, the text the content and image attachments embedded media. Mail longer than a post is published as an article. Requires a recent login
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	EmailPostAddressResponse
+//	@Failure		403	{object}	error	"Sudo required"
+//	@Failure		404	{object}	error	"Posting by email is disabled"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/post-by-email [put]
+func (app *application) rotateEmailPostAddressHandler(w http.ResponseWriter, r *http.Request) {
+	if !app.config.emailPosts.enabled() {
+		app.notFoundResponse(w, r, errors.New("posting by email is disabled"))
+		return
+	}
+	address, err := app.store.EmailPosts.Rotate(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, app.emailPostAddress(address)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteEmailPostAddress godoc
+//
+//	@Summary	Turn off posting by email
+//	@Tags		users
+//	@Success	204
+//	@Failure	404	{object}	error	"Posting by email is off"
+//	@Failure	500	{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/users/me/post-by-email [delete]
+func (app *application) deleteEmailPostAddressHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.store.EmailPosts.Delete(r.Context(), getUserFromContext(r).ID)
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+	case err != nil:
+		app.internalServerError(w, r, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// InboundEmail godoc
+//
+//	@Summary		Inbound email webhook
+//	@Description	Called by the mail provider with the raw message, signed in X-Inbound-Signature as "sha256=" and the hex HMAC-SHA256 of the body. Mail to a post by email address from the owner's account email is published as their post. Rejected mail is answered with 4xx and should not be retried
+//	@Tags			posts
+//	@Accept			plain
+//	@Produce		json
+//	@Success		201	{object}	store.Post
+//	@Failure		400	{object}	error	"Unreadable message or no subject"
+//	@Failure		403	{object}	error	"Bad signature or sender"
+//	@Failure		404	{object}	error	"Unknown address"
+//	@Failure		429	{object}	error
+//	@Failure		500	{object}	error
+//	@Router			/inbound/email [post]
+func (app *application) inboundEmailHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config.emailPosts
+	if !cfg.enabled() {
+		app.notFoundResponse(w, r, errors.New("posting by email is disabled"))
+		return
+	}
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.maxBytes))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := inbound.Verify([]byte(cfg.secret), raw, r.Header.Get(inbound.SignatureHeader)); err != nil {
+		app.logger.Warnw("inbound email signature rejected", "error", err.Error())
+		app.forbiddenResponse(w, r)
+		return
+	}
+	msg, err := inbound.Parse(bytes.NewReader(raw), cfg.authservID)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	token := app.addressToken(msg.To)
+	if token == "" {
+		app.notFoundResponse(w, r, errors.New("no post by email address among the recipients"))
+		return
+	}
+	userID, err := app.store.EmailPosts.UserID(ctx, token)
+	var user *store.User
+	if err == nil {
+		user, err = app.store.Users.GetByID(ctx, userID)
+	}
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+		return
+	case err != nil:
+		app.internalServerError(w, r, err)
+		return
+	}
+	// The address alone isn't enough, it may have leaked: the mail has to
+	// come from the account's own, verified email.
+	if !strings.EqualFold(msg.From, user.Email) || cfg.requireSenderAuth && !msg.Authenticated {
+		app.logger.Warnw("inbound email sender rejected", "user_id", user.ID, "authenticated", msg.Authenticated)
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	// Mail is cut to the instance's limits, longer mail is posted as an
+	// article.
+	limits := app.contentLimits()
+	title := excerpt(strings.TrimSpace(msg.Subject), limits.MaxPostTitleLength)
+	if title == "" {
+		app.badRequestResponse(w, r, errors.New("the subject is the post's title and can't be empty"))
+		return
+	}
+	retryAfter, err := app.postThrottle(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	content := msg.Text
+	attached := 0
+	for _, a := range msg.Attachments {
+		if attached == cfg.maxAttachments {
+			break
+		}
+		media, err := app.saveMedia(ctx, user.ID, a.Data, store.MediaVisibilityPublic)
+		if errors.Is(err, errUnsupportedMedia) {
+			continue
+		}
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		attached++
+		// the signed URL expires, the post keeps the blob's plain link and
+		// renderPost signs it whenever the post is served
+		if media.URL != "" {
+			content += fmt.Sprintf("\n\n![%s](%s)", strings.NewReplacer("[", "", "]", "").Replace(a.Filename), app.mediaSigner.Link(media.BlobKey))
+		}
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		app.badRequestResponse(w, r, errors.New("the message has no text"))
+		return
+	}
+
+	post := &store.Post{
+		Title:   title,
+		Content: content,
+		UserID:  user.ID,
+		Lang:    lang.Detect(title + "\n" + content),
+		User:    *user,
+	}
+	// Newsletters rarely fit a note, they become an article with the
+	// start of the mail as its summary.
+	if utf8.RuneCountInString(content) > limits.MaxPostLength {
+		post.Content = excerpt(content, limits.MaxPostLength)
+		err = app.store.Posts.CreateArticle(ctx, post, excerpt(content, limits.MaxArticleBodyLength))
+	} else {
+		err = app.store.Posts.Create(ctx, post)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("post created by email", "post_id", post.ID, "user_id", user.ID, "attachments", attached)
+	app.events.Publish(ctx, events.PostCreated{Post: post})
+	if err := app.renderPost(post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, post); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/inbound"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mailUserStore gives user 42 a verified email.
+type mailUserStore struct {
+	store.MockUserStore
+}
+
+func (m *mailUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return &store.User{ID: id, Email: "Gopher@Example.com", IsActive: true, Role: &store.Role{Name: "user", Level: 1}}, nil
+}
+
+// contentPostStore keeps the content of the last post created.
+type contentPostStore struct {
+	store.MockPostStore
+	content string
+}
+
+func (m *contentPostStore) Create(ctx context.Context, post *store.Post) error {
+	m.content = post.Content
+	return nil
+}
+
+func TestEmailPosts(t *testing.T) {
+	app := NewTestApplication(t, config{
+		auth: authConfig{sudoWindow: time.Minute},
+		emailPosts: emailPostsConfig{
+			domain:            "in.gophers.example",
+			secret:            "webhook-secret",
+			maxBytes:          1 << 20,
+			maxAttachments:    2,
+			requireSenderAuth: true,
+			authservID:        "mx.example",
+		},
+	})
+	app.store.Users = &mailUserStore{}
+	app.store.Media = &store.MockMediaStore{}
+	posts := &contentPostStore{}
+	app.store.Posts = posts
+	blobStore, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.blobStore = blobStore
+	app.mediaSigner = blob.NewSigner("secret", "http://localhost/v1/media/files", time.Minute)
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sudo bool, method string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, "/v1/users/me/post-by-email", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims := jwt.MapClaims{"sub": 42, "exp": exp}
+		if sudo {
+			claims["auth_time"] = time.Now().Unix()
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, claims))
+		return executeRequest(req, app.mount())
+	}
+	deliver := func(t *testing.T, raw, secret string) *httptest.ResponseRecorder {
+		t.Helper()
+		raw = strings.ReplaceAll(raw, "\n", "\r\n")
+		req, err := http.NewRequest(http.MethodPost, "/v1/inbound/email", strings.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(raw))
+		req.Header.Set(inbound.SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		return executeRequest(req, app.mount())
+	}
+	mail := func(from, to, auth, subject, body string) string {
+		return "Authentication-Results: mx.example; " + auth + "\n" +
+			"From: Gopher <" + from + ">\nTo: " + to + "\nSubject: " + subject + "\n" +
+			"Content-Type: text/plain; charset=utf-8\n\n" + body
+	}
+
+	var address string
+	t.Run("should issue a secret address", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, false, http.MethodGet).Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, false, http.MethodPut).Code)
+		rr := request(t, true, http.MethodPut)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		address = decodeData[EmailPostAddressResponse](t, rr.Body.String()).Address
+		if !strings.HasPrefix(address, "post+") || !strings.HasSuffix(address, "@in.gophers.example") {
+			t.Fatalf("unexpected address %q", address)
+		}
+		rr = request(t, false, http.MethodGet)
+		if got := decodeData[EmailPostAddressResponse](t, rr.Body.String()).Address; got != address {
+			t.Errorf("expected %q, got %q", address, got)
+		}
+	})
+
+	t.Run("should reject unsigned, unknown and spoofed mail", func(t *testing.T) {
+		good := mail("gopher@example.com", address, "dkim=pass header.d=example.com", "Hi", "Hello")
+		checkResponseCode(t, http.StatusForbidden, deliver(t, good, "wrong-secret").Code)
+		checkResponseCode(t, http.StatusNotFound, deliver(t,
+			mail("gopher@example.com", "post+nope@in.gophers.example", "dkim=pass header.d=example.com", "Hi", "Hello"), "webhook-secret").Code)
+		checkResponseCode(t, http.StatusForbidden, deliver(t,
+			mail("mallory@example.com", address, "dkim=pass header.d=example.com", "Hi", "Hello"), "webhook-secret").Code)
+		checkResponseCode(t, http.StatusForbidden, deliver(t,
+			mail("gopher@example.com", address, "dkim=fail; spf=softfail", "Hi", "Hello"), "webhook-secret").Code)
+		// SPF covers the envelope sender only, and a DKIM signature has to
+		// be the From domain's.
+		checkResponseCode(t, http.StatusForbidden, deliver(t,
+			mail("gopher@example.com", address, "spf=pass", "Hi", "Hello"), "webhook-secret").Code)
+		checkResponseCode(t, http.StatusForbidden, deliver(t,
+			mail("gopher@example.com", address, "dkim=pass header.d=mallory.example", "Hi", "Hello"), "webhook-secret").Code)
+		// Results the sender wrote themselves sit below the receiving
+		// server's, or name another server.
+		forged := strings.Replace(mail("gopher@example.com", address, "dmarc=fail", "Hi", "Hello"),
+			"\nFrom:", "\nAuthentication-Results: mx.example; dmarc=pass\nFrom:", 1)
+		checkResponseCode(t, http.StatusForbidden, deliver(t, forged, "webhook-secret").Code)
+		forged = "Authentication-Results: mx.mallory.example; dmarc=pass\n" +
+			mail("gopher@example.com", address, "none", "Hi", "Hello")
+		checkResponseCode(t, http.StatusForbidden, deliver(t, forged, "webhook-secret").Code)
+		checkResponseCode(t, http.StatusBadRequest, deliver(t,
+			mail("gopher@example.com", address, "dmarc=pass", "", "Hello"), "webhook-secret").Code)
+	})
+
+	t.Run("should post mail with its images", func(t *testing.T) {
+		png := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\nimage"))
+		raw := "Authentication-Results: mx.example; dkim=pass header.d=example.com\n" +
+			"From: gopher@example.com\nTo: " + address + "\nSubject: =?UTF-8?Q?Caf=C3=A9_notes?=\n" +
+			"MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=outer\n\n" +
+			"--outer\nContent-Type: multipart/alternative; boundary=inner\n\n" +
+			"--inner\nContent-Type: text/plain; charset=utf-8\n\nWeekly notes.\n\n-- \nGopher\n" +
+			"--inner\nContent-Type: text/html; charset=utf-8\n\n<p>Weekly <b>notes</b>.</p>\n" +
+			"--inner--\n" +
+			"--outer\nContent-Type: image/png; name=\"chart.png\"\nContent-Disposition: attachment; filename=\"chart.png\"\nContent-Transfer-Encoding: base64\n\n" + png + "\n" +
+			"--outer\nContent-Type: application/pdf\nContent-Disposition: attachment; filename=\"notes.pdf\"\n\n%PDF-1.4\n" +
+			"--outer--\n"
+		rr := deliver(t, raw, "webhook-secret")
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		post := decodeData[store.Post](t, rr.Body.String())
+		if post.Title != "Café notes" || post.UserID != 42 || !strings.HasPrefix(post.Content, "Weekly notes.\n\n![chart.png](http://localhost/v1/media/files/42/") {
+			t.Errorf("unexpected post %+v", post)
+		}
+		if strings.Contains(post.Content, "Gopher") || strings.Contains(post.Content, "notes.pdf") {
+			t.Errorf("expected no signature or pdf, got %q", post.Content)
+		}
+		if !strings.Contains(post.Content, "sig=") || strings.Contains(posts.content, "sig=") {
+			t.Errorf("expected the image stored unsigned and served signed, stored %q served %q", posts.content, post.Content)
+		}
+		if other := "![x](http://localhost/v1/media/files/7/a.png)"; app.signPostMedia(other, 42) != other {
+			t.Errorf("expected links to other users' media left unsigned")
+		}
+	})
+
+	t.Run("should post long mail as an article", func(t *testing.T) {
+		rr := deliver(t, mail("gopher@example.com", address, "dmarc=pass (p=reject)", "Newsletter", strings.Repeat("gophers ", 300)), "webhook-secret")
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if post := decodeData[store.Post](t, rr.Body.String()); post.Kind != store.PostKindArticle || len([]rune(post.Content)) > store.DefaultContentLimits.MaxPostLength {
+			t.Errorf("expected an article with a summary, got %s with %d characters", post.Kind, len(post.Content))
+		}
+	})
+
+	t.Run("should retire rotated addresses", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, true, http.MethodPut).Code)
+		checkResponseCode(t, http.StatusNotFound, deliver(t, mail("gopher@example.com", address, "dkim=pass header.d=example.com", "Hi", "Hello"), "webhook-secret").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, false, http.MethodDelete).Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, false, http.MethodGet).Code)
+	})
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/markup"
+	"gopher_social/internal/store"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// emojiConfig limits the size of custom emoji images. Every instance reloads
+// the emoji it renders every refreshInterval, the one serving an admin's
+// change does so right away.
+type emojiConfig struct {
+	maxBytes        int64
+	refreshInterval time.Duration
+}
+
+var errInvalidShortcode = errors.New("shortcode must be 2 to 32 lowercase letters, digits, _ or -")
+
+func emojiURL(shortcode string) string {
+	return "/v1/emoji/" + shortcode
+}
+
+// refreshEmoji reloads the custom emoji rendered in posts and comments. It
+// runs as a job and after admins change emoji.
+func (app *application) refreshEmoji(ctx context.Context) error {
+	emoji, err := app.store.Emoji.List(ctx)
+	if err != nil {
+		return err
+	}
+	urls := make(map[string]string, len(emoji))
+	for _, e := range emoji {
+		urls[e.Shortcode] = emojiURL(e.Shortcode)
+	}
+	app.markup.SetEmoji(urls)
+	return nil
+}
+
+// ListEmoji godoc
+//
+//	@Summary		List custom emoji
+//	@Description	The instance's custom emoji, written :shortcode: in posts and comments
+//	@Tags			emoji
+//	@Produce		json
+//	@Success		200	{object}	[]store.Emoji
+//	@Failure		500	{object}	error
+//	@Router			/emoji [get]
+func (app *application) listEmojiHandler(w http.ResponseWriter, r *http.Request) {
+	emoji, err := app.store.Emoji.List(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range emoji {
+		emoji[i].URL = emojiURL(emoji[i].Shortcode)
+	}
+	if err := app.jsonResponse(w, http.StatusOK, emoji); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ServeEmoji godoc
+//
+//	@Summary	Fetch a custom emoji image
+//	@Tags		emoji
+//	@Produce	image/png,image/gif,image/webp,image/jpeg
+//	@Param		shortcode	path		string	true	"Shortcode"
+//	@Success	200			{file}		file
+//	@Failure	404			{object}	error
+//	@Failure	500			{object}	error
+//	@Router		/emoji/{shortcode} [get]
+func (app *application) serveEmojiHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	emoji, err := app.store.Emoji.Get(ctx, chi.URLParam(r, "shortcode"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	rc, err := app.blobStore.Get(ctx, emoji.BlobKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", emoji.ContentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	if _, err := io.Copy(w, rc); err != nil {
+		app.logger.Errorw("error streaming emoji", "shortcode", emoji.Shortcode, "error", err.Error())
+	}
+}
+
+// UploadEmoji godoc
+//
+//	@Summary		Upload a custom emoji
+//	@Description	Adds an image rendered for :shortcode: in posts and comments
+//	@Tags			admin
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			shortcode	formData	string	true	"2 to 32 lowercase letters, digits, _ or -"
+//	@Param			file		formData	file	true	"Image file"
+//	@Success		201			{object}	store.Emoji
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/emoji [post]
+func (app *application) uploadEmojiHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.emoji.maxBytes)
+	if err := r.ParseMultipartForm(app.config.emoji.maxBytes); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	shortcode := r.FormValue("shortcode")
+	if !markup.ValidShortcode(shortcode) {
+		app.badRequestResponse(w, r, errInvalidShortcode)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedMediaTypes[contentType]
+	if !ok {
+		app.badRequestResponse(w, r, fmt.Errorf("unsupported media type %s", contentType))
+		return
+	}
+
+	ctx := r.Context()
+	admin := getUserFromContext(r)
+	emoji := &store.Emoji{
+		Shortcode:   shortcode,
+		BlobKey:     fmt.Sprintf("emoji/%s-%s%s", shortcode, uuid.New().String(), ext),
+		ContentType: contentType,
+		CreatedBy:   admin.ID,
+	}
+	if err := app.blobStore.Put(ctx, emoji.BlobKey, bytes.NewReader(data)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Emoji.Create(ctx, emoji); err != nil {
+		if err := app.blobStore.Delete(ctx, emoji.BlobKey); err != nil {
+			app.logger.Errorw("error deleting orphaned blob", "key", emoji.BlobKey, "error", err.Error())
+		}
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("emoji.create", admin.ID, "shortcode", shortcode)
+	if err := app.refreshEmoji(ctx); err != nil {
+		app.logger.Warnw("error refreshing custom emoji", "error", err.Error())
+	}
+	emoji.URL = emojiURL(shortcode)
+	if err := app.jsonResponse(w, http.StatusCreated, emoji); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeleteEmoji godoc
+//
+//	@Summary		Delete a custom emoji
+//	@Description	Posts and comments render its shortcode as text again
+//	@Tags			admin
+//	@Param			shortcode	path	string	true	"Shortcode"
+//	@Success		204
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/emoji/{shortcode} [delete]
+func (app *application) deleteEmojiHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	emoji, err := app.store.Emoji.Delete(ctx, chi.URLParam(r, "shortcode"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.blobStore.Delete(ctx, emoji.BlobKey); err != nil {
+		app.logger.Errorw("error deleting emoji blob", "key", emoji.BlobKey, "error", err.Error())
+	}
+	app.auditLog("emoji.delete", getUserFromContext(r).ID, "shortcode", emoji.Shortcode)
+	if err := app.refreshEmoji(ctx); err != nil {
+		app.logger.Warnw("error refreshing custom emoji", "error", err.Error())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
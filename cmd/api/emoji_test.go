@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/store"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCustomEmoji(t *testing.T) {
+	app := NewTestApplication(t, config{emoji: emojiConfig{maxBytes: 1 << 20}})
+	blobStore, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.blobStore = blobStore
+	app.store.Users = &adminUserStore{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := app.mount()
+
+	upload := func(t *testing.T, shortcode string, data []byte) int {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("shortcode", shortcode)
+		fw, err := mw.CreateFormFile("file", "emoji.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		mw.Close()
+		req, err := http.NewRequest(http.MethodPost, "/v1/admin/emoji", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Code
+	}
+	request := func(t *testing.T, method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux)
+	}
+
+	png := []byte("\x89PNG\r\n\x1a\ngopher dancing")
+
+	t.Run("should only take valid shortcodes and images", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, upload(t, "Gopher Dance", png))
+		checkResponseCode(t, http.StatusBadRequest, upload(t, "gopher-dance", []byte("<svg></svg>")))
+		checkResponseCode(t, http.StatusCreated, upload(t, "gopher-dance", png))
+		checkResponseCode(t, http.StatusConflict, upload(t, "gopher-dance", png))
+	})
+
+	t.Run("should list and serve the emoji", func(t *testing.T) {
+		rr := request(t, http.MethodGet, "/v1/emoji")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		emoji := decodeData[[]store.Emoji](t, rr.Body.String())
+		if len(emoji) != 1 || emoji[0].URL != "/v1/emoji/gopher-dance" {
+			t.Fatalf("expected gopher-dance, got %+v", emoji)
+		}
+		rr = request(t, http.MethodGet, emoji[0].URL)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if rr.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rr.Body.Bytes(), png) {
+			t.Errorf("expected the uploaded image, got %s", rr.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("should render known shortcodes outside code", func(t *testing.T) {
+		html, err := app.markup.Render("hi :gopher-dance: :unknown: `:gopher-dance:`")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(html, `<img class="emoji" src="/v1/emoji/gopher-dance" alt=":gopher-dance:" title=":gopher-dance:"`) {
+			t.Errorf("expected the emoji image, got %s", html)
+		}
+		if !strings.Contains(html, ":unknown:") || !strings.Contains(html, "<code>:gopher-dance:</code>") {
+			t.Errorf("expected unknown and code shortcodes to stay text, got %s", html)
+		}
+	})
+
+	t.Run("should stop rendering deleted emoji", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/admin/emoji/gopher-dance").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/admin/emoji/gopher-dance").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodGet, "/v1/emoji/gopher-dance").Code)
+		html, err := app.markup.Render("hi :gopher-dance:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(html, "<img") {
+			t.Errorf("expected the shortcode as text, got %s", html)
+		}
+	})
+}
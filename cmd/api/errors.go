@@ -1,13 +1,21 @@
 package main
 
 import (
+	"gopher_social/internal/i18n"
+	"gopher_social/internal/store"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
+	if store.IsQueryTimeout(err) {
+		app.queryTimeoutResponse(w, r, err)
+		return
+	}
 	//log.Printf("internal server error: %s path:%s error %s", r.Method, r.URL.Path, err)
 	app.logger.Errorw("internal server error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	writeJSONError(w, http.StatusInternalServerError, "The server encountered a problem")
+	writeLocalizedError(w, r, http.StatusInternalServerError, "error.internal")
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -18,7 +26,7 @@ func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Reques
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request, err error) {
 	//log.Printf("not found error: %s path:%s error %s", r.Method, r.URL.Path, err)
 	app.logger.Warnf("not found error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	writeJSONError(w, http.StatusNotFound, "The requested resource could not be found")
+	writeLocalizedError(w, r, http.StatusNotFound, "error.not_found")
 }
 
 func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request, err error) {
@@ -30,23 +38,104 @@ func (app *application) conflictResponse(w http.ResponseWriter, r *http.Request,
 func (app *application) unauthorizedErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	//log.Printf("unauthorized error: %s path:%s error %s", r.Method, r.URL.Path, err)
 	app.logger.Warnf("unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	writeLocalizedError(w, r, http.StatusUnauthorized, "error.unauthorized")
 }
 
 func (app *application) unauthorizedBasicErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	//log.Printf("unauthorized error: %s path:%s error %s", r.Method, r.URL.Path, err)
 	app.logger.Warnf("unauthorized error", "method", r.Method, "path", r.URL.Path, "error", err.Error())
 	w.Header().Set("WWW-Authenticate", `Basic realm="restricted", charset="UTF-8"`)
-	writeJSONError(w, http.StatusUnauthorized, "unauthorized")
+	writeLocalizedError(w, r, http.StatusUnauthorized, "error.unauthorized")
 }
 func (app *application) forbiddenResponse(w http.ResponseWriter, r *http.Request) {
 	//log.Printf("forbidden error: %s path:%s error %s", r.Method, r.URL.Path, err)
 	app.logger.Warnw("forbidden error", "method", r.Method, "path", r.URL.Path)
-	writeJSONError(w, http.StatusForbidden, "forbidden")
+	writeLocalizedError(w, r, http.StatusForbidden, "error.forbidden")
+}
+
+func (app *application) sudoRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("sudo required", "method", r.Method, "path", r.URL.Path)
+	writeLocalizedError(w, r, http.StatusForbidden, "error.sudo_required")
+}
+
+func (app *application) termsRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("terms acceptance required", "method", r.Method, "path", r.URL.Path)
+	writeLocalizedError(w, r, http.StatusUnavailableForLegalReasons, "error.terms_required")
 }
 
 func (app *application) rateLimitExceedResponse(w http.ResponseWriter, r *http.Request, retryAfter string) {
 	app.logger.Warnw("rate limit exceeded", "method", r.Method, "path", r.URL.Path, "error", retryAfter)
 	w.Header().Set("Retry-After", retryAfter)
-	writeJSONError(w, http.StatusTooManyRequests, "rate limit exceeded, retry after: "+retryAfter)
+	writeLocalizedError(w, r, http.StatusTooManyRequests, "error.rate_limited", retryAfter)
+}
+
+func (app *application) duplicatePostResponse(w http.ResponseWriter, r *http.Request, existingID int64) {
+	app.logger.Warnw("duplicate post", "method", r.Method, "path", r.URL.Path, "existing_post_id", existingID)
+	type envelope struct {
+		Error          string `json:"error"`
+		ExistingPostID int64  `json:"existing_post_id"`
+	}
+	writeJSON(w, http.StatusConflict, envelope{
+		Error:          i18n.T(requestLocale(r), "error.duplicate_post"),
+		ExistingPostID: existingID,
+	})
+}
+
+func (app *application) queryTimeoutResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Errorw("query timeout", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	type envelope struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	writeJSON(w, http.StatusGatewayTimeout, envelope{
+		Error: i18n.T(requestLocale(r), "error.query_timeout"),
+		Code:  "query_timeout",
+	})
+}
+
+// maintenanceResponse tells clients the route is down for maintenance and
+// when to come back.
+func (app *application) maintenanceResponse(w http.ResponseWriter, r *http.Request, m *store.MaintenanceWindow) {
+	retryAfter := app.config.maintenance.retryAfter
+	if m.EndsAt != nil {
+		retryAfter = max(time.Until(*m.EndsAt).Round(time.Second), time.Second)
+	}
+	message := m.Message
+	if message == "" {
+		message = i18n.T(requestLocale(r), "error.maintenance")
+	}
+	type envelope struct {
+		Error      string     `json:"error"`
+		Code       string     `json:"code"`
+		EndsAt     *time.Time `json:"ends_at"`
+		RetryAfter int        `json:"retry_after"`
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	writeJSON(w, http.StatusServiceUnavailable, envelope{
+		Error:      message,
+		Code:       "maintenance",
+		EndsAt:     m.EndsAt,
+		RetryAfter: int(retryAfter.Seconds()),
+	})
+}
+
+// postRemovedResponse answers for a post removed by a moderator with its
+// tombstone rather than a bare not found.
+func (app *application) postRemovedResponse(w http.ResponseWriter, r *http.Request, t *store.PostTombstone) {
+	type envelope struct {
+		Error     string               `json:"error"`
+		Code      string               `json:"code"`
+		Tombstone *store.PostTombstone `json:"tombstone"`
+	}
+	writeJSON(w, http.StatusGone, envelope{
+		Error:     i18n.T(requestLocale(r), "error.post_removed"),
+		Code:      "post_removed",
+		Tombstone: t,
+	})
+}
+
+func (app *application) serviceUnavailableResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("server saturated", "method", r.Method, "path", r.URL.Path)
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	writeLocalizedError(w, r, http.StatusServiceUnavailable, "error.busy")
 }
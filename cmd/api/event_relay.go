@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+	"strconv"
+	"time"
+)
+
+const (
+	// maxRelayAttempts is how often an event is offered to the broker
+	// before it is given up on.
+	maxRelayAttempts = 20
+	relayBatch       = 500
+)
+
+// brokerConfig forwards domain events to an external broker. Events go
+// through the outbox, so a broker outage delays them instead of losing them
+// and requests never wait on the broker.
+type brokerConfig struct {
+	events.BrokerConfig
+	interval time.Duration
+}
+
+// subscribeBroker queues every domain event for the relay. aggregateID only
+// matters for reading the outbox table.
+func (app *application) subscribeBroker(bus *events.Bus) {
+	events.Subscribe(bus, "broker", func(ctx context.Context, e events.PostCreated) error {
+		return app.enqueueBrokerEvent(ctx, e, e.Post.ID)
+	})
+	events.Subscribe(bus, "broker", func(ctx context.Context, e events.CommentCreated) error {
+		return app.enqueueBrokerEvent(ctx, e, e.Comment.ID)
+	})
+	events.Subscribe(bus, "broker", func(ctx context.Context, e events.UserFollowed) error {
+		return app.enqueueBrokerEvent(ctx, e, e.FollowerID)
+	})
+}
+
+func (app *application) enqueueBrokerEvent(ctx context.Context, e events.Event, aggregateID int64) error {
+	msg, err := events.NewMessage(e, time.Now())
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return app.store.Outbox.Enqueue(ctx, store.TopicBrokerEvent, aggregateID, payload)
+}
+
+// relayEvents is the job publishing queued events to the broker, oldest
+// first. It stops at the first failure so events keep their order, the
+// failed one leads the next run. The outbox ID is the message ID: an event
+// published again after a lost ack is dropped by the broker as a duplicate.
+func (app *application) relayEvents(ctx context.Context) error {
+	pending, err := app.store.Outbox.GetPending(ctx, store.TopicBrokerEvent, maxRelayAttempts, relayBatch)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+
+	published := make([]int64, 0, len(pending))
+	for _, e := range pending {
+		var msg events.Message
+		if err = json.Unmarshal(e.Payload, &msg); err == nil {
+			msg.ID = strconv.FormatInt(e.ID, 10)
+			err = app.broker.Publish(ctx, msg)
+		}
+		if err != nil {
+			app.logger.Warnw("event relay failed", "event_id", e.ID, "error", err.Error())
+			if err := app.store.Outbox.MarkFailed(ctx, []int64{e.ID}, err.Error()); err != nil {
+				return err
+			}
+			break
+		}
+		published = append(published, e.ID)
+	}
+	if len(published) == 0 {
+		return nil
+	}
+	return app.store.Outbox.MarkProcessed(ctx, published)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+	"testing"
+)
+
+// fakeBroker keeps what was published and fails once it holds limit
+// messages, when limit is set.
+type fakeBroker struct {
+	published []events.Message
+	limit     int
+}
+
+func (b *fakeBroker) Publish(ctx context.Context, msg events.Message) error {
+	if b.limit > 0 && len(b.published) >= b.limit {
+		return errors.New("nats: no responders available for request")
+	}
+	b.published = append(b.published, msg)
+	return nil
+}
+
+func (b *fakeBroker) Consume(ctx context.Context, group string, fn func(context.Context, events.Message) error) error {
+	return nil
+}
+
+func (b *fakeBroker) Close() error { return nil }
+
+func TestEventRelay(t *testing.T) {
+	setup := func(t *testing.T, broker *fakeBroker) (*application, *store.MockOutboxStore) {
+		t.Helper()
+		app := NewTestApplication(t, config{})
+		outbox := &store.MockOutboxStore{}
+		app.store.Outbox = outbox
+		app.broker = broker
+		bus := events.NewBus(app.logger)
+		app.subscribeEvents(bus)
+		app.events = bus
+
+		ctx := context.Background()
+		app.events.Publish(ctx, events.PostCreated{Post: &store.Post{ID: 3, UserID: 7}})
+		app.events.Publish(ctx, events.UserFollowed{FollowerID: 42, FollowedID: 7})
+		return app, outbox
+	}
+
+	t.Run("should publish queued events in order with their outbox ID", func(t *testing.T) {
+		broker := &fakeBroker{}
+		app, outbox := setup(t, broker)
+		if err := app.relayEvents(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(broker.published) != 2 {
+			t.Fatalf("got %d messages, want 2", len(broker.published))
+		}
+		first, second := broker.published[0], broker.published[1]
+		if first.ID != "1" || first.Name != "post.created" || second.ID != "2" || second.Name != "user.followed" {
+			t.Fatalf("unexpected messages %+v", broker.published)
+		}
+		if string(second.Data) != `{"follower_id":42,"followed_id":7}` {
+			t.Fatalf("unexpected payload %s", second.Data)
+		}
+		if len(outbox.Processed) != 2 || len(outbox.Failed) != 0 {
+			t.Fatalf("unexpected outbox %+v", outbox)
+		}
+	})
+
+	t.Run("should stop at the first failure and retry it later", func(t *testing.T) {
+		app, outbox := setup(t, &fakeBroker{limit: 1})
+		if err := app.relayEvents(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(outbox.Processed) != 1 || outbox.Processed[0] != 1 || len(outbox.Failed) != 1 || outbox.Failed[0] != 2 {
+			t.Fatalf("unexpected outbox %+v", outbox)
+		}
+	})
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/events"
+)
+
+// subscribeEvents wires the subsystems reacting to domain events. Search is
+// not among them, posts reach it through the outbox, which is written in
+// the same transaction and survives a crash that would lose an event.
+func (app *application) subscribeEvents(bus *events.Bus) {
+	events.Subscribe(bus, "notifications", app.notifyNewPost)
+	events.Subscribe(bus, "risk", func(ctx context.Context, e events.PostCreated) error {
+		app.recordPostSignals(ctx, e.Post)
+		return nil
+	})
+	events.Subscribe(bus, "notifications", app.notifyComment)
+	events.Subscribe(bus, "repo-cards", app.queueRepoCard)
+	events.Subscribe(bus, "crosspost", app.queueCrossposts)
+	events.Subscribe(bus, "notifications", app.notifyAnswerAccepted)
+	events.Subscribe(bus, "notifications", app.notifySpaceLive)
+	events.Subscribe(bus, "long-poll", func(ctx context.Context, e events.NotificationsCreated) error {
+		app.waiters.wake(e.UserIDs)
+		return nil
+	})
+	if app.broker != nil {
+		app.subscribeBroker(bus)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/events"
+	"net/http"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	bus := events.NewBus(app.logger)
+	app.events = bus
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls []string
+	events.Subscribe(bus, "failing", func(ctx context.Context, e events.UserFollowed) error {
+		calls = append(calls, "failing")
+		return errors.New("subscriber down")
+	})
+	var followed []events.UserFollowed
+	events.Subscribe(bus, "recorder", func(ctx context.Context, e events.UserFollowed) error {
+		calls = append(calls, "recorder")
+		followed = append(followed, e)
+		return nil
+	})
+	events.Subscribe(bus, "posts", func(ctx context.Context, e events.PostCreated) error {
+		t.Error("post subscriber got a follow event")
+		return nil
+	})
+
+	t.Run("should deliver a follow to every subscriber of its type in order", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/7/follow", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+
+		if len(calls) != 2 || calls[0] != "failing" || calls[1] != "recorder" {
+			t.Fatalf("unexpected subscriber calls %v", calls)
+		}
+		if len(followed) != 1 || followed[0] != (events.UserFollowed{FollowerID: 42, FollowedID: 7}) {
+			t.Fatalf("unexpected events %+v", followed)
+		}
+	})
+}
@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"gopher_social/internal/store"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// feedQueryLabel groups feed query metrics by which filters were used.
+func feedQueryLabel(fq store.PaginatedFeedQuery) string {
+	return fmt.Sprintf("search=%t,tags=%t,sort=%s", fq.Search != "", len(fq.Tags) > 0, fq.Sort)
+}
+
 // @Summary		Fetch user feed
 // @Description	Fetch user feed
 // @Tags			feed
@@ -19,6 +29,7 @@ import (
 // @Param			search	query		string		false	"Search"
 // @Param			since	query		string		false	"Since"
 // @Param			until	query		string		false	"Until"
+// @Param			lang	query		string		false	"Language (ISO 639-1)"
 //
 // @Success		200		{object}	[]store.PostWithMetadata
 // @Failure		500		{object}	error
@@ -48,16 +59,301 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	user := getUserFromContext(r)
+	fq.HideWarned = user.ContentWarningPref == store.ContentWarningHide
+	fq.HideAgeRestricted = !app.isAdult(user)
 
 	ctx := r.Context()
-	feed, err := app.store.Posts.GetUserFeed(ctx, int64(13), fq)
+	start := time.Now()
+	feed, err := app.store.Posts.GetUserFeed(ctx, user.ID, fq)
 
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	app.feedMetrics.Observe(feedQueryLabel(fq), time.Since(start), len(feed))
+	collapseWarned(feed, user)
+	stampAges(feed, time.Now())
+	if err := app.attachTopComments(ctx, feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedExtras(ctx, feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.hydrateFeedAuthors(ctx, feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderFeed(feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 }
+
+// @Summary		Fetch explore feed
+// @Description	Fetch recent posts from everyone, posts in the user's preferred languages first
+// @Tags			feed
+// @Accept			json
+// @Produce		json
+//
+// @Param			limit	query		int			false	"Limit"
+// @Param			offset	query		int			false	"Offset"
+// @Param			sort	query		string		false	"Sort"
+//
+// @Param			tags	query		[]string	false	"Tags (repeatable for multiple values)"
+// @Param			search	query		string		false	"Search"
+// @Param			lang	query		string		false	"Language (ISO 639-1)"
+//
+// @Success		200		{object}	[]store.PostWithMetadata
+// @Failure		500		{object}	error
+// @Failure		400		{object}	error
+// @Security		ApiKeyAuth
+// @Router			/users/explore [get]
+func (app *application) getExploreFeedHandler(w http.ResponseWriter, r *http.Request) {
+	fq := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Search: "",
+		Tags:   []string{},
+		Sort:   "desc",
+	}
+
+	fq, err := fq.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(fq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	app.serveExploreFeed(w, r, getUserFromContext(r), fq)
+}
+
+// serveExploreFeed answers with the explore feed as seen by viewer, anonymous
+// visitors pass a zero User.
+func (app *application) serveExploreFeed(w http.ResponseWriter, r *http.Request, viewer *store.User, fq store.PaginatedFeedQuery) {
+	fq.HideWarned = viewer.ContentWarningPref == store.ContentWarningHide
+	fq.HideAgeRestricted = !app.isAdult(viewer)
+
+	feed, err := app.store.Posts.GetExploreFeed(r.Context(), viewer.PreferredLanguages, fq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	collapseWarned(feed, viewer)
+	stampAges(feed, time.Now())
+	if err := app.attachTopComments(r.Context(), feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.attachFeedExtras(r.Context(), feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.hydrateFeedAuthors(r.Context(), feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderFeed(feed); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, feed); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type FeedUpdates struct {
+	NewPosts int `json:"new_posts"`
+}
+
+// @Summary		Count new feed posts
+// @Description	Counts the feed posts newer than a cursor (capped at 100), so clients can show a "new posts" pill without reloading the feed
+// @Tags			feed
+// @Produce		json
+//
+// @Param			since_cursor	query		string	true	"Cursor of the newest item the client has"
+//
+// @Success		200				{object}	FeedUpdates
+// @Failure		400				{object}	error
+// @Failure		500				{object}	error
+// @Security		ApiKeyAuth
+// @Router			/users/feed/updates [get]
+func (app *application) getFeedUpdatesHandler(w http.ResponseWriter, r *http.Request) {
+	sinceID, err := store.DecodeFeedCursor(r.URL.Query().Get("since_cursor"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	count, err := app.store.Posts.CountFeedSince(r.Context(), getUserFromContext(r).ID, sinceID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, FeedUpdates{NewPosts: count}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type FeedPositionPayload struct {
+	Cursor string `json:"cursor" validate:"required,max=64"`
+}
+
+// @Summary		Fetch feed position
+// @Description	Returns the last feed item the user read, on any device
+// @Tags			feed
+// @Produce		json
+// @Success		200	{object}	store.FeedPosition
+// @Failure		404	{object}	error
+// @Failure		500	{object}	error
+// @Security		ApiKeyAuth
+// @Router			/users/feed/position [get]
+func (app *application) getFeedPositionHandler(w http.ResponseWriter, r *http.Request) {
+	position, err := app.getFeedPosition(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, position); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// @Summary		Save feed position
+// @Description	Remembers the last feed item the user read so other devices can resume from it
+// @Tags			feed
+// @Accept			json
+// @Produce		json
+// @Param			payload	body		FeedPositionPayload	true	"Feed item cursor"
+// @Success		200		{object}	store.FeedPosition
+// @Failure		400		{object}	error
+// @Failure		500		{object}	error
+// @Security		ApiKeyAuth
+// @Router			/users/feed/position [put]
+func (app *application) setFeedPositionHandler(w http.ResponseWriter, r *http.Request) {
+	var payload FeedPositionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if _, err := store.DecodeFeedCursor(payload.Cursor); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID := getUserFromContext(r).ID
+	position, err := app.store.FeedPositions.Set(ctx, userID, payload.Cursor)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		if err := app.cacheStorage.FeedPositions.Set(ctx, userID, position); err != nil {
+			app.logger.Errorw("error caching feed position", "user_id", userID, "error", err.Error())
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, position); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getFeedPosition reads the position from Redis and falls back to Postgres,
+// warming the cache on a miss.
+func (app *application) getFeedPosition(ctx context.Context, userID int64) (*store.FeedPosition, error) {
+	if !app.config.redisCfg.enabled {
+		return app.store.FeedPositions.Get(ctx, userID)
+	}
+	position, err := app.cacheStorage.FeedPositions.Get(ctx, userID)
+	if err != nil {
+		app.logger.Errorw("error reading cached feed position", "user_id", userID, "error", err.Error())
+	}
+	if position != nil {
+		return position, nil
+	}
+	position, err = app.store.FeedPositions.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := app.cacheStorage.FeedPositions.Set(ctx, userID, position); err != nil {
+		app.logger.Errorw("error caching feed position", "user_id", userID, "error", err.Error())
+	}
+	return position, nil
+}
+
+// collapseWarned marks posts with a content warning as collapsed unless the
+// viewer chose to always expand them.
+func collapseWarned(feed []store.PostWithMetadata, viewer *store.User) {
+	for i := range feed {
+		feed[i].Collapsed = feed[i].ContentWarning != "" && viewer.ContentWarningPref != store.ContentWarningExpand
+	}
+}
+
+// stampAges sets the relative creation times of the feed items as of now.
+func stampAges(feed []store.PostWithMetadata, now time.Time) {
+	for i := range feed {
+		age := max(now.Sub(feed[i].CreatedAt), 0)
+		feed[i].AgeSeconds = int64(age.Seconds())
+		feed[i].CreatedAgo = relativeTime(feed[i].CreatedAt, now)
+	}
+}
+
+// relativeTime is the short form of how long ago t was: now, 5m, 3h, 2d and
+// 4w, then the date for anything older than a year.
+func relativeTime(t, now time.Time) string {
+	age := now.Sub(t)
+	switch {
+	case age < time.Minute:
+		return "now"
+	case age < time.Hour:
+		return strconv.Itoa(int(age/time.Minute)) + "m"
+	case age < 24*time.Hour:
+		return strconv.Itoa(int(age/time.Hour)) + "h"
+	case age < 7*24*time.Hour:
+		return strconv.Itoa(int(age/(24*time.Hour))) + "d"
+	case age < 365*24*time.Hour:
+		return strconv.Itoa(int(age/(7*24*time.Hour))) + "w"
+	}
+	return t.UTC().Format(time.DateOnly)
+}
+
+// attachTopComments loads the comment preview of every feed item with one
+// batched query. Items without comments are skipped.
+func (app *application) attachTopComments(ctx context.Context, feed []store.PostWithMetadata) error {
+	ids := make([]int64, 0, len(feed))
+	for _, post := range feed {
+		if post.CommentCount > 0 {
+			ids = append(ids, post.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	previews, err := app.store.Comments.GetPreviewsByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for i := range feed {
+		if c, ok := previews[feed[i].ID]; ok {
+			feed[i].TopComment = &c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollapseWarned(t *testing.T) {
+	feed := func() []store.PostWithMetadata {
+		return []store.PostWithMetadata{
+			{Post: store.Post{ID: 1, ContentWarning: "spoilers"}},
+			{Post: store.Post{ID: 2}},
+		}
+	}
+
+	t.Run("should collapse warned posts by default", func(t *testing.T) {
+		posts := feed()
+		collapseWarned(posts, &store.User{})
+		if !posts[0].Collapsed || posts[1].Collapsed {
+			t.Errorf("expected only the warned post to be collapsed, got %v and %v", posts[0].Collapsed, posts[1].Collapsed)
+		}
+	})
+
+	t.Run("should expand warned posts when the viewer prefers it", func(t *testing.T) {
+		posts := feed()
+		collapseWarned(posts, &store.User{ContentWarningPref: store.ContentWarningExpand})
+		if posts[0].Collapsed {
+			t.Error("expected the warned post to be expanded")
+		}
+	})
+}
+
+func TestGetFeedUpdates(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		cursor string
+		want   int
+	}{
+		{"should accept a feed cursor", store.EncodeFeedCursor(7), http.StatusOK},
+		{"should reject a malformed cursor", "bm9wZQ", http.StatusBadRequest},
+		{"should require a cursor", "", http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/feed/updates?since_cursor="+tc.cursor, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
+
+func TestFeedQueryMetrics(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"", "?search=go&sort=asc", "?search=gopher&sort=asc"} {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+	}
+
+	snapshot := app.feedMetrics.Snapshot()
+	if got := snapshot["search=false,tags=false,sort=desc"].Count; got != 1 {
+		t.Errorf("expected 1 unfiltered feed query, got %d", got)
+	}
+	if got := snapshot["search=true,tags=false,sort=asc"].Count; got != 2 {
+		t.Errorf("expected 2 searched feed queries, got %d", got)
+	}
+}
+
+type timeoutPostStore struct {
+	store.MockPostStore
+	err error
+}
+
+func (m *timeoutPostStore) GetUserFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	return nil, fmt.Errorf("loading feed: %w", m.err)
+}
+
+func TestFeedQueryTimeout(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	posts := &timeoutPostStore{}
+	app.store.Posts = posts
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := func() *httptest.ResponseRecorder {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux)
+	}
+
+	posts.err = fmt.Errorf("%w: %w", store.ErrQueryTimeout, context.DeadlineExceeded)
+	rr := feed()
+	checkResponseCode(t, http.StatusGatewayTimeout, rr.Code)
+	if !strings.Contains(rr.Body.String(), `"code":"query_timeout"`) {
+		t.Errorf("expected the query_timeout error code, got %s", rr.Body.String())
+	}
+
+	t.Run("other deadlines are not query timeouts", func(t *testing.T) {
+		posts.err = context.DeadlineExceeded
+		rr := feed()
+		checkResponseCode(t, http.StatusInternalServerError, rr.Code)
+	})
+}
+
+type slowPostStore struct {
+	store.MockPostStore
+	deadline bool
+}
+
+func (m *slowPostStore) GetUserFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	_, m.deadline = ctx.Deadline()
+	<-ctx.Done()
+	return nil, fmt.Errorf("%w: %w", store.ErrQueryTimeout, ctx.Err())
+}
+
+func TestFeedRequestDeadline(t *testing.T) {
+	app := NewTestApplication(t, config{
+		requestTimeouts: requestTimeoutsConfig{defaultTimeout: time.Minute, feed: 20 * time.Millisecond},
+	})
+	posts := &slowPostStore{}
+	app.store.Posts = posts
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "/v1/users/feed", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusGatewayTimeout, rr.Code)
+	if !posts.deadline {
+		t.Error("expected the feed query context to carry a deadline")
+	}
+}
+
+type blockingPostStore struct {
+	store.MockPostStore
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (m *blockingPostStore) GetUserFeed(ctx context.Context, userID int64, fq store.PaginatedFeedQuery) ([]store.PostWithMetadata, error) {
+	m.entered <- struct{}{}
+	<-m.release
+	return []store.PostWithMetadata{}, nil
+}
+
+func TestFeedConcurrencyLimit(t *testing.T) {
+	app := NewTestApplication(t, config{
+		concurrency: concurrencyConfig{feed: 1, retryAfter: 2 * time.Second},
+	})
+	posts := &blockingPostStore{entered: make(chan struct{}), release: make(chan struct{})}
+	app.store.Posts = posts
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/feed", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	first := make(chan int)
+	go func() {
+		first <- executeRequest(newRequest(), mux).Code
+	}()
+	<-posts.entered
+
+	rr := executeRequest(newRequest(), mux)
+	checkResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+	if got := rr.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("expected Retry-After 2, got %q", got)
+	}
+
+	close(posts.release)
+	checkResponseCode(t, http.StatusOK, <-first)
+}
+
+func TestRelativeTime(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		ago  time.Duration
+		want string
+	}{
+		{30 * time.Second, "now"},
+		{5 * time.Minute, "5m"},
+		{3*time.Hour + 59*time.Minute, "3h"},
+		{2 * 24 * time.Hour, "2d"},
+		{20 * 24 * time.Hour, "2w"},
+		{400 * 24 * time.Hour, "2025-09-12"},
+	} {
+		if got := relativeTime(now.Add(-tc.ago), now); got != tc.want {
+			t.Errorf("%v ago: expected %q, got %q", tc.ago, tc.want, got)
+		}
+	}
+
+	feed := []store.PostWithMetadata{{Post: store.Post{CreatedAt: now.Add(-90 * time.Second)}}}
+	stampAges(feed, now)
+	if feed[0].AgeSeconds != 90 || feed[0].CreatedAgo != "1m" {
+		t.Errorf("unexpected ages %d and %q", feed[0].AgeSeconds, feed[0].CreatedAgo)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFollowImportRows bounds a single import request.
+const maxFollowImportRows = 5000
+
+// FollowImportResult reports on the usernames of an import. Emails are
+// only counted: which of them belong to an account is never told, or the
+// import would look up who is registered.
+type FollowImportResult struct {
+	DryRun   bool     `json:"dry_run"`
+	Matched  int      `json:"matched"`
+	Followed int      `json:"followed"`
+	NotFound []string `json:"not_found"`
+	Emails   int      `json:"emails"`
+}
+
+// ExportFollowing godoc
+//
+//	@Summary		Export following
+//	@Description	Downloads the accounts the user follows as CSV (username, followed_at), streamed as it is read
+//	@Tags			users
+//	@Produce		text/csv
+//	@Success		200	{string}	string	"CSV file"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/following/export [get]
+func (app *application) exportFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	stream := streamCSV(w, r, "following.csv", []string{"username", "followed_at"})
+	err := app.store.Followers.EachFollowing(r.Context(), getUserFromContext(r).ID, func(f store.FollowedUser) error {
+		return stream.Write([]string{f.Username, f.FollowedAt.Format(time.RFC3339)})
+	})
+	if err == nil {
+		err = stream.Close()
+	}
+	if err != nil {
+		app.failStream(w, r, stream.responseStream, err)
+	}
+}
+
+// ImportFollowing godoc
+//
+//	@Summary		Import following
+//	@Description	Follows every account of a CSV whose first column holds a username or email, e.g. an export from another instance. Existing follows are skipped. Emails are followed silently: the result counts them without telling which belong to an account, and dry runs don't look them up. Imports, dry runs included, are limited to a few per hour.
+//	@Tags			users
+//	@Accept			text/csv
+//	@Produce		json
+//	@Param			dry_run	query		bool	false	"Only report what would happen"
+//	@Success		200		{object}	FollowImportResult
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/following/import [post]
+func (app *application) importFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	dryRun := false
+	if raw := r.URL.Query().Get("dry_run"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		dryRun = v
+	}
+	user := getUserFromContext(r)
+	if app.config.followImportLimiter.Enabled {
+		if allow, retryAfter := app.followImportLimiter.Allow(strconv.FormatInt(user.ID, 10)); !allow {
+			app.rateLimitExceedResponse(w, r, retryAfter.String())
+			return
+		}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+	handles, err := readFollowImport(r.Body)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var usernames, emails []string
+	for _, h := range handles {
+		if strings.Contains(h, "@") {
+			emails = append(emails, h)
+		} else {
+			usernames = append(usernames, h)
+		}
+	}
+	ctx := r.Context()
+	byUsername, err := app.store.Users.GetIDsByUsernames(ctx, usernames)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	result := FollowImportResult{DryRun: dryRun, NotFound: []string{}, Emails: len(emails)}
+	var ids []int64
+	for _, h := range usernames {
+		id, ok := byUsername[h]
+		if !ok {
+			result.NotFound = append(result.NotFound, h)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	result.Matched = len(ids)
+	if len(ids) > 0 {
+		result.Followed, err = app.store.Followers.FollowMany(ctx, user.ID, ids, dryRun)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	// Usernames are public, emails aren't: they are followed apart so the
+	// counts above don't give away which of them matched.
+	if len(emails) > 0 && !dryRun {
+		byEmail, err := app.store.Users.GetIDsByEmails(ctx, emails)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if len(byEmail) > 0 {
+			if _, err := app.store.Followers.FollowMany(ctx, user.ID, slices.Collect(maps.Values(byEmail)), false); err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, result); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// readFollowImport returns the distinct non-empty first column values of the
+// CSV, skipping a header row as written by the export.
+func readFollowImport(body io.Reader) ([]string, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	var handles []string
+	seen := make(map[string]bool)
+	for line := 0; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		h := strings.TrimPrefix(strings.TrimSpace(record[0]), "@")
+		if h == "" || (line == 0 && strings.EqualFold(h, "username")) || seen[h] {
+			continue
+		}
+		seen[h] = true
+		handles = append(handles, h)
+		if len(handles) > maxFollowImportRows {
+			return nil, fmt.Errorf("at most %d accounts can be imported at once", maxFollowImportRows)
+		}
+	}
+	if len(handles) == 0 {
+		return nil, errors.New("no accounts to import")
+	}
+	return handles, nil
+}
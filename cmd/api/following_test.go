@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/ratelimiter"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// emailUserStore knows friend@example.com as user 9.
+type emailUserStore struct {
+	store.MockUserStore
+}
+
+func (m *emailUserStore) GetIDsByEmails(ctx context.Context, emails []string) (map[string]int64, error) {
+	ids := map[string]int64{}
+	for _, e := range emails {
+		if strings.EqualFold(e, "friend@example.com") {
+			ids[e] = 9
+		}
+	}
+	return ids, nil
+}
+
+// followRecorder keeps the IDs of the follows really made.
+type followRecorder struct {
+	store.MockFollowerStore
+	followed []int64
+}
+
+func (m *followRecorder) FollowMany(ctx context.Context, followerID int64, userIDs []int64, dryRun bool) (int, error) {
+	if !dryRun {
+		m.followed = append(m.followed, userIDs...)
+	}
+	return len(userIDs), nil
+}
+
+func TestImportFollowing(t *testing.T) {
+	app := NewTestApplication(t, config{
+		followImportLimiter: ratelimiter.Config{RequestsPerTimeFrame: 1, TimeFrame: time.Hour, Enabled: true},
+	})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importCSV := func(t *testing.T, query, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/following/import"+query, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should limit imports per user", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, importCSV(t, "?dry_run=true", "username\ngopher\n").Code)
+		// Dry runs count too, they report which usernames exist.
+		checkResponseCode(t, http.StatusTooManyRequests, importCSV(t, "?dry_run=true", "username\ngopher\n").Code)
+	})
+
+	t.Run("should follow emails without telling which matched", func(t *testing.T) {
+		app.config.followImportLimiter.Enabled = false
+		app.store.Users = &emailUserStore{}
+		followers := &followRecorder{}
+		app.store.Followers = followers
+
+		rr := importCSV(t, "", "username\nfriend@example.com\nstranger@example.com\n")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		got := decodeData[FollowImportResult](t, rr.Body.String())
+		if got.Emails != 2 || got.Matched != 0 || got.Followed != 0 || len(got.NotFound) != 0 {
+			t.Errorf("expected the emails only counted, got %+v", got)
+		}
+		if len(followers.followed) != 1 || followers.followed[0] != 9 {
+			t.Errorf("expected the matching email to be followed, got %v", followers.followed)
+		}
+	})
+}
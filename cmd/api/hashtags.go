@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// minTrendingPosts keeps a tag used twice from trending on a quiet day.
+	minTrendingPosts = 3
+	// trendingBaseline damps the velocity of tags that barely existed in the
+	// previous window, otherwise 0 -> 3 posts would beat 100 -> 300.
+	trendingBaseline = 5
+	maxTrendingTags  = 50
+	relatedPerTag    = 20
+)
+
+// hashtagsConfig tunes trending and related tags. Trending compares the last
+// window with the one before it, counted in Redis per bucket.
+type hashtagsConfig struct {
+	bucket          time.Duration
+	window          time.Duration
+	relatedInterval time.Duration
+	relatedLookback time.Duration
+}
+
+// rankTrending orders the tags growing between the previous and the current
+// window by velocity, the growth relative to the damped previous count.
+func rankTrending(current, previous map[string]int, limit int) []store.TrendingTag {
+	tags := []store.TrendingTag{}
+	for tag, posts := range current {
+		prev := previous[tag]
+		if posts < minTrendingPosts || posts <= prev {
+			continue
+		}
+		tags = append(tags, store.TrendingTag{
+			Tag:      tag,
+			Posts:    posts,
+			Previous: prev,
+			Velocity: float64(posts-prev) / float64(prev+trendingBaseline),
+		})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Velocity != tags[j].Velocity {
+			return tags[i].Velocity > tags[j].Velocity
+		}
+		if tags[i].Posts != tags[j].Posts {
+			return tags[i].Posts > tags[j].Posts
+		}
+		return tags[i].Tag < tags[j].Tag
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags
+}
+
+// updateTrendingHashtags is the job maintaining trending tags in Redis. It
+// recounts the open bucket and the one just closed, so posts landing late in
+// a bucket are still counted, then ranks the windows from the buckets.
+func (app *application) updateTrendingHashtags(ctx context.Context) error {
+	cfg := app.config.hashtags
+	current := time.Now().UTC().Truncate(cfg.bucket)
+	exp := 2*cfg.window + cfg.bucket
+
+	for _, start := range []time.Time{current.Add(-cfg.bucket), current} {
+		counts, err := app.store.Hashtags.Counts(ctx, start, start.Add(cfg.bucket))
+		if err != nil {
+			return err
+		}
+		if err := app.cacheStorage.Hashtags.SetBucket(ctx, start, counts, exp); err != nil {
+			return err
+		}
+	}
+
+	perWindow := max(int(cfg.window/cfg.bucket), 1)
+	starts := make([]time.Time, 2*perWindow)
+	for i := range starts {
+		starts[i] = current.Add(-time.Duration(i) * cfg.bucket)
+	}
+	buckets, err := app.cacheStorage.Hashtags.GetBuckets(ctx, starts)
+	if err != nil {
+		return err
+	}
+
+	recent, previous := make(map[string]int), make(map[string]int)
+	for i, bucket := range buckets {
+		window := recent
+		if i >= perWindow {
+			window = previous
+		}
+		for tag, n := range bucket {
+			window[tag] += n
+		}
+	}
+
+	// The ranking expires when the job stops running rather than going stale.
+	tags := rankTrending(recent, previous, maxTrendingTags)
+	return app.cacheStorage.Hashtags.SetTrending(ctx, tags, 2*cfg.bucket)
+}
+
+// refreshRelatedHashtags is the job recomputing tag co-occurrence.
+func (app *application) refreshRelatedHashtags(ctx context.Context) error {
+	since := time.Now().Add(-app.config.hashtags.relatedLookback)
+	return app.store.Hashtags.RefreshRelated(ctx, since, relatedPerTag)
+}
+
+// trendingHashtags reads the ranking kept by the trending job. Without Redis,
+// or before the job has run, it is computed from Postgres instead.
+func (app *application) trendingHashtags(ctx context.Context) ([]store.TrendingTag, error) {
+	if app.config.redisCfg.enabled {
+		tags, err := app.cacheStorage.Hashtags.GetTrending(ctx)
+		if err != nil {
+			app.logger.Errorw("error reading cached trending hashtags", "error", err.Error())
+		}
+		if tags != nil {
+			return tags, nil
+		}
+	}
+
+	window := app.config.hashtags.window
+	now := time.Now()
+	recent, err := app.store.Hashtags.Counts(ctx, now.Add(-window), now)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := app.store.Hashtags.Counts(ctx, now.Add(-2*window), now.Add(-window))
+	if err != nil {
+		return nil, err
+	}
+	return rankTrending(recent, previous, maxTrendingTags), nil
+}
+
+// GetTrendingHashtags godoc
+//
+//	@Summary		Trending hashtags
+//	@Description	Tags gaining usage fastest, comparing the last window with the one before
+//	@Tags			hashtags
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 10, max 50)"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/hashtags/trending [get]
+func (app *application) getTrendingHashtagsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parseLimitOffset(r, 10, maxTrendingTags)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.trendingHashtags(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetRelatedHashtags godoc
+//
+//	@Summary		Related hashtags
+//	@Description	Tags most often used on the same posts as the given one
+//	@Tags			hashtags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit (default 10, max 20)"
+//	@Success		200		{object}	[]store.RelatedTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/hashtags/{tag}/related [get]
+func (app *application) getRelatedHashtagsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parseLimitOffset(r, 10, relatedPerTag)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.store.Hashtags.Related(r.Context(), chi.URLParam(r, "tag"), limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"gopher_social/internal/store/cache"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRankTrending(t *testing.T) {
+	current := map[string]int{"go": 300, "rust": 8, "new": 6, "rare": 2, "steady": 40}
+	previous := map[string]int{"go": 100, "rust": 1, "steady": 40}
+
+	tags := rankTrending(current, previous, 10)
+	var got []string
+	for _, tag := range tags {
+		got = append(got, tag.Tag)
+	}
+	want := []string{"go", "new", "rust"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+
+	if tags := rankTrending(current, previous, 1); len(tags) != 1 {
+		t.Errorf("expected the ranking to be cut at the limit, got %d tags", len(tags))
+	}
+}
+
+func TestUpdateTrendingHashtags(t *testing.T) {
+	app := NewTestApplication(t, config{
+		redisCfg: redisConfig{enabled: true},
+		hashtags: hashtagsConfig{bucket: 10 * time.Minute, window: 30 * time.Minute},
+	})
+	bucket := time.Now().UTC().Truncate(10 * time.Minute)
+	app.store.Hashtags = &store.MockHashtagStore{CountsByWindow: map[time.Time]map[string]int{
+		bucket:                        {"go": 4},
+		bucket.Add(-10 * time.Minute): {"go": 3},
+	}}
+	hashtags := &cache.MockHashtagStore{Buckets: map[time.Time]map[string]int{
+		bucket.Add(-40 * time.Minute): {"go": 2},
+	}}
+	app.cacheStorage.Hashtags = hashtags
+
+	if err := app.updateTrendingHashtags(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(hashtags.Trending) != 1 {
+		t.Fatalf("expected one trending tag, got %v", hashtags.Trending)
+	}
+	if tag := hashtags.Trending[0]; tag.Posts != 7 || tag.Previous != 2 {
+		t.Errorf("expected 7 posts against 2 before, got %d against %d", tag.Posts, tag.Previous)
+	}
+
+	tags, err := app.trendingHashtags(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Tag != "go" {
+		t.Errorf("expected the cached ranking, got %v", tags)
+	}
+
+	app.cacheStorage.Hashtags = &downHashtagCache{}
+	if _, err := app.trendingHashtags(context.Background()); err != nil {
+		t.Errorf("expected the ranking from Postgres while Redis is down, got %v", err)
+	}
+}
+
+// downHashtagCache fails reads like an unreachable Redis.
+type downHashtagCache struct {
+	cache.MockHashtagStore
+}
+
+func (m *downHashtagCache) GetTrending(ctx context.Context) ([]store.TrendingTag, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestHashtagRoutes(t *testing.T) {
+	app := NewTestApplication(t, config{hashtags: hashtagsConfig{window: time.Hour}})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		path string
+		want int
+	}{
+		{"should compute trending tags without Redis", "/v1/hashtags/trending", http.StatusOK},
+		{"should reject a large trending limit", "/v1/hashtags/trending?limit=500", http.StatusBadRequest},
+		{"should list related tags", "/v1/hashtags/golang/related?limit=5", http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tc.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"database/sql"
+	"errors"
 	"net/http"
 )
 
@@ -28,3 +30,55 @@ func (app *application) healthCheckHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 }
+
+// poolStats is the connection pool summary published at /debug/vars as
+// database_pool, with durations in milliseconds for dashboards.
+func poolStats(s sql.DBStats) map[string]any {
+	return map[string]any{
+		"max_open":             s.MaxOpenConnections,
+		"open":                 s.OpenConnections,
+		"in_use":               s.InUse,
+		"idle":                 s.Idle,
+		"wait_count":           s.WaitCount,
+		"wait_duration_ms":     s.WaitDuration.Milliseconds(),
+		"max_idle_closed":      s.MaxIdleClosed,
+		"max_idle_time_closed": s.MaxIdleTimeClosed,
+		"max_lifetime_closed":  s.MaxLifetimeClosed,
+	}
+}
+
+type readiness struct {
+	Status          string `json:"status"`
+	SchemaVersion   int    `json:"schema_version"`
+	ExpectedVersion int    `json:"expected_schema_version"`
+	Error           string `json:"error,omitempty"`
+}
+
+// Readiness godoc
+//
+//	@Summary		Readiness check
+//	@Description	Reports whether this instance can serve traffic. It is degraded with a 503 while the database schema is behind the version this build expects, a migration is dirty, or the cache is still being warmed after startup.
+//	@Tags			ops
+//	@Produce		json
+//	@Success		200	{object}	readiness
+//	@Failure		503	{object}	readiness
+//	@Router			/health/ready [get]
+func (app *application) readinessHandler(w http.ResponseWriter, r *http.Request) {
+	status := readiness{Status: "ready", ExpectedVersion: schemaVersion}
+	version, dirty, err := app.store.Schema.Version(r.Context())
+	if err == nil {
+		status.SchemaVersion = version
+		err = schemaCompatibility(version, dirty)
+	}
+	if err == nil && app.warming.Load() {
+		err = errors.New("warming the cache")
+	}
+	if err != nil {
+		app.logger.Warnw("instance not ready", "error", err.Error())
+		status.Status = "degraded"
+		status.Error = err.Error()
+		writeJSON(w, http.StatusServiceUnavailable, status)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type ImpersonatePayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type ImpersonationToken struct {
+	Token     string    `json:"token"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// impersonatorID returns the admin acting through an impersonation token,
+// named in the token's "act" claim as in RFC 8693.
+func impersonatorID(claims jwt.MapClaims) (int64, bool) {
+	act, ok := claims["act"].(map[string]any)
+	if !ok {
+		return 0, false
+	}
+	sub, ok := act["sub"].(float64)
+	return int64(sub), ok
+}
+
+// isImpersonated reports whether the request was made by an admin
+// impersonating the user.
+func isImpersonated(ctx context.Context) bool {
+	claims, _ := ctx.Value(claimsCtx).(jwt.MapClaims)
+	_, ok := impersonatorID(claims)
+	return ok
+}
+
+// impersonationDenied are the routes impersonation tokens never reach on top
+// of the scoped tokens' ones: the user's account settings can't be changed.
+// Deleting anything is refused everywhere, and without an auth_time claim the
+// actions behind RequireSudo are too.
+var impersonationDenied = []string{"/v1/users/me/"}
+
+func impersonationAllows(r *http.Request) bool {
+	if r.Method == http.MethodDelete {
+		return false
+	}
+	for _, prefix := range scopedDenied {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	if isSafeMethod(r.Method) {
+		return true
+	}
+	for _, prefix := range impersonationDenied {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeImpersonation checks an impersonated request, the admin behind it
+// has to still be one. Every request allowed is audit logged.
+func (app *application) authorizeImpersonation(ctx context.Context, r *http.Request, actorID int64, user *store.User) (bool, error) {
+	if !impersonationAllows(r) {
+		app.auditLog("impersonation.denied", actorID, "user_id", user.ID, "method", r.Method, "path", r.URL.Path)
+		return false, nil
+	}
+	actor, err := app.getUser(ctx, actorID)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	allowed, err := app.checkRolePrecedence(ctx, actor, "admin")
+	if err != nil || !allowed {
+		return false, err
+	}
+	app.auditLog("impersonation.request", actorID, "user_id", user.ID, "method", r.Method, "path", r.URL.Path)
+	return true, nil
+}
+
+// Impersonate godoc
+//
+//	@Summary		Impersonate a user
+//	@Description	Issues a short lived token acting as the user, to reproduce what they see. The token names the admin in its act claim, cannot delete anything, change the account's settings or reach admin routes, and every request made with it is audit logged. Only plain users can be impersonated
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		ImpersonatePayload	true	"Why the user is impersonated"
+//	@Success		201		{object}	ImpersonationToken
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Reauthentication required, or admins, moderators and service accounts can't be impersonated"
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/users/{userID}/impersonate [post]
+func (app *application) impersonateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload ImpersonatePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if user.IsBot {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	elevated, err := app.checkRolePrecedence(ctx, user, "moderator")
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if elevated {
+		app.forbiddenResponse(w, r)
+		return
+	}
+
+	admin := getUserFromContext(r)
+	now := time.Now()
+	expiresAt := now.Add(app.config.auth.impersonationTTL)
+	// no auth_time, so nothing behind RequireSudo accepts the token
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"exp": expiresAt.Unix(),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+		"act": map[string]any{"sub": admin.ID},
+	}
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.auditLog("impersonation.start", admin.ID, "user_id", user.ID, "reason", payload.Reason, "expires_at", expiresAt)
+	resp := ImpersonationToken{Token: token, UserID: user.ID, ExpiresAt: expiresAt}
+	if err := app.jsonResponse(w, http.StatusCreated, resp); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
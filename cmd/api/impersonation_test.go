@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// impersonationUserStore has 42 as an admin, 8 as a moderator and 9 as a
+// service account, everyone else is a plain user.
+type impersonationUserStore struct {
+	store.MockUserStore
+}
+
+func (m *impersonationUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	user := &store.User{ID: id, Role: &store.Role{Name: "user", Level: 1}}
+	switch id {
+	case 42:
+		user.Role = &store.Role{Name: "admin", Level: 3}
+	case 8:
+		user.Role = &store.Role{Name: "moderator", Level: 2}
+	case 9:
+		user.IsBot = true
+	}
+	return user, nil
+}
+
+// signTestToken signs claims the way the test authenticator validates them.
+func signTestToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestImpersonation(t *testing.T) {
+	app := NewTestApplication(t, config{auth: authConfig{sudoWindow: time.Minute, impersonationTTL: time.Minute}})
+	app.store.Users = &impersonationUserStore{}
+	mux := app.mount()
+
+	request := func(t *testing.T, token, method, path, body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux).Result()
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+	adminToken := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": exp, "auth_time": time.Now().Unix()})
+
+	t.Run("should issue a token for plain users only", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, adminToken, http.MethodPost, "/v1/admin/users/7/impersonate", `{}`).StatusCode)
+		for _, id := range []string{"8", "9", "42"} {
+			resp := request(t, adminToken, http.MethodPost, "/v1/admin/users/"+id+"/impersonate", `{"reason":"ticket 1234"}`)
+			checkResponseCode(t, http.StatusForbidden, resp.StatusCode)
+		}
+		checkResponseCode(t, http.StatusCreated, request(t, adminToken, http.MethodPost, "/v1/admin/users/7/impersonate", `{"reason":"ticket 1234"}`).StatusCode)
+	})
+
+	t.Run("should require a recent authentication to issue", func(t *testing.T) {
+		stale := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": exp})
+		checkResponseCode(t, http.StatusForbidden, request(t, stale, http.MethodPost, "/v1/admin/users/7/impersonate", `{"reason":"ticket 1234"}`).StatusCode)
+	})
+
+	token := signTestToken(t, jwt.MapClaims{"sub": 7, "exp": exp, "act": map[string]any{"sub": 42}})
+
+	t.Run("should act as the user and say who is acting", func(t *testing.T) {
+		resp := request(t, token, http.MethodGet, "/v1/users/7", "")
+		checkResponseCode(t, http.StatusOK, resp.StatusCode)
+		if got := resp.Header.Get("X-Impersonated-By"); got != "42" {
+			t.Errorf("expected X-Impersonated-By 42, got %q", got)
+		}
+	})
+
+	t.Run("should block destructive and privileged actions", func(t *testing.T) {
+		for _, tc := range []struct{ method, path string }{
+			{http.MethodDelete, "/v1/posts/1"},
+			{http.MethodPut, "/v1/users/me/retention"},
+			{http.MethodGet, "/v1/admin/holds"},
+			{http.MethodPost, "/v1/auth/sudo"},
+		} {
+			if got := request(t, token, tc.method, tc.path, "").StatusCode; got != http.StatusForbidden {
+				t.Errorf("%s %s: expected 403, got %d", tc.method, tc.path, got)
+			}
+		}
+	})
+
+	t.Run("should stop working once the actor is no longer an admin", func(t *testing.T) {
+		token := signTestToken(t, jwt.MapClaims{"sub": 7, "exp": exp, "act": map[string]any{"sub": 5}})
+		checkResponseCode(t, http.StatusForbidden, request(t, token, http.MethodGet, "/v1/users/7", "").StatusCode)
+	})
+}
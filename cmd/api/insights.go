@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const defaultInsightsDays = 30
+
+// parseInsightsSince turns the ?days= look-back of the insights endpoints into
+// a start time.
+func parseInsightsSince(r *http.Request) (time.Time, error) {
+	days := defaultInsightsDays
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		d, err := strconv.Atoi(raw)
+		if err != nil {
+			return time.Time{}, err
+		}
+		days = d
+	}
+	if err := Validate.Var(days, "gte=1,lte=365"); err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
+
+// recordImpression counts a view of the post. Failures are only logged, a
+// lost impression must never break reading the post. Authors viewing their
+// own post are skipped.
+func (app *application) recordImpression(r *http.Request, post *store.Post) {
+	viewer := getUserFromContext(r)
+	if viewer.ID == post.UserID {
+		return
+	}
+	source := r.URL.Query().Get("src")
+	if !slices.Contains(store.ImpressionSources, source) {
+		source = store.ImpressionSourceDirect
+	}
+	if err := app.store.Impressions.Record(r.Context(), post.ID, viewer.ID, source); err != nil {
+		app.logger.Errorw("error recording impression", "post_id", post.ID, "error", err.Error())
+	}
+}
+
+// GetPostInsights godoc
+//
+//	@Summary		Post insights
+//	@Description	Views over time, unique viewers, reaction breakdown and referral sources of a post. Only available to its author.
+//	@Tags			posts
+//	@Produce		json
+//
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			days	query		int	false	"Days to look back (default 30, max 365)"
+//
+//	@Success		200		{object}	store.PostInsights
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/insights [get]
+func (app *application) getPostInsightsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	since, err := parseInsightsSince(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	insights, err := app.store.Impressions.Insights(r.Context(), post.ID, post.UserID, since)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, insights); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetFollowerInsights godoc
+//
+//	@Summary		Follower insights
+//	@Description	Follower growth over time, unfollows and the most engaged followers of the authenticated user. Figures other than the follower total are aggregated nightly.
+//	@Tags			users
+//	@Produce		json
+//	@Param			days	query		int	false	"Days to look back (default 30, max 365)"
+//	@Success		200		{object}	store.FollowerInsights
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/insights [get]
+func (app *application) getFollowerInsightsHandler(w http.ResponseWriter, r *http.Request) {
+	since, err := parseInsightsSince(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	insights, err := app.store.Analytics.FollowerInsights(r.Context(), getUserFromContext(r).ID, since)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, insights); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// aggregateFollowerAnalytics is the job behind the follower insights, it
+// rolls up every finished UTC day not aggregated yet.
+func (app *application) aggregateFollowerAnalytics(ctx context.Context) error {
+	return app.aggregateAnalytics(ctx, store.AnalyticsFollowers, func(ctx context.Context, day time.Time) error {
+		return app.store.Analytics.AggregateFollowers(ctx, day, app.config.analytics.engagementWindow)
+	})
+}
+
+// aggregateAnalytics runs aggregate for each UTC day from the one after the
+// named aggregation last recorded through yesterday, at most backfillDays of
+// them, all under the job's timeout. An aggregation that never ran starts
+// with yesterday.
+func (app *application) aggregateAnalytics(ctx context.Context, name string, aggregate func(context.Context, time.Time) error) error {
+	cfg := app.config.analytics
+	ctx, cancel := context.WithTimeout(ctx, cfg.timeout)
+	defer cancel()
+
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	from := yesterday
+	last, err := app.store.Analytics.LastAggregated(ctx, name)
+	switch {
+	case err == nil:
+		from = last.AddDate(0, 0, 1)
+	case !errors.Is(err, store.ErrRecordNotFound):
+		return err
+	}
+	if earliest := yesterday.AddDate(0, 0, 1-max(cfg.backfillDays, 1)); from.Before(earliest) {
+		from = earliest
+	}
+
+	for day := from; !day.After(yesterday); day = day.AddDate(0, 0, 1) {
+		if err := aggregate(ctx, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
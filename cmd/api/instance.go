@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// instanceConfig sets how often every instance reloads the settings, the
+// instance serving an admin's change applies it right away.
+type instanceConfig struct {
+	refreshInterval time.Duration
+}
+
+// instanceState holds the settings loaded by refreshInstance. The zero value
+// has none and enforces store.DefaultContentLimits.
+type instanceState struct {
+	settings atomic.Pointer[store.InstanceSettings]
+}
+
+// Ceilings of the content limits admins can set. The validate tags of the
+// payloads, which can't reference them, check against these before the
+// instance's own limits apply.
+const (
+	ceilingPostTitleLength   = 1000
+	ceilingPostLength        = 100000
+	ceilingArticleBodyLength = 1000000
+	ceilingCommentLength     = 100000
+)
+
+// refreshInstance reloads the settings. It runs as a job and after admins
+// change them.
+func (app *application) refreshInstance(ctx context.Context) error {
+	settings, err := app.store.Instance.Get(ctx)
+	if err != nil {
+		return err
+	}
+	app.instance.settings.Store(settings)
+	return nil
+}
+
+// contentLimits are the limits in effect with the upload limit resolved.
+func (app *application) contentLimits() store.ContentLimits {
+	limits := store.DefaultContentLimits
+	if settings := app.instance.settings.Load(); settings != nil {
+		limits = settings.Limits
+	}
+	return app.effectiveLimits(limits)
+}
+
+// effectiveLimits resolves the upload limit, which can't go past what the
+// server is configured to accept.
+func (app *application) effectiveLimits(limits store.ContentLimits) store.ContentLimits {
+	if limits.MaxUploadBytes <= 0 || limits.MaxUploadBytes > app.config.media.maxUploadBytes {
+		limits.MaxUploadBytes = app.config.media.maxUploadBytes
+	}
+	return limits
+}
+
+// checkLength counts characters the way the validate tags do, a nil value
+// isn't being set and passes.
+func checkLength(field string, value *string, limit int) error {
+	if value != nil && utf8.RuneCountInString(*value) > limit {
+		return fmt.Errorf("%s is longer than %d characters", field, limit)
+	}
+	return nil
+}
+
+// checkPostLimits checks the parts of a post being set against the
+// instance's limits.
+func (app *application) checkPostLimits(title, content, body *string) error {
+	limits := app.contentLimits()
+	if err := checkLength("title", title, limits.MaxPostTitleLength); err != nil {
+		return err
+	}
+	if err := checkLength("content", content, limits.MaxPostLength); err != nil {
+		return err
+	}
+	return checkLength("body", body, limits.MaxArticleBodyLength)
+}
+
+// InstanceLimits are the policies clients should check before submitting.
+type InstanceLimits struct {
+	store.ContentLimits
+	// MinimumAge is the age users must have reached to sign up.
+	MinimumAge int `json:"minimum_age"`
+}
+
+type Instance struct {
+	store.InstanceSettings
+	Version string         `json:"version"`
+	Limits  InstanceLimits `json:"limits"`
+}
+
+func (app *application) newInstance(settings *store.InstanceSettings) Instance {
+	return Instance{
+		InstanceSettings: *settings,
+		Version:          app.config.version,
+		Limits: InstanceLimits{
+			ContentLimits: app.effectiveLimits(settings.Limits),
+			MinimumAge:    app.config.age.minAge,
+		},
+	}
+}
+
+// GetInstance godoc
+//
+//	@Summary		Instance metadata
+//	@Description	The name, description, rules and admin contact of this instance with the limits it enforces, for clients and federation peers to discover its policies
+//	@Tags			instance
+//	@Produce		json
+//	@Success		200	{object}	Instance
+//	@Failure		500	{object}	error
+//	@Router			/instance [get]
+func (app *application) getInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := app.store.Instance.Get(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, app.newInstance(settings)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type ContentLimitsPayload struct {
+	MaxPostTitleLength   int `json:"max_post_title_length" validate:"min=1,max=1000"`
+	MaxPostLength        int `json:"max_post_length" validate:"min=1,max=100000"`
+	MaxArticleBodyLength int `json:"max_article_body_length" validate:"min=1,max=1000000"`
+	MaxCommentLength     int `json:"max_comment_length" validate:"min=1,max=100000"`
+	// MaxUploadBytes 0 accepts as much as the server is configured to.
+	MaxUploadBytes int64 `json:"max_upload_bytes" validate:"min=0"`
+}
+
+type UpdateInstancePayload struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	Description  string   `json:"description" validate:"max=2000"`
+	Rules        []string `json:"rules" validate:"max=50,dive,required,max=500"`
+	ContactEmail string   `json:"contact_email" validate:"omitempty,email,max=255"`
+	// Limits replace the content limits, they are kept when omitted.
+	Limits *ContentLimitsPayload `json:"limits"`
+}
+
+// UpdateInstance godoc
+//
+//	@Summary		Update instance metadata
+//	@Description	Replaces the name, description, rules, admin contact and, when given, the content limits published at /instance. The upload limit can't exceed what the server is configured to accept. Every instance picks the limits up within the refresh interval
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		UpdateInstancePayload	true	"Settings"
+//	@Success		200		{object}	Instance
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/instance [put]
+func (app *application) updateInstanceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload UpdateInstancePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.Limits != nil && payload.Limits.MaxUploadBytes > app.config.media.maxUploadBytes {
+		app.badRequestResponse(w, r, fmt.Errorf("max_upload_bytes can't exceed %d", app.config.media.maxUploadBytes))
+		return
+	}
+	if payload.Rules == nil {
+		payload.Rules = []string{}
+	}
+
+	ctx := r.Context()
+	current, err := app.store.Instance.Get(ctx)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	admin := getUserFromContext(r)
+	settings := &store.InstanceSettings{
+		Name:         payload.Name,
+		Description:  payload.Description,
+		Rules:        payload.Rules,
+		ContactEmail: payload.ContactEmail,
+		Limits:       current.Limits,
+		UpdatedBy:    admin.ID,
+	}
+	if l := payload.Limits; l != nil {
+		settings.Limits = store.ContentLimits{
+			MaxPostTitleLength:   l.MaxPostTitleLength,
+			MaxPostLength:        l.MaxPostLength,
+			MaxArticleBodyLength: l.MaxArticleBodyLength,
+			MaxCommentLength:     l.MaxCommentLength,
+			MaxUploadBytes:       l.MaxUploadBytes,
+		}
+	}
+	if err := app.store.Instance.Update(ctx, settings); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.auditLog("instance.update", admin.ID, "name", settings.Name, "rules", len(settings.Rules), "limits", settings.Limits)
+	app.instance.settings.Store(settings)
+
+	if err := app.jsonResponse(w, http.StatusOK, app.newInstance(settings)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
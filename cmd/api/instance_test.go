@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInstance(t *testing.T) {
+	app := NewTestApplication(t, config{version: "test", age: ageConfig{minAge: 13}, media: mediaConfig{maxUploadBytes: 1 << 20}})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := app.mount()
+
+	request := func(t *testing.T, method, path, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should validate payloads against the ceilings of the limits", func(t *testing.T) {
+		for _, c := range []struct {
+			payload any
+			field   string
+			limit   int
+		}{
+			{CreatePostPayload{}, "Title", ceilingPostTitleLength},
+			{CreatePostPayload{}, "Content", ceilingPostLength},
+			{CreatePostPayload{}, "Body", ceilingArticleBodyLength},
+			{UpdatePostPayload{}, "Title", ceilingPostTitleLength},
+			{UpdatePostPayload{}, "Content", ceilingPostLength},
+			{UpdatePostPayload{}, "Body", ceilingArticleBodyLength},
+			{CreateCommentPayload{}, "Content", ceilingCommentLength},
+			{ContentLimitsPayload{}, "MaxPostTitleLength", ceilingPostTitleLength},
+			{ContentLimitsPayload{}, "MaxPostLength", ceilingPostLength},
+			{ContentLimitsPayload{}, "MaxArticleBodyLength", ceilingArticleBodyLength},
+			{ContentLimitsPayload{}, "MaxCommentLength", ceilingCommentLength},
+		} {
+			f, _ := reflect.TypeOf(c.payload).FieldByName(c.field)
+			if tag := f.Tag.Get("validate"); !strings.Contains(tag, fmt.Sprintf("max=%d,", c.limit)) && !strings.HasSuffix(tag, fmt.Sprintf("max=%d", c.limit)) {
+				t.Errorf("%T.%s is validated with %q, the ceiling is max=%d", c.payload, c.field, tag, c.limit)
+			}
+		}
+	})
+
+	t.Run("should serve the instance metadata", func(t *testing.T) {
+		code, body := request(t, http.MethodGet, "/v1/instance", "")
+		checkResponseCode(t, http.StatusOK, code)
+		instance := decodeData[Instance](t, body)
+		if instance.Name != "GopherSocial" || instance.Version != "test" || instance.Limits.MaxPostLength != store.DefaultContentLimits.MaxPostLength || instance.Limits.MinimumAge != 13 {
+			t.Errorf("unexpected instance: %+v", instance)
+		}
+	})
+
+	t.Run("should let only admins update it", func(t *testing.T) {
+		payload := `{"name":"Gophers","rules":["be kind"],"contact_email":"admin@example.com"}`
+		code, _ := request(t, http.MethodPut, "/v1/admin/instance", payload)
+		checkResponseCode(t, http.StatusForbidden, code)
+
+		app.store.Users = &adminUserStore{}
+		code, _ = request(t, http.MethodPut, "/v1/admin/instance", `{"name":"Gophers","contact_email":"nope"}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+		code, _ = request(t, http.MethodPut, "/v1/admin/instance", payload)
+		checkResponseCode(t, http.StatusOK, code)
+
+		_, body := request(t, http.MethodGet, "/v1/instance", "")
+		instance := decodeData[Instance](t, body)
+		if instance.Name != "Gophers" || len(instance.Rules) != 1 || instance.ContactEmail != "admin@example.com" {
+			t.Errorf("unexpected instance after update: %+v", instance)
+		}
+		if instance.Limits.ContentLimits != app.effectiveLimits(store.DefaultContentLimits) {
+			t.Errorf("expected the limits to be kept, got %+v", instance.Limits)
+		}
+	})
+
+	t.Run("should enforce the limits admins set", func(t *testing.T) {
+		code, _ := request(t, http.MethodPost, "/v1/posts", `{"title":"Long form","content":"`+strings.Repeat("a", 1500)+`"}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+
+		limits := `{"max_post_title_length":200,"max_post_length":5000,"max_article_body_length":200000,"max_comment_length":10,"max_upload_bytes":1024}`
+		code, _ = request(t, http.MethodPut, "/v1/admin/instance", `{"name":"Gophers","limits":{"max_post_title_length":0}}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+		code, _ = request(t, http.MethodPut, "/v1/admin/instance", `{"name":"Gophers","limits":`+strings.Replace(limits, "1024", "999999999", 1)+`}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+		code, body := request(t, http.MethodPut, "/v1/admin/instance", `{"name":"Gophers","limits":`+limits+`}`)
+		checkResponseCode(t, http.StatusOK, code)
+		if got := decodeData[Instance](t, body).Limits; got.MaxPostLength != 5000 || got.MaxUploadBytes != 1024 {
+			t.Errorf("unexpected limits %+v", got)
+		}
+
+		code, _ = request(t, http.MethodPost, "/v1/posts", `{"title":"Long form","content":"`+strings.Repeat("a", 1500)+`"}`)
+		checkResponseCode(t, http.StatusCreated, code)
+		code, _ = request(t, http.MethodPost, "/v1/posts/1/comments", `{"content":"far too long for this instance"}`)
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"gopher_social/internal/scheduler"
+	"time"
+)
+
+// registerJobs adds the enabled background jobs to the scheduler.
+func (app *application) registerJobs(s *scheduler.Scheduler) {
+	s.Add(scheduler.Job{
+		Name:     "mail-outbox",
+		Interval: time.Minute,
+		Run:      app.drainMailOutbox,
+	})
+	s.Add(scheduler.Job{
+		Name:     "mail-outbox-prune",
+		Interval: time.Hour,
+		Run:      app.pruneMailOutbox,
+	})
+	s.Add(scheduler.Job{
+		Name:     "outbox-prune",
+		Interval: time.Hour,
+		Run:      app.pruneOutbox,
+	})
+	s.Add(scheduler.Job{
+		Name:     "oauth-codes",
+		Interval: time.Hour,
+		Run:      app.pruneOAuthCodes,
+	})
+	s.Add(scheduler.Job{
+		Name:     "sessions-prune",
+		Interval: time.Hour,
+		Run:      app.pruneSessions,
+	})
+	s.Add(scheduler.Job{
+		Name:     "partitions",
+		Interval: app.config.partitions.interval,
+		Run:      app.maintainPartitions,
+	})
+	s.Add(scheduler.Job{
+		Name:     "counter-compaction",
+		Interval: app.config.counters.compactInterval,
+		Run:      app.compactCounters,
+	})
+	s.Add(scheduler.Job{
+		Name:     "account-recovery",
+		Interval: app.config.recovery.interval,
+		Run:      app.issueRecoveryLinks,
+	})
+	s.Add(scheduler.Job{
+		Name:     "emoji",
+		Interval: app.config.emoji.refreshInterval,
+		Run:      app.refreshEmoji,
+		Local:    true,
+	})
+	s.Add(scheduler.Job{
+		Name:     "profile-fields",
+		Interval: app.config.profileFields.interval,
+		Run:      app.verifyProfileFields,
+	})
+	s.Add(scheduler.Job{
+		Name:     "repo-cards",
+		Interval: app.config.github.interval,
+		Run:      app.fetchRepoCards,
+	})
+	s.Add(scheduler.Job{
+		Name:     "maintenance",
+		Interval: app.config.maintenance.refreshInterval,
+		Run:      app.refreshMaintenance,
+		Local:    true,
+	})
+	s.Add(scheduler.Job{
+		Name:     "instance",
+		Interval: app.config.instance.refreshInterval,
+		Run:      app.refreshInstance,
+		Local:    true,
+	})
+	s.Add(scheduler.Job{
+		Name:     "crosspost",
+		Interval: app.config.crosspost.interval,
+		Run:      app.deliverCrossposts,
+	})
+	s.Add(scheduler.Job{
+		Name:     "badges",
+		Interval: app.config.badges.interval,
+		Run:      app.assignBadges,
+	})
+	s.Add(scheduler.Job{
+		Name:     "event-reminders",
+		Interval: app.config.events.interval,
+		Run:      app.remindEvents,
+	})
+	s.Add(scheduler.Job{
+		Name:     "post-retention",
+		Interval: app.config.retention.interval,
+		Run:      app.purgeExpiredPosts,
+	})
+	if cfg := app.config.archive; cfg.enabled {
+		s.Add(scheduler.Job{
+			Name:     "post-archive",
+			Interval: cfg.interval,
+			Run:      app.archivePosts,
+		})
+	}
+	s.Add(scheduler.Job{
+		Name:     "related-hashtags",
+		Interval: app.config.hashtags.relatedInterval,
+		Run:      app.refreshRelatedHashtags,
+	})
+	if app.config.redisCfg.enabled {
+		s.Add(scheduler.Job{
+			Name:     "trending-hashtags",
+			Interval: app.config.hashtags.bucket,
+			Run:      app.updateTrendingHashtags,
+		})
+	}
+	if app.hotKeys != nil {
+		s.Add(scheduler.Job{
+			Name:     "hot-keys",
+			Interval: app.config.cacheWarm.interval,
+			Run:      app.flushHotKeys,
+			Local:    true,
+		})
+	}
+	if app.broker != nil {
+		s.Add(scheduler.Job{
+			Name:     "event-relay",
+			Interval: app.config.broker.interval,
+			Run:      app.relayEvents,
+		})
+	}
+	if cfg := app.config.search; cfg.enabled {
+		s.Add(scheduler.Job{
+			Name:     "search-indexer",
+			Interval: cfg.interval,
+			Run:      app.indexPosts,
+		})
+	}
+	if app.push != nil {
+		s.Add(scheduler.Job{
+			Name:     "push-notifications",
+			Interval: app.config.push.interval,
+			Run:      app.dispatchPushNotifications,
+		})
+	}
+	if cfg := app.config.notifications; cfg.followBackEnabled {
+		s.Add(scheduler.Job{
+			Name:     "follow-back-suggestions",
+			Interval: cfg.followBackInterval,
+			Run:      app.suggestFollowBacks,
+		})
+	}
+	if app.terms != nil {
+		s.Add(scheduler.Job{
+			Name:     "terms",
+			Interval: app.config.terms.refreshInterval,
+			Run:      app.refreshTerms,
+			Local:    true,
+		})
+	}
+	if app.banner != nil {
+		s.Add(scheduler.Job{
+			Name:     "announcements",
+			Interval: app.config.announcements.refreshInterval,
+			Run:      app.refreshAnnouncements,
+			Local:    true,
+		})
+	}
+	if cfg := app.config.analytics; cfg.enabled {
+		s.Add(scheduler.Job{
+			Name:     "follower-analytics",
+			Interval: cfg.interval,
+			Run:      app.aggregateFollowerAnalytics,
+		})
+		s.Add(scheduler.Job{
+			Name:     "platform-analytics",
+			Interval: cfg.interval,
+			Run:      app.aggregatePlatformAnalytics,
+		})
+	}
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"io"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/bytedance/sonic"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,12 +21,72 @@ var Validate *validator.Validate
 
 func init() {
 	Validate = validator.New(validator.WithRequiredStructEnabled())
+	Validate.RegisterValidation("scope", func(fl validator.FieldLevel) bool {
+		return store.ValidScope(fl.Field().String())
+	})
+	Validate.RegisterValidation("redirect_uri", func(fl validator.FieldLevel) bool {
+		return validRedirectURI(fl.Field().String())
+	})
+}
+
+// jsonEncoder marshals response bodies. encoding/json is the default,
+// JSON_ENCODER=sonic picks sonic in its standard library compatible mode,
+// about twice as fast on a full feed page.
+type jsonEncoder interface {
+	Encode(w io.Writer, v any) error
+}
+
+type stdJSONEncoder struct{}
+
+func (stdJSONEncoder) Encode(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+type sonicEncoder struct {
+	api sonic.API
 }
 
+func (e sonicEncoder) Encode(w io.Writer, v any) error {
+	return e.api.NewEncoder(w).Encode(v)
+}
+
+func newJSONEncoder(name string) (jsonEncoder, error) {
+	switch name {
+	case "", "std":
+		return stdJSONEncoder{}, nil
+	case "sonic":
+		return sonicEncoder{api: sonic.ConfigStd}, nil
+	default:
+		return nil, fmt.Errorf("unknown JSON encoder %q, use std or sonic", name)
+	}
+}
+
+// responseEncoder is set once at startup from config.
+var responseEncoder jsonEncoder = stdJSONEncoder{}
+
+// maxPooledJSONBuffer keeps the odd huge response from pinning its buffer in
+// the pool.
+const maxPooledJSONBuffer = 1 << 20
+
+var jsonBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// writeJSON encodes data into a pooled buffer before writing anything, so an
+// encoding error still leaves the caller free to answer with an error.
 func writeJSON(w http.ResponseWriter, status int, data interface{}) error {
+	buf := jsonBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledJSONBuffer {
+			jsonBuffers.Put(buf)
+		}
+	}()
+	if err := responseEncoder.Encode(buf, data); err != nil {
+		return err
+	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
-	return json.NewEncoder(w).Encode(data)
+	_, err := w.Write(buf.Bytes())
+	return err
 }
 
 func readJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
@@ -42,3 +112,160 @@ func (app *application) jsonResponse(w http.ResponseWriter, status int, data int
 		Data: data,
 	})
 }
+
+// streamFlushEvery is how many records a stream sends per chunk.
+const streamFlushEvery = 100
+
+// streamWriteTimeout is how long a chunk may take to reach the client. Every
+// chunk pushes the connection's write deadline out again, so an export lasts
+// as long as the client keeps reading and one that stalls is dropped. The
+// request deadline still bounds the whole stream.
+const streamWriteTimeout = 30 * time.Second
+
+// responseStream writes a response record by record instead of buffering it.
+// Nothing is sent before the first record, so an error that comes earlier
+// can still be answered normally. Writes block while the client is not
+// reading, which in turn holds back the producer and its database cursor.
+type responseStream struct {
+	w           http.ResponseWriter
+	rc          *http.ResponseController
+	ctx         context.Context
+	contentType string
+	filename    string
+	started     bool
+	pending     int
+	// begin writes a format's preamble, such as a CSV header.
+	begin func() error
+	// flushBuffer empties a format's own buffer before each chunk.
+	flushBuffer func() error
+}
+
+func newResponseStream(w http.ResponseWriter, r *http.Request, contentType, filename string) *responseStream {
+	return &responseStream{
+		w:           w,
+		rc:          http.NewResponseController(w),
+		ctx:         r.Context(),
+		contentType: contentType,
+		filename:    filename,
+	}
+}
+
+func (s *responseStream) start() error {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", s.contentType)
+	s.w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, s.filename))
+	s.extendDeadline()
+	s.w.WriteHeader(http.StatusOK)
+	if s.begin != nil {
+		return s.begin()
+	}
+	return nil
+}
+
+func (s *responseStream) extendDeadline() {
+	// Recorders and some wrappers can't set deadlines, the server's write
+	// timeout applies then.
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+}
+
+// recorded counts a written record and sends the chunk once it is full.
+func (s *responseStream) recorded() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	s.pending++
+	if s.pending < streamFlushEvery {
+		return nil
+	}
+	return s.flush()
+}
+
+func (s *responseStream) flush() error {
+	s.pending = 0
+	if s.flushBuffer != nil {
+		if err := s.flushBuffer(); err != nil {
+			return err
+		}
+	}
+	s.extendDeadline()
+	if err := s.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// Close sends what is left, or an empty export when nothing was written.
+func (s *responseStream) Close() error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// Started tells whether the status line went out. Before that, a failed
+// export can still be answered with an error response.
+func (s *responseStream) Started() bool {
+	return s.started
+}
+
+// failStream answers a failed export: with an error response when nothing
+// was sent yet, otherwise by dropping the connection so the client sees a
+// truncated transfer rather than a short export that looks complete.
+func (app *application) failStream(w http.ResponseWriter, r *http.Request, s *responseStream, err error) {
+	if !s.Started() {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Errorw("stream aborted", "method", r.Method, "path", r.URL.Path, "error", err.Error())
+	panic(http.ErrAbortHandler)
+}
+
+// ndjsonStream writes one JSON document per line.
+type ndjsonStream struct {
+	*responseStream
+}
+
+func streamNDJSON(w http.ResponseWriter, r *http.Request, filename string) *ndjsonStream {
+	return &ndjsonStream{newResponseStream(w, r, "application/x-ndjson", filename)}
+}
+
+func (s *ndjsonStream) Write(v any) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if err := responseEncoder.Encode(s.w, v); err != nil {
+		return err
+	}
+	return s.recorded()
+}
+
+// csvStream writes CSV rows under a header row.
+type csvStream struct {
+	*responseStream
+	cw *csv.Writer
+}
+
+func streamCSV(w http.ResponseWriter, r *http.Request, filename string, header []string) *csvStream {
+	s := &csvStream{responseStream: newResponseStream(w, r, "text/csv", filename), cw: csv.NewWriter(w)}
+	s.begin = func() error {
+		return s.cw.Write(header)
+	}
+	s.flushBuffer = func() error {
+		s.cw.Flush()
+		return s.cw.Error()
+	}
+	return s
+}
+
+func (s *csvStream) Write(record []string) error {
+	if err := s.start(); err != nil {
+		return err
+	}
+	if err := s.cw.Write(record); err != nil {
+		return err
+	}
+	return s.recorded()
+}
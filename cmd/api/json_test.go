@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gopher_social/internal/store"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// feedPage is a full feed page with comment previews, the response that
+// dominates encoding time.
+func feedPage(n int) any {
+	feed := make([]store.PostWithMetadata, n)
+	for i := range feed {
+		feed[i] = store.PostWithMetadata{
+			Post: store.Post{
+				ID:        int64(i + 1),
+				Title:     fmt.Sprintf("Post %d <draft>", i),
+				Content:   "Lorem ipsum dolor sit amet, consectetur adipiscing elit & co. ",
+				UserID:    int64(i % 7),
+				Tags:      []string{"go", "postgres"},
+				CreatedAt: time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+				Lang:      "en",
+				Kind:      store.PostKindNote,
+				User:      store.User{ID: int64(i % 7), Username: "gopher"},
+			},
+			TopComment: &store.Comment{
+				ID:        int64(i),
+				Content:   "Nice ✨",
+				Reactions: map[string]int{"like": 3, "heart": 1},
+				User:      store.User{Username: "commenter"},
+			},
+			Cursor: store.EncodeFeedCursor(int64(i + 1)),
+		}
+	}
+	return struct {
+		Data any `json:"data"`
+	}{feed}
+}
+
+func TestJSONEncoders(t *testing.T) {
+	if _, err := newJSONEncoder("gob"); err == nil {
+		t.Fatal("expected an error for an unknown encoder")
+	}
+
+	page := feedPage(20)
+	var want bytes.Buffer
+	if err := (stdJSONEncoder{}).Encode(&want, page); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"std", "sonic"} {
+		t.Run("should match encoding/json with "+name, func(t *testing.T) {
+			enc, err := newJSONEncoder(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := enc.Encode(&got, page); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got.Bytes(), want.Bytes()) {
+				t.Fatalf("output differs from encoding/json:\n%s\n%s", got.String(), want.String())
+			}
+		})
+	}
+
+	t.Run("should write nothing when encoding fails", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		if err := writeJSON(rr, 200, map[string]any{"bad": make(chan int)}); err == nil {
+			t.Fatal("expected an encoding error")
+		}
+		if rr.Body.Len() != 0 || rr.Header().Get("Content-Type") != "" {
+			t.Fatalf("partial response written: %q", rr.Body.String())
+		}
+	})
+}
+
+// Compare with: go test ./cmd/api -run '^$' -bench JSON -benchmem
+func BenchmarkJSONEncoders(b *testing.B) {
+	page := feedPage(100)
+	for _, name := range []string{"std", "sonic"} {
+		enc, err := newJSONEncoder(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				if err := enc.Encode(io.Discard, page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteJSON(b *testing.B) {
+	page := feedPage(100)
+	b.ReportAllocs()
+	for range b.N {
+		if err := writeJSON(httptest.NewRecorder(), 200, page); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type PlaceLegalHoldPayload struct {
+	SubjectType string `json:"subject_type" validate:"required,oneof=user post"`
+	SubjectID   int64  `json:"subject_id" validate:"required"`
+	Reason      string `json:"reason" validate:"required,max=2000"`
+}
+
+// PlaceLegalHold godoc
+//
+//	@Summary		Place a legal hold
+//	@Description	Hides a user or post from everyone but admins and preserves it from deletion until the hold is released. A hold on a user covers all of their posts
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		PlaceLegalHoldPayload	true	"Hold"
+//	@Success		201		{object}	store.LegalHold
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"Subject not found"
+//	@Failure		409		{object}	error	"Already under hold"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/holds [post]
+func (app *application) placeLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	var payload PlaceLegalHoldPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := getUserFromContext(r)
+	hold := &store.LegalHold{
+		SubjectType: payload.SubjectType,
+		SubjectID:   payload.SubjectID,
+		Reason:      payload.Reason,
+		PlacedBy:    admin.ID,
+	}
+	if err := app.store.LegalHolds.Place(r.Context(), hold); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrHoldExists):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.logger.Infow("legal hold placed", "hold_id", hold.ID, "subject_type", hold.SubjectType, "subject_id", hold.SubjectID, "admin_id", admin.ID)
+	if err := app.jsonResponse(w, http.StatusCreated, hold); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListLegalHolds godoc
+//
+//	@Summary		List legal holds
+//	@Description	Active holds newest first, released ones too with all=true
+//	@Tags			admin
+//	@Produce		json
+//	@Param			all		query		bool	false	"Include released holds"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.LegalHold
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/holds [get]
+func (app *application) listLegalHoldsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var all bool
+	if v := r.URL.Query().Get("all"); v != "" {
+		if all, err = strconv.ParseBool(v); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	holds, err := app.store.LegalHolds.List(r.Context(), all, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, holds); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ReleaseLegalHold godoc
+//
+//	@Summary		Release a legal hold
+//	@Description	Ends an active hold, the subject becomes visible and deletable again
+//	@Tags			admin
+//	@Produce		json
+//	@Param			holdID	path		int	true	"Hold ID"
+//	@Success		200		{object}	store.LegalHold
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"No active hold"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/holds/{holdID} [delete]
+func (app *application) releaseLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	holdID, err := strconv.ParseInt(chi.URLParam(r, "holdID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := getUserFromContext(r)
+	hold, err := app.store.LegalHolds.Release(r.Context(), holdID, admin.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.logger.Infow("legal hold released", "hold_id", hold.ID, "subject_type", hold.SubjectType, "subject_id", hold.SubjectID, "admin_id", admin.ID)
+	if err := app.jsonResponse(w, http.StatusOK, hold); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ExportLegalHold godoc
+//
+//	@Summary		Export held content
+//	@Description	Downloads everything preserved by the hold as JSON: the post with its body and comments, or the user's account, posts, comments and media records. With format=ndjson the export is streamed as it is read, one {"type","data"} record per line, the hold first
+//	@Tags			admin
+//	@Produce		json
+//	@Produce		application/x-ndjson
+//	@Param			holdID	path		int		true	"Hold ID"
+//	@Param			format	query		string	false	"json (default) or ndjson"
+//	@Success		200		{object}	store.HoldExport
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/holds/{holdID}/export [get]
+func (app *application) exportLegalHoldHandler(w http.ResponseWriter, r *http.Request) {
+	holdID, err := strconv.ParseInt(chi.URLParam(r, "holdID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	hold, err := app.store.LegalHolds.GetByID(ctx, holdID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+	case "ndjson":
+		app.logger.Infow("legal hold exported", "hold_id", hold.ID, "admin_id", getUserFromContext(r).ID)
+		stream := streamNDJSON(w, r, fmt.Sprintf("legal-hold-%d.ndjson", hold.ID))
+		err := app.store.LegalHolds.StreamExport(ctx, hold, func(rec store.HoldRecord) error {
+			return stream.Write(rec)
+		})
+		if err == nil {
+			err = stream.Close()
+		}
+		if err != nil {
+			app.failStream(w, r, stream.responseStream, err)
+		}
+		return
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("unknown format %q, use json or ndjson", format))
+		return
+	}
+	export, err := app.store.LegalHolds.Export(ctx, hold)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("legal hold exported", "hold_id", hold.ID, "admin_id", getUserFromContext(r).ID)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="legal-hold-%d.json"`, hold.ID))
+	if err := app.jsonResponse(w, http.StatusOK, export); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
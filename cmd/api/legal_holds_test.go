@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type heldPostStore struct {
+	store.MockPostStore
+}
+
+func (m *heldPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: 42, OnHold: true}, nil
+}
+
+func TestLegalHolds(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	holds := &store.MockLegalHoldStore{}
+	app.store.LegalHolds = holds
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should keep holds to admins", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/holds", `{"subject_type":"user","subject_id":7,"reason":"subpoena"}`))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/admin/holds/1/export", ""))
+	})
+
+	t.Run("should hide held users from other users", func(t *testing.T) {
+		holds.Holds = []store.LegalHold{{ID: 1, SubjectType: store.HoldSubjectUser, SubjectID: 7}}
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodGet, "/v1/users/7/", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/8/", ""))
+	})
+
+	t.Run("should hide held posts and keep them from being changed", func(t *testing.T) {
+		app.store.Posts = &heldPostStore{}
+		defer func() { app.store.Posts = &store.MockPostStore{} }()
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodGet, "/v1/posts/1/", ""))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/posts/1/", ""))
+
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodDelete, "/v1/posts/1/", ""))
+	})
+
+	t.Run("should place, export and release holds", func(t *testing.T) {
+		holds.Holds = nil
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/holds", `{"subject_type":"comment","subject_id":7,"reason":"subpoena"}`))
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/holds", `{"subject_type":"user","subject_id":7,"reason":"subpoena"}`))
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPost, "/v1/admin/holds", `{"subject_type":"user","subject_id":7,"reason":"again"}`))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/7/", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/admin/holds/1/export", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/admin/holds/1/export?format=ndjson", ""))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/admin/holds/1/export?format=xml", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodDelete, "/v1/admin/holds/1", ""))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/admin/holds/1", ""))
+	})
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/lang"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// listingsConfig bounds how long listings run: ttl unless the author asks
+// for another duration, at most maxTTL.
+type listingsConfig struct {
+	ttl    time.Duration
+	maxTTL time.Duration
+}
+
+type CreateListingPayload struct {
+	Title        string   `json:"title" validate:"required,max=100"`
+	Description  string   `json:"description" validate:"required,max=1000"`
+	Tags         []string `json:"tags"`
+	Category     string   `json:"category" validate:"required,oneof=job freelance project other"`
+	Location     string   `json:"location" validate:"max=200"`
+	Remote       bool     `json:"remote"`
+	Compensation string   `json:"compensation" validate:"max=200"`
+	ApplyURL     string   `json:"apply_url" validate:"omitempty,url,max=500"`
+	// ExpiresInDays defaults to the instance's listing lifetime.
+	ExpiresInDays int `json:"expires_in_days" validate:"omitempty,gte=1"`
+}
+
+type ReviewListingPayload struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+}
+
+func parseListingID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "listingID"), 10, 64)
+}
+
+// CreateListing godoc
+//
+//	@Summary		Create a listing
+//	@Description	Posts a job or classified ad. It waits for a moderator before it reaches the listing feed and stays out of the home and explore feeds
+//	@Tags			listings
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateListingPayload	true	"Listing"
+//	@Success		201		{object}	store.Listing
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error	"Risky account posting too often"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/listings [post]
+func (app *application) createListingHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateListingPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	cfg := app.config.listings
+	ttl := cfg.ttl
+	if payload.ExpiresInDays > 0 {
+		ttl = time.Duration(payload.ExpiresInDays) * 24 * time.Hour
+	}
+	if ttl > cfg.maxTTL {
+		app.badRequestResponse(w, r, fmt.Errorf("listings run for at most %d days", int(cfg.maxTTL.Hours()/24)))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	retryAfter, err := app.postThrottle(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	post := &store.Post{
+		Title:   payload.Title,
+		Content: payload.Description,
+		Tags:    payload.Tags,
+		UserID:  user.ID,
+		Lang:    lang.Detect(payload.Title + "\n" + payload.Description),
+	}
+	listing := &store.Listing{
+		Category:     payload.Category,
+		Location:     payload.Location,
+		Remote:       payload.Remote,
+		Compensation: payload.Compensation,
+		ApplyURL:     payload.ApplyURL,
+		ExpiresAt:    time.Now().Add(ttl).Truncate(time.Second),
+	}
+	if err := app.store.Listings.Create(ctx, post, listing); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, listing); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListListings godoc
+//
+//	@Summary		Listing feed
+//	@Description	Approved listings that haven't expired, newest first
+//	@Tags			listings
+//	@Produce		json
+//	@Param			category	query		string	false	"job, freelance, project or other"
+//	@Param			limit		query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	[]store.Listing
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/listings [get]
+func (app *application) listListingsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	category := r.URL.Query().Get("category")
+	if category != "" && !store.ValidListingCategory(category) {
+		app.badRequestResponse(w, r, fmt.Errorf("unknown category %q", category))
+		return
+	}
+	listings, err := app.store.Listings.Feed(r.Context(), category, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, listings); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetListing godoc
+//
+//	@Summary		Fetch a listing
+//	@Description	Listings not approved yet, rejected or expired are only shown to their author and moderators
+//	@Tags			listings
+//	@Produce		json
+//	@Param			listingID	path		int	true	"Listing ID, the ID of its post"
+//	@Success		200			{object}	store.Listing
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/listings/{listingID} [get]
+func (app *application) getListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListingID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	listing, err := app.store.Listings.Get(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	visible, err := app.canViewListing(ctx, getUserFromContext(r), listing)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !visible {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, listing); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// canViewListing reports whether viewer may see the listing: anyone while
+// it's approved and current, only its author and moderators otherwise.
+func (app *application) canViewListing(ctx context.Context, viewer *store.User, listing *store.Listing) (bool, error) {
+	if viewer.ID == listing.UserID || (listing.Status == store.ListingApproved && !listing.ExpiresAt.Before(time.Now())) {
+		return true, nil
+	}
+	return app.checkRolePrecedence(ctx, viewer, "moderator")
+}
+
+// ListPendingListings godoc
+//
+//	@Summary		List listings awaiting review
+//	@Description	Pending listings that haven't expired, oldest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Listing
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/listings [get]
+func (app *application) listPendingListingsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	listings, err := app.store.Listings.ListPending(r.Context(), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, listings); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ReviewListing godoc
+//
+//	@Summary		Review a listing
+//	@Description	Approves a listing into the listing feed or rejects it, approved listings can be rejected later to take them down. The author is notified
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			listingID	path		int						true	"Listing ID"
+//	@Param			payload		body		ReviewListingPayload	true	"Decision"
+//	@Success		200			{object}	store.Listing
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/listings/{listingID} [put]
+func (app *application) reviewListingHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseListingID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload ReviewListingPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	status := store.ListingApproved
+	if payload.Decision == "reject" {
+		status = store.ListingRejected
+	}
+
+	ctx := r.Context()
+	moderator := getUserFromContext(r)
+	listing, err := app.store.Listings.Review(ctx, id, moderator.ID, status)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("listing.review", moderator.ID, "listing_id", id, "status", status)
+	n := []store.Notification{{UserID: listing.UserID, ActorID: listing.UserID, Type: store.NotificationListingReviewed, PostID: id}}
+	if err := app.createNotifications(ctx, n); err != nil {
+		app.logger.Errorw("error notifying listing review", "listing_id", id, "error", err.Error())
+	}
+	if err := app.jsonResponse(w, http.StatusOK, listing); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestListings(t *testing.T) {
+	app := NewTestApplication(t, config{listings: listingsConfig{ttl: 30 * 24 * time.Hour, maxTTL: 90 * 24 * time.Hour}})
+	listings := &store.MockListingStore{}
+	app.store.Listings = listings
+	notifications := &store.MockNotificationStore{}
+	app.store.Notifications = notifications
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp}))
+		return executeRequest(req, app.mount())
+	}
+	feed := func(t *testing.T, query string) []store.Listing {
+		t.Helper()
+		rr := request(t, 43, http.MethodGet, "/v1/listings/"+query, "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return decodeData[[]store.Listing](t, rr.Body.String())
+	}
+
+	t.Run("should hold new listings for review", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/listings/", `{"title":"Go dev","description":"Remote","category":"gig"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/listings/", `{"title":"Go dev","description":"Remote","category":"job","expires_in_days":365}`).Code)
+		rr := request(t, 42, http.MethodPost, "/v1/listings/", `{"title":"Go dev","description":"Backend work","category":"job","remote":true,"apply_url":"https://jobs.example/go"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := decodeData[store.Listing](t, rr.Body.String()); got.Status != store.ListingPending {
+			t.Errorf("expected a pending listing, got %+v", got)
+		}
+
+		if got := feed(t, ""); len(got) != 0 {
+			t.Errorf("expected no listing in the feed before review, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodGet, "/v1/listings/1", "").Code)
+		checkResponseCode(t, http.StatusOK, request(t, 42, http.MethodGet, "/v1/listings/1", "").Code)
+
+		app.store.Posts = &kindPostStore{kind: store.PostKindListing}
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodGet, "/v1/posts/1", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodPost, "/v1/posts/1/comments", `{"content":"hi"}`).Code)
+		checkResponseCode(t, http.StatusOK, request(t, 42, http.MethodGet, "/v1/posts/1", "").Code)
+	})
+
+	t.Run("should list approved listings by category", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodGet, "/v1/moderation/listings", "").Code)
+
+		app.store.Users = &adminUserStore{}
+		rr := request(t, 7, http.MethodGet, "/v1/moderation/listings", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.Listing](t, rr.Body.String()); len(got) != 1 {
+			t.Fatalf("expected 1 pending listing, got %d", len(got))
+		}
+		checkResponseCode(t, http.StatusOK, request(t, 7, http.MethodPut, "/v1/moderation/listings/1", `{"decision":"approve"}`).Code)
+		app.store.Users = &store.MockUserStore{}
+
+		if len(notifications.Notifications) != 1 || notifications.Notifications[0].UserID != 42 {
+			t.Errorf("expected the author to be notified, got %+v", notifications.Notifications)
+		}
+		if got := feed(t, "?category=job"); len(got) != 1 || got[0].Title != "Go dev" {
+			t.Errorf("expected the approved job, got %+v", got)
+		}
+		if got := feed(t, "?category=project"); len(got) != 0 {
+			t.Errorf("expected no projects, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusBadRequest, request(t, 43, http.MethodGet, "/v1/listings/?category=gig", "").Code)
+		checkResponseCode(t, http.StatusOK, request(t, 43, http.MethodGet, "/v1/listings/1", "").Code)
+	})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"gopher_social/internal/i18n"
+	"net/http"
+)
+
+var errUnsupportedLocale = errors.New("unsupported locale")
+
+// requestLocale is the locale to answer the request in: the signed in user's,
+// else the closest match for Accept-Language.
+func requestLocale(r *http.Request) string {
+	if user := getUserFromContext(r); user != nil && user.Locale != "" {
+		return user.Locale
+	}
+	return i18n.Match(r.Header.Get("Accept-Language"))
+}
+
+// writeLocalizedError writes the catalog message key in the request's
+// locale.
+func writeLocalizedError(w http.ResponseWriter, r *http.Request, status int, key string, args ...any) error {
+	locale := requestLocale(r)
+	w.Header().Set("Content-Language", locale)
+	return writeJSONError(w, status, i18n.T(locale, key, args...))
+}
+
+type LocalePayload struct {
+	Locale   string `json:"locale" validate:"required,max=10"`
+	Timezone string `json:"timezone" validate:"required,timezone"`
+}
+
+// SetLocale godoc
+//
+//	@Summary		Set locale and timezone
+//	@Description	Stores the language of the API's messages and emails, and the IANA timezone of the user
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		LocalePayload	true	"Locale and timezone"
+//	@Success		204		{string}	string			"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/locale [put]
+func (app *application) setLocaleHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload LocalePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !i18n.Supported(payload.Locale) {
+		app.badRequestResponse(w, r, errUnsupportedLocale)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Users.SetLocale(ctx, user.ID, payload.Locale, payload.Timezone); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
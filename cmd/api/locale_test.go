@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/mailer"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spanishUserStore has every user reading Spanish.
+type spanishUserStore struct {
+	store.MockUserStore
+}
+
+func (m *spanishUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return &store.User{ID: id, Role: &store.Role{Name: "user", Level: 1}, Locale: "es"}, nil
+}
+
+func TestLocale(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string, header http.Header) (int, string, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := executeRequest(req, app.mount())
+		return rr.Code, rr.Header().Get("Content-Language"), rr.Body.String()
+	}
+
+	t.Run("should answer anonymous requests in the Accept-Language", func(t *testing.T) {
+		body := `{"token":"unknown","password":"new password"}`
+		code, lang, resp := request(t, http.MethodPost, "/v1/authentication/recovery/redeem", body, http.Header{"Accept-Language": {"es-ES,es;q=0.9,en;q=0.5"}})
+		checkResponseCode(t, http.StatusNotFound, code)
+		if lang != "es" || !strings.Contains(resp, "No se encontró") {
+			t.Errorf("expected a Spanish error, got %s %s", lang, resp)
+		}
+		code, lang, _ = request(t, http.MethodPost, "/v1/authentication/recovery/redeem", body, http.Header{"Accept-Language": {"ja"}})
+		checkResponseCode(t, http.StatusNotFound, code)
+		if lang != "en" {
+			t.Errorf("expected unsupported languages to fall back to en, got %s", lang)
+		}
+	})
+
+	t.Run("should answer users in their own locale", func(t *testing.T) {
+		app.store.Users = &spanishUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+
+		code, lang, resp := request(t, http.MethodGet, "/v1/admin/holds", "", http.Header{"Authorization": {"Bearer " + testToken}, "Accept-Language": {"en"}})
+		checkResponseCode(t, http.StatusForbidden, code)
+		if lang != "es" || !strings.Contains(resp, "prohibido") {
+			t.Errorf("expected a Spanish error, got %s %s", lang, resp)
+		}
+	})
+
+	t.Run("should only store supported locales and real timezones", func(t *testing.T) {
+		auth := http.Header{"Authorization": {"Bearer " + testToken}}
+		for body, want := range map[string]int{
+			`{"locale":"xx","timezone":"Europe/Madrid"}`: http.StatusBadRequest,
+			`{"locale":"es","timezone":"Mars/Olympus"}`:  http.StatusBadRequest,
+			`{"locale":"es","timezone":"Europe/Madrid"}`: http.StatusNoContent,
+		} {
+			if code, _, _ := request(t, http.MethodPut, "/v1/users/me/locale", body, auth); code != want {
+				t.Errorf("%s: expected %d, got %d", body, want, code)
+			}
+		}
+	})
+
+	t.Run("should pick translated emails when there are", func(t *testing.T) {
+		if got := mailer.Localized(mailer.RecoveryLinkTemplate, "es"); got != "es/"+mailer.RecoveryLinkTemplate {
+			t.Errorf("expected the Spanish template, got %s", got)
+		}
+		if got := mailer.Localized(mailer.RecoveryLinkTemplate, "fr"); got != mailer.RecoveryLinkTemplate {
+			t.Errorf("expected the default template, got %s", got)
+		}
+		if got := inWords("es", 48*time.Hour); got != "48 horas" {
+			t.Errorf("expected 48 horas, got %s", got)
+		}
+		if got := inWords("en", time.Minute); got != "1 minute" {
+			t.Errorf("expected 1 minute, got %s", got)
+		}
+	})
+}
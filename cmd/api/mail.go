@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/mailer"
+	"gopher_social/internal/store"
+	"time"
+)
+
+const (
+	// maxMailAttempts is how often the outbox retries a mail before giving up.
+	maxMailAttempts = 10
+	mailOutboxBatch = 50
+	// mailClaimLease is how long a replica owns the mails it claimed. It
+	// outlasts a batch of sends so a slow provider doesn't cause duplicates.
+	mailClaimLease = 10 * time.Minute
+	// mailRetention is how long sent or abandoned mail is kept around.
+	mailRetention = time.Hour * 24 * 7
+)
+
+// sendMail sends the locale's version of template through the mailer
+// breaker. When the provider is failing, or the breaker is already open, the
+// mail is queued in the outbox instead and queued is true. An error means
+// the mail was neither sent nor queued.
+func (app *application) sendMail(ctx context.Context, template, locale, username, email string, data any) (queued bool, err error) {
+	template = mailer.Localized(template, locale)
+	sandbox := app.config.env != "production"
+	err = app.mailBreaker.Do(func() error {
+		_, err := app.mailer.Send(template, username, email, data, sandbox)
+		return err
+	})
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, breaker.ErrOpen) {
+		app.logger.Warnw("error sending email, queueing it", "template", template, "error", err.Error())
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+	mail := &store.QueuedMail{
+		Template: template,
+		Username: username,
+		Email:    email,
+		Data:     raw,
+		Sandbox:  sandbox,
+	}
+	if err := app.store.MailOutbox.Enqueue(ctx, mail); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// drainMailOutbox is the job retrying queued mail. It stops early while the
+// mailer breaker is open.
+func (app *application) drainMailOutbox(ctx context.Context) error {
+	mails, err := app.store.MailOutbox.Claim(ctx, maxMailAttempts, mailOutboxBatch, mailClaimLease)
+	if err != nil {
+		return err
+	}
+	for _, mail := range mails {
+		// Templates only read fields, a map stands in for the original struct.
+		var data map[string]any
+		if err := json.Unmarshal(mail.Data, &data); err != nil {
+			return err
+		}
+		err := app.mailBreaker.Do(func() error {
+			_, err := app.mailer.Send(mail.Template, mail.Username, mail.Email, data, mail.Sandbox)
+			return err
+		})
+		if errors.Is(err, breaker.ErrOpen) {
+			return nil
+		}
+		if err != nil {
+			if err := app.store.MailOutbox.MarkFailed(ctx, mail.ID, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := app.store.MailOutbox.MarkSent(ctx, mail.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneMailOutbox is the job deleting old sent and abandoned mail.
+func (app *application) pruneMailOutbox(ctx context.Context) error {
+	return app.store.MailOutbox.Purge(ctx, time.Now().Add(-mailRetention), maxMailAttempts)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"testing"
+)
+
+type failingMailer struct {
+	calls int
+}
+
+func (m *failingMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	m.calls++
+	return -1, errors.New("smtp: connection refused")
+}
+
+func TestSendMailQueuesWhenProviderIsDown(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mailer := &failingMailer{}
+	app.mailer = mailer
+	outbox := &store.MockMailOutboxStore{}
+	app.store.MailOutbox = outbox
+
+	ctx := context.Background()
+	vars := struct{ Username string }{Username: "gopher"}
+	for i := 0; i < 5; i++ {
+		queued, err := app.sendMail(ctx, "user_invitation.tmpl", "en", "gopher", "gopher@example.com", vars)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !queued {
+			t.Fatal("expected the mail to be queued")
+		}
+	}
+
+	if len(outbox.Queued) != 5 {
+		t.Errorf("expected 5 queued mails, got %d", len(outbox.Queued))
+	}
+	if string(outbox.Queued[0].Data) != `{"Username":"gopher"}` {
+		t.Errorf("unexpected queued data %s", outbox.Queued[0].Data)
+	}
+	if mailer.calls != 3 {
+		t.Errorf("expected the breaker to stop calling the mailer after 3 failures, got %d calls", mailer.calls)
+	}
+}
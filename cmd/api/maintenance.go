@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maintenanceConfig sets how often every instance reloads the maintenance
+// windows, the instance serving an admin's change applies it right away.
+// retryAfter is what clients are told for windows without an end.
+type maintenanceConfig struct {
+	refreshInterval time.Duration
+	retryAfter      time.Duration
+}
+
+// maintenanceState holds the windows in effect, loaded by
+// refreshMaintenance. The zero value has none.
+type maintenanceState struct {
+	windows atomic.Pointer[[]store.MaintenanceWindow]
+}
+
+// maintenanceExempt stay available during maintenance so operators can
+// check health, sign in and lift it.
+var maintenanceExempt = []string{"/v1/health", "/v1/authentication/token", "/v1/auth", "/v1/admin/maintenance"}
+
+type CreateMaintenancePayload struct {
+	// Paths limit the window to routes under these prefixes, e.g.
+	// /v1/posts, empty for the whole API.
+	Paths []string `json:"paths" validate:"max=20,dive,startswith=/,max=200"`
+	// WritesOnly keeps GET requests working.
+	WritesOnly bool   `json:"writes_only"`
+	Message    string `json:"message" validate:"max=500"`
+	// EndsAt lifts the window by itself and tells clients when to retry.
+	EndsAt *time.Time `json:"ends_at"`
+}
+
+// refreshMaintenance reloads the windows in effect. It runs as a job and
+// after admins change them.
+func (app *application) refreshMaintenance(ctx context.Context) error {
+	windows, err := app.store.Maintenance.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	app.maintenance.windows.Store(&windows)
+	return nil
+}
+
+// pathUnder reports whether path is prefix or below it.
+func pathUnder(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// maintenanceFor returns the window covering the request, nil if none.
+func (app *application) maintenanceFor(r *http.Request) *store.MaintenanceWindow {
+	windows := app.maintenance.windows.Load()
+	if windows == nil || len(*windows) == 0 {
+		return nil
+	}
+	for _, exempt := range maintenanceExempt {
+		if pathUnder(r.URL.Path, exempt) {
+			return nil
+		}
+	}
+	read := r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+	now := time.Now()
+	for i, m := range *windows {
+		if m.EndsAt != nil && !m.EndsAt.After(now) || m.WritesOnly && read {
+			continue
+		}
+		if len(m.Paths) == 0 {
+			return &(*windows)[i]
+		}
+		for _, p := range m.Paths {
+			if pathUnder(r.URL.Path, p) {
+				return &(*windows)[i]
+			}
+		}
+	}
+	return nil
+}
+
+// MaintenanceMiddleware answers requests covered by a maintenance window
+// with 503 before they reach the handlers.
+func (app *application) MaintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m := app.maintenanceFor(r); m != nil {
+			app.maintenanceResponse(w, r, m)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CreateMaintenance godoc
+//
+//	@Summary		Start maintenance
+//	@Description	Answers requests with 503, Retry-After and the message until the window is ended or ends_at passes. It covers the whole API, or the routes under paths, optionally writes only. Health checks, signing in and these endpoints stay available. Every instance picks it up within the refresh interval
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateMaintenancePayload	true	"Window"
+//	@Success		201		{object}	store.MaintenanceWindow
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/maintenance [post]
+func (app *application) createMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateMaintenancePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.EndsAt != nil && !payload.EndsAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("ends_at must be in the future"))
+		return
+	}
+	if payload.Paths == nil {
+		payload.Paths = []string{}
+	}
+
+	ctx := r.Context()
+	admin := getUserFromContext(r)
+	m := &store.MaintenanceWindow{
+		Paths:      payload.Paths,
+		WritesOnly: payload.WritesOnly,
+		Message:    payload.Message,
+		EndsAt:     payload.EndsAt,
+		CreatedBy:  admin.ID,
+	}
+	if err := app.store.Maintenance.Create(ctx, m); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.auditLog("maintenance.start", admin.ID, "window_id", m.ID, "paths", m.Paths, "writes_only", m.WritesOnly)
+	if err := app.refreshMaintenance(ctx); err != nil {
+		app.logger.Warnw("error refreshing maintenance windows", "error", err.Error())
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, m); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListMaintenance godoc
+//
+//	@Summary		List maintenance windows
+//	@Description	Every window including ended ones, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.MaintenanceWindow
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/maintenance [get]
+func (app *application) listMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	windows, err := app.store.Maintenance.List(r.Context(), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, windows); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// EndMaintenance godoc
+//
+//	@Summary		End maintenance
+//	@Description	Lifts the window now
+//	@Tags			admin
+//	@Param			windowID	path	int	true	"Window ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"Not in effect"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/maintenance/{windowID} [delete]
+func (app *application) endMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "windowID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Maintenance.End(ctx, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("maintenance.end", getUserFromContext(r).ID, "window_id", id)
+	if err := app.refreshMaintenance(ctx); err != nil {
+		app.logger.Warnw("error refreshing maintenance windows", "error", err.Error())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
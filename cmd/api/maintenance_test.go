@@ -0,0 +1,85 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaintenance(t *testing.T) {
+	app := NewTestApplication(t, config{maintenance: maintenanceConfig{retryAfter: time.Minute}})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should keep maintenance to admins", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/maintenance", `{}`).Code)
+	})
+
+	t.Run("should turn away writes under the paths", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/maintenance", `{"paths":["posts"]}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/admin/maintenance", `{"ends_at":"2001-01-01T00:00:00Z"}`).Code)
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/maintenance",
+			`{"paths":["/v1/posts"],"writes_only":true,"message":"posting is paused"}`).Code)
+
+		rr := request(t, http.MethodPost, "/v1/posts", `{"title":"t","content":"c"}`)
+		checkResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+		if got := rr.Header().Get("Retry-After"); got != "60" {
+			t.Errorf("expected the default Retry-After, got %q", got)
+		}
+		if !strings.Contains(rr.Body.String(), `"code":"maintenance"`) || !strings.Contains(rr.Body.String(), "posting is paused") {
+			t.Errorf("expected the maintenance envelope, got %s", rr.Body.String())
+		}
+		if rr := request(t, http.MethodGet, "/v1/posts/1", ""); rr.Code == http.StatusServiceUnavailable {
+			t.Error("expected reads to pass a writes only window")
+		}
+		if rr := request(t, http.MethodPost, "/v1/postsx", ""); rr.Code == http.StatusServiceUnavailable {
+			t.Error("expected paths to match whole segments")
+		}
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/admin/maintenance/1", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/admin/maintenance/1", "").Code)
+		if rr := request(t, http.MethodPost, "/v1/posts", `{"title":"t","content":"c"}`); rr.Code == http.StatusServiceUnavailable {
+			t.Error("expected the ended window to be lifted")
+		}
+	})
+
+	t.Run("should take the whole API down but leave the exemptions", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		defer func() { app.store.Users = &store.MockUserStore{} }()
+		endsAt := time.Now().Add(10 * time.Minute).UTC().Format(time.RFC3339)
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/maintenance", `{"ends_at":"`+endsAt+`"}`).Code)
+
+		rr := request(t, http.MethodGet, "/v1/users/feed", "")
+		checkResponseCode(t, http.StatusServiceUnavailable, rr.Code)
+		if got, _ := strconv.Atoi(rr.Header().Get("Retry-After")); got < 590 || got > 600 {
+			t.Errorf("expected Retry-After to count down to ends_at, got %d", got)
+		}
+		if !strings.Contains(rr.Body.String(), "maintenance") {
+			t.Errorf("expected the default message, got %s", rr.Body.String())
+		}
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/health", "").Code)
+		rr = request(t, http.MethodGet, "/v1/admin/maintenance", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.MaintenanceWindow](t, rr.Body.String()); len(got) != 2 {
+			t.Errorf("expected 2 windows, got %d", len(got))
+		}
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/admin/maintenance/2", "").Code)
+	})
+}
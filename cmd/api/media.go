@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/store"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+var allowedMediaTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var mediaVisibilities = []string{
+	store.MediaVisibilityPublic,
+	store.MediaVisibilityFollowers,
+	store.MediaVisibilityPrivate,
+}
+
+// uploadMediaHandler godoc
+//
+//	@Summary		Upload media
+//	@Description	Uploads an image, the response contains a signed link to it. Media flagged by the scanners is quarantined until a moderator reviews it and has no link
+//	@Tags			media
+//	@Accept			mpfd
+//	@Produce		json
+//	@Param			file		formData	file	true	"Image file"
+//	@Param			visibility	formData	string	false	"public, followers or private"
+//	@Success		201			{object}	store.Media
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media [post]
+func (app *application) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	maxBytes := app.contentLimits().MaxUploadBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	defer file.Close()
+
+	visibility := r.FormValue("visibility")
+	if visibility == "" {
+		visibility = store.MediaVisibilityPublic
+	}
+	if !slices.Contains(mediaVisibilities, visibility) {
+		app.badRequestResponse(w, r, fmt.Errorf("invalid visibility %q", visibility))
+		return
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	media, err := app.saveMedia(r.Context(), user.ID, data, visibility)
+	if err != nil {
+		if errors.Is(err, errUnsupportedMedia) {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+var errUnsupportedMedia = errors.New("unsupported media type")
+
+// saveMedia scans and stores an upload of the user. Media the scanners
+// flag is kept quarantined without a link, otherwise it is signed.
+// errUnsupportedMedia means the data isn't one of allowedMediaTypes.
+func (app *application) saveMedia(ctx context.Context, userID int64, data []byte, visibility string) (*store.Media, error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedMediaTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", errUnsupportedMedia, contentType)
+	}
+
+	media := &store.Media{
+		UserID:      userID,
+		BlobKey:     fmt.Sprintf("%d/%s%s", userID, uuid.New().String(), ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Visibility:  visibility,
+		ScanStatus:  store.MediaScanClean,
+	}
+	if app.mediaScanner != nil {
+		results, quarantine := app.mediaScanner.Run(ctx, contentType, data)
+		raw, err := json.Marshal(results)
+		if err != nil {
+			return nil, err
+		}
+		media.ScanResults = raw
+		if quarantine {
+			media.ScanStatus = store.MediaScanQuarantined
+		}
+	}
+	if err := app.blobStore.Put(ctx, media.BlobKey, bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := app.store.Media.Create(ctx, media); err != nil {
+		if err := app.blobStore.Delete(ctx, media.BlobKey); err != nil {
+			app.logger.Errorw("error deleting orphaned blob", "key", media.BlobKey, "error", err.Error())
+		}
+		return nil, err
+	}
+	if media.Servable() {
+		app.signMedia(media)
+	} else {
+		app.logger.Infow("media quarantined", "media_id", media.ID, "user_id", userID)
+	}
+	media.ScanResults = nil
+	return media, nil
+}
+
+// getMediaHandler godoc
+//
+//	@Summary		Fetch media
+//	@Description	Returns the media record with a fresh signed link if the caller may view it
+//	@Tags			media
+//	@Produce		json
+//	@Param			mediaID	path		int	true	"Media ID"
+//	@Success		200		{object}	store.Media
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID} [get]
+func (app *application) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	media, err := app.store.Media.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	allowed, err := app.canViewMedia(ctx, getUserFromContext(r), media)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if !allowed {
+		// don't reveal that private media exists
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	if media.Servable() {
+		app.signMedia(media)
+	}
+	media.ScanResults = nil
+	if err := app.jsonResponse(w, http.StatusOK, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// getOwnMediaFileHandler godoc
+//
+//	@Summary		Fetch own media file
+//	@Description	Streams a file to its owner whatever its scan status, so quarantined uploads stay visible to them. Signed links are only handed out once media is servable
+//	@Tags			media
+//	@Produce		octet-stream
+//	@Param			mediaID	path		int	true	"Media ID"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/media/{mediaID}/file [get]
+func (app *application) getOwnMediaFileHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	media, err := app.store.Media.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if media.UserID != getUserFromContext(r).ID {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	app.streamMedia(w, r, media)
+}
+
+// serveMediaHandler godoc
+//
+//	@Summary		Serve media
+//	@Description	Streams a blob for a valid signed link
+//	@Tags			media
+//	@Produce		octet-stream
+//	@Param			key		path		string	true	"Blob key"
+//	@Param			expires	query		int		true	"Expiry (unix seconds)"
+//	@Param			sig		query		string	true	"Signature"
+//	@Success		200		{file}		file
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Router			/media/files/{key} [get]
+func (app *application) serveMediaHandler(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "*")
+	qs := r.URL.Query()
+	if err := app.mediaSigner.Verify(key, qs.Get("expires"), qs.Get("sig")); err != nil {
+		app.logger.Warnw("media signature rejected", "key", key, "error", err.Error())
+		app.forbiddenResponse(w, r)
+		return
+	}
+	ctx := r.Context()
+	media, err := app.store.Media.GetByBlobKey(ctx, key)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	// links signed before a quarantine or rejection stop working
+	if !media.Servable() {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	app.streamMedia(w, r, media)
+}
+
+func (app *application) streamMedia(w http.ResponseWriter, r *http.Request, media *store.Media) {
+	key := media.BlobKey
+	rc, err := app.blobStore.Get(r.Context(), key)
+	if err != nil {
+		switch {
+		case errors.Is(err, blob.ErrNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", media.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(media.Size, 10))
+	w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(app.config.media.urlExp.Seconds())))
+	if _, err := io.Copy(w, rc); err != nil {
+		app.logger.Errorw("error streaming media", "key", key, "error", err.Error())
+	}
+}
+
+func (app *application) canViewMedia(ctx context.Context, viewer *store.User, media *store.Media) (bool, error) {
+	if media.UserID == viewer.ID {
+		return true, nil
+	}
+	if !media.Servable() {
+		return false, nil
+	}
+	if held, err := app.store.LegalHolds.IsHeld(ctx, store.HoldSubjectUser, media.UserID); err != nil || held {
+		return false, err
+	}
+	if media.Visibility == store.MediaVisibilityPublic {
+		return true, nil
+	}
+	if media.Visibility == store.MediaVisibilityFollowers {
+		return app.store.Followers.ExistsFollow(ctx, viewer.ID, media.UserID)
+	}
+	return false, nil
+}
+
+func (app *application) signMedia(media *store.Media) {
+	url, expiresAt := app.mediaSigner.SignURL(media.BlobKey)
+	media.URL = url
+	media.URLExpires = expiresAt.Format(time.RFC3339)
+}
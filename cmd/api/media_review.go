@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ListMediaForReview godoc
+//
+//	@Summary		Media review queue
+//	@Description	Media in the given scan status with the scanners' findings, oldest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			status	query		string	false	"quarantined (default), approved or rejected"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.Media
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/media [get]
+func (app *application) listMediaForReviewHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = store.MediaScanQuarantined
+	}
+	if err := Validate.Var(status, "oneof=quarantined approved rejected"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	media, err := app.store.Media.ListByScanStatus(r.Context(), status, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, media); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetMediaForReview godoc
+//
+//	@Summary		Stream media for review
+//	@Description	Streams any media regardless of its scan status, for moderators
+//	@Tags			moderation
+//	@Produce		octet-stream
+//	@Param			mediaID	path		int	true	"Media ID"
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/media/{mediaID}/file [get]
+func (app *application) getMediaForReviewHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	media, err := app.store.Media.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	app.streamMedia(w, r, media)
+}
+
+type ReviewMediaPayload struct {
+	Decision string `json:"decision" validate:"required,oneof=approve reject"`
+}
+
+// ReviewMedia godoc
+//
+//	@Summary		Review quarantined media
+//	@Description	Approves quarantined media so it is served, or rejects it and deletes the file unless its owner is under legal hold
+//	@Tags			moderation
+//	@Accept			json
+//	@Param			mediaID	path	int					true	"Media ID"
+//	@Param			payload	body	ReviewMediaPayload	true	"Decision"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"Not in quarantine"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/media/{mediaID} [put]
+func (app *application) reviewMediaHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "mediaID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload ReviewMediaPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	media, err := app.store.Media.GetByID(ctx, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	status := store.MediaScanApproved
+	if payload.Decision == "reject" {
+		status = store.MediaScanRejected
+	}
+	// Files of users under legal hold are kept even when rejected.
+	held, err := app.store.LegalHolds.IsHeld(ctx, store.HoldSubjectUser, media.UserID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Media.Review(ctx, id, status, getUserFromContext(r).ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if status == store.MediaScanRejected && !held {
+		if err := app.blobStore.Delete(ctx, media.BlobKey); err != nil {
+			app.logger.Errorw("error deleting rejected media", "key", media.BlobKey, "error", err.Error())
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"gopher_social/internal/blob"
+	"gopher_social/internal/scan"
+	"gopher_social/internal/store"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMediaScanning(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.config.media.maxUploadBytes = 1 << 20
+	blobStore, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.blobStore = blobStore
+	app.mediaSigner = blob.NewSigner("secret", "http://localhost/v1/media/files", time.Minute)
+
+	bad := []byte("\x89PNG\r\n\x1a\nknown bad image")
+	sum := sha256.Sum256(bad)
+	app.mediaScanner = scan.NewPipeline(false, scan.NewHashList([]string{hex.EncodeToString(sum[:])}))
+	media := &store.MockMediaStore{}
+	app.store.Media = media
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload := func(t *testing.T, data []byte) {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, err := mw.CreateFormFile("file", "image.png")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		mw.Close()
+		req, err := http.NewRequest(http.MethodPost, "/v1/media/", &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		checkResponseCode(t, http.StatusCreated, executeRequest(req, app.mount()).Code)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should quarantine uploads on the hash list", func(t *testing.T) {
+		upload(t, []byte("\x89PNG\r\n\x1a\nfine image"))
+		upload(t, bad)
+		if media.Media[0].ScanStatus != store.MediaScanClean {
+			t.Errorf("expected the first upload to be clean, got %q", media.Media[0].ScanStatus)
+		}
+		if media.Media[1].ScanStatus != store.MediaScanQuarantined {
+			t.Errorf("expected the second upload to be quarantined, got %q", media.Media[1].ScanStatus)
+		}
+	})
+
+	t.Run("should let only the owner fetch quarantined media", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/media/2/file", ""))
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/media/2/file", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": 43, "exp": time.Now().Add(time.Minute).Unix()}))
+		checkResponseCode(t, http.StatusNotFound, executeRequest(req, app.mount()).Code)
+	})
+
+	t.Run("should keep the review queue to moderators", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/moderation/media", ""))
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPut, "/v1/moderation/media/2", `{"decision":"approve"}`))
+	})
+
+	t.Run("should review quarantined media", func(t *testing.T) {
+		app.store.Users = &adminUserStore{}
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/moderation/media", ""))
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/moderation/media/2", `{"decision":"maybe"}`))
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPut, "/v1/moderation/media/1", `{"decision":"reject"}`))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPut, "/v1/moderation/media/2", `{"decision":"reject"}`))
+		if media.Media[1].ScanStatus != store.MediaScanRejected {
+			t.Errorf("expected the upload to be rejected, got %q", media.Media[1].ScanStatus)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodGet, "/v1/moderation/media/2/file", ""))
+	})
+}
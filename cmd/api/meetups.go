@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/events"
+	"gopher_social/internal/lang"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// eventsConfig paces event reminders: every interval the events starting
+// within reminderLead remind the people who answered them.
+type eventsConfig struct {
+	interval     time.Duration
+	reminderLead time.Duration
+	batchSize    int
+}
+
+type CreateEventPayload struct {
+	Title       string     `json:"title" validate:"required,max=100"`
+	Description string     `json:"description" validate:"required,max=1000"`
+	Tags        []string   `json:"tags"`
+	StartsAt    time.Time  `json:"starts_at" validate:"required"`
+	EndsAt      *time.Time `json:"ends_at"`
+	// An event needs a Location, an OnlineURL or both.
+	Location  string `json:"location" validate:"required_without=OnlineURL,max=200"`
+	OnlineURL string `json:"online_url" validate:"omitempty,url,max=500"`
+}
+
+type RSVPPayload struct {
+	Status string `json:"status" validate:"required,oneof=going interested"`
+}
+
+func parseEventID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "eventID"), 10, 64)
+}
+
+// eventLookupError answers a failed event lookup or change.
+func (app *application) eventLookupError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CreateEvent godoc
+//
+//	@Summary		Create an event
+//	@Description	Publishes a meetup. It is announced by a post of kind "event" with the event's ID, which reaches followers' feeds like any post
+//	@Tags			events
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateEventPayload	true	"Event"
+//	@Success		201		{object}	store.Event
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error	"Risky account posting too often"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events [post]
+func (app *application) createEventHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateEventPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if !payload.StartsAt.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("starts_at must be in the future"))
+		return
+	}
+	if payload.EndsAt != nil && !payload.EndsAt.After(payload.StartsAt) {
+		app.badRequestResponse(w, r, errors.New("ends_at must be after starts_at"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	retryAfter, err := app.postThrottle(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	post := &store.Post{
+		Title:   payload.Title,
+		Content: payload.Description,
+		Tags:    payload.Tags,
+		UserID:  user.ID,
+		Lang:    lang.Detect(payload.Title + "\n" + payload.Description),
+	}
+	event := &store.Event{
+		StartsAt:  payload.StartsAt,
+		EndsAt:    payload.EndsAt,
+		Location:  payload.Location,
+		OnlineURL: payload.OnlineURL,
+	}
+	if err := app.store.Events.Create(ctx, post, event); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.events.Publish(ctx, events.PostCreated{Post: post})
+	if err := app.jsonResponse(w, http.StatusCreated, event); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListUpcomingEvents godoc
+//
+//	@Summary		List upcoming events
+//	@Description	Events not over yet that the user organizes, answered or that people they follow organize, soonest first
+//	@Tags			events
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Event
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events [get]
+func (app *application) listUpcomingEventsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	upcoming, err := app.store.Events.Upcoming(r.Context(), getUserFromContext(r).ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, upcoming); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetEvent godoc
+//
+//	@Summary		Fetch an event
+//	@Description	The event with its RSVP counts and the viewer's answer
+//	@Tags			events
+//	@Produce		json
+//	@Param			eventID	path		int	true	"Event ID, the ID of the post announcing it"
+//	@Success		200		{object}	store.Event
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events/{eventID} [get]
+func (app *application) getEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	event, err := app.store.Events.Get(r.Context(), id, getUserFromContext(r).ID)
+	if err != nil {
+		app.eventLookupError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, event); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RSVPEvent godoc
+//
+//	@Summary		Answer an event
+//	@Description	Going and interested users are reminded before the event starts
+//	@Tags			events
+//	@Accept			json
+//	@Param			eventID	path	int			true	"Event ID"
+//	@Param			payload	body	RSVPPayload	true	"Answer"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No such upcoming event"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events/{eventID}/rsvp [put]
+func (app *application) rsvpEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload RSVPPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.Events.RSVP(r.Context(), id, getUserFromContext(r).ID, payload.Status); err != nil {
+		app.eventLookupError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveRSVP godoc
+//
+//	@Summary	Withdraw an answer
+//	@Tags		events
+//	@Param		eventID	path	int	true	"Event ID"
+//	@Success	204
+//	@Failure	400	{object}	error
+//	@Failure	404	{object}	error
+//	@Failure	500	{object}	error
+//	@Security	ApiKeyAuth
+//	@Router		/events/{eventID}/rsvp [delete]
+func (app *application) removeRSVPHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.Events.RemoveRSVP(r.Context(), id, getUserFromContext(r).ID); err != nil {
+		app.eventLookupError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListEventAttendees godoc
+//
+//	@Summary		List event attendees
+//	@Description	Users who answered the event, earliest answers first
+//	@Tags			events
+//	@Produce		json
+//	@Param			eventID	path		int	true	"Event ID"
+//	@Param			limit	query		int	false	"Limit (default 50, max 200)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.EventAttendee
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events/{eventID}/attendees [get]
+func (app *application) listEventAttendeesHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if _, err := app.store.Events.Get(ctx, id, 0); err != nil {
+		app.eventLookupError(w, r, err)
+		return
+	}
+	attendees, err := app.store.Events.Attendees(ctx, id, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, attendees); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// CancelEvent godoc
+//
+//	@Summary		Cancel an event
+//	@Description	Only the organizer can cancel an upcoming event. Everyone who answered it is notified
+//	@Tags			events
+//	@Param			eventID	path	int	true	"Event ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error	"No such upcoming event by the user"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/events/{eventID}/cancel [post]
+func (app *application) cancelEventHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseEventID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	organizerID := getUserFromContext(r).ID
+	attendees, err := app.store.Events.Cancel(ctx, id, organizerID)
+	if err != nil {
+		app.eventLookupError(w, r, err)
+		return
+	}
+	app.notifyEvent(ctx, store.NotificationEventCanceled, id, organizerID, attendees)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifyEvent notifies the attendees about the event, failures are only
+// logged.
+func (app *application) notifyEvent(ctx context.Context, notificationType string, eventID, organizerID int64, attendees []int64) {
+	notifications := make([]store.Notification, len(attendees))
+	for i, id := range attendees {
+		notifications[i] = store.Notification{UserID: id, ActorID: organizerID, Type: notificationType, PostID: eventID}
+	}
+	if err := app.createNotifications(ctx, notifications); err != nil {
+		app.logger.Errorw("error notifying event attendees", "event_id", eventID, "type", notificationType, "error", err.Error())
+	}
+}
+
+// remindEvents notifies the attendees of events about to start.
+func (app *application) remindEvents(ctx context.Context) error {
+	cfg := app.config.events
+	reminders, err := app.store.Events.ClaimReminders(ctx, cfg.reminderLead, cfg.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, rem := range reminders {
+		app.notifyEvent(ctx, store.NotificationEventReminder, rem.EventID, rem.OrganizerID, rem.AttendeeIDs)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestMeetups(t *testing.T) {
+	app := NewTestApplication(t, config{events: eventsConfig{reminderLead: time.Hour, batchSize: 10}})
+	events := &store.MockEventStore{}
+	app.store.Events = events
+	notifications := &store.MockNotificationStore{}
+	app.store.Notifications = notifications
+	mux := app.mount()
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp}))
+		return executeRequest(req, mux)
+	}
+	at := func(d time.Duration) string {
+		return time.Now().Add(d).UTC().Format(time.RFC3339)
+	}
+
+	t.Run("should take upcoming events with a place", func(t *testing.T) {
+		for body, want := range map[string]int{
+			fmt.Sprintf(`{"title":"Gopher meetup","description":"Talks","starts_at":"%s","location":"Nairobi"}`, at(-time.Hour)):    http.StatusBadRequest,
+			fmt.Sprintf(`{"title":"Gopher meetup","description":"Talks","starts_at":"%s"}`, at(time.Hour)):                          http.StatusBadRequest,
+			fmt.Sprintf(`{"title":"Gopher meetup","description":"Talks","starts_at":"%s","online_url":"not a url"}`, at(time.Hour)): http.StatusBadRequest,
+		} {
+			if code := request(t, 42, http.MethodPost, "/v1/events/", body).Code; code != want {
+				t.Errorf("%s: expected %d, got %d", body, want, code)
+			}
+		}
+		body := fmt.Sprintf(`{"title":"Gopher meetup","description":"Talks","starts_at":"%s","online_url":"https://meet.example/gophers"}`, at(30*time.Minute))
+		checkResponseCode(t, http.StatusCreated, request(t, 42, http.MethodPost, "/v1/events/", body).Code)
+	})
+
+	t.Run("should count answers", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 43, http.MethodPut, "/v1/events/1/rsvp", `{"status":"maybe"}`).Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodPut, "/v1/events/9/rsvp", `{"status":"going"}`).Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 43, http.MethodPut, "/v1/events/1/rsvp", `{"status":"going"}`).Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 44, http.MethodPut, "/v1/events/1/rsvp", `{"status":"interested"}`).Code)
+
+		rr := request(t, 43, http.MethodGet, "/v1/events/1/", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		event := decodeData[store.Event](t, rr.Body.String())
+		if event.Going != 1 || event.Interested != 1 || event.RSVP != store.RSVPGoing {
+			t.Errorf("expected 1 going and 1 interested with the viewer going, got %+v", event)
+		}
+	})
+
+	t.Run("should remind the attendees once", func(t *testing.T) {
+		for range 2 {
+			if err := app.remindEvents(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(notifications.Notifications) != 2 || notifications.Notifications[0].Type != store.NotificationEventReminder || notifications.Notifications[0].PostID != 1 {
+			t.Errorf("expected a reminder per attendee, got %+v", notifications.Notifications)
+		}
+	})
+
+	t.Run("should let only the organizer cancel", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodPost, "/v1/events/1/cancel", "").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, http.MethodPost, "/v1/events/1/cancel", "").Code)
+		if got := notifications.Notifications[len(notifications.Notifications)-1]; got.Type != store.NotificationEventCanceled {
+			t.Errorf("expected the attendees to hear about the cancellation, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, 45, http.MethodPut, "/v1/events/1/rsvp", `{"status":"going"}`).Code)
+	})
+}
@@ -2,35 +2,51 @@ package main
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/db"
+	"gopher_social/internal/ratelimiter"
 	"gopher_social/internal/store"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/net/context"
 )
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("missing auth header"))
-			return
-		}
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			app.unauthorizedErrorResponse(w, r, fmt.Errorf("auth header is malformed"))
-			return
-		}
-		token := parts[1]
-		jwtToken, err := app.authenticator.ValidateToken(token)
+		token, err := app.requestToken(r)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
-		claims := jwtToken.Claims.(jwt.MapClaims)
+		// Service accounts authenticate with API keys only, everyone else
+		// with session tokens.
+		var claims jwt.MapClaims
+		var scopes []string
+		viaAPIKey := strings.HasPrefix(token, store.APIKeyPrefix)
+		if viaAPIKey {
+			key := app.requestAPIKey(r)
+			if key == nil {
+				app.unauthorizedErrorResponse(w, r, errors.New("invalid api key"))
+				return
+			}
+			claims = jwt.MapClaims{"sub": float64(key.UserID)}
+			scopes = key.Scopes
+		} else {
+			jwtToken, err := app.authenticator.ValidateToken(token)
+			if err != nil {
+				app.unauthorizedErrorResponse(w, r, err)
+				return
+			}
+			claims = jwtToken.Claims.(jwt.MapClaims)
+		}
 		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
 		if err != nil {
 			app.unauthorizedErrorResponse(w, r, err)
@@ -42,10 +58,96 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 			app.unauthorizedErrorResponse(w, r, err)
 			return
 		}
+		if user.IsBot != viaAPIKey {
+			app.unauthorizedErrorResponse(w, r, errors.New("wrong credentials for the account type"))
+			return
+		}
+		if err := app.checkSession(ctx, r, claims, userID); err != nil {
+			switch {
+			case errors.Is(err, errSessionRevoked):
+				app.unauthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		// Tokens issued to third party apps stop working once the app or
+		// the user's grant is revoked.
+		if delegatedClientID(claims) != "" {
+			if err := app.checkGrant(ctx, claims, userID); err != nil {
+				switch {
+				case errors.Is(err, store.ErrRecordNotFound):
+					app.unauthorizedErrorResponse(w, r, err)
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+			scope, _ := claims["scope"].(string)
+			scopes = store.ExpandScopes(strings.Fields(scope))
+		}
+		// Admins impersonating the user are held to what support needs and
+		// the response says who is acting.
+		if actorID, ok := impersonatorID(claims); ok {
+			allowed, err := app.authorizeImpersonation(ctx, r, actorID, user)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+			w.Header().Set("X-Impersonated-By", strconv.FormatInt(actorID, 10))
+		}
+		if scopes != nil && !scopesAllow(r, scopes) {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		if app.termsPending(r, user) {
+			app.termsRequiredResponse(w, r)
+			return
+		}
+		noteCaptureUser(ctx, user.ID)
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
+		ctx = context.WithValue(ctx, scopesCtx, scopes)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireSudo guards sensitive actions behind a recent authentication. The
+// client re-authenticates through /v1/auth/sudo to get a fresh token.
+func (app *application) RequireSudo(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := getClaimsFromContext(r)
+		authTime, ok := claims["auth_time"].(float64)
+		if !ok || time.Since(time.Unix(int64(authTime), 0)) > app.config.auth.sudoWindow {
+			app.sudoRequiredResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestToken extracts the bearer token, falling back to the session cookie
+// for browser clients when cookie sessions are enabled.
+func (app *application) requestToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if app.config.auth.cookie.enabled {
+			if _, err := r.Cookie(app.config.auth.cookie.name); err == nil {
+				return app.sessionToken(r)
+			}
+		}
+		return "", fmt.Errorf("missing auth header")
+	}
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", fmt.Errorf("auth header is malformed")
+	}
+	return parts[1], nil
+}
 func (app *application) BasicAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -104,7 +206,44 @@ func (app *application) checkPostOwnership(requiredRole string, next http.Handle
 		next.ServeHTTP(w, r)
 	})
 }
+
+// checkPostAuthor only lets the post's author through, whatever the role of
+// anyone else.
+func (app *application) checkPostAuthor(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getPostFromCtx(r).UserID != getUserFromContext(r).ID {
+			app.forbiddenResponse(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireRole only lets users at or above the given role through.
+func (app *application) requireRole(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			allowed, err := app.checkRolePrecedence(r.Context(), getUserFromContext(r), requiredRole)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if !allowed {
+				app.forbiddenResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkRolePrecedence reports whether the user holds requiredRole or above.
+// Third party apps and impersonating admins act with a plain user's rights
+// whatever the user's role.
 func (app *application) checkRolePrecedence(ctx context.Context, user *store.User, requiredRole string) (bool, error) {
+	if (isDelegated(ctx) || isImpersonated(ctx)) && requiredRole != "user" {
+		return false, nil
+	}
 	role, err := app.store.Roles.GetByName(ctx, requiredRole)
 	if err != nil {
 		return false, err
@@ -116,11 +255,17 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 		// app.logger.Infow("cache miss", "userID", userID)
 		return app.store.Users.GetByID(ctx, userID)
 	}
+	if app.hotKeys != nil {
+		app.hotKeys.touch(userID)
+	}
 
+	// cache.WithBreaker already reports failed reads as misses, an error
+	// from a bare cache is treated the same way.
 	user, err := app.cacheStorage.Users.Get(ctx, userID)
 	// app.logger.Infow("cache hit", "userID", userID)
 	if err != nil {
-		return nil, err
+		app.logCacheError("error reading cached user", userID, err)
+		user = nil
 	}
 	if user == nil {
 		// app.logger.Infow("user not found in cache, fetching from database", "userID", userID)
@@ -128,18 +273,35 @@ func (app *application) getUser(ctx context.Context, userID int64) (*store.User,
 		if err != nil {
 			return nil, err
 		}
-		err = app.cacheStorage.Users.Set(ctx, user)
-		if err != nil {
-			return nil, err
+		if err := app.cacheStorage.Users.Set(ctx, user); err != nil {
+			app.logCacheError("error caching user", userID, err)
 		}
 		return user, nil
 	}
 	return user, nil
 }
+
+// logCacheError logs a failed cache call. While the breaker is open every call
+// fails the same way, so those are only logged at debug level.
+func (app *application) logCacheError(msg string, userID int64, err error) {
+	if errors.Is(err, breaker.ErrOpen) {
+		app.logger.Debugw(msg, "user_id", userID, "error", err.Error())
+		return
+	}
+	app.logger.Warnw(msg, "user_id", userID, "error", err.Error())
+}
+
+// RateLimiterMiddleware limits clients by IP, service accounts calling with a
+// valid API key are limited per account by the bot limiter instead.
 func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if app.config.rateLimiter.Enabled {
-			if allow, retryAfter := app.rateLimiter.Allow(r.RemoteAddr); !allow {
+			limiter, client := app.rateLimiter, r.RemoteAddr
+			var key *store.APIKey
+			if r, key = app.withAPIKey(r); key != nil {
+				limiter, client = app.botRateLimiter, "bot:"+strconv.FormatInt(key.UserID, 10)
+			}
+			if allow, retryAfter := limiter.Allow(client); !allow {
 				app.rateLimitExceedResponse(w, r, retryAfter.String())
 				return
 			}
@@ -148,3 +310,75 @@ func (app *application) RateLimiterMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// SecurityHeadersMiddleware sets the hardening headers on every response. The
+// swagger UI ships inline scripts and styles so it gets its own, looser CSP.
+func (app *application) SecurityHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := app.config.securityHeaders
+		h := w.Header()
+
+		csp := cfg.csp
+		if strings.HasPrefix(r.URL.Path, "/v1/swagger/") {
+			csp = cfg.swaggerCSP
+		}
+		if csp != "" {
+			h.Set("Content-Security-Policy", csp)
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		if cfg.referrerPolicy != "" {
+			h.Set("Referrer-Policy", cfg.referrerPolicy)
+		}
+		if app.config.tls.enabled() && cfg.hstsMaxAge > 0 {
+			h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(cfg.hstsMaxAge.Seconds())))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StatementBudgetMiddleware counts the SQL statements of every request and
+// logs the ones running more than the budget, usually a query in a loop.
+func (app *application) StatementBudgetMiddleware(next http.Handler) http.Handler {
+	cfg := app.config.db
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, counter := db.WithStatementCounter(r.Context(), cfg.statementBudget, cfg.enforceBudget)
+		next.ServeHTTP(w, r.WithContext(ctx))
+		if counter.Exceeded() {
+			app.logger.Warnw("statement budget exceeded",
+				"route", app.routeLabels.label(r.Method, chi.RouteContext(ctx)),
+				"statements", counter.Count(),
+				"budget", cfg.statementBudget,
+			)
+		}
+	})
+}
+
+// requestDeadline puts a deadline of d on the request context and answers
+// 504 when the handler overruns it. Zero leaves the request unbounded.
+func requestDeadline(d time.Duration) func(http.Handler) http.Handler {
+	if d <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	return middleware.Timeout(d)
+}
+
+// limitInFlight sheds load with a 503 once max requests are already being
+// served through it. Zero or less disables the cap.
+func (app *application) limitInFlight(max int) func(http.Handler) http.Handler {
+	if max <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	limiter := ratelimiter.NewConcurrencyLimiter(max)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.TryAcquire() {
+				app.serviceUnavailableResponse(w, r, app.config.concurrency.retryAfter)
+				return
+			}
+			defer limiter.Release()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
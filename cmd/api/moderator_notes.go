@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CreateModeratorNotePayload struct {
+	Body string `json:"body" validate:"required,max=5000"`
+}
+
+// CreateUserNote godoc
+//
+//	@Summary		Add a note on a user
+//	@Description	Adds an internal moderator note to an account, never shown to the user
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int							true	"User ID"
+//	@Param			payload	body		CreateModeratorNotePayload	true	"Note"
+//	@Success		201		{object}	store.ModeratorNote
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/users/{userID}/notes [post]
+func (app *application) createUserNoteHandler(w http.ResponseWriter, r *http.Request) {
+	app.createModeratorNote(w, r, store.NoteSubjectUser, "userID")
+}
+
+// ListUserNotes godoc
+//
+//	@Summary		User note history
+//	@Description	Notes on an account and on its posts, newest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.ModeratorNote
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/users/{userID}/notes [get]
+func (app *application) listUserNotesHandler(w http.ResponseWriter, r *http.Request) {
+	app.listModeratorNotes(w, r, store.NoteSubjectUser, "userID")
+}
+
+// CreatePostNote godoc
+//
+//	@Summary		Add a note on a post
+//	@Description	Adds an internal moderator note to a post, never shown to its author
+//	@Tags			moderation
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int							true	"Post ID"
+//	@Param			payload	body		CreateModeratorNotePayload	true	"Note"
+//	@Success		201		{object}	store.ModeratorNote
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/posts/{postID}/notes [post]
+func (app *application) createPostNoteHandler(w http.ResponseWriter, r *http.Request) {
+	app.createModeratorNote(w, r, store.NoteSubjectPost, "postID")
+}
+
+// ListPostNotes godoc
+//
+//	@Summary		Post note history
+//	@Description	Notes on a post, newest first
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			postID	path		int	true	"Post ID"
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.ModeratorNote
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/posts/{postID}/notes [get]
+func (app *application) listPostNotesHandler(w http.ResponseWriter, r *http.Request) {
+	app.listModeratorNotes(w, r, store.NoteSubjectPost, "postID")
+}
+
+func (app *application) createModeratorNote(w http.ResponseWriter, r *http.Request, subjectType, param string) {
+	subjectID, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload CreateModeratorNotePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	note := &store.ModeratorNote{
+		SubjectType: subjectType,
+		SubjectID:   subjectID,
+		AuthorID:    getUserFromContext(r).ID,
+		Body:        payload.Body,
+	}
+	if err := app.store.ModeratorNotes.Create(r.Context(), note); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	note.Author = getUserFromContext(r).Username
+	if err := app.jsonResponse(w, http.StatusCreated, note); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) listModeratorNotes(w http.ResponseWriter, r *http.Request, subjectType, param string) {
+	subjectID, err := strconv.ParseInt(chi.URLParam(r, param), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	notes, err := app.store.ModeratorNotes.List(r.Context(), subjectType, subjectID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, notes); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModeratorNotes(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	notes := &store.MockModeratorNoteStore{}
+	app.store.ModeratorNotes = notes
+	app.store.Users = &adminUserStore{}
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux)
+	}
+
+	checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/moderation/users/7/notes", `{"body":"warned about link spam"}`).Code)
+	checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/moderation/posts/3/notes", `{"body":"reported twice"}`).Code)
+	checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/moderation/users/7/notes", `{"body":""}`).Code)
+
+	rr := request(t, http.MethodGet, "/v1/moderation/users/7/notes", "")
+	checkResponseCode(t, http.StatusOK, rr.Code)
+	history := decodeData[[]store.ModeratorNote](t, rr.Body.String())
+	if len(history) != 1 || history[0].AuthorID != 42 {
+		t.Errorf("expected the user note by the moderator, got %+v", history)
+	}
+}
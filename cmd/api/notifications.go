@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxMentionsPerComment caps how many users a single comment can notify.
+const maxMentionsPerComment = 10
+
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_]{1,100})`)
+
+// parseMentions returns the distinct @usernames in content, in order of
+// appearance.
+func parseMentions(content string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, m := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		if seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		names = append(names, m[1])
+		if len(names) == maxMentionsPerComment {
+			break
+		}
+	}
+	return names
+}
+
+// commentNotifications decides who hears about a new comment. Every user is
+// notified at most once per comment, the most specific reason wins: a reply
+// beats a mention which beats a plain comment on your post. The commenter is
+// never notified of their own comment.
+func commentNotifications(post *store.Post, comment *store.Comment, parent *store.Comment, mentioned []int64) []store.Notification {
+	var notifications []store.Notification
+	notified := map[int64]bool{comment.UserID: true}
+	add := func(userID int64, kind string) {
+		if notified[userID] {
+			return
+		}
+		notified[userID] = true
+		notifications = append(notifications, store.Notification{
+			UserID:    userID,
+			ActorID:   comment.UserID,
+			Type:      kind,
+			PostID:    post.ID,
+			CommentID: comment.ID,
+		})
+	}
+	if parent != nil {
+		add(parent.UserID, store.NotificationReply)
+	}
+	for _, id := range mentioned {
+		add(id, store.NotificationMention)
+	}
+	add(post.UserID, store.NotificationComment)
+	return notifications
+}
+
+// notifyComment stores the notifications for a new comment. A mention that
+// can't be resolved is logged and skipped.
+func (app *application) notifyComment(ctx context.Context, e events.CommentCreated) error {
+	comment := e.Comment
+	var mentioned []int64
+	if names := parseMentions(comment.Content); len(names) > 0 {
+		ids, err := app.store.Users.GetIDsByUsernames(ctx, names)
+		if err != nil {
+			app.logger.Errorw("error resolving mentions", "comment_id", comment.ID, "error", err.Error())
+		}
+		for _, name := range names {
+			if id, ok := ids[name]; ok {
+				mentioned = append(mentioned, id)
+			}
+		}
+	}
+	notifications := commentNotifications(e.Post, comment, e.Parent, mentioned)
+	if err := app.createNotifications(ctx, notifications); err != nil {
+		return fmt.Errorf("creating notifications for comment %d: %w", comment.ID, err)
+	}
+	return nil
+}
+
+// notifyNewPost notifies the followers who rang the bell on the author.
+func (app *application) notifyNewPost(ctx context.Context, e events.PostCreated) error {
+	post := e.Post
+	subscribers, err := app.store.Followers.GetSubscriberIDs(ctx, post.UserID)
+	if err != nil {
+		return fmt.Errorf("loading subscribers of user %d: %w", post.UserID, err)
+	}
+	notifications := make([]store.Notification, 0, len(subscribers))
+	for _, id := range subscribers {
+		if id == post.UserID {
+			continue
+		}
+		notifications = append(notifications, store.Notification{
+			UserID:  id,
+			ActorID: post.UserID,
+			Type:    store.NotificationNewPost,
+			PostID:  post.ID,
+		})
+	}
+	if err := app.createNotifications(ctx, notifications); err != nil {
+		return fmt.Errorf("creating notifications for post %d: %w", post.ID, err)
+	}
+	return nil
+}
+
+// GetNotifications godoc
+//
+//	@Summary		List notifications
+//	@Description	Lists the authenticated user's notifications, newest first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.Notification
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	notifications, err := app.store.Notifications.GetByUserID(r.Context(), getUserFromContext(r).ID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// Listing them delivers them in-app.
+	app.recordInAppDelivery(r.Context(), notifications)
+	if err := app.jsonResponse(w, http.StatusOK, notifications); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// MarkNotificationsRead godoc
+//
+//	@Summary		Mark notifications read
+//	@Description	Marks all of the authenticated user's notifications as read
+//	@Tags			users
+//	@Success		204	{string}	string	"Marked read"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/read [put]
+func (app *application) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.Notifications.MarkAllRead(r.Context(), getUserFromContext(r).ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// seenChannel reads the channel a notification was seen on, in-app unless
+// the client says otherwise, e.g. after the user tapped a push.
+func seenChannel(channel string) (string, error) {
+	switch channel {
+	case "":
+		return store.ChannelInApp, nil
+	case store.ChannelInApp, store.ChannelPush, store.ChannelEmail:
+		return channel, nil
+	}
+	return "", errors.New("unknown channel " + channel)
+}
+
+// MarkNotificationSeen godoc
+//
+//	@Summary		Mark a notification seen
+//	@Description	Marks one of the authenticated user's notifications read and records the channel it was seen on. Read notifications are not pushed or emailed afterwards
+//	@Tags			users
+//	@Param			notificationID	path	int		true	"Notification ID"
+//	@Param			channel			query	string	false	"in_app (default), push or email"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/{notificationID}/seen [post]
+func (app *application) markNotificationSeenHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "notificationID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	channel, err := seenChannel(r.URL.Query().Get("channel"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	seen, err := app.store.Notifications.MarkSeen(r.Context(), getUserFromContext(r).ID, []int64{id}, channel)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(seen) == 0 {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type MarkNotificationsSeenPayload struct {
+	IDs     []int64 `json:"ids" validate:"required,min=1,max=100"`
+	Channel string  `json:"channel"`
+}
+
+// NotificationsSeen lists the notifications that were marked, IDs of other
+// users' notifications are left out.
+type NotificationsSeen struct {
+	IDs []int64 `json:"ids"`
+}
+
+// MarkNotificationsSeen godoc
+//
+//	@Summary		Mark notifications seen
+//	@Description	Marks a batch of the authenticated user's notifications read and records the channel they were seen on
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		MarkNotificationsSeenPayload	true	"Notifications"
+//	@Success		200		{object}	NotificationsSeen
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/seen [post]
+func (app *application) markNotificationsSeenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload MarkNotificationsSeenPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	channel, err := seenChannel(payload.Channel)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	seen, err := app.store.Notifications.MarkSeen(r.Context(), getUserFromContext(r).ID, payload.IDs, channel)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if seen == nil {
+		seen = []int64{}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, NotificationsSeen{IDs: seen}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type NotificationPreferencesPayload struct {
+	Muted []string `json:"muted" validate:"max=10,dive,oneof=comment reply mention post follow_back"`
+}
+
+// SetNotificationPreferences godoc
+//
+//	@Summary		Set notification preferences
+//	@Description	Replaces the list of notification types the authenticated user has muted
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		NotificationPreferencesPayload	true	"Muted types"
+//	@Success		204		{string}	string							"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/notifications/preferences [put]
+func (app *application) setNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload NotificationPreferencesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	muted := []string{}
+	for _, t := range payload.Muted {
+		if !slices.Contains(muted, t) {
+			muted = append(muted, t)
+		}
+	}
+	ctx := r.Context()
+	if err := app.store.Users.SetMutedNotificationTypes(ctx, user.ID, muted); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// suggestFollowBacks sends "X followed you, follow back?" notifications in
+// batches until no batch comes back full, so one run clears the backlog
+// without holding a single long statement.
+func (app *application) suggestFollowBacks(ctx context.Context) error {
+	cfg := app.config.notifications
+	since := time.Now().Add(-cfg.followBackLookback)
+	total := 0
+	for {
+		n, err := app.store.Notifications.CreateFollowBackSuggestions(ctx, since, cfg.followBackDailyCap, cfg.followBackBatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		if n < cfg.followBackBatchSize || ctx.Err() != nil {
+			break
+		}
+	}
+	if total > 0 {
+		app.logger.Infow("follow-back suggestions sent", "count", total)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// pollBatch caps the notifications one poll returns, the client polls again
+// right away with the new cursor for the rest.
+const pollBatch = 50
+
+// notificationWaiters wakes the long polls of users who got notifications.
+// It only knows the polls this instance serves, the others find out on
+// their next recheck.
+type notificationWaiters struct {
+	mu      sync.Mutex
+	waiting map[int64]map[chan struct{}]struct{}
+}
+
+// wait registers a poll of userID. The channel receives when the user gets
+// notifications, cancel unregisters it.
+func (nw *notificationWaiters) wait(userID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	nw.mu.Lock()
+	if nw.waiting == nil {
+		nw.waiting = make(map[int64]map[chan struct{}]struct{})
+	}
+	if nw.waiting[userID] == nil {
+		nw.waiting[userID] = make(map[chan struct{}]struct{})
+	}
+	nw.waiting[userID][ch] = struct{}{}
+	nw.mu.Unlock()
+	return ch, func() {
+		nw.mu.Lock()
+		delete(nw.waiting[userID], ch)
+		if len(nw.waiting[userID]) == 0 {
+			delete(nw.waiting, userID)
+		}
+		nw.mu.Unlock()
+	}
+}
+
+func (nw *notificationWaiters) wake(userIDs []int64) {
+	nw.mu.Lock()
+	defer nw.mu.Unlock()
+	for _, id := range userIDs {
+		for ch := range nw.waiting[id] {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// createNotifications saves notifications and tells the subscribers of
+// NotificationsCreated who got them.
+func (app *application) createNotifications(ctx context.Context, notifications []store.Notification) error {
+	if err := app.store.Notifications.CreateMany(ctx, notifications); err != nil {
+		return err
+	}
+	if len(notifications) == 0 {
+		return nil
+	}
+	userIDs := make([]int64, 0, len(notifications))
+	for _, n := range notifications {
+		userIDs = append(userIDs, n.UserID)
+	}
+	app.events.Publish(ctx, events.NotificationsCreated{UserIDs: userIDs})
+	return nil
+}
+
+// NotificationsPoll is what a long poll returns. Cursor goes into the next
+// poll.
+type NotificationsPoll struct {
+	Notifications []store.Notification `json:"notifications"`
+	Cursor        string               `json:"cursor"`
+}
+
+// pollWait reads how long a poll may wait, capped at max.
+func pollWait(v string, max time.Duration) (time.Duration, error) {
+	if v == "" {
+		return max, nil
+	}
+	wait, err := time.ParseDuration(v)
+	if err != nil || wait < 0 {
+		return 0, errors.New("wait must be a duration like 25s")
+	}
+	return min(wait, max), nil
+}
+
+// PollNotifications godoc
+//
+//	@Summary		Long poll notifications
+//	@Description	A transport for clients that can't keep a streaming connection. Without a cursor it answers right away with the cursor to start from. With one it answers as soon as notifications were created or collapsed after it, or with none once wait is over. Either way poll again with the returned cursor
+//	@Tags			users
+//	@Produce		json
+//	@Param			cursor	query		string	false	"Cursor of the previous poll"
+//	@Param			wait	query		string	false	"How long to wait, e.g. 25s (default and max 25s)"
+//	@Success		200		{object}	NotificationsPoll
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Failure		503		{object}	error	"Too many open polls"
+//	@Security		ApiKeyAuth
+//	@Router			/notifications/poll [get]
+func (app *application) pollNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	cfg := app.config.notifications
+	wait, err := pollWait(r.URL.Query().Get("wait"), cfg.pollMaxWait)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	user := getUserFromContext(r)
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		seq, err := app.store.Notifications.LatestSeq(ctx, user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		app.respondPoll(w, r, []store.Notification{}, seq)
+		return
+	}
+	seq, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || seq < 0 {
+		app.badRequestResponse(w, r, store.ErrInvalidCursor)
+		return
+	}
+
+	// Registering before the first read means a notification created in
+	// between still wakes the poll.
+	woken, cancel := app.waiters.wait(user.ID)
+	defer cancel()
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	var recheck <-chan time.Time
+	if cfg.pollRecheck > 0 {
+		ticker := time.NewTicker(cfg.pollRecheck)
+		defer ticker.Stop()
+		recheck = ticker.C
+	}
+	for {
+		notifications, err := app.store.Notifications.ChangedAfter(ctx, user.ID, seq, pollBatch)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if len(notifications) > 0 {
+			app.recordInAppDelivery(ctx, notifications)
+			app.respondPoll(w, r, notifications, notifications[len(notifications)-1].Seq)
+			return
+		}
+		select {
+		case <-woken:
+		case <-recheck:
+		case <-timeout.C:
+			app.respondPoll(w, r, notifications, seq)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (app *application) respondPoll(w http.ResponseWriter, r *http.Request, notifications []store.Notification, seq int64) {
+	res := NotificationsPoll{Notifications: notifications, Cursor: strconv.FormatInt(seq, 10)}
+	if err := app.jsonResponse(w, http.StatusOK, res); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// recordInAppDelivery records the unread notifications handed to the
+// client as delivered in-app, like listing them does.
+func (app *application) recordInAppDelivery(ctx context.Context, notifications []store.Notification) {
+	var unread []int64
+	for _, n := range notifications {
+		if n.ReadAt == nil {
+			unread = append(unread, n.ID)
+		}
+	}
+	if err := app.store.Notifications.RecordDelivery(ctx, store.ChannelInApp, unread, unread); err != nil {
+		app.logger.Errorw("error recording notification delivery", "error", err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// lockedNotificationStore lets a poll read while the test writes.
+type lockedNotificationStore struct {
+	mu sync.Mutex
+	store.MockNotificationStore
+}
+
+func (s *lockedNotificationStore) CreateMany(ctx context.Context, notifications []store.Notification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.MockNotificationStore.CreateMany(ctx, notifications)
+}
+
+func (s *lockedNotificationStore) ChangedAfter(ctx context.Context, userID, seq int64, limit int) ([]store.Notification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.MockNotificationStore.ChangedAfter(ctx, userID, seq, limit)
+}
+
+func TestPollNotifications(t *testing.T) {
+	app := NewTestApplication(t, config{notifications: notificationsConfig{pollMaxWait: 5 * time.Second}})
+	app.store.Notifications = &lockedNotificationStore{}
+	mux := app.mount()
+	token := signTestToken(t, jwt.MapClaims{"sub": 7, "exp": time.Now().Add(time.Hour).Unix()})
+
+	poll := func(t *testing.T, query string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/notifications/poll"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux)
+	}
+
+	t.Run("should reject bad parameters", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, poll(t, "?cursor=0&wait=soon").Code)
+		checkResponseCode(t, http.StatusBadRequest, poll(t, "?cursor=abc").Code)
+	})
+
+	t.Run("should hand out a cursor and time out empty", func(t *testing.T) {
+		rr := poll(t, "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[NotificationsPoll](t, rr.Body.String()); got.Cursor != "0" || len(got.Notifications) != 0 {
+			t.Fatalf("expected an empty poll at cursor 0, got %+v", got)
+		}
+		rr = poll(t, "?cursor=0&wait=10ms")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[NotificationsPoll](t, rr.Body.String()); got.Cursor != "0" || len(got.Notifications) != 0 {
+			t.Fatalf("expected the poll to time out empty, got %+v", got)
+		}
+	})
+
+	t.Run("should answer right away with pending notifications", func(t *testing.T) {
+		if err := app.createNotifications(context.Background(), []store.Notification{{UserID: 7, ActorID: 8, Type: store.NotificationMention}}); err != nil {
+			t.Fatal(err)
+		}
+		got := decodeData[NotificationsPoll](t, poll(t, "?cursor=0").Body.String())
+		if got.Cursor != "1" || len(got.Notifications) != 1 {
+			t.Fatalf("expected the notification and cursor 1, got %+v", got)
+		}
+	})
+
+	t.Run("should wake up when a notification arrives", func(t *testing.T) {
+		done := make(chan *httptest.ResponseRecorder)
+		start := time.Now()
+		go func() { done <- poll(t, "?cursor=1") }()
+		for waiting := false; !waiting; {
+			time.Sleep(time.Millisecond)
+			app.waiters.mu.Lock()
+			waiting = len(app.waiters.waiting[7]) > 0
+			app.waiters.mu.Unlock()
+		}
+		if err := app.createNotifications(context.Background(), []store.Notification{{UserID: 7, ActorID: 9, Type: store.NotificationMention}}); err != nil {
+			t.Fatal(err)
+		}
+		rr := <-done
+		if time.Since(start) > 2*time.Second {
+			t.Error("expected the poll to return before it timed out")
+		}
+		got := decodeData[NotificationsPoll](t, rr.Body.String())
+		if got.Cursor != "2" || len(got.Notifications) != 1 || got.Notifications[0].ActorID != 9 {
+			t.Fatalf("expected the new notification, got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/push"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	got := parseMentions("@alice thanks, cc @bob and @alice again (mail me at carol@example.com)")
+	want := []string{"alice", "bob"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestCommentNotifications(t *testing.T) {
+	post := &store.Post{ID: 1, UserID: 10}
+
+	t.Run("should notify the post author of a new comment", func(t *testing.T) {
+		comment := &store.Comment{ID: 5, PostID: 1, UserID: 20}
+		got := commentNotifications(post, comment, nil, nil)
+		if len(got) != 1 || got[0].UserID != 10 || got[0].Type != store.NotificationComment {
+			t.Errorf("expected a single comment notification for user 10, got %+v", got)
+		}
+	})
+
+	t.Run("should not notify authors of their own comments", func(t *testing.T) {
+		comment := &store.Comment{ID: 5, PostID: 1, UserID: 10}
+		if got := commentNotifications(post, comment, nil, []int64{10}); len(got) != 0 {
+			t.Errorf("expected no notifications, got %+v", got)
+		}
+	})
+
+	t.Run("should notify each user once with the most specific reason", func(t *testing.T) {
+		parent := &store.Comment{ID: 4, PostID: 1, UserID: 10}
+		comment := &store.Comment{ID: 5, PostID: 1, UserID: 20}
+		got := commentNotifications(post, comment, parent, []int64{10, 30, 30})
+		if len(got) != 2 {
+			t.Fatalf("expected 2 notifications, got %+v", got)
+		}
+		if got[0].UserID != 10 || got[0].Type != store.NotificationReply {
+			t.Errorf("expected a reply notification for user 10, got %+v", got[0])
+		}
+		if got[1].UserID != 30 || got[1].Type != store.NotificationMention {
+			t.Errorf("expected a mention notification for user 30, got %+v", got[1])
+		}
+	})
+}
+
+func TestSetNotificationPreferences(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should accept known types", `{"muted":["follow_back","mention"]}`, http.StatusNoContent},
+		{"should accept an empty list", `{"muted":[]}`, http.StatusNoContent},
+		{"should reject unknown types", `{"muted":["spam"]}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/me/notifications/preferences", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
+
+func TestNotificationReceipts(t *testing.T) {
+	app := NewTestApplication(t, config{push: pushConfig{batchSize: 10}})
+	sender := &fakeSender{}
+	app.push = push.Router{push.PlatformIOS: sender}
+	notifications := app.store.Notifications.(*store.MockNotificationStore)
+	ctx := context.Background()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	if err := app.store.PushDevices.Register(ctx, &store.PushDevice{UserID: 42, Platform: push.PlatformIOS, Token: "phone"}); err != nil {
+		t.Fatal(err)
+	}
+	err = notifications.CreateMany(ctx, []store.Notification{
+		{UserID: 42, ActorID: 7, Type: store.NotificationReply},
+		{UserID: 42, ActorID: 7, Type: store.NotificationMention},
+		{UserID: 7, ActorID: 42, Type: store.NotificationComment},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should mark the user's notifications seen", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPost, "/v1/notifications/1/seen", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodPost, "/v1/notifications/3/seen", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/notifications/1/seen?channel=sms", "").Code)
+
+		rr := request(t, http.MethodPost, "/v1/notifications/seen", `{"ids":[1,3],"channel":"push"}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if seen := decodeData[NotificationsSeen](t, rr.Body.String()); !slices.Equal(seen.IDs, []int64{1}) {
+			t.Errorf("expected only the user's notification, got %v", seen.IDs)
+		}
+		if notifications.Receipts["1:in_app"] != "seen" || notifications.Receipts["1:push"] != "seen" {
+			t.Errorf("unexpected receipts %v", notifications.Receipts)
+		}
+	})
+
+	t.Run("should not push notifications already read in-app", func(t *testing.T) {
+		if err := app.dispatchPushNotifications(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(sender.sent, []string{"phone:mention"}) {
+			t.Errorf("unexpected pushes %v", sender.sent)
+		}
+		if notifications.Receipts["2:push"] != "delivered" || notifications.Receipts["3:push"] != "attempted" {
+			t.Errorf("unexpected receipts %v", notifications.Receipts)
+		}
+		if err := app.dispatchPushNotifications(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if len(sender.sent) != 1 {
+			t.Errorf("expected no repeated pushes, got %v", sender.sent)
+		}
+	})
+}
+
+func TestNotificationGrouping(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	ctx := context.Background()
+	comment := func(actorID int64) store.Notification {
+		return store.Notification{UserID: 42, ActorID: actorID, Type: store.NotificationComment, PostID: 1}
+	}
+	for _, actorID := range []int64{7, 8, 7, 9} {
+		if err := app.store.Notifications.CreateMany(ctx, []store.Notification{comment(actorID)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.store.Notifications.CreateMany(ctx, []store.Notification{{UserID: 42, ActorID: 7, Type: store.NotificationMention, PostID: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	notifications, err := app.store.Notifications.GetByUserID(ctx, 42, 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 2 {
+		t.Fatalf("expected the comments to collapse, got %+v", notifications)
+	}
+	group := notifications[0]
+	if group.EventCount != 4 || group.ActorCount != 3 || !slices.Equal(group.RecentActorIDs, []int64{9, 7, 8}) {
+		t.Errorf("unexpected group %+v", group)
+	}
+	if msg := pushMessage(group); msg.Body != "3 people commented on your post" || msg.Data["count"] != "4" {
+		t.Errorf("unexpected push %+v", msg)
+	}
+
+	t.Run("should start a new group once read", func(t *testing.T) {
+		if _, err := app.store.Notifications.MarkSeen(ctx, 42, []int64{group.ID}, store.ChannelInApp); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.store.Notifications.CreateMany(ctx, []store.Notification{comment(10)}); err != nil {
+			t.Fatal(err)
+		}
+		notifications, _ := app.store.Notifications.GetByUserID(ctx, 42, 20, 0)
+		if len(notifications) != 3 {
+			t.Errorf("expected a new notification, got %+v", notifications)
+		}
+	})
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"gopher_social/internal/store"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oauthConfig sets how long authorization codes and the access tokens issued
+// to third party apps live.
+type oauthConfig struct {
+	codeTTL  time.Duration
+	tokenExp time.Duration
+}
+
+// delegatedClientID returns the app a token was issued to, empty for the
+// user's own session tokens.
+func delegatedClientID(claims jwt.MapClaims) string {
+	clientID, _ := claims["client_id"].(string)
+	return clientID
+}
+
+// isDelegated reports whether the request was made by a third party app on
+// the user's behalf.
+func isDelegated(ctx context.Context) bool {
+	claims, _ := ctx.Value(claimsCtx).(jwt.MapClaims)
+	return delegatedClientID(claims) != ""
+}
+
+// checkGrant errors with store.ErrRecordNotFound once the user revoked the
+// app the delegated token was issued to, or the app itself was revoked.
+// Tokens of an earlier grant stay dead when the user authorizes the app again.
+func (app *application) checkGrant(ctx context.Context, claims jwt.MapClaims, userID int64) error {
+	grant, err := app.store.OAuth.GetGrant(ctx, userID, delegatedClientID(claims))
+	if err != nil {
+		return err
+	}
+	if id, _ := claims["grant_id"].(float64); int64(id) != grant.ID {
+		return store.ErrRecordNotFound
+	}
+	return nil
+}
+
+// validRedirectURI accepts absolute https URIs without a fragment. Plain
+// http is only allowed for loopback addresses, where native apps listen.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || strings.Contains(raw, "#") {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// parseScopes checks the requested space separated scopes against the
+// client's, no scope at all asks for everything the client registered.
+func parseScopes(raw string, client *store.OAuthClient) ([]string, error) {
+	scopes := strings.Fields(raw)
+	if len(scopes) == 0 {
+		return client.Scopes, nil
+	}
+	for _, s := range scopes {
+		if !slices.Contains(client.Scopes, s) {
+			return nil, errors.New("scope " + s + " is not registered for this client")
+		}
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes), nil
+}
+
+type RegisterOAuthClientPayload struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"required,min=1,max=10,dive,redirect_uri"`
+	Scopes       []string `json:"scopes" validate:"required,min=1,max=20,dive,scope"`
+}
+
+// OAuthClientRegistered carries the new client and its secret, the secret is
+// only ever returned here.
+type OAuthClientRegistered struct {
+	Client       *store.OAuthClient `json:"client"`
+	ClientSecret string             `json:"client_secret"`
+}
+
+// RegisterOAuthClient godoc
+//
+//	@Summary		Register an app
+//	@Description	Registers a third party app that users can authorize to act for them through the authorization code flow
+//	@Tags			oauth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RegisterOAuthClientPayload	true	"App"
+//	@Success		201		{object}	OAuthClientRegistered
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/clients [post]
+func (app *application) registerOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RegisterOAuthClientPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	clientID, secret, err := store.NewOAuthClientCredentials()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	slices.Sort(payload.Scopes)
+	client := &store.OAuthClient{
+		ClientID:     clientID,
+		Name:         payload.Name,
+		RedirectURIs: payload.RedirectURIs,
+		Scopes:       slices.Compact(payload.Scopes),
+		OwnerID:      getUserFromContext(r).ID,
+	}
+	if err := app.store.OAuth.CreateClient(r.Context(), client, secret); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, OAuthClientRegistered{Client: client, ClientSecret: secret}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListOAuthClients godoc
+//
+//	@Summary		List my apps
+//	@Description	The apps the authenticated user registered, newest first
+//	@Tags			oauth
+//	@Produce		json
+//	@Success		200	{object}	[]store.OAuthClient
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/clients [get]
+func (app *application) listOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := app.store.OAuth.ListClients(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, clients); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeOAuthClient godoc
+//
+//	@Summary		Revoke an app
+//	@Description	Disables the app for good, the tokens it was issued stop working right away
+//	@Tags			oauth
+//	@Param			clientID	path	string	true	"Client ID"
+//	@Success		204
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/clients/{clientID} [delete]
+func (app *application) revokeOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.store.OAuth.RevokeClient(r.Context(), getUserFromContext(r).ID, chi.URLParam(r, "clientID")); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// OAuthConsent describes an authorization request for the consent screen.
+type OAuthConsent struct {
+	ClientID    string   `json:"client_id"`
+	Name        string   `json:"name"`
+	RedirectURI string   `json:"redirect_uri"`
+	Scopes      []string `json:"scopes"`
+}
+
+// lookupAuthorization validates an authorization request's client, redirect
+// URI and scopes.
+func (app *application) lookupAuthorization(ctx context.Context, clientID, redirectURI, scope string) (*store.OAuthClient, []string, error) {
+	client, err := app.store.OAuth.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		return nil, nil, errors.New("redirect_uri is not registered for this client")
+	}
+	// Clients registered before the rule was enforced may still list one.
+	if !validRedirectURI(redirectURI) {
+		return nil, nil, errors.New("redirect_uri must use https, or http on a loopback address, and have no fragment")
+	}
+	scopes, err := parseScopes(scope, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, scopes, nil
+}
+
+// GetOAuthConsent godoc
+//
+//	@Summary		Describe an authorization request
+//	@Description	Validates an authorization request and returns what to show on the consent screen
+//	@Tags			oauth
+//	@Produce		json
+//	@Param			client_id		query		string	true	"Client ID"
+//	@Param			redirect_uri	query		string	true	"Registered redirect URI"
+//	@Param			scope			query		string	false	"Space separated scopes, all registered ones when empty"
+//	@Success		200				{object}	OAuthConsent
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error	"Unknown client"
+//	@Failure		500				{object}	error
+//	@Router			/oauth/authorize [get]
+func (app *application) getOAuthConsentHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	client, scopes, err := app.lookupAuthorization(r.Context(), q.Get("client_id"), q.Get("redirect_uri"), q.Get("scope"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	consent := OAuthConsent{ClientID: client.ClientID, Name: client.Name, RedirectURI: q.Get("redirect_uri"), Scopes: scopes}
+	if err := app.jsonResponse(w, http.StatusOK, consent); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type AuthorizeOAuthPayload struct {
+	ClientID    string `json:"client_id" validate:"required"`
+	RedirectURI string `json:"redirect_uri" validate:"required"`
+	Scope       string `json:"scope"`
+	State       string `json:"state" validate:"max=500"`
+	// CodeChallenge enables PKCE, only the S256 method is supported.
+	CodeChallenge       string `json:"code_challenge" validate:"omitempty,min=43,max=128"`
+	CodeChallengeMethod string `json:"code_challenge_method" validate:"required_with=CodeChallenge,omitempty,eq=S256"`
+}
+
+// OAuthRedirect is where the consent screen sends the browser next.
+type OAuthRedirect struct {
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// AuthorizeOAuth godoc
+//
+//	@Summary		Authorize an app
+//	@Description	Records the authenticated user's consent and returns the app's redirect URI carrying a single use code and the state
+//	@Tags			oauth
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		AuthorizeOAuthPayload	true	"Authorization request"
+//	@Success		200		{object}	OAuthRedirect
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Unknown client"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/authorize [post]
+func (app *application) authorizeOAuthHandler(w http.ResponseWriter, r *http.Request) {
+	var payload AuthorizeOAuthPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	client, scopes, err := app.lookupAuthorization(ctx, payload.ClientID, payload.RedirectURI, payload.Scope)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	redirect, err := url.Parse(payload.RedirectURI)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	secret, err := store.NewOAuthCode()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	code := &store.OAuthCode{
+		ClientID:      client.ID,
+		UserID:        getUserFromContext(r).ID,
+		RedirectURI:   payload.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: payload.CodeChallenge,
+		ExpiresAt:     time.Now().Add(app.config.oauth.codeTTL),
+	}
+	if err := app.store.OAuth.CreateCode(ctx, code, secret); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	q := redirect.Query()
+	q.Set("code", secret)
+	if payload.State != "" {
+		q.Set("state", payload.State)
+	}
+	redirect.RawQuery = q.Encode()
+	if err := app.jsonResponse(w, http.StatusOK, OAuthRedirect{RedirectURI: redirect.String()}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// OAuthToken is the RFC 6749 token response, sent without the data envelope.
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// oauthError answers the token endpoint in the RFC 6749 error format.
+func (app *application) oauthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, map[string]string{"error": code, "error_description": description})
+}
+
+// OAuthTokenExchange godoc
+//
+//	@Summary		Exchange a code for a token
+//	@Description	Authorization code grant. The client authenticates with HTTP basic auth or client_id and client_secret form fields. The response follows RFC 6749 and isn't wrapped in data
+//	@Tags			oauth
+//	@Accept			x-www-form-urlencoded
+//	@Produce		json
+//	@Param			grant_type		formData	string	true	"authorization_code"
+//	@Param			code			formData	string	true	"Code from the redirect"
+//	@Param			redirect_uri	formData	string	true	"Redirect URI used to authorize"
+//	@Param			client_id		formData	string	false	"Client ID"
+//	@Param			client_secret	formData	string	false	"Client secret"
+//	@Param			code_verifier	formData	string	false	"PKCE verifier"
+//	@Success		200				{object}	OAuthToken
+//	@Failure		400				{object}	error
+//	@Failure		401				{object}	error
+//	@Failure		500				{object}	error
+//	@Router			/oauth/token [post]
+func (app *application) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<16)
+	if err := r.ParseForm(); err != nil {
+		app.oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if grant := r.PostForm.Get("grant_type"); grant != "authorization_code" {
+		app.oauthError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	ctx := r.Context()
+	client, err := app.store.OAuth.AuthenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidClient) {
+			app.oauthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	code, err := app.store.OAuth.ExchangeCode(ctx, client.ID, r.PostForm.Get("code"))
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			app.oauthError(w, http.StatusBadRequest, "invalid_grant", "the code is invalid, expired or already used")
+			return
+		}
+		app.internalServerError(w, r, err)
+		return
+	}
+	if code.RedirectURI != r.PostForm.Get("redirect_uri") {
+		app.oauthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri doesn't match the authorization request")
+		return
+	}
+	if code.CodeChallenge != "" {
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(challenge), []byte(code.CodeChallenge)) != 1 {
+			app.oauthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier doesn't match the code challenge")
+			return
+		}
+	}
+
+	scope := strings.Join(code.Scopes, " ")
+	claims := jwt.MapClaims{
+		"sub":       code.UserID,
+		"exp":       time.Now().Add(app.config.oauth.tokenExp).Unix(),
+		"iat":       time.Now().Unix(),
+		"nbf":       time.Now().Unix(),
+		"iss":       app.config.auth.token.iss,
+		"aud":       app.config.auth.token.iss,
+		"client_id": client.ClientID,
+		"grant_id":  code.GrantID,
+		"scope":     scope,
+	}
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("oauth token issued", "client_id", client.ClientID, "user_id", code.UserID, "scope", scope)
+	w.Header().Set("Cache-Control", "no-store")
+	resp := OAuthToken{AccessToken: token, TokenType: "Bearer", ExpiresIn: int(app.config.oauth.tokenExp.Seconds()), Scope: scope}
+	if err := writeJSON(w, http.StatusOK, resp); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListOAuthGrants godoc
+//
+//	@Summary		List authorized apps
+//	@Description	The apps the authenticated user authorized to act for them, most recently authorized first
+//	@Tags			oauth
+//	@Produce		json
+//	@Success		200	{object}	[]store.OAuthGrant
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/grants [get]
+func (app *application) listOAuthGrantsHandler(w http.ResponseWriter, r *http.Request) {
+	grants, err := app.store.OAuth.ListGrants(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, grants); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeOAuthGrant godoc
+//
+//	@Summary		Revoke an app's access
+//	@Description	Withdraws the authenticated user's authorization of the app, the tokens it holds for them stop working right away
+//	@Tags			oauth
+//	@Param			clientID	path	string	true	"Client ID"
+//	@Success		204
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/oauth/grants/{clientID} [delete]
+func (app *application) revokeOAuthGrantHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	clientID := chi.URLParam(r, "clientID")
+	if err := app.store.OAuth.RevokeGrant(r.Context(), user.ID, clientID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.logger.Infow("oauth grant revoked", "client_id", clientID, "user_id", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pruneOAuthCodes drops expired authorization codes.
+func (app *application) pruneOAuthCodes(ctx context.Context) error {
+	return app.store.OAuth.PruneCodes(ctx)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	app := NewTestApplication(t, config{oauth: oauthConfig{codeTTL: time.Minute, tokenExp: time.Hour}})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+	exchange := func(t *testing.T, form url.Values) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/oauth/token", strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should only register https or loopback redirect URIs", func(t *testing.T) {
+		for _, uri := range []string{"http://app.example.com/cb", "https://app.example.com/cb#frag", "myapp:/cb", "/cb"} {
+			rr := request(t, http.MethodPost, "/v1/oauth/clients", `{"name":"reader","redirect_uris":["`+uri+`"],"scopes":["read"]}`)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		}
+		for _, uri := range []string{"http://localhost:8765/cb", "http://127.0.0.1/cb", "http://[::1]:9000/cb"} {
+			if !validRedirectURI(uri) {
+				t.Errorf("expected %s to be accepted", uri)
+			}
+		}
+	})
+
+	rr := request(t, http.MethodPost, "/v1/oauth/clients", `{"name":"reader","redirect_uris":["https://app.example.com/cb"],"scopes":["read"]}`)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+	registered := decodeData[OAuthClientRegistered](t, rr.Body.String())
+	clientID := registered.Client.ClientID
+
+	t.Run("should reject unregistered redirect URIs and scopes", func(t *testing.T) {
+		q := url.Values{"client_id": {clientID}, "redirect_uri": {"https://evil.example.com/cb"}}
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/oauth/authorize?"+q.Encode(), "").Code)
+		q = url.Values{"client_id": {clientID}, "redirect_uri": {"https://app.example.com/cb"}, "scope": {"write"}}
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodGet, "/v1/oauth/authorize?"+q.Encode(), "").Code)
+	})
+
+	t.Run("should exchange a code once for a token", func(t *testing.T) {
+		verifier := strings.Repeat("v", 43)
+		sum := sha256.Sum256([]byte(verifier))
+		challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+		rr := request(t, http.MethodPost, "/v1/oauth/authorize", `{"client_id":"`+clientID+`","redirect_uri":"https://app.example.com/cb","state":"xyz",
+			"code_challenge":"`+challenge+`","code_challenge_method":"S256"}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		redirect, err := url.Parse(decodeData[OAuthRedirect](t, rr.Body.String()).RedirectURI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if redirect.Query().Get("state") != "xyz" {
+			t.Errorf("expected the state to be passed back, got %q", redirect.RawQuery)
+		}
+
+		form := url.Values{
+			"grant_type":    {"authorization_code"},
+			"code":          {redirect.Query().Get("code")},
+			"redirect_uri":  {"https://app.example.com/cb"},
+			"client_id":     {clientID},
+			"client_secret": {"wrong"},
+			"code_verifier": {verifier},
+		}
+		checkResponseCode(t, http.StatusUnauthorized, exchange(t, form).Code)
+
+		form.Set("client_secret", registered.ClientSecret)
+		rr = exchange(t, form)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if !strings.Contains(rr.Body.String(), `"token_type":"Bearer"`) {
+			t.Errorf("unexpected token response %s", rr.Body.String())
+		}
+		checkResponseCode(t, http.StatusBadRequest, exchange(t, form).Code)
+	})
+
+	t.Run("should stop the app's tokens once the user revokes the grant", func(t *testing.T) {
+		// The test authenticator ignores the claims it is given, so sign the
+		// token the exchange would have issued by hand.
+		grant, err := app.store.OAuth.GetGrant(context.Background(), 42, clientID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		accessToken := signTestToken(t, jwt.MapClaims{
+			"sub": 42, "exp": time.Now().Add(time.Hour).Unix(), "client_id": clientID, "grant_id": grant.ID, "scope": "read",
+		})
+		asApp := func() int {
+			req, err := http.NewRequest(http.MethodGet, "/v1/oauth/grants", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+accessToken)
+			return executeRequest(req, app.mount()).Code
+		}
+		// Scoped tokens can't manage grants, but get past authentication.
+		checkResponseCode(t, http.StatusForbidden, asApp())
+
+		rr := request(t, http.MethodGet, "/v1/oauth/grants", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if grants := decodeData[[]store.OAuthGrant](t, rr.Body.String()); len(grants) != 1 || grants[0].ClientID != clientID {
+			t.Fatalf("unexpected grants %+v", grants)
+		}
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/oauth/grants/"+clientID, "").Code)
+		checkResponseCode(t, http.StatusUnauthorized, asApp())
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/oauth/grants/"+clientID, "").Code)
+	})
+
+	t.Run("should limit delegated tokens to their scopes", func(t *testing.T) {
+		read := store.ExpandScopes([]string{"read"})
+		cases := []struct {
+			method, path string
+			scopes       []string
+			want         bool
+		}{
+			{http.MethodGet, "/v1/users/feed", read, true},
+			{http.MethodPost, "/v1/posts", read, false},
+			{http.MethodPost, "/v1/posts", store.ExpandScopes([]string{"read", "write"}), true},
+			{http.MethodGet, "/v1/oauth/clients", read, false},
+			{http.MethodGet, "/v1/admin/holds", store.ExpandScopes([]string{"write"}), false},
+		}
+		for _, c := range cases {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			if got := scopesAllow(req, c.scopes); got != c.want {
+				t.Errorf("%s %s: expected %v, got %v", c.method, c.path, c.want, got)
+			}
+		}
+	})
+
+	t.Run("should revoke the client", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/oauth/clients/"+clientID, "").Code)
+		if _, err := app.store.OAuth.GetClient(context.Background(), clientID); !errors.Is(err, store.ErrRecordNotFound) {
+			t.Errorf("expected the client to be gone, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// pagesConfig serves minimal HTML pages for public posts and profiles at
+// /posts/{id} and /users/{id}, the paths of the frontend. Pointing the
+// frontend URL at the API makes shared links unfurl before a frontend
+// exists. siteName is shown in link previews.
+type pagesConfig struct {
+	enabled  bool
+	siteName string
+}
+
+func (app *application) mountPages(r chi.Router) {
+	r.Group(func(r chi.Router) {
+		r.Use(app.cacheResponse(app.config.publicCacheTTL))
+		r.Get("/posts/{postID}", app.postPageHandler)
+		r.Get("/users/{userID}", app.profilePageHandler)
+	})
+}
+
+// postPageHandler serves a public post as a page for link previews.
+func (app *application) postPageHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.loadPublicPost(w, r)
+	if !ok {
+		return
+	}
+	if err := app.writeHTML(w, app.postSEOPage(post)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// profilePageHandler serves a public profile as a page for link previews.
+func (app *application) profilePageHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.loadPublicProfile(w, r)
+	if !ok {
+		return
+	}
+	if err := app.writeHTML(w, app.profileSEOPage(profile)); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// imagePostStore serves posts embedding an image.
+type imagePostStore struct {
+	store.MockPostStore
+}
+
+func (m *imagePostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{
+		ID: id, UserID: 42, Title: "Gopher art", Content: "Look ![gopher](https://cdn.example/gopher.png) and more",
+		Kind: store.PostKindNote, Tags: []string{"art"}, CreatedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC), User: store.User{ID: 42, Username: "gopher"},
+	}, nil
+}
+
+func TestPages(t *testing.T) {
+	get := func(t *testing.T, app *application, path string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, app.mount())
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should be off by default", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		if code, _ := get(t, app, "/posts/1"); code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", code)
+		}
+	})
+
+	app := NewTestApplication(t, config{
+		frontendURL: "https://api.gophers.example",
+		pages:       pagesConfig{enabled: true, siteName: "GopherSocial"},
+	})
+	app.store.Posts = &imagePostStore{}
+
+	t.Run("should render post cards", func(t *testing.T) {
+		code, body := get(t, app, "/posts/1")
+		checkResponseCode(t, http.StatusOK, code)
+		for _, want := range []string{
+			`<meta property="og:site_name" content="GopherSocial">`,
+			`<meta property="og:image" content="https://cdn.example/gopher.png">`,
+			`<meta name="twitter:card" content="summary_large_image">`,
+			`<meta name="twitter:title" content="Gopher art">`,
+			`by <a href="https://api.gophers.example/users/42">gopher</a> on <time datetime="2026-10-01T12:00:00Z">`,
+			`<a href="https://api.gophers.example/tags/art">#art</a>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected %s in %s", want, body)
+			}
+		}
+	})
+
+	t.Run("should render profile cards", func(t *testing.T) {
+		code, body := get(t, app, "/users/42")
+		checkResponseCode(t, http.StatusOK, code)
+		if !strings.Contains(body, `<meta name="twitter:card" content="summary">`) || !strings.Contains(body, `<meta property="og:type" content="profile">`) {
+			t.Errorf("expected a profile card, got %s", body)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"time"
+)
+
+// partitionsConfig keeps the monthly partitions of store.PartitionedTables
+// ahead months in advance. retention maps a table to the months of rows it
+// keeps, tables missing from it or set to 0 are never pruned.
+type partitionsConfig struct {
+	interval  time.Duration
+	ahead     int
+	retention map[string]int
+}
+
+// maintainPartitions creates the partitions for this month and the coming
+// ones, then drops those entirely past retention.
+func (app *application) maintainPartitions(ctx context.Context) error {
+	now := time.Now()
+	var errs []error
+	for _, table := range store.PartitionedTables {
+		existing, err := app.store.Partitions.List(ctx, table)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		have := make(map[string]bool, len(existing))
+		for _, p := range existing {
+			have[p.Name] = true
+		}
+		month := store.MonthPartition(table, now).From
+		for i := 0; i <= app.config.partitions.ahead; i++ {
+			p := store.MonthPartition(table, month.AddDate(0, i, 0))
+			if have[p.Name] {
+				continue
+			}
+			if err := app.store.Partitions.Create(ctx, p); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			app.logger.Infow("created partition", "partition", p.Name)
+		}
+
+		months := app.config.partitions.retention[table]
+		if months <= 0 {
+			continue
+		}
+		cutoff := now.AddDate(0, -months, 0)
+		for _, p := range existing {
+			if p.To.After(cutoff) {
+				continue
+			}
+			if err := app.store.Partitions.Drop(ctx, p); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			app.logger.Infow("dropped partition", "partition", p.Name)
+		}
+	}
+	return errors.Join(errs...)
+}
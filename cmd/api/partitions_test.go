@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"testing"
+	"time"
+)
+
+func TestMaintainPartitions(t *testing.T) {
+	now := time.Now()
+	old := store.MonthPartition("post_impressions", now.AddDate(0, -14, 0))
+	recent := store.MonthPartition("post_impressions", now.AddDate(0, -2, 0))
+	current := store.MonthPartition("post_impressions", now)
+
+	t.Run("should create partitions ahead and keep everything without retention", func(t *testing.T) {
+		app := NewTestApplication(t, config{partitions: partitionsConfig{ahead: 2}})
+		partitions := &store.MockPartitionStore{Partitions: []store.Partition{old, current}}
+		app.store.Partitions = partitions
+		if err := app.maintainPartitions(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(partitions.Partitions) != 4 {
+			t.Fatalf("got %d partitions, want 4: %+v", len(partitions.Partitions), partitions.Partitions)
+		}
+		next := store.MonthPartition("post_impressions", now.AddDate(0, 2, 0))
+		if !partitions.Partitions[3].From.Equal(next.From) {
+			t.Fatalf("last partition starts %v, want %v", partitions.Partitions[3].From, next.From)
+		}
+	})
+
+	t.Run("should drop partitions past retention", func(t *testing.T) {
+		app := NewTestApplication(t, config{partitions: partitionsConfig{
+			retention: map[string]int{"post_impressions": 12},
+		}})
+		partitions := &store.MockPartitionStore{Partitions: []store.Partition{old, recent, current}}
+		app.store.Partitions = partitions
+		if err := app.maintainPartitions(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		want := []store.Partition{recent, current}
+		if len(partitions.Partitions) != len(want) || partitions.Partitions[0] != recent || partitions.Partitions[1] != current {
+			t.Fatalf("got %+v, want %+v", partitions.Partitions, want)
+		}
+	})
+
+	t.Run("should name partitions by UTC month", func(t *testing.T) {
+		p := store.MonthPartition("post_impressions", time.Date(2026, 1, 31, 23, 30, 0, 0, time.FixedZone("", -2*3600)))
+		if p.Name != "post_impressions_p202602" || !p.To.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Fatalf("unexpected partition %+v", p)
+		}
+	})
+}
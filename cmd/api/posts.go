@@ -3,9 +3,14 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"gopher_social/internal/events"
+	"gopher_social/internal/lang"
 	"gopher_social/internal/store"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -15,9 +20,42 @@ type postKey string
 const postCtx postKey = "post"
 
 type CreatePostPayload struct {
-	Title   string   `json:"title" validate:"required,max=100"`
-	Content string   `json:"content" validate:"required,max=1000"`
+	Title   string   `json:"title" validate:"required,max=1000"`
+	Content string   `json:"content" validate:"required,max=100000"`
 	Tags    []string `json:"tags"`
+	// ContentWarning hides the body behind a short label, e.g. a spoiler.
+	ContentWarning string `json:"content_warning" validate:"max=200"`
+	// AgeRestricted shows the post to adults only.
+	AgeRestricted bool `json:"age_restricted"`
+	// Kind "article" publishes a long form post: Content becomes the summary
+	// shown in feeds and Body the full markdown served by /posts/{id}/body.
+	// Kind "question" takes answers, see CreateCommentPayload.
+	Kind string `json:"kind" validate:"omitempty,oneof=note article question"`
+	Body string `json:"body" validate:"required_if=Kind article,max=1000000"`
+	// AsUserID lets admins publish on behalf of another account.
+	AsUserID *int64 `json:"as_user_id,omitempty" validate:"omitempty,gte=1"`
+	// Snippets attach highlighted code, whole Go programs get a playground
+	// link.
+	Snippets []SnippetPayload `json:"snippets" validate:"max=4,dive"`
+	// NoCrosspost keeps the post off the author's connected networks.
+	NoCrosspost bool `json:"no_crosspost"`
+}
+
+const (
+	duplicatePostsOff  = "off"
+	duplicatePostsWarn = "warn"
+	duplicatePostsDeny = "deny"
+)
+
+// duplicateOfHeader carries the ID of the earlier post when a duplicate is
+// accepted in warn mode.
+const duplicateOfHeader = "X-Duplicate-Of"
+
+// PostDetail is a single post together with its author, comments and the
+// reaction state relevant to the requesting user.
+type PostDetail struct {
+	*store.Post
+	Reactions *store.ReactionSummary `json:"reactions"`
 }
 
 // CreatePost godoc
@@ -33,6 +71,8 @@ type CreatePostPayload struct {
 //	@Success		201		{object}	store.Post
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		409		{object}	error	"Duplicate of a recent post"
+//	@Failure		429		{object}	error	"Risky account posting too often"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts [post]
@@ -46,16 +86,83 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestResponse(w, r, err)
 		return
 	}
-	user := getUserFromContext(r)
-	post := &store.Post{
-		Title:   payload.Title,
-		Content: payload.Content,
-		Tags:    payload.Tags,
-		UserID:  user.ID,
+	if err := app.checkPostLimits(&payload.Title, &payload.Content, &payload.Body); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
 	}
 	ctx := r.Context()
+	authorID, err := app.resolvePostAuthor(ctx, getUserFromContext(r), payload.AsUserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errPostAsForbidden):
+			app.forbiddenResponse(w, r)
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.badRequestResponse(w, r, errors.New("as_user_id does not match an active user"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	post := &store.Post{
+		Title:          payload.Title,
+		Content:        payload.Content,
+		Tags:           payload.Tags,
+		UserID:         authorID,
+		Lang:           lang.Detect(payload.Title + "\n" + payload.Content + "\n" + payload.Body),
+		ContentWarning: payload.ContentWarning,
+		AgeRestricted:  payload.AgeRestricted,
+		NoCrosspost:    payload.NoCrosspost,
+	}
 
-	if err := app.store.Posts.Create(ctx, post); err != nil {
+	retryAfter, err := app.postThrottle(ctx, authorID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	post.Fingerprint = store.PostFingerprint(post.Title, post.Content)
+	duplicateID, err := app.findDuplicatePost(ctx, post)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if duplicateID != 0 {
+		if app.config.posts.duplicateMode == duplicatePostsDeny {
+			app.duplicatePostResponse(w, r, duplicateID)
+			return
+		}
+		w.Header().Set(duplicateOfHeader, strconv.FormatInt(duplicateID, 10))
+	}
+	if app.config.posts.duplicateMode == duplicatePostsDeny {
+		// Checked again under a lock as the post is stored, in case an
+		// identical submission is racing this one.
+		post.DuplicateWindow = app.config.posts.duplicateWindow
+	}
+	post.Snippets = app.newSnippets(ctx, payload.Snippets)
+
+	switch payload.Kind {
+	case store.PostKindArticle:
+		err = app.store.Posts.CreateArticle(ctx, post, payload.Body)
+	case store.PostKindQuestion:
+		err = app.store.Questions.Create(ctx, post)
+	default:
+		err = app.store.Posts.Create(ctx, post)
+	}
+	var duplicate *store.DuplicatePostError
+	if errors.As(err, &duplicate) {
+		app.duplicatePostResponse(w, r, duplicate.ExistingID)
+		return
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.events.Publish(ctx, events.PostCreated{Post: post})
+	if err := app.renderPost(post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -73,28 +180,157 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 //	@Accept			json
 //	@Produce		json
 //
-//	@Param			postID	path		int	true	"Post ID"
+//	@Param			postID	path		int		true	"Post ID"
 //
-//	@Success		200		{object}	store.Post
+//	@Param			src		query		string	false	"Where the post was opened from: direct, feed, profile or hashtag"
+//
+//	@Success		200		{object}	PostDetail
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
 //	@Failure		404		{object}	error
+//	@Failure		410		{object}	error	"Removed by a moderator, the body holds the tombstone"
 //	@Failure		500		{object}	error
 //	@Security		ApiKeyAuth
 //	@Router			/posts/{postID} [get]
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromCtx(r)
+	ctx := r.Context()
 
-	comments, err := app.store.Comments.GetByPostID(r.Context(), post.ID)
+	comments, err := app.visibleComments(ctx, post, getUserFromContext(r))
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 	post.Comments = comments
-	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
+	if err := app.attachPostExtras(ctx, []*store.Post{post}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderPost(post); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	reactions, err := app.store.Reactions.Summary(ctx, store.ReactionSubjectPost, post.ID, getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.recordImpression(r, post)
+	detail := PostDetail{
+		Post:      post,
+		Reactions: reactions,
+	}
+	if err := app.jsonResponse(w, http.StatusOK, detail); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+const maxBatchPostIDs = 100
+
+// GetPostsByIDs godoc
+//
+//	@Summary		Fetches posts in batch
+//	@Description	Fetches up to 100 posts by ID in a single request, missing posts are skipped
+//	@Tags			posts
+//	@Produce		json
+//
+//	@Param			ids	query		string	true	"Comma separated post IDs"
+//
+//	@Success		200	{object}	[]store.Post
+//	@Failure		400	{object}	error
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts [get]
+func (app *application) getPostsByIDsHandler(w http.ResponseWriter, r *http.Request) {
+	ids, err := parseIDList(r.URL.Query().Get("ids"), maxBatchPostIDs)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	posts, err := app.store.Posts.GetByIDs(r.Context(), ids)
+	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+	viewer := getUserFromContext(r)
+	posts = slices.DeleteFunc(posts, func(p store.Post) bool {
+		return !app.canViewAgeRestricted(viewer, &p)
+	})
+	if err := app.hydratePostAuthors(r.Context(), posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	attached := make([]*store.Post, len(posts))
+	for i := range posts {
+		attached[i] = &posts[i]
+	}
+	if err := app.attachPostExtras(r.Context(), attached); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range posts {
+		if err := app.renderPost(&posts[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// parseIDList parses a comma separated list of IDs, dropping duplicates.
+func parseIDList(raw string, max int) ([]int64, error) {
+	if raw == "" {
+		return nil, errors.New("ids is required")
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) > max {
+		return nil, fmt.Errorf("at most %d ids can be requested at once", max)
+	}
+	seen := make(map[int64]bool, len(parts))
+	ids := make([]int64, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// parseLimitOffset reads the limit and offset query parameters, limit
+// defaults to def and may not exceed max.
+func parseLimitOffset(r *http.Request, def, max int) (int, int, error) {
+	limit, offset := def, 0
+	qs := r.URL.Query()
+	if raw := qs.Get("limit"); raw != "" {
+		l, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, err
+		}
+		limit = l
+	}
+	if raw := qs.Get("offset"); raw != "" {
+		o, err := strconv.Atoi(raw)
+		if err != nil {
+			return 0, 0, err
+		}
+		offset = o
+	}
+	if err := Validate.Var(limit, fmt.Sprintf("gte=1,lte=%d", max)); err != nil {
+		return 0, 0, err
+	}
+	if err := Validate.Var(offset, "gte=0"); err != nil {
+		return 0, 0, err
+	}
+	return limit, offset, nil
 }
 
 // DeletePost godoc
@@ -105,7 +341,8 @@ func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 //	@Accept			json
 //	@Produce		json
 //
-//	@Param			postID	path		int	true	"Post ID"
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			reason	query		string	false	"Why a moderator removed the post, shown on its tombstone"
 //
 //	@Success		204		{object}	string
 //	@Failure		400		{object}	error
@@ -123,23 +360,55 @@ func (app *application) deletePostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	reason := r.URL.Query().Get("reason")
+	if err := Validate.Var(reason, "max=500"); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 	ctx := r.Context()
 	err = app.store.Posts.Delete(ctx, int64(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrRecordNotFound):
 			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrUnderLegalHold):
+			app.conflictResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
 		}
 		return
 	}
+	// Removal by someone other than the author is enforcement, appealable,
+	// and leaves a tombstone in place of the post.
+	if post, actor := getPostFromCtx(r), getUserFromContext(r); post.UserID != actor.ID {
+		c := &store.ModerationCase{
+			UserID:      post.UserID,
+			Action:      store.ModerationPostRemoved,
+			SubjectType: store.CaseSubjectPost,
+			SubjectID:   post.ID,
+			ModeratorID: actor.ID,
+			Reason:      reason,
+		}
+		app.openModerationCase(ctx, c)
+		tombstone := &store.PostTombstone{PostID: post.ID, UserID: post.UserID, Reason: reason}
+		if c.ID != 0 {
+			tombstone.CaseID = &c.ID
+		}
+		if err := app.store.Tombstones.Create(ctx, tombstone); err != nil {
+			app.logger.Errorw("error leaving post tombstone", "post_id", post.ID, "error", err.Error())
+		}
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 type UpdatePostPayload struct {
-	Title   *string `json:"title" validate:"omitempty,max=100"`
-	Content *string `json:"content" validate:"omitempty,max=1000"`
+	Title   *string `json:"title" validate:"omitempty,max=1000"`
+	Content *string `json:"content" validate:"omitempty,max=100000"`
+	// ContentWarning replaces the warning, an empty string removes it.
+	ContentWarning *string `json:"content_warning" validate:"omitempty,max=200"`
+	AgeRestricted  *bool   `json:"age_restricted"`
+	// Body replaces the full body of an article.
+	Body *string `json:"body" validate:"omitempty,max=1000000"`
 }
 
 // UpdatePost godoc
@@ -172,6 +441,10 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 		app.badRequestResponse(w, r, err)
 		return
 	}
+	if err := app.checkPostLimits(payload.Title, payload.Content, payload.Body); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
 
 	if payload.Title != nil {
 		post.Title = *payload.Title
@@ -179,7 +452,35 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	if payload.Content != nil {
 		post.Content = *payload.Content
 	}
-	if err := app.updatePost(r.Context(), post); err != nil {
+	if payload.ContentWarning != nil {
+		post.ContentWarning = *payload.ContentWarning
+	}
+	if payload.AgeRestricted != nil {
+		post.AgeRestricted = *payload.AgeRestricted
+	}
+	if payload.Body != nil && post.Kind != store.PostKindArticle {
+		app.badRequestResponse(w, r, errors.New("only articles have a body"))
+		return
+	}
+	ctx := r.Context()
+	if payload.Title != nil || payload.Content != nil || payload.Body != nil {
+		// Detected as on creation, from the article's body too.
+		text := post.Title + "\n" + post.Content
+		if post.Kind == store.PostKindArticle {
+			body, err := app.articleBody(ctx, post.ID, payload.Body)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			text += "\n" + body
+		}
+		post.Lang = lang.Detect(text)
+	}
+	if err := app.updatePost(ctx, post, payload.Body); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderPost(post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -189,6 +490,64 @@ func (app *application) updatePostHandler(w http.ResponseWriter, r *http.Request
 	}
 
 }
+
+// findDuplicatePost returns the ID of an identical post the same author
+// published within the configured window, or 0 if there is none. Mobile
+// clients retrying a timed out request are the usual source.
+func (app *application) findDuplicatePost(ctx context.Context, post *store.Post) (int64, error) {
+	if app.config.posts.duplicateMode == duplicatePostsOff || app.config.posts.duplicateMode == "" {
+		return 0, nil
+	}
+	id, err := app.store.Posts.FindRecentDuplicate(ctx, post.UserID, post.Fingerprint, app.config.posts.duplicateWindow)
+	if errors.Is(err, store.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return id, err
+}
+
+// GetPostBody godoc
+//
+//	@Summary		Fetches an article body
+//	@Description	Fetches the full markdown body of a long form post
+//	@Tags			posts
+//	@Produce		json
+//
+//	@Param			postID	path		int	true	"Post ID"
+//
+//	@Success		200		{object}	store.PostBody
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/body [get]
+func (app *application) getPostBodyHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if post.Kind != store.PostKindArticle {
+		app.notFoundResponse(w, r, errors.New("post has no separate body"))
+		return
+	}
+	body, err := app.store.Posts.GetBody(r.Context(), post.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	body.Body = app.signPostMedia(body.Body, post.UserID)
+	html, err := app.markup.Render(body.Body)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	body.BodyHTML = html
+	if err := app.jsonResponse(w, http.StatusOK, body); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
 func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		idParams := chi.URLParam(r, "postID")
@@ -204,22 +563,146 @@ func (app *application) postsContextMiddleware(next http.Handler) http.Handler {
 		if err != nil {
 			switch {
 			case errors.Is(err, store.ErrRecordNotFound):
-				app.notFoundResponse(w, r, err)
+				tombstone, ok := app.lookupTombstone(w, r, id, err)
+				if !ok {
+					return
+				}
+				// Comments of a removed post stay readable so threads
+				// linking into them don't break.
+				if r.Method == http.MethodGet && strings.HasSuffix(strings.TrimSuffix(r.URL.Path, "/"), "/comments") {
+					post := &store.Post{ID: tombstone.PostID, UserID: tombstone.UserID}
+					next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, postCtx, post)))
+					return
+				}
+				app.postRemovedResponse(w, r, tombstone)
 			default:
 				app.internalServerError(w, r, err)
 			}
 			return
 		}
+		// Stories are only served by /stories, which enforces their expiry
+		// and records views.
+		if post.Kind == store.PostKindStory {
+			app.notFoundResponse(w, r, store.ErrRecordNotFound)
+			return
+		}
+		// Listings awaiting review, rejected or expired stay hidden here as
+		// they are under /listings.
+		if post.Kind == store.PostKindListing {
+			listing, err := app.store.Listings.Get(ctx, post.ID)
+			if err != nil {
+				switch {
+				case errors.Is(err, store.ErrRecordNotFound):
+					app.notFoundResponse(w, r, err)
+				default:
+					app.internalServerError(w, r, err)
+				}
+				return
+			}
+			visible, err := app.canViewListing(ctx, getUserFromContext(r), listing)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if !visible {
+				app.notFoundResponse(w, r, store.ErrRecordNotFound)
+				return
+			}
+		}
+		// Held posts are hidden from everyone but admins, who may read but
+		// not change them.
+		if post.OnHold {
+			isAdmin, err := app.checkRolePrecedence(ctx, getUserFromContext(r), "admin")
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+			if !isAdmin {
+				app.notFoundResponse(w, r, store.ErrRecordNotFound)
+				return
+			}
+			if r.Method != http.MethodGet {
+				app.conflictResponse(w, r, store.ErrUnderLegalHold)
+				return
+			}
+		}
+		if !app.canViewAgeRestricted(getUserFromContext(r), post) {
+			app.forbiddenResponse(w, r)
+			return
+		}
 		ctx = context.WithValue(ctx, postCtx, post)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// lookupTombstone finds the tombstone of a post that is gone, answering not
+// found itself when the post wasn't removed by a moderator.
+func (app *application) lookupTombstone(w http.ResponseWriter, r *http.Request, postID int64, notFound error) (*store.PostTombstone, bool) {
+	tombstone, err := app.store.Tombstones.Get(r.Context(), postID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, notFound)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	return tombstone, true
+}
+
 func getPostFromCtx(r *http.Request) *store.Post {
 	post, _ := r.Context().Value(postCtx).(*store.Post)
 	return post
 }
-func (app *application) updatePost(ctx context.Context, post *store.Post) error {
-	if err := app.store.Posts.Update(ctx, post); err != nil {
+
+var errPostAsForbidden = errors.New("only admins can post as another user")
+
+// resolvePostAuthor returns who a new post is attributed to. It is always the
+// authenticated user unless an admin explicitly posts as someone else, which
+// is audit logged.
+func (app *application) resolvePostAuthor(ctx context.Context, user *store.User, asUserID *int64) (int64, error) {
+	if asUserID == nil || *asUserID == user.ID {
+		return user.ID, nil
+	}
+	allowed, err := app.checkRolePrecedence(ctx, user, "admin")
+	if err != nil {
+		return 0, err
+	}
+	if !allowed {
+		app.auditLog("post.create_as.denied", user.ID, "as_user_id", *asUserID)
+		return 0, errPostAsForbidden
+	}
+	if _, err := app.store.Users.GetByID(ctx, *asUserID); err != nil {
+		return 0, err
+	}
+	app.auditLog("post.create_as", user.ID, "as_user_id", *asUserID)
+	return *asUserID, nil
+}
+
+// articleBody returns the body an article update leaves it with, the new one
+// when given or else the stored one.
+func (app *application) articleBody(ctx context.Context, postID int64, updated *string) (string, error) {
+	if updated != nil {
+		return *updated, nil
+	}
+	body, err := app.store.Posts.GetBody(ctx, postID)
+	if err != nil {
+		return "", err
+	}
+	return body.Body, nil
+}
+
+// updatePost saves the post, together with the article body when one is
+// given.
+func (app *application) updatePost(ctx context.Context, post *store.Post, body *string) error {
+	var err error
+	if body != nil {
+		err = app.store.Posts.UpdateArticle(ctx, post, *body)
+	} else {
+		err = app.store.Posts.Update(ctx, post)
+	}
+	if err != nil {
 		return err
 	}
 	app.cacheStorage.Users.Delete(ctx, post.ID)
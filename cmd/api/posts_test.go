@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type adminUserStore struct {
+	store.MockUserStore
+}
+
+func (m *adminUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return &store.User{
+		ID:   id,
+		Role: &store.Role{Name: "admin", Level: 3},
+	}, nil
+}
+
+func decodeData[T any](t *testing.T, body string) T {
+	t.Helper()
+	var envelope struct {
+		Data T `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	return envelope.Data
+}
+
+func TestCreatePostAttribution(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should attribute the post to the authenticated user", func(t *testing.T) {
+		body := `{"title":"hello","content":"gophers"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		post := decodeData[store.Post](t, rr.Body.String())
+		if post.UserID != 42 {
+			t.Errorf("expected post to be attributed to user 42, got %d", post.UserID)
+		}
+	})
+
+	t.Run("should not allow regular users to post as someone else", func(t *testing.T) {
+		body := `{"title":"hello","content":"gophers","as_user_id":7}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should allow admins to post as another user", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		app.store.Users = &adminUserStore{}
+		mux := app.mount()
+
+		body := `{"title":"hello","content":"gophers","as_user_id":7}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		post := decodeData[store.Post](t, rr.Body.String())
+		if post.UserID != 7 {
+			t.Errorf("expected post to be attributed to user 7, got %d", post.UserID)
+		}
+	})
+}
+
+func TestCreateCommentAttribution(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should attribute the comment to the authenticated user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments/", strings.NewReader(`{"content":"nice"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		comment := decodeData[store.Comment](t, rr.Body.String())
+		if comment.UserID != 42 || comment.PostID != 1 {
+			t.Errorf("expected comment by user 42 on post 1, got user %d post %d", comment.UserID, comment.PostID)
+		}
+	})
+
+	t.Run("should reject a client supplied user id", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments/", strings.NewReader(`{"content":"nice","user_id":7}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestCreateArticle(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should require a body for articles", func(t *testing.T) {
+		body := `{"title":"hello","content":"summary","kind":"article"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should create an article", func(t *testing.T) {
+		body := `{"title":"hello","content":"summary","kind":"article","body":"# the long read"}`
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+
+		post := decodeData[store.Post](t, rr.Body.String())
+		if post.Kind != store.PostKindArticle {
+			t.Errorf("expected kind %q, got %q", store.PostKindArticle, post.Kind)
+		}
+	})
+}
+
+type duplicatePostStore struct {
+	store.MockPostStore
+}
+
+func (m *duplicatePostStore) FindRecentDuplicate(ctx context.Context, userID int64, fingerprint string, window time.Duration) (int64, error) {
+	return 5, nil
+}
+
+// racedPostStore finds no duplicate up front, as if the identical post was
+// committed between the check and the insert.
+type racedPostStore struct {
+	store.MockPostStore
+}
+
+func (m *racedPostStore) FindRecentDuplicate(ctx context.Context, userID int64, fingerprint string, window time.Duration) (int64, error) {
+	return 0, store.ErrRecordNotFound
+}
+
+func (m *racedPostStore) Create(ctx context.Context, post *store.Post) error {
+	if post.DuplicateWindow > 0 {
+		return &store.DuplicatePostError{ExistingID: 6}
+	}
+	return nil
+}
+
+func TestCreateDuplicatePost(t *testing.T) {
+	newRequest := func(t *testing.T, app *application) *http.Request {
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(`{"title":"hello","content":"gophers"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return req
+	}
+
+	t.Run("should reject a duplicate in deny mode", func(t *testing.T) {
+		app := NewTestApplication(t, config{posts: postsConfig{duplicateMode: duplicatePostsDeny, duplicateWindow: time.Minute}})
+		app.store.Posts = &duplicatePostStore{}
+		rr := executeRequest(newRequest(t, app), app.mount())
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+
+		var body struct {
+			ExistingPostID int64 `json:"existing_post_id"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.ExistingPostID != 5 {
+			t.Errorf("expected existing post 5, got %d", body.ExistingPostID)
+		}
+	})
+
+	t.Run("should reject a duplicate racing the check in deny mode", func(t *testing.T) {
+		app := NewTestApplication(t, config{posts: postsConfig{duplicateMode: duplicatePostsDeny, duplicateWindow: time.Minute}})
+		app.store.Posts = &racedPostStore{}
+		rr := executeRequest(newRequest(t, app), app.mount())
+		checkResponseCode(t, http.StatusConflict, rr.Code)
+		if !strings.Contains(rr.Body.String(), `"existing_post_id":6`) {
+			t.Errorf("expected existing post 6, got %s", rr.Body.String())
+		}
+	})
+
+	t.Run("should accept a duplicate in warn mode", func(t *testing.T) {
+		app := NewTestApplication(t, config{posts: postsConfig{duplicateMode: duplicatePostsWarn, duplicateWindow: time.Minute}})
+		app.store.Posts = &duplicatePostStore{}
+		rr := executeRequest(newRequest(t, app), app.mount())
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := rr.Header().Get(duplicateOfHeader); got != "5" {
+			t.Errorf("expected %s header 5, got %q", duplicateOfHeader, got)
+		}
+	})
+}
+
+func TestHideComment(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow other users to hide comments", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments/3/hide", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should allow moderators to hide comments", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		app.store.Users = &adminUserStore{}
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments/3/hide", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+func TestReactToComment(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should accept a known reaction", `{"type":"love"}`, http.StatusOK},
+		{"should reject an unknown reaction", `{"type":"meh"}`, http.StatusBadRequest},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/posts/1/comments/3/reactions", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
+
+type ownedPostStore struct {
+	store.MockPostStore
+}
+
+func (m *ownedPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: id}, nil
+}
+
+func TestPostInsightsAuthorOnly(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.store.Users = &adminUserStore{}
+	app.store.Posts = &ownedPostStore{}
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		postID string
+		want   int
+	}{
+		{"should show the author their insights", "42", http.StatusOK},
+		{"should not show an admin someone else's", "7", http.StatusForbidden},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/posts/"+tc.postID+"/insights", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
+
+type articlePostStore struct {
+	store.MockPostStore
+	body string
+}
+
+func (m *articlePostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: 42, Kind: store.PostKindArticle, Title: "Notes", Content: "Summary"}, nil
+}
+
+func (m *articlePostStore) UpdateArticle(ctx context.Context, post *store.Post, body string) error {
+	m.body = body
+	return nil
+}
+
+func TestUpdateArticle(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	posts := &articlePostStore{}
+	app.store.Posts = posts
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"body":"Los gophers escriben programas concurrentes y los comparten con sus amigos todos los días."}`
+	req, err := http.NewRequest(http.MethodPatch, "/v1/posts/1", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rr := executeRequest(req, mux)
+	checkResponseCode(t, http.StatusOK, rr.Code)
+
+	if !strings.HasPrefix(posts.body, "Los gophers") {
+		t.Errorf("expected the body saved with the post, got %q", posts.body)
+	}
+	if post := decodeData[store.Post](t, rr.Body.String()); post.Lang != "es" {
+		t.Errorf("expected the language detected from the new body, got %q", post.Lang)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/relme"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// profileFieldsConfig paces the rel="me" verification of profile links:
+// batchSize fields every interval, each verified again after recheck.
+type profileFieldsConfig struct {
+	interval  time.Duration
+	recheck   time.Duration
+	timeout   time.Duration
+	batchSize int
+}
+
+type ProfileFieldPayload struct {
+	Name  string `json:"name" validate:"required,max=255"`
+	Value string `json:"value" validate:"required,max=255"`
+}
+
+type ProfileFieldsPayload struct {
+	Fields []ProfileFieldPayload `json:"fields" validate:"max=4,dive"`
+}
+
+// SetProfileFields godoc
+//
+//	@Summary		Set profile fields
+//	@Description	Replaces the custom key/value fields of the profile, up to 4. Values linking to a page that links back to the profile with rel="me" get verified shortly after
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ProfileFieldsPayload	true	"Fields in display order"
+//	@Success		200		{object}	[]store.ProfileField
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/fields [put]
+func (app *application) setProfileFieldsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ProfileFieldsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	fields := make([]store.ProfileField, len(payload.Fields))
+	for i, f := range payload.Fields {
+		fields[i] = store.ProfileField{Name: strings.TrimSpace(f.Name), Value: strings.TrimSpace(f.Value)}
+	}
+
+	ctx := r.Context()
+	userID := getUserFromContext(r).ID
+	if err := app.store.ProfileFields.Set(ctx, userID, fields); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, fields); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// profileURLs are the links back to a user's profile that verify a field.
+func (app *application) profileURLs(userID int64, username string) []string {
+	return []string{
+		fmt.Sprintf("%s/users/%d", app.config.frontendURL, userID),
+		fmt.Sprintf("%s/@%s", app.config.frontendURL, username),
+	}
+}
+
+// verifyProfileFields checks a batch of link fields for a rel="me" link back
+// to their profile. Pages that can't be fetched count as not linking back
+// until the next check.
+func (app *application) verifyProfileFields(ctx context.Context) error {
+	cfg := app.config.profileFields
+	due, err := app.store.ProfileFields.Due(ctx, cfg.recheck, cfg.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, c := range due {
+		verified := false
+		if relme.IsLink(c.Value) {
+			verified, err = app.relme.LinksBack(ctx, c.Value, app.profileURLs(c.UserID, c.Username))
+			if err != nil {
+				app.logger.Infow("error verifying profile link", "user_id", c.UserID, "url", c.Value, "error", err.Error())
+			}
+		}
+		if err := app.store.ProfileFields.Checked(ctx, c, verified); err != nil {
+			return err
+		}
+	}
+	return nil
+}
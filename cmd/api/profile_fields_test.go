@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/relme"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileFields(t *testing.T) {
+	app := NewTestApplication(t, config{frontendURL: "https://social.example", profileFields: profileFieldsConfig{batchSize: 10}})
+	checker := relme.NewChecker(time.Second)
+	checker.AllowPrivate = true
+	app.relme = checker
+	fields := &store.MockProfileFieldStore{}
+	app.store.ProfileFields = fields
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/me":
+			fmt.Fprint(w, `<html><head><link rel="me" href="https://social.example/users/42"></head></html>`)
+		default:
+			fmt.Fprint(w, `<html><body><a href="https://social.example/users/42">not me</a></body></html>`)
+		}
+	}))
+	defer site.Close()
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should take up to four fields", func(t *testing.T) {
+		five := `{"fields":[{"name":"a","value":"1"},{"name":"b","value":"2"},{"name":"c","value":"3"},{"name":"d","value":"4"},{"name":"e","value":"5"}]}`
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/users/me/fields", five).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/users/me/fields", `{"fields":[{"name":"","value":"1"}]}`).Code)
+
+		body := fmt.Sprintf(`{"fields":[{"name":"Pronouns","value":"they/them"},{"name":"Site","value":"%[1]s/me"},{"name":"Blog","value":"%[1]s/blog"}]}`, site.URL)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/users/me/fields", body).Code)
+	})
+
+	t.Run("should verify links that link back with rel=me", func(t *testing.T) {
+		if err := app.verifyProfileFields(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		rr := request(t, http.MethodGet, "/v1/users/42/", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		got := decodeData[store.User](t, rr.Body.String()).Fields
+		if len(got) != 3 || got[0].Name != "Pronouns" {
+			t.Fatalf("expected the 3 fields in order, got %+v", got)
+		}
+		if got[0].VerifiedAt != nil || got[1].VerifiedAt == nil || got[2].VerifiedAt != nil {
+			t.Errorf("expected only the rel=me link to be verified, got %+v", got)
+		}
+
+		rr = request(t, http.MethodGet, "/v1/public/users/42", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if profile := decodeData[store.Profile](t, rr.Body.String()); len(profile.Fields) != 3 {
+			t.Errorf("expected the fields on the public profile, got %+v", profile.Fields)
+		}
+	})
+
+	t.Run("should not fetch private addresses", func(t *testing.T) {
+		ok, err := relme.NewChecker(time.Second).LinksBack(context.Background(), site.URL+"/me", []string{"https://social.example/users/42"})
+		if ok || err == nil {
+			t.Errorf("expected the loopback page to be refused, got %v %v", ok, err)
+		}
+	})
+}
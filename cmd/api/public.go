@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// cacheResponse serves public GET pages from Redis for ttl and tells browsers
+// and CDNs they may do the same. Only successful responses are cached, the
+// pages must be identical for every visitor.
+func (app *application) cacheResponse(ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl.Seconds())))
+			w.Header().Set("Vary", "Accept-Encoding, Accept")
+			if !app.config.redisCfg.enabled || r.Method != http.MethodGet {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			key := r.URL.RequestURI()
+			format := responseFormat(r)
+			if format == formatHTML {
+				key += "#html"
+			}
+			if body, _ := app.cacheStorage.Responses.Get(ctx, key); body != nil {
+				w.Header().Set("Content-Type", formatContentTypes[format])
+				w.Header().Set("X-Cache", "HIT")
+				w.Write(body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			if rec.status != http.StatusOK {
+				return
+			}
+			if err := app.cacheStorage.Responses.Set(ctx, key, rec.body.Bytes(), ttl); err != nil {
+				app.logger.Warnw("error caching response", "path", r.URL.Path, "error", err.Error())
+			}
+		})
+	}
+}
+
+// responseRecorder copies the body it writes so it can be cached afterwards.
+// Error responses are marked uncacheable.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	rec.status = status
+	if status != http.StatusOK {
+		rec.Header().Set("Cache-Control", "no-store")
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// GetPublicExplore godoc
+//
+//	@Summary		Public explore feed
+//	@Description	Recent posts from everyone, as seen by an anonymous visitor
+//	@Tags			public
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			lang	query		string	false	"Language (ISO 639-1)"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/public/explore [get]
+func (app *application) publicExploreHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parsePublicFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	app.serveExploreFeed(w, r, &store.User{}, fq)
+}
+
+// GetPublicTag godoc
+//
+//	@Summary		Public hashtag page
+//	@Description	Recent posts carrying the tag, as seen by an anonymous visitor
+//	@Tags			public
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.PostWithMetadata
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/public/tags/{tag} [get]
+func (app *application) publicTagHandler(w http.ResponseWriter, r *http.Request) {
+	fq, err := parsePublicFeedQuery(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	fq.Tags = []string{chi.URLParam(r, "tag")}
+	app.serveExploreFeed(w, r, &store.User{}, fq)
+}
+
+// GetPublicTrending godoc
+//
+//	@Summary		Trending tags
+//	@Description	Tags gaining usage fastest, the same ranking as /hashtags/trending
+//	@Tags			public
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 10, max 50)"
+//	@Success		200		{object}	[]store.TrendingTag
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/public/trending [get]
+func (app *application) publicTrendingHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parseLimitOffset(r, 10, maxTrendingTags)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	tags, err := app.trendingHashtags(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	if err := app.jsonResponse(w, http.StatusOK, tags); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetPublicProfile godoc
+//
+//	@Summary		Public profile
+//	@Description	A user's public profile with follower, following and post counts and their custom fields. With format=html or an Accept header asking for HTML it is served as a page with JSON-LD structured data for crawlers
+//	@Tags			public
+//	@Produce		json
+//	@Produce		html
+//	@Param			userID	path		int		true	"User ID"
+//	@Param			format	query		string	false	"html for the crawler page"
+//	@Success		200		{object}	store.Profile
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/public/users/{userID} [get]
+func (app *application) publicProfileHandler(w http.ResponseWriter, r *http.Request) {
+	profile, ok := app.loadPublicProfile(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	if responseFormat(r) == formatHTML {
+		err = app.writeHTML(w, app.profileSEOPage(profile))
+	} else {
+		err = app.jsonResponse(w, http.StatusOK, profile)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// loadPublicProfile fetches the profile of the request with its fields,
+// answering the error itself when it can't.
+func (app *application) loadPublicProfile(w http.ResponseWriter, r *http.Request) (*store.Profile, bool) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+
+	ctx := r.Context()
+	profile, err := app.store.Users.GetProfile(ctx, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	if profile.Fields, err = app.store.ProfileFields.List(ctx, userID); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	return profile, true
+}
+
+// parsePublicFeedQuery reads the paging options of the public feeds. Search
+// is left out so the cacheable key space stays small.
+func parsePublicFeedQuery(r *http.Request) (store.PaginatedFeedQuery, error) {
+	fq := store.PaginatedFeedQuery{
+		Limit: 20,
+		Tags:  []string{},
+		Sort:  "desc",
+	}
+	fq, err := fq.Parse(r)
+	if err != nil {
+		return fq, err
+	}
+	fq.Search = ""
+	fq.Tags = []string{}
+	fq.Sort = "desc"
+	return fq, Validate.Struct(fq)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"gopher_social/internal/store/cache"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPublicResponseCache(t *testing.T) {
+	app := NewTestApplication(t, config{
+		redisCfg:       redisConfig{enabled: true},
+		publicCacheTTL: 30 * time.Second,
+	})
+	responses := &cache.MockResponseStore{}
+	app.cacheStorage.Responses = responses
+	mux := app.mount()
+
+	get := func(path string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return executeRequest(req, mux).Result()
+	}
+
+	t.Run("should serve the second request from the cache", func(t *testing.T) {
+		first := get("/v1/public/users/7")
+		checkResponseCode(t, http.StatusOK, first.StatusCode)
+		if got := first.Header.Get("X-Cache"); got != "MISS" {
+			t.Errorf("expected a cache miss, got %q", got)
+		}
+		if got := first.Header.Get("Cache-Control"); got != "public, max-age=30" {
+			t.Errorf("unexpected Cache-Control %q", got)
+		}
+
+		second := get("/v1/public/users/7")
+		checkResponseCode(t, http.StatusOK, second.StatusCode)
+		if got := second.Header.Get("X-Cache"); got != "HIT" {
+			t.Errorf("expected a cache hit, got %q", got)
+		}
+	})
+
+	t.Run("should not cache errors", func(t *testing.T) {
+		res := get("/v1/public/trending?limit=500")
+		checkResponseCode(t, http.StatusBadRequest, res.StatusCode)
+		if got := res.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("expected errors to be marked no-store, got %q", got)
+		}
+		if _, ok := responses.Bodies["/v1/public/trending?limit=500"]; ok {
+			t.Error("expected the error response not to be cached")
+		}
+	})
+}
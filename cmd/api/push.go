@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/push"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// pushConfig enables mobile push per platform, a platform without
+// credentials gets no pushes.
+type pushConfig struct {
+	apnsKeyFile string
+	apnsKeyID   string
+	apnsTeamID  string
+	// apnsTopic is the iOS app's bundle ID.
+	apnsTopic   string
+	apnsSandbox bool
+	// fcmCredentialsFile is the Firebase service account JSON key.
+	fcmCredentialsFile string
+	// Notifications are pushed once they are delay old, unless they were
+	// read in-app by then. Ones older than maxAge are no longer pushed.
+	delay     time.Duration
+	maxAge    time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+// newPushRouter builds the senders for the configured platforms, nil when
+// none is.
+func newPushRouter(cfg pushConfig) (push.Router, error) {
+	router := push.Router{}
+	if cfg.apnsKeyFile != "" {
+		host := push.APNsProduction
+		if cfg.apnsSandbox {
+			host = push.APNsSandbox
+		}
+		apns, err := push.NewAPNs(host, cfg.apnsKeyFile, cfg.apnsKeyID, cfg.apnsTeamID, cfg.apnsTopic)
+		if err != nil {
+			return nil, err
+		}
+		router[push.PlatformIOS] = apns
+	}
+	if cfg.fcmCredentialsFile != "" {
+		fcm, err := push.NewFCM(cfg.fcmCredentialsFile)
+		if err != nil {
+			return nil, err
+		}
+		router[push.PlatformAndroid] = fcm
+	}
+	if len(router) == 0 {
+		return nil, nil
+	}
+	return router, nil
+}
+
+var pushBodies = map[string]string{
+	store.NotificationComment:          "New comment on your post",
+	store.NotificationReply:            "New reply to your comment",
+	store.NotificationMention:          "You were mentioned in a comment",
+	store.NotificationNewPost:          "New post from someone you follow",
+	store.NotificationFollowBack:       "Someone you might want to follow back",
+	store.NotificationModerationAction: "A moderation action was taken on your account",
+	store.NotificationAppealResolved:   "Your appeal was resolved",
+	store.NotificationRecoveryRequest:  "Someone who trusts you asks you to confirm their account recovery",
+	store.NotificationEventReminder:    "An event you're attending starts soon",
+	store.NotificationEventCanceled:    "An event you answered was canceled",
+	store.NotificationListingReviewed:  "A moderator reviewed your listing",
+	store.NotificationAnswerAccepted:   "Your answer was accepted",
+	store.NotificationSpaceLive:        "Someone you follow went live",
+}
+
+// pushGroupBodies word collapsed notifications, they get the actor count, or
+// the event count for new posts which share an author.
+var pushGroupBodies = map[string]string{
+	store.NotificationComment:    "%d people commented on your post",
+	store.NotificationReply:      "%d people replied to your comments",
+	store.NotificationNewPost:    "%d new posts from someone you follow",
+	store.NotificationFollowBack: "%d people you might want to follow back",
+}
+
+// pushMessage is what the phone shows for a notification, the app opens the
+// post or comment from the data and reports the notification seen when the
+// user taps it.
+func pushMessage(n store.Notification) push.Message {
+	data := map[string]string{"notification_id": strconv.FormatInt(n.ID, 10), "type": n.Type}
+	if n.PostID != 0 {
+		data["post_id"] = strconv.FormatInt(n.PostID, 10)
+	}
+	if n.CommentID != 0 {
+		data["comment_id"] = strconv.FormatInt(n.CommentID, 10)
+	}
+	body := pushBodies[n.Type]
+	if format, ok := pushGroupBodies[n.Type]; ok && n.EventCount > 1 {
+		count := n.ActorCount
+		if n.Type == store.NotificationNewPost {
+			count = n.EventCount
+		}
+		if count > 1 {
+			body = fmt.Sprintf(format, count)
+		}
+	}
+	data["count"] = strconv.Itoa(max(n.EventCount, 1))
+	return push.Message{Title: "Gopher Social", Body: body, Data: data}
+}
+
+// dispatchPushNotifications pushes the notifications that are due and records
+// the receipts. Waiting out the delay lets users who are in the app read them
+// there instead of getting a push as well.
+func (app *application) dispatchPushNotifications(ctx context.Context) error {
+	cfg := app.config.push
+	now := time.Now()
+	pending, err := app.store.Notifications.PendingDelivery(ctx, store.ChannelPush, now.Add(-cfg.maxAge), now.Add(-cfg.delay), cfg.batchSize)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	delivered := app.pushNotifications(ctx, pending)
+	attempted := make([]int64, len(pending))
+	for i, n := range pending {
+		attempted[i] = n.ID
+	}
+	if err := app.store.Notifications.RecordDelivery(ctx, store.ChannelPush, attempted, delivered); err != nil {
+		return err
+	}
+	app.logger.Infow("pushed notifications", "attempted", len(attempted), "delivered", len(delivered))
+	return nil
+}
+
+// pushNotifications sends every notification to the recipient's devices,
+// muted types are skipped like in CreateMany. Tokens the provider rejects are
+// forgotten so they aren't tried again. It returns the notifications that
+// reached at least one device.
+func (app *application) pushNotifications(ctx context.Context, notifications []store.Notification) []int64 {
+	var delivered []int64
+	byType := make(map[string][]store.Notification)
+	for _, n := range notifications {
+		byType[n.Type] = append(byType[n.Type], n)
+	}
+	for kind, batch := range byType {
+		userIDs := make([]int64, len(batch))
+		for i, n := range batch {
+			userIDs[i] = n.UserID
+		}
+		devices, err := app.store.PushDevices.ListForNotification(ctx, userIDs, kind)
+		if err != nil {
+			app.logger.Errorw("error loading push devices", "type", kind, "error", err.Error())
+			continue
+		}
+		for _, n := range batch {
+			msg := pushMessage(n)
+			ok := false
+			for _, d := range devices {
+				if d.UserID == n.UserID && app.sendPush(ctx, d, msg) {
+					ok = true
+				}
+			}
+			if ok {
+				delivered = append(delivered, n.ID)
+			}
+		}
+	}
+	return delivered
+}
+
+func (app *application) sendPush(ctx context.Context, d store.PushDevice, msg push.Message) bool {
+	err := app.push.Send(ctx, d.Platform, d.Token, msg)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, push.ErrTokenRejected):
+		app.logger.Infow("pruning rejected push token", "device_id", d.ID, "user_id", d.UserID, "platform", d.Platform)
+		if err := app.store.PushDevices.DeleteByToken(ctx, d.Token); err != nil {
+			app.logger.Errorw("error pruning push token", "device_id", d.ID, "error", err.Error())
+		}
+	default:
+		app.logger.Warnw("error sending push", "device_id", d.ID, "platform", d.Platform, "error", err.Error())
+	}
+	return false
+}
+
+type RegisterPushDevicePayload struct {
+	Platform   string `json:"platform" validate:"required,oneof=ios android"`
+	Token      string `json:"token" validate:"required,max=512"`
+	DeviceName string `json:"device_name" validate:"max=100"`
+	AppVersion string `json:"app_version" validate:"max=30"`
+	OSVersion  string `json:"os_version" validate:"max=30"`
+}
+
+// RegisterPushDevice godoc
+//
+//	@Summary		Register a device for push
+//	@Description	Registers the phone's APNs or FCM token for push notifications. Apps call it on every launch, known tokens are refreshed
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		RegisterPushDevicePayload	true	"Device"
+//	@Success		200		{object}	store.PushDevice
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/devices [put]
+func (app *application) registerPushDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RegisterPushDevicePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	device := &store.PushDevice{
+		UserID:     getUserFromContext(r).ID,
+		Platform:   payload.Platform,
+		Token:      payload.Token,
+		DeviceName: payload.DeviceName,
+		AppVersion: payload.AppVersion,
+		OSVersion:  payload.OSVersion,
+	}
+	if err := app.store.PushDevices.Register(r.Context(), device); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, device); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListPushDevices godoc
+//
+//	@Summary		List push devices
+//	@Description	Lists the phones registered for the authenticated user's push notifications
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.PushDevice
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/devices [get]
+func (app *application) listPushDevicesHandler(w http.ResponseWriter, r *http.Request) {
+	devices, err := app.store.PushDevices.ListByUser(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, devices); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// DeletePushDevice godoc
+//
+//	@Summary		Unregister a push device
+//	@Description	Stops push notifications to one of the authenticated user's phones, e.g. on sign out
+//	@Tags			users
+//	@Param			deviceID	path	int	true	"Device ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/devices/{deviceID} [delete]
+func (app *application) deletePushDeviceHandler(w http.ResponseWriter, r *http.Request) {
+	deviceID, err := strconv.ParseInt(chi.URLParam(r, "deviceID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.PushDevices.Delete(r.Context(), getUserFromContext(r).ID, deviceID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"gopher_social/internal/push"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSender records pushes and rejects the tokens in rejected.
+type fakeSender struct {
+	mu       sync.Mutex
+	sent     []string
+	rejected map[string]bool
+}
+
+func (f *fakeSender) Send(ctx context.Context, token string, msg push.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rejected[token] {
+		return fmt.Errorf("%w: Unregistered", push.ErrTokenRejected)
+	}
+	f.sent = append(f.sent, token+":"+msg.Data["type"])
+	return nil
+}
+
+func TestPushDevices(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	sender := &fakeSender{rejected: map[string]bool{"stale": true}}
+	app.push = push.Router{push.PlatformIOS: sender, push.PlatformAndroid: sender}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should register devices once per token", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPut, "/v1/users/me/devices", `{"platform":"web","token":"abc"}`).Code)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/users/me/devices", `{"platform":"ios","token":"fresh","device_name":"iPhone"}`).Code)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/users/me/devices", `{"platform":"ios","token":"fresh","app_version":"2.1.0"}`).Code)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodPut, "/v1/users/me/devices", `{"platform":"android","token":"stale"}`).Code)
+
+		rr := request(t, http.MethodGet, "/v1/users/me/devices", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if devices := decodeData[[]store.PushDevice](t, rr.Body.String()); len(devices) != 2 {
+			t.Fatalf("expected 2 devices, got %+v", devices)
+		}
+	})
+
+	t.Run("should push notifications and prune rejected tokens", func(t *testing.T) {
+		app.pushNotifications(context.Background(), []store.Notification{{UserID: 42, ActorID: 7, Type: store.NotificationReply, PostID: 1}})
+		if len(sender.sent) != 1 || sender.sent[0] != "fresh:reply" {
+			t.Errorf("unexpected pushes %v", sender.sent)
+		}
+		devices, _ := app.store.PushDevices.ListByUser(context.Background(), 42)
+		if len(devices) != 1 || devices[0].Token != "fresh" {
+			t.Errorf("expected the rejected token to be pruned, got %+v", devices)
+		}
+	})
+
+	t.Run("should unregister a device", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/users/me/devices/1", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/users/me/devices/1", "").Code)
+	})
+}
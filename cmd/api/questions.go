@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gopher_social/internal/events"
+	"gopher_social/internal/store"
+)
+
+type AcceptAnswerPayload struct {
+	CommentID int64 `json:"comment_id" validate:"required,gte=1"`
+}
+
+// AcceptAnswer godoc
+//
+//	@Summary		Accept an answer
+//	@Description	Lets the author of a question accept one of its answers, replacing the answer accepted before
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID	path		int					true	"Question ID"
+//	@Param			payload	body		AcceptAnswerPayload	true	"Answer to accept"
+//	@Success		200		{object}	store.Comment
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"Not an answer to this question"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/accepted-answer [put]
+func (app *application) acceptAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if post.UserID != getUserFromContext(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	if post.Kind != store.PostKindQuestion {
+		app.badRequestResponse(w, r, errors.New("only questions take answers"))
+		return
+	}
+	var payload AcceptAnswerPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	answer, err := app.store.Questions.Accept(ctx, post.ID, payload.CommentID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	// Accepting the same answer again is not news.
+	if post.AcceptedAnswerID == nil || *post.AcceptedAnswerID != answer.ID {
+		post.AcceptedAnswerID = &answer.ID
+		app.events.Publish(ctx, events.AnswerAccepted{Question: post, Answer: answer})
+	}
+	if err := app.renderComment(answer); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, answer); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// UnacceptAnswer godoc
+//
+//	@Summary		Unaccept the answer
+//	@Description	Lets the author of a question take back accepting an answer, the question is unanswered again
+//	@Tags			posts
+//	@Param			postID	path		int		true	"Question ID"
+//	@Success		204		{string}	string	"No accepted answer"
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/accepted-answer [delete]
+func (app *application) unacceptAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	if post.UserID != getUserFromContext(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	if err := app.store.Questions.Unaccept(r.Context(), post.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListUnansweredQuestions godoc
+//
+//	@Summary		Unanswered questions
+//	@Description	Questions without an accepted answer, newest first
+//	@Tags			posts
+//	@Produce		json
+//	@Param			tag		query		string	false	"Only questions with this tag"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	[]store.Post
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/questions/unanswered [get]
+func (app *application) listUnansweredHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	questions, err := app.store.Questions.Unanswered(ctx, r.URL.Query().Get("tag"), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.hydratePostAuthors(ctx, questions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range questions {
+		if err := app.renderPost(&questions[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, questions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// notifyAnswerAccepted tells whoever answered that their answer was
+// accepted. Reputation for accepted answers would hook in the same way.
+func (app *application) notifyAnswerAccepted(ctx context.Context, e events.AnswerAccepted) error {
+	if e.Answer.UserID == e.Question.UserID {
+		return nil
+	}
+	n := store.Notification{
+		UserID:    e.Answer.UserID,
+		ActorID:   e.Question.UserID,
+		Type:      store.NotificationAnswerAccepted,
+		PostID:    e.Question.ID,
+		CommentID: e.Answer.ID,
+	}
+	if err := app.createNotifications(ctx, []store.Notification{n}); err != nil {
+		return fmt.Errorf("creating notification for accepted answer %d: %w", e.Answer.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// questionPostStore serves the questions of a MockQuestionStore as posts.
+type questionPostStore struct {
+	store.MockPostStore
+	questions *store.MockQuestionStore
+}
+
+func (m *questionPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	for _, q := range m.questions.Questions {
+		if q.ID == id {
+			return &q, nil
+		}
+	}
+	return &store.Post{ID: id, UserID: 42, Kind: store.PostKindNote}, nil
+}
+
+func TestQuestions(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	questions := &store.MockQuestionStore{}
+	app.store.Questions = questions
+	app.store.Posts = &questionPostStore{questions: questions}
+	notifications := &store.MockNotificationStore{}
+	app.store.Notifications = notifications
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp}))
+		return executeRequest(req, app.mount())
+	}
+	unanswered := func(t *testing.T) []store.Post {
+		t.Helper()
+		rr := request(t, 43, http.MethodGet, "/v1/questions/unanswered", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return decodeData[[]store.Post](t, rr.Body.String())
+	}
+
+	t.Run("should take answers on questions only", func(t *testing.T) {
+		rr := request(t, 42, http.MethodPost, "/v1/posts", `{"title":"Generics?","content":"How do I constrain a type parameter?","kind":"question","tags":["go"]}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := decodeData[store.Post](t, rr.Body.String()); got.Kind != store.PostKindQuestion {
+			t.Fatalf("expected a question, got %+v", got)
+		}
+
+		rr = request(t, 43, http.MethodPost, "/v1/posts/1/comments", `{"content":"Use an interface","answer":true}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := decodeData[store.Comment](t, rr.Body.String()); !got.Answer {
+			t.Errorf("expected an answer, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusBadRequest, request(t, 43, http.MethodPost, "/v1/posts/2/comments", `{"content":"x","answer":true}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 43, http.MethodPost, "/v1/posts/1/comments", `{"content":"x","answer":true,"parent_id":1}`).Code)
+
+		if got := unanswered(t); len(got) != 1 || got[0].ID != 1 {
+			t.Errorf("expected the question to be unanswered, got %+v", got)
+		}
+	})
+
+	t.Run("should let the author accept an answer", func(t *testing.T) {
+		questions.Answers = map[int64]store.Comment{
+			5: {ID: 5, PostID: 1, UserID: 43, Content: "Use an interface", Answer: true},
+			6: {ID: 6, PostID: 1, UserID: 44, Content: "Just a comment"},
+		}
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodPut, "/v1/posts/1/accepted-answer", `{"comment_id":5}`).Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 42, http.MethodPut, "/v1/posts/1/accepted-answer", `{"comment_id":6}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPut, "/v1/posts/2/accepted-answer", `{"comment_id":5}`).Code)
+
+		rr := request(t, 42, http.MethodPut, "/v1/posts/1/accepted-answer", `{"comment_id":5}`)
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[store.Comment](t, rr.Body.String()); !got.Accepted {
+			t.Errorf("expected the answer to be accepted, got %+v", got)
+		}
+		if got := unanswered(t); len(got) != 0 {
+			t.Errorf("expected no unanswered questions, got %+v", got)
+		}
+
+		accepted := func() []store.Notification {
+			var accepted []store.Notification
+			for _, n := range notifications.Notifications {
+				if n.Type == store.NotificationAnswerAccepted {
+					accepted = append(accepted, n)
+				}
+			}
+			return accepted
+		}
+		if got := accepted(); len(got) != 1 || got[0].UserID != 43 || got[0].CommentID != 5 {
+			t.Errorf("expected the answerer to be notified, got %+v", got)
+		}
+
+		checkResponseCode(t, http.StatusOK, request(t, 42, http.MethodPut, "/v1/posts/1/accepted-answer", `{"comment_id":5}`).Code)
+		if got := accepted(); len(got) != 1 {
+			t.Errorf("expected accepting again not to notify, got %+v", got)
+		}
+	})
+
+	t.Run("should unaccept", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodDelete, "/v1/posts/1/accepted-answer", "").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, http.MethodDelete, "/v1/posts/1/accepted-answer", "").Code)
+		if got := unanswered(t); len(got) != 1 {
+			t.Errorf("expected the question to be unanswered again, got %+v", got)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+)
+
+type ReactionPayload struct {
+	Type string `json:"type" validate:"required"`
+}
+
+// ReactToPost godoc
+//
+//	@Summary		React to a post
+//	@Description	Adds or replaces the authenticated user's reaction on a post
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			postID	path		int				true	"Post ID"
+//	@Param			payload	body		ReactionPayload	true	"Reaction type"
+//
+//	@Success		200		{object}	store.ReactionSummary
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	app.setReaction(w, r, store.ReactionSubjectPost, getPostFromCtx(r).ID)
+}
+
+// ListPostReactors godoc
+//
+//	@Summary		List who reacted
+//	@Description	Pages through the users who reacted to a post, newest first
+//	@Tags			posts
+//	@Produce		json
+//
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			type	query		string	false	"Only this reaction type"
+//	@Param			limit	query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int		false	"Offset"
+//
+//	@Success		200		{object}	store.ReactorPage
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [get]
+func (app *application) listPostReactorsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromCtx(r)
+	qs := r.URL.Query()
+	reactionType := qs.Get("type")
+	if err := Validate.Var(reactionType, "omitempty,oneof="+strings.Join(store.ReactionTypes, " ")); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	page, err := app.store.Reactions.ListReactors(r.Context(), store.ReactionSubjectPost, post.ID, reactionType, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, page); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RemoveReaction godoc
+//
+//	@Summary		Remove reaction
+//	@Description	Removes the authenticated user's reaction from a post
+//	@Tags			posts
+//
+//	@Param			postID	path		int		true	"Post ID"
+//
+//	@Success		204		{string}	string	"Reaction removed"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/reactions [delete]
+func (app *application) removeReactionHandler(w http.ResponseWriter, r *http.Request) {
+	app.removeReaction(w, r, store.ReactionSubjectPost, getPostFromCtx(r).ID)
+}
+
+// ReactToComment godoc
+//
+//	@Summary		React to a comment
+//	@Description	Adds or replaces the authenticated user's reaction on a comment
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//
+//	@Param			postID		path		int				true	"Post ID"
+//	@Param			commentID	path		int				true	"Comment ID"
+//	@Param			payload		body		ReactionPayload	true	"Reaction type"
+//
+//	@Success		200			{object}	store.ReactionSummary
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/reactions [put]
+func (app *application) reactToCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.commentFromPost(w, r)
+	if !ok {
+		return
+	}
+	app.setReaction(w, r, store.ReactionSubjectComment, comment.ID)
+}
+
+// RemoveCommentReaction godoc
+//
+//	@Summary		Remove comment reaction
+//	@Description	Removes the authenticated user's reaction from a comment
+//	@Tags			posts
+//
+//	@Param			postID		path		int		true	"Post ID"
+//	@Param			commentID	path		int		true	"Comment ID"
+//
+//	@Success		204			{string}	string	"Reaction removed"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/posts/{postID}/comments/{commentID}/reactions [delete]
+func (app *application) removeCommentReactionHandler(w http.ResponseWriter, r *http.Request) {
+	comment, ok := app.commentFromPost(w, r)
+	if !ok {
+		return
+	}
+	app.removeReaction(w, r, store.ReactionSubjectComment, comment.ID)
+}
+
+func (app *application) setReaction(w http.ResponseWriter, r *http.Request, subjectType string, subjectID int64) {
+	user := getUserFromContext(r)
+	var payload ReactionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Var(payload.Type, "oneof="+strings.Join(store.ReactionTypes, " ")); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Reactions.Set(ctx, subjectType, subjectID, user.ID, payload.Type); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	summary, err := app.store.Reactions.Summary(ctx, subjectType, subjectID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, summary); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) removeReaction(w http.ResponseWriter, r *http.Request, subjectType string, subjectID int64) {
+	user := getUserFromContext(r)
+	if err := app.store.Reactions.Remove(r.Context(), subjectType, subjectID, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// attachCommentReactions fills in the reaction counts of the comments with a
+// single batched query.
+func (app *application) attachCommentReactions(ctx context.Context, comments []store.Comment) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(comments))
+	for i, c := range comments {
+		ids[i] = c.ID
+	}
+	counts, err := app.store.Reactions.Counts(ctx, store.ReactionSubjectComment, ids)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = counts[comments[i].ID]
+	}
+	return nil
+}
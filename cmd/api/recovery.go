@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/i18n"
+	"gopher_social/internal/mailer"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// recoveryConfig governs recovering accounts through trusted contacts, meant
+// for passkey-only accounts whose devices are lost. quorum contacts have to
+// approve within expiry, the reset link is mailed delay after that so the
+// owner can still cancel a recovery they didn't start, and works for
+// linkExpiry.
+type recoveryConfig struct {
+	quorum     int
+	delay      time.Duration
+	expiry     time.Duration
+	linkExpiry time.Duration
+	interval   time.Duration
+}
+
+// recoveryLinkBatch is how many reset links one run of the job mails.
+const recoveryLinkBatch = 50
+
+// recoveryAcceptedMessage answers every recovery request, so it can't reveal
+// whether the email is registered or has trusted contacts.
+const recoveryAcceptedMessage = "If the account can be recovered, its trusted contacts have been asked to confirm"
+
+// inWords spells a duration out for emails, in whole hours or minutes.
+func inWords(locale string, d time.Duration) string {
+	n, key := int(d.Minutes()), "duration.minute"
+	if d >= time.Hour {
+		n, key = int(d.Hours()), "duration.hour"
+	}
+	if n != 1 {
+		key += "s"
+	}
+	return i18n.T(locale, key, n)
+}
+
+type RecoveryContactsPayload struct {
+	ContactIDs []int64 `json:"contact_ids" validate:"max=5,unique,dive,min=1"`
+}
+
+// ListRecoveryContacts godoc
+//
+//	@Summary		List trusted contacts
+//	@Description	The people who can approve recovering the account
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.RecoveryContact
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/recovery/contacts [get]
+func (app *application) listRecoveryContactsHandler(w http.ResponseWriter, r *http.Request) {
+	contacts, err := app.store.Recovery.ListContacts(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, contacts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// SetRecoveryContacts godoc
+//
+//	@Summary		Set trusted contacts
+//	@Description	Replaces the people who can approve recovering the account, at least as many as the quorum and at most 5. No contacts turns recovery through contacts off. A recovery in progress is cancelled
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		RecoveryContactsPayload	true	"Contacts"
+//	@Success		204		{string}	string					"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error	"Reauthentication required"
+//	@Failure		404		{object}	error	"Contact not found"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/recovery/contacts [put]
+func (app *application) setRecoveryContactsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RecoveryContactsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if n := len(payload.ContactIDs); n > 0 && n < app.config.recovery.quorum {
+		app.badRequestResponse(w, r, fmt.Errorf("at least %d trusted contacts are needed", app.config.recovery.quorum))
+		return
+	}
+
+	user := getUserFromContext(r)
+	if err := app.store.Recovery.SetContacts(r.Context(), user.ID, payload.ContactIDs); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("recovery.contacts", user.ID, "contacts", payload.ContactIDs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type StartRecoveryPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// StartRecovery godoc
+//
+//	@Summary		Start recovering an account
+//	@Description	Asks the account's trusted contacts to confirm the recovery and tells the owner by email. Once the quorum approved and the delay passed, a reset link is mailed to the account's address. The answer is the same whether or not the account can be recovered
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		StartRecoveryPayload	true	"Account email"
+//	@Success		202		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/recovery [post]
+func (app *application) startRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload StartRecoveryPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.startRecovery(r.Context(), payload.Email); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusAccepted, recoveryAcceptedMessage); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// startRecovery opens a recovery for the account behind email, if it has
+// enough contacts and none is in progress already.
+func (app *application) startRecovery(ctx context.Context, email string) error {
+	user, err := app.store.Users.GetByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	cfg := app.config.recovery
+	req, contacts, err := app.store.Recovery.Open(ctx, user.ID, cfg.quorum, cfg.expiry)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) || errors.Is(err, store.ErrConflict) {
+			return nil
+		}
+		return err
+	}
+	app.auditLog("recovery.open", user.ID, "request_id", req.ID, "contacts", len(contacts))
+
+	notifications := make([]store.Notification, len(contacts))
+	for i, id := range contacts {
+		notifications[i] = store.Notification{UserID: id, ActorID: user.ID, Type: store.NotificationRecoveryRequest}
+	}
+	if err := app.createNotifications(ctx, notifications); err != nil {
+		app.logger.Errorw("error notifying recovery contacts", "request_id", req.ID, "error", err.Error())
+	}
+
+	vars := struct {
+		Username    string
+		Quorum      int
+		Delay       string
+		SettingsURL string
+	}{
+		Username:    user.Username,
+		Quorum:      cfg.quorum,
+		Delay:       inWords(user.Locale, cfg.delay),
+		SettingsURL: fmt.Sprintf("%s/settings/recovery", app.config.frontendURL),
+	}
+	if _, err := app.sendMail(ctx, mailer.RecoveryRequestedTemplate, user.Locale, user.Username, user.Email, vars); err != nil {
+		app.logger.Errorw("error sending recovery requested email", "user_id", user.ID, "error", err.Error())
+	}
+	return nil
+}
+
+// CancelRecovery godoc
+//
+//	@Summary		Cancel a recovery
+//	@Description	Stops a recovery of the account that its owner didn't start
+//	@Tags			users
+//	@Success		204	{string}	string	"Cancelled"
+//	@Failure		404	{object}	error	"No recovery in progress"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/recovery [delete]
+func (app *application) cancelRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	if err := app.store.Recovery.Cancel(r.Context(), user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("recovery.cancel", user.ID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListRecoveryRequests godoc
+//
+//	@Summary		List recoveries to approve
+//	@Description	Recoveries of accounts that named the user a trusted contact and that the user hasn't approved yet
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.RecoveryRequest
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/recovery/requests [get]
+func (app *application) listRecoveryRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	requests, err := app.store.Recovery.Pending(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, requests); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ApproveRecovery godoc
+//
+//	@Summary		Approve a recovery
+//	@Description	Vouches that the account is being recovered by its owner. Only approve after confirming it with them outside the app
+//	@Tags			users
+//	@Produce		json
+//	@Param			requestID	path		int	true	"Recovery request ID"
+//	@Success		200			{object}	store.RecoveryRequest
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error	"Reauthentication required"
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error	"Already approved"
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/recovery/requests/{requestID}/approve [post]
+func (app *application) approveRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "requestID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	contact := getUserFromContext(r)
+	cfg := app.config.recovery
+	req, err := app.store.Recovery.Approve(r.Context(), requestID, contact.ID, cfg.quorum, cfg.delay)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("recovery.approve", contact.ID, "request_id", req.ID, "user_id", req.UserID, "approvals", req.Approvals)
+	if err := app.jsonResponse(w, http.StatusOK, req); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// issueRecoveryLinks is the job mailing reset links for the recoveries
+// approved and past their delay. A request that fails is logged and left
+// for the next run, without holding up the rest of the batch.
+func (app *application) issueRecoveryLinks(ctx context.Context) error {
+	requests, err := app.store.Recovery.Ready(ctx, recoveryLinkBatch)
+	if err != nil {
+		return err
+	}
+	for _, req := range requests {
+		if err := app.issueRecoveryLink(ctx, req); err != nil {
+			app.logger.Errorw("error issuing recovery link", "request_id", req.ID, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+func (app *application) issueRecoveryLink(ctx context.Context, req store.RecoveryRequest) error {
+	user, err := app.store.Users.GetByID(ctx, req.UserID)
+	if err != nil {
+		return err
+	}
+	secret, err := app.store.Recovery.IssueLink(ctx, req.ID, app.config.recovery.linkExpiry)
+	if errors.Is(err, store.ErrRecordNotFound) {
+		// Issued by another run, or cancelled, since Ready listed it.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	vars := struct {
+		Username string
+		ResetURL string
+		Expiry   string
+	}{
+		Username: user.Username,
+		ResetURL: fmt.Sprintf("%s/recover/%s", app.config.frontendURL, secret),
+		Expiry:   inWords(user.Locale, app.config.recovery.linkExpiry),
+	}
+	if _, err := app.sendMail(ctx, mailer.RecoveryLinkTemplate, user.Locale, user.Username, user.Email, vars); err != nil {
+		// Neither sent nor queued, withdraw the link so the next run
+		// issues a fresh one instead of waiting for it to expire.
+		if err := app.store.Recovery.RevokeLink(ctx, req.ID); err != nil {
+			app.logger.Errorw("error revoking undelivered recovery link", "request_id", req.ID, "error", err.Error())
+		}
+		return err
+	}
+	app.auditLog("recovery.link", user.ID, "request_id", req.ID)
+	return nil
+}
+
+type RedeemRecoveryPayload struct {
+	Token    string `json:"token" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// RedeemRecovery godoc
+//
+//	@Summary		Finish recovering an account
+//	@Description	Sets a new password with the reset link's token, turns password login back on and signs the user in, so a new passkey can be registered
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload		body		RedeemRecoveryPayload	true	"Reset token and new password"
+//	@Param			X-Client-ID	header		string					false	"Client ID, cookie session clients get an HttpOnly cookie"
+//	@Success		200			{object}	string
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error	"Unknown, used or expired link"
+//	@Failure		500			{object}	error
+//	@Router			/authentication/recovery/redeem [post]
+func (app *application) redeemRecoveryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload RedeemRecoveryPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	userID, err := app.store.Recovery.Redeem(ctx, payload.Token, payload.Password)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, userID)
+	}
+	app.auditLog("recovery.complete", userID)
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.issueToken(w, r, user)
+}
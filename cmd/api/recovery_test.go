@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/mailer"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// recordingMailer keeps the templates it was asked to send.
+type recordingMailer struct {
+	sent []string
+}
+
+func (m *recordingMailer) Send(templateFile, username, email string, data any, isSandbox bool) (int, error) {
+	m.sent = append(m.sent, templateFile)
+	return 200, nil
+}
+
+// recoveryUserStore knows owner@example.com as user 7.
+type recoveryUserStore struct {
+	store.MockUserStore
+}
+
+func (m *recoveryUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	if email != "owner@example.com" {
+		return nil, store.ErrRecordNotFound
+	}
+	return &store.User{ID: 7, Username: "owner", Email: email}, nil
+}
+
+// issuedElsewhereStore loses every IssueLink to another replica.
+type issuedElsewhereStore struct {
+	*store.MockRecoveryStore
+}
+
+func (m issuedElsewhereStore) IssueLink(ctx context.Context, requestID int64, expiry time.Duration) (string, error) {
+	return "", store.ErrRecordNotFound
+}
+
+// downMailOutbox can't queue mail either, so sendMail fails.
+type downMailOutbox struct {
+	store.MockMailOutboxStore
+}
+
+func (m *downMailOutbox) Enqueue(ctx context.Context, mail *store.QueuedMail) error {
+	return errors.New("connection refused")
+}
+
+func TestAccountRecovery(t *testing.T) {
+	app := NewTestApplication(t, config{recovery: recoveryConfig{quorum: 2, expiry: time.Hour, linkExpiry: time.Hour},
+		auth: authConfig{sudoWindow: time.Minute, token: tokenConfig{exp: time.Hour}}})
+	app.store.Users = &recoveryUserStore{}
+	recovery := &store.MockRecoveryStore{Contacts: map[int64][]int64{7: {42, 43}}}
+	app.store.Recovery = recovery
+	notifications := &store.MockNotificationStore{}
+	app.store.Notifications = notifications
+	mail := &recordingMailer{}
+	app.mailer = mail
+	mux := app.mount()
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sub != 0 {
+			req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp, "auth_time": time.Now().Unix()}))
+		}
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should take at least the quorum of contacts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPut, "/v1/users/me/recovery/contacts", `{"contact_ids":[5]}`))
+		checkResponseCode(t, http.StatusNotFound, request(t, 42, http.MethodPut, "/v1/users/me/recovery/contacts", `{"contact_ids":[5,42]}`))
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, http.MethodPut, "/v1/users/me/recovery/contacts", `{"contact_ids":[5,6]}`))
+	})
+
+	t.Run("should ask the contacts and tell the owner", func(t *testing.T) {
+		checkResponseCode(t, http.StatusAccepted, request(t, 0, http.MethodPost, "/v1/authentication/recovery", `{"email":"nobody@example.com"}`))
+		checkResponseCode(t, http.StatusAccepted, request(t, 0, http.MethodPost, "/v1/authentication/recovery", `{"email":"owner@example.com"}`))
+		checkResponseCode(t, http.StatusAccepted, request(t, 0, http.MethodPost, "/v1/authentication/recovery", `{"email":"owner@example.com"}`))
+		if len(recovery.Requests) != 1 {
+			t.Fatalf("expected 1 recovery request, got %d", len(recovery.Requests))
+		}
+		if len(notifications.Notifications) != 2 || notifications.Notifications[0].Type != store.NotificationRecoveryRequest {
+			t.Errorf("expected both contacts to be notified, got %+v", notifications.Notifications)
+		}
+		if len(mail.sent) != 1 || mail.sent[0] != mailer.RecoveryRequestedTemplate {
+			t.Errorf("expected the owner to be mailed, got %v", mail.sent)
+		}
+	})
+
+	t.Run("should mail the link once the quorum approved", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/recovery/requests/1/approve", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": 42, "exp": exp}))
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+
+		checkResponseCode(t, http.StatusNotFound, request(t, 44, http.MethodPost, "/v1/users/me/recovery/requests/1/approve", ""))
+		checkResponseCode(t, http.StatusOK, request(t, 42, http.MethodPost, "/v1/users/me/recovery/requests/1/approve", ""))
+		checkResponseCode(t, http.StatusConflict, request(t, 42, http.MethodPost, "/v1/users/me/recovery/requests/1/approve", ""))
+		if err := app.issueRecoveryLinks(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(mail.sent) != 1 {
+			t.Fatalf("expected no link before the quorum, got %v", mail.sent)
+		}
+
+		checkResponseCode(t, http.StatusOK, request(t, 43, http.MethodPost, "/v1/users/me/recovery/requests/1/approve", ""))
+		app.store.Recovery = issuedElsewhereStore{recovery}
+		if err := app.issueRecoveryLinks(context.Background()); err != nil {
+			t.Fatalf("expected a link issued elsewhere to be skipped, got %v", err)
+		}
+		if len(mail.sent) != 1 {
+			t.Fatalf("expected no mail for a link issued elsewhere, got %v", mail.sent)
+		}
+		app.store.Recovery = recovery
+		app.mailer, app.store.MailOutbox = &failingMailer{}, &downMailOutbox{}
+		if err := app.issueRecoveryLinks(context.Background()); err != nil {
+			t.Fatalf("expected an undelivered link to be logged, got %v", err)
+		}
+		app.mailer, app.store.MailOutbox = mail, &store.MockMailOutboxStore{}
+		if err := app.issueRecoveryLinks(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(mail.sent) != 2 || mail.sent[1] != mailer.RecoveryLinkTemplate {
+			t.Fatalf("expected the reset link to be mailed, got %v", mail.sent)
+		}
+	})
+
+	t.Run("should reset the password once", func(t *testing.T) {
+		body := `{"token":"recovery-1","password":"new password"}`
+		checkResponseCode(t, http.StatusOK, request(t, 0, http.MethodPost, "/v1/authentication/recovery/redeem", body))
+		if recovery.Passwords[7] != "new password" {
+			t.Errorf("expected the owner's password to be reset, got %v", recovery.Passwords)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, 0, http.MethodPost, "/v1/authentication/recovery/redeem", body))
+	})
+}
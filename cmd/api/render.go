@@ -0,0 +1,65 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"strconv"
+)
+
+// renderPost fills in the sanitized HTML for a post and its loaded comments
+// and snippets, signing the author's media it links to.
+func (app *application) renderPost(post *store.Post) error {
+	post.Content = app.signPostMedia(post.Content, post.UserID)
+	html, err := app.markup.Render(post.Content)
+	if err != nil {
+		return err
+	}
+	post.ContentHTML = html
+	for i := range post.Snippets {
+		s := &post.Snippets[i]
+		if s.HTML, err = app.markup.Highlight(s.Language, s.Content); err != nil {
+			return err
+		}
+	}
+	return app.renderComments(post.Comments)
+}
+
+func (app *application) renderComment(comment *store.Comment) error {
+	html, err := app.markup.Render(comment.Content)
+	if err != nil {
+		return err
+	}
+	comment.ContentHTML = html
+	return nil
+}
+
+func (app *application) renderComments(comments []store.Comment) error {
+	for i := range comments {
+		if err := app.renderComment(&comments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *application) renderFeed(feed []store.PostWithMetadata) error {
+	for i := range feed {
+		if err := app.renderPost(&feed[i].Post); err != nil {
+			return err
+		}
+		if feed[i].TopComment != nil {
+			if err := app.renderComment(feed[i].TopComment); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// signPostMedia signs the plain links to the author's media in content, such
+// as the images of posts created by email.
+func (app *application) signPostMedia(content string, authorID int64) string {
+	if app.mediaSigner == nil {
+		return content
+	}
+	return app.mediaSigner.SignLinks(content, strconv.FormatInt(authorID, 10)+"/")
+}
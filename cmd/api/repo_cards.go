@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gopher_social/internal/events"
+	"gopher_social/internal/github"
+	"gopher_social/internal/store"
+)
+
+// githubConfig paces fetching repo cards: batchSize cards every interval,
+// each fetched again after refresh to keep the stars current. Lookups are
+// cached for cacheTTL so popular repositories cost one request.
+type githubConfig struct {
+	apiURL    string
+	token     string
+	timeout   time.Duration
+	cacheTTL  time.Duration
+	interval  time.Duration
+	refresh   time.Duration
+	batchSize int
+}
+
+// queueRepoCard records the repository a new post links to. It is fetched
+// by the repo-cards job rather than here, so posting doesn't wait on GitHub.
+func (app *application) queueRepoCard(ctx context.Context, e events.PostCreated) error {
+	owner, name, ok := github.FindRepo(e.Post.Title + "\n" + e.Post.Content)
+	if !ok {
+		return nil
+	}
+	return app.store.RepoCards.Add(ctx, e.Post.ID, owner, name)
+}
+
+// fetchRepoCards fills in a batch of due cards. Cards of missing
+// repositories are removed, and the batch stops early once GitHub's rate
+// limit is used up, the rest waits for the next run.
+func (app *application) fetchRepoCards(ctx context.Context) error {
+	if app.github == nil {
+		return nil
+	}
+	cfg := app.config.github
+	due, err := app.store.RepoCards.Due(ctx, cfg.refresh, cfg.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, card := range due {
+		repo, err := app.github.Repo(ctx, card.Owner, card.Name)
+		switch {
+		case errors.Is(err, github.ErrRateLimited):
+			app.logger.Infow("github rate limit reached, repo cards postponed", "due", len(due))
+			return nil
+		case errors.Is(err, github.ErrNotFound):
+			if err := app.store.RepoCards.Remove(ctx, card.PostID); err != nil {
+				return err
+			}
+			continue
+		case err != nil:
+			app.logger.Infow("error fetching repo card", "post_id", card.PostID, "repo", card.Owner+"/"+card.Name, "error", err.Error())
+			continue
+		}
+		card.Description = repo.Description
+		card.Stars = repo.Stars
+		card.Language = repo.Language
+		card.URL = repo.URL
+		if err := app.store.RepoCards.Fetched(ctx, &card); err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachRepoCards loads the fetched repo cards of the posts with one batched
+// query.
+func (app *application) attachRepoCards(ctx context.Context, posts []*store.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	cards, err := app.store.RepoCards.GetByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		if card, ok := cards[post.ID]; ok {
+			post.RepoCard = &card
+		}
+	}
+	return nil
+}
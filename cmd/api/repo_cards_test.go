@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/github"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRepoCards(t *testing.T) {
+	var calls atomic.Int32
+	var limited atomic.Bool
+	gh := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if limited.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/repos/golang/go" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"full_name":        "golang/go",
+			"description":      "The Go programming language",
+			"stargazers_count": 120000,
+			"language":         "Go",
+			"html_url":         "https://github.com/golang/go",
+		})
+	}))
+	defer gh.Close()
+
+	app := NewTestApplication(t, config{github: githubConfig{refresh: time.Hour, batchSize: 10}})
+	app.github = github.New(gh.URL, "", time.Second, time.Hour)
+	cards := &store.MockRepoCardStore{}
+	app.store.RepoCards = cards
+
+	token := signTestToken(t, jwt.MapClaims{"sub": int64(42), "exp": time.Now().Add(time.Minute).Unix()})
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should find repository links", func(t *testing.T) {
+		for text, want := range map[string]string{
+			"see https://github.com/golang/go for more": "golang/go",
+			"clone https://github.com/user/repo.git":    "user/repo",
+			"https://github.com/settings/profile":       "",
+			"https://gitlab.com/golang/go":              "",
+		} {
+			owner, name, ok := github.FindRepo(text)
+			if got := owner + "/" + name; ok != (want != "") || ok && got != want {
+				t.Errorf("FindRepo(%q) = %q, %v, want %q", text, got, ok, want)
+			}
+		}
+	})
+
+	t.Run("should attach a card once fetched", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/posts", `{"title":"Go","content":"Star https://github.com/golang/go"}`).Code)
+		if len(cards.Cards) != 1 {
+			t.Fatalf("expected a pending card, got %+v", cards.Cards)
+		}
+		var postID int64
+		for id := range cards.Cards {
+			postID = id
+		}
+
+		path := "/v1/posts/" + strconv.FormatInt(postID, 10)
+		card := func(t *testing.T) *store.RepoCard {
+			t.Helper()
+			rr := request(t, http.MethodGet, path, "")
+			checkResponseCode(t, http.StatusOK, rr.Code)
+			return decodeData[struct {
+				RepoCard *store.RepoCard `json:"repo_card"`
+			}](t, rr.Body.String()).RepoCard
+		}
+		if got := card(t); got != nil {
+			t.Errorf("expected no card before fetching, got %+v", got)
+		}
+		if err := app.fetchRepoCards(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got := card(t); got == nil || got.Stars != 120000 || got.Description != "The Go programming language" {
+			t.Errorf("expected the golang/go card, got %+v", got)
+		}
+	})
+
+	t.Run("should drop cards of missing repositories", func(t *testing.T) {
+		cards.Add(context.Background(), 99, "nobody", "nothing")
+		if err := app.fetchRepoCards(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cards.Cards[99]; ok {
+			t.Error("expected the card to be removed")
+		}
+	})
+
+	t.Run("should stop asking once rate limited", func(t *testing.T) {
+		limited.Store(true)
+		cards.Add(context.Background(), 100, "someone", "a")
+		cards.Add(context.Background(), 101, "someone", "b")
+		before := calls.Load()
+		for range 2 {
+			if err := app.fetchRepoCards(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := calls.Load() - before; got != 1 {
+			t.Errorf("expected 1 request until the limit resets, got %d", got)
+		}
+		if c := cards.Cards[100]; !c.FetchedAt.IsZero() {
+			t.Errorf("expected the card to stay pending, got %+v", c)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// retentionConfig paces the purge of posts past their authors' retention
+// setting, and of expired posts such as stories.
+type retentionConfig struct {
+	interval  time.Duration
+	batchSize int
+}
+
+type PostRetentionPayload struct {
+	// Months after which posts are deleted, null keeps them for good.
+	Months *int `json:"months" validate:"omitempty,min=1,max=120"`
+}
+
+// SetPostRetention godoc
+//
+//	@Summary		Set post retention
+//	@Description	Opts into deleting the authenticated user's posts once they are older than the given number of months, null opts out. Posts the user bookmarked and posts under a legal hold are kept
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		PostRetentionPayload	true	"Retention"
+//	@Success		204		{string}	string					"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/retention [put]
+func (app *application) setPostRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload PostRetentionPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Users.SetPostRetention(ctx, user.ID, payload.Months); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	app.auditLog("user.retention", user.ID, "months", payload.Months)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeExpiredPosts deletes expired posts batch by batch until none are left.
+func (app *application) purgeExpiredPosts(ctx context.Context) error {
+	total := 0
+	for {
+		ids, err := app.store.Posts.PurgeExpired(ctx, app.config.retention.batchSize)
+		if err != nil {
+			return err
+		}
+		total += len(ids)
+		if len(ids) == 0 || len(ids) < app.config.retention.batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		app.logger.Infow("purged expired posts", "count", total)
+	}
+	return nil
+}
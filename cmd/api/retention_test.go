@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// purgingPostStore has expired posts left to purge.
+type purgingPostStore struct {
+	store.MockPostStore
+	expired []int64
+	calls   int
+}
+
+func (m *purgingPostStore) PurgeExpired(ctx context.Context, limit int) ([]int64, error) {
+	m.calls++
+	n := min(limit, len(m.expired))
+	ids := m.expired[:n]
+	m.expired = m.expired[n:]
+	return ids, nil
+}
+
+func TestPostRetention(t *testing.T) {
+	app := NewTestApplication(t, config{retention: retentionConfig{batchSize: 2}})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"should opt in", `{"months":6}`, http.StatusNoContent},
+		{"should opt out", `{"months":null}`, http.StatusNoContent},
+		{"should reject zero months", `{"months":0}`, http.StatusBadRequest},
+		{"should reject more than ten years", `{"months":121}`, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, "/v1/users/me/retention", strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			checkResponseCode(t, tc.want, executeRequest(req, app.mount()).Code)
+		})
+	}
+
+	t.Run("should purge in batches until nothing is left", func(t *testing.T) {
+		posts := &purgingPostStore{expired: []int64{1, 2, 3, 4, 5}}
+		app.store.Posts = posts
+		if err := app.purgeExpiredPosts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(posts.expired) != 0 || posts.calls != 3 {
+			t.Errorf("expected 3 batches to purge everything, got %d calls and %v left", posts.calls, posts.expired)
+		}
+	})
+}
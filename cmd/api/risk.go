@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Weights of the risk signals, added to the account's score.
+const (
+	disposableEmailWeight  = 30
+	burstPostingWeight     = 10
+	duplicateContentWeight = 20
+)
+
+// riskConfig tunes spam signals. Accounts scoring throttleScore or more may
+// post once per throttleInterval, zero disables throttling. A burst is
+// burstPosts posts within burstWindow, zero disables the signal.
+type riskConfig struct {
+	disposableDomains []string
+	burstPosts        int
+	burstWindow       time.Duration
+	duplicateWindow   time.Duration
+	throttleScore     int
+	throttleInterval  time.Duration
+}
+
+func (app *application) recordRiskSignal(ctx context.Context, userID int64, signal string, weight int, detail string, dedupe time.Duration) {
+	sig := &store.RiskSignal{UserID: userID, Signal: signal, Weight: weight, Detail: detail}
+	recorded, err := app.store.Risk.Record(ctx, sig, dedupe)
+	if err != nil {
+		app.logger.Errorw("error recording risk signal", "user_id", userID, "signal", signal, "error", err.Error())
+		return
+	}
+	if recorded {
+		app.logger.Infow("risk signal recorded", "user_id", userID, "signal", signal, "weight", weight)
+	}
+}
+
+// recordSignupSignals scores a new account. It never fails the signup.
+func (app *application) recordSignupSignals(ctx context.Context, user *store.User) {
+	_, domain, _ := strings.Cut(strings.ToLower(user.Email), "@")
+	for _, disposable := range app.config.risk.disposableDomains {
+		if domain == disposable {
+			app.recordRiskSignal(ctx, user.ID, store.RiskDisposableEmail, disposableEmailWeight, domain, 0)
+			return
+		}
+	}
+}
+
+// recordPostSignals scores the author of a new post for bursts and for
+// content already posted by other accounts. It never fails the post.
+func (app *application) recordPostSignals(ctx context.Context, post *store.Post) {
+	cfg := app.config.risk
+	if cfg.burstPosts > 0 {
+		count, err := app.store.Risk.RecentPostCount(ctx, post.UserID, time.Now().Add(-cfg.burstWindow))
+		if err != nil {
+			app.logger.Errorw("error counting recent posts", "user_id", post.UserID, "error", err.Error())
+		} else if count >= cfg.burstPosts {
+			detail := fmt.Sprintf("%d posts in %s", count, cfg.burstWindow)
+			app.recordRiskSignal(ctx, post.UserID, store.RiskBurstPosting, burstPostingWeight, detail, cfg.burstWindow)
+		}
+	}
+
+	others, err := app.store.Risk.CountOtherAuthors(ctx, post.UserID, post.Fingerprint, time.Now().Add(-cfg.duplicateWindow))
+	if err != nil {
+		app.logger.Errorw("error looking up duplicate content", "post_id", post.ID, "error", err.Error())
+		return
+	}
+	if others > 0 {
+		detail := fmt.Sprintf("post %d matches content of %d other accounts", post.ID, others)
+		app.recordRiskSignal(ctx, post.UserID, store.RiskDuplicateContent, duplicateContentWeight, detail, cfg.duplicateWindow)
+	}
+}
+
+// postThrottle returns how long a risky account has to wait before posting
+// again, zero when it may post now.
+func (app *application) postThrottle(ctx context.Context, userID int64) (time.Duration, error) {
+	cfg := app.config.risk
+	if cfg.throttleScore <= 0 {
+		return 0, nil
+	}
+	score, err := app.store.Risk.Score(ctx, userID)
+	if err != nil || score < cfg.throttleScore {
+		return 0, err
+	}
+	recent, err := app.store.Risk.RecentPostCount(ctx, userID, time.Now().Add(-cfg.throttleInterval))
+	if err != nil || recent == 0 {
+		return 0, err
+	}
+	return cfg.throttleInterval, nil
+}
+
+// ListRiskyUsers godoc
+//
+//	@Summary		List risky accounts
+//	@Description	Accounts by descending risk score
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			min_score	query		int	false	"Minimum score (default 1)"
+//	@Param			limit		query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset		query		int	false	"Offset"
+//	@Success		200			{object}	[]store.UserRisk
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/users [get]
+func (app *application) listRiskyUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	minScore := 1
+	if raw := r.URL.Query().Get("min_score"); raw != "" {
+		if minScore, err = strconv.Atoi(raw); err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+	}
+
+	users, err := app.store.Risk.ListRisky(r.Context(), minScore, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range users {
+		if users[i].Birthdate != nil {
+			adult := app.adultBirthdate(users[i].Birthdate)
+			users[i].Adult = &adult
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, users); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetUserRisk godoc
+//
+//	@Summary		Account risk
+//	@Description	An account's risk score with its latest signals
+//	@Tags			moderation
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.UserRisk
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/users/{userID}/risk [get]
+func (app *application) getUserRiskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	risk, err := app.store.Risk.Get(r.Context(), userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if risk.Birthdate != nil {
+		adult := app.adultBirthdate(risk.Birthdate)
+		risk.Adult = &adult
+	}
+	if err := app.jsonResponse(w, http.StatusOK, risk); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ClearUserRisk godoc
+//
+//	@Summary		Clear account risk
+//	@Description	Drops an account's signals and resets its score after review
+//	@Tags			moderation
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/moderation/users/{userID}/risk [delete]
+func (app *application) clearUserRiskHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Risk.Clear(r.Context(), userID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.logger.Infow("risk cleared", "user_id", userID, "moderator_id", getUserFromContext(r).ID)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRiskSignals(t *testing.T) {
+	cfg := config{risk: riskConfig{
+		disposableDomains: []string{"mailinator.com"},
+		burstPosts:        3,
+		burstWindow:       time.Minute,
+		duplicateWindow:   time.Hour,
+		throttleScore:     30,
+		throttleInterval:  time.Minute,
+	}}
+
+	t.Run("should flag disposable email domains", func(t *testing.T) {
+		app := NewTestApplication(t, cfg)
+		risk := &store.MockRiskStore{}
+		app.store.Risk = risk
+
+		app.recordSignupSignals(context.Background(), &store.User{ID: 7, Email: "spam@Mailinator.com"})
+		app.recordSignupSignals(context.Background(), &store.User{ID: 8, Email: "gopher@example.com"})
+		if len(risk.Signals) != 1 || risk.Signals[0].Signal != store.RiskDisposableEmail || risk.Signals[0].UserID != 7 {
+			t.Errorf("expected one disposable email signal for user 7, got %+v", risk.Signals)
+		}
+	})
+
+	t.Run("should flag bursts and content shared across accounts", func(t *testing.T) {
+		app := NewTestApplication(t, cfg)
+		risk := &store.MockRiskStore{RecentPosts: 3, OtherAuthors: 2}
+		app.store.Risk = risk
+
+		app.recordPostSignals(context.Background(), &store.Post{ID: 1, UserID: 7})
+		if got := risk.Scores[7]; got != burstPostingWeight+duplicateContentWeight {
+			t.Errorf("expected score %d, got %d", burstPostingWeight+duplicateContentWeight, got)
+		}
+	})
+
+	t.Run("should throttle posting above the score threshold", func(t *testing.T) {
+		app := NewTestApplication(t, cfg)
+		app.store.Risk = &store.MockRiskStore{Scores: map[int64]int{42: 30}, RecentPosts: 1}
+		mux := app.mount()
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/", strings.NewReader(`{"title":"hello","content":"gophers"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		checkResponseCode(t, http.StatusTooManyRequests, rr.Code)
+		if rr.Header().Get("Retry-After") == "" {
+			t.Error("expected a Retry-After header")
+		}
+	})
+}
+
+func TestModerationRoutes(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should forbid regular users", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/moderation/users"))
+	})
+
+	app.store.Users = &adminUserStore{}
+	mux = app.mount()
+	t.Run("should let moderators review and clear accounts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/moderation/users?min_score=20"))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/moderation/users/7/risk"))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/moderation/users/7/risk"))
+	})
+}
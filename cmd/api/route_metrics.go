@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// routeLabels maps a request's method and chi route patterns to its metrics
+// label, e.g. "GET /v1/posts/{postID}". Labels come from the router's
+// patterns rather than the URL, so there is one per route however many
+// posts or users get requested.
+type routeLabels struct {
+	mu     sync.RWMutex
+	labels map[string]string
+}
+
+// maxRouteKey bounds the lookup key built on the stack. Longer keys, which
+// no route in this API comes near, are built on the heap.
+const maxRouteKey = 256
+
+// label is on the request path, so a known route costs no allocation: the
+// lookup key is assembled in a stack buffer and the map lookup converts it
+// without copying. Only the first request of a route builds its label.
+func (l *routeLabels) label(method string, rctx *chi.Context) string {
+	if rctx == nil || len(rctx.RoutePatterns) == 0 {
+		return "unmatched"
+	}
+	method = metricsMethod(method)
+
+	var buf [maxRouteKey]byte
+	key := append(buf[:0], method...)
+	for _, p := range rctx.RoutePatterns {
+		key = append(key, p...)
+	}
+
+	l.mu.RLock()
+	label, ok := l.labels[string(key)]
+	l.mu.RUnlock()
+	if ok {
+		return label
+	}
+
+	label = method + " " + rctx.RoutePattern()
+	l.mu.Lock()
+	if l.labels == nil {
+		l.labels = make(map[string]string)
+	}
+	l.labels[string(key)] = label
+	l.mu.Unlock()
+	return label
+}
+
+// metricsMethod folds methods the API does not serve into one, so made up
+// methods can't add labels either.
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "OTHER"
+}
+
+// RouteMetricsMiddleware records the latency of every request under its route
+// label, published at /debug/vars as routes. It has to wrap the router:
+// the route patterns are only known once routing is done, so the label is
+// read from the chi context after the handler returns.
+func (app *application) RouteMetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		defer func() {
+			label := app.routeLabels.label(r.Method, chi.RouteContext(r.Context()))
+			app.routeMetrics.Observe(label, time.Since(start), 0)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
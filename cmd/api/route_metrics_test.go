@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/metrics"
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestRouteMetrics(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := func(t *testing.T, method, path string) {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		executeRequest(req, mux)
+	}
+
+	t.Run("should label requests by route pattern", func(t *testing.T) {
+		request(t, http.MethodGet, "/v1/posts/1")
+		request(t, http.MethodGet, "/v1/posts/2")
+		request(t, http.MethodGet, "/v1/health")
+		request(t, http.MethodTrace, "/v1/health")
+		request(t, http.MethodGet, "/nope")
+
+		snapshot := app.routeMetrics.Snapshot()
+		for label, count := range map[string]int64{
+			"GET /v1/posts/{postID}": 2,
+			"GET /v1/health":         1,
+			"OTHER /v1/*":            1,
+			"unmatched":              1,
+		} {
+			if snapshot[label].Count != count {
+				t.Errorf("got %d requests for %q, want %d", snapshot[label].Count, label, count)
+			}
+		}
+		if len(snapshot) != 4 {
+			t.Errorf("unexpected labels %v", snapshot)
+		}
+	})
+
+	t.Run("should not allocate for a known route", func(t *testing.T) {
+		rctx := chi.NewRouteContext()
+		rctx.RoutePatterns = []string{"/v1/*", "/posts/*", "/{postID}/*", "/"}
+		labels := &routeLabels{}
+		if got := labels.label(http.MethodGet, rctx); got != "GET /v1/posts/{postID}" {
+			t.Fatalf("got label %q", got)
+		}
+		allocs := testing.AllocsPerRun(100, func() {
+			labels.label(http.MethodGet, rctx)
+		})
+		if allocs != 0 {
+			t.Fatalf("got %v allocations per label, want 0", allocs)
+		}
+	})
+}
+
+func BenchmarkRouteMetrics(b *testing.B) {
+	app := &application{routeMetrics: metrics.NewQueryRecorder(metrics.DefaultWindow)}
+	handler := app.RouteMetricsMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rctx := chi.NewRouteContext()
+	rctx.RoutePatterns = []string{"/v1/*", "/posts/*", "/{postID}/*", "/"}
+	req, err := http.NewRequestWithContext(context.WithValue(context.Background(), chi.RouteCtxKey, rctx), http.MethodGet, "/v1/posts/1", nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for range b.N {
+		handler.ServeHTTP(nil, req)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+)
+
+type scopesKey string
+
+const scopesCtx scopesKey = "scopes"
+
+// getScopesFromContext returns the scopes the request's token carries, nil
+// for unrestricted tokens such as the user's own sessions.
+func getScopesFromContext(r *http.Request) []string {
+	scopes, _ := r.Context().Value(scopesCtx).([]string)
+	return scopes
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// scopedDenied are the routes scoped tokens never reach: managing and
+// authorizing apps, administration and reauthentication.
+var scopedDenied = []string{"/v1/oauth/", "/v1/admin/", "/v1/auth/"}
+
+// scopesAllow is the coarse check AuthTokenMiddleware runs for scoped tokens
+// on every route, requireScope narrows it per resource. Changes need some
+// write scope.
+func scopesAllow(r *http.Request, scopes []string) bool {
+	for _, prefix := range scopedDenied {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return false
+		}
+	}
+	if isSafeMethod(r.Method) {
+		return len(scopes) > 0
+	}
+	for _, s := range scopes {
+		if strings.HasPrefix(s, "write:") {
+			return true
+		}
+	}
+	return false
+}
+
+// requireScope limits scoped tokens on the routes to "read:<resource>" for
+// safe methods and "write:<resource>" otherwise. Unrestricted tokens pass.
+func (app *application) requireScope(resource string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes := getScopesFromContext(r)
+			if scopes != nil && !store.HasScope(scopes, !isSafeMethod(r.Method), resource) {
+				app.forbiddenResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
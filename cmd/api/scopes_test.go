@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestScopedAPIKeys(t *testing.T) {
+	app := NewTestApplication(t, config{auth: authConfig{sudoWindow: time.Minute}})
+	app.store.Users = &serviceUserStore{}
+	testToken := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix(), "auth_time": time.Now().Unix()})
+
+	request := func(t *testing.T, method, path, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should reject unknown scopes", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/admin/service-accounts/100/keys", testToken, `{"name":"reader","scopes":["read:dms"]}`)
+		checkResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	rr := request(t, http.MethodPost, "/v1/admin/service-accounts/100/keys", testToken, `{"name":"reader","scopes":["read:posts"]}`)
+	checkResponseCode(t, http.StatusCreated, rr.Code)
+	secret := decodeData[APIKeyCreated](t, rr.Body.String()).Secret
+
+	t.Run("should let a read only key read posts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/hashtags/trending", secret, "").Code)
+	})
+
+	t.Run("should keep a read only key from posting or reaching other resources", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/posts", secret, `{"title":"t","content":"c"}`)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/users/me/notifications", secret, "").Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodGet, "/v1/announcements/", secret, "").Code)
+	})
+
+	t.Run("should leave keys without scopes unrestricted", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/admin/service-accounts/100/keys", testToken, `{"name":"full"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		full := decodeData[APIKeyCreated](t, rr.Body.String())
+		if full.Key.Scopes != nil {
+			t.Fatalf("expected no scopes, got %v", full.Key.Scopes)
+		}
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/announcements/", full.Secret, "").Code)
+	})
+}
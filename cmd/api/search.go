@@ -0,0 +1,61 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"time"
+)
+
+// searchPostsHandler godoc
+//
+//	@Summary		Search posts
+//	@Description	Search posts with structured filters. q also accepts operators: from:user since:2024-01-01 until:2024-02-01 has:media min_reactions:10, explicit parameters win over them
+//	@Tags			search
+//	@Produce		json
+//	@Param			q				query		string	false	"Text and operators"
+//	@Param			author			query		string	false	"Author username"
+//	@Param			since			query		string	false	"Created on or after (YYYY-MM-DD or RFC 3339)"
+//	@Param			until			query		string	false	"Created before, a date includes that day"
+//	@Param			has_media		query		bool	false	"Only posts embedding media"
+//	@Param			min_reactions	query		int		false	"Minimum number of reactions"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			offset			query		int		false	"Offset"
+//	@Success		200				{object}	[]store.PostWithMetadata
+//	@Failure		400				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/search/posts [get]
+func (app *application) searchPostsHandler(w http.ResponseWriter, r *http.Request) {
+	sq, err := store.PostSearchQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(sq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	sq.HideAgeRestricted = !app.isAdult(user)
+	ctx := r.Context()
+	posts, err := app.store.Posts.Search(ctx, sq)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	collapseWarned(posts, user)
+	stampAges(posts, time.Now())
+	if err := app.hydrateFeedAuthors(ctx, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderFeed(posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/search"
+	"gopher_social/internal/store"
+	"time"
+)
+
+const (
+	// maxIndexAttempts is how often an outbox event is retried before it is
+	// left for a reindex to pick up.
+	maxIndexAttempts = 10
+	indexBatch       = 200
+	// outboxRetention is how long processed outbox events are kept around.
+	outboxRetention = time.Hour * 24 * 7
+)
+
+// indexPosts is the job draining search.post events into the search index.
+// Each event only names the post, its current state decides between upsert
+// and delete, so replays and out of order events converge.
+func (app *application) indexPosts(ctx context.Context) error {
+	events, err := app.store.Outbox.GetPending(ctx, store.TopicSearchPost, maxIndexAttempts, indexBatch)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	eventIDs := make([]int64, 0, len(events))
+	postIDs := make([]int64, 0, len(events))
+	seen := make(map[int64]bool, len(events))
+	for _, e := range events {
+		eventIDs = append(eventIDs, e.ID)
+		if !seen[e.AggregateID] {
+			seen[e.AggregateID] = true
+			postIDs = append(postIDs, e.AggregateID)
+		}
+	}
+
+	if err := app.syncPosts(ctx, postIDs); err != nil {
+		app.logger.Warnw("search indexing failed", "events", len(eventIDs), "error", err.Error())
+		return app.store.Outbox.MarkFailed(ctx, eventIDs, err.Error())
+	}
+	return app.store.Outbox.MarkProcessed(ctx, eventIDs)
+}
+
+func (app *application) syncPosts(ctx context.Context, ids []int64) error {
+	posts, err := app.store.Posts.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	docs := make([]search.Document, 0, len(posts))
+	found := make(map[int64]bool, len(posts))
+	// GetByIDs leaves out stories, so they are dropped from the index like
+	// deleted posts.
+	for _, p := range posts {
+		found[p.ID] = true
+		docs = append(docs, search.Document{
+			ID:        p.ID,
+			UserID:    p.UserID,
+			Title:     p.Title,
+			Content:   p.Content,
+			Tags:      p.Tags,
+			Lang:      p.Lang,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+	var deleted []int64
+	for _, id := range ids {
+		if !found[id] {
+			deleted = append(deleted, id)
+		}
+	}
+
+	if err := app.searchIndex.Upsert(ctx, docs); err != nil {
+		return err
+	}
+	return app.searchIndex.Delete(ctx, deleted)
+}
+
+// pruneOutbox drops processed events. With search or the broker disabled
+// nothing consumes their topic, so those are dropped too instead of piling
+// up.
+func (app *application) pruneOutbox(ctx context.Context) error {
+	var abandoned []string
+	if !app.config.search.enabled {
+		abandoned = append(abandoned, store.TopicSearchPost)
+	}
+	if app.broker == nil {
+		abandoned = append(abandoned, store.TopicBrokerEvent)
+	}
+	return app.store.Outbox.Prune(ctx, time.Now().Add(-outboxRetention), abandoned)
+}
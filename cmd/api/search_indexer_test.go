@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/search"
+	"gopher_social/internal/store"
+	"testing"
+)
+
+type fakeIndex struct {
+	docs    map[int64]search.Document
+	deleted []int64
+	err     error
+}
+
+func (f *fakeIndex) Upsert(ctx context.Context, docs []search.Document) error {
+	if f.err != nil {
+		return f.err
+	}
+	for _, d := range docs {
+		f.docs[d.ID] = d
+	}
+	return nil
+}
+func (f *fakeIndex) Delete(ctx context.Context, ids []int64) error {
+	f.deleted = append(f.deleted, ids...)
+	return f.err
+}
+func (f *fakeIndex) Reset(ctx context.Context) error {
+	f.docs = map[int64]search.Document{}
+	return f.err
+}
+
+func TestIndexPosts(t *testing.T) {
+	events := []store.OutboxEvent{
+		{ID: 1, Topic: store.TopicSearchPost, AggregateID: 7},
+		{ID: 2, Topic: store.TopicSearchPost, AggregateID: 8},
+		{ID: 3, Topic: store.TopicSearchPost, AggregateID: 7},
+	}
+
+	t.Run("should upsert each post once and mark events processed", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		index := &fakeIndex{docs: map[int64]search.Document{}}
+		app.searchIndex = index
+		outbox := &store.MockOutboxStore{Pending: events}
+		app.store.Outbox = outbox
+
+		if err := app.indexPosts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(index.docs) != 2 {
+			t.Errorf("expected 2 indexed posts, got %d", len(index.docs))
+		}
+		if len(outbox.Processed) != 3 || len(outbox.Failed) != 0 {
+			t.Errorf("expected 3 processed events, got %d processed and %d failed", len(outbox.Processed), len(outbox.Failed))
+		}
+	})
+
+	t.Run("should mark events failed when the backend is down", func(t *testing.T) {
+		app := NewTestApplication(t, config{})
+		app.searchIndex = &fakeIndex{docs: map[int64]search.Document{}, err: errors.New("connection refused")}
+		outbox := &store.MockOutboxStore{Pending: events}
+		app.store.Outbox = outbox
+
+		if err := app.indexPosts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(outbox.Failed) != 3 || len(outbox.Processed) != 0 {
+			t.Errorf("expected 3 failed events, got %d failed and %d processed", len(outbox.Failed), len(outbox.Processed))
+		}
+	})
+}
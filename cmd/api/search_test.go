@@ -0,0 +1,71 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSearchPosts(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	posts := &store.MockPostStore{}
+	app.store.Posts = posts
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(t *testing.T, params url.Values) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/search/posts?"+params.Encode(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, mux).Code
+	}
+
+	t.Run("should parse operators out of the text", func(t *testing.T) {
+		code := search(t, url.Values{"q": {"gopher from:@alice since:2024-01-01 until:2024-01-31 has:media min_reactions:5 note:x"}})
+		checkResponseCode(t, http.StatusOK, code)
+
+		sq := posts.LastSearch
+		if sq.Text != "gopher note:x" {
+			t.Errorf("expected text %q, got %q", "gopher note:x", sq.Text)
+		}
+		if sq.Author != "alice" || !sq.HasMedia || sq.MinReactions != 5 {
+			t.Errorf("unexpected filters: %+v", sq)
+		}
+		if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !sq.Since.Equal(want) {
+			t.Errorf("expected since %v, got %v", want, sq.Since)
+		}
+		if want := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC); !sq.Until.Equal(want) {
+			t.Errorf("expected until to include the whole day, got %v", sq.Until)
+		}
+	})
+
+	t.Run("should prefer explicit parameters over operators", func(t *testing.T) {
+		code := search(t, url.Values{"q": {"from:alice"}, "author": {"bob"}, "has_media": {"false"}})
+		checkResponseCode(t, http.StatusOK, code)
+		if posts.LastSearch.Author != "bob" {
+			t.Errorf("expected author bob, got %q", posts.LastSearch.Author)
+		}
+	})
+
+	for _, tc := range []struct {
+		name   string
+		params url.Values
+	}{
+		{"should reject an invalid date", url.Values{"since": {"yesterday"}}},
+		{"should reject an invalid date operator", url.Values{"q": {"until:01/02/2024"}}},
+		{"should reject negative reactions", url.Values{"min_reactions": {"-1"}}},
+		{"should reject a large limit", url.Values{"limit": {"100"}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			checkResponseCode(t, http.StatusBadRequest, search(t, tc.params))
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// seoConfig configures the sitemaps. sitemapURL is the public base they
+// are served under, the index links to the pages there. Each page lists up
+// to pageSize URLs, the protocol allows 50,000.
+type seoConfig struct {
+	sitemapURL string
+	pageSize   int
+}
+
+// Formats of the public pages: sitemaps are XML, pages are JSON unless HTML
+// is asked for, as crawlers and link previews do.
+const (
+	formatJSON = "json"
+	formatHTML = "html"
+	formatXML  = "xml"
+)
+
+var formatContentTypes = map[string]string{
+	formatJSON: "application/json",
+	formatHTML: "text/html; charset=utf-8",
+	formatXML:  "application/xml; charset=utf-8",
+}
+
+func responseFormat(r *http.Request) string {
+	switch {
+	case strings.HasSuffix(r.URL.Path, ".xml"):
+		return formatXML
+	case !strings.HasPrefix(r.URL.Path, "/v1/"):
+		// the server rendered pages, see pagesConfig
+		return formatHTML
+	case r.URL.Query().Get("format") == "html", strings.Contains(r.Header.Get("Accept"), "text/html"):
+		return formatHTML
+	default:
+		return formatJSON
+	}
+}
+
+var sitemapSections = []string{store.SitemapUsers, store.SitemapPosts, store.SitemapTags}
+
+const sitemapNS = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	NS       string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+type urlSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	NS      string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+func (app *application) writeXML(w http.ResponseWriter, v any) error {
+	out, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", formatContentTypes[formatXML])
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// Links to the frontend's pages, what search engines should index.
+func (app *application) userPageURL(id int64) string {
+	return fmt.Sprintf("%s/users/%d", app.config.frontendURL, id)
+}
+
+func (app *application) postPageURL(id int64) string {
+	return fmt.Sprintf("%s/posts/%d", app.config.frontendURL, id)
+}
+
+func (app *application) tagPageURL(tag string) string {
+	return fmt.Sprintf("%s/tags/%s", app.config.frontendURL, url.PathEscape(tag))
+}
+
+// GetSitemapIndex godoc
+//
+//	@Summary		Sitemap index
+//	@Description	Lists the sitemap pages of public profiles, posts and hashtags
+//	@Tags			public
+//	@Produce		xml
+//	@Success		200	{string}	string
+//	@Failure		500	{object}	error
+//	@Router			/public/sitemap.xml [get]
+func (app *application) sitemapIndexHandler(w http.ResponseWriter, r *http.Request) {
+	counts, err := app.store.Sitemap.Counts(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	index := sitemapIndex{NS: sitemapNS}
+	size := app.config.seo.pageSize
+	for _, section := range sitemapSections {
+		for page := 1; (page-1)*size < counts[section]; page++ {
+			index.Sitemaps = append(index.Sitemaps, sitemapURL{
+				Loc: fmt.Sprintf("%s/sitemaps/%s-%d.xml", app.config.seo.sitemapURL, section, page),
+			})
+		}
+	}
+	if err := app.writeXML(w, index); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetSitemap godoc
+//
+//	@Summary		Sitemap page
+//	@Description	A page of public profiles, posts or hashtags linking to the frontend
+//	@Tags			public
+//	@Produce		xml
+//	@Param			section	path		string	true	"users, posts or tags"
+//	@Param			page	path		int		true	"Page, from 1"
+//	@Success		200		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/public/sitemaps/{section}-{page}.xml [get]
+func (app *application) sitemapHandler(w http.ResponseWriter, r *http.Request) {
+	section := chi.URLParam(r, "section")
+	page, err := strconv.Atoi(chi.URLParam(r, "page"))
+	if err != nil || page < 1 {
+		app.badRequestResponse(w, r, errors.New("page must be a positive number"))
+		return
+	}
+	found := false
+	for _, s := range sitemapSections {
+		found = found || s == section
+	}
+	if !found {
+		app.notFoundResponse(w, r, fmt.Errorf("unknown sitemap %q", section))
+		return
+	}
+
+	size := app.config.seo.pageSize
+	entries, err := app.store.Sitemap.Entries(r.Context(), section, size, (page-1)*size)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if len(entries) == 0 && page > 1 {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	set := urlSet{NS: sitemapNS, URLs: make([]sitemapURL, len(entries))}
+	for i, e := range entries {
+		var loc string
+		switch section {
+		case store.SitemapUsers:
+			loc = app.userPageURL(e.ID)
+		case store.SitemapPosts:
+			loc = app.postPageURL(e.ID)
+		case store.SitemapTags:
+			loc = app.tagPageURL(e.Tag)
+		}
+		set.URLs[i] = sitemapURL{Loc: loc, LastMod: e.Modified.UTC().Format(time.DateOnly)}
+	}
+	if err := app.writeXML(w, set); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// seoPage is the HTML served to crawlers: the content with its schema.org
+// description as JSON-LD and OpenGraph and Twitter card tags for link
+// previews, pointing to the frontend page as canonical.
+type seoPage struct {
+	Lang        string
+	SiteName    string
+	Title       string
+	Description string
+	URL         string
+	OGType      string
+	// Image is the preview image, a post's first embedded one.
+	Image     string
+	Author    *seoLink
+	Published string
+	Tags      []seoLink
+	Body      template.HTML
+	JSONLD    map[string]any
+}
+
+type seoLink struct {
+	Name string
+	URL  string
+}
+
+var seoTemplate = template.Must(template.New("seo").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta name="description" content="{{.Description}}">
+<link rel="canonical" href="{{.URL}}">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:type" content="{{.OGType}}">
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:description" content="{{.Description}}">
+<meta property="og:url" content="{{.URL}}">
+{{- with .Image}}
+<meta property="og:image" content="{{.}}">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="{{.}}">
+{{- else}}
+<meta name="twitter:card" content="summary">
+{{- end}}
+<meta name="twitter:title" content="{{.Title}}">
+<meta name="twitter:description" content="{{.Description}}">
+<script type="application/ld+json">{{.JSONLD}}</script>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{- with .Author}}
+<p>by <a href="{{.URL}}">{{.Name}}</a>{{with $.Published}} on <time datetime="{{.}}">{{.}}</time>{{end}}</p>
+{{- end}}
+{{.Body}}
+{{- with .Tags}}
+<p>{{range .}}<a href="{{.URL}}">#{{.Name}}</a> {{end}}</p>
+{{- end}}
+</main>
+</body>
+</html>
+`))
+
+func (app *application) writeHTML(w http.ResponseWriter, page seoPage) error {
+	if page.Lang == "" {
+		page.Lang = "en"
+	}
+	page.SiteName = app.config.pages.siteName
+	page.JSONLD["@context"] = "https://schema.org"
+	w.Header().Set("Content-Type", formatContentTypes[formatHTML])
+	w.WriteHeader(http.StatusOK)
+	return seoTemplate.Execute(w, page)
+}
+
+// postImage finds the first image a post embeds.
+var postImage = regexp.MustCompile(`!\[[^\]]*\]\(([^)\s]+)`)
+
+func (app *application) postSEOPage(post *store.Post) seoPage {
+	url := app.postPageURL(post.ID)
+	ld := map[string]any{
+		"@type":         "SocialMediaPosting",
+		"headline":      post.Title,
+		"text":          post.Content,
+		"url":           url,
+		"datePublished": post.CreatedAt,
+		"dateModified":  post.UpdatedAt,
+		"keywords":      post.Tags,
+		"commentCount":  post.CommentCount,
+		"author": map[string]any{
+			"@type": "Person",
+			"name":  post.User.Username,
+			"url":   app.userPageURL(post.UserID),
+		},
+	}
+	if post.Kind == store.PostKindArticle {
+		ld["@type"] = "Article"
+		ld["abstract"] = post.Content
+		delete(ld, "text")
+	}
+	if post.Lang != "" {
+		ld["inLanguage"] = post.Lang
+	}
+	tags := make([]seoLink, len(post.Tags))
+	for i, tag := range post.Tags {
+		tags[i] = seoLink{Name: tag, URL: app.tagPageURL(tag)}
+	}
+	image := ""
+	if m := postImage.FindStringSubmatch(post.Content); m != nil {
+		image = m[1]
+		ld["image"] = image
+	}
+	return seoPage{
+		Lang:        post.Lang,
+		Title:       post.Title,
+		Description: excerpt(post.Content, 160),
+		URL:         url,
+		OGType:      "article",
+		Image:       image,
+		Author:      &seoLink{Name: post.User.Username, URL: app.userPageURL(post.UserID)},
+		Published:   post.CreatedAt.Format(time.RFC3339),
+		Tags:        tags,
+		Body:        template.HTML(post.ContentHTML),
+		JSONLD:      ld,
+	}
+}
+
+func (app *application) profileSEOPage(p *store.Profile) seoPage {
+	url := app.userPageURL(p.ID)
+	description := fmt.Sprintf("%s has %d followers and %d posts.", p.Username, p.Followers, p.Posts)
+	return seoPage{
+		Title:       p.Username,
+		Description: description,
+		URL:         url,
+		OGType:      "profile",
+		Body:        template.HTML("<p>" + template.HTMLEscapeString(description) + "</p>"),
+		JSONLD: map[string]any{
+			"@type":       "ProfilePage",
+			"dateCreated": p.CreatedAt,
+			"mainEntity": map[string]any{
+				"@type":      "Person",
+				"name":       p.Username,
+				"identifier": p.ID,
+				"url":        url,
+				"interactionStatistic": []map[string]any{
+					{"@type": "InteractionCounter", "interactionType": "https://schema.org/FollowAction", "userInteractionCount": p.Followers},
+					{"@type": "InteractionCounter", "interactionType": "https://schema.org/WriteAction", "userInteractionCount": p.Posts},
+				},
+			},
+		},
+	}
+}
+
+// loadPublicPost fetches and renders the post of the request if anonymous
+// visitors may see it, answering the error itself when it can't.
+func (app *application) loadPublicPost(w http.ResponseWriter, r *http.Request) (*store.Post, bool) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "postID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+	post, err := app.store.Posts.GetByID(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			if tombstone, ok := app.lookupTombstone(w, r, id, err); ok {
+				app.postRemovedResponse(w, r, tombstone)
+			}
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return nil, false
+	}
+	if post.OnHold || post.AgeRestricted || post.Kind == store.PostKindStory {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return nil, false
+	}
+	if err := app.renderPost(post); err != nil {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+	return post, true
+}
+
+// GetPublicPost godoc
+//
+//	@Summary		Public post
+//	@Description	A post as seen by an anonymous visitor. With format=html or an Accept header asking for HTML it is served as a page with JSON-LD structured data for crawlers. Age restricted posts and stories are not public
+//	@Tags			public
+//	@Produce		json
+//	@Produce		html
+//	@Param			postID	path		int		true	"Post ID"
+//	@Param			format	query		string	false	"html for the crawler page"
+//	@Success		200		{object}	store.Post
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		410		{object}	error	"Removed by a moderator, the body holds the tombstone"
+//	@Failure		500		{object}	error
+//	@Router			/public/posts/{postID} [get]
+func (app *application) publicPostHandler(w http.ResponseWriter, r *http.Request) {
+	post, ok := app.loadPublicPost(w, r)
+	if !ok {
+		return
+	}
+	var err error
+	if responseFormat(r) == formatHTML {
+		err = app.writeHTML(w, app.postSEOPage(post))
+	} else {
+		err = app.jsonResponse(w, http.StatusOK, post)
+	}
+	if err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
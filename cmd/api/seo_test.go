@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// publicPostStore serves post 1 as a public note and post 2 age restricted.
+type publicPostStore struct {
+	store.MockPostStore
+}
+
+func (m *publicPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{
+		ID: id, UserID: 42, Title: "Hello <gophers>", Content: "Go **1.23** is out", Kind: store.PostKindNote,
+		Tags: []string{"go"}, Lang: "en", AgeRestricted: id == 2, User: store.User{ID: 42, Username: "gopher"},
+	}, nil
+}
+
+func TestSEO(t *testing.T) {
+	app := NewTestApplication(t, config{
+		frontendURL: "https://gophers.example",
+		seo:         seoConfig{sitemapURL: "https://api.gophers.example/v1/public", pageSize: 2},
+	})
+	app.store.Posts = &publicPostStore{}
+	day := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	app.store.Sitemap = &store.MockSitemapStore{Sections: map[string][]store.SitemapEntry{
+		store.SitemapUsers: {{ID: 1, Modified: day}, {ID: 2, Modified: day}, {ID: 3, Modified: day}},
+		store.SitemapPosts: {{ID: 7, Modified: day}},
+		store.SitemapTags:  {{Tag: "c++", Modified: day}},
+	}}
+
+	request := func(t *testing.T, path, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return executeRequest(req, app.mount())
+	}
+	locs := func(t *testing.T, rr *httptest.ResponseRecorder) []string {
+		t.Helper()
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/xml") {
+			t.Errorf("expected XML, got %s", ct)
+		}
+		var doc struct {
+			Entries []struct {
+				Loc     string `xml:"loc"`
+				LastMod string `xml:"lastmod"`
+			} `xml:",any"`
+		}
+		if err := xml.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range doc.Entries {
+			got = append(got, e.Loc+" "+e.LastMod)
+		}
+		return got
+	}
+
+	t.Run("should index the sitemap pages", func(t *testing.T) {
+		got := locs(t, request(t, "/v1/public/sitemap.xml", ""))
+		want := []string{
+			"https://api.gophers.example/v1/public/sitemaps/users-1.xml ",
+			"https://api.gophers.example/v1/public/sitemaps/users-2.xml ",
+			"https://api.gophers.example/v1/public/sitemaps/posts-1.xml ",
+			"https://api.gophers.example/v1/public/sitemaps/tags-1.xml ",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	})
+
+	t.Run("should list frontend pages", func(t *testing.T) {
+		if got := locs(t, request(t, "/v1/public/sitemaps/users-2.xml", "")); len(got) != 1 || got[0] != "https://gophers.example/users/3 2026-10-01" {
+			t.Errorf("unexpected users page %q", got)
+		}
+		if got := locs(t, request(t, "/v1/public/sitemaps/tags-1.xml", "")); len(got) != 1 || got[0] != "https://gophers.example/tags/c++ 2026-10-01" {
+			t.Errorf("unexpected tags page %q", got)
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, "/v1/public/sitemaps/users-3.xml", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, "/v1/public/sitemaps/secrets-1.xml", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, "/v1/public/sitemaps/posts-0.xml", "").Code)
+	})
+
+	t.Run("should render public posts for crawlers", func(t *testing.T) {
+		rr := request(t, "/v1/public/posts/1", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[store.Post](t, rr.Body.String()); got.ID != 1 || !strings.Contains(got.ContentHTML, "<strong>") {
+			t.Errorf("expected the rendered post, got %+v", got)
+		}
+
+		rr = request(t, "/v1/public/posts/1", "text/html,application/xhtml+xml")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		body := rr.Body.String()
+		for _, want := range []string{
+			`<title>Hello &lt;gophers&gt;</title>`,
+			`<link rel="canonical" href="https://gophers.example/posts/1">`,
+			`<script type="application/ld+json">`,
+			`"@type":"SocialMediaPosting"`,
+			`"headline":"Hello \u003cgophers\u003e"`,
+			`<strong>1.23</strong>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected %s in %s", want, body)
+			}
+		}
+		checkResponseCode(t, http.StatusNotFound, request(t, "/v1/public/posts/2?format=html", "").Code)
+	})
+
+	t.Run("should render public profiles for crawlers", func(t *testing.T) {
+		rr := request(t, "/v1/public/users/42?format=html", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if body := rr.Body.String(); !strings.Contains(body, `"@type":"ProfilePage"`) || !strings.Contains(body, `"url":"https://gophers.example/users/42"`) {
+			t.Errorf("expected a profile page with JSON-LD, got %s", body)
+		}
+		if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("expected HTML, got %s", ct)
+		}
+	})
+}
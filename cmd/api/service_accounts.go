@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type apiKeyKey string
+
+const apiKeyCtx apiKeyKey = "apiKey"
+
+// requestAPIKey authenticates the API key the request carries, nil without a
+// valid key. RateLimiterMiddleware resolves the key first and hands the result
+// on through the context.
+func (app *application) requestAPIKey(r *http.Request) *store.APIKey {
+	if key, ok := r.Context().Value(apiKeyCtx).(*store.APIKey); ok {
+		return key
+	}
+	token, err := app.requestToken(r)
+	if err != nil || !strings.HasPrefix(token, store.APIKeyPrefix) {
+		return nil
+	}
+	key, err := app.store.APIKeys.Authenticate(r.Context(), token)
+	if err != nil {
+		if !errors.Is(err, store.ErrRecordNotFound) {
+			app.logger.Errorw("error authenticating api key", "error", err.Error())
+		}
+		return nil
+	}
+	return key
+}
+
+// withAPIKey resolves the request's API key once for the rest of the chain.
+// Requests without one are returned as is.
+func (app *application) withAPIKey(r *http.Request) (*http.Request, *store.APIKey) {
+	token, err := app.requestToken(r)
+	if err != nil || !strings.HasPrefix(token, store.APIKeyPrefix) {
+		return r, nil
+	}
+	key := app.requestAPIKey(r)
+	return r.WithContext(context.WithValue(r.Context(), apiKeyCtx, key)), key
+}
+
+type CreateServiceAccountPayload struct {
+	Username string `json:"username" validate:"required,max=100"`
+	// Email reaches whoever operates the integration.
+	Email string `json:"email" validate:"required,email,max=255"`
+	// KeyName labels the first API key, e.g. the host running the bot.
+	KeyName string `json:"key_name" validate:"max=100"`
+	// KeyScopes limit the first API key, e.g. ["read:posts"]. Without any
+	// the key is unrestricted.
+	KeyScopes []string `json:"key_scopes" validate:"omitempty,max=20,dive,scope"`
+}
+
+// ServiceAccountCreated carries the new account and its first API key. The
+// key is only ever returned here.
+type ServiceAccountCreated struct {
+	User   *store.User   `json:"user"`
+	Key    *store.APIKey `json:"key"`
+	Secret string        `json:"api_key"`
+}
+
+// CreateServiceAccount godoc
+//
+//	@Summary		Create a service account
+//	@Description	Creates a bot account for an integration, such as an RSS mirror, with a first API key. Service accounts are labeled as bots, can't sign in with a password and have their own rate limit. Requires a recent authentication
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateServiceAccountPayload	true	"Account"
+//	@Success		201		{object}	ServiceAccountCreated
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error	"Username or email taken"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/service-accounts [post]
+func (app *application) createServiceAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateServiceAccountPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	admin := getUserFromContext(r)
+	ctx := r.Context()
+	user := &store.User{Username: payload.Username, Email: payload.Email}
+	if err := app.store.Users.CreateServiceAccount(ctx, user); err != nil {
+		switch {
+		case errors.Is(err, store.ErrDuplicateEmail), errors.Is(err, store.ErrDuplicateUsername):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("service_account.create", admin.ID, "user_id", user.ID, "username", user.Username)
+
+	key, secret, err := app.issueAPIKey(ctx, user.ID, payload.KeyName, payload.KeyScopes, admin.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, ServiceAccountCreated{User: user, Key: key, Secret: secret}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+func (app *application) issueAPIKey(ctx context.Context, userID int64, name string, scopes []string, adminID int64) (*store.APIKey, string, error) {
+	secret, err := store.NewAPIKeySecret()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(scopes) == 0 {
+		scopes = nil
+	} else {
+		scopes = store.ExpandScopes(scopes)
+	}
+	key := &store.APIKey{UserID: userID, Name: name, Scopes: scopes, CreatedBy: adminID}
+	if err := app.store.APIKeys.Create(ctx, key, secret); err != nil {
+		return nil, "", err
+	}
+	app.auditLog("api_key.create", adminID, "user_id", userID, "key_id", key.ID, "scopes", key.Scopes)
+	return key, secret, nil
+}
+
+type CreateAPIKeyPayload struct {
+	Name string `json:"name" validate:"max=100"`
+	// Scopes limit the key, e.g. ["read:posts"]. Without any the key is
+	// unrestricted.
+	Scopes []string `json:"scopes" validate:"omitempty,max=20,dive,scope"`
+}
+
+// APIKeyCreated carries a new API key, it is only ever returned here.
+type APIKeyCreated struct {
+	Key    *store.APIKey `json:"key"`
+	Secret string        `json:"api_key"`
+}
+
+// CreateAPIKey godoc
+//
+//	@Summary		Create an API key
+//	@Description	Issues another API key for a service account, e.g. to rotate keys without downtime. Requires a recent authentication
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"Service account ID"
+//	@Param			payload	body		CreateAPIKeyPayload	true	"Key"
+//	@Success		201		{object}	APIKeyCreated
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"No such service account"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/service-accounts/{userID}/keys [post]
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	var payload CreateAPIKeyPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	key, secret, err := app.issueAPIKey(r.Context(), userID, payload.Name, payload.Scopes, getUserFromContext(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, APIKeyCreated{Key: key, Secret: secret}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAPIKeys godoc
+//
+//	@Summary		List API keys
+//	@Description	A service account's keys newest first, revoked ones included. The keys themselves are never shown again
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"Service account ID"
+//	@Success		200		{object}	[]store.APIKey
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/service-accounts/{userID}/keys [get]
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	keys, err := app.store.APIKeys.ListByUser(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, keys); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeAPIKey godoc
+//
+//	@Summary		Revoke an API key
+//	@Description	Disables the key immediately and for good. Requires a recent authentication
+//	@Tags			admin
+//	@Param			userID	path	int	true	"Service account ID"
+//	@Param			keyID	path	int	true	"Key ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error	"No such active key"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/service-accounts/{userID}/keys/{keyID} [delete]
+func (app *application) revokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	keyID, err := strconv.ParseInt(chi.URLParam(r, "keyID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.APIKeys.Revoke(r.Context(), userID, keyID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("api_key.revoke", getUserFromContext(r).ID, "user_id", userID, "key_id", keyID)
+	w.WriteHeader(http.StatusNoContent)
+}
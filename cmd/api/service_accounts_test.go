@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/ratelimiter"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// serviceUserStore serves the admin of the test token and service accounts
+// for every other ID.
+type serviceUserStore struct {
+	store.MockUserStore
+}
+
+func (m *serviceUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	if id == 42 {
+		return &store.User{ID: id, Role: &store.Role{Name: "admin", Level: 3}}, nil
+	}
+	return &store.User{ID: id, Role: &store.Role{Name: "user", Level: 1}, IsBot: true}, nil
+}
+
+func TestServiceAccounts(t *testing.T) {
+	app := NewTestApplication(t, config{
+		rateLimiter:    ratelimiter.Config{RequestsPerTimeFrame: 100, TimeFrame: time.Minute, Enabled: true},
+		botRateLimiter: ratelimiter.Config{RequestsPerTimeFrame: 2, TimeFrame: time.Minute},
+		auth:           authConfig{sudoWindow: time.Minute},
+	})
+	testToken := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix(), "auth_time": time.Now().Unix()})
+
+	request := func(t *testing.T, method, path, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should keep creating service accounts to admins", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/admin/service-accounts", testToken, `{"username":"rss","email":"ops@example.com"}`)
+		checkResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	app.store.Users = &serviceUserStore{}
+	t.Run("should require a recent authentication to mint keys", func(t *testing.T) {
+		staleToken := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix()})
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/service-accounts", staleToken, `{"username":"rss","email":"ops@example.com"}`).Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodPost, "/v1/admin/service-accounts/100/keys", staleToken, `{"name":"mirror"}`).Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, http.MethodDelete, "/v1/admin/service-accounts/100/keys/1", staleToken, "").Code)
+	})
+
+	var secret string
+	t.Run("should create a service account with an api key", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/admin/service-accounts", testToken, `{"username":"rss","email":"ops@example.com","key_name":"mirror"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		created := decodeData[ServiceAccountCreated](t, rr.Body.String())
+		if !created.User.IsBot || !strings.HasPrefix(created.Secret, store.APIKeyPrefix) {
+			t.Fatalf("unexpected service account %+v", created)
+		}
+		secret = created.Secret
+	})
+
+	t.Run("should authenticate with the key under the bot rate limit", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/announcements/", secret, "").Code)
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/announcements/", secret, "").Code)
+		checkResponseCode(t, http.StatusTooManyRequests, request(t, http.MethodGet, "/v1/announcements/", secret, "").Code)
+		checkResponseCode(t, http.StatusUnauthorized, request(t, http.MethodGet, "/v1/announcements/", store.APIKeyPrefix+"bogus", "").Code)
+	})
+
+	t.Run("should reject revoked keys", func(t *testing.T) {
+		app.botRateLimiter = ratelimiter.NewFixedWindowLimiter(100, time.Minute)
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodDelete, "/v1/admin/service-accounts/100/keys/1", testToken, "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, http.MethodDelete, "/v1/admin/service-accounts/100/keys/1", testToken, "").Code)
+		checkResponseCode(t, http.StatusUnauthorized, request(t, http.MethodGet, "/v1/announcements/", secret, "").Code)
+	})
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"gopher_social/internal/store"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clientIDHeader = "X-Client-ID"
+const csrfHeader = "X-CSRF-Token"
+
+var errCSRFTokenMismatch = errors.New("csrf token missing or invalid")
+
+type SessionResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// usesCookieSession reports whether the calling client is configured to
+// receive its token in an HttpOnly cookie rather than the response body.
+func (app *application) usesCookieSession(r *http.Request) bool {
+	cfg := app.config.auth.cookie
+	if !cfg.enabled {
+		return false
+	}
+	return slices.Contains(cfg.clients, r.Header.Get(clientIDHeader))
+}
+
+// setSessionCookies stores the token in an HttpOnly cookie and issues a
+// matching CSRF token that the client must echo back in X-CSRF-Token
+// (double-submit). The CSRF cookie is readable by scripts on purpose.
+func (app *application) setSessionCookies(w http.ResponseWriter, token string) (string, error) {
+	cfg := app.config.auth.cookie
+	csrfToken, err := generateCSRFToken()
+	if err != nil {
+		return "", err
+	}
+	expires := time.Now().Add(app.config.auth.token.exp)
+	http.SetCookie(w, &http.Cookie{
+		Name:     cfg.name,
+		Value:    token,
+		Path:     "/",
+		Domain:   cfg.domain,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   cfg.secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     cfg.csrfName,
+		Value:    csrfToken,
+		Path:     "/",
+		Domain:   cfg.domain,
+		Expires:  expires,
+		Secure:   cfg.secure,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return csrfToken, nil
+}
+
+func (app *application) clearSessionCookies(w http.ResponseWriter) {
+	cfg := app.config.auth.cookie
+	for _, name := range []string{cfg.name, cfg.csrfName} {
+		http.SetCookie(w, &http.Cookie{
+			Name:   name,
+			Value:  "",
+			Path:   "/",
+			Domain: cfg.domain,
+			MaxAge: -1,
+		})
+	}
+}
+
+// sessionToken returns the token from the session cookie, enforcing the
+// double-submit check for state changing requests.
+func (app *application) sessionToken(r *http.Request) (string, error) {
+	cfg := app.config.auth.cookie
+	cookie, err := r.Cookie(cfg.name)
+	if err != nil {
+		return "", err
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return cookie.Value, nil
+	}
+	csrfCookie, err := r.Cookie(cfg.csrfName)
+	if err != nil {
+		return "", errCSRFTokenMismatch
+	}
+	header := r.Header.Get(csrfHeader)
+	if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(csrfCookie.Value)) != 1 {
+		return "", errCSRFTokenMismatch
+	}
+	return cookie.Value, nil
+}
+
+func generateCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// logoutHandler godoc
+//
+//	@Summary		Logout
+//	@Description	Revokes the session of the token sent, if any, and clears the session cookies of cookie based clients
+//	@Tags			authentication
+//	@Success		204	{string}	string	"Logged out"
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if token, err := app.requestToken(r); err == nil {
+		if jwtToken, err := app.authenticator.ValidateToken(token); err == nil {
+			claims := jwtToken.Claims.(jwt.MapClaims)
+			sub, _ := claims["sub"].(float64)
+			if id, ok := sessionID(claims); ok {
+				err := app.store.Sessions.Revoke(r.Context(), int64(sub), id)
+				if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+					app.internalServerError(w, r, err)
+					return
+				}
+			}
+		}
+	}
+	app.clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errSessionRevoked = errors.New("session revoked or expired")
+
+// sessionID is the session a token was issued for. Tokens from before
+// sessions were recorded, impersonation and third party apps carry none.
+func sessionID(claims jwt.MapClaims) (int64, bool) {
+	sid, ok := claims["sid"].(float64)
+	return int64(sid), ok
+}
+
+// startSession records the login the request is for and returns its ID. A
+// re-authentication keeps its session, which lives as long as the new token.
+func (app *application) startSession(r *http.Request, user *store.User, expiresAt time.Time) (int64, error) {
+	ctx := r.Context()
+	if id, ok := sessionID(getClaimsFromContext(r)); ok {
+		err := app.store.Sessions.Extend(ctx, id, expiresAt)
+		if !errors.Is(err, store.ErrRecordNotFound) {
+			return id, err
+		}
+	}
+	userAgent := truncate(r.UserAgent(), 500)
+	session := &store.Session{
+		UserID:    user.ID,
+		Device:    deviceName(r.Header.Get(deviceHeader), userAgent),
+		IP:        clientIP(r),
+		UserAgent: userAgent,
+		ExpiresAt: expiresAt,
+	}
+	if err := app.store.Sessions.Create(ctx, session); err != nil {
+		return 0, err
+	}
+	return session.ID, nil
+}
+
+// checkSession refuses tokens of revoked or expired sessions, and notes when
+// the session was last used at most once per touch interval.
+func (app *application) checkSession(ctx context.Context, r *http.Request, claims jwt.MapClaims, userID int64) error {
+	id, ok := sessionID(claims)
+	if !ok {
+		return nil
+	}
+	session, err := app.store.Sessions.Get(ctx, id)
+	if err != nil {
+		if errors.Is(err, store.ErrRecordNotFound) {
+			return errSessionRevoked
+		}
+		return err
+	}
+	now := time.Now()
+	if session.UserID != userID || !session.Active(now) {
+		return errSessionRevoked
+	}
+	if ip := clientIP(r); now.Sub(session.LastSeenAt) >= app.config.auth.sessionTouch || ip != session.IP {
+		if err := app.store.Sessions.Touch(ctx, id, ip); err != nil {
+			app.logger.Warnw("error touching session", "session_id", id, "error", err.Error())
+		}
+	}
+	return nil
+}
+
+// deviceHeader lets apps name the device they sign in from, browsers are
+// named after their user agent.
+const deviceHeader = "X-Device-Name"
+
+// deviceName is a short label for the sessions list such as "Firefox on
+// Linux".
+func deviceName(named, userAgent string) string {
+	if named = strings.TrimSpace(named); named != "" {
+		return truncate(named, 100)
+	}
+	find := func(candidates [][2]string) string {
+		for _, c := range candidates {
+			if strings.Contains(userAgent, c[0]) {
+				return c[1]
+			}
+		}
+		return ""
+	}
+	// Order matters, Edge claims to be Chrome which claims to be Safari and
+	// Android claims to be Linux.
+	browser := find([][2]string{{"Edg/", "Edge"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"}})
+	os := find([][2]string{{"iPhone", "iOS"}, {"iPad", "iPadOS"}, {"Android", "Android"}, {"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"}})
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+// clientIP is the address the request came from, RealIP has already taken
+// proxies into account.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
+
+// ListSessions godoc
+//
+//	@Summary		List sessions
+//	@Description	The authenticated user's active logins, most recently used first. current marks the one making the request
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	[]store.Session
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	sessions, err := app.store.Sessions.ListActive(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if current, ok := sessionID(getClaimsFromContext(r)); ok {
+		for i := range sessions {
+			sessions[i].Current = sessions[i].ID == current
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Revoke a session
+//	@Description	Signs one of the authenticated user's logins out, its tokens stop working right away. Revoking the current session signs the caller out
+//	@Tags			users
+//	@Param			sessionID	path	int	true	"Session ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "sessionID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user := getUserFromContext(r)
+	if err := app.store.Sessions.Revoke(r.Context(), user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.auditLog("session.revoke", user.ID, "session_id", id)
+	if current, ok := sessionID(getClaimsFromContext(r)); ok && current == id && app.usesCookieSession(r) {
+		app.clearSessionCookies(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (app *application) pruneSessions(ctx context.Context) error {
+	return app.store.Sessions.Prune(ctx)
+}
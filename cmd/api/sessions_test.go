@@ -0,0 +1,80 @@
+package main
+
+import (
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSessions(t *testing.T) {
+	app := NewTestApplication(t, config{auth: authConfig{token: tokenConfig{exp: time.Hour}}})
+	sessions := &store.MockSessionStore{}
+	app.store.Sessions = sessions
+	mux := app.mount()
+
+	login := func(t *testing.T, userAgent string) int64 {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/authentication/token", nil)
+		req.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		app.issueToken(rr, req, &store.User{ID: 42})
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return int64(len(sessions.Sessions))
+	}
+	tokenFor := func(t *testing.T, sid int64) string {
+		return signTestToken(t, jwt.MapClaims{"sub": 42, "sid": sid, "exp": time.Now().Add(time.Hour).Unix()})
+	}
+	request := func(t *testing.T, token, method, path string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, mux)
+	}
+
+	laptop := login(t, "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0")
+	phone := login(t, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Version/17.0 Mobile/15E148 Safari/604.1")
+
+	t.Run("should record each login as a session", func(t *testing.T) {
+		if got := sessions.Sessions[laptop-1].Device; got != "Firefox on Linux" {
+			t.Errorf("expected the laptop to be named after its browser, got %q", got)
+		}
+		if got := sessions.Sessions[phone-1].Device; got != "Safari on iOS" {
+			t.Errorf("expected the phone to be named after its browser, got %q", got)
+		}
+
+		rr := request(t, tokenFor(t, laptop), http.MethodGet, "/v1/users/me/sessions")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		listed := decodeData[[]store.Session](t, rr.Body.String())
+		if len(listed) != 2 {
+			t.Fatalf("expected 2 sessions, got %d", len(listed))
+		}
+		for _, s := range listed {
+			if s.Current != (s.ID == laptop) {
+				t.Errorf("session %d has current=%v", s.ID, s.Current)
+			}
+		}
+	})
+
+	t.Run("should refuse tokens of revoked sessions", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, tokenFor(t, laptop), http.MethodDelete, "/v1/users/me/sessions/2").Code)
+		checkResponseCode(t, http.StatusUnauthorized, request(t, tokenFor(t, phone), http.MethodGet, "/v1/users/me/sessions").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, tokenFor(t, laptop), http.MethodDelete, "/v1/users/me/sessions/2").Code)
+	})
+
+	t.Run("should not let users revoke the sessions of others", func(t *testing.T) {
+		other := signTestToken(t, jwt.MapClaims{"sub": 7, "exp": time.Now().Add(time.Hour).Unix()})
+		checkResponseCode(t, http.StatusNotFound, request(t, other, http.MethodDelete, "/v1/users/me/sessions/1").Code)
+	})
+
+	t.Run("should revoke the session on logout", func(t *testing.T) {
+		checkResponseCode(t, http.StatusNoContent, request(t, tokenFor(t, laptop), http.MethodPost, "/v1/authentication/logout").Code)
+		checkResponseCode(t, http.StatusUnauthorized, request(t, tokenFor(t, laptop), http.MethodGet, "/v1/users/me/sessions").Code)
+	})
+}
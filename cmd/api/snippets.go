@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gopher_social/internal/playground"
+	"gopher_social/internal/store"
+)
+
+// playgroundConfig points at the Go Playground runnable snippets are shared
+// on. An empty url disables playground links.
+type playgroundConfig struct {
+	url     string
+	timeout time.Duration
+}
+
+type SnippetPayload struct {
+	Language string `json:"language" validate:"required,max=30"`
+	Content  string `json:"content" validate:"required,max=20000"`
+}
+
+// newSnippets turns the payload into snippets, sharing whole Go programs on
+// the playground. A failed share only costs the link, the post is still
+// created.
+func (app *application) newSnippets(ctx context.Context, payload []SnippetPayload) []store.Snippet {
+	if len(payload) == 0 {
+		return nil
+	}
+	snippets := make([]store.Snippet, len(payload))
+	for i, p := range payload {
+		snippets[i] = store.Snippet{
+			Language: strings.ToLower(strings.TrimSpace(p.Language)),
+			Content:  p.Content,
+		}
+		if app.playground == nil || !playground.Runnable(snippets[i].Language, p.Content) {
+			continue
+		}
+		url, err := app.playground.Share(ctx, p.Content)
+		if err != nil {
+			app.logger.Warnw("sharing snippet on the playground", "error", err)
+			continue
+		}
+		snippets[i].PlaygroundURL = url
+	}
+	return snippets
+}
+
+// attachSnippets loads the snippets of the posts with one batched query.
+func (app *application) attachSnippets(ctx context.Context, posts []*store.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	snippets, err := app.store.Snippets.GetByPostIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.Snippets = snippets[post.ID]
+	}
+	return nil
+}
+
+// attachPostExtras loads the snippets and repo cards of the posts.
+func (app *application) attachPostExtras(ctx context.Context, posts []*store.Post) error {
+	if err := app.attachSnippets(ctx, posts); err != nil {
+		return err
+	}
+	return app.attachRepoCards(ctx, posts)
+}
+
+// attachFeedExtras is attachPostExtras for feed items.
+func (app *application) attachFeedExtras(ctx context.Context, feed []store.PostWithMetadata) error {
+	posts := make([]*store.Post, len(feed))
+	for i := range feed {
+		posts[i] = &feed[i].Post
+	}
+	return app.attachPostExtras(ctx, posts)
+}
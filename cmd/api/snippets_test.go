@@ -0,0 +1,87 @@
+package main
+
+import (
+	"gopher_social/internal/playground"
+	"gopher_social/internal/store"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSnippets(t *testing.T) {
+	var shared []string
+	play := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/_/share" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		shared = append(shared, string(body))
+		io.WriteString(w, "abc123")
+	}))
+	defer play.Close()
+
+	app := NewTestApplication(t, config{})
+	app.playground = playground.New(play.URL, time.Second)
+	snippets := &store.MockSnippetStore{}
+	app.store.Snippets = snippets
+
+	token := signTestToken(t, jwt.MapClaims{"sub": int64(42), "exp": time.Now().Add(time.Minute).Unix()})
+	request := func(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		return executeRequest(req, app.mount())
+	}
+
+	t.Run("should highlight snippets and share runnable Go", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/posts", `{"title":"Hello","content":"Two snippets","snippets":[
+			{"language":"Go","content":"package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n"},
+			{"language":"python","content":"print('<script>alert(1)</script>')"}
+		]}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		post := decodeData[store.Post](t, rr.Body.String())
+		if len(post.Snippets) != 2 {
+			t.Fatalf("expected 2 snippets, got %+v", post.Snippets)
+		}
+		gosnip, pysnip := post.Snippets[0], post.Snippets[1]
+		if gosnip.Language != "go" || !strings.Contains(gosnip.HTML, `class="chroma"`) {
+			t.Errorf("expected highlighted go, got %+v", gosnip)
+		}
+		if gosnip.PlaygroundURL != play.URL+"/play/p/abc123" || len(shared) != 1 {
+			t.Errorf("expected a playground link, got %q after %d shares", gosnip.PlaygroundURL, len(shared))
+		}
+		if pysnip.PlaygroundURL != "" {
+			t.Errorf("expected no playground link for python, got %q", pysnip.PlaygroundURL)
+		}
+		if strings.Contains(pysnip.HTML, "<script>") {
+			t.Errorf("expected code to be escaped, got %s", pysnip.HTML)
+		}
+	})
+
+	t.Run("should reject invalid snippets", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts", `{"title":"Hello","content":"x","snippets":[{"language":"go","content":""}]}`).Code)
+		many := strings.Repeat(`{"language":"go","content":"x"},`, store.MaxPostSnippets) + `{"language":"go","content":"x"}`
+		checkResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/posts", `{"title":"Hello","content":"x","snippets":[`+many+`]}`).Code)
+	})
+
+	t.Run("should load snippets with the post", func(t *testing.T) {
+		snippets.Snippets = map[int64][]store.Snippet{1: {{ID: 1, PostID: 1, Language: "go", Content: "x := 1"}}}
+		rr := request(t, http.MethodGet, "/v1/posts/1", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		detail := decodeData[struct {
+			Snippets []store.Snippet `json:"snippets"`
+		}](t, rr.Body.String())
+		if len(detail.Snippets) != 1 || !strings.Contains(detail.Snippets[0].HTML, "chroma") {
+			t.Errorf("expected the highlighted snippet, got %+v", detail.Snippets)
+		}
+	})
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/events"
+	"gopher_social/internal/lang"
+	"gopher_social/internal/rtc"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// spacesConfig configures live spaces. Media rooms are opened at the rtc
+// provider, with none configured spaces only carry metadata.
+type spacesConfig struct {
+	rtc        rtc.Config
+	maxCohosts int
+}
+
+type CreateSpacePayload struct {
+	Title       string   `json:"title" validate:"required,max=100"`
+	Description string   `json:"description" validate:"max=1000"`
+	Tags        []string `json:"tags"`
+	Media       string   `json:"media" validate:"required,oneof=audio video"`
+	// ScheduledFor announces when the space goes live, empty for now.
+	ScheduledFor *time.Time `json:"scheduled_for"`
+}
+
+func parseSpaceID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "spaceID"), 10, 64)
+}
+
+// spaceError answers a failed space lookup or change.
+func (app *application) spaceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, store.ErrRecordNotFound):
+		app.notFoundResponse(w, r, err)
+	case errors.Is(err, store.ErrConflict):
+		app.conflictResponse(w, r, err)
+	default:
+		app.internalServerError(w, r, err)
+	}
+}
+
+// loadSpace fetches the space of the request, answering the error itself
+// when it can't.
+func (app *application) loadSpace(w http.ResponseWriter, r *http.Request) (*store.Space, bool) {
+	id, err := parseSpaceID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, false
+	}
+	space, err := app.store.Spaces.Get(r.Context(), id)
+	if err != nil {
+		app.spaceError(w, r, err)
+		return nil, false
+	}
+	return space, true
+}
+
+// CreateSpace godoc
+//
+//	@Summary		Schedule a space
+//	@Description	Schedules a live audio or video room. It is announced by a post of kind "space" with the space's ID, which reaches followers' feeds like any post
+//	@Tags			spaces
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateSpacePayload	true	"Space"
+//	@Success		201		{object}	store.Space
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error	"Risky account posting too often"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces [post]
+func (app *application) createSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateSpacePayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.ScheduledFor != nil && !payload.ScheduledFor.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("scheduled_for must be in the future"))
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	retryAfter, err := app.postThrottle(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	post := &store.Post{
+		Title:   payload.Title,
+		Content: payload.Description,
+		Tags:    payload.Tags,
+		UserID:  user.ID,
+		Lang:    lang.Detect(payload.Title + "\n" + payload.Description),
+		User:    *user,
+	}
+	space := &store.Space{Media: payload.Media, ScheduledFor: payload.ScheduledFor}
+	if err := app.store.Spaces.Create(ctx, post, space); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.events.Publish(ctx, events.PostCreated{Post: post})
+	if err := app.jsonResponse(w, http.StatusCreated, space); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListSpaces godoc
+//
+//	@Summary		List spaces
+//	@Description	Live spaces, most recently started first, or scheduled ones, soonest first
+//	@Tags			spaces
+//	@Produce		json
+//	@Param			status		query		string	false	"live (default) or scheduled"
+//	@Param			following	query		bool	false	"Only spaces hosted by people the user follows"
+//	@Param			limit		query		int		false	"Limit (default 20, max 100)"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	[]store.Space
+//	@Failure		400			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces [get]
+func (app *application) listSpacesHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	qs := r.URL.Query()
+	status := qs.Get("status")
+	switch status {
+	case "":
+		status = store.SpaceLive
+	case store.SpaceLive, store.SpaceScheduled:
+	default:
+		app.badRequestResponse(w, r, fmt.Errorf("unknown status %q", status))
+		return
+	}
+	var followerID int64
+	if qs.Get("following") == "true" {
+		followerID = getUserFromContext(r).ID
+	}
+	spaces, err := app.store.Spaces.List(r.Context(), status, followerID, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, spaces); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetSpace godoc
+//
+//	@Summary		Fetch a space
+//	@Description	The space with its hosts and the participants present
+//	@Tags			spaces
+//	@Produce		json
+//	@Param			spaceID	path		int	true	"Space ID, the ID of its post"
+//	@Success		200		{object}	store.Space
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID} [get]
+func (app *application) getSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	space, ok := app.loadSpace(w, r)
+	if !ok {
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, space); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// StartSpace godoc
+//
+//	@Summary		Go live
+//	@Description	Lets a host or cohost start a scheduled space. The media room is opened at the RTC provider and followers with notifications on for the host are told
+//	@Tags			spaces
+//	@Produce		json
+//	@Param			spaceID	path		int	true	"Space ID"
+//	@Success		200		{object}	store.Space
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Not scheduled"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/start [post]
+func (app *application) startSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	space, ok := app.loadSpace(w, r)
+	if !ok {
+		return
+	}
+	if role := space.Role(getUserFromContext(r).ID); role != store.SpaceRoleHost && role != store.SpaceRoleCohost {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	if space.Status != store.SpaceScheduled {
+		app.conflictResponse(w, r, fmt.Errorf("space is %s", space.Status))
+		return
+	}
+
+	ctx := r.Context()
+	var room rtc.Room
+	if app.rtc != nil {
+		var err error
+		if room, err = app.rtc.CreateRoom(ctx, fmt.Sprintf("space-%d", space.ID)); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.store.Spaces.Start(ctx, space.ID, room.ID, room.URL); err != nil {
+		app.closeSpaceRoom(ctx, space.ID, room.ID)
+		app.spaceError(w, r, err)
+		return
+	}
+	now := time.Now()
+	space.Status, space.StartedAt, space.RoomID, space.RoomURL = store.SpaceLive, &now, room.ID, room.URL
+	app.events.Publish(ctx, events.SpaceStarted{Space: space})
+	if err := app.jsonResponse(w, http.StatusOK, space); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// StopSpace godoc
+//
+//	@Summary		End a space
+//	@Description	Lets a host or cohost end a live space, closing its media room. Ended spaces can't be restarted
+//	@Tags			spaces
+//	@Param			spaceID	path	int	true	"Space ID"
+//	@Success		204
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"Not live"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/stop [post]
+func (app *application) stopSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	space, ok := app.loadSpace(w, r)
+	if !ok {
+		return
+	}
+	if role := space.Role(getUserFromContext(r).ID); role != store.SpaceRoleHost && role != store.SpaceRoleCohost {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Spaces.Stop(ctx, space.ID); err != nil {
+		app.spaceError(w, r, err)
+		return
+	}
+	app.closeSpaceRoom(ctx, space.ID, space.RoomID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// closeSpaceRoom frees the media room of a space. The space is over either
+// way, a room the provider failed to close expires on its side.
+func (app *application) closeSpaceRoom(ctx context.Context, spaceID int64, roomID string) {
+	if app.rtc == nil || roomID == "" {
+		return
+	}
+	if err := app.rtc.CloseRoom(ctx, roomID); err != nil {
+		app.logger.Warnw("error closing space room", "space_id", spaceID, "room_id", roomID, "error", err.Error())
+	}
+}
+
+// JoinSpace godoc
+//
+//	@Summary		Join a space
+//	@Description	Marks the user present in a live space, as a listener unless they host it. The answer carries the room URL to connect the media to
+//	@Tags			spaces
+//	@Produce		json
+//	@Param			spaceID	path		int	true	"Space ID"
+//	@Success		200		{object}	store.Space
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error	"Not live"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/join [post]
+func (app *application) joinSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	space, ok := app.loadSpace(w, r)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Spaces.Join(ctx, space.ID, getUserFromContext(r).ID); err != nil {
+		app.spaceError(w, r, err)
+		return
+	}
+	space, err := app.store.Spaces.Get(ctx, space.ID)
+	if err != nil {
+		app.spaceError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, space); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// LeaveSpace godoc
+//
+//	@Summary		Leave a space
+//	@Description	Marks the user absent, hosts keep their role
+//	@Tags			spaces
+//	@Param			spaceID	path	int	true	"Space ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/leave [post]
+func (app *application) leaveSpaceHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseSpaceID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.Spaces.Leave(r.Context(), id, getUserFromContext(r).ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetSpaceCohost godoc
+//
+//	@Summary		Add a cohost
+//	@Description	Lets the host make a user cohost of a space that hasn't ended. Cohosts can start and end the space
+//	@Tags			spaces
+//	@Param			spaceID	path	int	true	"Space ID"
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		409	{object}	error	"Space ended or too many cohosts"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/cohosts/{userID} [put]
+func (app *application) setSpaceCohostHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeSpaceCohost(w, r, true)
+}
+
+// RemoveSpaceCohost godoc
+//
+//	@Summary		Remove a cohost
+//	@Description	Lets the host take the cohost role back, a cohost present stays as a listener
+//	@Tags			spaces
+//	@Param			spaceID	path	int	true	"Space ID"
+//	@Param			userID	path	int	true	"User ID"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		404	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/spaces/{spaceID}/cohosts/{userID} [delete]
+func (app *application) removeSpaceCohostHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeSpaceCohost(w, r, false)
+}
+
+func (app *application) changeSpaceCohost(w http.ResponseWriter, r *http.Request, cohost bool) {
+	space, ok := app.loadSpace(w, r)
+	if !ok {
+		return
+	}
+	if space.HostID != getUserFromContext(r).ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if userID == space.HostID {
+		app.badRequestResponse(w, r, errors.New("the host can't be a cohost"))
+		return
+	}
+	if cohost && space.Role(userID) != store.SpaceRoleCohost {
+		cohosts := 0
+		for _, p := range space.Participants {
+			if p.Role == store.SpaceRoleCohost {
+				cohosts++
+			}
+		}
+		if cohosts >= app.config.spaces.maxCohosts {
+			app.conflictResponse(w, r, fmt.Errorf("a space has at most %d cohosts", app.config.spaces.maxCohosts))
+			return
+		}
+	}
+	if err := app.store.Spaces.SetCohost(r.Context(), space.ID, userID, cohost); err != nil {
+		app.spaceError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// notifySpaceLive tells the followers who rang the bell on the host that
+// their space went live.
+func (app *application) notifySpaceLive(ctx context.Context, e events.SpaceStarted) error {
+	space := e.Space
+	subscribers, err := app.store.Followers.GetSubscriberIDs(ctx, space.HostID)
+	if err != nil {
+		return fmt.Errorf("loading subscribers of user %d: %w", space.HostID, err)
+	}
+	notifications := make([]store.Notification, 0, len(subscribers))
+	for _, id := range subscribers {
+		if id == space.HostID {
+			continue
+		}
+		notifications = append(notifications, store.Notification{
+			UserID:  id,
+			ActorID: space.HostID,
+			Type:    store.NotificationSpaceLive,
+			PostID:  space.ID,
+		})
+	}
+	if err := app.createNotifications(ctx, notifications); err != nil {
+		return fmt.Errorf("creating notifications for space %d: %w", space.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/rtc"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// bellFollowerStore has every user subscribed to the posts of everyone.
+type bellFollowerStore struct {
+	store.MockFollowerStore
+	subscribers []int64
+}
+
+func (m *bellFollowerStore) GetSubscriberIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return m.subscribers, nil
+}
+
+func TestSpaces(t *testing.T) {
+	var closed []string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rooms":
+			json.NewEncoder(w).Encode(rtc.Room{ID: "room-1", URL: "wss://rtc.example/room-1"})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/rooms/"):
+			closed = append(closed, strings.TrimPrefix(r.URL.Path, "/rooms/"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer provider.Close()
+
+	app := NewTestApplication(t, config{spaces: spacesConfig{maxCohosts: 1}})
+	app.rtc = rtc.NewHTTPProvider(provider.URL, "key", time.Second)
+	spaces := &store.MockSpaceStore{Followed: map[int64][]int64{43: {42}}}
+	app.store.Spaces = spaces
+	app.store.Followers = &bellFollowerStore{subscribers: []int64{43}}
+	notifications := &store.MockNotificationStore{}
+	app.store.Notifications = notifications
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp}))
+		return executeRequest(req, app.mount())
+	}
+	space := func(t *testing.T) store.Space {
+		t.Helper()
+		rr := request(t, 43, http.MethodGet, "/v1/spaces/1", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return decodeData[store.Space](t, rr.Body.String())
+	}
+
+	t.Run("should schedule a space", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/spaces/", `{"title":"Go chat","media":"hologram"}`).Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/spaces/", `{"title":"Go chat","media":"audio","scheduled_for":"2001-01-01T00:00:00Z"}`).Code)
+		rr := request(t, 42, http.MethodPost, "/v1/spaces/", `{"title":"Go chat","media":"audio"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		if got := decodeData[store.Space](t, rr.Body.String()); got.Status != store.SpaceScheduled || got.HostID != 42 {
+			t.Errorf("expected a scheduled space hosted by 42, got %+v", got)
+		}
+		rr = request(t, 43, http.MethodGet, "/v1/spaces/?status=scheduled", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.Space](t, rr.Body.String()); len(got) != 1 {
+			t.Errorf("expected the scheduled space, got %+v", got)
+		}
+	})
+
+	t.Run("should let only the host manage cohosts", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodPut, "/v1/spaces/1/cohosts/44", "").Code)
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPut, "/v1/spaces/1/cohosts/42", "").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, http.MethodPut, "/v1/spaces/1/cohosts/44", "").Code)
+		checkResponseCode(t, http.StatusConflict, request(t, 42, http.MethodPut, "/v1/spaces/1/cohosts/45", "").Code)
+		if got := space(t).Participants; len(got) != 2 || got[1].UserID != 44 || got[1].Role != store.SpaceRoleCohost {
+			t.Errorf("expected 44 to cohost, got %+v", got)
+		}
+	})
+
+	t.Run("should go live and announce it", func(t *testing.T) {
+		checkResponseCode(t, http.StatusConflict, request(t, 43, http.MethodPost, "/v1/spaces/1/join", "").Code)
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodPost, "/v1/spaces/1/start", "").Code)
+
+		rr := request(t, 44, http.MethodPost, "/v1/spaces/1/start", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[store.Space](t, rr.Body.String()); got.Status != store.SpaceLive || got.RoomURL != "wss://rtc.example/room-1" {
+			t.Errorf("expected a live space in room-1, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusConflict, request(t, 42, http.MethodPost, "/v1/spaces/1/start", "").Code)
+
+		var live []store.Notification
+		for _, n := range notifications.Notifications {
+			if n.Type == store.NotificationSpaceLive {
+				live = append(live, n)
+			}
+		}
+		if len(live) != 1 || live[0].UserID != 43 || live[0].PostID != 1 {
+			t.Errorf("expected the follower to be told, got %+v", live)
+		}
+		rr = request(t, 43, http.MethodGet, "/v1/spaces/?following=true", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.Space](t, rr.Body.String()); len(got) != 1 || got[0].ID != 1 {
+			t.Errorf("expected the followed host's live space, got %+v", got)
+		}
+	})
+
+	t.Run("should track participants", func(t *testing.T) {
+		rr := request(t, 43, http.MethodPost, "/v1/spaces/1/join", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[store.Space](t, rr.Body.String()); got.Listeners != 1 || got.Role(43) != store.SpaceRoleListener {
+			t.Errorf("expected 43 to listen, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusNoContent, request(t, 43, http.MethodPost, "/v1/spaces/1/leave", "").Code)
+		if got := space(t); got.Listeners != 0 || got.Role(43) != "" {
+			t.Errorf("expected 43 to be gone, got %+v", got)
+		}
+	})
+
+	t.Run("should end the space and close its room", func(t *testing.T) {
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodPost, "/v1/spaces/1/stop", "").Code)
+		checkResponseCode(t, http.StatusNoContent, request(t, 42, http.MethodPost, "/v1/spaces/1/stop", "").Code)
+		if len(closed) != 1 || closed[0] != "room-1" {
+			t.Errorf("expected room-1 to be closed, got %v", closed)
+		}
+		if got := space(t); got.Status != store.SpaceEnded || got.RoomURL != "" {
+			t.Errorf("expected an ended space, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusConflict, request(t, 42, http.MethodPost, "/v1/spaces/1/stop", "").Code)
+		checkResponseCode(t, http.StatusConflict, request(t, 42, http.MethodPost, "/v1/spaces/1/start", "").Code)
+	})
+}
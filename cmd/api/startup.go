@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+// schemaVersion is the number of the latest migration in cmd/migrate. Bump
+// it together with every new migration.
+const schemaVersion = 82
+
+// defaultTokenSecret is the development fallback for AUTH_TOKEN_SECRET.
+const defaultTokenSecret = "secret"
+
+type dependencyCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// runStartupChecks runs every check and logs one line per dependency. It
+// returns the names of the failed checks.
+func runStartupChecks(ctx context.Context, logger *zap.SugaredLogger, checks []dependencyCheck) []string {
+	var failed []string
+	for _, c := range checks {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		err := c.check(ctx)
+		cancel()
+		if err != nil {
+			logger.Warnw("❌ startup check failed", "dependency", c.name, "error", err.Error())
+			failed = append(failed, c.name)
+			continue
+		}
+		logger.Infow("✅ startup check passed", "dependency", c.name)
+	}
+	return failed
+}
+
+func checkSchema(s store.Storage) func(context.Context) error {
+	return func(ctx context.Context) error {
+		version, dirty, err := s.Schema.Version(ctx)
+		if err != nil {
+			return fmt.Errorf("reading schema version: %w", err)
+		}
+		return schemaCompatibility(version, dirty)
+	}
+}
+
+// schemaCompatibility tells whether this build can serve against the given
+// schema. Migrations are expand-only until every instance runs the new
+// build, so a schema ahead of the binary is fine during a rolling deploy,
+// one that is behind or dirty is not.
+func schemaCompatibility(version int, dirty bool) error {
+	if dirty {
+		return fmt.Errorf("migration %d is dirty, fix it and re-run migrate", version)
+	}
+	if version < schemaVersion {
+		return fmt.Errorf("schema is at version %d, this build expects %d", version, schemaVersion)
+	}
+	return nil
+}
+
+func checkRedis(rdb *redis.Client) func(context.Context) error {
+	return func(ctx context.Context) error {
+		if rdb == nil {
+			return nil
+		}
+		return rdb.Ping(ctx).Err()
+	}
+}
+
+func checkMailer(mailerErr error) func(context.Context) error {
+	return func(context.Context) error {
+		return mailerErr
+	}
+}
+
+func checkTokenSecret(cfg tokenConfig, env string) func(context.Context) error {
+	return func(context.Context) error {
+		if len(cfg.keys) > 0 {
+			return nil
+		}
+		if cfg.secret == "" {
+			return errors.New("AUTH_TOKEN_SECRET is not set")
+		}
+		if cfg.secret == defaultTokenSecret && env == "production" {
+			return errors.New("AUTH_TOKEN_SECRET is the development default")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	entries, err := os.ReadDir("../migrate/migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := 0
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(prefix); err == nil && n > latest {
+			latest = n
+		}
+	}
+	if latest != schemaVersion {
+		t.Errorf("schemaVersion is %d but the latest migration is %d", schemaVersion, latest)
+	}
+}
+
+func TestCheckTokenSecret(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     tokenConfig
+		env     string
+		wantErr bool
+	}{
+		{"missing secret", tokenConfig{}, "development", true},
+		{"default secret in development", tokenConfig{secret: defaultTokenSecret}, "development", false},
+		{"default secret in production", tokenConfig{secret: defaultTokenSecret}, "production", true},
+		{"signing keys", tokenConfig{keys: []string{"k1:key.pem"}}, "production", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkTokenSecret(tc.cfg, tc.env)(context.Background())
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	cases := []struct {
+		name   string
+		schema *store.MockSchemaStore
+		want   int
+	}{
+		{"should be ready on the expected schema", &store.MockSchemaStore{CurrentVersion: schemaVersion}, http.StatusOK},
+		{"should be ready on a newer schema", &store.MockSchemaStore{CurrentVersion: schemaVersion + 1}, http.StatusOK},
+		{"should be degraded on an older schema", &store.MockSchemaStore{CurrentVersion: schemaVersion - 1}, http.StatusServiceUnavailable},
+		{"should be degraded on a dirty schema", &store.MockSchemaStore{CurrentVersion: schemaVersion, Dirty: true}, http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			app := NewTestApplication(t, config{})
+			app.store.Schema = tc.schema
+			mux := app.mount()
+
+			req, err := http.NewRequest(http.MethodGet, "/v1/health/ready", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, tc.want, rr.Code)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"gopher_social/internal/db"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// nopConnector is a database that accepts every exec.
+type nopConnector struct{}
+
+func (nopConnector) Connect(context.Context) (driver.Conn, error) { return nopConn{}, nil }
+func (nopConnector) Driver() driver.Driver                        { return nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (nopConn) Close() error                        { return nil }
+func (nopConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+func (nopConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func TestStatementBudget(t *testing.T) {
+	pool := sql.OpenDB(db.CountStatements(nopConnector{}))
+	defer pool.Close()
+
+	// handler runs one statement per item, the classic N+1.
+	handler := func(items int) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for range items {
+				if _, err := pool.ExecContext(r.Context(), "SELECT 1"); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+		})
+	}
+	serve := func(t *testing.T, cfg dbConfig, items int) int {
+		t.Helper()
+		app := NewTestApplication(t, config{db: cfg})
+		rr := httptest.NewRecorder()
+		app.StatementBudgetMiddleware(handler(items)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rr.Code
+	}
+
+	t.Run("should count the statements of a request", func(t *testing.T) {
+		ctx, counter := db.WithStatementCounter(context.Background(), 3, false)
+		for range 5 {
+			if _, err := pool.ExecContext(ctx, "SELECT 1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if counter.Count() != 5 || !counter.Exceeded() {
+			t.Fatalf("got %d statements, exceeded %v", counter.Count(), counter.Exceeded())
+		}
+	})
+
+	t.Run("should only log a request over budget", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, serve(t, dbConfig{statementBudget: 3}, 5))
+	})
+
+	t.Run("should fail a request over an enforced budget", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, serve(t, dbConfig{statementBudget: 3, enforceBudget: true}, 3))
+		checkResponseCode(t, http.StatusInternalServerError, serve(t, dbConfig{statementBudget: 3, enforceBudget: true}, 4))
+	})
+}
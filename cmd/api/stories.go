@@ -0,0 +1,229 @@
+package main
+
+import (
+	"errors"
+	"gopher_social/internal/lang"
+	"gopher_social/internal/store"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// storiesConfig sets how long stories live. Expired stories are deleted by
+// the post-retention job.
+type storiesConfig struct {
+	ttl time.Duration
+}
+
+type CreateStoryPayload struct {
+	Content string `json:"content" validate:"required,max=100000"`
+}
+
+func parseStoryID(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "storyID"), 10, 64)
+}
+
+func (app *application) renderStory(story *store.Story) error {
+	html, err := app.markup.Render(story.Content)
+	if err != nil {
+		return err
+	}
+	story.ContentHTML = html
+	return nil
+}
+
+// CreateStory godoc
+//
+//	@Summary		Post a story
+//	@Description	Publishes a story, shown to followers in the stories feed until it expires a day later. Stories stay out of the home and explore feeds
+//	@Tags			stories
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		CreateStoryPayload	true	"Story"
+//	@Success		201		{object}	store.Story
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error	"Risky account posting too often"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stories [post]
+func (app *application) createStoryHandler(w http.ResponseWriter, r *http.Request) {
+	var payload CreateStoryPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.checkPostLimits(nil, &payload.Content, nil); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	retryAfter, err := app.postThrottle(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if retryAfter > 0 {
+		app.rateLimitExceedResponse(w, r, retryAfter.String())
+		return
+	}
+
+	post := &store.Post{
+		Content: payload.Content,
+		UserID:  user.ID,
+		Lang:    lang.Detect(payload.Content),
+		User:    *user,
+	}
+	// No PostCreated: stories don't ring followers' bells, they find them in
+	// the stories feed.
+	story, err := app.store.Stories.Create(ctx, post, app.config.stories.ttl)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.renderStory(story); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, story); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// GetStoriesFeed godoc
+//
+//	@Summary		Stories feed
+//	@Description	Active stories of the user and the people they follow: the user's own first, then unseen ones, newest first
+//	@Tags			stories
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 100, max 200)"
+//	@Success		200		{object}	[]store.Story
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stories/feed [get]
+func (app *application) getStoriesFeedHandler(w http.ResponseWriter, r *http.Request) {
+	limit, _, err := parseLimitOffset(r, 100, 200)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	stories, err := app.store.Stories.Feed(r.Context(), getUserFromContext(r).ID, limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	for i := range stories {
+		if err := app.renderStory(&stories[i]); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+	}
+	if err := app.jsonResponse(w, http.StatusOK, stories); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ViewStory godoc
+//
+//	@Summary		View a story
+//	@Description	Fetches an active story and records that the user viewed it
+//	@Tags			stories
+//	@Produce		json
+//	@Param			storyID	path		int	true	"Story ID"
+//	@Success		200		{object}	store.Story
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"No such active story"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stories/{storyID} [get]
+func (app *application) viewStoryHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseStoryID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	viewerID := getUserFromContext(r).ID
+	if err := app.store.Stories.View(ctx, id, viewerID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	story, err := app.store.Stories.Get(ctx, id, viewerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.renderStory(story); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, story); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListStoryViewers godoc
+//
+//	@Summary		List story viewers
+//	@Description	Lets the author see who viewed their story, most recent first
+//	@Tags			stories
+//	@Produce		json
+//	@Param			storyID	path		int	true	"Story ID"
+//	@Param			limit	query		int	false	"Limit (default 50, max 200)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.StoryViewer
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error	"No such active story"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/stories/{storyID}/viewers [get]
+func (app *application) listStoryViewersHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseStoryID(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	limit, offset, err := parseLimitOffset(r, 50, 200)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	user := getUserFromContext(r)
+	story, err := app.store.Stories.Get(ctx, id, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if story.UserID != user.ID {
+		app.forbiddenResponse(w, r)
+		return
+	}
+	viewers, err := app.store.Stories.Viewers(ctx, id, limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, viewers); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// kindPostStore serves every post as one of kind, authored by user 7.
+type kindPostStore struct {
+	store.MockPostStore
+	kind string
+}
+
+func (m *kindPostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	return &store.Post{ID: id, UserID: 7, Kind: m.kind}, nil
+}
+
+func TestStories(t *testing.T) {
+	app := NewTestApplication(t, config{stories: storiesConfig{ttl: 24 * time.Hour}})
+	stories := &store.MockStoryStore{Following: map[int64][]int64{43: {42}}}
+	app.store.Stories = stories
+
+	exp := time.Now().Add(time.Minute).Unix()
+	request := func(t *testing.T, sub int64, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.MapClaims{"sub": sub, "exp": exp}))
+		return executeRequest(req, app.mount())
+	}
+	feed := func(t *testing.T, sub int64) []store.Story {
+		t.Helper()
+		rr := request(t, sub, http.MethodGet, "/v1/stories/feed", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		return decodeData[[]store.Story](t, rr.Body.String())
+	}
+
+	t.Run("should post a story expiring in a day", func(t *testing.T) {
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/stories/", `{"content":""}`).Code)
+		limits := store.DefaultContentLimits
+		limits.MaxPostLength = 10
+		app.instance.settings.Store(&store.InstanceSettings{Limits: limits})
+		checkResponseCode(t, http.StatusBadRequest, request(t, 42, http.MethodPost, "/v1/stories/", `{"content":"Hello **gophers**"}`).Code)
+		app.instance.settings.Store(nil)
+
+		rr := request(t, 42, http.MethodPost, "/v1/stories/", `{"content":"Hello **gophers**"}`)
+		checkResponseCode(t, http.StatusCreated, rr.Code)
+		got := decodeData[store.Story](t, rr.Body.String())
+		if got.ExpiresAt.Before(time.Now().Add(23*time.Hour)) || !strings.Contains(got.ContentHTML, "<strong>") {
+			t.Errorf("expected a rendered story expiring in a day, got %+v", got)
+		}
+	})
+
+	t.Run("should show stories of followed users", func(t *testing.T) {
+		if got := feed(t, 43); len(got) != 1 || got[0].Seen || got[0].Views != nil {
+			t.Errorf("expected an unseen story without view count, got %+v", got)
+		}
+		if got := feed(t, 44); len(got) != 0 {
+			t.Errorf("expected no stories for a non follower, got %+v", got)
+		}
+	})
+
+	t.Run("should track views for the author", func(t *testing.T) {
+		rr := request(t, 43, http.MethodGet, "/v1/stories/1", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[store.Story](t, rr.Body.String()); !got.Seen {
+			t.Errorf("expected the story to be seen, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusOK, request(t, 42, http.MethodGet, "/v1/stories/1", "").Code)
+
+		if got := feed(t, 42); len(got) != 1 || got[0].Views == nil || *got[0].Views != 1 {
+			t.Errorf("expected the author to see 1 view, got %+v", got)
+		}
+		checkResponseCode(t, http.StatusForbidden, request(t, 43, http.MethodGet, "/v1/stories/1/viewers", "").Code)
+		rr = request(t, 42, http.MethodGet, "/v1/stories/1/viewers", "")
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		if got := decodeData[[]store.StoryViewer](t, rr.Body.String()); len(got) != 1 || got[0].UserID != 43 {
+			t.Errorf("expected 43 to have viewed, got %+v", got)
+		}
+	})
+
+	t.Run("should hide expired stories", func(t *testing.T) {
+		stories.Stories[0].ExpiresAt = time.Now().Add(-time.Minute)
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodGet, "/v1/stories/1", "").Code)
+		if got := feed(t, 43); len(got) != 0 {
+			t.Errorf("expected no stories, got %+v", got)
+		}
+	})
+
+	t.Run("should keep stories out of the post routes", func(t *testing.T) {
+		app.store.Posts = &kindPostStore{kind: store.PostKindStory}
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodGet, "/v1/posts/1", "").Code)
+		checkResponseCode(t, http.StatusNotFound, request(t, 43, http.MethodPost, "/v1/posts/1/comments", `{"content":"hi"}`).Code)
+	})
+}
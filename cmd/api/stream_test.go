@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingFollowerStore hands out after rows, then fails.
+type failingFollowerStore struct {
+	store.MockFollowerStore
+	after int
+}
+
+func (m *failingFollowerStore) EachFollowing(ctx context.Context, followerID int64, fn func(store.FollowedUser) error) error {
+	for i := range m.after {
+		if err := fn(store.FollowedUser{Username: fmt.Sprintf("user%d", i)}); err != nil {
+			return err
+		}
+	}
+	return errors.New("connection reset")
+}
+
+func TestStreamedExports(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	export := func(t *testing.T) (int, string, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/following/export", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, app.mount())
+		return rr.Code, rr.Header().Get("Content-Type"), rr.Body.String()
+	}
+
+	t.Run("should stream every row under the header", func(t *testing.T) {
+		followers := &store.MockFollowerStore{}
+		for i := range 250 {
+			followers.Following = append(followers.Following, store.FollowedUser{Username: fmt.Sprintf("user%d", i), FollowedAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)})
+		}
+		app.store.Followers = followers
+		code, contentType, body := export(t)
+		checkResponseCode(t, http.StatusOK, code)
+		lines := strings.Split(strings.TrimSpace(body), "\n")
+		if contentType != "text/csv" || len(lines) != 251 || lines[0] != "username,followed_at" || lines[250] != "user249,2026-01-01T00:00:00Z" {
+			t.Fatalf("unexpected export %q with %d lines", contentType, len(lines))
+		}
+	})
+
+	t.Run("should send just the header when nothing is followed", func(t *testing.T) {
+		app.store.Followers = &store.MockFollowerStore{}
+		code, _, body := export(t)
+		checkResponseCode(t, http.StatusOK, code)
+		if body != "username,followed_at\n" {
+			t.Fatalf("unexpected export %q", body)
+		}
+	})
+
+	t.Run("should answer with an error when the export fails before any row", func(t *testing.T) {
+		app.store.Followers = &failingFollowerStore{}
+		code, _, _ := export(t)
+		checkResponseCode(t, http.StatusInternalServerError, code)
+	})
+
+	t.Run("should drop the connection when the export fails midway", func(t *testing.T) {
+		app.store.Followers = &failingFollowerStore{after: 150}
+		defer func() {
+			if rec := recover(); rec != http.ErrAbortHandler {
+				t.Fatalf("expected the handler to abort, got %v", rec)
+			}
+		}()
+		export(t)
+		t.Fatal("expected the handler to abort")
+	})
+}
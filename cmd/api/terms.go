@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// termsConfig sets how often the current terms are reloaded, an admin
+// publishing a version refreshes the instance they hit right away.
+type termsConfig struct {
+	refreshInterval time.Duration
+}
+
+// termsGate holds the current terms so authenticated requests can be checked
+// without a query.
+type termsGate struct {
+	current atomic.Pointer[[]store.TermsVersion]
+}
+
+// latestID is the highest current terms ID, 0 when nothing is published.
+func (g *termsGate) latestID() int64 {
+	var id int64
+	if current := g.current.Load(); current != nil {
+		for _, t := range *current {
+			id = max(id, t.ID)
+		}
+	}
+	return id
+}
+
+func (app *application) refreshTerms(ctx context.Context) error {
+	if app.terms == nil {
+		return nil
+	}
+	current, err := app.store.Terms.Current(ctx)
+	if err != nil {
+		return err
+	}
+	app.terms.current.Store(&current)
+	return nil
+}
+
+// termsExemptPaths stay reachable with outdated terms, so the user can read
+// and accept them or leave instead.
+var termsExemptPaths = map[string]string{
+	"/v1/users/me/accept-terms": http.MethodPost,
+	"/v1/users/me/terms":        http.MethodGet,
+	"/v1/users/me":              http.MethodDelete,
+}
+
+// termsPending reports whether the user has to accept newer terms before
+// using the route. Service accounts are run by admins and never asked.
+func (app *application) termsPending(r *http.Request, user *store.User) bool {
+	if app.terms == nil || user.IsBot || user.AcceptedTermsID >= app.terms.latestID() {
+		return false
+	}
+	method, ok := termsExemptPaths[strings.TrimSuffix(r.URL.Path, "/")]
+	return !ok || method != r.Method
+}
+
+// GetTerms godoc
+//
+//	@Summary		Current terms
+//	@Description	The current terms of service and privacy policy. Accept them by sending the highest id to /users/me/accept-terms
+//	@Tags			terms
+//	@Produce		json
+//	@Success		200	{object}	[]store.TermsVersion
+//	@Failure		500	{object}	error
+//	@Router			/terms [get]
+func (app *application) getTermsHandler(w http.ResponseWriter, r *http.Request) {
+	current, err := app.store.Terms.Current(r.Context())
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, current); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListAcceptedTerms godoc
+//
+//	@Summary		Accepted terms
+//	@Description	The terms versions the authenticated user accepted, newest first
+//	@Tags			terms
+//	@Produce		json
+//	@Success		200	{object}	[]store.TermsVersion
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/terms [get]
+func (app *application) listAcceptedTermsHandler(w http.ResponseWriter, r *http.Request) {
+	accepted, err := app.store.Terms.Accepted(r.Context(), getUserFromContext(r).ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, accepted); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+type AcceptTermsPayload struct {
+	TermsID int64 `json:"terms_id" validate:"required"`
+}
+
+// AcceptTerms godoc
+//
+//	@Summary		Accept the current terms
+//	@Description	Records that the user accepted the current terms of service and privacy policy. terms_id is the highest id from /terms
+//	@Tags			terms
+//	@Accept			json
+//	@Param			payload	body	AcceptTermsPayload	true	"Terms"
+//	@Success		204
+//	@Failure		400	{object}	error
+//	@Failure		409	{object}	error	"The terms changed in the meantime"
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/accept-terms [post]
+func (app *application) acceptTermsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload AcceptTermsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := getUserFromContext(r)
+	ctx := r.Context()
+	if err := app.store.Terms.Accept(ctx, user.ID, payload.TermsID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrTermsChanged):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type PublishTermsPayload struct {
+	Kind    string `json:"kind" validate:"required,oneof=terms privacy"`
+	Version string `json:"version" validate:"required,max=50"`
+	URL     string `json:"url" validate:"required,url"`
+	Summary string `json:"summary" validate:"max=2000"`
+}
+
+// PublishTerms godoc
+//
+//	@Summary		Publish terms
+//	@Description	Publishes a new terms of service or privacy policy version. Every user has to accept it before using the API again
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		PublishTermsPayload	true	"Version"
+//	@Success		201		{object}	store.TermsVersion
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		409		{object}	error	"Version already published"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/terms [post]
+func (app *application) publishTermsHandler(w http.ResponseWriter, r *http.Request) {
+	var payload PublishTermsPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	t := &store.TermsVersion{
+		Kind:        payload.Kind,
+		Version:     payload.Version,
+		URL:         payload.URL,
+		Summary:     payload.Summary,
+		PublishedBy: getUserFromContext(r).ID,
+	}
+	ctx := r.Context()
+	if err := app.store.Terms.Publish(ctx, t); err != nil {
+		switch {
+		case errors.Is(err, store.ErrConflict):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	app.logger.Infow("terms published", "terms_id", t.ID, "kind", t.Kind, "version", t.Version, "admin_id", t.PublishedBy)
+	if err := app.refreshTerms(ctx); err != nil {
+		app.logger.Warnw("error refreshing terms", "error", err.Error())
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, t); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ListTerms godoc
+//
+//	@Summary		List terms versions
+//	@Description	Every published terms of service and privacy policy version, newest first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit (default 20, max 100)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	[]store.TermsVersion
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/admin/terms [get]
+func (app *application) listTermsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parseLimitOffset(r, 20, 100)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	versions, err := app.store.Terms.List(r.Context(), limit, offset)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, versions); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
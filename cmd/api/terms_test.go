@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// termsUserStore reports the terms the mock terms store recorded as accepted.
+type termsUserStore struct {
+	store.MockUserStore
+	terms *store.MockTermsStore
+}
+
+func (m *termsUserStore) GetByID(ctx context.Context, id int64) (*store.User, error) {
+	return &store.User{
+		ID:              id,
+		Role:            &store.Role{Name: "admin", Level: 3},
+		AcceptedTermsID: m.terms.Acceptances[id],
+	}, nil
+}
+
+func TestTermsAcceptance(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	terms := &store.MockTermsStore{}
+	app.store.Terms = terms
+	app.store.Users = &termsUserStore{terms: terms}
+	app.terms = &termsGate{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		return executeRequest(req, app.mount()).Code
+	}
+
+	t.Run("should not require acceptance before terms are published", func(t *testing.T) {
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/announcements/", ""))
+	})
+
+	t.Run("should require acceptance of newly published terms", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/terms", `{"kind":"terms","version":"2024-01","url":"https://example.com/terms"}`))
+		checkResponseCode(t, http.StatusUnavailableForLegalReasons, request(t, http.MethodGet, "/v1/announcements/", ""))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/terms", ""))
+
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPost, "/v1/users/me/accept-terms", `{"terms_id":1}`))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/announcements/", ""))
+	})
+
+	t.Run("should reject accepting outdated terms", func(t *testing.T) {
+		checkResponseCode(t, http.StatusCreated, request(t, http.MethodPost, "/v1/admin/terms", `{"kind":"privacy","version":"2024-02","url":"https://example.com/privacy"}`))
+		checkResponseCode(t, http.StatusUnavailableForLegalReasons, request(t, http.MethodGet, "/v1/admin/terms", ""))
+		checkResponseCode(t, http.StatusConflict, request(t, http.MethodPost, "/v1/users/me/accept-terms", `{"terms_id":1}`))
+		checkResponseCode(t, http.StatusNoContent, request(t, http.MethodPost, "/v1/users/me/accept-terms", `{"terms_id":2}`))
+		checkResponseCode(t, http.StatusOK, request(t, http.MethodGet, "/v1/users/me/terms", ""))
+	})
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"gopher_social/internal/auth"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/events"
+	"gopher_social/internal/markup"
+	"gopher_social/internal/metrics"
 	"gopher_social/internal/ratelimiter"
 	"gopher_social/internal/store"
 	"gopher_social/internal/store/cache"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
@@ -27,14 +32,32 @@ func NewTestApplication(t *testing.T, cfg config) *application {
 		cfg.rateLimiter.RequestsPerTimeFrame,
 		cfg.rateLimiter.TimeFrame,
 	)
-	return &application{
-		logger:        logger,
-		store:         mockStore,
-		cacheStorage:  cacheStore,
-		authenticator: testAuth,
-		config:        cfg,
-		rateLimiter:   rateLimiter,
+	botRateLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.botRateLimiter.RequestsPerTimeFrame,
+		cfg.botRateLimiter.TimeFrame,
+	)
+	followImportLimiter := ratelimiter.NewFixedWindowLimiter(
+		cfg.followImportLimiter.RequestsPerTimeFrame,
+		cfg.followImportLimiter.TimeFrame,
+	)
+	app := &application{
+		logger:              logger,
+		store:               mockStore,
+		cacheStorage:        cacheStore,
+		authenticator:       testAuth,
+		config:              cfg,
+		rateLimiter:         rateLimiter,
+		botRateLimiter:      botRateLimiter,
+		followImportLimiter: followImportLimiter,
+		markup:              markup.NewRenderer(),
+		feedMetrics:         metrics.NewQueryRecorder(metrics.DefaultWindow),
+		routeMetrics:        metrics.NewQueryRecorder(metrics.DefaultWindow),
+		mailBreaker:         breaker.New(3, time.Minute),
 	}
+	bus := events.NewBus(logger)
+	app.subscribeEvents(bus)
+	app.events = bus
+	return app
 }
 func executeRequest(req *http.Request, mux *chi.Mux) *httptest.ResponseRecorder {
 	rr := httptest.NewRecorder()
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// removablePostStore holds posts by user 7 until they are deleted.
+type removablePostStore struct {
+	store.MockPostStore
+	deleted map[int64]bool
+}
+
+func (m *removablePostStore) GetByID(ctx context.Context, id int64) (*store.Post, error) {
+	if m.deleted[id] {
+		return nil, store.ErrRecordNotFound
+	}
+	return &store.Post{ID: id, UserID: 7}, nil
+}
+
+func (m *removablePostStore) Delete(ctx context.Context, id int64) error {
+	m.deleted[id] = true
+	return nil
+}
+
+func TestPostTombstones(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	app.store.Posts = &removablePostStore{deleted: map[int64]bool{9: true}}
+	app.store.Users = &adminUserStore{}
+	tombstones := &store.MockTombstoneStore{}
+	app.store.Tombstones = tombstones
+	app.store.ModerationCases = &store.MockModerationCaseStore{}
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := app.mount()
+
+	request := func(t *testing.T, method, path string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+testToken)
+		rr := executeRequest(req, mux)
+		return rr.Code, rr.Body.String()
+	}
+
+	t.Run("should reject overlong removal reasons", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v1/posts/5?reason="+strings.Repeat("x", 501))
+		checkResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should leave a tombstone when a moderator removes a post", func(t *testing.T) {
+		code, _ := request(t, http.MethodDelete, "/v1/posts/5?reason=spam")
+		checkResponseCode(t, http.StatusNoContent, code)
+		if len(tombstones.Tombstones) != 1 {
+			t.Fatalf("expected a tombstone, got %d", len(tombstones.Tombstones))
+		}
+
+		code, body := request(t, http.MethodGet, "/v1/posts/5")
+		checkResponseCode(t, http.StatusGone, code)
+		var got struct {
+			Code      string              `json:"code"`
+			Tombstone store.PostTombstone `json:"tombstone"`
+		}
+		if err := json.Unmarshal([]byte(body), &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Code != "post_removed" || got.Tombstone.Reason != "spam" || got.Tombstone.CaseID == nil || got.Tombstone.RemovedAt.IsZero() {
+			t.Errorf("unexpected tombstone response: %s", body)
+		}
+	})
+
+	t.Run("should keep the comments of a removed post readable", func(t *testing.T) {
+		code, _ := request(t, http.MethodGet, "/v1/posts/5/comments")
+		checkResponseCode(t, http.StatusOK, code)
+		code, _ = request(t, http.MethodPost, "/v1/posts/5/comments")
+		checkResponseCode(t, http.StatusGone, code)
+	})
+
+	t.Run("should answer public permalinks with the tombstone", func(t *testing.T) {
+		code, _ := request(t, http.MethodGet, "/v1/public/posts/5")
+		checkResponseCode(t, http.StatusGone, code)
+	})
+
+	t.Run("should still 404 posts that were never removed by a moderator", func(t *testing.T) {
+		code, _ := request(t, http.MethodGet, "/v1/posts/9")
+		checkResponseCode(t, http.StatusNotFound, code)
+		code, _ = request(t, http.MethodGet, "/v1/posts/9/comments")
+		checkResponseCode(t, http.StatusNotFound, code)
+	})
+}
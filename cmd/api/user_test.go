@@ -2,6 +2,8 @@ package main
 
 import (
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -81,3 +83,43 @@ func TestGetUser(t *testing.T) {
 	// })
 
 }
+
+func TestReadFollowImport(t *testing.T) {
+	t.Run("should skip the header, blanks and duplicates", func(t *testing.T) {
+		got, err := readFollowImport(strings.NewReader("username,followed_at\nalice,2024-01-01\n@bob\n\nalice\ncarol@example.com\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"alice", "bob", "carol@example.com"}
+		if !slices.Equal(got, want) {
+			t.Errorf("expected %v, got %v", want, got)
+		}
+	})
+
+	t.Run("should reject an empty import", func(t *testing.T) {
+		if _, err := readFollowImport(strings.NewReader("username,followed_at\n")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestGetFollowerInsights(t *testing.T) {
+	app := NewTestApplication(t, config{})
+	mux := app.mount()
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, days := range []string{"0", "366", "week"} {
+		t.Run("should reject days="+days, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, "/v1/users/me/insights?days="+days, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+testToken)
+			rr := executeRequest(req, mux)
+			checkResponseCode(t, http.StatusBadRequest, rr.Code)
+		})
+	}
+}
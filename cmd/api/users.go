@@ -1,18 +1,31 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"gopher_social/internal/events"
+	"gopher_social/internal/mailer"
 	"gopher_social/internal/store"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 type userKey string
 
 var userCtx userKey
 
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
 // @Summary		Fetches a user profile
 // @Description	Fetches a user profile by ID
 // @Tags			users
@@ -43,6 +56,24 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if visible, err := app.canViewUser(ctx, getUserFromContext(r), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	} else if !visible {
+		app.notFoundResponse(w, r, store.ErrRecordNotFound)
+		return
+	}
+	if user.ID == getUserFromContext(r).ID {
+		adult := app.isAdult(user)
+		user.Adult = &adult
+	} else {
+		user.Birthdate = nil
+		user.PostRetentionMonths = nil
+	}
+	if user.Fields, err = app.store.ProfileFields.List(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
 
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
 		app.internalServerError(w, r, err)
@@ -91,6 +122,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 
 		}
 	}
+	app.events.Publish(ctx, events.UserFollowed{FollowerID: followerUser.ID, FollowedID: followedUserID})
 
 	if err := app.jsonResponse(w, http.StatusNoContent, nil); err != nil {
 		app.internalServerError(w, r, err)
@@ -133,6 +165,57 @@ func (app *application) unfollowUserHandler(w http.ResponseWriter, r *http.Reque
 
 }
 
+// SubscribeUser godoc
+//
+//	@Summary		Subscribe to a user's posts
+//	@Description	Rings the bell on a followed user: every new post of theirs creates a notification
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Subscribed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Not following the user"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/subscribe [put]
+func (app *application) subscribeUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.setFollowNotify(w, r, true)
+}
+
+// UnsubscribeUser godoc
+//
+//	@Summary		Unsubscribe from a user's posts
+//	@Description	Turns the bell off again, the follow itself stays
+//	@Tags			users
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Unsubscribed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error	"Not following the user"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/{userID}/unsubscribe [put]
+func (app *application) unsubscribeUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.setFollowNotify(w, r, false)
+}
+
+func (app *application) setFollowNotify(w http.ResponseWriter, r *http.Request, notify bool) {
+	follower := getUserFromContext(r)
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := app.store.Followers.SetNotify(r.Context(), follower.ID, userID, notify); err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // ActivateUser godoc
 //
 //	@Summary		Activate a user
@@ -162,6 +245,125 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 
 }
 
+type ChangeEmailPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type EmailChangeResponse struct {
+	PendingEmail string `json:"pending_email"`
+}
+
+// ChangeEmail godoc
+//
+//	@Summary		Change email address
+//	@Description	Mails a confirmation link to the new address, the account keeps its current address until the link is followed. A new request replaces the pending one. Requires a recent authentication
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		ChangeEmailPayload	true	"New address"
+//	@Success		202		{object}	EmailChangeResponse
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error	"The address belongs to another account"
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/email [patch]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var payload ChangeEmailPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	user := getUserFromContext(r)
+	if strings.EqualFold(payload.Email, user.Email) {
+		app.badRequestResponse(w, r, errors.New("that is already the account's address"))
+		return
+	}
+
+	ctx := r.Context()
+	response := EmailChangeResponse{PendingEmail: payload.Email}
+	existing, err := app.store.Users.GetByEmail(ctx, payload.Email)
+	if err != nil && !errors.Is(err, store.ErrRecordNotFound) {
+		app.internalServerError(w, r, err)
+		return
+	}
+	// A taken address is answered like a free one when enumeration is
+	// guarded against, the link would fail anyway.
+	if err == nil && existing != nil {
+		if app.config.auth.antiEnumeration {
+			app.logger.Warnw("email change to a taken address", "user_id", user.ID)
+			if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+		app.conflictResponse(w, r, store.ErrDuplicateEmail)
+		return
+	}
+
+	plainToken := uuid.New().String()
+	hash := sha256.Sum256([]byte(plainToken))
+	hashToken := hex.EncodeToString(hash[:])
+	if err := app.store.Users.RequestEmailChange(ctx, user.ID, payload.Email, hashToken, app.config.mail.exp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	vars := struct {
+		Username   string
+		ConfirmURL string
+		Expiry     string
+	}{
+		Username:   user.Username,
+		ConfirmURL: fmt.Sprintf("%s/confirm-email/%s", app.config.frontendURL, plainToken),
+		Expiry:     inWords(user.Locale, app.config.mail.exp),
+	}
+	if _, err := app.sendMail(ctx, mailer.EmailChangeTemplate, user.Locale, user.Username, payload.Email, vars); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.auditLog("email.change_requested", user.ID)
+
+	if err := app.jsonResponse(w, http.StatusAccepted, response); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// ConfirmEmailChange godoc
+//
+//	@Summary		Confirm an email change
+//	@Description	Swaps in the new address of the account the confirmation link was mailed for
+//	@Tags			users
+//	@Param			token	path	string	true	"Confirmation token"
+//	@Success		204
+//	@Failure		404	{object}	error	"Unknown or expired token"
+//	@Failure		409	{object}	error	"The address was taken in the meantime"
+//	@Failure		500	{object}	error
+//	@Router			/users/confirm-email/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := app.store.Users.ConfirmEmailChange(ctx, chi.URLParam(r, "token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRecordNotFound):
+			app.notFoundResponse(w, r, err)
+		case errors.Is(err, store.ErrDuplicateEmail):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, userID)
+	}
+	app.auditLog("email.changed", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // func (app *application) userContextMiddleware(next http.Handler) http.Handler {
 // 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 // 		idParams := chi.URLParam(r, "userID")
@@ -187,7 +389,137 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 // 	})
 // }
 
+// deleteAccountHandler godoc
+//
+//	@Summary		Delete own account
+//	@Description	Permanently deletes the authenticated user, requires a recent re-authentication
+//	@Tags			users
+//	@Success		204	{string}	string	"Account deleted"
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	ctx := r.Context()
+	if err := app.store.Users.Delete(ctx, user.ID); err != nil {
+		switch {
+		case errors.Is(err, store.ErrUnderLegalHold):
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	app.clearSessionCookies(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// canViewUser hides users under legal hold from everyone but themselves and
+// admins.
+func (app *application) canViewUser(ctx context.Context, viewer *store.User, userID int64) (bool, error) {
+	if viewer.ID == userID {
+		return true, nil
+	}
+	held, err := app.store.LegalHolds.IsHeld(ctx, store.HoldSubjectUser, userID)
+	if err != nil {
+		return false, err
+	}
+	if !held {
+		return true, nil
+	}
+	return app.checkRolePrecedence(ctx, viewer, "admin")
+}
+
 func getUserFromContext(r *http.Request) *store.User {
 	user, _ := r.Context().Value(userCtx).(*store.User)
 	return user
 }
+
+func getClaimsFromContext(r *http.Request) jwt.MapClaims {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	return claims
+}
+
+type PreferredLanguagesPayload struct {
+	Languages []string `json:"languages" validate:"max=5,dive,len=2,lowercase"`
+}
+
+// SetPreferredLanguages godoc
+//
+//	@Summary		Set preferred languages
+//	@Description	Stores the ISO 639-1 languages that are ranked first on the explore feed
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		PreferredLanguagesPayload	true	"Languages"
+//	@Success		204		{string}	string						"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/languages [put]
+func (app *application) setPreferredLanguagesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload PreferredLanguagesPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.Languages == nil {
+		payload.Languages = []string{}
+	}
+	ctx := r.Context()
+	if err := app.store.Users.SetPreferredLanguages(ctx, user.ID, payload.Languages); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type ContentWarningPrefPayload struct {
+	Preference string `json:"preference" validate:"required,oneof=collapse expand hide"`
+}
+
+// SetContentWarningPref godoc
+//
+//	@Summary		Set content warning preference
+//	@Description	Chooses whether posts with a content warning are collapsed, expanded or hidden from feeds
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		ContentWarningPrefPayload	true	"Preference"
+//	@Success		204		{string}	string						"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/content-warnings [put]
+func (app *application) setContentWarningPrefHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload ContentWarningPrefPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	ctx := r.Context()
+	if err := app.store.Users.SetContentWarningPref(ctx, user.ID, payload.Preference); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(ctx, user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+const webauthnChallengeExp = 5 * time.Minute
+
+var (
+	errChallengeExpired     = errors.New("passkey challenge expired or already used")
+	errNoPasskeys           = errors.New("no passkeys enrolled for this account")
+	errLastPasskey          = errors.New("cannot remove the last passkey of a passwordless account")
+	errChallengesNeedsRedis = errors.New("passkeys require the redis cache to be enabled")
+)
+
+// webauthnUser adapts a store user and its credentials to the webauthn.User
+// interface expected by the ceremonies.
+type webauthnUser struct {
+	user        *store.User
+	credentials []webauthn.Credential
+}
+
+func (u *webauthnUser) WebAuthnID() []byte {
+	id := make([]byte, 8)
+	binary.BigEndian.PutUint64(id, uint64(u.user.ID))
+	return id
+}
+func (u *webauthnUser) WebAuthnName() string                       { return u.user.Email }
+func (u *webauthnUser) WebAuthnDisplayName() string                { return u.user.Username }
+func (u *webauthnUser) WebAuthnCredentials() []webauthn.Credential { return u.credentials }
+
+// webauthnSession is what is kept in the challenge store between the begin
+// and finish steps of a ceremony.
+type webauthnSession struct {
+	UserID  int64                `json:"user_id"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+type PasskeyLoginPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type PasskeyLoginOptions struct {
+	SessionID string                        `json:"session_id"`
+	Options   *protocol.CredentialAssertion `json:"options" swaggertype:"object"`
+}
+
+type PasswordlessPayload struct {
+	Enabled bool `json:"enabled"`
+}
+
+func (app *application) loadWebauthnUser(r *http.Request, user *store.User) (*webauthnUser, error) {
+	stored, err := app.store.Credentials.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, c := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(c.Data, &credential); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, credential)
+	}
+	return &webauthnUser{user: user, credentials: credentials}, nil
+}
+
+// decoyWebauthnUser stands in for unknown emails and accounts without
+// passkeys, so login/begin answers them as it answers an account with one.
+// The credential ID is derived from the email, repeated requests get the
+// same one like they would for a real passkey.
+func (app *application) decoyWebauthnUser(email string) *webauthnUser {
+	mac := hmac.New(sha256.New, []byte(app.config.auth.token.secret))
+	mac.Write([]byte("passkey-decoy:" + strings.ToLower(email)))
+	return &webauthnUser{
+		user:        &store.User{Email: email},
+		credentials: []webauthn.Credential{{ID: mac.Sum(nil)}},
+	}
+}
+
+func (app *application) saveWebauthnSession(r *http.Request, key string, userID int64, session *webauthn.SessionData) error {
+	if !app.config.redisCfg.enabled {
+		return errChallengesNeedsRedis
+	}
+	data, err := json.Marshal(webauthnSession{UserID: userID, Session: *session})
+	if err != nil {
+		return err
+	}
+	return app.cacheStorage.Challenges.Set(r.Context(), key, data, webauthnChallengeExp)
+}
+
+func (app *application) popWebauthnSession(r *http.Request, key string) (*webauthnSession, error) {
+	if !app.config.redisCfg.enabled {
+		return nil, errChallengesNeedsRedis
+	}
+	data, err := app.cacheStorage.Challenges.Pop(r.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, errChallengeExpired
+	}
+	var session webauthnSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// beginPasskeyRegistrationHandler godoc
+//
+//	@Summary		Begin passkey registration
+//	@Description	Returns the credential creation options for the authenticated user. Requires a recent authentication
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	object	"Credential creation options"
+//	@Failure		401	{object}	error
+//	@Failure		403	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/webauthn/register/begin [post]
+func (app *application) beginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	waUser, err := app.loadWebauthnUser(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(waUser.credentials))
+	for _, c := range waUser.credentials {
+		exclusions = append(exclusions, c.Descriptor())
+	}
+	options, session, err := app.webauthn.BeginRegistration(
+		waUser,
+		webauthn.WithExclusions(exclusions),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.saveWebauthnSession(r, registrationChallengeKey(user.ID), user.ID, session); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, options); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// finishPasskeyRegistrationHandler godoc
+//
+//	@Summary		Finish passkey registration
+//	@Description	Verifies the attestation and stores the new passkey. Requires a recent authentication
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			name	query		string	false	"Passkey name"
+//	@Success		201		{object}	store.Credential
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/webauthn/register/finish [post]
+func (app *application) finishPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	session, err := app.popWebauthnSession(r, registrationChallengeKey(user.ID))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	waUser, err := app.loadWebauthnUser(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	credential, err := app.webauthn.FinishRegistration(waUser, session.Session, r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	stored := &store.Credential{
+		ID:     credential.ID,
+		UserID: user.ID,
+		Name:   r.URL.Query().Get("name"),
+		Data:   data,
+	}
+	if err := app.store.Credentials.Create(r.Context(), stored); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusCreated, stored); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// beginPasskeyLoginHandler godoc
+//
+//	@Summary		Begin passkey login
+//	@Description	Returns the assertion options and a session id to finish the login with. Unknown emails get options too, which no passkey can answer
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		PasskeyLoginPayload	true	"Account email"
+//	@Success		200		{object}	PasskeyLoginOptions
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/webauthn/login/begin [post]
+func (app *application) beginPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var payload PasskeyLoginPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	// Unknown emails and accounts without passkeys get a decoy challenge
+	// that can't be answered, an error would tell which emails have an
+	// account. Their session has no user and fails at login/finish.
+	var (
+		waUser *webauthnUser
+		userID int64
+	)
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	switch err {
+	case nil:
+		if waUser, err = app.loadWebauthnUser(r, user); err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if len(waUser.credentials) == 0 {
+			waUser = app.decoyWebauthnUser(payload.Email)
+		} else {
+			userID = user.ID
+		}
+	case store.ErrRecordNotFound:
+		waUser = app.decoyWebauthnUser(payload.Email)
+	default:
+		app.internalServerError(w, r, err)
+		return
+	}
+	options, session, err := app.webauthn.BeginLogin(waUser)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	sessionID := uuid.New().String()
+	if err := app.saveWebauthnSession(r, loginChallengeKey(sessionID), userID, session); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, PasskeyLoginOptions{SessionID: sessionID, Options: options}); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// finishPasskeyLoginHandler godoc
+//
+//	@Summary		Finish passkey login
+//	@Description	Verifies the assertion and issues a token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			session	query		string	true	"Session id returned by login/begin"
+//	@Success		200		{object}	string
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/auth/webauthn/login/finish [post]
+func (app *application) finishPasskeyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	session, err := app.popWebauthnSession(r, loginChallengeKey(r.URL.Query().Get("session")))
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+	if session.UserID == 0 {
+		app.unauthorizedErrorResponse(w, r, errNoPasskeys)
+		return
+	}
+	user, err := app.store.Users.GetByID(r.Context(), session.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrRecordNotFound:
+			app.unauthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	waUser, err := app.loadWebauthnUser(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	credential, err := app.webauthn.FinishLogin(waUser, session.Session, r)
+	if err != nil {
+		app.unauthorizedErrorResponse(w, r, err)
+		return
+	}
+	if credential.Authenticator.CloneWarning {
+		app.logger.Warnw("passkey sign counter went backwards, possible cloned authenticator", "userID", user.ID)
+	}
+	data, err := json.Marshal(credential)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.store.Credentials.Touch(r.Context(), credential.ID, data); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	app.issueToken(w, r, user)
+}
+
+// listPasskeysHandler godoc
+//
+//	@Summary		List passkeys
+//	@Description	Lists the passkeys enrolled by the authenticated user
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	[]store.Credential
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/webauthn/credentials [get]
+func (app *application) listPasskeysHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	credentials, err := app.store.Credentials.GetByUserID(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if err := app.jsonResponse(w, http.StatusOK, credentials); err != nil {
+		app.internalServerError(w, r, err)
+	}
+}
+
+// deletePasskeyHandler godoc
+//
+//	@Summary		Delete a passkey
+//	@Description	Removes a passkey, the last one of a passwordless account can't be removed
+//	@Tags			authentication
+//	@Param			credentialID	path		string	true	"Base64url credential ID"
+//	@Success		204				{string}	string	"Passkey deleted"
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Failure		500				{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/auth/webauthn/credentials/{credentialID} [delete]
+func (app *application) deletePasskeyHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	id, err := base64.RawURLEncoding.DecodeString(chi.URLParam(r, "credentialID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if user.Passwordless {
+		credentials, err := app.store.Credentials.GetByUserID(r.Context(), user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if len(credentials) <= 1 {
+			app.badRequestResponse(w, r, errLastPasskey)
+			return
+		}
+	}
+	if err := app.store.Credentials.Delete(r.Context(), user.ID, id); err != nil {
+		switch err {
+		case store.ErrRecordNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// setPasswordlessHandler godoc
+//
+//	@Summary		Toggle password-less login
+//	@Description	Disables (or re-enables) password login once a passkey is enrolled
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		PasswordlessPayload	true	"Passwordless flag"
+//	@Success		204		{string}	string				"Updated"
+//	@Failure		400		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		ApiKeyAuth
+//	@Router			/users/me/passwordless [put]
+func (app *application) setPasswordlessHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	var payload PasswordlessPayload
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+	if payload.Enabled {
+		credentials, err := app.store.Credentials.GetByUserID(r.Context(), user.ID)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+		if len(credentials) == 0 {
+			app.badRequestResponse(w, r, errNoPasskeys)
+			return
+		}
+	}
+	if err := app.store.Users.SetPasswordless(r.Context(), user.ID, payload.Enabled); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+	if app.config.redisCfg.enabled {
+		app.cacheStorage.Users.Delete(r.Context(), user.ID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func registrationChallengeKey(userID int64) string {
+	return fmt.Sprintf("webauthn-register-%d", userID)
+}
+
+func loginChallengeKey(sessionID string) string {
+	return "webauthn-login-" + sessionID
+}
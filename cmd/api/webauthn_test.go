@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/store"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// passkeyUserStore knows gopher@example.com once registered is set.
+type passkeyUserStore struct {
+	store.MockUserStore
+	registered bool
+}
+
+func (m *passkeyUserStore) GetByEmail(ctx context.Context, email string) (*store.User, error) {
+	if !m.registered || email != "gopher@example.com" {
+		return nil, store.ErrRecordNotFound
+	}
+	return &store.User{ID: 42, Email: email, Username: "gopher"}, nil
+}
+
+// noCredentialStore is an account without passkeys.
+type noCredentialStore struct{}
+
+func (noCredentialStore) Create(context.Context, *store.Credential) error { return nil }
+func (noCredentialStore) GetByUserID(context.Context, int64) ([]store.Credential, error) {
+	return nil, nil
+}
+func (noCredentialStore) Touch(ctx context.Context, id []byte, data []byte) error   { return nil }
+func (noCredentialStore) Delete(ctx context.Context, userID int64, id []byte) error { return nil }
+
+func TestPasskeyLoginDoesNotRevealAccounts(t *testing.T) {
+	app := NewTestApplication(t, config{
+		redisCfg: redisConfig{enabled: true},
+		auth:     authConfig{token: tokenConfig{secret: "secret"}},
+	})
+	wa, err := webauthn.New(&webauthn.Config{RPID: "localhost", RPDisplayName: "Gophers", RPOrigins: []string{"http://localhost"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.webauthn = wa
+	users := &passkeyUserStore{}
+	app.store.Users = users
+	app.store.Credentials = noCredentialStore{}
+
+	// begin returns the response with the parts that change per request,
+	// the challenge and session id, blanked out.
+	begin := func(t *testing.T) string {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, "/v1/auth/webauthn/login/begin", strings.NewReader(`{"email":"gopher@example.com"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := executeRequest(req, app.mount())
+		checkResponseCode(t, http.StatusOK, rr.Code)
+		var body struct {
+			Data struct {
+				SessionID string         `json:"session_id"`
+				Options   map[string]any `json:"options"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Data.SessionID == "" {
+			t.Fatal("expected a session id")
+		}
+		publicKey := body.Data.Options["publicKey"].(map[string]any)
+		delete(publicKey, "challenge")
+		out, err := json.Marshal(body.Data.Options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(out)
+	}
+
+	unknown := begin(t)
+	users.registered = true
+	withoutPasskeys := begin(t)
+	if unknown != withoutPasskeys {
+		t.Errorf("responses differ:\nunknown email:    %s\nwithout passkeys: %s", unknown, withoutPasskeys)
+	}
+	if !strings.Contains(unknown, "allowCredentials") {
+		t.Errorf("expected the decoy to list a credential like a real account, got %s", unknown)
+	}
+}
+
+func TestPasskeyRegistrationRequiresSudo(t *testing.T) {
+	app := NewTestApplication(t, config{auth: authConfig{sudoWindow: time.Minute}})
+	mux := app.mount()
+	staleToken := signTestToken(t, jwt.MapClaims{"sub": 42, "exp": time.Now().Add(time.Hour).Unix()})
+
+	for _, path := range []string{"/v1/auth/webauthn/register/begin", "/v1/auth/webauthn/register/finish"} {
+		req, err := http.NewRequest(http.MethodPost, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+staleToken)
+		checkResponseCode(t, http.StatusForbidden, executeRequest(req, mux).Code)
+	}
+}
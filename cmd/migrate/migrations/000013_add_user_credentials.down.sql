@@ -0,0 +1,4 @@
+ALTER TABLE IF EXISTS users
+DROP COLUMN passwordless;
+
+DROP TABLE IF EXISTS user_credentials;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_credentials(
+    id bytea PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL DEFAULT '',
+    data jsonb NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_credentials_user_id ON user_credentials (user_id);
+
+ALTER TABLE
+    users
+ADD
+    COLUMN passwordless BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS media(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blob_key text UNIQUE NOT NULL,
+    content_type varchar(100) NOT NULL,
+    size bigint NOT NULL,
+    visibility varchar(20) NOT NULL DEFAULT 'public',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_media_user_id ON media (user_id);
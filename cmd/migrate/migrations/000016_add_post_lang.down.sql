@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_lang;
+ALTER TABLE users DROP COLUMN IF EXISTS preferred_languages;
+ALTER TABLE posts DROP COLUMN IF EXISTS lang;
@@ -0,0 +1,3 @@
+ALTER TABLE posts ADD COLUMN lang varchar(8) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN preferred_languages varchar(8)[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_posts_lang ON posts (lang);
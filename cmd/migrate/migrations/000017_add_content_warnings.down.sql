@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS content_warning_pref;
+ALTER TABLE posts DROP COLUMN IF EXISTS content_warning;
@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN content_warning varchar(200) NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN content_warning_pref varchar(10) NOT NULL DEFAULT 'collapse';
@@ -0,0 +1,7 @@
+ALTER TABLE posts ADD COLUMN kind varchar(20) NOT NULL DEFAULT 'note';
+
+CREATE TABLE IF NOT EXISTS post_bodies(
+    post_id bigint PRIMARY KEY REFERENCES posts(id) ON DELETE CASCADE,
+    body text NOT NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
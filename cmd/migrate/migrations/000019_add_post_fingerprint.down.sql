@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_posts_user_fingerprint;
+ALTER TABLE posts DROP COLUMN IF EXISTS fingerprint;
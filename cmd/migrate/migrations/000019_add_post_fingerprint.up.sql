@@ -0,0 +1,2 @@
+ALTER TABLE posts ADD COLUMN fingerprint varchar(64) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_posts_user_fingerprint ON posts (user_id, fingerprint, created_at);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS post_impressions(
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    viewer_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    source varchar(20) NOT NULL DEFAULT 'direct',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_impressions_post_created ON post_impressions (post_id, created_at);
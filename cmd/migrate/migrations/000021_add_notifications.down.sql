@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS notifications;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
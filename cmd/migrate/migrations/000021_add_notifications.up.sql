@@ -0,0 +1,14 @@
+ALTER TABLE comments ADD COLUMN parent_id bigint REFERENCES comments(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS notifications(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    actor_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type varchar(30) NOT NULL,
+    post_id bigint REFERENCES posts(id) ON DELETE CASCADE,
+    comment_id bigint REFERENCES comments(id) ON DELETE CASCADE,
+    read_at timestamp(0) with time zone,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_created ON notifications (user_id, created_at DESC);
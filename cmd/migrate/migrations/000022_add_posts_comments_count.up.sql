@@ -0,0 +1,6 @@
+ALTER TABLE posts ADD COLUMN comments_count integer NOT NULL DEFAULT 0;
+
+UPDATE posts p
+SET comments_count = c.total
+FROM (SELECT post_id, COUNT(*) AS total FROM comments GROUP BY post_id) c
+WHERE c.post_id = p.id;
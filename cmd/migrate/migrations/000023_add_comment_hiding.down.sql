@@ -0,0 +1,2 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS hidden_by;
+ALTER TABLE comments DROP COLUMN IF EXISTS hidden_at;
@@ -0,0 +1,2 @@
+ALTER TABLE comments ADD COLUMN hidden_at timestamp(0) with time zone;
+ALTER TABLE comments ADD COLUMN hidden_by bigint REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS post_reactions(
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (post_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_reactions_post_type ON post_reactions (post_id, type);
+
+INSERT INTO post_reactions (post_id, user_id, type, created_at)
+SELECT subject_id, user_id, type, created_at FROM reactions
+WHERE subject_type = 'post' AND subject_id IN (SELECT id FROM posts);
+
+DROP TABLE IF EXISTS reactions;
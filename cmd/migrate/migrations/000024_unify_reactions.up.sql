@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS reactions(
+    subject_type varchar(20) NOT NULL,
+    subject_id bigint NOT NULL,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subject_type, subject_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_reactions_subject_type ON reactions (subject_type, subject_id, type);
+
+INSERT INTO reactions (subject_type, subject_id, user_id, type, created_at)
+SELECT 'post', post_id, user_id, type, created_at FROM post_reactions;
+
+DROP TABLE IF EXISTS post_reactions;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_reactions_subject_created ON reactions (subject_type, subject_id, created_at DESC);
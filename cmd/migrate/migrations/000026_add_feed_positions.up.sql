@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS feed_positions(
+    user_id bigint PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    cursor varchar(64) NOT NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
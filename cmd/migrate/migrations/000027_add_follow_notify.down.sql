@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_followers_user_notify;
+ALTER TABLE followers DROP COLUMN IF EXISTS notify;
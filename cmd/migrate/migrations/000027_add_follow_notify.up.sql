@@ -0,0 +1,2 @@
+ALTER TABLE followers ADD COLUMN notify boolean NOT NULL DEFAULT false;
+CREATE INDEX IF NOT EXISTS idx_followers_user_notify ON followers (user_id) WHERE notify;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_notifications_user_actor_type;
+ALTER TABLE users DROP COLUMN IF EXISTS muted_notification_types;
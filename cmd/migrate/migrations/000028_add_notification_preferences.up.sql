@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN muted_notification_types varchar(30)[] NOT NULL DEFAULT '{}';
+CREATE INDEX IF NOT EXISTS idx_notifications_user_actor_type ON notifications (user_id, actor_id, type);
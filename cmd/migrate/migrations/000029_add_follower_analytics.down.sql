@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS follower_engagement;
+DROP TABLE IF EXISTS follower_daily_stats;
+DROP TABLE IF EXISTS unfollows;
@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS unfollows(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    follower_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_unfollows_created ON unfollows (created_at);
+
+CREATE TABLE IF NOT EXISTS follower_daily_stats(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    day date NOT NULL,
+    followers int NOT NULL DEFAULT 0,
+    gained int NOT NULL DEFAULT 0,
+    lost int NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE TABLE IF NOT EXISTS follower_engagement(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    follower_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    score int NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, follower_id)
+);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS hashtag_daily_counts;
+DROP TABLE IF EXISTS retention_cohorts;
+DROP TABLE IF EXISTS platform_daily_stats;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS platform_daily_stats(
+    day date PRIMARY KEY,
+    dau int NOT NULL DEFAULT 0,
+    mau int NOT NULL DEFAULT 0,
+    signups int NOT NULL DEFAULT 0,
+    posts int NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS retention_cohorts(
+    cohort_week date NOT NULL,
+    week_offset int NOT NULL,
+    cohort_size int NOT NULL,
+    retained int NOT NULL,
+    PRIMARY KEY (cohort_week, week_offset)
+);
+
+CREATE TABLE IF NOT EXISTS hashtag_daily_counts(
+    day date NOT NULL,
+    tag varchar(100) NOT NULL,
+    posts int NOT NULL,
+    PRIMARY KEY (day, tag)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS mail_outbox(
+    id bigserial PRIMARY KEY,
+    template varchar(100) NOT NULL,
+    username varchar(255) NOT NULL,
+    email citext NOT NULL,
+    data jsonb NOT NULL DEFAULT '{}',
+    sandbox boolean NOT NULL DEFAULT false,
+    attempts int NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    sent_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_mail_outbox_pending ON mail_outbox (id) WHERE sent_at IS NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS outbox_events(
+    id bigserial PRIMARY KEY,
+    topic varchar(50) NOT NULL,
+    aggregate_id bigint NOT NULL,
+    attempts int NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    processed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (topic, id) WHERE processed_at IS NULL;
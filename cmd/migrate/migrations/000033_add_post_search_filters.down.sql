@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_posts_has_media;
+DROP INDEX IF EXISTS idx_posts_reactions_count;
+DROP INDEX IF EXISTS idx_posts_created_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS has_media;
+ALTER TABLE posts DROP COLUMN IF EXISTS reactions_count;
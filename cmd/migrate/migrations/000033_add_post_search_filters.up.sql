@@ -0,0 +1,13 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS reactions_count integer NOT NULL DEFAULT 0;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS has_media boolean NOT NULL DEFAULT false;
+
+UPDATE posts p
+SET reactions_count = r.total
+FROM (SELECT subject_id, COUNT(*) AS total FROM reactions WHERE subject_type = 'post' GROUP BY subject_id) r
+WHERE r.subject_id = p.id;
+
+UPDATE posts SET has_media = true WHERE content ~ '!\[[^\]]*\]\([^)]+\)';
+
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_reactions_count ON posts (reactions_count);
+CREATE INDEX IF NOT EXISTS idx_posts_has_media ON posts (created_at) WHERE has_media;
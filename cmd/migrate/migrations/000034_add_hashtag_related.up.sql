@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS hashtag_related(
+    tag varchar(100) NOT NULL,
+    related varchar(100) NOT NULL,
+    posts int NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tag, related)
+);
+
+CREATE INDEX IF NOT EXISTS idx_hashtag_related_rank ON hashtag_related (tag, posts DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_posts_fingerprint_created;
+DROP TABLE IF EXISTS user_risk;
+DROP TABLE IF EXISTS risk_signals;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS risk_signals(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    signal varchar(50) NOT NULL,
+    weight int NOT NULL,
+    detail text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_risk_signals_user ON risk_signals (user_id, signal, created_at);
+
+CREATE TABLE IF NOT EXISTS user_risk(
+    user_id bigint PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    score int NOT NULL DEFAULT 0,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_risk_score ON user_risk (score DESC);
+
+CREATE INDEX IF NOT EXISTS idx_posts_fingerprint_created ON posts (fingerprint, created_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS moderator_notes(
+    id bigserial PRIMARY KEY,
+    subject_type varchar(20) NOT NULL,
+    subject_id bigint NOT NULL,
+    author_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    body text NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderator_notes_subject ON moderator_notes (subject_type, subject_id, created_at);
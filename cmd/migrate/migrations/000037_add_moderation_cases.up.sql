@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS moderation_cases(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    action varchar(30) NOT NULL,
+    subject_type varchar(20) NOT NULL,
+    subject_id bigint NOT NULL,
+    moderator_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    reason text NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    appeal_status varchar(20) NOT NULL DEFAULT '',
+    appeal_text text NOT NULL DEFAULT '',
+    appealed_at timestamp(0) with time zone,
+    resolved_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    resolution_note text NOT NULL DEFAULT '',
+    resolved_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_moderation_cases_user ON moderation_cases (user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_moderation_cases_appeals ON moderation_cases (appeal_status, appealed_at) WHERE appeal_status <> '';
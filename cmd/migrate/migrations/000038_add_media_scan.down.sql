@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_media_quarantined;
+
+ALTER TABLE media DROP COLUMN IF EXISTS reviewed_at;
+ALTER TABLE media DROP COLUMN IF EXISTS reviewed_by;
+ALTER TABLE media DROP COLUMN IF EXISTS scan_results;
+ALTER TABLE media DROP COLUMN IF EXISTS scan_status;
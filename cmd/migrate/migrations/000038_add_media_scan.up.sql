@@ -0,0 +1,6 @@
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scan_status varchar(20) NOT NULL DEFAULT 'clean';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS scan_results jsonb NOT NULL DEFAULT '[]';
+ALTER TABLE media ADD COLUMN IF NOT EXISTS reviewed_by bigint REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE media ADD COLUMN IF NOT EXISTS reviewed_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_media_quarantined ON media (created_at) WHERE scan_status = 'quarantined';
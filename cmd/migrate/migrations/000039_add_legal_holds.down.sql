@@ -0,0 +1,3 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS on_hold;
+ALTER TABLE users DROP COLUMN IF EXISTS on_hold;
+DROP TABLE IF EXISTS legal_holds;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS legal_holds(
+    id bigserial PRIMARY KEY,
+    subject_type varchar(20) NOT NULL,
+    subject_id bigint NOT NULL,
+    reason text NOT NULL,
+    placed_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    released_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    released_at timestamp(0) with time zone
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_legal_holds_active ON legal_holds (subject_type, subject_id) WHERE released_at IS NULL;
+
+ALTER TABLE users ADD COLUMN on_hold boolean NOT NULL DEFAULT false;
+ALTER TABLE posts ADD COLUMN on_hold boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS announcement_dismissals;
+DROP TABLE IF EXISTS announcements;
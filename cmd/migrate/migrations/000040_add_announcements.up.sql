@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS announcements(
+    id bigserial PRIMARY KEY,
+    title varchar(200) NOT NULL,
+    body text NOT NULL DEFAULT '',
+    severity varchar(20) NOT NULL DEFAULT 'info',
+    starts_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    ends_at timestamp(0) with time zone,
+    created_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_announcements_window ON announcements (starts_at, ends_at);
+
+CREATE TABLE IF NOT EXISTS announcement_dismissals(
+    announcement_id bigint NOT NULL REFERENCES announcements(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dismissed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (announcement_id, user_id)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE users DROP COLUMN IF EXISTS accepted_terms_id;
+DROP TABLE IF EXISTS terms_acceptances;
+DROP TABLE IF EXISTS terms_versions;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS terms_versions(
+    id bigserial PRIMARY KEY,
+    kind varchar(20) NOT NULL,
+    version varchar(50) NOT NULL,
+    url text NOT NULL,
+    summary text NOT NULL DEFAULT '',
+    published_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    published_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (kind, version)
+);
+
+CREATE TABLE IF NOT EXISTS terms_acceptances(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    terms_id bigint NOT NULL REFERENCES terms_versions(id) ON DELETE CASCADE,
+    accepted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, terms_id)
+);
+
+ALTER TABLE users ADD COLUMN accepted_terms_id bigint NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE posts DROP COLUMN IF EXISTS age_restricted;
+ALTER TABLE users DROP COLUMN IF EXISTS birthdate;
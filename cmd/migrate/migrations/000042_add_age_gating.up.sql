@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN birthdate date;
+ALTER TABLE posts ADD COLUMN age_restricted boolean NOT NULL DEFAULT false;
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN IF EXISTS merged_into;
+DROP TABLE IF EXISTS account_merges;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS account_merges(
+    id bigserial PRIMARY KEY,
+    source_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    target_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    source_username varchar(255) NOT NULL,
+    merged_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    reason text NOT NULL DEFAULT '',
+    posts int NOT NULL DEFAULT 0,
+    comments int NOT NULL DEFAULT 0,
+    follows int NOT NULL DEFAULT 0,
+    reactions int NOT NULL DEFAULT 0,
+    bookmarks int NOT NULL DEFAULT 0,
+    duplicates int NOT NULL DEFAULT 0,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE users ADD COLUMN merged_into bigint REFERENCES users(id) ON DELETE SET NULL;
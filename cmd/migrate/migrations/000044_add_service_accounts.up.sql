@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN is_bot boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS api_keys(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name varchar(100) NOT NULL DEFAULT '',
+    prefix varchar(20) NOT NULL,
+    key_hash varchar(64) NOT NULL UNIQUE,
+    created_by bigint REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_used_at timestamp(0) with time zone,
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys (user_id);
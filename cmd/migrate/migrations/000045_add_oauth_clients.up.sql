@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS oauth_clients(
+    id bigserial PRIMARY KEY,
+    client_id varchar(64) NOT NULL UNIQUE,
+    secret_hash varchar(64) NOT NULL,
+    name varchar(100) NOT NULL,
+    redirect_uris text[] NOT NULL,
+    scopes varchar(20)[] NOT NULL,
+    owner_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_owner ON oauth_clients (owner_id);
+
+CREATE TABLE IF NOT EXISTS oauth_codes(
+    code_hash varchar(64) PRIMARY KEY,
+    client_id bigint NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri text NOT NULL,
+    scopes varchar(20)[] NOT NULL,
+    code_challenge varchar(128) NOT NULL DEFAULT '',
+    expires_at timestamp(0) with time zone NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE api_keys ADD COLUMN scopes varchar(30)[];
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS push_devices(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform varchar(10) NOT NULL,
+    token varchar(512) NOT NULL UNIQUE,
+    device_name varchar(100) NOT NULL DEFAULT '',
+    app_version varchar(30) NOT NULL DEFAULT '',
+    os_version varchar(30) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_push_devices_user ON push_devices (user_id);
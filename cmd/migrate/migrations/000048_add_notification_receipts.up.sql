@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS notification_receipts(
+    notification_id bigint NOT NULL REFERENCES notifications(id) ON DELETE CASCADE,
+    channel varchar(10) NOT NULL,
+    attempted_at timestamp(0) with time zone,
+    delivered_at timestamp(0) with time zone,
+    seen_at timestamp(0) with time zone,
+    PRIMARY KEY (notification_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_unread_created ON notifications (created_at) WHERE read_at IS NULL;
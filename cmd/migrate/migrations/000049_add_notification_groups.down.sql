@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_notifications_open_group;
+
+ALTER TABLE notifications
+    DROP COLUMN IF EXISTS group_key,
+    DROP COLUMN IF EXISTS event_count,
+    DROP COLUMN IF EXISTS actor_count,
+    DROP COLUMN IF EXISTS recent_actor_ids;
@@ -0,0 +1,9 @@
+ALTER TABLE notifications
+    ADD COLUMN group_key varchar(60),
+    ADD COLUMN event_count int NOT NULL DEFAULT 1,
+    ADD COLUMN actor_count int NOT NULL DEFAULT 1,
+    ADD COLUMN recent_actor_ids bigint[] NOT NULL DEFAULT '{}';
+
+UPDATE notifications SET recent_actor_ids = ARRAY[actor_id];
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_open_group ON notifications (user_id, group_key) WHERE read_at IS NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_post_retention;
+ALTER TABLE users DROP COLUMN IF EXISTS post_retention_months;
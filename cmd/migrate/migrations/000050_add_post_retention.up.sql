@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN post_retention_months smallint;
+
+CREATE INDEX IF NOT EXISTS idx_users_post_retention ON users (id) WHERE post_retention_months IS NOT NULL;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS archived_posts(
+    post_id bigint PRIMARY KEY,
+    -- No foreign key, the archive job drops archives of deleted users
+    -- together with their blobs.
+    user_id bigint NOT NULL,
+    blob_key varchar(255) NOT NULL,
+    post_created_at timestamp(0) with time zone NOT NULL,
+    archived_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_posts_user ON archived_posts (user_id);
@@ -0,0 +1,17 @@
+ALTER TABLE post_impressions RENAME TO post_impressions_partitioned;
+ALTER INDEX post_impressions_pkey RENAME TO post_impressions_partitioned_pkey;
+ALTER INDEX idx_post_impressions_post_created RENAME TO idx_post_impressions_partitioned;
+
+CREATE TABLE post_impressions(
+    id bigint PRIMARY KEY DEFAULT nextval('post_impressions_id_seq'),
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    viewer_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    source varchar(20) NOT NULL DEFAULT 'direct',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO post_impressions SELECT * FROM post_impressions_partitioned;
+ALTER SEQUENCE post_impressions_id_seq OWNED BY post_impressions.id;
+DROP TABLE post_impressions_partitioned;
+
+CREATE INDEX IF NOT EXISTS idx_post_impressions_post_created ON post_impressions (post_id, created_at);
@@ -0,0 +1,43 @@
+-- Impressions are append-only and only read by time range, so they are split
+-- into monthly partitions, created ahead of time and dropped past retention by
+-- the partitions job. posts and notifications stay whole: other tables hold
+-- foreign keys to their ids and grouping needs a unique (user_id, group_key),
+-- while unique keys on a partitioned table must include the partition column.
+ALTER TABLE post_impressions RENAME TO post_impressions_unpartitioned;
+ALTER INDEX post_impressions_pkey RENAME TO post_impressions_unpartitioned_pkey;
+ALTER INDEX idx_post_impressions_post_created RENAME TO idx_post_impressions_unpartitioned;
+
+CREATE TABLE post_impressions(
+    id bigint NOT NULL DEFAULT nextval('post_impressions_id_seq'),
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    viewer_id bigint REFERENCES users(id) ON DELETE SET NULL,
+    source varchar(20) NOT NULL DEFAULT 'direct',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, created_at)
+) PARTITION BY RANGE (created_at);
+
+-- One partition per month from the oldest impression to three months ahead,
+-- named post_impressions_pYYYYMM with UTC bounds.
+DO $$
+DECLARE
+    month date := date_trunc('month', COALESCE((SELECT min(created_at) FROM post_impressions_unpartitioned), NOW()) AT TIME ZONE 'UTC')::date;
+BEGIN
+    WHILE month <= (date_trunc('month', NOW() AT TIME ZONE 'UTC') + interval '3 months')::date LOOP
+        EXECUTE format(
+            'CREATE TABLE %I PARTITION OF post_impressions FOR VALUES FROM (%L) TO (%L)',
+            'post_impressions_p' || to_char(month, 'YYYYMM'),
+            month::text || ' 00:00:00+00',
+            (month + interval '1 month')::date::text || ' 00:00:00+00'
+        );
+        month := (month + interval '1 month')::date;
+    END LOOP;
+END $$;
+
+-- Catches rows for months the job has not created yet.
+CREATE TABLE post_impressions_default PARTITION OF post_impressions DEFAULT;
+
+INSERT INTO post_impressions SELECT * FROM post_impressions_unpartitioned;
+ALTER SEQUENCE post_impressions_id_seq OWNED BY post_impressions.id;
+DROP TABLE post_impressions_unpartitioned;
+
+CREATE INDEX IF NOT EXISTS idx_post_impressions_post_created ON post_impressions (post_id, created_at);
@@ -0,0 +1,5 @@
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts (created_at);
+DROP INDEX IF EXISTS idx_posts_created_id;
+CREATE INDEX IF NOT EXISTS idx_posts_user_id ON posts (user_id);
+DROP INDEX IF EXISTS idx_posts_user_created;
+DROP INDEX IF EXISTS idx_posts_content;
@@ -0,0 +1,12 @@
+-- Text filters match title OR content with ILIKE '%...%', only title had a
+-- trigram index so the OR fell back to a sequential scan of posts.
+CREATE INDEX IF NOT EXISTS idx_posts_content ON posts USING gin (content gin_trgm_ops);
+
+-- The home feed reads the newest posts of each followed user, this replaces
+-- the plain user_id index it had to sort on top of.
+CREATE INDEX IF NOT EXISTS idx_posts_user_created ON posts (user_id, created_at DESC);
+DROP INDEX IF EXISTS idx_posts_user_id;
+
+-- Search orders by (created_at, id) to keep pages stable on equal times.
+CREATE INDEX IF NOT EXISTS idx_posts_created_id ON posts (created_at DESC, id DESC);
+DROP INDEX IF EXISTS idx_posts_created_at;
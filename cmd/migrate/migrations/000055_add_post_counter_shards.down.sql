@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS post_counter_shards;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS views_count;
@@ -0,0 +1,16 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS views_count bigint NOT NULL DEFAULT 0;
+
+UPDATE posts p
+SET views_count = v.total
+FROM (SELECT post_id, COUNT(*) AS total FROM post_impressions GROUP BY post_id) v
+WHERE p.id = v.post_id;
+
+-- Counter updates of hot posts land on one of several rows here instead of
+-- all queuing on the posts row, the compaction job folds them back.
+CREATE TABLE IF NOT EXISTS post_counter_shards(
+    post_id bigint NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    counter varchar(20) NOT NULL,
+    shard smallint NOT NULL,
+    delta bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (post_id, counter, shard)
+);
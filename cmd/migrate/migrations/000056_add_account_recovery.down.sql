@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS recovery_approvals;
+DROP TABLE IF EXISTS recovery_requests;
+DROP TABLE IF EXISTS recovery_contacts;
@@ -0,0 +1,35 @@
+-- Trusted contacts vouch for users locked out of their account, see
+-- RecoveryStore.
+CREATE TABLE IF NOT EXISTS recovery_contacts(
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    contact_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, contact_id),
+    CHECK (user_id <> contact_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_contacts_contact ON recovery_contacts (contact_id);
+
+CREATE TABLE IF NOT EXISTS recovery_requests(
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    approvals int NOT NULL DEFAULT 0,
+    ready_at timestamp(0) with time zone,
+    token_hash varchar(64) UNIQUE,
+    issued_at timestamp(0) with time zone,
+    completed_at timestamp(0) with time zone,
+    cancelled_at timestamp(0) with time zone,
+    expires_at timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_requests_user ON recovery_requests (user_id);
+CREATE INDEX IF NOT EXISTS idx_recovery_requests_ready ON recovery_requests (ready_at)
+    WHERE token_hash IS NULL AND completed_at IS NULL AND cancelled_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS recovery_approvals(
+    request_id bigint NOT NULL REFERENCES recovery_requests(id) ON DELETE CASCADE,
+    contact_id bigint NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (request_id, contact_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS locale, DROP COLUMN IF EXISTS timezone;
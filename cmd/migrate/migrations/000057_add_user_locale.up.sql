@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS locale varchar(10) NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS timezone varchar(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS custom_emoji (
+    shortcode varchar(32) PRIMARY KEY,
+    blob_key text NOT NULL,
+    content_type varchar(50) NOT NULL,
+    created_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
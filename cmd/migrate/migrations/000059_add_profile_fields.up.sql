@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS profile_fields (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    position smallint NOT NULL,
+    name varchar(255) NOT NULL,
+    value varchar(255) NOT NULL,
+    verified_at timestamp(0) with time zone,
+    checked_at timestamp(0) with time zone,
+    PRIMARY KEY (user_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_profile_fields_checked_at ON profile_fields (checked_at NULLS FIRST);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_badges (
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    badge varchar(32) NOT NULL,
+    -- NULL for badges assigned automatically
+    granted_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    granted_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, badge)
+);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS events (
+    post_id bigint PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+    starts_at timestamp(0) with time zone NOT NULL,
+    ends_at timestamp(0) with time zone,
+    location varchar(200) NOT NULL DEFAULT '',
+    online_url varchar(500) NOT NULL DEFAULT '',
+    canceled_at timestamp(0) with time zone,
+    reminded_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_starts_at ON events (starts_at) WHERE canceled_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS event_rsvps (
+    event_id bigint NOT NULL REFERENCES events (post_id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    status varchar(20) NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_rsvps_user_id ON event_rsvps (user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS listings (
+    post_id bigint PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+    category varchar(20) NOT NULL,
+    location varchar(200) NOT NULL DEFAULT '',
+    remote boolean NOT NULL DEFAULT false,
+    compensation varchar(200) NOT NULL DEFAULT '',
+    apply_url varchar(500) NOT NULL DEFAULT '',
+    status varchar(20) NOT NULL DEFAULT 'pending',
+    expires_at timestamp(0) with time zone NOT NULL,
+    reviewed_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    reviewed_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_listings_feed ON listings (category, expires_at) WHERE status = 'approved';
+CREATE INDEX IF NOT EXISTS idx_listings_pending ON listings (post_id) WHERE status = 'pending';
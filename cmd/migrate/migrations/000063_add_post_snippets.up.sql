@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS post_snippets (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    position smallint NOT NULL,
+    language varchar(30) NOT NULL,
+    content text NOT NULL,
+    playground_url varchar(200) NOT NULL DEFAULT '',
+    UNIQUE (post_id, position)
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS post_repo_cards (
+    post_id bigint PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+    owner varchar(39) NOT NULL,
+    name varchar(100) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    stars int NOT NULL DEFAULT 0,
+    language varchar(50) NOT NULL DEFAULT '',
+    url varchar(200) NOT NULL DEFAULT '',
+    fetched_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_repo_cards_fetched_at ON post_repo_cards (fetched_at NULLS FIRST);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_posts_unanswered;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS accepted_answer_id;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS is_answer;
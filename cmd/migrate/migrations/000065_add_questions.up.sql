@@ -0,0 +1,5 @@
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS is_answer boolean NOT NULL DEFAULT false;
+
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS accepted_answer_id bigint REFERENCES comments (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_posts_unanswered ON posts (created_at DESC) WHERE kind = 'question' AND accepted_answer_id IS NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS space_participants;
+
+DROP TABLE IF EXISTS spaces;
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS spaces (
+    post_id bigint PRIMARY KEY REFERENCES posts (id) ON DELETE CASCADE,
+    media varchar(10) NOT NULL,
+    status varchar(20) NOT NULL DEFAULT 'scheduled',
+    scheduled_for timestamp(0) with time zone,
+    started_at timestamp(0) with time zone,
+    ended_at timestamp(0) with time zone,
+    room_id varchar(200) NOT NULL DEFAULT '',
+    room_url varchar(500) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_spaces_status ON spaces (status) WHERE status <> 'ended';
+
+CREATE TABLE IF NOT EXISTS space_participants (
+    space_id bigint NOT NULL REFERENCES spaces (post_id) ON DELETE CASCADE,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role varchar(20) NOT NULL,
+    joined_at timestamp(0) with time zone,
+    PRIMARY KEY (space_id, user_id)
+);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS story_views;
+
+DROP INDEX IF EXISTS idx_posts_expires_at;
+
+ALTER TABLE posts DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,10 @@
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS expires_at timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_posts_expires_at ON posts (expires_at) WHERE expires_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS story_views (
+    story_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    viewer_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    viewed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (story_id, viewer_id)
+);
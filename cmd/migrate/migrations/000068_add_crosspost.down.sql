@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS crosspost_deliveries;
+DROP TABLE IF EXISTS crosspost_accounts;
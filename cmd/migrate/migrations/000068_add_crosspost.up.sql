@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS crosspost_accounts (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    network varchar(20) NOT NULL,
+    server varchar(255) NOT NULL,
+    handle varchar(255) NOT NULL,
+    secret text NOT NULL,
+    enabled boolean NOT NULL DEFAULT true,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, network)
+);
+
+CREATE TABLE IF NOT EXISTS crosspost_deliveries (
+    id bigserial PRIMARY KEY,
+    post_id bigint NOT NULL REFERENCES posts (id) ON DELETE CASCADE,
+    account_id bigint NOT NULL REFERENCES crosspost_accounts (id) ON DELETE CASCADE,
+    status varchar(10) NOT NULL DEFAULT 'pending',
+    attempts int NOT NULL DEFAULT 0,
+    next_attempt_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    remote_url text NOT NULL DEFAULT '',
+    error text NOT NULL DEFAULT '',
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (post_id, account_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_crosspost_deliveries_due ON crosspost_deliveries (next_attempt_at) WHERE status = 'pending';
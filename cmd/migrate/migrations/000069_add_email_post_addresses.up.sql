@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS email_post_addresses (
+    user_id bigint PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    token varchar(64) NOT NULL UNIQUE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS maintenance_windows (
+    id bigserial PRIMARY KEY,
+    paths text[] NOT NULL DEFAULT '{}',
+    writes_only boolean NOT NULL DEFAULT false,
+    message varchar(500) NOT NULL DEFAULT '',
+    ends_at timestamp(0) with time zone,
+    ended_at timestamp(0) with time zone,
+    created_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_maintenance_windows_active ON maintenance_windows (created_at) WHERE ended_at IS NULL;
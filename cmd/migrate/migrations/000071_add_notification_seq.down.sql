@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_notifications_user_seq;
+
+ALTER TABLE notifications DROP COLUMN IF EXISTS seq;
+
+DROP SEQUENCE IF EXISTS notifications_seq;
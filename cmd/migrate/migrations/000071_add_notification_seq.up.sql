@@ -0,0 +1,7 @@
+-- seq orders changes to notifications, a collapsed notification takes a new
+-- one, so long polls can ask for everything after the last seq they saw.
+CREATE SEQUENCE IF NOT EXISTS notifications_seq;
+
+ALTER TABLE notifications ADD COLUMN IF NOT EXISTS seq bigint NOT NULL DEFAULT nextval('notifications_seq');
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user_seq ON notifications (user_id, seq);
@@ -0,0 +1,9 @@
+-- Posts removed by moderators leave a tombstone behind so permalinks and
+-- comment threads can still say what happened to them.
+CREATE TABLE IF NOT EXISTS post_tombstones (
+    post_id bigint PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reason varchar(500) NOT NULL DEFAULT '',
+    case_id bigint REFERENCES moderation_cases (id) ON DELETE SET NULL,
+    removed_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
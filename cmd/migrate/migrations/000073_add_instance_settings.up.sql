@@ -0,0 +1,13 @@
+-- instance_settings holds the single row describing this instance to clients
+-- and federation peers.
+CREATE TABLE IF NOT EXISTS instance_settings (
+    id boolean PRIMARY KEY DEFAULT true CHECK (id),
+    name varchar(100) NOT NULL DEFAULT 'GopherSocial',
+    description varchar(2000) NOT NULL DEFAULT '',
+    rules text[] NOT NULL DEFAULT '{}',
+    contact_email citext NOT NULL DEFAULT '',
+    updated_by bigint REFERENCES users (id) ON DELETE SET NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO instance_settings DEFAULT VALUES ON CONFLICT DO NOTHING;
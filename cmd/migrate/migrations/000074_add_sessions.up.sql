@@ -0,0 +1,15 @@
+-- Every login is recorded as a session, the tokens issued for it carry its id
+-- and stop working once the session is revoked.
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device varchar(100) NOT NULL DEFAULT '',
+    ip varchar(45) NOT NULL DEFAULT '',
+    user_agent varchar(500) NOT NULL DEFAULT '',
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    expires_at timestamp(0) with time zone NOT NULL,
+    revoked_at timestamp(0) with time zone
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_active ON sessions (user_id, last_seen_at) WHERE revoked_at IS NULL;
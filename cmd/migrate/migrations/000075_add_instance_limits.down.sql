@@ -0,0 +1,6 @@
+ALTER TABLE instance_settings
+    DROP COLUMN IF EXISTS max_post_title_length,
+    DROP COLUMN IF EXISTS max_post_length,
+    DROP COLUMN IF EXISTS max_article_body_length,
+    DROP COLUMN IF EXISTS max_comment_length,
+    DROP COLUMN IF EXISTS max_upload_bytes;
@@ -0,0 +1,7 @@
+ALTER TABLE instance_settings
+    ADD COLUMN IF NOT EXISTS max_post_title_length int NOT NULL DEFAULT 100,
+    ADD COLUMN IF NOT EXISTS max_post_length int NOT NULL DEFAULT 1000,
+    ADD COLUMN IF NOT EXISTS max_article_body_length int NOT NULL DEFAULT 100000,
+    ADD COLUMN IF NOT EXISTS max_comment_length int NOT NULL DEFAULT 1000,
+    -- 0 defers to the server's configured maximum.
+    ADD COLUMN IF NOT EXISTS max_upload_bytes bigint NOT NULL DEFAULT 0;
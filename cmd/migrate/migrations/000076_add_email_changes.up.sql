@@ -0,0 +1,11 @@
+-- A requested email change waits here until the new address confirms it,
+-- token is the SHA-256 of the link sent there like user_invitations.
+CREATE TABLE IF NOT EXISTS email_changes (
+    token bytea PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    new_email citext NOT NULL,
+    expiry timestamp(0) with time zone NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_changes_user ON email_changes (user_id);
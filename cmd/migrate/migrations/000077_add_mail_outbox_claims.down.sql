@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_mail_outbox_sent;
+
+ALTER TABLE mail_outbox DROP COLUMN IF EXISTS claimed_until;
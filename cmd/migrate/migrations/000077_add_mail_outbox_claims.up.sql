@@ -0,0 +1,3 @@
+ALTER TABLE mail_outbox ADD COLUMN IF NOT EXISTS claimed_until timestamp(0) with time zone;
+
+CREATE INDEX IF NOT EXISTS idx_mail_outbox_sent ON mail_outbox (sent_at) WHERE sent_at IS NOT NULL;
@@ -0,0 +1,12 @@
+-- A user's standing authorization of an app, recorded when the app first
+-- exchanges a code. Delegated tokens carry the grant id and stop working
+-- once the user revokes it.
+CREATE TABLE IF NOT EXISTS oauth_grants (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    client_id bigint NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+    scopes varchar(20)[] NOT NULL,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, client_id)
+);
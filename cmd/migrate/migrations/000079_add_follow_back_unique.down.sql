@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_notifications_follow_back;
@@ -0,0 +1,8 @@
+-- Replicas running the suggestion job at once could suggest a pair twice,
+-- keep the oldest and let the index turn later attempts into no-ops.
+DELETE FROM notifications n
+USING notifications d
+WHERE n.type = 'follow_back' AND d.type = 'follow_back'
+    AND n.user_id = d.user_id AND n.actor_id = d.actor_id AND n.id > d.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_notifications_follow_back ON notifications (user_id, actor_id) WHERE type = 'follow_back';
@@ -0,0 +1,7 @@
+-- The last UTC day each analytics aggregation rolled up, so runs missed to a
+-- restart are backfilled.
+CREATE TABLE IF NOT EXISTS analytics_runs (
+    name text PRIMARY KEY,
+    day date NOT NULL,
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW()
+);
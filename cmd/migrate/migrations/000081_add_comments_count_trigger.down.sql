@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS comments_count ON comments;
+DROP FUNCTION IF EXISTS count_post_comments();
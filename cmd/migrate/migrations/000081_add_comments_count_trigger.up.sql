@@ -0,0 +1,22 @@
+-- Maintain posts.comments_count in the database so every way a comment is
+-- added, removed or hidden moves it, not only the API's insert. Hidden
+-- comments don't count, like they aren't listed.
+CREATE OR REPLACE FUNCTION count_post_comments() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.hidden_at IS NULL THEN
+        UPDATE posts SET comments_count = GREATEST(comments_count - 1, 0) WHERE id = OLD.post_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.hidden_at IS NULL THEN
+        UPDATE posts SET comments_count = comments_count + 1 WHERE id = NEW.post_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS comments_count ON comments;
+CREATE TRIGGER comments_count
+AFTER INSERT OR DELETE OR UPDATE OF post_id, hidden_at ON comments
+FOR EACH ROW EXECUTE FUNCTION count_post_comments();
+
+UPDATE posts p
+SET comments_count = (SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id AND c.hidden_at IS NULL);
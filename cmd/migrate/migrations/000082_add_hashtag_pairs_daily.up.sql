@@ -0,0 +1,15 @@
+-- Tag co-occurrence per UTC day, so refreshing the related tags only counts
+-- the recent posts and sums the days of the lookback.
+CREATE TABLE IF NOT EXISTS hashtag_pairs_daily (
+    day date NOT NULL,
+    tag varchar(100) NOT NULL,
+    related varchar(100) NOT NULL,
+    posts int NOT NULL,
+    PRIMARY KEY (day, tag, related)
+);
+
+INSERT INTO hashtag_pairs_daily (day, tag, related, posts)
+SELECT (p.created_at AT TIME ZONE 'UTC')::date, a.tag, b.tag, COUNT(*)
+FROM posts p, unnest(p.tags) AS a(tag), unnest(p.tags) AS b(tag)
+WHERE p.created_at >= NOW() - interval '30 days' AND a.tag <> b.tag
+GROUP BY 1, 2, 3;
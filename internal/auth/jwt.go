@@ -2,27 +2,39 @@ package auth
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// legacyKeyID is used for the shared HMAC secret so tokens issued before key
+// sets existed (which carry no kid header) keep validating.
+const legacyKeyID = "default"
+
 type JWTAuthenticator struct {
-	secret string
-	aud    string
-	iss    string
+	keys *KeySet
+	aud  string
+	iss  string
 }
 
 func NewJWTAuthenticator(secret, aud, iss string) *JWTAuthenticator {
+	keys, _ := NewKeySet(legacyKeyID, NewHMACKey(legacyKeyID, secret))
+	return NewJWTAuthenticatorWithKeys(keys, aud, iss)
+}
+
+func NewJWTAuthenticatorWithKeys(keys *KeySet, aud, iss string) *JWTAuthenticator {
 	return &JWTAuthenticator{
-		secret: secret,
-		aud:    aud,
-		iss:    iss,
+		keys: keys,
+		aud:  aud,
+		iss:  iss,
 	}
 }
 
 func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(a.secret))
+	key := a.keys.signingKey()
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -30,15 +42,31 @@ func (a *JWTAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 }
 func (a *JWTAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			kid = legacyKeyID
+		}
+		var issuedAt time.Time
+		if iat, err := t.Claims.GetIssuedAt(); err == nil && iat != nil {
+			issuedAt = iat.Time
+		}
+		key, err := a.keys.lookup(kid, issuedAt, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
-		return []byte(a.secret), nil
+		return key.verifyKey, nil
 	},
 		jwt.WithAudience(a.aud),
 		jwt.WithExpirationRequired(),
 		jwt.WithIssuer(a.iss),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithValidMethods(a.keys.methods()),
 	)
 
 }
+
+func (a *JWTAuthenticator) JWKS() JWKS {
+	return a.keys.JWKS()
+}
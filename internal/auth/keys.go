@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyID     = errors.New("unknown signing key id")
+	ErrNoSigningKey     = errors.New("no active signing key")
+	ErrUnsupportedKey   = errors.New("unsupported key type")
+	ErrMalformedKeySpec = errors.New("malformed key spec, expected kid:path")
+	ErrRetiredKey       = errors.New("signing key retired")
+	ErrRetireActive     = errors.New("the active signing key can't be retired")
+	ErrMalformedRetired = errors.New("malformed retired key, expected kid@RFC3339 time")
+)
+
+// Key is a single named signing/verification key. HMAC keys use the same
+// secret for both, asymmetric keys only expose their public half via JWKS.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	// retiredAt is when a retired key stopped signing, zero for keys in
+	// use. It validates tokens signed before then until validUntil.
+	retiredAt  time.Time
+	validUntil time.Time
+}
+
+func NewHMACKey(id, secret string) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}
+}
+
+// NewKeyFromPEM parses an RSA or Ed25519 private key. The algorithm is
+// inferred from the key type: RSA keys sign with RS256, Ed25519 with EdDSA.
+func NewKeyFromPEM(id string, data []byte) (*Key, error) {
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(data); err == nil {
+		return &Key{
+			ID:        id,
+			Method:    jwt.SigningMethodRS256,
+			signKey:   rsaKey,
+			verifyKey: &rsaKey.PublicKey,
+		}, nil
+	}
+	edKey, err := jwt.ParseEdPrivateKeyFromPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, ErrUnsupportedKey)
+	}
+	priv, ok := edKey.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key %s: %w", id, ErrUnsupportedKey)
+	}
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodEdDSA,
+		signKey:   priv,
+		verifyKey: priv.Public(),
+	}, nil
+}
+
+// KeySet holds every key that is accepted for validation plus the ID of the
+// one used for signing new tokens. Rotating means adding a new key, making
+// it active and retiring the old one, which keeps validating until issued
+// tokens expire.
+type KeySet struct {
+	active string
+	keys   map[string]*Key
+}
+
+func NewKeySet(active string, keys ...*Key) (*KeySet, error) {
+	ks := &KeySet{
+		active: active,
+		keys:   make(map[string]*Key, len(keys)),
+	}
+	for _, k := range keys {
+		ks.keys[k.ID] = k
+	}
+	if _, ok := ks.keys[active]; !ok {
+		return nil, ErrNoSigningKey
+	}
+	return ks, nil
+}
+
+// LoadKeySet builds a key set from "kid:path" specs pointing at PEM files.
+func LoadKeySet(active string, specs []string) (*KeySet, error) {
+	keys := make([]*Key, 0, len(specs))
+	for _, spec := range specs {
+		kid, path, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok || kid == "" || path == "" {
+			return nil, ErrMalformedKeySpec
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := NewKeyFromPEM(kid, data)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(active, keys...)
+}
+
+// Retire marks kid as no longer signing since at. Tokens it signed before
+// then keep validating for grace, usually the token lifetime, after which
+// the key is dropped from validation and the JWKS.
+func (ks *KeySet) Retire(kid string, at time.Time, grace time.Duration) error {
+	if kid == ks.active {
+		return ErrRetireActive
+	}
+	key, ok := ks.keys[kid]
+	if !ok {
+		return fmt.Errorf("%s: %w", kid, ErrUnknownKeyID)
+	}
+	key.retiredAt, key.validUntil = at, at.Add(grace)
+	return nil
+}
+
+// RetireFromSpecs retires the keys of "kid@time" specs, time in RFC3339.
+// The legacy kid "default" stands for the shared HMAC secret, naming it
+// keeps tokens issued before the key set was configured valid for grace.
+func (ks *KeySet) RetireFromSpecs(specs []string, grace time.Duration, legacySecret string) error {
+	for _, spec := range specs {
+		kid, at, ok := strings.Cut(strings.TrimSpace(spec), "@")
+		retiredAt, err := time.Parse(time.RFC3339, at)
+		if !ok || kid == "" || err != nil {
+			return ErrMalformedRetired
+		}
+		if _, ok := ks.keys[kid]; !ok && kid == legacyKeyID && legacySecret != "" {
+			ks.keys[kid] = NewHMACKey(kid, legacySecret)
+		}
+		if err := ks.Retire(kid, retiredAt, grace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ks *KeySet) signingKey() *Key {
+	return ks.keys[ks.active]
+}
+
+// lookup returns the key to validate a token of kid issued at issuedAt,
+// zero when the token has no iat.
+func (ks *KeySet) lookup(kid string, issuedAt time.Time, now time.Time) (*Key, error) {
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if key.retired(now) || !key.retiredAt.IsZero() && issuedAt.After(key.retiredAt) {
+		return nil, fmt.Errorf("%s: %w", kid, ErrRetiredKey)
+	}
+	return key, nil
+}
+
+// retired reports whether the key's grace period is over.
+func (k *Key) retired(now time.Time) bool {
+	return !k.validUntil.IsZero() && now.After(k.validUntil)
+}
+
+func (ks *KeySet) methods() []string {
+	seen := map[string]bool{}
+	var methods []string
+	for _, k := range ks.keys {
+		alg := k.Method.Alg()
+		if !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWK is the public representation of a key as defined in RFC 7517.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, retired ones until their grace
+// period is over. Symmetric keys are never exposed.
+func (ks *KeySet) JWKS() JWKS {
+	now := time.Now()
+	ids := make([]string, 0, len(ks.keys))
+	for id, k := range ks.keys {
+		if !k.retired(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		k := ks.keys[id]
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: k.ID,
+				Alg: k.Method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: k.ID,
+				Alg: k.Method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
@@ -27,3 +27,6 @@ func (a *TestAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 		return []byte(secret), nil
 	})
 }
+func (a *TestAuthenticator) JWKS() JWKS {
+	return JWKS{Keys: []JWK{}}
+}
@@ -0,0 +1,18 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// Store persists opaque media blobs by key. The local filesystem
+// implementation is used in development; object storage backends only need
+// to satisfy the same interface.
+type Store interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
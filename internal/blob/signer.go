@@ -0,0 +1,117 @@
+package blob
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid media signature")
+	ErrExpiredSignature = errors.New("media link has expired")
+)
+
+// Signer creates and verifies HMAC signed, expiring links to blobs so that
+// private media can be handed to a client without being hotlinkable.
+type Signer struct {
+	secret  []byte
+	baseURL string
+	exp     time.Duration
+}
+
+func NewSigner(secret, baseURL string, exp time.Duration) *Signer {
+	return &Signer{
+		secret:  []byte(secret),
+		baseURL: baseURL,
+		exp:     exp,
+	}
+}
+
+func (s *Signer) sign(key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s:%d", key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignURL returns a link to the blob valid for the signer's expiry window.
+// Keys are generated server side and are already URL safe.
+func (s *Signer) SignURL(key string) (string, time.Time) {
+	expiresAt := time.Now().Add(s.exp)
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("sig", s.sign(key, expiresAt.Unix()))
+	return fmt.Sprintf("%s/%s?%s", s.baseURL, key, q.Encode()), expiresAt
+}
+
+// Link returns the unsigned link to a blob, for content that outlives the
+// expiry window. SignLinks turns it into a working one when it is served.
+func (s *Signer) Link(key string) string {
+	return s.baseURL + "/" + key
+}
+
+// SignLinks signs the links in text to blobs whose keys start with prefix,
+// replacing the signature of links signed before. Links to other keys are
+// left as they are, so content can't mint lasting links to someone else's
+// blobs.
+func (s *Signer) SignLinks(text, prefix string) string {
+	marker := s.baseURL + "/"
+	var b strings.Builder
+	for {
+		i := strings.Index(text, marker)
+		if i < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		text = text[i+len(marker):]
+		key := text[:linkEnd(text, "-_./")]
+		text = text[len(key):]
+		if key == "" || !strings.HasPrefix(key, prefix) {
+			b.WriteString(marker + key)
+			continue
+		}
+		if strings.HasPrefix(text, "?") {
+			text = text[1+linkEnd(text[1:], "=&"):]
+		}
+		url, _ := s.SignURL(key)
+		b.WriteString(url)
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// linkEnd returns the length of the run of letters, digits and extra at the
+// start of s.
+func linkEnd(s, extra string) int {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(extra, r))
+	})
+	if end < 0 {
+		return len(s)
+	}
+	return end
+}
+
+func (s *Signer) Verify(key, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(s.sign(key, exp))
+	if err != nil {
+		return err
+	}
+	given, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(expected, given) {
+		return ErrInvalidSignature
+	}
+	if time.Now().Unix() > exp {
+		return ErrExpiredSignature
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrOpen = errors.New("circuit breaker is open")
+
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// Breaker stops calling a failing dependency. After threshold consecutive
+// failures it opens and rejects calls with ErrOpen for the cooldown, then
+// lets a single trial call through: success closes it again, failure
+// reopens it for another cooldown.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+}
+
+func New(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     StateClosed,
+	}
+}
+
+// Do runs fn unless the breaker is open and records its outcome.
+func (b *Breaker) Do(fn func() error) error {
+	if !b.allow() {
+		return ErrOpen
+	}
+	err := fn()
+	b.record(err)
+	return err
+}
+
+func (b *Breaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		return true
+	case StateHalfOpen:
+		// a trial call is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
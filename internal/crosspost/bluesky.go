@@ -0,0 +1,105 @@
+package crosspost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// blueskyLimit is the post length in graphemes, counted as runes here which
+// is never more.
+const blueskyLimit = 300
+
+// Bluesky creates posts through the AT Protocol on the account's PDS,
+// https://bsky.social for most, logging in with an app password.
+type Bluesky struct {
+	client *http.Client
+}
+
+func NewBluesky(client *http.Client) *Bluesky {
+	return &Bluesky{client: client}
+}
+
+type blueskySession struct {
+	AccessJwt string `json:"accessJwt"`
+	DID       string `json:"did"`
+	Handle    string `json:"handle"`
+}
+
+func (b *Bluesky) Verify(ctx context.Context, acct Account) (string, error) {
+	session, err := b.login(ctx, acct)
+	if err != nil {
+		return "", err
+	}
+	return session.Handle, nil
+}
+
+func (b *Bluesky) Publish(ctx context.Context, acct Account, p Post) (string, error) {
+	session, err := b.login(ctx, acct)
+	if err != nil {
+		return "", err
+	}
+	text := Text(Post{Title: p.Title, Content: p.Content, Link: p.Link}, blueskyLimit)
+	if p.ContentWarning != "" {
+		text = Text(Post{Title: "CW: " + p.ContentWarning, Content: p.Title + "\n\n" + p.Content, Link: p.Link}, blueskyLimit)
+	}
+	record := map[string]any{
+		"repo":       session.DID,
+		"collection": "app.bsky.feed.post",
+		"record": map[string]any{
+			"$type":     "app.bsky.feed.post",
+			"text":      text,
+			"createdAt": time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+	var created struct {
+		URI string `json:"uri"`
+	}
+	if err := b.call(ctx, acct.Server, "com.atproto.repo.createRecord", session.AccessJwt, record, &created); err != nil {
+		return "", err
+	}
+	// at://did/app.bsky.feed.post/rkey opens at bsky.app/profile/handle/post/rkey.
+	rkey := created.URI[strings.LastIndex(created.URI, "/")+1:]
+	return "https://bsky.app/profile/" + session.Handle + "/post/" + rkey, nil
+}
+
+func (b *Bluesky) login(ctx context.Context, acct Account) (*blueskySession, error) {
+	var session blueskySession
+	creds := map[string]string{"identifier": acct.Handle, "password": acct.Secret}
+	if err := b.call(ctx, acct.Server, "com.atproto.server.createSession", "", creds, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (b *Bluesky) call(ctx context.Context, server, method, token string, in, out any) error {
+	server, err := serverURL(server)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/xrpc/"+method, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	// A wrong app password answers 401 AuthenticationRequired.
+	if res.StatusCode != http.StatusOK {
+		return statusError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,130 @@
+// Package crosspost mirrors posts to the users' accounts on other networks.
+// Each network has an adapter implementing Publisher.
+package crosspost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// Networks posts can be mirrored to.
+const (
+	NetworkMastodon = "mastodon"
+	NetworkBluesky  = "bluesky"
+)
+
+// ErrUnauthorized means the network rejected the account's credentials, the
+// user has to connect it again.
+var ErrUnauthorized = errors.New("crosspost: credentials rejected")
+
+var errPrivateAddress = errors.New("crosspost: refusing to connect to a private address")
+
+// Account is a user's account on a network. Server is the Mastodon instance
+// or the Bluesky PDS, Secret the access token or app password.
+type Account struct {
+	Server string
+	Handle string
+	Secret string
+}
+
+// Post is what gets mirrored. Link points back to the original.
+type Post struct {
+	Title          string
+	Content        string
+	ContentWarning string
+	Link           string
+	// Key is stable across retries of the same delivery, networks that
+	// support it drop the duplicate.
+	Key string
+}
+
+type Publisher interface {
+	// Verify checks the account's credentials and returns its canonical
+	// handle.
+	Verify(ctx context.Context, acct Account) (string, error)
+	// Publish posts p and returns the URL of the mirrored post.
+	Publish(ctx context.Context, acct Account, p Post) (string, error)
+}
+
+// Router picks the publisher of a network.
+type Router map[string]Publisher
+
+func (r Router) Publisher(network string) (Publisher, error) {
+	p, ok := r[network]
+	if !ok {
+		return nil, fmt.Errorf("crosspost: no publisher for network %q", network)
+	}
+	return p, nil
+}
+
+// Text fits the post into limit characters: the title and content, cut
+// short with an ellipsis if needed, followed by the link back.
+func Text(p Post, limit int) string {
+	body := strings.TrimSpace(p.Content)
+	if p.Title != "" {
+		body = p.Title + "\n\n" + body
+	}
+	suffix := ""
+	if p.Link != "" {
+		suffix = "\n\n" + p.Link
+	}
+	room := limit - utf8.RuneCountInString(suffix)
+	if utf8.RuneCountInString(body) > room {
+		runes := []rune(body)
+		body = strings.TrimSpace(string(runes[:max(room-1, 0)])) + "…"
+	}
+	return body + suffix
+}
+
+// NewClient returns the HTTP client adapters talk to servers with. Users
+// pick their server, so unless allowPrivate is set only public addresses
+// are reached, the server can't be made to probe its own network.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = publicOnly
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+}
+
+func publicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// serverURL checks a user supplied server and returns it without a trailing
+// slash.
+func serverURL(server string) (string, error) {
+	u, err := url.Parse(server)
+	if err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return "", fmt.Errorf("crosspost: invalid server %q", server)
+	}
+	return strings.TrimSuffix(u.Scheme+"://"+u.Host+u.Path, "/"), nil
+}
+
+// statusError turns a failed answer into an error, ErrUnauthorized for
+// rejected credentials.
+func statusError(res *http.Response) error {
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden {
+		return ErrUnauthorized
+	}
+	return fmt.Errorf("crosspost: %s %s: %s", res.Request.Method, res.Request.URL.Host, res.Status)
+}
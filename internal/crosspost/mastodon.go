@@ -0,0 +1,75 @@
+package crosspost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mastodonLimit is the default status length, instances may allow more.
+const mastodonLimit = 500
+
+// Mastodon posts statuses with an access token of the user's account that
+// has the write:statuses scope.
+type Mastodon struct {
+	client *http.Client
+}
+
+func NewMastodon(client *http.Client) *Mastodon {
+	return &Mastodon{client: client}
+}
+
+func (m *Mastodon) Verify(ctx context.Context, acct Account) (string, error) {
+	var account struct {
+		Acct string `json:"acct"`
+	}
+	if err := m.do(ctx, acct, http.MethodGet, "/api/v1/accounts/verify_credentials", nil, "", &account); err != nil {
+		return "", err
+	}
+	return account.Acct, nil
+}
+
+func (m *Mastodon) Publish(ctx context.Context, acct Account, p Post) (string, error) {
+	form := url.Values{}
+	form.Set("status", Text(Post{Title: p.Title, Content: p.Content, Link: p.Link}, mastodonLimit))
+	form.Set("visibility", "public")
+	if p.ContentWarning != "" {
+		form.Set("spoiler_text", p.ContentWarning)
+	}
+	var status struct {
+		URL string `json:"url"`
+	}
+	if err := m.do(ctx, acct, http.MethodPost, "/api/v1/statuses", form, p.Key, &status); err != nil {
+		return "", err
+	}
+	return status.URL, nil
+}
+
+func (m *Mastodon) do(ctx context.Context, acct Account, method, path string, form url.Values, key string, dst any) error {
+	server, err := serverURL(acct.Server)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, server+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+acct.Secret)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return statusError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(dst)
+}
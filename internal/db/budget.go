@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gopher_social/internal/store"
+	"sync/atomic"
+)
+
+// ErrStatementBudget is returned for the statements a request runs past its
+// budget, when the budget is enforced.
+var ErrStatementBudget = errors.New("statement budget exceeded")
+
+type budgetKey struct{}
+
+// StatementCounter counts the statements run with a context, see
+// WithStatementCounter.
+type StatementCounter struct {
+	n       atomic.Int64
+	limit   int64
+	enforce bool
+}
+
+// WithStatementCounter returns a context counting the queries and execs run
+// with it or contexts derived from it. With enforce, statements past limit
+// fail with ErrStatementBudget instead of reaching the database.
+// Preparing, BEGIN and COMMIT are not counted.
+func WithStatementCounter(ctx context.Context, limit int, enforce bool) (context.Context, *StatementCounter) {
+	c := &StatementCounter{limit: int64(limit), enforce: enforce}
+	return context.WithValue(ctx, budgetKey{}, c), c
+}
+
+// Count is the number of statements run so far.
+func (c *StatementCounter) Count() int {
+	return int(c.n.Load())
+}
+
+// Exceeded tells whether more statements ran than the limit allows.
+func (c *StatementCounter) Exceeded() bool {
+	return c.n.Load() > c.limit
+}
+
+func countStatement(ctx context.Context) error {
+	c, ok := ctx.Value(budgetKey{}).(*StatementCounter)
+	if !ok {
+		return nil
+	}
+	if n := c.n.Add(1); c.enforce && n > c.limit {
+		return fmt.Errorf("%w: %d statements, budget %d", ErrStatementBudget, n, c.limit)
+	}
+	return nil
+}
+
+// CountStatements wraps a driver so the statements run through it count
+// against the StatementCounter of their context, and the timestamps they
+// return are in UTC. New wraps every pool.
+func CountStatements(c driver.Connector) driver.Connector {
+	return countingConnector{c}
+}
+
+type countingConnector struct {
+	driver.Connector
+}
+
+func (c countingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &countingConn{conn}, nil
+}
+
+type countingConn struct {
+	driver.Conn
+}
+
+func (c *countingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := countStatement(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := inUTC(q.QueryContext(ctx, query, args))
+	return rows, markTimeout(ctx, err)
+}
+
+func (c *countingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := countStatement(ctx); err != nil {
+		return nil, err
+	}
+	res, err := e.ExecContext(ctx, query, args)
+	return res, markTimeout(ctx, err)
+}
+
+func (c *countingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var (
+		stmt driver.Stmt
+		err  error
+	)
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &countingStmt{stmt}, nil
+}
+
+func (c *countingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *countingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *countingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *countingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+type countingStmt struct {
+	driver.Stmt
+}
+
+func (s *countingStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := countStatement(ctx); err != nil {
+		return nil, err
+	}
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err := inUTC(q.QueryContext(ctx, args))
+		return rows, markTimeout(ctx, err)
+	}
+	return inUTC(s.Stmt.Query(namedValues(args)))
+}
+
+func (s *countingStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := countStatement(ctx); err != nil {
+		return nil, err
+	}
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err := e.ExecContext(ctx, args)
+		return res, markTimeout(ctx, err)
+	}
+	return s.Stmt.Exec(namedValues(args))
+}
+
+// markTimeout wraps the error of a statement whose context ran past its
+// deadline with store.ErrQueryTimeout, so it can be told apart from the
+// deadlines of everything else a request does.
+func markTimeout(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", store.ErrQueryTimeout, err)
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	return values
+}
@@ -3,16 +3,26 @@ package db
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
 )
 
-func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", addr)
+// New opens the connection pool and waits for Postgres to answer, retrying
+// up to connectAttempts times with exponential backoff so the API can start
+// alongside a database that is still booting.
+func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime, maxLifetime string, connectAttempts int) (*sql.DB, error) {
+	connector, err := pq.NewConnector(addr)
 	if err != nil {
 		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	db := sql.OpenDB(CountStatements(connector))
 	db.SetMaxOpenConns(maxOpenConns)
 	db.SetMaxIdleConns(maxIdleConns)
 	duration, err := time.ParseDuration(maxIdleTime)
@@ -20,9 +30,28 @@ func New(addr string, maxOpenConns, maxIdleConns int, maxIdleTime string) (*sql.
 		return nil, err
 	}
 	db.SetConnMaxIdleTime(duration)
-	if err = db.PingContext(ctx); err != nil {
+	lifetime, err := time.ParseDuration(maxLifetime)
+	if err != nil {
 		return nil, err
 	}
+	db.SetConnMaxLifetime(lifetime)
 
-	return db, nil
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		if err = ping(db); err == nil {
+			return db, nil
+		}
+		if attempt >= connectAttempts {
+			db.Close()
+			return nil, fmt.Errorf("database not reachable after %d attempts: %w", attempt, err)
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func ping(db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return db.PingContext(ctx)
 }
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// utcRows hands timestamps back in UTC whatever the session time zone, so
+// every time the store reads has the same location and encodes the same
+// way.
+type utcRows struct {
+	driver.Rows
+}
+
+func (r utcRows) Next(dest []driver.Value) error {
+	if err := r.Rows.Next(dest); err != nil {
+		return err
+	}
+	for i, v := range dest {
+		if t, ok := v.(time.Time); ok {
+			dest[i] = t.UTC()
+		}
+	}
+	return nil
+}
+
+func inUTC(rows driver.Rows, err error) (driver.Rows, error) {
+	if err != nil {
+		return nil, err
+	}
+	return utcRows{rows}, nil
+}
@@ -3,6 +3,7 @@ package env
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 func GetString(key string, fallback string) string {
@@ -35,3 +36,15 @@ func GetBool(key string, fallback bool) bool {
 	}
 	return valAsBool
 }
+
+func GetStrings(key string, fallback []string) []string {
+	val, ok := os.LookupEnv(key)
+	if !ok || val == "" {
+		return fallback
+	}
+	parts := strings.Split(val, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Message is an event on its way through a Broker.
+type Message struct {
+	// ID is unique per event. A publish retried after a lost ack carries the
+	// same ID, so brokers and consumers can drop the duplicate.
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// NewMessage encodes e. The ID is left to whoever publishes the message.
+func NewMessage(e Event, at time.Time) (Message, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Name: e.Name(), OccurredAt: at.UTC(), Data: data}, nil
+}
+
+// Broker carries events to consumers outside the process, such as analytics
+// and ML pipelines. Delivery is at least once: a message is only gone once a
+// consumer acknowledged it.
+type Broker interface {
+	Publish(ctx context.Context, msg Message) error
+	// Consume hands messages to fn until ctx is done. Consumers sharing a
+	// group split the messages between them, every group gets all of them.
+	// A message fn fails on is delivered again.
+	Consume(ctx context.Context, group string, fn func(context.Context, Message) error) error
+	Close() error
+}
+
+// BrokerConfig selects and addresses a broker. Subject prefixes the event
+// names, e.g. "social.events" publishes post.created on
+// social.events.post.created.
+type BrokerConfig struct {
+	Driver  string
+	URL     string
+	Stream  string
+	Subject string
+}
+
+// OpenBroker connects to the broker of cfg.Driver, nil when it is empty.
+// Only "nats" (JetStream) is implemented, Kafka would be another Broker.
+func OpenBroker(ctx context.Context, cfg BrokerConfig) (Broker, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "nats":
+		b, err := NewNATSBroker(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unknown event broker %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,60 @@
+package events
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Publisher is what handlers publish through. Bus implements it in process,
+// adapters to Redis streams or Kafka can implement it later.
+type Publisher interface {
+	Publish(ctx context.Context, e Event)
+}
+
+type subscriber struct {
+	name string
+	fn   func(context.Context, Event) error
+}
+
+// Bus delivers events to the subscribers of their type, in process. Publish
+// runs them one after the other on the caller's goroutine and context, in
+// the order they subscribed. A failing subscriber is logged and does not
+// keep the others from running: the change the event reports is already
+// saved by then, so there is nothing left to fail.
+type Bus struct {
+	logger *zap.SugaredLogger
+	mu     sync.RWMutex
+	subs   map[reflect.Type][]subscriber
+}
+
+func NewBus(logger *zap.SugaredLogger) *Bus {
+	return &Bus{logger: logger, subs: make(map[reflect.Type][]subscriber)}
+}
+
+// Subscribe registers fn for events of type E under name, which identifies
+// the subscriber in logs.
+func Subscribe[E Event](b *Bus, name string, fn func(context.Context, E) error) {
+	t := reflect.TypeFor[E]()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[t] = append(b.subs[t], subscriber{
+		name: name,
+		fn: func(ctx context.Context, e Event) error {
+			return fn(ctx, e.(E))
+		},
+	})
+}
+
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	subs := b.subs[reflect.TypeOf(e)]
+	b.mu.RUnlock()
+	for _, s := range subs {
+		if err := s.fn(ctx, e); err != nil {
+			b.logger.Errorw("event subscriber failed", "event", e.Name(), "subscriber", s.name, "error", err.Error())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package events carries domain events from the handlers that cause them to
+// the subsystems that react to them, so a handler publishes what happened
+// instead of calling every interested subsystem itself.
+package events
+
+import "gopher_social/internal/store"
+
+// Event is a domain event. Name identifies its type in logs and, for
+// adapters to external brokers, on the wire, where the event is its JSON.
+type Event interface {
+	Name() string
+}
+
+// PostCreated is published once a post was saved.
+type PostCreated struct {
+	Post *store.Post `json:"post"`
+}
+
+func (PostCreated) Name() string { return "post.created" }
+
+// CommentCreated is published once a comment was saved. Parent is the
+// comment it replies to, nil for a top level comment.
+type CommentCreated struct {
+	Post    *store.Post    `json:"post"`
+	Comment *store.Comment `json:"comment"`
+	Parent  *store.Comment `json:"parent,omitempty"`
+}
+
+func (CommentCreated) Name() string { return "comment.created" }
+
+// AnswerAccepted is published once the author of a question accepted an
+// answer. It is the hook for rewarding whoever answered.
+type AnswerAccepted struct {
+	Question *store.Post    `json:"question"`
+	Answer   *store.Comment `json:"answer"`
+}
+
+func (AnswerAccepted) Name() string { return "answer.accepted" }
+
+// SpaceStarted is published once a space went live.
+type SpaceStarted struct {
+	Space *store.Space `json:"space"`
+}
+
+func (SpaceStarted) Name() string { return "space.started" }
+
+// UserFollowed is published once FollowerID started following FollowedID.
+type UserFollowed struct {
+	FollowerID int64 `json:"follower_id"`
+	FollowedID int64 `json:"followed_id"`
+}
+
+func (UserFollowed) Name() string { return "user.followed" }
+
+// NotificationsCreated is published once notifications for UserIDs were
+// saved, new or collapsed into an unread one.
+type NotificationsCreated struct {
+	UserIDs []int64 `json:"user_ids"`
+}
+
+func (NotificationsCreated) Name() string { return "notifications.created" }
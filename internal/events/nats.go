@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// natsDuplicateWindow is how long JetStream remembers message IDs to drop
+// republished duplicates.
+const natsDuplicateWindow = 2 * time.Hour
+
+// NATSBroker publishes to a JetStream stream. Consumer groups are durable
+// consumers: members of a group pull from the same one.
+type NATSBroker struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	stream  string
+	subject string
+}
+
+// NewNATSBroker connects and creates or updates the stream, which captures
+// every subject under cfg.Subject.
+func NewNATSBroker(ctx context.Context, cfg BrokerConfig) (*NATSBroker, error) {
+	nc, err := nats.Connect(cfg.URL, nats.Name("gopher_social"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	_, err = js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:       cfg.Stream,
+		Subjects:   []string{cfg.Subject + ".>"},
+		Storage:    jetstream.FileStorage,
+		Duplicates: natsDuplicateWindow,
+	})
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &NATSBroker{nc: nc, js: js, stream: cfg.Stream, subject: cfg.Subject}, nil
+}
+
+// Publish returns once the stream stored the message.
+func (b *NATSBroker) Publish(ctx context.Context, msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = b.js.Publish(ctx, b.subject+"."+msg.Name, data, jetstream.WithMsgID(msg.ID))
+	return err
+}
+
+func (b *NATSBroker) Consume(ctx context.Context, group string, fn func(context.Context, Message) error) error {
+	consumer, err := b.js.CreateOrUpdateConsumer(ctx, b.stream, jetstream.ConsumerConfig{
+		Durable:       group,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		FilterSubject: b.subject + ".>",
+	})
+	if err != nil {
+		return err
+	}
+	cc, err := consumer.Consume(func(m jetstream.Msg) {
+		var msg Message
+		if err := json.Unmarshal(m.Data(), &msg); err != nil {
+			// Redelivering can't fix a message that does not parse.
+			_ = m.Term()
+			return
+		}
+		if err := fn(ctx, msg); err != nil {
+			_ = m.Nak()
+			return
+		}
+		_ = m.Ack()
+	})
+	if err != nil {
+		return err
+	}
+	<-ctx.Done()
+	cc.Stop()
+	return nil
+}
+
+func (b *NATSBroker) Close() error {
+	return b.nc.Drain()
+}
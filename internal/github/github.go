@@ -0,0 +1,173 @@
+// Package github looks up the repositories posts link to, for the repo cards
+// clients render as previews.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("github: repository not found")
+	// ErrRateLimited is returned without asking GitHub while the rate limit
+	// is used up, until it resets.
+	ErrRateLimited = errors.New("github: rate limited")
+)
+
+var repoLinkPattern = regexp.MustCompile(`https?://(?:www\.)?github\.com/([A-Za-z0-9](?:[A-Za-z0-9-]{0,38}))/([A-Za-z0-9._-]{1,100})`)
+
+// reservedOwners are github.com paths that look like an owner but aren't.
+var reservedOwners = map[string]bool{
+	"about": true, "apps": true, "collections": true, "enterprise": true, "explore": true,
+	"features": true, "marketplace": true, "orgs": true, "pricing": true, "settings": true,
+	"sponsors": true, "topics": true, "trending": true, "users": true,
+}
+
+// FindRepo returns the first GitHub repository linked in text.
+func FindRepo(text string) (owner, name string, ok bool) {
+	for _, m := range repoLinkPattern.FindAllStringSubmatch(text, -1) {
+		owner, name = m[1], strings.TrimSuffix(m[2], ".git")
+		if reservedOwners[strings.ToLower(owner)] || name == "" || name == "." || name == ".." {
+			continue
+		}
+		return owner, name, true
+	}
+	return "", "", false
+}
+
+type Repo struct {
+	FullName    string `json:"full_name"`
+	Description string `json:"description"`
+	Stars       int    `json:"stargazers_count"`
+	Language    string `json:"language"`
+	URL         string `json:"html_url"`
+}
+
+type cachedRepo struct {
+	repo    *Repo
+	err     error
+	expires time.Time
+}
+
+// Client reads repositories from the GitHub REST API. Answers, including
+// missing repositories, are cached for ttl, and once a response reports the
+// rate limit used up no request is made until it resets.
+type Client struct {
+	baseURL string
+	token   string
+	ttl     time.Duration
+	client  *http.Client
+
+	mu      sync.Mutex
+	cache   map[string]cachedRepo
+	resetAt time.Time
+}
+
+// New returns a client for the API at baseURL, https://api.github.com for
+// github.com. Without a token requests are anonymous, with a far lower rate
+// limit.
+func New(baseURL, token string, timeout, ttl time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		ttl:     ttl,
+		client:  &http.Client{Timeout: timeout},
+		cache:   make(map[string]cachedRepo),
+	}
+}
+
+func (c *Client) Repo(ctx context.Context, owner, name string) (*Repo, error) {
+	key := strings.ToLower(owner + "/" + name)
+	now := time.Now()
+	c.mu.Lock()
+	if cached, ok := c.cache[key]; ok && now.Before(cached.expires) {
+		c.mu.Unlock()
+		return cached.repo, cached.err
+	}
+	if now.Before(c.resetAt) {
+		c.mu.Unlock()
+		return nil, ErrRateLimited
+	}
+	c.mu.Unlock()
+
+	repo, err := c.fetch(ctx, owner, name)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		c.mu.Lock()
+		c.cache[key] = cachedRepo{repo: repo, err: err, expires: now.Add(c.ttl)}
+		c.evictExpired(now)
+		c.mu.Unlock()
+	}
+	return repo, err
+}
+
+// evictExpired drops expired entries so links seen once don't pile up.
+func (c *Client) evictExpired(now time.Time) {
+	for key, cached := range c.cache {
+		if now.After(cached.expires) {
+			delete(c.cache, key)
+		}
+	}
+}
+
+func (c *Client) fetch(ctx context.Context, owner, name string) (*Repo, error) {
+	endpoint := fmt.Sprintf("%s/repos/%s/%s", c.baseURL, url.PathEscape(owner), url.PathEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	limited := c.trackRateLimit(res)
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return nil, ErrNotFound
+	case limited || res.StatusCode == http.StatusTooManyRequests:
+		return nil, ErrRateLimited
+	default:
+		return nil, fmt.Errorf("github: %s", res.Status)
+	}
+
+	var repo Repo
+	if err := json.NewDecoder(res.Body).Decode(&repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// trackRateLimit remembers when the rate limit resets once a response says
+// no requests are left, and reports whether that is the case.
+func (c *Client) trackRateLimit(res *http.Response) bool {
+	if res.Header.Get("X-RateLimit-Remaining") != "0" && res.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	reset := time.Now().Add(time.Minute)
+	if secs, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		reset = time.Unix(secs, 0)
+	} else if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		reset = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	c.mu.Lock()
+	c.resetAt = reset
+	c.mu.Unlock()
+	return true
+}
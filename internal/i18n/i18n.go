@@ -0,0 +1,106 @@
+// Package i18n holds the translations of the API's own messages and picks
+// the locale to answer in.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Default is the locale of messages missing from a catalog and of users who
+// never chose one.
+const Default = "en"
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+var (
+	catalogs = mustLoad()
+	// Locales are the supported locales, Default first.
+	Locales = supported()
+	matcher = language.NewMatcher(tags())
+)
+
+func mustLoad() map[string]map[string]string {
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string, len(entries))
+	for _, e := range entries {
+		raw, err := localesFS.ReadFile(path.Join("locales", e.Name()))
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), ".json")] = messages
+	}
+	if _, ok := catalogs[Default]; !ok {
+		panic("i18n: no catalog for the default locale")
+	}
+	return catalogs
+}
+
+func supported() []string {
+	var others []string
+	for locale := range catalogs {
+		if locale != Default {
+			others = append(others, locale)
+		}
+	}
+	slices.Sort(others)
+	return append([]string{Default}, others...)
+}
+
+func tags() []language.Tag {
+	tags := make([]language.Tag, len(Locales))
+	for i, locale := range Locales {
+		tags[i] = language.MustParse(locale)
+	}
+	return tags
+}
+
+// Supported reports whether there is a catalog for locale.
+func Supported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// Match picks the supported locale closest to an Accept-Language header,
+// Default when none is.
+func Match(acceptLanguage string) string {
+	prefs, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(prefs) == 0 {
+		return Default
+	}
+	_, i, confidence := matcher.Match(prefs...)
+	if confidence == language.No {
+		return Default
+	}
+	return Locales[i]
+}
+
+// T returns the message for key in locale, formatted with args. Messages
+// missing from the locale's catalog come from Default's, unknown keys are
+// returned as is.
+func T(locale, key string, args ...any) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		if msg, ok = catalogs[Default][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
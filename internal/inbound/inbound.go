@@ -0,0 +1,255 @@
+// Package inbound reads emails forwarded by the mail provider's inbound
+// webhook. Providers post the raw RFC 5322 message signed with a shared
+// secret.
+package inbound
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the body.
+const SignatureHeader = "X-Inbound-Signature"
+
+var ErrInvalidSignature = errors.New("inbound: invalid signature")
+
+// maxDepth bounds nested multiparts, e.g. a forwarded mail inside a mail.
+const maxDepth = 5
+
+// Message is the part of an email a post is made of.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	// Text is the plain text body without the signature, or the HTML body
+	// stripped of tags when there is no plain text one.
+	Text        string
+	Attachments []Attachment
+	// Authenticated is set when the receiving server found DMARC to pass,
+	// or a DKIM signature aligned with the From domain to, see
+	// Authentication-Results in RFC 8601.
+	Authenticated bool
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Verify checks the signature header of a webhook body.
+func Verify(secret, body []byte, signature string) error {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parse reads a raw message. authservID names the receiving server, only
+// its Authentication-Results header is trusted.
+func Parse(r io.Reader, authservID string) (*Message, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, err
+	}
+	from, err := mail.ParseAddress(m.Header.Get("From"))
+	if err != nil {
+		return nil, err
+	}
+	msg := &Message{From: strings.ToLower(from.Address)}
+	msg.Authenticated = authenticated(m.Header["Authentication-Results"], authservID, msg.From)
+	for _, field := range []string{"To", "Cc", "Delivered-To"} {
+		addrs, err := m.Header.AddressList(field)
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			msg.To = append(msg.To, strings.ToLower(a.Address))
+		}
+	}
+	dec := new(mime.WordDecoder)
+	if msg.Subject, err = dec.DecodeHeader(m.Header.Get("Subject")); err != nil {
+		msg.Subject = m.Header.Get("Subject")
+	}
+
+	var text, htmlText string
+	err = walk(m.Header.Get("Content-Type"), m.Header.Get("Content-Transfer-Encoding"), "", m.Body, 0, func(p part) {
+		switch {
+		case p.filename != "":
+			msg.Attachments = append(msg.Attachments, Attachment{Filename: p.filename, ContentType: p.mediaType, Data: p.data})
+		case p.mediaType == "text/plain" && text == "":
+			text = string(p.data)
+		case p.mediaType == "text/html" && htmlText == "":
+			htmlText = string(p.data)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if text == "" && htmlText != "" {
+		text = html.UnescapeString(bluemonday.StrictPolicy().Sanitize(htmlText))
+	}
+	msg.Text = stripSignature(strings.ReplaceAll(text, "\r\n", "\n"))
+	return msg, nil
+}
+
+type part struct {
+	mediaType string
+	filename  string
+	data      []byte
+}
+
+// walk decodes the leaves of a possibly multipart body.
+func walk(contentType, encoding, disposition string, body io.Reader, depth int, fn func(part)) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxDepth {
+			return nil
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = walk(p.Header.Get("Content-Type"), p.Header.Get("Content-Transfer-Encoding"), p.Header.Get("Content-Disposition"), p, depth+1, fn)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// multipart decodes quoted-printable parts itself and hides the header,
+	// leaving single part messages to decode here.
+	switch strings.ToLower(encoding) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineSkipper{r: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	filename := params["name"]
+	if disp, dparams, err := mime.ParseMediaType(disposition); err == nil {
+		if dparams["filename"] != "" {
+			filename = dparams["filename"]
+		} else if disp == "attachment" && filename == "" {
+			filename = "attachment"
+		}
+	}
+	fn(part{mediaType: mediaType, filename: filename, data: data})
+	return nil
+}
+
+// newlineSkipper drops the line breaks of base64 bodies.
+type newlineSkipper struct {
+	r io.Reader
+}
+
+func (n *newlineSkipper) Read(p []byte) (int, error) {
+	for {
+		c, err := n.r.Read(p)
+		kept := p[:0]
+		for _, b := range p[:c] {
+			if b != '\r' && b != '\n' {
+				kept = append(kept, b)
+			}
+		}
+		if len(kept) > 0 || err != nil {
+			return len(kept), err
+		}
+	}
+}
+
+// stripSignature cuts the text at the "-- " line that conventionally starts
+// the sender's signature.
+func stripSignature(text string) string {
+	if i := strings.Index(text, "\n-- \n"); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+// authenticated reads the topmost Authentication-Results header naming
+// authservID. The receiving server prepends its own, so headers further
+// down or naming another server may have come with the mail. SPF alone
+// isn't enough, it checks the envelope sender rather than From.
+func authenticated(results []string, authservID, from string) bool {
+	_, fromDomain, _ := strings.Cut(from, "@")
+	for _, r := range results {
+		id, rest, _ := strings.Cut(stripComments(r), ";")
+		if fields := strings.Fields(id); len(fields) == 0 || !strings.EqualFold(fields[0], authservID) {
+			continue
+		}
+		for _, result := range strings.Split(strings.ToLower(rest), ";") {
+			fields := strings.Fields(result)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "dmarc=pass":
+				return true
+			case "dkim=pass":
+				for _, f := range fields[1:] {
+					if d, ok := strings.CutPrefix(f, "header.d="); ok && aligned(fromDomain, d) {
+						return true
+					}
+				}
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// aligned reports relaxed DMARC alignment: the signing domain is the From
+// domain or one of its parents.
+func aligned(fromDomain, signingDomain string) bool {
+	if fromDomain == "" || !strings.Contains(signingDomain, ".") {
+		return false
+	}
+	return fromDomain == signingDomain || strings.HasSuffix(fromDomain, "."+signingDomain)
+}
+
+// stripComments drops the parenthesized comments of a header value.
+func stripComments(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, c := range s {
+		switch {
+		case c == '(':
+			depth++
+		case c == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,14 @@
+package lang
+
+import "github.com/abadojack/whatlanggo"
+
+// Detect guesses the language of text and returns its ISO 639-1 code. Short
+// or mixed texts that can't be classified reliably return an empty string,
+// which callers treat as "unknown".
+func Detect(text string) string {
+	info := whatlanggo.Detect(text)
+	if !info.IsReliable() {
+		return ""
+	}
+	return info.Lang.Iso6391()
+}
@@ -1,16 +1,34 @@
 package mailer
 
-import "embed"
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
 
 const (
-	FromName            = "Gopher Social"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName                  = "Gopher Social"
+	maxRetries                = 3
+	UserWelcomeTemplate       = "user_invitation.tmpl"
+	AccountExistsTemplate     = "account_exists.tmpl"
+	RecoveryRequestedTemplate = "recovery_requested.tmpl"
+	RecoveryLinkTemplate      = "recovery_link.tmpl"
+	EmailChangeTemplate       = "email_change.tmpl"
 )
 
 //go:embed templates/*
 var FS embed.FS
 
+// Localized returns the locale's version of templateFile, kept under
+// templates/<locale>/, or templateFile itself when it isn't translated.
+func Localized(templateFile, locale string) string {
+	name := path.Join(locale, templateFile)
+	if _, err := fs.Stat(FS, path.Join("templates", name)); err != nil {
+		return templateFile
+	}
+	return name
+}
+
 type Client interface {
 	Send(templateFile, username, email string, data any, isSandbox bool) (int, error)
 }
@@ -0,0 +1,110 @@
+package markup
+
+import (
+	"regexp"
+	"sync/atomic"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+var shortcodePattern = regexp.MustCompile(`^[a-z0-9_-]{2,32}$`)
+
+// ValidShortcode reports whether s can name a custom emoji, written :s: in
+// posts and comments.
+func ValidShortcode(s string) bool {
+	return shortcodePattern.MatchString(s)
+}
+
+func isShortcodeByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// emojiSet maps the shortcodes of the instance's custom emoji to their image
+// URL. It is swapped as a whole when admins change the emoji.
+type emojiSet struct {
+	urls atomic.Pointer[map[string]string]
+}
+
+func (s *emojiSet) lookup(shortcode string) (string, bool) {
+	urls := s.urls.Load()
+	if urls == nil {
+		return "", false
+	}
+	url, ok := (*urls)[shortcode]
+	return url, ok
+}
+
+var kindEmoji = ast.NewNodeKind("Emoji")
+
+type emojiNode struct {
+	ast.BaseInline
+	Shortcode string
+	URL       string
+}
+
+func (n *emojiNode) Kind() ast.NodeKind {
+	return kindEmoji
+}
+
+func (n *emojiNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Shortcode": n.Shortcode, "URL": n.URL}, nil)
+}
+
+// emojiParser turns known :shortcode: into emoji. Unknown ones stay text, and
+// like any inline parser it never runs inside code.
+type emojiParser struct {
+	set *emojiSet
+}
+
+func (p *emojiParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *emojiParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	end := 1
+	for end < len(line) && isShortcodeByte(line[end]) {
+		end++
+	}
+	if end >= len(line) || line[end] != ':' {
+		return nil
+	}
+	shortcode := string(line[1:end])
+	url, ok := p.set.lookup(shortcode)
+	if !ok {
+		return nil
+	}
+	block.Advance(end + 1)
+	return &emojiNode{Shortcode: shortcode, URL: url}
+}
+
+type emojiHTMLRenderer struct{}
+
+func (r *emojiHTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindEmoji, r.render)
+}
+
+func (r *emojiHTMLRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*emojiNode)
+	_, _ = w.WriteString(`<img class="emoji" src="`)
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape([]byte(n.URL), false)))
+	_, _ = w.WriteString(`" alt=":` + n.Shortcode + `:" title=":` + n.Shortcode + `:">`)
+	return ast.WalkContinue, nil
+}
+
+type emojiExtension struct {
+	set *emojiSet
+}
+
+func (e *emojiExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&emojiParser{set: e.set}, 999)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&emojiHTMLRenderer{}, 500)))
+}
@@ -0,0 +1,47 @@
+package markup
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/microcosm-cc/bluemonday"
+)
+
+// codeFormatter marks tokens with chroma's CSS classes rather than inline
+// styles, clients pick the colors.
+var codeFormatter = html.New(html.WithClasses(true))
+
+// newCodePolicy allows the markup the formatter produces and nothing else.
+func newCodePolicy() *bluemonday.Policy {
+	policy := bluemonday.NewPolicy()
+	policy.AllowElements("pre", "code", "span")
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^[a-z0-9 ]+$`)).OnElements("pre", "code", "span")
+	return policy
+}
+
+// KnownLanguage reports whether code in language gets highlighted.
+func KnownLanguage(language string) bool {
+	return lexers.Get(language) != nil
+}
+
+// Highlight renders a code snippet as sanitized HTML. Code in a language
+// KnownLanguage doesn't know is escaped as plain text.
+func (r *Renderer) Highlight(language, code string) (string, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, code)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := codeFormatter.Format(&buf, styles.Fallback, iterator); err != nil {
+		return "", err
+	}
+	return r.codePolicy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,53 @@
+package markup
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Renderer turns user supplied markdown into HTML that is safe to embed.
+// Raw HTML in the source is never trusted: goldmark escapes it and the
+// output is run through a strict allow-list policy anyway.
+type Renderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+	emoji  *emojiSet
+	// codePolicy sanitizes highlighted snippets.
+	codePolicy *bluemonday.Policy
+}
+
+func NewRenderer() *Renderer {
+	policy := bluemonday.UGCPolicy()
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^emoji$`)).OnElements("img")
+	policy.AllowAttrs("alt", "title").OnElements("img")
+
+	emoji := &emojiSet{}
+	return &Renderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extension.Linkify, extension.Strikethrough, &emojiExtension{set: emoji}),
+		),
+		policy:     policy,
+		emoji:      emoji,
+		codePolicy: newCodePolicy(),
+	}
+}
+
+// SetEmoji replaces the custom emoji rendered for :shortcode:, keyed by
+// shortcode with the URL of the image.
+func (r *Renderer) SetEmoji(urls map[string]string) {
+	r.emoji.urls.Store(&urls)
+}
+
+func (r *Renderer) Render(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := r.md.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return r.policy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultWindow is how many recent samples per label the quantiles are
+// computed from.
+const DefaultWindow = 1024
+
+// Summary describes the recent samples recorded under one label.
+type Summary struct {
+	Count   int64   `json:"count"`
+	P50Ms   float64 `json:"p50_ms"`
+	P95Ms   float64 `json:"p95_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	RowsP50 int     `json:"rows_p50"`
+	RowsP95 int     `json:"rows_p95"`
+	RowsP99 int     `json:"rows_p99"`
+}
+
+type sample struct {
+	duration time.Duration
+	rows     int
+}
+
+type series struct {
+	count   int64
+	samples []sample
+	next    int
+}
+
+// QueryRecorder keeps a sliding window of query latencies and row counts per
+// label. It is safe for concurrent use.
+type QueryRecorder struct {
+	mu     sync.Mutex
+	window int
+	series map[string]*series
+}
+
+func NewQueryRecorder(window int) *QueryRecorder {
+	return &QueryRecorder{
+		window: window,
+		series: make(map[string]*series),
+	}
+}
+
+func (q *QueryRecorder) Observe(label string, d time.Duration, rows int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	s, ok := q.series[label]
+	if !ok {
+		s = &series{samples: make([]sample, 0, q.window)}
+		q.series[label] = s
+	}
+	s.count++
+	if len(s.samples) < q.window {
+		s.samples = append(s.samples, sample{d, rows})
+		return
+	}
+	s.samples[s.next] = sample{d, rows}
+	s.next = (s.next + 1) % q.window
+}
+
+// Snapshot summarises every label. Its result is meant to be published
+// through expvar.
+func (q *QueryRecorder) Snapshot() map[string]Summary {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make(map[string]Summary, len(q.series))
+	for label, s := range q.series {
+		durations := make([]time.Duration, len(s.samples))
+		rows := make([]int, len(s.samples))
+		for i, smp := range s.samples {
+			durations[i] = smp.duration
+			rows[i] = smp.rows
+		}
+		slices.Sort(durations)
+		slices.Sort(rows)
+		out[label] = Summary{
+			Count:   s.count,
+			P50Ms:   ms(quantile(durations, 0.50)),
+			P95Ms:   ms(quantile(durations, 0.95)),
+			P99Ms:   ms(quantile(durations, 0.99)),
+			RowsP50: quantile(rows, 0.50),
+			RowsP95: quantile(rows, 0.95),
+			RowsP99: quantile(rows, 0.99),
+		}
+	}
+	return out
+}
+
+// quantile uses the nearest-rank method on sorted values.
+func quantile[T any](sorted []T, p float64) T {
+	var zero T
+	if len(sorted) == 0 {
+		return zero
+	}
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	i = max(0, min(i, len(sorted)-1))
+	return sorted[i]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,60 @@
+// Package playground shares Go snippets on the Go Playground so readers can
+// run them.
+package playground
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client shares snippets through the playground's share endpoint.
+type Client struct {
+	baseURL string
+	client  *http.Client
+}
+
+// New returns a client for the playground at baseURL, https://go.dev for the
+// public one.
+func New(baseURL string, timeout time.Duration) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Runnable reports whether a snippet is a whole Go program the playground
+// can run.
+func Runnable(language, code string) bool {
+	return strings.EqualFold(language, "go") && strings.Contains(code, "package main") && strings.Contains(code, "func main()")
+}
+
+// Share uploads code and returns the link that opens it in the playground.
+func (c *Client) Share(ctx context.Context, code string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/_/share", strings.NewReader(code))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, 512))
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("playground: %d %s", res.StatusCode, body)
+	}
+	id := strings.TrimSpace(string(body))
+	if id == "" {
+		return "", fmt.Errorf("playground: empty share id")
+	}
+	return c.baseURL + "/play/p/" + id, nil
+}
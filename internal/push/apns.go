@@ -0,0 +1,127 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	APNsProduction = "https://api.push.apple.com"
+	APNsSandbox    = "https://api.sandbox.push.apple.com"
+	// Apple rejects provider tokens older than an hour and throttles ones
+	// refreshed more often than every 20 minutes.
+	apnsTokenTTL = 50 * time.Minute
+)
+
+// APNs sends to iOS devices through Apple's HTTP/2 API with token based
+// authentication.
+type APNs struct {
+	host   string
+	keyID  string
+	teamID string
+	// topic is the app's bundle ID.
+	topic  string
+	key    *ecdsa.PrivateKey
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNs loads the .p8 signing key from keyFile.
+func NewAPNs(host, keyFile, keyID, teamID, topic string) (*APNs, error) {
+	pem, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, fmt.Errorf("apns: %w", err)
+	}
+	return &APNs{
+		host:   host,
+		keyID:  keyID,
+		teamID: teamID,
+		topic:  topic,
+		key:    key,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (a *APNs) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Since(a.issuedAt) < apnsTokenTTL {
+		return a.token, nil
+	}
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": a.teamID, "iat": now.Unix()})
+	t.Header["kid"] = a.keyID
+	signed, err := t.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.issuedAt = signed, now
+	return signed, nil
+}
+
+func (a *APNs) Send(ctx context.Context, token string, msg Message) error {
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": msg.Title, "body": msg.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	providerToken, err := a.providerToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", a.topic)
+	req.Header.Set("apns-push-type", "alert")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var reason struct {
+		Reason string `json:"reason"`
+	}
+	msgBody, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	_ = json.Unmarshal(msgBody, &reason)
+	switch {
+	case res.StatusCode == http.StatusGone,
+		reason.Reason == "BadDeviceToken", reason.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("%w: %s", ErrTokenRejected, reason.Reason)
+	}
+	return fmt.Errorf("apns: %d %s", res.StatusCode, msgBody)
+}
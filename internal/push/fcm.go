@@ -0,0 +1,160 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCM sends to Android devices through the Firebase Cloud Messaging HTTP v1
+// API, authenticating as a Google service account.
+type FCM struct {
+	projectID   string
+	clientEmail string
+	tokenURI    string
+	key         *rsa.PrivateKey
+	client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCM loads the service account JSON key downloaded from the Firebase
+// console.
+func NewFCM(credentialsFile string) (*FCM, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	var creds struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("fcm: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("fcm: %w", err)
+	}
+	if creds.TokenURI == "" {
+		creds.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &FCM{
+		projectID:   creds.ProjectID,
+		clientEmail: creds.ClientEmail,
+		tokenURI:    creds.TokenURI,
+		key:         key,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// token exchanges a signed assertion for an access token, cached until
+// shortly before it expires.
+func (f *FCM) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.accessToken != "" && time.Now().Before(f.expiresAt) {
+		return f.accessToken, nil
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   f.clientEmail,
+		"scope": fcmScope,
+		"aud":   f.tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(f.key)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return "", fmt.Errorf("fcm token: %d %s", res.StatusCode, msg)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tok); err != nil {
+		return "", err
+	}
+	f.accessToken = tok.AccessToken
+	f.expiresAt = now.Add(time.Duration(tok.ExpiresIn)*time.Second - time.Minute)
+	return f.accessToken, nil
+}
+
+func (f *FCM) Send(ctx context.Context, token string, msg Message) error {
+	body, err := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"token":        token,
+			"notification": map[string]string{"title": msg.Title, "body": msg.Body},
+			"data":         msg.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	endpoint := "https://fcm.googleapis.com/v1/projects/" + f.projectID + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	msgBody, _ := io.ReadAll(io.LimitReader(res.Body, 2048))
+	// Unregistered tokens come back as 404 NOT_FOUND with the UNREGISTERED
+	// error code, malformed ones as INVALID_ARGUMENT naming the token.
+	if res.StatusCode == http.StatusNotFound || bytes.Contains(msgBody, []byte("UNREGISTERED")) ||
+		(res.StatusCode == http.StatusBadRequest && bytes.Contains(msgBody, []byte("registration token"))) {
+		return fmt.Errorf("%w: %s", ErrTokenRejected, res.Status)
+	}
+	return fmt.Errorf("fcm: %d %s", res.StatusCode, msgBody)
+}
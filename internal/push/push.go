@@ -0,0 +1,43 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Platforms a device token can belong to.
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+)
+
+// ErrTokenRejected means the provider no longer accepts the device token, the
+// app was uninstalled or the token rotated. Callers should forget the token.
+var ErrTokenRejected = errors.New("push: device token rejected")
+
+// Message is what the device shows. Data is handed to the app, e.g. the post
+// to open.
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Sender delivers messages to one platform's devices. Implementations must be
+// safe for concurrent use.
+type Sender interface {
+	Send(ctx context.Context, token string, msg Message) error
+}
+
+// Router picks the sender for a device's platform.
+type Router map[string]Sender
+
+// Send delivers to the platform's sender, platforms without one are an error.
+func (r Router) Send(ctx context.Context, platform, token string, msg Message) error {
+	s, ok := r[platform]
+	if !ok {
+		return fmt.Errorf("push: no sender for platform %q", platform)
+	}
+	return s.Send(ctx, token, msg)
+}
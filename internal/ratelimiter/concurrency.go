@@ -0,0 +1,32 @@
+package ratelimiter
+
+// ConcurrencyLimiter caps how many requests are in flight at once. Unlike the
+// fixed window limiter it is not per client, it protects shared resources
+// such as database connections.
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// TryAcquire takes a slot without waiting. Every successful call must be
+// paired with Release.
+func (l *ConcurrencyLimiter) TryAcquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *ConcurrencyLimiter) Release() {
+	<-l.slots
+}
+
+// InFlight returns the number of slots currently taken.
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
@@ -0,0 +1,130 @@
+// Package relme verifies that a page links back to a profile with rel="me",
+// the way Mastodon verifies profile links.
+package relme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// maxPageBytes bounds how much of a page is read looking for the link.
+const maxPageBytes = 1 << 20
+
+var errPrivateAddress = errors.New("relme: refusing to fetch a private address")
+
+// Checker fetches the pages users link from their profile. Only http(s)
+// pages on public addresses are fetched, so users can't make the server
+// probe its own network.
+type Checker struct {
+	// AllowPrivate lets the checker fetch loopback and private addresses,
+	// for tests and local development.
+	AllowPrivate bool
+	client       *http.Client
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	c := &Checker{}
+	dialer := &net.Dialer{Timeout: timeout, Control: c.control}
+	c.client = &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("relme: too many redirects")
+			}
+			return nil
+		},
+	}
+	return c
+}
+
+func (c *Checker) control(network, address string, _ syscall.RawConn) error {
+	if c.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// IsLink reports whether value is an http(s) URL the checker would fetch.
+func IsLink(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+// LinksBack fetches page and reports whether one of its <a> or <link>
+// elements with rel="me" points at any of targets.
+func (c *Checker) LinksBack(ctx context.Context, page string, targets []string) (bool, error) {
+	if !IsLink(page) {
+		return false, fmt.Errorf("relme: %q is not a link", page)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, page, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "text/html")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, nil
+	}
+
+	for _, href := range meLinks(io.LimitReader(res.Body, maxPageBytes)) {
+		if slices.Contains(targets, strings.TrimSuffix(href, "/")) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// meLinks returns the hrefs of the rel="me" links in an HTML document.
+func meLinks(r io.Reader) []string {
+	var links []string
+	z := html.NewTokenizer(r)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return links
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tok := z.Token()
+			if tok.Data != "a" && tok.Data != "link" {
+				continue
+			}
+			var href string
+			var me bool
+			for _, attr := range tok.Attr {
+				switch attr.Key {
+				case "href":
+					href = attr.Val
+				case "rel":
+					me = slices.Contains(strings.Fields(strings.ToLower(attr.Val)), "me")
+				}
+			}
+			if me && href != "" {
+				links = append(links, href)
+			}
+		}
+	}
+}
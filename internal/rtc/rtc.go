@@ -0,0 +1,124 @@
+// Package rtc opens and closes the media rooms of live spaces at an external
+// RTC provider. Audio and video never pass through the API, which only keeps
+// the rooms' metadata.
+package rtc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Room is a media room at the provider. Clients connect to URL.
+type Room struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+type Provider interface {
+	// CreateRoom opens a room for the space called name.
+	CreateRoom(ctx context.Context, name string) (Room, error)
+	// CloseRoom disconnects everyone and frees the room.
+	CloseRoom(ctx context.Context, roomID string) error
+}
+
+// Config selects and addresses a provider.
+type Config struct {
+	Driver  string
+	URL     string
+	APIKey  string
+	Timeout time.Duration
+}
+
+// Open returns the provider of cfg.Driver, nil when it is empty: spaces then
+// only carry metadata and clients bring their own media. Only "http" is
+// implemented, a provider's own SDK would be another Provider.
+func Open(cfg Config) (Provider, error) {
+	switch cfg.Driver {
+	case "":
+		return nil, nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("rtc: the http provider needs a URL")
+		}
+		return NewHTTPProvider(cfg.URL, cfg.APIKey, cfg.Timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown rtc provider %q", cfg.Driver)
+	}
+}
+
+// HTTPProvider talks to a provider through a small REST API: POST /rooms
+// with {"name"} answers a Room, DELETE /rooms/{id} closes it.
+type HTTPProvider struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+func NewHTTPProvider(baseURL, apiKey string, timeout time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPProvider) CreateRoom(ctx context.Context, name string) (Room, error) {
+	body, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return Room{}, err
+	}
+	res, err := p.do(ctx, http.MethodPost, p.baseURL+"/rooms", bytes.NewReader(body))
+	if err != nil {
+		return Room{}, err
+	}
+	defer res.Body.Close()
+
+	var room Room
+	if err := json.NewDecoder(res.Body).Decode(&room); err != nil {
+		return Room{}, err
+	}
+	if room.ID == "" || room.URL == "" {
+		return Room{}, fmt.Errorf("rtc: provider answered an incomplete room")
+	}
+	return room, nil
+}
+
+func (p *HTTPProvider) CloseRoom(ctx context.Context, roomID string) error {
+	res, err := p.do(ctx, http.MethodDelete, p.baseURL+"/rooms/"+url.PathEscape(roomID), nil)
+	if err != nil {
+		return err
+	}
+	return res.Body.Close()
+}
+
+// do sends the request and fails on any answer but a 2xx. A room already
+// gone counts as closed.
+func (p *HTTPProvider) do(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode/100 == 2 || method == http.MethodDelete && res.StatusCode == http.StatusNotFound {
+		return res, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	res.Body.Close()
+	return nil, fmt.Errorf("rtc: %s %s: %d %s", method, endpoint, res.StatusCode, msg)
+}
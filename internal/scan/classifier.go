@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Classifier sends uploads to an external classification API. The API gets
+// the raw bytes and answers {"label": "nsfw", "score": 0.97}, uploads scoring
+// threshold or more are flagged.
+type Classifier struct {
+	url       string
+	apiKey    string
+	threshold float64
+	client    *http.Client
+}
+
+func NewClassifier(url, apiKey string, threshold float64) *Classifier {
+	return &Classifier{
+		url:       url,
+		apiKey:    apiKey,
+		threshold: threshold,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (c *Classifier) Name() string { return "classifier" }
+
+func (c *Classifier) Scan(ctx context.Context, contentType string, data []byte) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return Result{}, fmt.Errorf("classifier: %d %s", res.StatusCode, msg)
+	}
+
+	var verdict struct {
+		Label string  `json:"label"`
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&verdict); err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Scanner: c.Name(),
+		Flagged: verdict.Score >= c.threshold,
+		Label:   verdict.Label,
+		Score:   verdict.Score,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"strings"
+)
+
+// HashList flags uploads whose SHA-256 is on a list of known bad files.
+type HashList struct {
+	hashes map[string]bool
+}
+
+func NewHashList(hashes []string) *HashList {
+	h := &HashList{hashes: make(map[string]bool, len(hashes))}
+	for _, hash := range hashes {
+		h.hashes[strings.ToLower(strings.TrimSpace(hash))] = true
+	}
+	return h
+}
+
+// LoadHashList reads one hex encoded SHA-256 per line, blank lines and lines
+// starting with # are skipped.
+func LoadHashList(path string) (*HashList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var hashes []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hashes = append(hashes, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return NewHashList(hashes), nil
+}
+
+func (h *HashList) Name() string { return "hash_list" }
+
+func (h *HashList) Scan(ctx context.Context, contentType string, data []byte) (Result, error) {
+	sum := sha256.Sum256(data)
+	if h.hashes[hex.EncodeToString(sum[:])] {
+		return Result{Scanner: h.Name(), Flagged: true, Label: "known_bad"}, nil
+	}
+	return Result{Scanner: h.Name()}, nil
+}
@@ -0,0 +1,49 @@
+package scan
+
+import (
+	"context"
+)
+
+// Result is one scanner's opinion of an upload. Error is set when the
+// scanner could not decide, Flagged is false then.
+type Result struct {
+	Scanner string  `json:"scanner"`
+	Flagged bool    `json:"flagged"`
+	Label   string  `json:"label,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Scanner inspects uploaded media. Implementations must be safe for
+// concurrent use.
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, contentType string, data []byte) (Result, error)
+}
+
+// Pipeline runs every scanner on an upload. An upload is quarantined when a
+// scanner flags it, or when one fails and the pipeline fails closed.
+type Pipeline struct {
+	scanners   []Scanner
+	failClosed bool
+}
+
+func NewPipeline(failClosed bool, scanners ...Scanner) *Pipeline {
+	return &Pipeline{scanners: scanners, failClosed: failClosed}
+}
+
+// Run returns the results of all scanners, it does not stop at the first
+// flag so reviewers see every reason.
+func (p *Pipeline) Run(ctx context.Context, contentType string, data []byte) (results []Result, quarantine bool) {
+	results = make([]Result, 0, len(p.scanners))
+	for _, s := range p.scanners {
+		res, err := s.Scan(ctx, contentType, data)
+		if err != nil {
+			res = Result{Scanner: s.Name(), Error: err.Error()}
+			quarantine = quarantine || p.failClosed
+		}
+		quarantine = quarantine || res.Flagged
+		results = append(results, res)
+	}
+	return results, quarantine
+}
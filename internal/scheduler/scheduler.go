@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Job is a unit of background work run every Interval. Local jobs refresh
+// state held in memory and run on every replica, the others on one at a
+// time.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+	Local    bool
+}
+
+// Locker keeps a job from running on several replicas at once. ok is false
+// when another replica holds the job's lock.
+type Locker interface {
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// Scheduler runs registered jobs on their own tickers until its context is
+// cancelled. Failed runs are logged and retried on the next tick, as are
+// runs skipped because another replica was running the job.
+type Scheduler struct {
+	logger *zap.SugaredLogger
+	locker Locker
+	jobs   []Job
+	wg     sync.WaitGroup
+}
+
+// New returns a scheduler taking the locker's lock around every run, a nil
+// locker runs jobs unguarded.
+func New(logger *zap.SugaredLogger, locker Locker) *Scheduler {
+	return &Scheduler{logger: logger, locker: locker}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func(job Job) {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}(job)
+	}
+}
+
+// Wait blocks until every job has returned after the context was cancelled.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	if s.locker != nil && !job.Local {
+		unlock, ok, err := s.locker.TryLock(ctx, job.Name)
+		if err != nil {
+			s.logger.Errorw("scheduled job lock failed", "job", job.Name, "error", err.Error())
+			return
+		}
+		if !ok {
+			s.logger.Debugw("scheduled job running elsewhere", "job", job.Name)
+			return
+		}
+		defer unlock()
+	}
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		s.logger.Errorw("scheduled job failed", "job", job.Name, "error", err.Error())
+		return
+	}
+	s.logger.Debugw("scheduled job finished", "job", job.Name, "duration", time.Since(start).String())
+}
@@ -0,0 +1,82 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type meiliIndex struct {
+	baseURL string
+	apiKey  string
+	index   string
+	client  *http.Client
+}
+
+// NewMeiliIndex returns an Index backed by a Meilisearch index. Writes are
+// asynchronous on the Meilisearch side, a nil error means the task was
+// accepted.
+func NewMeiliIndex(baseURL, apiKey, index string) Index {
+	return &meiliIndex{
+		baseURL: baseURL,
+		apiKey:  apiKey,
+		index:   index,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (m *meiliIndex) Upsert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return m.do(ctx, http.MethodPost, "/documents", docs)
+}
+
+func (m *meiliIndex) Delete(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return m.do(ctx, http.MethodPost, "/documents/delete-batch", ids)
+}
+
+func (m *meiliIndex) Reset(ctx context.Context) error {
+	return m.do(ctx, http.MethodDelete, "/documents", nil)
+}
+
+func (m *meiliIndex) do(ctx context.Context, method, path string, payload any) error {
+	var body io.Reader
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+
+	endpoint := m.baseURL + "/indexes/" + url.PathEscape(m.index) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+m.apiKey)
+	}
+
+	res, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("search backend: %s %s: %d %s", method, path, res.StatusCode, msg)
+	}
+	return nil
+}
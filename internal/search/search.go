@@ -0,0 +1,26 @@
+package search
+
+import (
+	"context"
+	"time"
+)
+
+// Document is the searchable projection of a post.
+type Document struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	Lang      string    `json:"lang"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Index is an external full text search backend. Upsert and Delete must be
+// idempotent, the indexer retries them after failures.
+type Index interface {
+	Upsert(ctx context.Context, docs []Document) error
+	Delete(ctx context.Context, ids []int64) error
+	// Reset removes every document, used before a reindex from scratch.
+	Reset(ctx context.Context) error
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AccountMerge records folding a duplicate account into another one. The
+// counts are the rows moved to the target, duplicates the follows, reactions
+// and bookmarks the target already had and which were dropped.
+type AccountMerge struct {
+	ID             int64     `json:"id"`
+	SourceID       int64     `json:"source_id"`
+	TargetID       int64     `json:"target_id"`
+	SourceUsername string    `json:"source_username"`
+	MergedBy       int64     `json:"merged_by"`
+	Reason         string    `json:"reason"`
+	Posts          int       `json:"posts"`
+	Comments       int       `json:"comments"`
+	Follows        int       `json:"follows"`
+	Reactions      int       `json:"reactions"`
+	Bookmarks      int       `json:"bookmarks"`
+	Duplicates     int       `json:"duplicates"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type AccountMergeStore struct {
+	db *sql.DB
+}
+
+const accountMergeColumns = `id, COALESCE(source_id, 0), COALESCE(target_id, 0), source_username, COALESCE(merged_by, 0), reason,
+	posts, comments, follows, reactions, bookmarks, duplicates, created_at`
+
+func scanAccountMerge(row interface{ Scan(...any) error }, m *AccountMerge) error {
+	return row.Scan(&m.ID, &m.SourceID, &m.TargetID, &m.SourceUsername, &m.MergedBy, &m.Reason,
+		&m.Posts, &m.Comments, &m.Follows, &m.Reactions, &m.Bookmarks, &m.Duplicates, &m.CreatedAt)
+}
+
+// Merge moves the posts, comments, follows, reactions and bookmarks of
+// m.SourceID to m.TargetID in one transaction, deactivates the source and
+// records the merge. Where both accounts follow, react to or bookmark the
+// same thing the target's row is kept. Follows between the two accounts are
+// dropped. ErrRecordNotFound means either account doesn't exist or was
+// already merged, ErrUnderLegalHold that either is preserved by a hold.
+func (s *AccountMergeStore) Merge(ctx context.Context, m *AccountMerge) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+		SELECT u.id, u.username, u.on_hold OR EXISTS (SELECT 1 FROM posts WHERE user_id = u.id AND on_hold)
+		FROM users u
+		WHERE u.id IN ($1, $2) AND u.merged_into IS NULL
+		ORDER BY u.id
+		FOR UPDATE
+		`, m.SourceID, m.TargetID)
+		if err != nil {
+			return err
+		}
+		var found int
+		var held bool
+		for rows.Next() {
+			var (
+				id       int64
+				username string
+				onHold   bool
+			)
+			if err := rows.Scan(&id, &username, &onHold); err != nil {
+				rows.Close()
+				return err
+			}
+			if id == m.SourceID {
+				m.SourceUsername = username
+			}
+			found++
+			held = held || onHold
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		switch {
+		case found != 2:
+			return ErrRecordNotFound
+		case held:
+			return ErrUnderLegalHold
+		}
+
+		// Moved posts are queued for the search indexer, their author changed.
+		if m.Posts, err = execCount(ctx, tx, `
+		WITH moved AS (UPDATE posts SET user_id = $2 WHERE user_id = $1 RETURNING id)
+		INSERT INTO outbox_events (topic, aggregate_id) SELECT $3, id FROM moved
+		`, m.SourceID, m.TargetID, TopicSearchPost); err != nil {
+			return err
+		}
+		if m.Comments, err = execCount(ctx, tx, `UPDATE comments SET user_id = $2 WHERE user_id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+
+		dropped := []string{
+			`DELETE FROM followers WHERE follower_id = $1
+			AND (user_id = $2 OR user_id IN (SELECT user_id FROM followers WHERE follower_id = $2))`,
+			`DELETE FROM followers WHERE user_id = $1
+			AND (follower_id = $2 OR follower_id IN (SELECT follower_id FROM followers WHERE user_id = $2))`,
+			`DELETE FROM reactions r WHERE r.user_id = $1 AND EXISTS (
+				SELECT 1 FROM reactions t WHERE t.user_id = $2 AND t.subject_type = r.subject_type AND t.subject_id = r.subject_id)`,
+			`DELETE FROM bookmarks b WHERE b.user_id = $1 AND EXISTS (
+				SELECT 1 FROM bookmarks t WHERE t.user_id = $2 AND t.post_id = b.post_id)`,
+		}
+		for _, query := range dropped {
+			n, err := execCount(ctx, tx, query, m.SourceID, m.TargetID)
+			if err != nil {
+				return err
+			}
+			m.Duplicates += n
+		}
+
+		var n int
+		if m.Follows, err = execCount(ctx, tx, `UPDATE followers SET follower_id = $2 WHERE follower_id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+		if n, err = execCount(ctx, tx, `UPDATE followers SET user_id = $2 WHERE user_id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+		m.Follows += n
+		if m.Reactions, err = execCount(ctx, tx, `UPDATE reactions SET user_id = $2 WHERE user_id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+		if m.Bookmarks, err = execCount(ctx, tx, `UPDATE bookmarks SET user_id = $2 WHERE user_id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_active = false, merged_into = $2 WHERE id = $1`, m.SourceID, m.TargetID); err != nil {
+			return err
+		}
+
+		query := `
+		INSERT INTO account_merges (source_id, target_id, source_username, merged_by, reason, posts, comments, follows, reactions, bookmarks, duplicates)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, created_at
+		`
+		return tx.QueryRowContext(ctx, query, m.SourceID, m.TargetID, m.SourceUsername, m.MergedBy, m.Reason,
+			m.Posts, m.Comments, m.Follows, m.Reactions, m.Bookmarks, m.Duplicates).Scan(&m.ID, &m.CreatedAt)
+	})
+}
+
+func execCount(ctx context.Context, tx *sql.Tx, query string, args ...any) (int, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// List returns merges newest first.
+func (s *AccountMergeStore) List(ctx context.Context, limit, offset int) ([]AccountMerge, error) {
+	query := `SELECT ` + accountMergeColumns + ` FROM account_merges ORDER BY created_at DESC, id DESC LIMIT $1 OFFSET $2`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	merges := []AccountMerge{}
+	for rows.Next() {
+		var m AccountMerge
+		if err := scanAccountMerge(rows, &m); err != nil {
+			return nil, err
+		}
+		merges = append(merges, m)
+	}
+	return merges, rows.Err()
+}
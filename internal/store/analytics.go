@@ -0,0 +1,197 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// topFollowersLimit is how many of a user's most engaged followers are kept.
+const topFollowersLimit = 10
+
+// Names under which the aggregations record the last day they rolled up.
+const (
+	AnalyticsFollowers = "followers"
+	AnalyticsPlatform  = "platform"
+)
+
+type DailyFollowers struct {
+	Date      string `json:"date"`
+	Followers int    `json:"followers"`
+	Gained    int    `json:"gained"`
+	Lost      int    `json:"lost"`
+}
+
+type EngagedFollower struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+type FollowerInsights struct {
+	Followers    int               `json:"followers"`
+	Gained       int               `json:"gained"`
+	Unfollows    int               `json:"unfollows"`
+	Growth       []DailyFollowers  `json:"growth"`
+	TopFollowers []EngagedFollower `json:"top_followers"`
+	// ComputedAt is when the top followers were last aggregated.
+	ComputedAt *time.Time `json:"computed_at"`
+}
+
+type AnalyticsStore struct {
+	db *sql.DB
+}
+
+// AggregateFollowers rolls the follows and unfollows of the UTC day starting
+// at day into follower_daily_stats and recomputes every user's most engaged
+// followers from the comments and reactions they left over the previous
+// engagementWindow. Running it twice for the same day is harmless. It scans
+// whole tables, so it runs under the caller's deadline rather than the
+// per-query write timeout.
+func (s *AnalyticsStore) AggregateFollowers(ctx context.Context, day time.Time, engagementWindow time.Duration) error {
+	day = day.UTC().Truncate(24 * time.Hour)
+	end := day.Add(24 * time.Hour)
+
+	statsQuery := `
+	WITH totals AS (
+		SELECT user_id,
+			COUNT(*) AS followers,
+			COUNT(*) FILTER (WHERE created_at >= $1) AS gained
+		FROM followers
+		WHERE created_at < $2
+		GROUP BY user_id
+	), lost AS (
+		SELECT user_id, COUNT(*) AS lost
+		FROM unfollows
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY user_id
+	)
+	INSERT INTO follower_daily_stats (user_id, day, followers, gained, lost)
+	SELECT COALESCE(t.user_id, l.user_id), $1::date, COALESCE(t.followers, 0), COALESCE(t.gained, 0), COALESCE(l.lost, 0)
+	FROM totals t
+	FULL JOIN lost l ON l.user_id = t.user_id
+	ON CONFLICT (user_id, day) DO UPDATE
+	SET followers = EXCLUDED.followers, gained = EXCLUDED.gained, lost = EXCLUDED.lost
+	`
+	engagementQuery := `
+	WITH activity AS (
+		SELECT p.user_id, c.user_id AS follower_id, COUNT(*) AS total
+		FROM comments c
+		JOIN posts p ON p.id = c.post_id
+		WHERE c.created_at >= $1
+		GROUP BY 1, 2
+		UNION ALL
+		SELECT p.user_id, r.user_id, COUNT(*)
+		FROM reactions r
+		JOIN posts p ON r.subject_type = 'post' AND p.id = r.subject_id
+		WHERE r.created_at >= $1
+		GROUP BY 1, 2
+	), scored AS (
+		SELECT a.user_id, a.follower_id, SUM(a.total) AS score,
+			ROW_NUMBER() OVER (PARTITION BY a.user_id ORDER BY SUM(a.total) DESC, a.follower_id) AS rank
+		FROM activity a
+		JOIN followers f ON f.user_id = a.user_id AND f.follower_id = a.follower_id
+		GROUP BY a.user_id, a.follower_id
+	)
+	INSERT INTO follower_engagement (user_id, follower_id, score)
+	SELECT user_id, follower_id, score FROM scored WHERE rank <= $2
+	`
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, statsQuery, day, end); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM follower_engagement`); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, engagementQuery, end.Add(-engagementWindow), topFollowersLimit); err != nil {
+			return err
+		}
+		return markAggregated(ctx, tx, AnalyticsFollowers, day)
+	})
+}
+
+// LastAggregated returns the last UTC day the named aggregation rolled up.
+// ErrRecordNotFound means it never ran.
+func (s *AnalyticsStore) LastAggregated(ctx context.Context, name string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var day time.Time
+	err := s.db.QueryRowContext(ctx, `SELECT day FROM analytics_runs WHERE name = $1`, name).Scan(&day)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, ErrRecordNotFound
+		}
+		return time.Time{}, err
+	}
+	return day.UTC(), nil
+}
+
+// markAggregated records day as rolled up by the named aggregation unless a
+// later day already is.
+func markAggregated(ctx context.Context, tx *sql.Tx, name string, day time.Time) error {
+	query := `
+	INSERT INTO analytics_runs (name, day) VALUES ($1, $2::date)
+	ON CONFLICT (name) DO UPDATE
+	SET day = GREATEST(analytics_runs.day, EXCLUDED.day), updated_at = NOW()
+	`
+	_, err := tx.ExecContext(ctx, query, name, day)
+	return err
+}
+
+// FollowerInsights reads a user's aggregated follower analytics from since
+// onwards. The follower total is live, everything else is as of the last
+// aggregation.
+func (s *AnalyticsStore) FollowerInsights(ctx context.Context, userID int64, since time.Time) (*FollowerInsights, error) {
+	query := `
+	WITH stats AS (
+		SELECT day, followers, gained, lost FROM follower_daily_stats
+		WHERE user_id = $1 AND day >= $2::date
+	)
+	SELECT
+		(SELECT COUNT(*) FROM followers WHERE user_id = $1),
+		(SELECT COALESCE(SUM(gained), 0) FROM stats),
+		(SELECT COALESCE(SUM(lost), 0) FROM stats),
+		COALESCE((
+			SELECT jsonb_agg(jsonb_build_object(
+				'date', to_char(day, 'YYYY-MM-DD'), 'followers', followers, 'gained', gained, 'lost', lost
+			) ORDER BY day) FROM stats
+		), '[]'),
+		COALESCE((
+			SELECT jsonb_agg(jsonb_build_object('user_id', u.id, 'username', u.username, 'score', e.score)
+				ORDER BY e.score DESC, u.id)
+			FROM follower_engagement e
+			JOIN users u ON u.id = e.follower_id
+			WHERE e.user_id = $1
+		), '[]'),
+		(SELECT MAX(computed_at) FROM follower_engagement WHERE user_id = $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var (
+		growth, top []byte
+		insights    FollowerInsights
+	)
+	err := s.db.QueryRowContext(ctx, query, userID, since).Scan(
+		&insights.Followers,
+		&insights.Gained,
+		&insights.Unfollows,
+		&growth,
+		&top,
+		&insights.ComputedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(growth, &insights.Growth); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(top, &insights.TopFollowers); err != nil {
+		return nil, err
+	}
+	return &insights, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Announcement severities, clients style the banner by them.
+const (
+	AnnouncementInfo     = "info"
+	AnnouncementWarning  = "warning"
+	AnnouncementCritical = "critical"
+)
+
+// Announcement is an admin notice shown to every user between StartsAt and
+// EndsAt, or indefinitely when EndsAt is nil, until the user dismisses it.
+type Announcement struct {
+	ID        int64      `json:"id"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Severity  string     `json:"severity"`
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	CreatedBy int64      `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type AnnouncementStore struct {
+	db *sql.DB
+}
+
+const announcementColumns = `id, title, body, severity, starts_at, ends_at, COALESCE(created_by, 0), created_at`
+
+func scanAnnouncement(row interface{ Scan(...any) error }, a *Announcement) error {
+	return row.Scan(&a.ID, &a.Title, &a.Body, &a.Severity, &a.StartsAt, &a.EndsAt, &a.CreatedBy, &a.CreatedAt)
+}
+
+// Create stores the announcement, a zero StartsAt means now.
+func (s *AnnouncementStore) Create(ctx context.Context, a *Announcement) error {
+	query := `
+	INSERT INTO announcements (title, body, severity, starts_at, ends_at, created_by)
+	VALUES ($1, $2, $3, COALESCE($4, NOW()), $5, $6)
+	RETURNING starts_at, id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var startsAt *time.Time
+	if !a.StartsAt.IsZero() {
+		startsAt = &a.StartsAt
+	}
+	return s.db.QueryRowContext(ctx, query, a.Title, a.Body, a.Severity, startsAt, a.EndsAt, a.CreatedBy).
+		Scan(&a.StartsAt, &a.ID, &a.CreatedAt)
+}
+
+// ListActive returns the announcements running now that the user hasn't
+// dismissed, newest first. A zero userID lists every running announcement.
+func (s *AnnouncementStore) ListActive(ctx context.Context, userID int64) ([]Announcement, error) {
+	query := `
+	SELECT ` + announcementColumns + ` FROM announcements a
+	WHERE a.starts_at <= NOW() AND (a.ends_at IS NULL OR a.ends_at > NOW())
+		AND NOT EXISTS (SELECT 1 FROM announcement_dismissals d WHERE d.announcement_id = a.id AND d.user_id = $1)
+	ORDER BY a.starts_at DESC, a.id DESC
+	`
+	return s.list(ctx, query, userID)
+}
+
+// List returns every announcement including scheduled and expired ones,
+// newest first.
+func (s *AnnouncementStore) List(ctx context.Context, limit, offset int) ([]Announcement, error) {
+	query := `SELECT ` + announcementColumns + ` FROM announcements
+	ORDER BY starts_at DESC, id DESC
+	LIMIT $1 OFFSET $2`
+	return s.list(ctx, query, limit, offset)
+}
+
+func (s *AnnouncementStore) list(ctx context.Context, query string, args ...any) ([]Announcement, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		var a Announcement
+		if err := scanAnnouncement(rows, &a); err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
+
+func (s *AnnouncementStore) Delete(ctx context.Context, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM announcements WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Dismiss hides the announcement for the user. Dismissing twice is a no-op,
+// ErrRecordNotFound means there is no such announcement.
+func (s *AnnouncementStore) Dismiss(ctx context.Context, id, userID int64) error {
+	query := `
+	WITH a AS (SELECT id FROM announcements WHERE id = $1),
+	dismissed AS (
+		INSERT INTO announcement_dismissals (announcement_id, user_id)
+		SELECT id, $2 FROM a
+		ON CONFLICT DO NOTHING
+	)
+	SELECT EXISTS (SELECT 1 FROM a)
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, query, id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrRecordNotFound
+	}
+	return nil
+}
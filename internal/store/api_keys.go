@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyPrefix starts every API key so they can be told apart from session
+// tokens and spotted by secret scanners.
+const APIKeyPrefix = "gsk_"
+
+// APIKey authenticates a service account. Only a hash of the key is stored,
+// the key itself is shown once when it is created.
+type APIKey struct {
+	ID     int64  `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	// Prefix is the start of the key, enough to recognise it in a list.
+	Prefix string `json:"prefix"`
+	// Scopes limit what the key can do, nil leaves it unrestricted.
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// NewAPIKeySecret returns a random key carrying APIKeyPrefix.
+func NewAPIKeySecret() (string, error) {
+	return newSecret(APIKeyPrefix, 32)
+}
+
+// newSecret returns n random bytes hex encoded after prefix.
+func newSecret(prefix string, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// hashSecret is how API keys and OAuth secrets are stored.
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+type APIKeyStore struct {
+	db    *sql.DB
+	stmts *stmtCache
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, COALESCE(created_by, 0), created_at, last_used_at, revoked_at`
+
+func scanAPIKey(row interface{ Scan(...any) error }, k *APIKey) error {
+	return row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.CreatedBy, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+}
+
+// Create stores the key for a service account. ErrRecordNotFound means the
+// user doesn't exist or isn't a service account.
+func (s *APIKeyStore) Create(ctx context.Context, key *APIKey, secret string) error {
+	query := `
+	INSERT INTO api_keys (user_id, name, prefix, key_hash, created_by, scopes)
+	SELECT id, $2, $3, $4, $5, $6 FROM users WHERE id = $1 AND is_bot
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	key.Prefix = secret[:len(APIKeyPrefix)+8]
+	err := s.db.QueryRowContext(ctx, query, key.UserID, key.Name, key.Prefix, hashSecret(secret), key.CreatedBy, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRecordNotFound
+	}
+	return err
+}
+
+// Authenticate returns the key, which names the service account it belongs
+// to, and records its use. Revoked keys and keys of deactivated accounts give
+// ErrRecordNotFound.
+func (s *APIKeyStore) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	query := `
+	UPDATE api_keys k SET last_used_at = NOW()
+	FROM users u
+	WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND u.id = k.user_id AND u.is_active AND u.is_bot
+	RETURNING k.id, k.user_id, k.name, k.prefix, k.scopes, COALESCE(k.created_by, 0), k.created_at, k.last_used_at, k.revoked_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var key APIKey
+	if err := scanAPIKey(s.stmts.QueryRowContext(ctx, "api_key_auth", query, hashSecret(secret)), &key); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &key, nil
+}
+
+// ListByUser returns the account's keys newest first, revoked ones included.
+func (s *APIKeyStore) ListByUser(ctx context.Context, userID int64) ([]APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY id DESC`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var k APIKey
+		if err := scanAPIKey(rows, &k); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables the key for good. ErrRecordNotFound means the account has
+// no such active key.
+func (s *APIKeyStore) Revoke(ctx context.Context, userID, id int64) error {
+	query := `UPDATE api_keys SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
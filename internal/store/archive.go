@@ -0,0 +1,370 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ArchivedPost is the tombstone of a post moved to cold storage.
+type ArchivedPost struct {
+	PostID        int64     `json:"post_id"`
+	UserID        int64     `json:"user_id"`
+	BlobKey       string    `json:"blob_key"`
+	PostCreatedAt time.Time `json:"post_created_at"`
+	ArchivedAt    time.Time `json:"archived_at"`
+}
+
+// PostArchive is what goes to cold storage: the post's rows as JSON, in the
+// shape of the tables they came from so they can be put back as they were.
+// Bookmarks, impressions and notifications of the post are not kept. Posts
+// with an event, listing or space aren't archived at all.
+type PostArchive struct {
+	Post       json.RawMessage `json:"post"`
+	Body       json.RawMessage `json:"body"`
+	Comments   json.RawMessage `json:"comments"`
+	Reactions  json.RawMessage `json:"reactions"`
+	Snippets   json.RawMessage `json:"snippets"`
+	RepoCard   json.RawMessage `json:"repo_card"`
+	Crossposts json.RawMessage `json:"crossposts"`
+}
+
+// ErrNotArchivable is returned when archiving a post that has an event,
+// listing or space attached, their RSVPs and participants would be lost.
+var ErrNotArchivable = errors.New("post can't be archived")
+
+// attachedQuery is true for posts with rows the archive doesn't keep.
+const attachedQuery = `
+	EXISTS (SELECT 1 FROM events e WHERE e.post_id = p.id)
+	OR EXISTS (SELECT 1 FROM listings l WHERE l.post_id = p.id)
+	OR EXISTS (SELECT 1 FROM spaces s WHERE s.post_id = p.id)
+`
+
+type ArchiveStore struct {
+	db *sql.DB
+}
+
+// Candidates returns up to limit posts created before cutoff that can be
+// archived, posts under legal hold stay in the database.
+func (s *ArchiveStore) Candidates(ctx context.Context, cutoff time.Time, limit int) ([]int64, error) {
+	query := `
+	SELECT p.id FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE p.created_at < $1 AND NOT p.on_hold AND NOT u.on_hold AND NOT (` + attachedQuery + `)
+	ORDER BY p.created_at
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Archive hands the post to put, which writes it to cold storage and returns
+// the key, then deletes it from the database. The post stays locked
+// throughout so edits and new comments can't slip in between.
+func (s *ArchiveStore) Archive(ctx context.Context, postID int64, put func(context.Context, *PostArchive) (string, error)) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var (
+			archive   PostArchive
+			tombstone = ArchivedPost{PostID: postID}
+			held      bool
+			attached  bool
+		)
+		err := tx.QueryRowContext(ctx, `
+		SELECT p.user_id, p.created_at, p.on_hold OR u.on_hold, `+attachedQuery+`
+		FROM posts p JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1
+		FOR UPDATE OF p
+		`, postID).Scan(&tombstone.UserID, &tombstone.PostCreatedAt, &held, &attached)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		if held {
+			return ErrUnderLegalHold
+		}
+		if attached {
+			return ErrNotArchivable
+		}
+		// Fold the post's pending counter shards in first so the archived
+		// counts are complete, see CounterStore.Compact.
+		_, err = tx.ExecContext(ctx, `
+		WITH drained AS (
+			DELETE FROM post_counter_shards WHERE post_id = $1 RETURNING counter, delta
+		)
+		UPDATE posts SET
+			reactions_count = reactions_count + COALESCE((SELECT SUM(delta) FROM drained WHERE counter = 'reactions'), 0),
+			views_count = views_count + COALESCE((SELECT SUM(delta) FROM drained WHERE counter = 'views'), 0)
+		WHERE id = $1
+		`, postID)
+		if err != nil {
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT row_to_json(p) FROM posts p WHERE p.id = $1),
+			(SELECT row_to_json(b) FROM post_bodies b WHERE b.post_id = $1),
+			(SELECT COALESCE(json_agg(c ORDER BY c.id), '[]') FROM comments c WHERE c.post_id = $1),
+			(SELECT COALESCE(json_agg(r), '[]') FROM reactions r
+				WHERE (r.subject_type = 'post' AND r.subject_id = $1)
+					OR (r.subject_type = 'comment' AND r.subject_id IN (SELECT id FROM comments WHERE post_id = $1))),
+			(SELECT COALESCE(json_agg(s ORDER BY s.position), '[]') FROM post_snippets s WHERE s.post_id = $1),
+			(SELECT row_to_json(rc) FROM post_repo_cards rc WHERE rc.post_id = $1),
+			(SELECT COALESCE(json_agg(d ORDER BY d.id), '[]') FROM crosspost_deliveries d WHERE d.post_id = $1)
+		`, postID).Scan(&archive.Post, &archive.Body, &archive.Comments, &archive.Reactions, &archive.Snippets, &archive.RepoCard, &archive.Crossposts)
+		if err != nil {
+			return err
+		}
+
+		if tombstone.BlobKey, err = put(ctx, &archive); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+		reactionsQuery := `
+		DELETE FROM reactions
+		WHERE (subject_type = 'post' AND subject_id = $1)
+			OR (subject_type = 'comment' AND subject_id IN (SELECT id FROM comments WHERE post_id = $1))
+		`
+		if _, err := tx.ExecContext(ctx, reactionsQuery, postID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE post_id = $1`, postID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = $1`, postID); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO archived_posts (post_id, user_id, blob_key, post_created_at) VALUES ($1, $2, $3, $4)
+		`, tombstone.PostID, tombstone.UserID, tombstone.BlobKey, tombstone.PostCreatedAt)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, TopicSearchPost, postID)
+	})
+}
+
+func (s *ArchiveStore) Get(ctx context.Context, postID int64) (*ArchivedPost, error) {
+	query := `SELECT post_id, user_id, blob_key, post_created_at, archived_at FROM archived_posts WHERE post_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var a ArchivedPost
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&a.PostID, &a.UserID, &a.BlobKey, &a.PostCreatedAt, &a.ArchivedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &a, nil
+}
+
+// List returns archived posts, of one user when userID isn't 0, most
+// recently archived first.
+func (s *ArchiveStore) List(ctx context.Context, userID int64, limit, offset int) ([]ArchivedPost, error) {
+	query := `
+	SELECT post_id, user_id, blob_key, post_created_at, archived_at FROM archived_posts
+	WHERE $1 = 0 OR user_id = $1
+	ORDER BY archived_at DESC, post_id DESC
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	archived := []ArchivedPost{}
+	for rows.Next() {
+		var a ArchivedPost
+		if err := rows.Scan(&a.PostID, &a.UserID, &a.BlobKey, &a.PostCreatedAt, &a.ArchivedAt); err != nil {
+			return nil, err
+		}
+		archived = append(archived, a)
+	}
+	return archived, rows.Err()
+}
+
+// Restore puts an archived post back into the database and drops its
+// tombstone, the blob is left for the caller to delete. Comments and
+// reactions of users deleted in the meantime are skipped, as are crossposts
+// to accounts since removed. ErrRecordNotFound means the post isn't archived
+// (anymore).
+//
+// Columns are listed one by one: an archive written before a column was
+// added lacks it, and the COALESCEs give it the column's default.
+func (s *ArchiveStore) Restore(ctx context.Context, postID int64, archive *PostArchive) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM archived_posts WHERE post_id = $1`, postID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrRecordNotFound
+		}
+
+		// The accepted answer is set once the comments are back, the reaction
+		// count once it's known which reactions made it. The comment count
+		// follows the comments through their trigger.
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO posts (id, title, user_id, content, created_at, tags, updated_at, version, lang,
+			content_warning, kind, fingerprint, has_media, on_hold, age_restricted, views_count, expires_at)
+		SELECT r.id, r.title, r.user_id, r.content, r.created_at, r.tags, COALESCE(r.updated_at, r.created_at),
+			COALESCE(r.version, 0), COALESCE(r.lang, ''), COALESCE(r.content_warning, ''), COALESCE(r.kind, 'note'),
+			COALESCE(r.fingerprint, ''), COALESCE(r.has_media, false), COALESCE(r.on_hold, false),
+			COALESCE(r.age_restricted, false), COALESCE(r.views_count, 0), r.expires_at
+		FROM json_populate_record(NULL::posts, $1) r
+		WHERE r.id = $2
+		`, []byte(archive.Post), postID)
+		if err != nil {
+			return err
+		}
+		if len(archive.Body) > 0 && string(archive.Body) != "null" {
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO post_bodies (post_id, body, updated_at)
+			SELECT $2, b.body, COALESCE(b.updated_at, NOW())
+			FROM json_populate_record(NULL::post_bodies, $1) b
+			`, []byte(archive.Body), postID)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+		WITH kept AS (
+			SELECT c.* FROM json_populate_recordset(NULL::comments, $1) c
+			WHERE EXISTS (SELECT 1 FROM users u WHERE u.id = c.user_id)
+		)
+		INSERT INTO comments (id, post_id, parent_id, user_id, content, created_at, hidden_at, hidden_by, is_answer)
+		SELECT k.id, $2, CASE WHEN k.parent_id IN (SELECT id FROM kept) THEN k.parent_id END, k.user_id,
+			k.content, k.created_at, k.hidden_at,
+			CASE WHEN EXISTS (SELECT 1 FROM users u WHERE u.id = k.hidden_by) THEN k.hidden_by END,
+			COALESCE(k.is_answer, false)
+		FROM kept k
+		`, jsonArray(archive.Comments), postID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO reactions (subject_type, subject_id, user_id, type, created_at)
+		SELECT r.subject_type, r.subject_id, r.user_id, r.type, r.created_at
+		FROM json_populate_recordset(NULL::reactions, $1) r
+		WHERE EXISTS (SELECT 1 FROM users WHERE id = r.user_id)
+			AND ((r.subject_type = 'post' AND r.subject_id = $2)
+				OR (r.subject_type = 'comment' AND r.subject_id IN (SELECT id FROM comments WHERE post_id = $2)))
+		`, jsonArray(archive.Reactions), postID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+		UPDATE posts p SET
+			accepted_answer_id = (SELECT c.id FROM comments c WHERE c.post_id = p.id AND c.id = r.accepted_answer_id),
+			reactions_count = (SELECT COUNT(*) FROM reactions x WHERE x.subject_type = 'post' AND x.subject_id = p.id)
+		FROM json_populate_record(NULL::posts, $1) r
+		WHERE p.id = $2
+		`, []byte(archive.Post), postID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO post_snippets (post_id, position, language, content, playground_url)
+		SELECT $2, s.position, s.language, s.content, COALESCE(s.playground_url, '')
+		FROM json_populate_recordset(NULL::post_snippets, $1) s
+		`, jsonArray(archive.Snippets), postID)
+		if err != nil {
+			return err
+		}
+		if len(archive.RepoCard) > 0 && string(archive.RepoCard) != "null" {
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO post_repo_cards (post_id, owner, name, description, stars, language, url, fetched_at)
+			SELECT $2, rc.owner, rc.name, COALESCE(rc.description, ''), COALESCE(rc.stars, 0),
+				COALESCE(rc.language, ''), COALESCE(rc.url, ''), rc.fetched_at
+			FROM json_populate_record(NULL::post_repo_cards, $1) rc
+			`, []byte(archive.RepoCard), postID)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO crosspost_deliveries (post_id, account_id, status, attempts, next_attempt_at, remote_url, error, updated_at)
+		SELECT $2, d.account_id, d.status, d.attempts, d.next_attempt_at, d.remote_url, d.error, d.updated_at
+		FROM json_populate_recordset(NULL::crosspost_deliveries, $1) d
+		WHERE EXISTS (SELECT 1 FROM crosspost_accounts a WHERE a.id = d.account_id)
+		`, jsonArray(archive.Crossposts), postID)
+		if err != nil {
+			return err
+		}
+		return enqueueOutbox(ctx, tx, TopicSearchPost, postID)
+	})
+}
+
+// PruneOrphans drops the tombstones of users who no longer exist and returns
+// them so their blobs can be deleted too.
+func (s *ArchiveStore) PruneOrphans(ctx context.Context, limit int) ([]ArchivedPost, error) {
+	query := `
+	DELETE FROM archived_posts
+	WHERE post_id IN (
+		SELECT a.post_id FROM archived_posts a
+		WHERE NOT EXISTS (SELECT 1 FROM users u WHERE u.id = a.user_id)
+		LIMIT $1
+	)
+	RETURNING post_id, user_id, blob_key, post_created_at, archived_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pruned []ArchivedPost
+	for rows.Next() {
+		var a ArchivedPost
+		if err := rows.Scan(&a.PostID, &a.UserID, &a.BlobKey, &a.PostCreatedAt, &a.ArchivedAt); err != nil {
+			return nil, err
+		}
+		pruned = append(pruned, a)
+	}
+	return pruned, rows.Err()
+}
+
+func jsonArray(raw json.RawMessage) []byte {
+	if len(raw) == 0 || string(raw) == "null" {
+		return []byte("[]")
+	}
+	return raw
+}
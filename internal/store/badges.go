@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+
+	"github.com/lib/pq"
+)
+
+// Badges shown next to a user's name. Verified is only granted by admins,
+// staff and early adopter are also assigned automatically.
+const (
+	BadgeVerified     = "verified"
+	BadgeStaff        = "staff"
+	BadgeEarlyAdopter = "early_adopter"
+)
+
+var Badges = []string{BadgeVerified, BadgeStaff, BadgeEarlyAdopter}
+
+func ValidBadge(badge string) bool {
+	return slices.Contains(Badges, badge)
+}
+
+// userBadges selects the badges of the user in users.id, for user queries.
+const userBadges = `ARRAY(SELECT badge FROM user_badges WHERE user_id = users.id ORDER BY badge)`
+
+type BadgeStore struct {
+	db *sql.DB
+}
+
+// Grant gives the user the badge on behalf of an admin. A badge granted by
+// an admin is never revoked automatically.
+func (s *BadgeStore) Grant(ctx context.Context, userID int64, badge string, grantedBy int64) error {
+	query := `
+	INSERT INTO user_badges (user_id, badge, granted_by) VALUES ($1, $2, $3)
+	ON CONFLICT (user_id, badge) DO UPDATE SET granted_by = EXCLUDED.granted_by, granted_at = NOW()
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, badge, grantedBy)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23503" {
+		return ErrRecordNotFound
+	}
+	return err
+}
+
+func (s *BadgeStore) Revoke(ctx context.Context, userID int64, badge string) error {
+	query := `DELETE FROM user_badges WHERE user_id = $1 AND badge = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, badge)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Assign applies the automated criteria: staff for users whose role is at
+// least staffRole's level, early adopter for the first earlyAdopters human
+// accounts. Staff badges no longer earned are revoked unless an admin
+// granted them. It returns the IDs of the users whose badges changed.
+func (s *BadgeStore) Assign(ctx context.Context, staffRole string, earlyAdopters int) ([]int64, error) {
+	var changed []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		for _, q := range []struct {
+			query string
+			args  []any
+		}{
+			{`
+			INSERT INTO user_badges (user_id, badge)
+			SELECT users.id, 'staff' FROM users JOIN roles ON roles.id = users.role_id
+			WHERE roles.level >= (SELECT level FROM roles WHERE name = $1)
+			ON CONFLICT DO NOTHING
+			RETURNING user_id
+			`, []any{staffRole}},
+			{`
+			DELETE FROM user_badges b USING users JOIN roles ON roles.id = users.role_id
+			WHERE b.user_id = users.id AND b.badge = 'staff' AND b.granted_by IS NULL
+				AND roles.level < (SELECT level FROM roles WHERE name = $1)
+			RETURNING b.user_id
+			`, []any{staffRole}},
+			{`
+			INSERT INTO user_badges (user_id, badge)
+			SELECT id, 'early_adopter' FROM (
+				SELECT id FROM users WHERE NOT is_bot ORDER BY created_at, id LIMIT $1
+			) first
+			ON CONFLICT DO NOTHING
+			RETURNING user_id
+			`, []any{earlyAdopters}},
+		} {
+			rows, err := tx.QueryContext(ctx, q.query, q.args...)
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+				var id int64
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return err
+				}
+				changed = append(changed, id)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
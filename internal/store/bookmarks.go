@@ -0,0 +1,31 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type BookmarkStore struct {
+	db *sql.DB
+}
+
+func (s *BookmarkStore) Add(ctx context.Context, postID, userID int64) error {
+	query := `
+	INSERT INTO bookmarks (post_id, user_id) VALUES ($1, $2)
+	ON CONFLICT DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID)
+	return err
+}
+
+func (s *BookmarkStore) Remove(ctx context.Context, postID, userID int64) error {
+	query := `DELETE FROM bookmarks WHERE post_id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, userID)
+	return err
+}
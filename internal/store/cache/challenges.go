@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ChallengeStore keeps short lived, single use ceremony state such as
+// WebAuthn session data between the begin and finish requests.
+type ChallengeStore struct {
+	rdb *redis.Client
+}
+
+func (s *ChallengeStore) Set(ctx context.Context, key string, data []byte, exp time.Duration) error {
+	return s.rdb.SetEX(ctx, "challenge-"+key, data, exp).Err()
+}
+
+// Pop returns the stored data and removes it, so a challenge can only be
+// answered once. A missing or expired key returns nil data and no error.
+func (s *ChallengeStore) Pop(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.rdb.GetDel(ctx, "challenge-"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
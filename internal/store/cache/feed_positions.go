@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"gopher_social/internal/store"
+	"time"
+)
+
+// FeedPositionExpTime keeps positions of active users in Redis, idle users
+// fall back to Postgres.
+const FeedPositionExpTime = time.Hour * 24 * 7
+
+type FeedPositionStore struct {
+	keys versionedKeys
+}
+
+func (s *FeedPositionStore) Get(ctx context.Context, userID int64) (*store.FeedPosition, error) {
+	data, err := s.keys.get(ctx, userID)
+	if data == nil || err != nil {
+		return nil, err
+	}
+	var position store.FeedPosition
+	if err := json.Unmarshal(data, &position); err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
+
+func (s *FeedPositionStore) Set(ctx context.Context, userID int64, position *store.FeedPosition) error {
+	data, err := json.Marshal(position)
+	if err != nil {
+		return err
+	}
+	return s.keys.set(ctx, userID, data, FeedPositionExpTime)
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gopher_social/internal/store"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const trendingKey = "hashtags-trending"
+
+// HashtagStore keeps per bucket tag counts and the ranked trending tags.
+// Buckets are keyed by their start time.
+type HashtagStore struct {
+	rdb *redis.Client
+}
+
+func bucketKey(start time.Time) string {
+	return fmt.Sprintf("hashtag-counts-%d", start.Unix())
+}
+
+// SetBucket replaces the counts of the bucket starting at start.
+func (s *HashtagStore) SetBucket(ctx context.Context, start time.Time, counts map[string]int, exp time.Duration) error {
+	key := bucketKey(start)
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(counts) > 0 {
+			values := make(map[string]any, len(counts))
+			for tag, n := range counts {
+				values[tag] = n
+			}
+			pipe.HSet(ctx, key, values)
+			pipe.Expire(ctx, key, exp)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetBuckets returns the counts of each bucket, empty for missing ones.
+func (s *HashtagStore) GetBuckets(ctx context.Context, starts []time.Time) ([]map[string]int, error) {
+	cmds := make([]*redis.StringStringMapCmd, len(starts))
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, start := range starts {
+			cmds[i] = pipe.HGetAll(ctx, bucketKey(start))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]map[string]int, len(starts))
+	for i, cmd := range cmds {
+		buckets[i] = make(map[string]int)
+		for tag, raw := range cmd.Val() {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, err
+			}
+			buckets[i][tag] = n
+		}
+	}
+	return buckets, nil
+}
+
+// GetTrending returns nil, nil when no ranking has been stored yet.
+func (s *HashtagStore) GetTrending(ctx context.Context) ([]store.TrendingTag, error) {
+	data, err := s.rdb.Get(ctx, trendingKey).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var tags []store.TrendingTag
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+func (s *HashtagStore) SetTrending(ctx context.Context, tags []store.TrendingTag, exp time.Duration) error {
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	return s.rdb.SetEX(ctx, trendingKey, data, exp).Err()
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// maxHotUsers is how many users an hourly ranking keeps, the rest are
+	// trimmed after every write.
+	maxHotUsers = 10000
+	hotUsersExp = 2 * time.Hour
+)
+
+// HotUserStore ranks users by how often instances looked them up, per hour,
+// so a fresh instance knows whom to load before taking traffic.
+type HotUserStore struct {
+	rdb *redis.Client
+}
+
+func hotUsersKey(hour time.Time) string {
+	return fmt.Sprintf("hot-users-%d", hour.Unix())
+}
+
+// Record adds counts to the ranking of the current hour.
+func (s *HotUserStore) Record(ctx context.Context, counts map[int64]int) error {
+	if len(counts) == 0 {
+		return nil
+	}
+	key := hotUsersKey(time.Now().UTC().Truncate(time.Hour))
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for id, n := range counts {
+			pipe.ZIncrBy(ctx, key, float64(n), strconv.FormatInt(id, 10))
+		}
+		pipe.ZRemRangeByRank(ctx, key, 0, -maxHotUsers-1)
+		pipe.Expire(ctx, key, hotUsersExp)
+		return nil
+	})
+	return err
+}
+
+// Top returns up to n users looked up most over the current and the previous
+// hour, the hottest first.
+func (s *HotUserStore) Top(ctx context.Context, n int) ([]int64, error) {
+	current := time.Now().UTC().Truncate(time.Hour)
+	var cmds []*redis.ZSliceCmd
+	_, err := s.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, hour := range []time.Time{current.Add(-time.Hour), current} {
+			cmds = append(cmds, pipe.ZRevRangeWithScores(ctx, hotUsersKey(hour), 0, int64(n)-1))
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	scores := make(map[int64]float64)
+	for _, cmd := range cmds {
+		for _, z := range cmd.Val() {
+			id, err := strconv.ParseInt(fmt.Sprint(z.Member), 10, 64)
+			if err != nil {
+				continue
+			}
+			scores[id] += z.Score
+		}
+	}
+	return rankHotUsers(scores, n), nil
+}
+
+// rankHotUsers orders ids by score, ties by id so the order is stable.
+func rankHotUsers(scores map[int64]float64, n int) []int64 {
+	ids := make([]int64, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return ids
+}
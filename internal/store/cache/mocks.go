@@ -3,16 +3,40 @@ package cache
 import (
 	"context"
 	"gopher_social/internal/store"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
 
 func NewMockStore() *Storage {
 	return &Storage{
-		Users: &MockUserStore{},
+		Users:      &MockUserStore{},
+		Responses:  &MockResponseStore{},
+		Hashtags:   &MockHashtagStore{},
+		HotUsers:   &MockHotUserStore{},
+		Challenges: &MockChallengeStore{},
 	}
 }
 
+// MockChallengeStore keeps challenges in memory and ignores expiry.
+type MockChallengeStore struct {
+	data map[string][]byte
+}
+
+func (m *MockChallengeStore) Set(ctx context.Context, key string, data []byte, exp time.Duration) error {
+	if m.data == nil {
+		m.data = make(map[string][]byte)
+	}
+	m.data[key] = data
+	return nil
+}
+
+func (m *MockChallengeStore) Pop(ctx context.Context, key string) ([]byte, error) {
+	data := m.data[key]
+	delete(m.data, key)
+	return data, nil
+}
+
 type MockUserStore struct {
 	mock.Mock
 }
@@ -30,3 +54,74 @@ func (m *MockUserStore) Set(ctx context.Context, user *store.User) error {
 func (m *MockUserStore) Delete(context.Context, int64) error {
 	return nil
 }
+
+// MockResponseStore keeps responses in memory and ignores expiry.
+type MockResponseStore struct {
+	Bodies map[string][]byte
+}
+
+func (m *MockResponseStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.Bodies[key], nil
+}
+
+func (m *MockResponseStore) Set(ctx context.Context, key string, body []byte, exp time.Duration) error {
+	if m.Bodies == nil {
+		m.Bodies = make(map[string][]byte)
+	}
+	m.Bodies[key] = body
+	return nil
+}
+
+// MockHashtagStore keeps buckets and the trending ranking in memory.
+type MockHashtagStore struct {
+	Buckets  map[time.Time]map[string]int
+	Trending []store.TrendingTag
+}
+
+func (m *MockHashtagStore) SetBucket(ctx context.Context, start time.Time, counts map[string]int, exp time.Duration) error {
+	if m.Buckets == nil {
+		m.Buckets = make(map[time.Time]map[string]int)
+	}
+	m.Buckets[start] = counts
+	return nil
+}
+
+func (m *MockHashtagStore) GetBuckets(ctx context.Context, starts []time.Time) ([]map[string]int, error) {
+	buckets := make([]map[string]int, len(starts))
+	for i, start := range starts {
+		buckets[i] = m.Buckets[start]
+	}
+	return buckets, nil
+}
+
+func (m *MockHashtagStore) GetTrending(ctx context.Context) ([]store.TrendingTag, error) {
+	return m.Trending, nil
+}
+
+func (m *MockHashtagStore) SetTrending(ctx context.Context, tags []store.TrendingTag, exp time.Duration) error {
+	m.Trending = tags
+	return nil
+}
+
+// MockHotUserStore keeps a single ranking in memory, ignoring the hours.
+type MockHotUserStore struct {
+	Counts map[int64]int
+}
+
+func (m *MockHotUserStore) Record(ctx context.Context, counts map[int64]int) error {
+	if m.Counts == nil {
+		m.Counts = make(map[int64]int)
+	}
+	for id, n := range counts {
+		m.Counts[id] += n
+	}
+	return nil
+}
+
+func (m *MockHotUserStore) Top(ctx context.Context, n int) ([]int64, error) {
+	scores := make(map[int64]float64, len(m.Counts))
+	for id, c := range m.Counts {
+		scores[id] = float64(c)
+	}
+	return rankHotUsers(scores, n), nil
+}
@@ -0,0 +1,210 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"gopher_social/internal/breaker"
+	"gopher_social/internal/store"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ReadErrors counts failed cache reads per store, published at /debug/vars.
+var ReadErrors = expvar.NewMap("cache_read_errors")
+
+// WithBreaker routes every Redis call of s through b, so an unreachable Redis
+// fails fast with breaker.ErrOpen instead of each request waiting on it.
+//
+// Cache reads never fail through the returned storage: a read error is
+// counted, logged and reported as a miss so callers fall back to Postgres.
+// Writes still return their errors.
+func WithBreaker(s *Storage, b *breaker.Breaker, logger *zap.SugaredLogger) *Storage {
+	return &Storage{
+		Users:         &breakerUserStore{next: s.Users, b: b, logger: logger},
+		Challenges:    &breakerChallengeStore{next: s.Challenges, b: b},
+		FeedPositions: &breakerFeedPositionStore{next: s.FeedPositions, b: b, logger: logger},
+		Responses:     &breakerResponseStore{next: s.Responses, b: b, logger: logger},
+		Hashtags:      &breakerHashtagStore{next: s.Hashtags, b: b, logger: logger},
+		HotUsers:      &breakerHotUserStore{next: s.HotUsers, b: b},
+	}
+}
+
+// readMiss records a failed read. While the breaker is open every read fails
+// the same way, those are only logged at debug level.
+func readMiss(logger *zap.SugaredLogger, store string, err error) {
+	ReadErrors.Add(store, 1)
+	if errors.Is(err, breaker.ErrOpen) {
+		logger.Debugw("cache read skipped", "store", store, "error", err.Error())
+		return
+	}
+	logger.Warnw("cache read failed, treating as a miss", "store", store, "error", err.Error())
+}
+
+type breakerUserStore struct {
+	next interface {
+		Get(context.Context, int64) (*store.User, error)
+		Set(context.Context, *store.User) error
+		Delete(context.Context, int64) error
+	}
+	b      *breaker.Breaker
+	logger *zap.SugaredLogger
+}
+
+func (s *breakerUserStore) Get(ctx context.Context, userID int64) (*store.User, error) {
+	var user *store.User
+	err := s.b.Do(func() (err error) {
+		user, err = s.next.Get(ctx, userID)
+		return err
+	})
+	if err != nil {
+		readMiss(s.logger, "users", err)
+		return nil, nil
+	}
+	return user, nil
+}
+
+func (s *breakerUserStore) Set(ctx context.Context, user *store.User) error {
+	return s.b.Do(func() error { return s.next.Set(ctx, user) })
+}
+
+func (s *breakerUserStore) Delete(ctx context.Context, userID int64) error {
+	return s.b.Do(func() error { return s.next.Delete(ctx, userID) })
+}
+
+type breakerChallengeStore struct {
+	next interface {
+		Set(ctx context.Context, key string, data []byte, exp time.Duration) error
+		Pop(ctx context.Context, key string) ([]byte, error)
+	}
+	b *breaker.Breaker
+}
+
+func (s *breakerChallengeStore) Set(ctx context.Context, key string, data []byte, exp time.Duration) error {
+	return s.b.Do(func() error { return s.next.Set(ctx, key, data, exp) })
+}
+
+func (s *breakerChallengeStore) Pop(ctx context.Context, key string) (data []byte, err error) {
+	err = s.b.Do(func() error {
+		data, err = s.next.Pop(ctx, key)
+		return err
+	})
+	return data, err
+}
+
+type breakerFeedPositionStore struct {
+	next interface {
+		Get(ctx context.Context, userID int64) (*store.FeedPosition, error)
+		Set(ctx context.Context, userID int64, position *store.FeedPosition) error
+	}
+	b      *breaker.Breaker
+	logger *zap.SugaredLogger
+}
+
+func (s *breakerFeedPositionStore) Get(ctx context.Context, userID int64) (*store.FeedPosition, error) {
+	var position *store.FeedPosition
+	err := s.b.Do(func() (err error) {
+		position, err = s.next.Get(ctx, userID)
+		return err
+	})
+	if err != nil {
+		readMiss(s.logger, "feed_positions", err)
+		return nil, nil
+	}
+	return position, nil
+}
+
+func (s *breakerFeedPositionStore) Set(ctx context.Context, userID int64, position *store.FeedPosition) error {
+	return s.b.Do(func() error { return s.next.Set(ctx, userID, position) })
+}
+
+type breakerResponseStore struct {
+	next interface {
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, body []byte, exp time.Duration) error
+	}
+	b      *breaker.Breaker
+	logger *zap.SugaredLogger
+}
+
+func (s *breakerResponseStore) Get(ctx context.Context, key string) ([]byte, error) {
+	var body []byte
+	err := s.b.Do(func() (err error) {
+		body, err = s.next.Get(ctx, key)
+		return err
+	})
+	if err != nil {
+		readMiss(s.logger, "responses", err)
+		return nil, nil
+	}
+	return body, nil
+}
+
+func (s *breakerResponseStore) Set(ctx context.Context, key string, body []byte, exp time.Duration) error {
+	return s.b.Do(func() error { return s.next.Set(ctx, key, body, exp) })
+}
+
+type breakerHashtagStore struct {
+	next interface {
+		SetBucket(ctx context.Context, start time.Time, counts map[string]int, exp time.Duration) error
+		GetBuckets(ctx context.Context, starts []time.Time) ([]map[string]int, error)
+		GetTrending(ctx context.Context) ([]store.TrendingTag, error)
+		SetTrending(ctx context.Context, tags []store.TrendingTag, exp time.Duration) error
+	}
+	b      *breaker.Breaker
+	logger *zap.SugaredLogger
+}
+
+func (s *breakerHashtagStore) SetBucket(ctx context.Context, start time.Time, counts map[string]int, exp time.Duration) error {
+	return s.b.Do(func() error { return s.next.SetBucket(ctx, start, counts, exp) })
+}
+
+// GetBuckets is only used by the trending job, which has nothing to fall
+// back to, so errors are returned rather than turned into misses.
+func (s *breakerHashtagStore) GetBuckets(ctx context.Context, starts []time.Time) (buckets []map[string]int, err error) {
+	err = s.b.Do(func() (err error) {
+		buckets, err = s.next.GetBuckets(ctx, starts)
+		return err
+	})
+	return buckets, err
+}
+
+func (s *breakerHashtagStore) GetTrending(ctx context.Context) ([]store.TrendingTag, error) {
+	var tags []store.TrendingTag
+	err := s.b.Do(func() (err error) {
+		tags, err = s.next.GetTrending(ctx)
+		return err
+	})
+	if err != nil {
+		readMiss(s.logger, "hashtags", err)
+		return nil, nil
+	}
+	return tags, nil
+}
+
+func (s *breakerHashtagStore) SetTrending(ctx context.Context, tags []store.TrendingTag, exp time.Duration) error {
+	return s.b.Do(func() error { return s.next.SetTrending(ctx, tags, exp) })
+}
+
+type breakerHotUserStore struct {
+	next interface {
+		Record(ctx context.Context, counts map[int64]int) error
+		Top(ctx context.Context, n int) ([]int64, error)
+	}
+	b *breaker.Breaker
+}
+
+func (s *breakerHotUserStore) Record(ctx context.Context, counts map[int64]int) error {
+	return s.b.Do(func() error { return s.next.Record(ctx, counts) })
+}
+
+// Top is only used to warm the cache, which has nothing to fall back to, so
+// errors are returned rather than turned into misses.
+func (s *breakerHotUserStore) Top(ctx context.Context, n int) (ids []int64, err error) {
+	err = s.b.Do(func() error {
+		ids, err = s.next.Top(ctx, n)
+		return err
+	})
+	return ids, err
+}
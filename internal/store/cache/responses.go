@@ -0,0 +1,26 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ResponseStore holds rendered response bodies of public pages, keyed by
+// request URI.
+type ResponseStore struct {
+	rdb *redis.Client
+}
+
+func (s *ResponseStore) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.rdb.Get(ctx, "response-"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (s *ResponseStore) Set(ctx context.Context, key string, body []byte, exp time.Duration) error {
+	return s.rdb.SetEX(ctx, "response-"+key, body, exp).Err()
+}
@@ -3,20 +3,51 @@ package cache
 import (
 	"context"
 	"gopher_social/internal/store"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// Storage is the Redis cache. Get methods return nil, nil on a miss.
 type Storage struct {
 	Users interface {
 		Get(context.Context, int64) (*store.User, error)
 		Set(context.Context, *store.User) error
 		Delete(context.Context, int64) error
 	}
+	Challenges interface {
+		Set(ctx context.Context, key string, data []byte, exp time.Duration) error
+		Pop(ctx context.Context, key string) ([]byte, error)
+	}
+	FeedPositions interface {
+		Get(ctx context.Context, userID int64) (*store.FeedPosition, error)
+		Set(ctx context.Context, userID int64, position *store.FeedPosition) error
+	}
+	Responses interface {
+		Get(ctx context.Context, key string) ([]byte, error)
+		Set(ctx context.Context, key string, body []byte, exp time.Duration) error
+	}
+	Hashtags interface {
+		SetBucket(ctx context.Context, start time.Time, counts map[string]int, exp time.Duration) error
+		GetBuckets(ctx context.Context, starts []time.Time) ([]map[string]int, error)
+		GetTrending(ctx context.Context) ([]store.TrendingTag, error)
+		SetTrending(ctx context.Context, tags []store.TrendingTag, exp time.Duration) error
+	}
+	HotUsers interface {
+		Record(ctx context.Context, counts map[int64]int) error
+		Top(ctx context.Context, n int) ([]int64, error)
+	}
 }
 
-func NewRedisStorage(rdb *redis.Client) *Storage {
+// NewRedisStorage builds the cache. compat keeps values readable by the
+// previous release, see versionedKeys.
+func NewRedisStorage(rdb *redis.Client, compat bool) *Storage {
 	return &Storage{
-		Users: &UserStore{rdb: rdb},
+		Users:         &UserStore{keys: versionedKeys{rdb: rdb, prefix: "user", version: UserSchema, compat: compat}},
+		Challenges:    &ChallengeStore{rdb: rdb},
+		FeedPositions: &FeedPositionStore{keys: versionedKeys{rdb: rdb, prefix: "feed-position", version: FeedPositionSchema, compat: compat}},
+		Responses:     &ResponseStore{rdb: rdb},
+		Hashtags:      &HashtagStore{rdb: rdb},
+		HotUsers:      &HotUserStore{rdb: rdb},
 	}
 }
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Versions of the value formats cached for each kind of key. Bump one when
+// the cached struct changes, so instances of the old and new releases stop
+// reading each other's values during a rolling deploy.
+const (
+	UserSchema         = 1
+	FeedPositionSchema = 1
+)
+
+// versionedKeys stores values under keys carrying their format version.
+//
+// With compat on, writes also go to the previous version's key and reads
+// fall back to it on a miss, so both releases of a rolling deploy keep a
+// warm cache. It suits additive changes, since values of the previous
+// format decode with the new fields zero; turn it off once every instance
+// runs the new release.
+type versionedKeys struct {
+	rdb     *redis.Client
+	prefix  string
+	version int
+	compat  bool
+}
+
+// key is the key of id in the given format version. Version 1 keeps the
+// keys written before formats were versioned.
+func (v versionedKeys) key(version int, id any) string {
+	if version <= 1 {
+		return fmt.Sprintf("%s-%v", v.prefix, id)
+	}
+	return fmt.Sprintf("%s-v%d-%v", v.prefix, version, id)
+}
+
+// keys lists the current key of id first, then the previous one when
+// compat is on.
+func (v versionedKeys) keys(id any) []string {
+	keys := []string{v.key(v.version, id)}
+	if v.compat && v.version > 1 {
+		keys = append(keys, v.key(v.version-1, id))
+	}
+	return keys
+}
+
+// get returns nil, nil on a miss.
+func (v versionedKeys) get(ctx context.Context, id any) ([]byte, error) {
+	for _, key := range v.keys(id) {
+		data, err := v.rdb.Get(ctx, key).Bytes()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func (v versionedKeys) set(ctx context.Context, id any, data []byte, exp time.Duration) error {
+	keys := v.keys(id)
+	if len(keys) == 1 {
+		return v.rdb.SetEX(ctx, keys[0], data, exp).Err()
+	}
+	_, err := v.rdb.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for _, key := range keys {
+			p.SetEX(ctx, key, data, exp)
+		}
+		return nil
+	})
+	return err
+}
+
+// del always drops the previous version's key too, an instance of the old
+// release may have written it.
+func (v versionedKeys) del(ctx context.Context, id any) error {
+	keys := []string{v.key(v.version, id)}
+	if v.version > 1 {
+		keys = append(keys, v.key(v.version-1, id))
+	}
+	return v.rdb.Del(ctx, keys...).Err()
+}
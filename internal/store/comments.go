@@ -3,30 +3,47 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 type Comment struct {
-	ID        int64  `json:"id"`
-	PostID    int64  `json:"post_id"`
-	UserID    int64  `json:"user_id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
-	User      User   `json:"user"`
+	ID     int64 `json:"id"`
+	PostID int64 `json:"post_id"`
+	// ParentID is set on replies to another comment.
+	ParentID *int64 `json:"parent_id"`
+	// Hidden is set when the post author hid the comment. Hidden comments
+	// are only returned to their author and to moderators.
+	Hidden bool `json:"hidden"`
+	// Answer marks a top level comment answering a question post, Accepted
+	// the answer the question's author accepted.
+	Answer   bool `json:"answer"`
+	Accepted bool `json:"accepted"`
+	// Reactions counts the emoji reactions on the comment by type.
+	Reactions   map[string]int `json:"reactions,omitempty"`
+	UserID      int64          `json:"user_id"`
+	Content     string         `json:"content"`
+	ContentHTML string         `json:"content_html"`
+	CreatedAt   time.Time      `json:"created_at"`
+	User        User           `json:"user"`
 }
 
 type CommentStore struct {
 	db *sql.DB
 }
 
-func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment, error) {
+// GetByPostID lists the comments of a post as seen by viewerID: hidden
+// comments are left out unless the viewer wrote them or includeHidden is set.
+// Authors are left for the caller to hydrate with Users.GetByIDs.
+func (s *CommentStore) GetByPostID(ctx context.Context, postID, viewerID int64, includeHidden bool) ([]Comment, error) {
 	query := `
-	SELECT c.id,c.post_id,c.user_id,c.content,c.created_at,u.username,u.id FROM comments c 
-	JOIN users u
-	ON c.user_id = u.id
-	where c.post_id = $1
+	SELECT c.id,c.post_id,c.parent_id,c.user_id,c.content,c.created_at,c.hidden_at IS NOT NULL,c.is_answer FROM comments c
+	where c.post_id = $1 AND (c.hidden_at IS NULL OR c.user_id = $2 OR $3)
 	ORDER BY c.created_at DESC;
 	`
-	rows, err := s.db.QueryContext(ctx, query, postID)
+	rows, err := s.db.QueryContext(ctx, query, postID, viewerID, includeHidden)
 	if err != nil {
 		return nil, err
 	}
@@ -34,8 +51,7 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 	comments := []Comment{}
 	for rows.Next() {
 		var c Comment
-		c.User = User{}
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
+		err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.UserID, &c.Content, &c.CreatedAt, &c.Hidden, &c.Answer)
 		if err != nil {
 			return nil, err
 		}
@@ -45,23 +61,95 @@ func (s *CommentStore) GetByPostID(ctx context.Context, postID int64) ([]Comment
 	return comments, nil
 }
 
+// Create inserts the comment. A trigger keeps the post's comments_count in
+// step, so feeds can read the count without a join.
 func (s *CommentStore) Create(ctx context.Context, comment *Comment) error {
 	query := `
-	INSERT INTO comments (post_id,user_id,content)
-	VALUES ($1, $2, $3)
+	INSERT INTO comments (post_id,parent_id,user_id,content,is_answer)
+	VALUES ($1, $2, $3, $4, $5)
 	RETURNING id, created_at
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	return s.db.QueryRowContext(
 		ctx,
 		query,
 		comment.PostID,
+		comment.ParentID,
 		comment.UserID,
-		comment.Content).Scan(&comment.ID, &comment.CreatedAt)
+		comment.Content,
+		comment.Answer).Scan(&comment.ID, &comment.CreatedAt)
+}
+
+func (s *CommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	query := `SELECT id, post_id, parent_id, user_id, content, created_at, hidden_at IS NOT NULL, is_answer FROM comments WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var c Comment
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&c.ID, &c.PostID, &c.ParentID, &c.UserID, &c.Content, &c.CreatedAt, &c.Hidden, &c.Answer)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// GetPreviewsByPostIDs returns the most recent top level comment of each post
+// in one query, keyed by post ID. Posts without comments are absent.
+func (s *CommentStore) GetPreviewsByPostIDs(ctx context.Context, postIDs []int64) (map[int64]Comment, error) {
+	query := `
+	SELECT DISTINCT ON (c.post_id) c.id, c.post_id, c.parent_id, c.user_id, c.content, c.created_at
+	FROM comments c
+	WHERE c.post_id = ANY($1) AND c.parent_id IS NULL AND c.hidden_at IS NULL
+	ORDER BY c.post_id, c.created_at DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	previews := make(map[int64]Comment, len(postIDs))
+	for rows.Next() {
+		var c Comment
+		err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.UserID, &c.Content, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		previews[c.PostID] = c
+	}
+	return previews, rows.Err()
+}
+
+// SetHidden hides or unhides a comment, recording who hid it.
+func (s *CommentStore) SetHidden(ctx context.Context, commentID int64, hidden bool, hiddenBy int64) error {
+	query := `
+	UPDATE comments
+	SET hidden_at = CASE WHEN $2 THEN now() END, hidden_by = CASE WHEN $2 THEN $3::bigint END
+	WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, commentID, hidden, hiddenBy)
 	if err != nil {
 		return err
 	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
 	return nil
 }
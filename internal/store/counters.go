@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Counters of a post. Both live on the posts row, while a post is hot their
+// updates are spread over post_counter_shards and folded back by Compact.
+const (
+	CounterReactions = "reactions"
+	CounterViews     = "views"
+)
+
+var (
+	counterShards = 16
+	hotPosts      = &hotTracker{}
+)
+
+// SetCounterSharding spreads the counter updates of a post over shards rows
+// once this instance sees more than threshold of them within a minute, so a
+// viral post does not serialize every reaction and view on its posts row.
+// Zero disables sharding. It must be called before the storage is used.
+func SetCounterSharding(threshold, shards int) {
+	hotPosts = &hotTracker{threshold: threshold}
+	counterShards = max(shards, 1)
+}
+
+// hotTracker counts counter updates per post in the current minute.
+type hotTracker struct {
+	threshold int
+	mu        sync.Mutex
+	minute    time.Time
+	counts    map[int64]int
+}
+
+// hit records an update of postID and tells whether the post is hot.
+func (h *hotTracker) hit(postID int64) bool {
+	if h.threshold <= 0 {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if now := time.Now().Truncate(time.Minute); !now.Equal(h.minute) {
+		h.minute = now
+		h.counts = make(map[int64]int)
+	}
+	h.counts[postID]++
+	return h.counts[postID] > h.threshold
+}
+
+// shardUpsert adds the (post_id, delta) rows selected by from to a random
+// shard of counter, passed as argument number shardArg.
+func shardUpsert(counter, from string, shardArg int) string {
+	return fmt.Sprintf(`
+	INSERT INTO post_counter_shards (post_id, counter, shard, delta)
+	SELECT post_id, '%s', $%d::smallint, delta FROM (%s) AS d(post_id, delta)
+	ON CONFLICT (post_id, counter, shard) DO UPDATE SET delta = post_counter_shards.delta + EXCLUDED.delta
+	`, counter, shardArg, from)
+}
+
+func randomShard() int {
+	return rand.IntN(counterShards)
+}
+
+type CounterStore struct {
+	db *sql.DB
+}
+
+// Compact folds every shard back into the counters on the posts rows and
+// returns how many posts it updated. Writers hitting a shard being folded
+// wait for it and start a new one.
+func (s *CounterStore) Compact(ctx context.Context) (int, error) {
+	query := `
+	WITH drained AS (
+		DELETE FROM post_counter_shards RETURNING post_id, counter, delta
+	), sums AS (
+		SELECT post_id,
+			COALESCE(SUM(delta) FILTER (WHERE counter = 'reactions'), 0) AS reactions,
+			COALESCE(SUM(delta) FILTER (WHERE counter = 'views'), 0) AS views
+		FROM drained GROUP BY post_id
+	)
+	UPDATE posts p
+	SET reactions_count = p.reactions_count + s.reactions, views_count = p.views_count + s.views
+	FROM sums s
+	WHERE p.id = s.post_id
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
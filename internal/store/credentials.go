@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Credential is a WebAuthn (passkey) credential. Data holds the serialized
+// credential record as produced by the webauthn library so the store doesn't
+// need to know its shape.
+type Credential struct {
+	ID         []byte     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `json:"name"`
+	Data       []byte     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+type CredentialStore struct {
+	db *sql.DB
+}
+
+func (s *CredentialStore) Create(ctx context.Context, credential *Credential) error {
+	query := `
+	INSERT INTO user_credentials (id, user_id, name, data)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(
+		ctx,
+		query,
+		credential.ID,
+		credential.UserID,
+		credential.Name,
+		credential.Data,
+	).Scan(&credential.CreatedAt)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" {
+			return ErrConflict
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *CredentialStore) GetByUserID(ctx context.Context, userID int64) ([]Credential, error) {
+	query := `
+	SELECT id, user_id, name, data, created_at, last_used_at
+	FROM user_credentials
+	WHERE user_id = $1
+	ORDER BY created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credentials := []Credential{}
+	for rows.Next() {
+		var c Credential
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Name, &c.Data, &c.CreatedAt, &c.LastUsedAt); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, rows.Err()
+}
+
+// Touch stores the updated credential record (sign count, flags) after a
+// successful login.
+func (s *CredentialStore) Touch(ctx context.Context, id []byte, data []byte) error {
+	query := `
+	UPDATE user_credentials
+	SET data = $1, last_used_at = NOW()
+	WHERE id = $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, data, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *CredentialStore) Delete(ctx context.Context, userID int64, id []byte) error {
+	query := `DELETE FROM user_credentials WHERE user_id = $1 AND id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, id)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Crosspost delivery statuses. Deliveries stay pending while retried and
+// fail for good once out of attempts.
+const (
+	CrosspostPending   = "pending"
+	CrosspostDelivered = "delivered"
+	CrosspostFailed    = "failed"
+)
+
+// CrosspostAccount is a user's account on another network their public
+// posts are mirrored to while Enabled. A user connects one account per
+// network.
+type CrosspostAccount struct {
+	ID      int64  `json:"id"`
+	UserID  int64  `json:"user_id"`
+	Network string `json:"network"`
+	Server  string `json:"server"`
+	Handle  string `json:"handle"`
+	// Secret is the access token or app password, it is never shown back.
+	Secret    string    `json:"-"`
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CrosspostDelivery tracks mirroring one post to one account.
+type CrosspostDelivery struct {
+	ID        int64     `json:"id"`
+	PostID    int64     `json:"post_id"`
+	Network   string    `json:"network"`
+	Handle    string    `json:"handle"`
+	Status    string    `json:"status"`
+	Attempts  int       `json:"attempts"`
+	RemoteURL string    `json:"remote_url,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// NextAttemptAt is when a pending delivery is tried again.
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// Account is filled by Due for the job to publish with.
+	Account CrosspostAccount `json:"-"`
+}
+
+type CrosspostStore struct {
+	db *sql.DB
+}
+
+func (s *CrosspostStore) Accounts(ctx context.Context, userID int64) ([]CrosspostAccount, error) {
+	query := `
+	SELECT id, user_id, network, server, handle, enabled, created_at
+	FROM crosspost_accounts
+	WHERE user_id = $1
+	ORDER BY network
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []CrosspostAccount{}
+	for rows.Next() {
+		var a CrosspostAccount
+		if err := rows.Scan(&a.ID, &a.UserID, &a.Network, &a.Server, &a.Handle, &a.Enabled, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// Connect stores the account, replacing the one the user had on the network.
+func (s *CrosspostStore) Connect(ctx context.Context, acct *CrosspostAccount) error {
+	query := `
+	INSERT INTO crosspost_accounts (user_id, network, server, handle, secret, enabled)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (user_id, network) DO UPDATE
+	SET server = EXCLUDED.server, handle = EXCLUDED.handle, secret = EXCLUDED.secret, enabled = EXCLUDED.enabled
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query,
+		acct.UserID, acct.Network, acct.Server, acct.Handle, acct.Secret, acct.Enabled,
+	).Scan(&acct.ID, &acct.CreatedAt)
+}
+
+// SetEnabled turns mirroring to the user's account on the network on or
+// off. ErrRecordNotFound means they have none there.
+func (s *CrosspostStore) SetEnabled(ctx context.Context, userID int64, network string, enabled bool) error {
+	query := `UPDATE crosspost_accounts SET enabled = $3 WHERE user_id = $1 AND network = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, network, enabled)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Disconnect removes the account along with its pending deliveries.
+func (s *CrosspostStore) Disconnect(ctx context.Context, userID int64, network string) error {
+	query := `DELETE FROM crosspost_accounts WHERE user_id = $1 AND network = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, network)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Enqueue queues the post for every enabled account of its author and
+// returns how many deliveries were queued.
+func (s *CrosspostStore) Enqueue(ctx context.Context, postID, userID int64) (int, error) {
+	query := `
+	INSERT INTO crosspost_deliveries (post_id, account_id)
+	SELECT $1, id FROM crosspost_accounts WHERE user_id = $2 AND enabled
+	ON CONFLICT (post_id, account_id) DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, postID, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// Due returns pending deliveries whose next attempt is due, oldest first.
+// Deliveries to accounts disabled since are left waiting.
+func (s *CrosspostStore) Due(ctx context.Context, limit int) ([]CrosspostDelivery, error) {
+	query := `
+	SELECT d.id, d.post_id, d.status, d.attempts, d.updated_at, d.next_attempt_at,
+		a.id, a.user_id, a.network, a.server, a.handle, a.secret
+	FROM crosspost_deliveries d
+	JOIN crosspost_accounts a ON a.id = d.account_id
+	WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND a.enabled
+	ORDER BY d.next_attempt_at
+	LIMIT $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []CrosspostDelivery
+	for rows.Next() {
+		var d CrosspostDelivery
+		a := &d.Account
+		if err := rows.Scan(&d.ID, &d.PostID, &d.Status, &d.Attempts, &d.UpdatedAt, &d.NextAttemptAt,
+			&a.ID, &a.UserID, &a.Network, &a.Server, &a.Handle, &a.Secret); err != nil {
+			return nil, err
+		}
+		a.Enabled = true
+		d.Network, d.Handle = a.Network, a.Handle
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+func (s *CrosspostStore) Delivered(ctx context.Context, id int64, remoteURL string) error {
+	query := `
+	UPDATE crosspost_deliveries
+	SET status = 'delivered', attempts = attempts + 1, remote_url = $2, error = '', updated_at = NOW()
+	WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, remoteURL)
+	return err
+}
+
+// Failed records a failed attempt. The delivery is tried again at retryAt,
+// or fails for good when retryAt is nil.
+func (s *CrosspostStore) Failed(ctx context.Context, id int64, reason string, retryAt *time.Time) error {
+	query := `
+	UPDATE crosspost_deliveries
+	SET status = CASE WHEN $3::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+		attempts = attempts + 1, error = $2, next_attempt_at = COALESCE($3, next_attempt_at), updated_at = NOW()
+	WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, reason, retryAt)
+	return err
+}
+
+// ForPosts returns the deliveries of the posts keyed by post ID.
+func (s *CrosspostStore) ForPosts(ctx context.Context, postIDs []int64) (map[int64][]CrosspostDelivery, error) {
+	query := `
+	SELECT d.id, d.post_id, a.network, a.handle, d.status, d.attempts, d.remote_url, d.error, d.updated_at, d.next_attempt_at
+	FROM crosspost_deliveries d
+	JOIN crosspost_accounts a ON a.id = d.account_id
+	WHERE d.post_id = ANY($1)
+	ORDER BY a.network
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make(map[int64][]CrosspostDelivery)
+	for rows.Next() {
+		var d CrosspostDelivery
+		if err := rows.Scan(&d.ID, &d.PostID, &d.Network, &d.Handle, &d.Status, &d.Attempts, &d.RemoteURL, &d.Error, &d.UpdatedAt, &d.NextAttemptAt); err != nil {
+			return nil, err
+		}
+		deliveries[d.PostID] = append(deliveries[d.PostID], d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// EmailPostAddress is the secret part of the address a user emails posts
+// to. Mail to it only becomes a post when sent from the user's own email.
+type EmailPostAddress struct {
+	UserID    int64     `json:"-"`
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type EmailPostStore struct {
+	db *sql.DB
+}
+
+func (s *EmailPostStore) Get(ctx context.Context, userID int64) (*EmailPostAddress, error) {
+	query := `SELECT user_id, token, created_at FROM email_post_addresses WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var a EmailPostAddress
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&a.UserID, &a.Token, &a.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRecordNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Rotate gives the user a new address, the previous one stops working.
+func (s *EmailPostStore) Rotate(ctx context.Context, userID int64) (*EmailPostAddress, error) {
+	token, err := newSecret("", 12)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO email_post_addresses (user_id, token)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token, created_at = NOW()
+	RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	a := &EmailPostAddress{UserID: userID, Token: token}
+	if err := s.db.QueryRowContext(ctx, query, userID, token).Scan(&a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete turns posting by email off. ErrRecordNotFound means it was off.
+func (s *EmailPostStore) Delete(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM email_post_addresses WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// UserID returns the owner of the address token, ErrRecordNotFound if it
+// was rotated or never issued.
+func (s *EmailPostStore) UserID(ctx context.Context, token string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var userID int64
+	err := s.db.QueryRowContext(ctx, `SELECT user_id FROM email_post_addresses WHERE token = $1`, token).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrRecordNotFound
+	}
+	return userID, err
+}
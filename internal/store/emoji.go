@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Emoji is an instance specific image written :Shortcode: in posts and
+// comments. URL is filled in by the API.
+type Emoji struct {
+	Shortcode   string    `json:"shortcode"`
+	URL         string    `json:"url"`
+	BlobKey     string    `json:"-"`
+	ContentType string    `json:"-"`
+	CreatedBy   int64     `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type EmojiStore struct {
+	db *sql.DB
+}
+
+const emojiColumns = `shortcode, blob_key, content_type, COALESCE(created_by, 0), created_at`
+
+func scanEmoji(row interface{ Scan(...any) error }, e *Emoji) error {
+	return row.Scan(&e.Shortcode, &e.BlobKey, &e.ContentType, &e.CreatedBy, &e.CreatedAt)
+}
+
+// Create stores the emoji, ErrConflict means the shortcode is taken.
+func (s *EmojiStore) Create(ctx context.Context, e *Emoji) error {
+	query := `
+	INSERT INTO custom_emoji (shortcode, blob_key, content_type, created_by)
+	VALUES ($1, $2, $3, $4)
+	RETURNING created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, e.Shortcode, e.BlobKey, e.ContentType, e.CreatedBy).Scan(&e.CreatedAt)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+// List returns every emoji ordered by shortcode.
+func (s *EmojiStore) List(ctx context.Context) ([]Emoji, error) {
+	query := `SELECT ` + emojiColumns + ` FROM custom_emoji ORDER BY shortcode`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	emoji := []Emoji{}
+	for rows.Next() {
+		var e Emoji
+		if err := scanEmoji(rows, &e); err != nil {
+			return nil, err
+		}
+		emoji = append(emoji, e)
+	}
+	return emoji, rows.Err()
+}
+
+func (s *EmojiStore) Get(ctx context.Context, shortcode string) (*Emoji, error) {
+	query := `SELECT ` + emojiColumns + ` FROM custom_emoji WHERE shortcode = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var e Emoji
+	if err := scanEmoji(s.db.QueryRowContext(ctx, query, shortcode), &e); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Delete removes the emoji and returns it so its image can be deleted too.
+func (s *EmojiStore) Delete(ctx context.Context, shortcode string) (*Emoji, error) {
+	query := `DELETE FROM custom_emoji WHERE shortcode = $1 RETURNING ` + emojiColumns
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var e Emoji
+	if err := scanEmoji(s.db.QueryRowContext(ctx, query, shortcode), &e); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RSVP answers to an event. Both are reminded before it starts.
+const (
+	RSVPGoing      = "going"
+	RSVPInterested = "interested"
+)
+
+// Event is a meetup announced by a post of kind PostKindEvent, so it reaches
+// followers' feeds like any post. ID is the post's ID, Title and Description
+// are the post's title and content.
+type Event struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	StartsAt    time.Time  `json:"starts_at"`
+	EndsAt      *time.Time `json:"ends_at"`
+	Location    string     `json:"location,omitempty"`
+	OnlineURL   string     `json:"online_url,omitempty"`
+	CanceledAt  *time.Time `json:"canceled_at,omitempty"`
+	Going       int        `json:"going"`
+	Interested  int        `json:"interested"`
+	// RSVP is the viewer's answer, empty if they haven't answered.
+	RSVP      string    `json:"rsvp,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EventAttendee is a user who answered an event.
+type EventAttendee struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Status   string `json:"status"`
+}
+
+// EventReminder is an event about to start with the users to remind.
+type EventReminder struct {
+	EventID     int64
+	OrganizerID int64
+	AttendeeIDs []int64
+}
+
+type EventStore struct {
+	db    *sql.DB
+	posts *PostStore
+}
+
+const eventColumns = `p.id, p.user_id, p.title, p.content, e.starts_at, e.ends_at, e.location, e.online_url, e.canceled_at,
+	(SELECT COUNT(*) FROM event_rsvps r WHERE r.event_id = e.post_id AND r.status = 'going'),
+	(SELECT COUNT(*) FROM event_rsvps r WHERE r.event_id = e.post_id AND r.status = 'interested'),
+	COALESCE((SELECT status FROM event_rsvps r WHERE r.event_id = e.post_id AND r.user_id = $1), ''),
+	p.created_at`
+
+// eventVisible leaves out events whose post or organizer is under legal hold.
+const eventVisible = `NOT p.on_hold AND NOT u.on_hold`
+
+func scanEvent(row interface{ Scan(...any) error }, e *Event) error {
+	return row.Scan(&e.ID, &e.UserID, &e.Title, &e.Description, &e.StartsAt, &e.EndsAt, &e.Location, &e.OnlineURL,
+		&e.CanceledAt, &e.Going, &e.Interested, &e.RSVP, &e.CreatedAt)
+}
+
+// Create publishes the post announcing the event and the event itself in one
+// transaction. The event takes the post's ID.
+func (s *EventStore) Create(ctx context.Context, post *Post, e *Event) error {
+	post.Kind = PostKindEvent
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.posts.create(ctx, tx, post); err != nil {
+			return err
+		}
+		query := `
+		INSERT INTO events (post_id, starts_at, ends_at, location, online_url)
+		VALUES ($1, $2, $3, $4, $5)
+		`
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+		if _, err := tx.ExecContext(ctx, query, post.ID, e.StartsAt, e.EndsAt, e.Location, e.OnlineURL); err != nil {
+			return err
+		}
+		e.ID, e.UserID, e.Title, e.Description, e.CreatedAt = post.ID, post.UserID, post.Title, post.Content, post.CreatedAt
+		return nil
+	})
+}
+
+// Get returns the event with the viewer's RSVP.
+func (s *EventStore) Get(ctx context.Context, id, viewerID int64) (*Event, error) {
+	query := `
+	SELECT ` + eventColumns + `
+	FROM events e JOIN posts p ON p.id = e.post_id JOIN users u ON u.id = p.user_id
+	WHERE e.post_id = $2 AND ` + eventVisible
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var e Event
+	if err := scanEvent(s.db.QueryRowContext(ctx, query, viewerID, id), &e); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &e, nil
+}
+
+// Upcoming returns the events not over yet that the user organizes, answered
+// or that people they follow organize, soonest first.
+func (s *EventStore) Upcoming(ctx context.Context, userID int64, limit, offset int) ([]Event, error) {
+	query := `
+	SELECT ` + eventColumns + `
+	FROM events e JOIN posts p ON p.id = e.post_id JOIN users u ON u.id = p.user_id
+	WHERE COALESCE(e.ends_at, e.starts_at) > NOW() AND e.canceled_at IS NULL AND ` + eventVisible + `
+		AND (p.user_id = $1
+			OR EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1)
+			OR EXISTS (SELECT 1 FROM event_rsvps r WHERE r.event_id = e.post_id AND r.user_id = $1))
+	ORDER BY e.starts_at, e.post_id
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// RSVP records the user's answer, replacing an earlier one. ErrRecordNotFound
+// means the event doesn't exist, was canceled or is over.
+func (s *EventStore) RSVP(ctx context.Context, id, userID int64, status string) error {
+	query := `
+	INSERT INTO event_rsvps (event_id, user_id, status)
+	SELECT post_id, $2, $3 FROM events
+	WHERE post_id = $1 AND canceled_at IS NULL AND COALESCE(ends_at, starts_at) > NOW()
+	ON CONFLICT (event_id, user_id) DO UPDATE SET status = EXCLUDED.status
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID, status)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (s *EventStore) RemoveRSVP(ctx context.Context, id, userID int64) error {
+	query := `DELETE FROM event_rsvps WHERE event_id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Attendees lists who answered the event, earliest answers first.
+func (s *EventStore) Attendees(ctx context.Context, id int64, limit, offset int) ([]EventAttendee, error) {
+	query := `
+	SELECT r.user_id, u.username, r.status
+	FROM event_rsvps r JOIN users u ON u.id = r.user_id
+	WHERE r.event_id = $1
+	ORDER BY r.created_at, r.user_id
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, id, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attendees := []EventAttendee{}
+	for rows.Next() {
+		var a EventAttendee
+		if err := rows.Scan(&a.UserID, &a.Username, &a.Status); err != nil {
+			return nil, err
+		}
+		attendees = append(attendees, a)
+	}
+	return attendees, rows.Err()
+}
+
+// Cancel cancels an upcoming event of the organizer and returns who had
+// answered it. ErrRecordNotFound means there is no such upcoming event by
+// the organizer.
+func (s *EventStore) Cancel(ctx context.Context, id, organizerID int64) ([]int64, error) {
+	var attendees []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `
+		UPDATE events e SET canceled_at = NOW()
+		FROM posts p
+		WHERE p.id = e.post_id AND e.post_id = $1 AND p.user_id = $2
+			AND e.canceled_at IS NULL AND COALESCE(e.ends_at, e.starts_at) > NOW()
+		`, id, organizerID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrRecordNotFound
+		}
+		return tx.QueryRowContext(ctx, `SELECT ARRAY(SELECT user_id FROM event_rsvps WHERE event_id = $1)`, id).
+			Scan((*pq.Int64Array)(&attendees))
+	})
+	return attendees, err
+}
+
+// ClaimReminders marks up to limit events starting within lead as reminded
+// and returns them with their attendees. Each event is claimed once, so
+// reminders go out at most once even with several instances.
+func (s *EventStore) ClaimReminders(ctx context.Context, lead time.Duration, limit int) ([]EventReminder, error) {
+	var reminders []EventReminder
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+		UPDATE events e SET reminded_at = NOW()
+		FROM posts p
+		WHERE p.id = e.post_id AND e.post_id IN (
+			SELECT post_id FROM events
+			WHERE reminded_at IS NULL AND canceled_at IS NULL
+				AND starts_at > NOW() AND starts_at <= NOW() + $1 * interval '1 second'
+			ORDER BY starts_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING e.post_id, p.user_id,
+			ARRAY(SELECT user_id FROM event_rsvps r WHERE r.event_id = e.post_id)
+		`, lead.Seconds(), limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rem EventReminder
+			if err := rows.Scan(&rem.EventID, &rem.OrganizerID, (*pq.Int64Array)(&rem.AttendeeIDs)); err != nil {
+				return err
+			}
+			reminders = append(reminders, rem)
+		}
+		return rows.Err()
+	})
+	return reminders, err
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// PlanLogger receives the plan of a slow query, label names the query.
+type PlanLogger func(label string, elapsed time.Duration, plan string)
+
+var (
+	explainThreshold time.Duration
+	explainLog       PlanLogger
+	// explaining keeps a burst of slow queries from piling up EXPLAINs on an
+	// already struggling database, one runs at a time and the rest are skipped.
+	explaining atomic.Bool
+)
+
+// SetSlowQueryExplain turns on the debug mode logging the plans of feed and
+// search queries slower than threshold. The slow query is run again under
+// EXPLAIN (ANALYZE, BUFFERS), so leave it off unless chasing a plan. It must
+// be called before the storage is used, a zero threshold turns it off.
+func SetSlowQueryExplain(threshold time.Duration, log PlanLogger) {
+	explainThreshold = threshold
+	explainLog = log
+}
+
+// explainIfSlow logs the plan of query in the background when it took longer
+// than the threshold.
+func explainIfSlow(db *sql.DB, label string, elapsed time.Duration, query string, args ...any) {
+	if explainThreshold <= 0 || elapsed < explainThreshold || !explaining.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer explaining.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), FeedTimeoutDuration)
+		defer cancel()
+
+		rows, err := db.QueryContext(ctx, "EXPLAIN (ANALYZE, BUFFERS) "+query, args...)
+		if err != nil {
+			explainLog(label, elapsed, "explain failed: "+err.Error())
+			return
+		}
+		defer rows.Close()
+
+		var plan []string
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				explainLog(label, elapsed, "explain failed: "+err.Error())
+				return
+			}
+			plan = append(plan, line)
+		}
+		explainLog(label, elapsed, strings.Join(plan, "\n"))
+	}()
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// FeedPosition is the last feed item a user read, shared across devices.
+type FeedPosition struct {
+	Cursor    string    `json:"cursor"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type FeedPositionStore struct {
+	db *sql.DB
+}
+
+func (s *FeedPositionStore) Get(ctx context.Context, userID int64) (*FeedPosition, error) {
+	query := `SELECT cursor, updated_at FROM feed_positions WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var position FeedPosition
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&position.Cursor, &position.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &position, nil
+}
+
+func (s *FeedPositionStore) Set(ctx context.Context, userID int64, cursor string) (*FeedPosition, error) {
+	query := `
+	INSERT INTO feed_positions (user_id, cursor) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET cursor = EXCLUDED.cursor, updated_at = NOW()
+	RETURNING cursor, updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var position FeedPosition
+	err := s.db.QueryRowContext(ctx, query, userID, cursor).Scan(&position.Cursor, &position.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &position, nil
+}
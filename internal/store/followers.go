@@ -3,14 +3,15 @@ package store
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Follower struct {
-	UserID     int64  `json:"user_id"`
-	FollowerID int64  `json:"follower_id"`
-	CreatedAt  string `json:"created_at"`
+	UserID     int64     `json:"user_id"`
+	FollowerID int64     `json:"follower_id"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 type FollowerStore struct {
 	db *sql.DB
@@ -20,7 +21,7 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID int64, userID int
 	query := `
 	INSERT INTO followers (follower_id,user_id) VALUES ($1, $2)
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
 	_, err := s.db.ExecContext(ctx, query, followerID, userID)
@@ -33,13 +34,20 @@ func (s *FollowerStore) Follow(ctx context.Context, followerID int64, userID int
 	return err
 }
 
+// Unfollow removes the follow and records it in unfollows for the follower
+// analytics.
 func (s *FollowerStore) Unfollow(ctx context.Context, followerID int64, userID int64) error {
 	query := `
-	DELETE FROM followers
-	WHERE follower_id = $1 AND user_id = $2
+	WITH deleted AS (
+		DELETE FROM followers
+		WHERE follower_id = $1 AND user_id = $2
+		RETURNING user_id, follower_id
+	)
+	INSERT INTO unfollows (user_id, follower_id)
+	SELECT user_id, follower_id FROM deleted
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
 	_, err := s.db.ExecContext(ctx, query, followerID, userID)
@@ -51,7 +59,7 @@ func (s *FollowerStore) ExistsFollow(ctx context.Context, followerID int64, user
 	query := `
 	SELECT EXISTS(
 		SELECT 1 FROM followers WHERE follower_id = $1 AND user_id = $2)`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 
 	var exists bool
@@ -62,3 +70,107 @@ func (s *FollowerStore) ExistsFollow(ctx context.Context, followerID int64, user
 	return exists, nil
 
 }
+
+// SetNotify turns the "bell" on a follow on or off. Subscribed followers are
+// notified of every new post. Returns ErrRecordNotFound when not following.
+func (s *FollowerStore) SetNotify(ctx context.Context, followerID int64, userID int64, notify bool) error {
+	query := `UPDATE followers SET notify = $3 WHERE follower_id = $1 AND user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, followerID, userID, notify)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetSubscriberIDs lists the followers of userID that have the bell on.
+func (s *FollowerStore) GetSubscriberIDs(ctx context.Context, userID int64) ([]int64, error) {
+	query := `SELECT follower_id FROM followers WHERE user_id = $1 AND notify`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// FollowedUser is one entry of a user's following list.
+type FollowedUser struct {
+	UserID     int64     `json:"user_id"`
+	Username   string    `json:"username"`
+	FollowedAt time.Time `json:"followed_at"`
+}
+
+// EachFollowing calls fn on every account followerID follows, oldest follow
+// first, reading them off the cursor as fn consumes them. It stops at the
+// first error fn returns and sets no timeout of its own.
+func (s *FollowerStore) EachFollowing(ctx context.Context, followerID int64, fn func(FollowedUser) error) error {
+	query := `
+	SELECT u.id, u.username, f.created_at
+	FROM followers f
+	JOIN users u ON u.id = f.user_id
+	WHERE f.follower_id = $1
+	ORDER BY f.created_at
+	`
+	return eachRow(ctx, s.db, query, []any{followerID}, func(rows *sql.Rows) error {
+		var f FollowedUser
+		if err := rows.Scan(&f.UserID, &f.Username, &f.FollowedAt); err != nil {
+			return err
+		}
+		return fn(f)
+	})
+}
+
+// FollowMany follows all userIDs at once, skipping existing follows, and
+// returns how many were created. With dryRun the transaction is rolled back
+// so the count shows what an import would do.
+func (s *FollowerStore) FollowMany(ctx context.Context, followerID int64, userIDs []int64, dryRun bool) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO followers (follower_id, user_id)
+	SELECT $1, id FROM unnest($2::bigint[]) AS id
+	WHERE id <> $1
+	ON CONFLICT DO NOTHING
+	`
+	res, err := tx.ExecContext(ctx, query, followerID, pq.Array(userIDs))
+	if err != nil {
+		return 0, err
+	}
+	created, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if dryRun {
+		return int(created), nil
+	}
+	return int(created), tx.Commit()
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// TrendingTag is a tag gaining usage: Posts counts the current window,
+// Previous the window before it.
+type TrendingTag struct {
+	Tag      string  `json:"tag"`
+	Posts    int     `json:"posts"`
+	Previous int     `json:"previous"`
+	Velocity float64 `json:"velocity"`
+}
+
+// RelatedTag is a tag used on the same posts as another one.
+type RelatedTag struct {
+	Tag   string `json:"tag"`
+	Posts int    `json:"posts"`
+}
+
+type HashtagStore struct {
+	db *sql.DB
+}
+
+// Counts returns the number of posts per tag created in [from, to).
+func (s *HashtagStore) Counts(ctx context.Context, from, to time.Time) (map[string]int, error) {
+	query := `
+	SELECT t.tag, COUNT(*)
+	FROM posts p, unnest(p.tags) AS t(tag)
+	WHERE p.created_at >= $1 AND p.created_at < $2
+	GROUP BY t.tag
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			tag   string
+			posts int
+		)
+		if err := rows.Scan(&tag, &posts); err != nil {
+			return nil, err
+		}
+		counts[tag] = posts
+	}
+	return counts, rows.Err()
+}
+
+// RefreshRelated recomputes tag co-occurrence over posts created since the
+// given time, keeping the perTag most frequent related tags of each tag.
+// Only yesterday's and today's posts are counted again, earlier UTC days
+// come from hashtag_pairs_daily, so a run stays cheap whatever the lookback.
+func (s *HashtagStore) RefreshRelated(ctx context.Context, since time.Time, perTag int) error {
+	since = since.UTC()
+	recent := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	pairsQuery := `
+	INSERT INTO hashtag_pairs_daily (day, tag, related, posts)
+	SELECT (p.created_at AT TIME ZONE 'UTC')::date, a.tag, b.tag, COUNT(*)
+	FROM posts p, unnest(p.tags) AS a(tag), unnest(p.tags) AS b(tag)
+	WHERE p.created_at >= $1 AND a.tag <> b.tag
+	GROUP BY 1, 2, 3
+	`
+	relatedQuery := `
+	INSERT INTO hashtag_related (tag, related, posts)
+	SELECT tag, related, posts FROM (
+		SELECT tag, related, SUM(posts) AS posts,
+			ROW_NUMBER() OVER (PARTITION BY tag ORDER BY SUM(posts) DESC, related) AS rank
+		FROM hashtag_pairs_daily
+		WHERE day >= $1::date
+		GROUP BY tag, related
+	) pairs
+	WHERE rank <= $2
+	`
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM hashtag_pairs_daily WHERE day >= $1::date OR day < $2::date`, recent, since); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, pairsQuery, recent); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM hashtag_related`); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, relatedQuery, since, perTag)
+		return err
+	})
+}
+
+// Related lists the tags most often used together with tag, as of the last
+// RefreshRelated.
+func (s *HashtagStore) Related(ctx context.Context, tag string, limit int) ([]RelatedTag, error) {
+	query := `
+	SELECT related, posts FROM hashtag_related
+	WHERE tag = $1
+	ORDER BY posts DESC, related
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []RelatedTag{}
+	for rows.Next() {
+		var t RelatedTag
+		if err := rows.Scan(&t.Tag, &t.Posts); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
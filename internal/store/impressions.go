@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Where a post was opened from, passed by clients as ?src= on the post URL.
+const (
+	ImpressionSourceDirect  = "direct"
+	ImpressionSourceFeed    = "feed"
+	ImpressionSourceProfile = "profile"
+	ImpressionSourceHashtag = "hashtag"
+)
+
+var ImpressionSources = []string{
+	ImpressionSourceDirect,
+	ImpressionSourceFeed,
+	ImpressionSourceProfile,
+	ImpressionSourceHashtag,
+}
+
+type DailyViews struct {
+	Date  string `json:"date"`
+	Views int    `json:"views"`
+}
+
+type PostInsights struct {
+	PostID        int64          `json:"post_id"`
+	Views         int            `json:"views"`
+	UniqueViewers int            `json:"unique_viewers"`
+	Daily         []DailyViews   `json:"daily"`
+	Sources       map[string]int `json:"sources"`
+	Reactions     map[string]int `json:"reactions"`
+	// TotalViews and TotalReactions are all-time counts.
+	TotalViews     int64 `json:"total_views"`
+	TotalReactions int64 `json:"total_reactions"`
+}
+
+type ImpressionStore struct {
+	db *sql.DB
+}
+
+// Record stores an impression and counts it in the post's views.
+func (s *ImpressionStore) Record(ctx context.Context, postID, viewerID int64, source string) error {
+	query := `
+	WITH recorded AS (
+		INSERT INTO post_impressions (post_id, viewer_id, source) VALUES ($1, NULLIF($2, 0), $3)
+		RETURNING post_id
+	)
+	`
+	args := []any{postID, viewerID, source}
+	if hotPosts.hit(postID) {
+		query += shardUpsert(CounterViews, `SELECT post_id, 1 FROM recorded`, 4)
+		args = append(args, randomShard())
+	} else {
+		query += `UPDATE posts SET views_count = views_count + 1 WHERE id IN (SELECT post_id FROM recorded)`
+	}
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// Insights aggregates the impressions of a post recorded after since. The
+// author's own views are not counted, except in the all-time totals, which
+// are the post's counters with their pending shards.
+func (s *ImpressionStore) Insights(ctx context.Context, postID, authorID int64, since time.Time) (*PostInsights, error) {
+	query := `
+	WITH views AS (
+		SELECT viewer_id, source, created_at FROM post_impressions
+		WHERE post_id = $1 AND created_at >= $3 AND viewer_id IS DISTINCT FROM $2
+	)
+	SELECT
+		(SELECT COUNT(*) FROM views),
+		(SELECT COUNT(DISTINCT viewer_id) FROM views),
+		COALESCE((
+			SELECT jsonb_agg(jsonb_build_object('date', day, 'views', total) ORDER BY day) FROM (
+				SELECT to_char(date_trunc('day', created_at), 'YYYY-MM-DD') AS day, COUNT(*) AS total
+				FROM views GROUP BY 1
+			) d
+		), '[]'),
+		COALESCE((
+			SELECT jsonb_object_agg(source, total) FROM (
+				SELECT source, COUNT(*) AS total FROM views GROUP BY source
+			) s
+		), '{}'),
+		COALESCE((
+			SELECT jsonb_object_agg(type, total) FROM (
+				SELECT type, COUNT(*) AS total FROM reactions
+				WHERE subject_type = 'post' AND subject_id = $1 GROUP BY type
+			) r
+		), '{}'),
+		p.views_count + COALESCE((
+			SELECT SUM(delta) FROM post_counter_shards WHERE post_id = $1 AND counter = 'views'
+		), 0),
+		p.reactions_count + COALESCE((
+			SELECT SUM(delta) FROM post_counter_shards WHERE post_id = $1 AND counter = 'reactions'
+		), 0)
+	FROM posts p WHERE p.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var (
+		daily, sources, reactions []byte
+		insights                  = PostInsights{PostID: postID}
+	)
+	err := s.db.QueryRowContext(ctx, query, postID, authorID, since).Scan(
+		&insights.Views,
+		&insights.UniqueViewers,
+		&daily,
+		&sources,
+		&reactions,
+		&insights.TotalViews,
+		&insights.TotalReactions,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(daily, &insights.Daily); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(sources, &insights.Sources); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(reactions, &insights.Reactions); err != nil {
+		return nil, err
+	}
+	return &insights, nil
+}
@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// InstanceSettings describe the instance to clients and federation peers.
+type InstanceSettings struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Rules       []string `json:"rules"`
+	// ContactEmail reaches the admins, empty when they publish none.
+	ContactEmail string        `json:"contact_email"`
+	Limits       ContentLimits `json:"limits"`
+	UpdatedBy    int64         `json:"-"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// ContentLimits bound what users can submit, lengths count characters.
+type ContentLimits struct {
+	MaxPostTitleLength   int `json:"max_post_title_length"`
+	MaxPostLength        int `json:"max_post_length"`
+	MaxArticleBodyLength int `json:"max_article_body_length"`
+	MaxCommentLength     int `json:"max_comment_length"`
+	// MaxUploadBytes 0 defers to the server's configured maximum.
+	MaxUploadBytes int64 `json:"max_upload_bytes"`
+}
+
+// DefaultContentLimits apply until the settings are loaded.
+var DefaultContentLimits = ContentLimits{
+	MaxPostTitleLength:   100,
+	MaxPostLength:        1000,
+	MaxArticleBodyLength: 100000,
+	MaxCommentLength:     1000,
+}
+
+type InstanceStore struct {
+	db *sql.DB
+}
+
+func (s *InstanceStore) Get(ctx context.Context) (*InstanceSettings, error) {
+	query := `
+	SELECT name, description, rules, contact_email,
+		max_post_title_length, max_post_length, max_article_body_length, max_comment_length, max_upload_bytes,
+		COALESCE(updated_by, 0), updated_at
+	FROM instance_settings
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var i InstanceSettings
+	l := &i.Limits
+	err := s.db.QueryRowContext(ctx, query).Scan(&i.Name, &i.Description, pq.Array(&i.Rules), &i.ContactEmail,
+		&l.MaxPostTitleLength, &l.MaxPostLength, &l.MaxArticleBodyLength, &l.MaxCommentLength, &l.MaxUploadBytes,
+		&i.UpdatedBy, &i.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (s *InstanceStore) Update(ctx context.Context, i *InstanceSettings) error {
+	query := `
+	UPDATE instance_settings
+	SET name = $1, description = $2, rules = $3, contact_email = $4,
+		max_post_title_length = $5, max_post_length = $6, max_article_body_length = $7, max_comment_length = $8, max_upload_bytes = $9,
+		updated_by = $10, updated_at = NOW()
+	RETURNING updated_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	l := i.Limits
+	return s.db.QueryRowContext(ctx, query, i.Name, i.Description, pq.Array(i.Rules), i.ContactEmail,
+		l.MaxPostTitleLength, l.MaxPostLength, l.MaxArticleBodyLength, l.MaxCommentLength, l.MaxUploadBytes,
+		i.UpdatedBy).Scan(&i.UpdatedAt)
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+)
+
+// jobLockClass namespaces the advisory locks of scheduled jobs, the second
+// key is the hash of the job name.
+const jobLockClass = 8301
+
+type JobLockStore struct {
+	db *sql.DB
+}
+
+// TryLock takes the job's advisory lock on a connection of its own, ok is
+// false when another replica holds it. unlock releases the lock and the
+// connection and must be called once the job is done.
+func (s *JobLockStore) TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	lockCtx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	err = conn.QueryRowContext(lockCtx, `SELECT pg_try_advisory_lock($1, hashtext($2))`, jobLockClass, name).Scan(&ok)
+	if err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+	return func() {
+		// Not the job's context, it is cancelled on shutdown.
+		ctx, cancel := context.WithTimeout(context.Background(), WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1, hashtext($2))`, jobLockClass, name); err != nil {
+			// The lock lives as long as the session, drop the connection
+			// rather than hand it back to the pool still holding it.
+			conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}, true, nil
+}
@@ -0,0 +1,332 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Subjects a legal hold can be placed on. A hold on a user covers all of the
+// user's posts as well.
+const (
+	HoldSubjectUser = "user"
+	HoldSubjectPost = "post"
+)
+
+var (
+	// ErrHoldExists means the subject is already under an active hold.
+	ErrHoldExists = errors.New("subject is already under legal hold")
+	// ErrUnderLegalHold is returned when deleting content that is preserved
+	// by a hold.
+	ErrUnderLegalHold = errors.New("content is under legal hold")
+)
+
+// LegalHold preserves a user or post for abuse reports and legal requests.
+// Held content is hidden from everyone but admins and can't be deleted until
+// the hold is released.
+type LegalHold struct {
+	ID          int64      `json:"id"`
+	SubjectType string     `json:"subject_type"`
+	SubjectID   int64      `json:"subject_id"`
+	Reason      string     `json:"reason"`
+	PlacedBy    int64      `json:"placed_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ReleasedBy  int64      `json:"released_by,omitempty"`
+	ReleasedAt  *time.Time `json:"released_at"`
+}
+
+// HoldExport is everything preserved by a hold, as handed over to admins.
+type HoldExport struct {
+	Hold       LegalHold  `json:"hold"`
+	User       *User      `json:"user,omitempty"`
+	Posts      []Post     `json:"posts"`
+	Bodies     []PostBody `json:"bodies"`
+	Comments   []Comment  `json:"comments"`
+	Media      []Media    `json:"media"`
+	ExportedAt time.Time  `json:"exported_at"`
+}
+
+type LegalHoldStore struct {
+	db *sql.DB
+}
+
+const legalHoldColumns = `id, subject_type, subject_id, reason, COALESCE(placed_by, 0), created_at, COALESCE(released_by, 0), released_at`
+
+func scanLegalHold(row interface{ Scan(...any) error }, h *LegalHold) error {
+	return row.Scan(&h.ID, &h.SubjectType, &h.SubjectID, &h.Reason, &h.PlacedBy, &h.CreatedAt, &h.ReleasedBy, &h.ReleasedAt)
+}
+
+// Place records the hold and flags the subject. ErrRecordNotFound means the
+// subject doesn't exist. The subject's posts are queued for the search
+// indexer so they drop out of the index.
+func (s *LegalHoldStore) Place(ctx context.Context, hold *LegalHold) error {
+	table, ok := subjectTables[hold.SubjectType]
+	if !ok {
+		return errors.New("unknown hold subject " + hold.SubjectType)
+	}
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO legal_holds (subject_type, subject_id, reason, placed_by)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM ` + table + ` WHERE id = $2)
+		RETURNING id, created_at
+		`
+		err := tx.QueryRowContext(ctx, query, hold.SubjectType, hold.SubjectID, hold.Reason, hold.PlacedBy).Scan(&hold.ID, &hold.CreatedAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" {
+					return ErrHoldExists
+				}
+				return err
+			}
+		}
+		return s.setOnHold(ctx, tx, hold.SubjectType, hold.SubjectID, true)
+	})
+}
+
+// Release ends an active hold and makes the subject visible again.
+func (s *LegalHoldStore) Release(ctx context.Context, id, releasedBy int64) (*LegalHold, error) {
+	var hold LegalHold
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		query := `
+		UPDATE legal_holds SET released_by = $2, released_at = NOW()
+		WHERE id = $1 AND released_at IS NULL
+		RETURNING ` + legalHoldColumns
+		if err := scanLegalHold(tx.QueryRowContext(ctx, query, id, releasedBy), &hold); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		return s.setOnHold(ctx, tx, hold.SubjectType, hold.SubjectID, false)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (s *LegalHoldStore) setOnHold(ctx context.Context, tx *sql.Tx, subjectType string, subjectID int64, onHold bool) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE `+subjectTables[subjectType]+` SET on_hold = $2 WHERE id = $1`, subjectID, onHold); err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox_events (topic, aggregate_id) SELECT $1, id FROM posts WHERE id = $2`
+	if subjectType == HoldSubjectUser {
+		query = `INSERT INTO outbox_events (topic, aggregate_id) SELECT $1, id FROM posts WHERE user_id = $2`
+	}
+	_, err := tx.ExecContext(ctx, query, TopicSearchPost, subjectID)
+	return err
+}
+
+func (s *LegalHoldStore) GetByID(ctx context.Context, id int64) (*LegalHold, error) {
+	query := `SELECT ` + legalHoldColumns + ` FROM legal_holds WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var hold LegalHold
+	if err := scanLegalHold(s.db.QueryRowContext(ctx, query, id), &hold); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &hold, nil
+}
+
+// List returns holds newest first, only the active ones unless all is set.
+func (s *LegalHoldStore) List(ctx context.Context, all bool, limit, offset int) ([]LegalHold, error) {
+	query := `SELECT ` + legalHoldColumns + ` FROM legal_holds
+	WHERE $1 OR released_at IS NULL
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, all, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holds := []LegalHold{}
+	for rows.Next() {
+		var hold LegalHold
+		if err := scanLegalHold(rows, &hold); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+	return holds, rows.Err()
+}
+
+// IsHeld reports whether the user or post is under an active hold. A post is
+// also held through a hold on its author.
+func (s *LegalHoldStore) IsHeld(ctx context.Context, subjectType string, subjectID int64) (bool, error) {
+	query := `SELECT on_hold FROM users WHERE id = $1`
+	if subjectType == HoldSubjectPost {
+		query = `SELECT p.on_hold OR u.on_hold FROM posts p JOIN users u ON u.id = p.user_id WHERE p.id = $1`
+	}
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var held bool
+	err := s.db.QueryRowContext(ctx, query, subjectID).Scan(&held)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrRecordNotFound
+	}
+	return held, err
+}
+
+// HoldRecord is one row of a streamed hold export, Type is "hold", "user",
+// "post", "body", "comment" or "media".
+type HoldRecord struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// Export collects the held content: the post with its body and comments, or
+// for a user the account, every post, every comment written by or under the
+// user and the user's media. Released holds can still be exported for
+// whatever the hold preserved that is left.
+func (s *LegalHoldStore) Export(ctx context.Context, hold *LegalHold) (*HoldExport, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	export := &HoldExport{
+		Posts:      []Post{},
+		Bodies:     []PostBody{},
+		Comments:   []Comment{},
+		Media:      []Media{},
+		ExportedAt: time.Now().UTC(),
+	}
+	err := s.StreamExport(ctx, hold, func(rec HoldRecord) error {
+		switch v := rec.Data.(type) {
+		case LegalHold:
+			export.Hold = v
+		case *User:
+			export.User = v
+		case Post:
+			export.Posts = append(export.Posts, v)
+		case PostBody:
+			export.Bodies = append(export.Bodies, v)
+		case Comment:
+			export.Comments = append(export.Comments, v)
+		case Media:
+			export.Media = append(export.Media, v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// StreamExport hands the same content as Export to emit one row at a time,
+// straight off the database cursors, so exports of prolific users never sit
+// in memory whole. It stops at the first error emit returns. It sets no
+// timeout of its own, ctx bounds the whole export.
+func (s *LegalHoldStore) StreamExport(ctx context.Context, hold *LegalHold, emit func(HoldRecord) error) error {
+	if err := emit(HoldRecord{Type: "hold", Data: *hold}); err != nil {
+		return err
+	}
+	var (
+		postsWhere    = `id = $1`
+		commentsWhere = `post_id = $1`
+		bodiesWhere   = `post_id = $1`
+	)
+	if hold.SubjectType == HoldSubjectUser {
+		postsWhere = `user_id = $1`
+		commentsWhere = `user_id = $1 OR post_id IN (SELECT id FROM posts WHERE user_id = $1)`
+		bodiesWhere = `post_id IN (SELECT id FROM posts WHERE user_id = $1)`
+
+		user := &User{}
+		err := s.db.QueryRowContext(ctx, `SELECT id, username, email, created_at, is_active FROM users WHERE id = $1`, hold.SubjectID).
+			Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.IsActive)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		default:
+			if err := emit(HoldRecord{Type: "user", Data: user}); err != nil {
+				return err
+			}
+		}
+	}
+
+	err := eachRow(ctx, s.db, `SELECT id, content, title, user_id, tags, created_at, updated_at, version, lang, content_warning, kind, comments_count
+		FROM posts WHERE `+postsWhere+` ORDER BY id`, []any{hold.SubjectID}, func(rows *sql.Rows) error {
+		var post Post
+		if err := rows.Scan(&post.ID, &post.Content, &post.Title, &post.UserID, pq.Array(&post.Tags), &post.CreatedAt, &post.UpdatedAt,
+			&post.Version, &post.Lang, &post.ContentWarning, &post.Kind, &post.CommentCount); err != nil {
+			return err
+		}
+		return emit(HoldRecord{Type: "post", Data: post})
+	})
+	if err != nil {
+		return err
+	}
+
+	err = eachRow(ctx, s.db, `SELECT post_id, body, updated_at FROM post_bodies WHERE `+bodiesWhere+` ORDER BY post_id`,
+		[]any{hold.SubjectID}, func(rows *sql.Rows) error {
+			var body PostBody
+			if err := rows.Scan(&body.PostID, &body.Body, &body.UpdatedAt); err != nil {
+				return err
+			}
+			return emit(HoldRecord{Type: "body", Data: body})
+		})
+	if err != nil {
+		return err
+	}
+
+	err = eachRow(ctx, s.db, `SELECT id, post_id, parent_id, hidden_at IS NOT NULL, user_id, content, created_at
+		FROM comments WHERE `+commentsWhere+` ORDER BY id`, []any{hold.SubjectID}, func(rows *sql.Rows) error {
+		var c Comment
+		if err := rows.Scan(&c.ID, &c.PostID, &c.ParentID, &c.Hidden, &c.UserID, &c.Content, &c.CreatedAt); err != nil {
+			return err
+		}
+		return emit(HoldRecord{Type: "comment", Data: c})
+	})
+	if err != nil || hold.SubjectType != HoldSubjectUser {
+		return err
+	}
+
+	return eachRow(ctx, s.db, `SELECT `+mediaColumns+` FROM media WHERE user_id = $1 ORDER BY id`, []any{hold.SubjectID}, func(rows *sql.Rows) error {
+		var m Media
+		if err := scanMedia(rows, &m); err != nil {
+			return err
+		}
+		return emit(HoldRecord{Type: "media", Data: m})
+	})
+}
+
+// eachRow runs query and calls fn on every row until one fails.
+func eachRow(ctx context.Context, db *sql.DB, query string, args []any, fn func(*sql.Rows) error) error {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
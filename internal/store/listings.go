@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Listing categories.
+const (
+	ListingJob       = "job"
+	ListingFreelance = "freelance"
+	ListingProject   = "project"
+	ListingOther     = "other"
+)
+
+var ListingCategories = []string{ListingJob, ListingFreelance, ListingProject, ListingOther}
+
+func ValidListingCategory(category string) bool {
+	return slices.Contains(ListingCategories, category)
+}
+
+// Listings wait for a moderator before they reach the listing feed.
+const (
+	ListingPending  = "pending"
+	ListingApproved = "approved"
+	ListingRejected = "rejected"
+)
+
+// Listing is a job or classified ad announced by a post of kind
+// PostKindListing, it shares the post's ID, title and content. Listing posts
+// stay out of the home and explore feeds, they are found through the
+// listing feed once approved and until they expire.
+type Listing struct {
+	ID           int64     `json:"id"`
+	UserID       int64     `json:"user_id"`
+	Title        string    `json:"title"`
+	Description  string    `json:"description"`
+	Tags         []string  `json:"tags"`
+	Category     string    `json:"category"`
+	Location     string    `json:"location,omitempty"`
+	Remote       bool      `json:"remote"`
+	Compensation string    `json:"compensation,omitempty"`
+	ApplyURL     string    `json:"apply_url,omitempty"`
+	Status       string    `json:"status"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	ReviewedBy   int64     `json:"reviewed_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ListingStore struct {
+	db    *sql.DB
+	posts *PostStore
+}
+
+const listingColumns = `p.id, p.user_id, p.title, p.content, p.tags, l.category, l.location, l.remote, l.compensation,
+	l.apply_url, l.status, l.expires_at, COALESCE(l.reviewed_by, 0), p.created_at`
+
+func scanListing(row interface{ Scan(...any) error }, l *Listing) error {
+	return row.Scan(&l.ID, &l.UserID, &l.Title, &l.Description, pq.Array(&l.Tags), &l.Category, &l.Location, &l.Remote,
+		&l.Compensation, &l.ApplyURL, &l.Status, &l.ExpiresAt, &l.ReviewedBy, &l.CreatedAt)
+}
+
+// Create publishes the listing's post and the pending listing in one
+// transaction. The listing takes the post's ID.
+func (s *ListingStore) Create(ctx context.Context, post *Post, l *Listing) error {
+	post.Kind = PostKindListing
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.posts.create(ctx, tx, post); err != nil {
+			return err
+		}
+		query := `
+		INSERT INTO listings (post_id, category, location, remote, compensation, apply_url, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING status
+		`
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+		err := tx.QueryRowContext(ctx, query, post.ID, l.Category, l.Location, l.Remote, l.Compensation, l.ApplyURL, l.ExpiresAt).
+			Scan(&l.Status)
+		if err != nil {
+			return err
+		}
+		l.ID, l.UserID, l.Title, l.Description, l.Tags, l.CreatedAt = post.ID, post.UserID, post.Title, post.Content, post.Tags, post.CreatedAt
+		return nil
+	})
+}
+
+// Get returns the listing whatever its status, unless its post or author is
+// under legal hold.
+func (s *ListingStore) Get(ctx context.Context, id int64) (*Listing, error) {
+	query := `
+	SELECT ` + listingColumns + `
+	FROM listings l JOIN posts p ON p.id = l.post_id JOIN users u ON u.id = p.user_id
+	WHERE l.post_id = $1 AND NOT p.on_hold AND NOT u.on_hold
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var l Listing
+	if err := scanListing(s.db.QueryRowContext(ctx, query, id), &l); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Feed returns the approved listings that haven't expired, newest first,
+// only those of category unless it is empty.
+func (s *ListingStore) Feed(ctx context.Context, category string, limit, offset int) ([]Listing, error) {
+	query := `
+	SELECT ` + listingColumns + `
+	FROM listings l JOIN posts p ON p.id = l.post_id JOIN users u ON u.id = p.user_id
+	WHERE l.status = 'approved' AND l.expires_at > NOW() AND (l.category = $1 OR $1 = '')
+		AND NOT p.on_hold AND NOT u.on_hold
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3
+	`
+	return s.list(ctx, query, category, limit, offset)
+}
+
+// ListPending is the moderation queue, oldest first. Expired listings are
+// left out, nobody would see them anyway.
+func (s *ListingStore) ListPending(ctx context.Context, limit, offset int) ([]Listing, error) {
+	query := `
+	SELECT ` + listingColumns + `
+	FROM listings l JOIN posts p ON p.id = l.post_id JOIN users u ON u.id = p.user_id
+	WHERE l.status = 'pending' AND l.expires_at > NOW()
+	ORDER BY p.created_at, p.id
+	LIMIT $1 OFFSET $2
+	`
+	return s.list(ctx, query, limit, offset)
+}
+
+func (s *ListingStore) list(ctx context.Context, query string, args ...any) ([]Listing, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	listings := []Listing{}
+	for rows.Next() {
+		var l Listing
+		if err := scanListing(rows, &l); err != nil {
+			return nil, err
+		}
+		listings = append(listings, l)
+	}
+	return listings, rows.Err()
+}
+
+// Review approves or rejects the listing. Approved listings can be rejected
+// later to take them down.
+func (s *ListingStore) Review(ctx context.Context, id, moderatorID int64, status string) (*Listing, error) {
+	query := `
+	UPDATE listings SET status = $3, reviewed_by = $2, reviewed_at = NOW()
+	WHERE post_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, moderatorID, status)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrRecordNotFound
+	}
+	return s.Get(ctx, id)
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// QueuedMail is an email that could not be sent right away and waits in the
+// outbox for the mailer to recover.
+type QueuedMail struct {
+	ID       int64
+	Template string
+	Username string
+	Email    string
+	Data     json.RawMessage
+	Sandbox  bool
+	Attempts int
+}
+
+type MailOutboxStore struct {
+	db *sql.DB
+}
+
+func (s *MailOutboxStore) Enqueue(ctx context.Context, mail *QueuedMail) error {
+	query := `
+	INSERT INTO mail_outbox (template, username, email, data, sandbox)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, mail.Template, mail.Username, mail.Email, []byte(mail.Data), mail.Sandbox).Scan(&mail.ID)
+}
+
+// Claim leases up to limit unsent mails tried fewer than maxAttempts times,
+// oldest first, so no other replica picks them up until lease has passed.
+// A mail whose sender crashed is retried once its lease runs out.
+func (s *MailOutboxStore) Claim(ctx context.Context, maxAttempts, limit int, lease time.Duration) ([]QueuedMail, error) {
+	query := `
+	UPDATE mail_outbox SET claimed_until = NOW() + $3 * interval '1 second'
+	WHERE id IN (
+		SELECT id FROM mail_outbox
+		WHERE sent_at IS NULL AND attempts < $1
+			AND (claimed_until IS NULL OR claimed_until < NOW())
+		ORDER BY id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, template, username, email, data, sandbox, attempts
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, maxAttempts, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mails []QueuedMail
+	for rows.Next() {
+		var m QueuedMail
+		if err := rows.Scan(&m.ID, &m.Template, &m.Username, &m.Email, &m.Data, &m.Sandbox, &m.Attempts); err != nil {
+			return nil, err
+		}
+		mails = append(mails, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(mails, func(i, j int) bool { return mails[i].ID < mails[j].ID })
+	return mails, nil
+}
+
+// MarkSent records the delivery and drops the template data, which often
+// holds activation links or other personal details.
+
+func (s *MailOutboxStore) MarkSent(ctx context.Context, id int64) error {
+	query := `UPDATE mail_outbox SET sent_at = NOW(), attempts = attempts + 1, data = '{}', claimed_until = NULL WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (s *MailOutboxStore) MarkFailed(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE mail_outbox SET attempts = attempts + 1, last_error = $2, claimed_until = NULL WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, reason)
+	return err
+}
+
+// Purge deletes mail sent before the cutoff, along with mail created before
+// it that used up all its attempts.
+func (s *MailOutboxStore) Purge(ctx context.Context, before time.Time, maxAttempts int) error {
+	query := `
+	DELETE FROM mail_outbox
+	WHERE (sent_at IS NOT NULL AND sent_at < $1)
+		OR (sent_at IS NULL AND attempts >= $2 AND created_at < $1)
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, before, maxAttempts)
+	return err
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MaintenanceWindow puts the API, or only the routes under Paths, into
+// maintenance: requests are answered 503 until an admin ends it or EndsAt
+// passes. With WritesOnly reads keep working.
+type MaintenanceWindow struct {
+	ID int64 `json:"id"`
+	// Paths are route prefixes such as /v1/posts, empty for the whole API.
+	Paths      []string `json:"paths"`
+	WritesOnly bool     `json:"writes_only"`
+	// Message is shown to clients, a default is used when empty.
+	Message   string     `json:"message"`
+	EndsAt    *time.Time `json:"ends_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	CreatedBy int64      `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+const maintenanceColumns = `id, paths, writes_only, message, ends_at, ended_at, COALESCE(created_by, 0), created_at`
+
+type MaintenanceStore struct {
+	db *sql.DB
+}
+
+func (s *MaintenanceStore) Create(ctx context.Context, m *MaintenanceWindow) error {
+	query := `
+	INSERT INTO maintenance_windows (paths, writes_only, message, ends_at, created_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, pq.Array(m.Paths), m.WritesOnly, m.Message, m.EndsAt, m.CreatedBy).
+		Scan(&m.ID, &m.CreatedAt)
+}
+
+// ListActive returns the windows in effect, oldest first.
+func (s *MaintenanceStore) ListActive(ctx context.Context) ([]MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows
+	WHERE ended_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())
+	ORDER BY created_at, id`
+	return s.list(ctx, query)
+}
+
+// List returns every window, newest first.
+func (s *MaintenanceStore) List(ctx context.Context, limit, offset int) ([]MaintenanceWindow, error) {
+	query := `SELECT ` + maintenanceColumns + ` FROM maintenance_windows
+	ORDER BY created_at DESC, id DESC
+	LIMIT $1 OFFSET $2`
+	return s.list(ctx, query, limit, offset)
+}
+
+func (s *MaintenanceStore) list(ctx context.Context, query string, args ...any) ([]MaintenanceWindow, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	windows := []MaintenanceWindow{}
+	for rows.Next() {
+		var m MaintenanceWindow
+		if err := rows.Scan(&m.ID, pq.Array(&m.Paths), &m.WritesOnly, &m.Message, &m.EndsAt, &m.EndedAt, &m.CreatedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		windows = append(windows, m)
+	}
+	return windows, rows.Err()
+}
+
+// End lifts the window now. ErrRecordNotFound means it isn't in effect.
+func (s *MaintenanceStore) End(ctx context.Context, id int64) error {
+	query := `UPDATE maintenance_windows SET ended_at = NOW()
+	WHERE id = $1 AND ended_at IS NULL AND (ends_at IS NULL OR ends_at > NOW())`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
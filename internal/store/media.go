@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	MediaVisibilityPublic    = "public"
+	MediaVisibilityFollowers = "followers"
+	MediaVisibilityPrivate   = "private"
+)
+
+// Scan states of media. Quarantined media waits for a moderator and is not
+// served, approved and clean media are.
+const (
+	MediaScanClean       = "clean"
+	MediaScanQuarantined = "quarantined"
+	MediaScanApproved    = "approved"
+	MediaScanRejected    = "rejected"
+)
+
+type Media struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	BlobKey     string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Visibility  string    `json:"visibility"`
+	CreatedAt   time.Time `json:"created_at"`
+	URL         string    `json:"url,omitempty"`
+	URLExpires  string    `json:"url_expires_at,omitempty"`
+	ScanStatus  string    `json:"scan_status"`
+	// ScanResults holds the scanners' findings as JSON, for reviewers only.
+	ScanResults json.RawMessage `json:"scan_results,omitempty" swaggertype:"object"`
+}
+
+// Servable reports whether the media may be handed out to viewers.
+func (m *Media) Servable() bool {
+	return m.ScanStatus == MediaScanClean || m.ScanStatus == MediaScanApproved
+}
+
+type MediaStore struct {
+	db *sql.DB
+}
+
+func (s *MediaStore) Create(ctx context.Context, media *Media) error {
+	query := `
+	INSERT INTO media (user_id, blob_key, content_type, size, visibility, scan_status, scan_results)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING id, created_at
+	`
+	if media.ScanStatus == "" {
+		media.ScanStatus = MediaScanClean
+	}
+	results := media.ScanResults
+	if results == nil {
+		results = json.RawMessage("[]")
+	}
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(
+		ctx,
+		query,
+		media.UserID,
+		media.BlobKey,
+		media.ContentType,
+		media.Size,
+		media.Visibility,
+		media.ScanStatus,
+		[]byte(results),
+	).Scan(&media.ID, &media.CreatedAt)
+}
+
+func (s *MediaStore) GetByID(ctx context.Context, id int64) (*Media, error) {
+	query := `
+	SELECT ` + mediaColumns + `
+	FROM media
+	WHERE id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var media Media
+	err := scanMedia(s.db.QueryRowContext(ctx, query, id), &media)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &media, nil
+}
+
+func (s *MediaStore) GetByBlobKey(ctx context.Context, key string) (*Media, error) {
+	query := `
+	SELECT ` + mediaColumns + `
+	FROM media
+	WHERE blob_key = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var media Media
+	err := scanMedia(s.db.QueryRowContext(ctx, query, key), &media)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &media, nil
+}
+
+const mediaColumns = `id, user_id, blob_key, content_type, size, visibility, created_at, scan_status, scan_results`
+
+func scanMedia(row interface{ Scan(...any) error }, media *Media) error {
+	var results []byte
+	err := row.Scan(
+		&media.ID,
+		&media.UserID,
+		&media.BlobKey,
+		&media.ContentType,
+		&media.Size,
+		&media.Visibility,
+		&media.CreatedAt,
+		&media.ScanStatus,
+		&results,
+	)
+	media.ScanResults = results
+	return err
+}
+
+// ListByScanStatus is the review queue of media, oldest first.
+func (s *MediaStore) ListByScanStatus(ctx context.Context, status string, limit, offset int) ([]Media, error) {
+	query := `
+	SELECT ` + mediaColumns + `
+	FROM media
+	WHERE scan_status = $1
+	ORDER BY created_at, id
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []Media{}
+	for rows.Next() {
+		var m Media
+		if err := scanMedia(rows, &m); err != nil {
+			return nil, err
+		}
+		media = append(media, m)
+	}
+	return media, rows.Err()
+}
+
+// Review records a moderator's decision on quarantined media.
+func (s *MediaStore) Review(ctx context.Context, id int64, status string, reviewerID int64) error {
+	query := `
+	UPDATE media SET scan_status = $2, reviewed_by = $3, reviewed_at = NOW()
+	WHERE id = $1 AND scan_status = $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, id, status, reviewerID, MediaScanQuarantined)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
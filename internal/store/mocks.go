@@ -1,14 +1,64 @@
 package store
 
 import (
+	"cmp"
 	"context"
 	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Users:           &MockUserStore{},
+		Posts:           &MockPostStore{},
+		Comments:        &MockCommentStore{},
+		Notifications:   &MockNotificationStore{},
+		Reactions:       &MockReactionStore{},
+		FeedPositions:   &MockFeedPositionStore{},
+		Followers:       &MockFollowerStore{},
+		Roles:           &MockRoleStore{},
+		MailOutbox:      &MockMailOutboxStore{},
+		Schema:          &MockSchemaStore{},
+		Partitions:      &MockPartitionStore{},
+		Counters:        &MockCounterStore{},
+		Outbox:          &MockOutboxStore{},
+		Hashtags:        &MockHashtagStore{},
+		Risk:            &MockRiskStore{},
+		ModeratorNotes:  &MockModeratorNoteStore{},
+		ModerationCases: &MockModerationCaseStore{},
+		Media:           &MockMediaStore{},
+		LegalHolds:      &MockLegalHoldStore{},
+		Announcements:   &MockAnnouncementStore{},
+		Terms:           &MockTermsStore{},
+		Impressions:     &MockImpressionStore{},
+		AccountMerges:   &MockAccountMergeStore{},
+		APIKeys:         &MockAPIKeyStore{},
+		Recovery:        &MockRecoveryStore{},
+		Emoji:           &MockEmojiStore{},
+		ProfileFields:   &MockProfileFieldStore{},
+		Badges:          &MockBadgeStore{},
+		Events:          &MockEventStore{},
+		Listings:        &MockListingStore{},
+		Snippets:        &MockSnippetStore{},
+		RepoCards:       &MockRepoCardStore{},
+		Crossposts:      &MockCrosspostStore{},
+		EmailPosts:      &MockEmailPostStore{},
+		Sitemap:         &MockSitemapStore{},
+		Maintenance:     &MockMaintenanceStore{},
+		Questions:       &MockQuestionStore{},
+		Spaces:          &MockSpaceStore{},
+		Stories:         &MockStoryStore{},
+		OAuth:           &MockOAuthStore{},
+		PushDevices:     &MockPushDeviceStore{},
+		Archive:         &MockArchiveStore{},
+		Tombstones:      &MockTombstoneStore{},
+		Instance:        &MockInstanceStore{},
+		Sessions:        &MockSessionStore{},
+		JobLocks:        &MockJobLockStore{},
 	}
 }
 
@@ -20,9 +70,22 @@ func (m *MockUserStore) Create(ctx context.Context, tx *sql.Tx, user *User) erro
 }
 func (m *MockUserStore) GetByID(ctx context.Context, id int64) (*User, error) {
 	return &User{
-		ID: id,
+		ID:   id,
+		Role: &Role{Name: "user", Level: 1},
 	}, nil
 }
+func (m *MockUserStore) GetByIDs(ctx context.Context, ids []int64) ([]User, error) {
+	users := make([]User, len(ids))
+	for i, id := range ids {
+		users[i] = User{ID: id, Username: fmt.Sprintf("user%d", id)}
+	}
+	return users, nil
+}
+func (m *MockUserStore) CreateServiceAccount(ctx context.Context, user *User) error {
+	user.ID = 100
+	user.IsActive, user.Passwordless, user.IsBot = true, true, true
+	return nil
+}
 func (m *MockUserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
 	return nil, nil
 }
@@ -33,6 +96,2048 @@ func (m *MockUserStore) Delete(ctx context.Context, id int64) error {
 func (m *MockUserStore) Activate(ctx context.Context, token string) error {
 	return nil
 }
+func (m *MockUserStore) RequestEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return nil
+}
+func (m *MockUserStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	return 0, ErrRecordNotFound
+}
 func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return nil
 }
+func (m *MockUserStore) SetPasswordless(ctx context.Context, userID int64, passwordless bool) error {
+	return nil
+}
+
+func (m *MockUserStore) SetBirthdate(ctx context.Context, userID int64, birthdate string) error {
+	return nil
+}
+
+func (m *MockUserStore) SetLocale(ctx context.Context, userID int64, locale, timezone string) error {
+	return nil
+}
+func (m *MockUserStore) SetPreferredLanguages(ctx context.Context, userID int64, languages []string) error {
+	return nil
+}
+
+func (m *MockUserStore) SetContentWarningPref(ctx context.Context, userID int64, pref string) error {
+	return nil
+}
+
+func (m *MockUserStore) SetMutedNotificationTypes(ctx context.Context, userID int64, types []string) error {
+	return nil
+}
+func (m *MockUserStore) SetPostRetention(ctx context.Context, userID int64, months *int) error {
+	return nil
+}
+
+func (m *MockUserStore) GetProfile(ctx context.Context, userID int64) (*Profile, error) {
+	return &Profile{ID: userID, Username: "gopher"}, nil
+}
+
+func (m *MockUserStore) GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (m *MockUserStore) GetIDsByEmails(ctx context.Context, emails []string) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+type MockPostStore struct {
+	// LastSearch is the query of the latest Search call.
+	LastSearch PostSearchQuery
+}
+
+func (m *MockPostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
+	return &Post{ID: id}, nil
+}
+func (m *MockPostStore) GetByIDs(ctx context.Context, ids []int64) ([]Post, error) {
+	posts := make([]Post, 0, len(ids))
+	for _, id := range ids {
+		posts = append(posts, Post{ID: id})
+	}
+	return posts, nil
+}
+func (m *MockPostStore) Create(ctx context.Context, post *Post) error {
+	return nil
+}
+func (m *MockPostStore) CreateArticle(ctx context.Context, post *Post, body string) error {
+	post.Kind = PostKindArticle
+	return nil
+}
+func (m *MockPostStore) FindRecentDuplicate(ctx context.Context, userID int64, fingerprint string, window time.Duration) (int64, error) {
+	return 0, ErrRecordNotFound
+}
+func (m *MockPostStore) GetBody(ctx context.Context, postID int64) (*PostBody, error) {
+	return &PostBody{PostID: postID}, nil
+}
+func (m *MockPostStore) UpdateArticle(ctx context.Context, post *Post, body string) error {
+	return nil
+}
+func (m *MockPostStore) Delete(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *MockPostStore) PurgeExpired(ctx context.Context, limit int) ([]int64, error) {
+	return nil, nil
+}
+func (m *MockPostStore) Update(ctx context.Context, post *Post) error {
+	return nil
+}
+func (m *MockPostStore) GetUserFeed(ctx context.Context, userID int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) CountFeedSince(ctx context.Context, userID, sinceID int64) (int, error) {
+	return 0, nil
+}
+func (m *MockPostStore) Search(ctx context.Context, sq PostSearchQuery) ([]PostWithMetadata, error) {
+	m.LastSearch = sq
+	return []PostWithMetadata{}, nil
+}
+
+func (m *MockPostStore) GetExploreFeed(ctx context.Context, preferred []string, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	return []PostWithMetadata{}, nil
+}
+
+type MockCommentStore struct {
+}
+
+func (m *MockCommentStore) SetHidden(ctx context.Context, commentID int64, hidden bool, hiddenBy int64) error {
+	return nil
+}
+func (m *MockCommentStore) GetByPostID(ctx context.Context, postID, viewerID int64, includeHidden bool) ([]Comment, error) {
+	return []Comment{}, nil
+}
+func (m *MockCommentStore) GetPreviewsByPostIDs(ctx context.Context, postIDs []int64) (map[int64]Comment, error) {
+	return map[int64]Comment{}, nil
+}
+func (m *MockCommentStore) GetByID(ctx context.Context, id int64) (*Comment, error) {
+	return &Comment{ID: id, PostID: 1}, nil
+}
+func (m *MockCommentStore) Create(ctx context.Context, comment *Comment) error {
+	return nil
+}
+
+type MockRoleStore struct {
+}
+
+var mockRoleLevels = map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+func (m *MockRoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
+	level, ok := mockRoleLevels[name]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &Role{Name: name, Level: level}, nil
+}
+
+type MockNotificationStore struct {
+	Notifications []Notification
+	// Receipts maps "<notification id>:<channel>" to "delivered" or "seen".
+	Receipts map[string]string
+	seq      int64
+}
+
+func (m *MockNotificationStore) CreateMany(ctx context.Context, notifications []Notification) error {
+next:
+	for _, n := range notifications {
+		if key := NotificationGroupKey(n); key != "" {
+			for i, open := range m.Notifications {
+				if open.UserID == n.UserID && open.ReadAt == nil && NotificationGroupKey(open) == key {
+					g := &m.Notifications[i]
+					g.EventCount++
+					if !slices.Contains(g.RecentActorIDs, n.ActorID) {
+						g.ActorCount++
+					}
+					g.RecentActorIDs = append([]int64{n.ActorID}, slices.DeleteFunc(g.RecentActorIDs, func(id int64) bool { return id == n.ActorID })...)
+					g.RecentActorIDs = g.RecentActorIDs[:min(len(g.RecentActorIDs), maxRecentActors)]
+					g.ActorID, g.CommentID = n.ActorID, n.CommentID
+					m.seq++
+					g.Seq = m.seq
+					continue next
+				}
+			}
+		}
+		n.ID = int64(len(m.Notifications) + 1)
+		n.EventCount, n.ActorCount, n.RecentActorIDs = 1, 1, []int64{n.ActorID}
+		n.CreatedAt = time.Now()
+		m.seq++
+		n.Seq = m.seq
+		m.Notifications = append(m.Notifications, n)
+	}
+	return nil
+}
+func (m *MockNotificationStore) LatestSeq(ctx context.Context, userID int64) (int64, error) {
+	var seq int64
+	for _, n := range m.Notifications {
+		if n.UserID == userID {
+			seq = max(seq, n.Seq)
+		}
+	}
+	return seq, nil
+}
+func (m *MockNotificationStore) ChangedAfter(ctx context.Context, userID, seq int64, limit int) ([]Notification, error) {
+	notifications := []Notification{}
+	for _, n := range m.Notifications {
+		if n.UserID == userID && n.Seq > seq {
+			notifications = append(notifications, n)
+		}
+	}
+	slices.SortFunc(notifications, func(a, b Notification) int { return cmp.Compare(a.Seq, b.Seq) })
+	return notifications[:min(len(notifications), limit)], nil
+}
+func (m *MockNotificationStore) GetByUserID(ctx context.Context, userID int64, limit, offset int) ([]Notification, error) {
+	notifications := []Notification{}
+	for _, n := range m.Notifications {
+		if n.UserID == userID {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+func (m *MockNotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	var ids []int64
+	for _, n := range m.Notifications {
+		ids = append(ids, n.ID)
+	}
+	_, err := m.MarkSeen(ctx, userID, ids, ChannelInApp)
+	return err
+}
+func (m *MockNotificationStore) MarkSeen(ctx context.Context, userID int64, ids []int64, channel string) ([]int64, error) {
+	var seen []int64
+	for i, n := range m.Notifications {
+		if n.UserID == userID && slices.Contains(ids, n.ID) {
+			readAt := time.Now()
+			m.Notifications[i].ReadAt = &readAt
+			m.receipt(n.ID, channel, "seen")
+			seen = append(seen, n.ID)
+		}
+	}
+	return seen, nil
+}
+func (m *MockNotificationStore) RecordDelivery(ctx context.Context, channel string, attempted, delivered []int64) error {
+	for _, id := range attempted {
+		state := "attempted"
+		if slices.Contains(delivered, id) {
+			state = "delivered"
+		}
+		m.receipt(id, channel, state)
+	}
+	return nil
+}
+func (m *MockNotificationStore) PendingDelivery(ctx context.Context, channel string, since, until time.Time, limit int) ([]Notification, error) {
+	pending := []Notification{}
+	for _, n := range m.Notifications {
+		if _, ok := m.Receipts[fmt.Sprintf("%d:%s", n.ID, channel)]; n.ReadAt == nil && !ok {
+			pending = append(pending, n)
+		}
+	}
+	return pending, nil
+}
+func (m *MockNotificationStore) receipt(id int64, channel, state string) {
+	if m.Receipts == nil {
+		m.Receipts = make(map[string]string)
+	}
+	m.Receipts[fmt.Sprintf("%d:%s", id, channel)] = state
+}
+func (m *MockNotificationStore) CreateFollowBackSuggestions(ctx context.Context, since time.Time, dailyCap, limit int) (int, error) {
+	return 0, nil
+}
+
+type MockReactionStore struct {
+}
+
+func (m *MockReactionStore) Set(ctx context.Context, subjectType string, subjectID, userID int64, reactionType string) error {
+	return nil
+}
+func (m *MockReactionStore) Remove(ctx context.Context, subjectType string, subjectID, userID int64) error {
+	return nil
+}
+func (m *MockReactionStore) Summary(ctx context.Context, subjectType string, subjectID, viewerID int64) (*ReactionSummary, error) {
+	return &ReactionSummary{Counts: map[string]int{}}, nil
+}
+func (m *MockReactionStore) Counts(ctx context.Context, subjectType string, subjectIDs []int64) (map[int64]map[string]int, error) {
+	return map[int64]map[string]int{}, nil
+}
+func (m *MockReactionStore) ListReactors(ctx context.Context, subjectType string, subjectID int64, reactionType string, limit, offset int) (*ReactorPage, error) {
+	return &ReactorPage{Reactors: []Reactor{}}, nil
+}
+
+type MockFeedPositionStore struct {
+}
+
+func (m *MockFeedPositionStore) Get(ctx context.Context, userID int64) (*FeedPosition, error) {
+	return nil, ErrRecordNotFound
+}
+func (m *MockFeedPositionStore) Set(ctx context.Context, userID int64, cursor string) (*FeedPosition, error) {
+	return &FeedPosition{Cursor: cursor}, nil
+}
+
+type MockFollowerStore struct {
+	Following []FollowedUser
+}
+
+func (m *MockFollowerStore) Follow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+func (m *MockFollowerStore) Unfollow(ctx context.Context, followerID, userID int64) error {
+	return nil
+}
+func (m *MockFollowerStore) ExistsFollow(ctx context.Context, followerID, userID int64) (bool, error) {
+	return false, nil
+}
+func (m *MockFollowerStore) SetNotify(ctx context.Context, followerID, userID int64, notify bool) error {
+	return nil
+}
+func (m *MockFollowerStore) GetSubscriberIDs(ctx context.Context, userID int64) ([]int64, error) {
+	return nil, nil
+}
+func (m *MockFollowerStore) EachFollowing(ctx context.Context, followerID int64, fn func(FollowedUser) error) error {
+	for _, f := range m.Following {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (m *MockFollowerStore) FollowMany(ctx context.Context, followerID int64, userIDs []int64, dryRun bool) (int, error) {
+	return len(userIDs), nil
+}
+
+type MockMailOutboxStore struct {
+	Queued []QueuedMail
+}
+
+func (m *MockMailOutboxStore) Enqueue(ctx context.Context, mail *QueuedMail) error {
+	mail.ID = int64(len(m.Queued) + 1)
+	m.Queued = append(m.Queued, *mail)
+	return nil
+}
+func (m *MockMailOutboxStore) Claim(ctx context.Context, maxAttempts, limit int, lease time.Duration) ([]QueuedMail, error) {
+	return m.Queued, nil
+}
+func (m *MockMailOutboxStore) MarkSent(ctx context.Context, id int64) error {
+	return nil
+}
+func (m *MockMailOutboxStore) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return nil
+}
+func (m *MockMailOutboxStore) Purge(ctx context.Context, before time.Time, maxAttempts int) error {
+	return nil
+}
+
+type MockSchemaStore struct {
+	CurrentVersion int
+	Dirty          bool
+}
+
+func (m *MockSchemaStore) Version(ctx context.Context) (int, bool, error) {
+	return m.CurrentVersion, m.Dirty, nil
+}
+
+type MockOutboxStore struct {
+	Pending   []OutboxEvent
+	Processed []int64
+	Failed    []int64
+}
+
+func (m *MockOutboxStore) Enqueue(ctx context.Context, topic string, aggregateID int64, payload []byte) error {
+	m.Pending = append(m.Pending, OutboxEvent{
+		ID:          int64(len(m.Pending) + 1),
+		Topic:       topic,
+		AggregateID: aggregateID,
+		Payload:     payload,
+	})
+	return nil
+}
+func (m *MockOutboxStore) GetPending(ctx context.Context, topic string, maxAttempts, limit int) ([]OutboxEvent, error) {
+	return m.Pending, nil
+}
+func (m *MockOutboxStore) MarkProcessed(ctx context.Context, ids []int64) error {
+	m.Processed = append(m.Processed, ids...)
+	return nil
+}
+func (m *MockOutboxStore) MarkFailed(ctx context.Context, ids []int64, reason string) error {
+	m.Failed = append(m.Failed, ids...)
+	return nil
+}
+func (m *MockOutboxStore) EnqueueAllPosts(ctx context.Context) (int, error) {
+	return 0, nil
+}
+func (m *MockOutboxStore) Prune(ctx context.Context, before time.Time, abandonedTopics []string) error {
+	return nil
+}
+
+// MockHashtagStore returns CountsByWindow[from] from Counts.
+type MockHashtagStore struct {
+	CountsByWindow map[time.Time]map[string]int
+}
+
+func (m *MockHashtagStore) Counts(ctx context.Context, from, to time.Time) (map[string]int, error) {
+	return m.CountsByWindow[from], nil
+}
+func (m *MockHashtagStore) RefreshRelated(ctx context.Context, since time.Time, perTag int) error {
+	return nil
+}
+func (m *MockHashtagStore) Related(ctx context.Context, tag string, limit int) ([]RelatedTag, error) {
+	return []RelatedTag{}, nil
+}
+
+// MockRiskStore records signals in memory, RecentPosts and OtherAuthors are
+// returned by the post counting methods.
+type MockRiskStore struct {
+	Signals      []RiskSignal
+	Scores       map[int64]int
+	RecentPosts  int
+	OtherAuthors int
+}
+
+func (m *MockRiskStore) Record(ctx context.Context, signal *RiskSignal, dedupe time.Duration) (bool, error) {
+	if m.Scores == nil {
+		m.Scores = make(map[int64]int)
+	}
+	m.Signals = append(m.Signals, *signal)
+	m.Scores[signal.UserID] += signal.Weight
+	return true, nil
+}
+func (m *MockRiskStore) Score(ctx context.Context, userID int64) (int, error) {
+	return m.Scores[userID], nil
+}
+func (m *MockRiskStore) Get(ctx context.Context, userID int64) (*UserRisk, error) {
+	return &UserRisk{UserID: userID, Score: m.Scores[userID], Signals: m.Signals}, nil
+}
+func (m *MockRiskStore) ListRisky(ctx context.Context, minScore, limit, offset int) ([]UserRisk, error) {
+	return []UserRisk{}, nil
+}
+func (m *MockRiskStore) Clear(ctx context.Context, userID int64) error {
+	delete(m.Scores, userID)
+	m.Signals = nil
+	return nil
+}
+func (m *MockRiskStore) RecentPostCount(ctx context.Context, userID int64, since time.Time) (int, error) {
+	return m.RecentPosts, nil
+}
+func (m *MockRiskStore) CountOtherAuthors(ctx context.Context, userID int64, fingerprint string, since time.Time) (int, error) {
+	return m.OtherAuthors, nil
+}
+
+type MockModeratorNoteStore struct {
+	Notes []ModeratorNote
+}
+
+func (m *MockModeratorNoteStore) Create(ctx context.Context, note *ModeratorNote) error {
+	note.ID = int64(len(m.Notes) + 1)
+	m.Notes = append(m.Notes, *note)
+	return nil
+}
+func (m *MockModeratorNoteStore) List(ctx context.Context, subjectType string, subjectID int64, limit, offset int) ([]ModeratorNote, error) {
+	notes := []ModeratorNote{}
+	for _, n := range m.Notes {
+		if n.SubjectType == subjectType && n.SubjectID == subjectID {
+			notes = append(notes, n)
+		}
+	}
+	return notes, nil
+}
+
+type MockModerationCaseStore struct {
+	Cases []ModerationCase
+}
+
+func (m *MockModerationCaseStore) Create(ctx context.Context, c *ModerationCase) error {
+	c.ID = int64(len(m.Cases) + 1)
+	m.Cases = append(m.Cases, *c)
+	return nil
+}
+func (m *MockModerationCaseStore) GetByID(ctx context.Context, id int64) (*ModerationCase, error) {
+	if id < 1 || int(id) > len(m.Cases) {
+		return nil, ErrRecordNotFound
+	}
+	c := m.Cases[id-1]
+	return &c, nil
+}
+func (m *MockModerationCaseStore) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]ModerationCase, error) {
+	cases := []ModerationCase{}
+	for _, c := range m.Cases {
+		if c.UserID == userID {
+			cases = append(cases, c)
+		}
+	}
+	return cases, nil
+}
+func (m *MockModerationCaseStore) ListAppeals(ctx context.Context, status string, limit, offset int) ([]ModerationCase, error) {
+	cases := []ModerationCase{}
+	for _, c := range m.Cases {
+		if c.AppealStatus == status {
+			cases = append(cases, c)
+		}
+	}
+	return cases, nil
+}
+func (m *MockModerationCaseStore) Appeal(ctx context.Context, id, userID int64, text string) (*ModerationCase, error) {
+	c, err := m.GetByID(ctx, id)
+	if err != nil || c.UserID != userID {
+		return nil, ErrRecordNotFound
+	}
+	if c.AppealStatus != "" {
+		return nil, ErrAppealExists
+	}
+	c.AppealStatus, c.AppealText = AppealPending, text
+	m.Cases[id-1] = *c
+	return c, nil
+}
+func (m *MockModerationCaseStore) Resolve(ctx context.Context, id, moderatorID int64, status, note string) (*ModerationCase, error) {
+	c, err := m.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if c.AppealStatus != AppealPending {
+		return nil, ErrAppealNotPending
+	}
+	c.AppealStatus, c.ResolvedBy, c.ResolutionNote = status, moderatorID, note
+	m.Cases[id-1] = *c
+	return c, nil
+}
+
+// MockMediaStore keeps media in memory, IDs are assigned in insert order.
+type MockMediaStore struct {
+	Media []Media
+}
+
+func (m *MockMediaStore) Create(ctx context.Context, media *Media) error {
+	media.ID = int64(len(m.Media) + 1)
+	m.Media = append(m.Media, *media)
+	return nil
+}
+func (m *MockMediaStore) GetByID(ctx context.Context, id int64) (*Media, error) {
+	for i := range m.Media {
+		if m.Media[i].ID == id {
+			media := m.Media[i]
+			return &media, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockMediaStore) GetByBlobKey(ctx context.Context, key string) (*Media, error) {
+	for i := range m.Media {
+		if m.Media[i].BlobKey == key {
+			media := m.Media[i]
+			return &media, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockMediaStore) ListByScanStatus(ctx context.Context, status string, limit, offset int) ([]Media, error) {
+	var media []Media
+	for _, item := range m.Media {
+		if item.ScanStatus == status {
+			media = append(media, item)
+		}
+	}
+	return media, nil
+}
+func (m *MockMediaStore) Review(ctx context.Context, id int64, status string, reviewerID int64) error {
+	for i := range m.Media {
+		if m.Media[i].ID == id && m.Media[i].ScanStatus == MediaScanQuarantined {
+			m.Media[i].ScanStatus = status
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+
+// MockLegalHoldStore keeps holds in memory. Subjects always exist.
+type MockLegalHoldStore struct {
+	Holds []LegalHold
+}
+
+func (m *MockLegalHoldStore) Place(ctx context.Context, hold *LegalHold) error {
+	for _, h := range m.Holds {
+		if h.SubjectType == hold.SubjectType && h.SubjectID == hold.SubjectID && h.ReleasedAt == nil {
+			return ErrHoldExists
+		}
+	}
+	hold.ID = int64(len(m.Holds) + 1)
+	m.Holds = append(m.Holds, *hold)
+	return nil
+}
+func (m *MockLegalHoldStore) Release(ctx context.Context, id, releasedBy int64) (*LegalHold, error) {
+	for i := range m.Holds {
+		if m.Holds[i].ID == id && m.Holds[i].ReleasedAt == nil {
+			now := time.Now()
+			m.Holds[i].ReleasedBy = releasedBy
+			m.Holds[i].ReleasedAt = &now
+			hold := m.Holds[i]
+			return &hold, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockLegalHoldStore) GetByID(ctx context.Context, id int64) (*LegalHold, error) {
+	for _, h := range m.Holds {
+		if h.ID == id {
+			return &h, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockLegalHoldStore) List(ctx context.Context, all bool, limit, offset int) ([]LegalHold, error) {
+	holds := []LegalHold{}
+	for _, h := range m.Holds {
+		if all || h.ReleasedAt == nil {
+			holds = append(holds, h)
+		}
+	}
+	return holds, nil
+}
+func (m *MockLegalHoldStore) IsHeld(ctx context.Context, subjectType string, subjectID int64) (bool, error) {
+	for _, h := range m.Holds {
+		if h.SubjectType == subjectType && h.SubjectID == subjectID && h.ReleasedAt == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *MockLegalHoldStore) Export(ctx context.Context, hold *LegalHold) (*HoldExport, error) {
+	return &HoldExport{Hold: *hold, ExportedAt: time.Now()}, nil
+}
+func (m *MockLegalHoldStore) StreamExport(ctx context.Context, hold *LegalHold, emit func(HoldRecord) error) error {
+	return emit(HoldRecord{Type: "hold", Data: *hold})
+}
+
+// MockAnnouncementStore keeps announcements in memory, every one of them is
+// treated as running. Dismissed maps announcement IDs to the users who
+// dismissed them.
+type MockAnnouncementStore struct {
+	Announcements []Announcement
+	Dismissed     map[int64][]int64
+}
+
+func (m *MockAnnouncementStore) Create(ctx context.Context, a *Announcement) error {
+	a.ID = int64(len(m.Announcements) + 1)
+	if a.StartsAt.IsZero() {
+		a.StartsAt = time.Now()
+	}
+	m.Announcements = append(m.Announcements, *a)
+	return nil
+}
+func (m *MockAnnouncementStore) ListActive(ctx context.Context, userID int64) ([]Announcement, error) {
+	active := []Announcement{}
+	for _, a := range m.Announcements {
+		if !slices.Contains(m.Dismissed[a.ID], userID) {
+			active = append(active, a)
+		}
+	}
+	return active, nil
+}
+func (m *MockAnnouncementStore) List(ctx context.Context, limit, offset int) ([]Announcement, error) {
+	return m.Announcements, nil
+}
+func (m *MockAnnouncementStore) Delete(ctx context.Context, id int64) error {
+	for i, a := range m.Announcements {
+		if a.ID == id {
+			m.Announcements = slices.Delete(m.Announcements, i, i+1)
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+func (m *MockAnnouncementStore) Dismiss(ctx context.Context, id, userID int64) error {
+	for _, a := range m.Announcements {
+		if a.ID == id {
+			if m.Dismissed == nil {
+				m.Dismissed = make(map[int64][]int64)
+			}
+			m.Dismissed[id] = append(m.Dismissed[id], userID)
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+
+// MockTermsStore keeps published versions in memory, Acceptances maps users
+// to the latest terms ID they accepted.
+type MockTermsStore struct {
+	Versions    []TermsVersion
+	Acceptances map[int64]int64
+}
+
+func (m *MockTermsStore) Publish(ctx context.Context, t *TermsVersion) error {
+	for _, v := range m.Versions {
+		if v.Kind == t.Kind && v.Version == t.Version {
+			return ErrConflict
+		}
+	}
+	t.ID = int64(len(m.Versions) + 1)
+	m.Versions = append(m.Versions, *t)
+	return nil
+}
+func (m *MockTermsStore) Current(ctx context.Context) ([]TermsVersion, error) {
+	latest := map[string]TermsVersion{}
+	for _, v := range m.Versions {
+		latest[v.Kind] = v
+	}
+	current := []TermsVersion{}
+	for _, kind := range []string{PrivacyPolicy, TermsOfService} {
+		if v, ok := latest[kind]; ok {
+			current = append(current, v)
+		}
+	}
+	return current, nil
+}
+func (m *MockTermsStore) List(ctx context.Context, limit, offset int) ([]TermsVersion, error) {
+	return m.Versions, nil
+}
+func (m *MockTermsStore) Accepted(ctx context.Context, userID int64) ([]TermsVersion, error) {
+	accepted := []TermsVersion{}
+	for _, v := range m.Versions {
+		if v.ID <= m.Acceptances[userID] {
+			accepted = append(accepted, v)
+		}
+	}
+	return accepted, nil
+}
+func (m *MockTermsStore) Accept(ctx context.Context, userID, termsID int64) error {
+	if termsID != int64(len(m.Versions)) {
+		return ErrTermsChanged
+	}
+	if m.Acceptances == nil {
+		m.Acceptances = make(map[int64]int64)
+	}
+	m.Acceptances[userID] = termsID
+	return nil
+}
+
+type MockImpressionStore struct{}
+
+func (m *MockImpressionStore) Record(ctx context.Context, postID, viewerID int64, source string) error {
+	return nil
+}
+func (m *MockImpressionStore) Insights(ctx context.Context, postID, authorID int64, since time.Time) (*PostInsights, error) {
+	return &PostInsights{PostID: postID}, nil
+}
+
+type MockAccountMergeStore struct {
+	Merges []AccountMerge
+}
+
+func (m *MockAccountMergeStore) Merge(ctx context.Context, merge *AccountMerge) error {
+	for _, prev := range m.Merges {
+		if prev.SourceID == merge.SourceID || prev.SourceID == merge.TargetID {
+			return ErrRecordNotFound
+		}
+	}
+	merge.ID = int64(len(m.Merges) + 1)
+	m.Merges = append(m.Merges, *merge)
+	return nil
+}
+func (m *MockAccountMergeStore) List(ctx context.Context, limit, offset int) ([]AccountMerge, error) {
+	merges := slices.Clone(m.Merges)
+	slices.Reverse(merges)
+	return merges, nil
+}
+
+// MockPartitionStore keeps partitions in memory.
+type MockPartitionStore struct {
+	Partitions []Partition
+}
+
+func (m *MockPartitionStore) List(ctx context.Context, table string) ([]Partition, error) {
+	var out []Partition
+	for _, p := range m.Partitions {
+		if p.Table == table {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+func (m *MockPartitionStore) Create(ctx context.Context, p Partition) error {
+	if !slices.Contains(m.Partitions, p) {
+		m.Partitions = append(m.Partitions, p)
+	}
+	return nil
+}
+func (m *MockPartitionStore) Drop(ctx context.Context, p Partition) error {
+	m.Partitions = slices.DeleteFunc(m.Partitions, func(q Partition) bool { return q == p })
+	return nil
+}
+
+// MockArchiveStore archives the posts listed in Posts, each with a comment
+// unless Rows has the archive to hand over. Restore keeps what it was given
+// in RestoredArchives.
+type MockArchiveStore struct {
+	Posts            []int64
+	Rows             map[int64]PostArchive
+	Archived         []ArchivedPost
+	Restored         []int64
+	RestoredArchives []PostArchive
+}
+
+func (m *MockArchiveStore) Candidates(ctx context.Context, cutoff time.Time, limit int) ([]int64, error) {
+	return slices.Clone(m.Posts[:min(limit, len(m.Posts))]), nil
+}
+func (m *MockArchiveStore) Archive(ctx context.Context, postID int64, put func(context.Context, *PostArchive) (string, error)) error {
+	i := slices.Index(m.Posts, postID)
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	archive, ok := m.Rows[postID]
+	if !ok {
+		archive = PostArchive{
+			Post:      []byte(fmt.Sprintf(`{"id":%d,"user_id":1}`, postID)),
+			Comments:  []byte(fmt.Sprintf(`[{"id":1,"post_id":%d}]`, postID)),
+			Reactions: []byte("[]"),
+		}
+	}
+	key, err := put(ctx, &archive)
+	if err != nil {
+		return err
+	}
+	m.Posts = slices.Delete(m.Posts, i, i+1)
+	m.Archived = append(m.Archived, ArchivedPost{PostID: postID, UserID: 1, BlobKey: key})
+	return nil
+}
+func (m *MockArchiveStore) Get(ctx context.Context, postID int64) (*ArchivedPost, error) {
+	for _, a := range m.Archived {
+		if a.PostID == postID {
+			return &a, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockArchiveStore) List(ctx context.Context, userID int64, limit, offset int) ([]ArchivedPost, error) {
+	return m.Archived, nil
+}
+func (m *MockArchiveStore) Restore(ctx context.Context, postID int64, archive *PostArchive) error {
+	if _, err := m.Get(ctx, postID); err != nil {
+		return err
+	}
+	m.Archived = slices.DeleteFunc(m.Archived, func(a ArchivedPost) bool { return a.PostID == postID })
+	m.Restored = append(m.Restored, postID)
+	m.RestoredArchives = append(m.RestoredArchives, *archive)
+	return nil
+}
+func (m *MockArchiveStore) PruneOrphans(ctx context.Context, limit int) ([]ArchivedPost, error) {
+	return nil, nil
+}
+
+type MockTombstoneStore struct {
+	Tombstones []PostTombstone
+}
+
+func (m *MockTombstoneStore) Create(ctx context.Context, t *PostTombstone) error {
+	t.RemovedAt = time.Now()
+	m.Tombstones = append(m.Tombstones, *t)
+	return nil
+}
+func (m *MockTombstoneStore) Get(ctx context.Context, postID int64) (*PostTombstone, error) {
+	for _, t := range m.Tombstones {
+		if t.PostID == postID {
+			return &t, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+type MockInstanceStore struct {
+	Settings *InstanceSettings
+}
+
+func (m *MockInstanceStore) Get(ctx context.Context) (*InstanceSettings, error) {
+	if m.Settings == nil {
+		return &InstanceSettings{Name: "GopherSocial", Rules: []string{}, Limits: DefaultContentLimits}, nil
+	}
+	i := *m.Settings
+	return &i, nil
+}
+func (m *MockInstanceStore) Update(ctx context.Context, i *InstanceSettings) error {
+	i.UpdatedAt = time.Now()
+	s := *i
+	m.Settings = &s
+	return nil
+}
+
+type MockSessionStore struct {
+	Sessions []Session
+}
+
+func (m *MockSessionStore) Create(ctx context.Context, s *Session) error {
+	s.ID = int64(len(m.Sessions) + 1)
+	s.CreatedAt, s.LastSeenAt = time.Now(), time.Now()
+	m.Sessions = append(m.Sessions, *s)
+	return nil
+}
+func (m *MockSessionStore) Get(ctx context.Context, id int64) (*Session, error) {
+	if id < 1 || int(id) > len(m.Sessions) {
+		return nil, ErrRecordNotFound
+	}
+	s := m.Sessions[id-1]
+	return &s, nil
+}
+func (m *MockSessionStore) Touch(ctx context.Context, id int64, ip string) error {
+	if id >= 1 && int(id) <= len(m.Sessions) {
+		m.Sessions[id-1].LastSeenAt, m.Sessions[id-1].IP = time.Now(), ip
+	}
+	return nil
+}
+func (m *MockSessionStore) Extend(ctx context.Context, id int64, expiresAt time.Time) error {
+	if id < 1 || int(id) > len(m.Sessions) || m.Sessions[id-1].RevokedAt != nil {
+		return ErrRecordNotFound
+	}
+	m.Sessions[id-1].ExpiresAt = expiresAt
+	return nil
+}
+func (m *MockSessionStore) ListActive(ctx context.Context, userID int64) ([]Session, error) {
+	sessions := []Session{}
+	for _, s := range m.Sessions {
+		if s.UserID == userID && s.Active(time.Now()) {
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions, nil
+}
+func (m *MockSessionStore) Revoke(ctx context.Context, userID, id int64) error {
+	if id < 1 || int(id) > len(m.Sessions) || m.Sessions[id-1].UserID != userID || m.Sessions[id-1].RevokedAt != nil {
+		return ErrRecordNotFound
+	}
+	now := time.Now()
+	m.Sessions[id-1].RevokedAt = &now
+	return nil
+}
+func (m *MockSessionStore) Prune(ctx context.Context) error {
+	return nil
+}
+
+type MockPushDeviceStore struct {
+	Devices []PushDevice
+}
+
+func (m *MockPushDeviceStore) Register(ctx context.Context, d *PushDevice) error {
+	for i, existing := range m.Devices {
+		if existing.Token == d.Token {
+			d.ID = existing.ID
+			m.Devices[i] = *d
+			return nil
+		}
+	}
+	d.ID = int64(len(m.Devices) + 1)
+	m.Devices = append(m.Devices, *d)
+	return nil
+}
+func (m *MockPushDeviceStore) ListByUser(ctx context.Context, userID int64) ([]PushDevice, error) {
+	return m.ListForNotification(ctx, []int64{userID}, "")
+}
+func (m *MockPushDeviceStore) ListForNotification(ctx context.Context, userIDs []int64, notificationType string) ([]PushDevice, error) {
+	devices := []PushDevice{}
+	for _, d := range m.Devices {
+		if slices.Contains(userIDs, d.UserID) {
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+func (m *MockPushDeviceStore) Delete(ctx context.Context, userID, id int64) error {
+	for i, d := range m.Devices {
+		if d.ID == id && d.UserID == userID {
+			m.Devices = slices.Delete(m.Devices, i, i+1)
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+func (m *MockPushDeviceStore) DeleteByToken(ctx context.Context, token string) error {
+	m.Devices = slices.DeleteFunc(m.Devices, func(d PushDevice) bool { return d.Token == token })
+	return nil
+}
+
+type MockAPIKeyStore struct {
+	Keys    []APIKey
+	Secrets map[string]int64
+}
+
+func (m *MockAPIKeyStore) Create(ctx context.Context, key *APIKey, secret string) error {
+	if m.Secrets == nil {
+		m.Secrets = make(map[string]int64)
+	}
+	key.ID = int64(len(m.Keys) + 1)
+	key.Prefix = secret[:len(APIKeyPrefix)+8]
+	m.Keys = append(m.Keys, *key)
+	m.Secrets[secret] = key.ID
+	return nil
+}
+func (m *MockAPIKeyStore) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	id, ok := m.Secrets[secret]
+	if !ok || m.Keys[id-1].RevokedAt != nil {
+		return nil, ErrRecordNotFound
+	}
+	key := m.Keys[id-1]
+	return &key, nil
+}
+func (m *MockAPIKeyStore) ListByUser(ctx context.Context, userID int64) ([]APIKey, error) {
+	keys := []APIKey{}
+	for _, k := range slices.Backward(m.Keys) {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+func (m *MockAPIKeyStore) Revoke(ctx context.Context, userID, id int64) error {
+	if id < 1 || id > int64(len(m.Keys)) || m.Keys[id-1].UserID != userID || m.Keys[id-1].RevokedAt != nil {
+		return ErrRecordNotFound
+	}
+	revoked := time.Now()
+	m.Keys[id-1].RevokedAt = &revoked
+	return nil
+}
+
+type MockOAuthStore struct {
+	Clients []OAuthClient
+	Codes   map[string]OAuthCode
+	grants  map[int64][]OAuthGrant
+	grantID int64
+}
+
+func (m *MockOAuthStore) CreateClient(ctx context.Context, c *OAuthClient, secret string) error {
+	c.ID = int64(len(m.Clients) + 1)
+	c.secretHash = hashSecret(secret)
+	m.Clients = append(m.Clients, *c)
+	return nil
+}
+func (m *MockOAuthStore) GetClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	for _, c := range m.Clients {
+		if c.ClientID == clientID && c.RevokedAt == nil {
+			return &c, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockOAuthStore) AuthenticateClient(ctx context.Context, clientID, secret string) (*OAuthClient, error) {
+	c, err := m.GetClient(ctx, clientID)
+	if err != nil || c.secretHash != hashSecret(secret) {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+func (m *MockOAuthStore) ListClients(ctx context.Context, ownerID int64) ([]OAuthClient, error) {
+	clients := []OAuthClient{}
+	for _, c := range slices.Backward(m.Clients) {
+		if c.OwnerID == ownerID {
+			clients = append(clients, c)
+		}
+	}
+	return clients, nil
+}
+func (m *MockOAuthStore) RevokeClient(ctx context.Context, ownerID int64, clientID string) error {
+	for i, c := range m.Clients {
+		if c.ClientID == clientID && c.OwnerID == ownerID && c.RevokedAt == nil {
+			revoked := time.Now()
+			m.Clients[i].RevokedAt = &revoked
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+func (m *MockOAuthStore) CreateCode(ctx context.Context, code *OAuthCode, secret string) error {
+	if m.Codes == nil {
+		m.Codes = make(map[string]OAuthCode)
+	}
+	m.Codes[secret] = *code
+	return nil
+}
+func (m *MockOAuthStore) ExchangeCode(ctx context.Context, clientID int64, secret string) (*OAuthCode, error) {
+	code, ok := m.Codes[secret]
+	if !ok || code.ClientID != clientID || !code.ExpiresAt.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+	delete(m.Codes, secret)
+	client := m.Clients[code.ClientID-1]
+	if m.grants == nil {
+		m.grants = make(map[int64][]OAuthGrant)
+	}
+	i := slices.IndexFunc(m.grants[code.UserID], func(g OAuthGrant) bool { return g.ClientID == client.ClientID })
+	if i < 0 {
+		m.grantID++
+		m.grants[code.UserID] = append(m.grants[code.UserID], OAuthGrant{ID: m.grantID, ClientID: client.ClientID, Name: client.Name})
+		i = len(m.grants[code.UserID]) - 1
+	}
+	g := &m.grants[code.UserID][i]
+	g.Scopes = slices.Compact(slices.Sorted(slices.Values(append(g.Scopes, code.Scopes...))))
+	code.GrantID = g.ID
+	return &code, nil
+}
+func (m *MockOAuthStore) PruneCodes(ctx context.Context) error {
+	return nil
+}
+func (m *MockOAuthStore) GetGrant(ctx context.Context, userID int64, clientID string) (*OAuthGrant, error) {
+	if _, err := m.GetClient(ctx, clientID); err != nil {
+		return nil, err
+	}
+	for _, g := range m.grants[userID] {
+		if g.ClientID == clientID {
+			return &g, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockOAuthStore) ListGrants(ctx context.Context, userID int64) ([]OAuthGrant, error) {
+	grants := []OAuthGrant{}
+	for _, g := range m.grants[userID] {
+		if _, err := m.GetClient(ctx, g.ClientID); err == nil {
+			grants = append(grants, g)
+		}
+	}
+	return grants, nil
+}
+func (m *MockOAuthStore) RevokeGrant(ctx context.Context, userID int64, clientID string) error {
+	n := len(m.grants[userID])
+	m.grants[userID] = slices.DeleteFunc(m.grants[userID], func(g OAuthGrant) bool { return g.ClientID == clientID })
+	if len(m.grants[userID]) == n {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// MockCounterStore reports Pending posts compacted once.
+type MockCounterStore struct {
+	Pending int
+}
+
+func (m *MockCounterStore) Compact(ctx context.Context) (int, error) {
+	n := m.Pending
+	m.Pending = 0
+	return n, nil
+}
+
+// MockRecoveryStore keeps contacts and requests in memory, Passwords holds
+// what Redeem set per user.
+type MockRecoveryStore struct {
+	Contacts  map[int64][]int64
+	Requests  []RecoveryRequest
+	Passwords map[int64]string
+	approvals map[int64][]int64
+	links     map[string]int64
+	closed    map[int64]bool
+}
+
+func (m *MockRecoveryStore) open(userID int64) *RecoveryRequest {
+	for i, r := range m.Requests {
+		if r.UserID == userID && !m.closed[r.ID] && r.ExpiresAt.After(time.Now()) {
+			return &m.Requests[i]
+		}
+	}
+	return nil
+}
+func (m *MockRecoveryStore) SetContacts(ctx context.Context, userID int64, contactIDs []int64) error {
+	if slices.Contains(contactIDs, userID) {
+		return ErrRecordNotFound
+	}
+	if m.Contacts == nil {
+		m.Contacts = make(map[int64][]int64)
+	}
+	m.Contacts[userID] = contactIDs
+	if r := m.open(userID); r != nil {
+		m.closed[r.ID] = true
+	}
+	return nil
+}
+func (m *MockRecoveryStore) ListContacts(ctx context.Context, userID int64) ([]RecoveryContact, error) {
+	contacts := []RecoveryContact{}
+	for _, id := range m.Contacts[userID] {
+		contacts = append(contacts, RecoveryContact{ContactID: id, Username: fmt.Sprintf("user%d", id)})
+	}
+	return contacts, nil
+}
+func (m *MockRecoveryStore) Open(ctx context.Context, userID int64, quorum int, expiry time.Duration) (*RecoveryRequest, []int64, error) {
+	contacts := m.Contacts[userID]
+	if len(contacts) < quorum {
+		return nil, nil, ErrRecordNotFound
+	}
+	if m.open(userID) != nil {
+		return nil, nil, ErrConflict
+	}
+	if m.closed == nil {
+		m.approvals, m.links, m.closed = make(map[int64][]int64), make(map[string]int64), make(map[int64]bool)
+	}
+	req := RecoveryRequest{ID: int64(len(m.Requests) + 1), UserID: userID, Username: fmt.Sprintf("user%d", userID), ExpiresAt: time.Now().Add(expiry)}
+	m.Requests = append(m.Requests, req)
+	return &req, contacts, nil
+}
+func (m *MockRecoveryStore) Cancel(ctx context.Context, userID int64) error {
+	r := m.open(userID)
+	if r == nil {
+		return ErrRecordNotFound
+	}
+	m.closed[r.ID] = true
+	return nil
+}
+func (m *MockRecoveryStore) Pending(ctx context.Context, contactID int64) ([]RecoveryRequest, error) {
+	requests := []RecoveryRequest{}
+	for _, r := range m.Requests {
+		if open := m.open(r.UserID); open != nil && open.ID == r.ID && r.ReadyAt == nil &&
+			slices.Contains(m.Contacts[r.UserID], contactID) && !slices.Contains(m.approvals[r.ID], contactID) {
+			requests = append(requests, r)
+		}
+	}
+	return requests, nil
+}
+func (m *MockRecoveryStore) Approve(ctx context.Context, requestID, contactID int64, quorum int, delay time.Duration) (*RecoveryRequest, error) {
+	if requestID < 1 || requestID > int64(len(m.Requests)) {
+		return nil, ErrRecordNotFound
+	}
+	r := &m.Requests[requestID-1]
+	if m.closed[r.ID] || !r.ExpiresAt.After(time.Now()) || !slices.Contains(m.Contacts[r.UserID], contactID) {
+		return nil, ErrRecordNotFound
+	}
+	if slices.Contains(m.approvals[r.ID], contactID) {
+		return nil, ErrConflict
+	}
+	m.approvals[r.ID] = append(m.approvals[r.ID], contactID)
+	r.Approvals++
+	if r.Approvals >= quorum && r.ReadyAt == nil {
+		readyAt := time.Now().Add(delay)
+		r.ReadyAt = &readyAt
+	}
+	req := *r
+	return &req, nil
+}
+func (m *MockRecoveryStore) Ready(ctx context.Context, limit int) ([]RecoveryRequest, error) {
+	var requests []RecoveryRequest
+	for _, r := range m.Requests {
+		if !m.closed[r.ID] && r.ReadyAt != nil && !r.ReadyAt.After(time.Now()) && !slices.Contains(slices.Collect(maps.Values(m.links)), r.ID) {
+			requests = append(requests, r)
+		}
+	}
+	return requests, nil
+}
+func (m *MockRecoveryStore) IssueLink(ctx context.Context, requestID int64, expiry time.Duration) (string, error) {
+	secret := fmt.Sprintf("recovery-%d", requestID)
+	m.links[secret] = requestID
+	return secret, nil
+}
+func (m *MockRecoveryStore) RevokeLink(ctx context.Context, requestID int64) error {
+	maps.DeleteFunc(m.links, func(secret string, id int64) bool { return id == requestID })
+	return nil
+}
+func (m *MockRecoveryStore) Redeem(ctx context.Context, secret, newPassword string) (int64, error) {
+	id, ok := m.links[secret]
+	if !ok || m.closed[id] {
+		return 0, ErrRecordNotFound
+	}
+	m.closed[id] = true
+	userID := m.Requests[id-1].UserID
+	if m.Passwords == nil {
+		m.Passwords = make(map[int64]string)
+	}
+	m.Passwords[userID] = newPassword
+	return userID, nil
+}
+
+// MockEmojiStore keeps emoji in memory in upload order.
+type MockEmojiStore struct {
+	Emoji []Emoji
+}
+
+func (m *MockEmojiStore) Create(ctx context.Context, e *Emoji) error {
+	for _, existing := range m.Emoji {
+		if existing.Shortcode == e.Shortcode {
+			return ErrConflict
+		}
+	}
+	e.CreatedAt = time.Now()
+	m.Emoji = append(m.Emoji, *e)
+	return nil
+}
+func (m *MockEmojiStore) List(ctx context.Context) ([]Emoji, error) {
+	return slices.Clone(m.Emoji), nil
+}
+func (m *MockEmojiStore) Get(ctx context.Context, shortcode string) (*Emoji, error) {
+	for _, e := range m.Emoji {
+		if e.Shortcode == shortcode {
+			return &e, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockEmojiStore) Delete(ctx context.Context, shortcode string) (*Emoji, error) {
+	for i, e := range m.Emoji {
+		if e.Shortcode == shortcode {
+			m.Emoji = slices.Delete(m.Emoji, i, i+1)
+			return &e, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+// MockProfileFieldStore keeps fields in memory per user. Every link field is
+// due for verification until Checked.
+type MockProfileFieldStore struct {
+	Fields  map[int64][]ProfileField
+	checked map[ProfileFieldCheck]bool
+}
+
+func (m *MockProfileFieldStore) Set(ctx context.Context, userID int64, fields []ProfileField) error {
+	if m.Fields == nil {
+		m.Fields = make(map[int64][]ProfileField)
+	}
+	m.Fields[userID] = slices.Clone(fields)
+	return nil
+}
+func (m *MockProfileFieldStore) List(ctx context.Context, userID int64) ([]ProfileField, error) {
+	return append([]ProfileField{}, m.Fields[userID]...), nil
+}
+func (m *MockProfileFieldStore) Due(ctx context.Context, recheck time.Duration, limit int) ([]ProfileFieldCheck, error) {
+	var due []ProfileFieldCheck
+	for userID, fields := range m.Fields {
+		for i, f := range fields {
+			c := ProfileFieldCheck{UserID: userID, Username: "gopher", Position: i, Value: f.Value}
+			if strings.HasPrefix(f.Value, "http") && !m.checked[c] {
+				due = append(due, c)
+			}
+		}
+	}
+	return due, nil
+}
+func (m *MockProfileFieldStore) Checked(ctx context.Context, c ProfileFieldCheck, verified bool) error {
+	if m.checked == nil {
+		m.checked = make(map[ProfileFieldCheck]bool)
+	}
+	m.checked[c] = true
+	fields := m.Fields[c.UserID]
+	if c.Position < len(fields) && fields[c.Position].Value == c.Value {
+		var at *time.Time
+		if verified {
+			now := time.Now()
+			at = &now
+		}
+		fields[c.Position].VerifiedAt = at
+	}
+	return nil
+}
+
+// MockBadgeStore keeps the badges granted per user. Assign grants nothing.
+type MockBadgeStore struct {
+	Badges map[int64][]string
+}
+
+func (m *MockBadgeStore) Grant(ctx context.Context, userID int64, badge string, grantedBy int64) error {
+	if m.Badges == nil {
+		m.Badges = make(map[int64][]string)
+	}
+	if !slices.Contains(m.Badges[userID], badge) {
+		m.Badges[userID] = append(m.Badges[userID], badge)
+	}
+	return nil
+}
+func (m *MockBadgeStore) Revoke(ctx context.Context, userID int64, badge string) error {
+	i := slices.Index(m.Badges[userID], badge)
+	if i < 0 {
+		return ErrRecordNotFound
+	}
+	m.Badges[userID] = slices.Delete(m.Badges[userID], i, i+1)
+	return nil
+}
+func (m *MockBadgeStore) Assign(ctx context.Context, staffRole string, earlyAdopters int) ([]int64, error) {
+	return nil, nil
+}
+
+// MockEventStore keeps events in memory. Every event counts as upcoming and
+// RSVPs maps event IDs to each user's answer.
+type MockEventStore struct {
+	Events   []Event
+	RSVPs    map[int64]map[int64]string
+	reminded map[int64]bool
+}
+
+func (m *MockEventStore) find(id int64) *Event {
+	for i := range m.Events {
+		if m.Events[i].ID == id {
+			return &m.Events[i]
+		}
+	}
+	return nil
+}
+func (m *MockEventStore) Create(ctx context.Context, post *Post, e *Event) error {
+	post.Kind = PostKindEvent
+	post.ID = int64(len(m.Events) + 1)
+	e.ID, e.UserID, e.Title, e.Description = post.ID, post.UserID, post.Title, post.Content
+	m.Events = append(m.Events, *e)
+	return nil
+}
+func (m *MockEventStore) Get(ctx context.Context, id, viewerID int64) (*Event, error) {
+	e := m.find(id)
+	if e == nil {
+		return nil, ErrRecordNotFound
+	}
+	got := *e
+	got.Going, got.Interested = 0, 0
+	for _, status := range m.RSVPs[id] {
+		switch status {
+		case RSVPGoing:
+			got.Going++
+		case RSVPInterested:
+			got.Interested++
+		}
+	}
+	got.RSVP = m.RSVPs[id][viewerID]
+	return &got, nil
+}
+func (m *MockEventStore) Upcoming(ctx context.Context, userID int64, limit, offset int) ([]Event, error) {
+	events := []Event{}
+	for _, e := range m.Events {
+		if e.CanceledAt == nil {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+func (m *MockEventStore) RSVP(ctx context.Context, id, userID int64, status string) error {
+	if e := m.find(id); e == nil || e.CanceledAt != nil {
+		return ErrRecordNotFound
+	}
+	if m.RSVPs == nil {
+		m.RSVPs = make(map[int64]map[int64]string)
+	}
+	if m.RSVPs[id] == nil {
+		m.RSVPs[id] = make(map[int64]string)
+	}
+	m.RSVPs[id][userID] = status
+	return nil
+}
+func (m *MockEventStore) RemoveRSVP(ctx context.Context, id, userID int64) error {
+	if _, ok := m.RSVPs[id][userID]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(m.RSVPs[id], userID)
+	return nil
+}
+func (m *MockEventStore) Attendees(ctx context.Context, id int64, limit, offset int) ([]EventAttendee, error) {
+	attendees := []EventAttendee{}
+	for _, userID := range slices.Sorted(maps.Keys(m.RSVPs[id])) {
+		attendees = append(attendees, EventAttendee{UserID: userID, Status: m.RSVPs[id][userID]})
+	}
+	return attendees, nil
+}
+func (m *MockEventStore) Cancel(ctx context.Context, id, organizerID int64) ([]int64, error) {
+	e := m.find(id)
+	if e == nil || e.UserID != organizerID || e.CanceledAt != nil {
+		return nil, ErrRecordNotFound
+	}
+	now := time.Now()
+	e.CanceledAt = &now
+	return slices.Sorted(maps.Keys(m.RSVPs[id])), nil
+}
+func (m *MockEventStore) ClaimReminders(ctx context.Context, lead time.Duration, limit int) ([]EventReminder, error) {
+	if m.reminded == nil {
+		m.reminded = make(map[int64]bool)
+	}
+	var reminders []EventReminder
+	for _, e := range m.Events {
+		if m.reminded[e.ID] || e.CanceledAt != nil || time.Until(e.StartsAt) > lead {
+			continue
+		}
+		m.reminded[e.ID] = true
+		reminders = append(reminders, EventReminder{EventID: e.ID, OrganizerID: e.UserID, AttendeeIDs: slices.Sorted(maps.Keys(m.RSVPs[e.ID]))})
+	}
+	return reminders, nil
+}
+
+// MockListingStore keeps listings in memory, none of them expire.
+type MockListingStore struct {
+	Listings []Listing
+}
+
+func (m *MockListingStore) Create(ctx context.Context, post *Post, l *Listing) error {
+	post.Kind = PostKindListing
+	post.ID = int64(len(m.Listings) + 1)
+	l.ID, l.UserID, l.Title, l.Description, l.Tags = post.ID, post.UserID, post.Title, post.Content, post.Tags
+	l.Status = ListingPending
+	m.Listings = append(m.Listings, *l)
+	return nil
+}
+func (m *MockListingStore) Get(ctx context.Context, id int64) (*Listing, error) {
+	for _, l := range m.Listings {
+		if l.ID == id {
+			return &l, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+func (m *MockListingStore) Feed(ctx context.Context, category string, limit, offset int) ([]Listing, error) {
+	listings := []Listing{}
+	for _, l := range slices.Backward(m.Listings) {
+		if l.Status == ListingApproved && (category == "" || l.Category == category) {
+			listings = append(listings, l)
+		}
+	}
+	return listings, nil
+}
+func (m *MockListingStore) ListPending(ctx context.Context, limit, offset int) ([]Listing, error) {
+	listings := []Listing{}
+	for _, l := range m.Listings {
+		if l.Status == ListingPending {
+			listings = append(listings, l)
+		}
+	}
+	return listings, nil
+}
+func (m *MockListingStore) Review(ctx context.Context, id, moderatorID int64, status string) (*Listing, error) {
+	for i := range m.Listings {
+		if m.Listings[i].ID == id {
+			m.Listings[i].Status = status
+			m.Listings[i].ReviewedBy = moderatorID
+			l := m.Listings[i]
+			return &l, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+// MockSnippetStore returns the snippets set per post.
+type MockSnippetStore struct {
+	Snippets map[int64][]Snippet
+}
+
+func (m *MockSnippetStore) GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64][]Snippet, error) {
+	snippets := make(map[int64][]Snippet)
+	for _, id := range postIDs {
+		if s, ok := m.Snippets[id]; ok {
+			snippets[id] = slices.Clone(s)
+		}
+	}
+	return snippets, nil
+}
+
+// MockRepoCardStore keeps cards by post, pending ones have a zero FetchedAt.
+type MockRepoCardStore struct {
+	Cards map[int64]RepoCard
+}
+
+func (m *MockRepoCardStore) Add(ctx context.Context, postID int64, owner, name string) error {
+	if m.Cards == nil {
+		m.Cards = make(map[int64]RepoCard)
+	}
+	if _, ok := m.Cards[postID]; !ok {
+		m.Cards[postID] = RepoCard{PostID: postID, Owner: owner, Name: name}
+	}
+	return nil
+}
+
+func (m *MockRepoCardStore) Due(ctx context.Context, refresh time.Duration, limit int) ([]RepoCard, error) {
+	var due []RepoCard
+	for _, c := range m.Cards {
+		if len(due) < limit && (c.FetchedAt.IsZero() || time.Since(c.FetchedAt) > refresh) {
+			due = append(due, c)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockRepoCardStore) Fetched(ctx context.Context, card *RepoCard) error {
+	if _, ok := m.Cards[card.PostID]; !ok {
+		return ErrRecordNotFound
+	}
+	card.FetchedAt = time.Now()
+	m.Cards[card.PostID] = *card
+	return nil
+}
+
+func (m *MockRepoCardStore) Remove(ctx context.Context, postID int64) error {
+	delete(m.Cards, postID)
+	return nil
+}
+
+func (m *MockRepoCardStore) GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64]RepoCard, error) {
+	cards := make(map[int64]RepoCard)
+	for _, id := range postIDs {
+		if c, ok := m.Cards[id]; ok && !c.FetchedAt.IsZero() {
+			cards[id] = c
+		}
+	}
+	return cards, nil
+}
+
+// MockQuestionStore keeps questions in memory, Answers are the comments
+// Accept can pick from.
+type MockQuestionStore struct {
+	Questions []Post
+	Answers   map[int64]Comment
+}
+
+func (m *MockQuestionStore) Create(ctx context.Context, post *Post) error {
+	post.Kind = PostKindQuestion
+	post.ID = int64(len(m.Questions) + 1)
+	m.Questions = append(m.Questions, *post)
+	return nil
+}
+
+func (m *MockQuestionStore) Accept(ctx context.Context, postID, commentID int64) (*Comment, error) {
+	answer, ok := m.Answers[commentID]
+	if !ok || answer.PostID != postID || !answer.Answer {
+		return nil, ErrRecordNotFound
+	}
+	for i := range m.Questions {
+		if m.Questions[i].ID == postID {
+			m.Questions[i].AcceptedAnswerID = &answer.ID
+			answer.Accepted = true
+			return &answer, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m *MockQuestionStore) Unaccept(ctx context.Context, postID int64) error {
+	for i := range m.Questions {
+		if m.Questions[i].ID == postID {
+			m.Questions[i].AcceptedAnswerID = nil
+		}
+	}
+	return nil
+}
+
+func (m *MockQuestionStore) Unanswered(ctx context.Context, tag string, limit, offset int) ([]Post, error) {
+	questions := []Post{}
+	for _, p := range slices.Backward(m.Questions) {
+		if p.AcceptedAnswerID == nil && (tag == "" || slices.Contains(p.Tags, tag)) {
+			questions = append(questions, p)
+		}
+	}
+	return questions, nil
+}
+
+// MockSpaceStore keeps spaces in memory. Followed maps a follower to the
+// hosts they follow, for List.
+type MockSpaceStore struct {
+	Spaces   []Space
+	Followed map[int64][]int64
+}
+
+func (m *MockSpaceStore) find(id int64) *Space {
+	for i := range m.Spaces {
+		if m.Spaces[i].ID == id {
+			return &m.Spaces[i]
+		}
+	}
+	return nil
+}
+
+func (m *MockSpaceStore) Create(ctx context.Context, post *Post, space *Space) error {
+	post.Kind = PostKindSpace
+	post.ID = int64(len(m.Spaces) + 1)
+	space.ID, space.HostID, space.Title, space.Description = post.ID, post.UserID, post.Title, post.Content
+	space.Status = SpaceScheduled
+	space.Participants = []SpaceParticipant{{UserID: post.UserID, Role: SpaceRoleHost}}
+	m.Spaces = append(m.Spaces, *space)
+	return nil
+}
+
+func (m *MockSpaceStore) Get(ctx context.Context, id int64) (*Space, error) {
+	s := m.find(id)
+	if s == nil {
+		return nil, ErrRecordNotFound
+	}
+	space := *s
+	space.Participants = slices.Clone(s.Participants)
+	return &space, nil
+}
+
+func (m *MockSpaceStore) List(ctx context.Context, status string, followerID int64, limit, offset int) ([]Space, error) {
+	spaces := []Space{}
+	for _, s := range m.Spaces {
+		if s.Status == status && (followerID == 0 || slices.Contains(m.Followed[followerID], s.HostID)) {
+			spaces = append(spaces, s)
+		}
+	}
+	return spaces, nil
+}
+
+func (m *MockSpaceStore) Start(ctx context.Context, id int64, roomID, roomURL string) error {
+	s := m.find(id)
+	if s == nil || s.Status != SpaceScheduled {
+		return ErrConflict
+	}
+	now := time.Now()
+	s.Status, s.StartedAt, s.RoomID, s.RoomURL = SpaceLive, &now, roomID, roomURL
+	return nil
+}
+
+func (m *MockSpaceStore) Stop(ctx context.Context, id int64) error {
+	s := m.find(id)
+	if s == nil || s.Status != SpaceLive {
+		return ErrConflict
+	}
+	now := time.Now()
+	s.Status, s.EndedAt, s.RoomURL = SpaceEnded, &now, ""
+	s.Participants = slices.DeleteFunc(s.Participants, func(p SpaceParticipant) bool { return p.Role == SpaceRoleListener })
+	for i := range s.Participants {
+		s.Participants[i].Present = false
+	}
+	s.Listeners = 0
+	return nil
+}
+
+func (m *MockSpaceStore) Join(ctx context.Context, id, userID int64) error {
+	s := m.find(id)
+	if s == nil || s.Status != SpaceLive {
+		return ErrConflict
+	}
+	for i := range s.Participants {
+		if s.Participants[i].UserID == userID {
+			if !s.Participants[i].Present {
+				s.Participants[i].Present = true
+				s.Listeners++
+			}
+			return nil
+		}
+	}
+	s.Participants = append(s.Participants, SpaceParticipant{UserID: userID, Role: SpaceRoleListener, Present: true})
+	s.Listeners++
+	return nil
+}
+
+func (m *MockSpaceStore) Leave(ctx context.Context, id, userID int64) error {
+	s := m.find(id)
+	if s == nil {
+		return nil
+	}
+	for i, p := range s.Participants {
+		if p.UserID != userID {
+			continue
+		}
+		if p.Present {
+			s.Listeners--
+		}
+		if p.Role == SpaceRoleListener {
+			s.Participants = slices.Delete(s.Participants, i, i+1)
+		} else {
+			s.Participants[i].Present = false
+		}
+		return nil
+	}
+	return nil
+}
+
+func (m *MockSpaceStore) SetCohost(ctx context.Context, id, userID int64, cohost bool) error {
+	s := m.find(id)
+	if s == nil || s.Status == SpaceEnded {
+		return ErrConflict
+	}
+	for i, p := range s.Participants {
+		if p.UserID != userID || p.Role == SpaceRoleHost {
+			continue
+		}
+		switch {
+		case cohost:
+			s.Participants[i].Role = SpaceRoleCohost
+		case p.Present:
+			s.Participants[i].Role = SpaceRoleListener
+		default:
+			s.Participants = slices.Delete(s.Participants, i, i+1)
+		}
+		return nil
+	}
+	if cohost {
+		s.Participants = append(s.Participants, SpaceParticipant{UserID: userID, Role: SpaceRoleCohost})
+	}
+	return nil
+}
+
+// MockStoryStore keeps stories in memory with the IDs of their viewers.
+// Following maps a user to the authors they follow, for Feed.
+type MockStoryStore struct {
+	Stories   []Story
+	Viewed    map[int64][]int64
+	Following map[int64][]int64
+}
+
+func (m *MockStoryStore) Create(ctx context.Context, post *Post, ttl time.Duration) (*Story, error) {
+	post.Kind = PostKindStory
+	post.ID = int64(len(m.Stories) + 1)
+	m.Stories = append(m.Stories, Story{ID: post.ID, UserID: post.UserID, Content: post.Content, ExpiresAt: time.Now().Add(ttl)})
+	return m.Get(ctx, post.ID, post.UserID)
+}
+
+func (m *MockStoryStore) Get(ctx context.Context, id, viewerID int64) (*Story, error) {
+	for _, s := range m.Stories {
+		if s.ID == id && s.ExpiresAt.After(time.Now()) {
+			s.Seen = slices.Contains(m.Viewed[id], viewerID)
+			if s.UserID == viewerID {
+				views := len(m.Viewed[id])
+				s.Views = &views
+			}
+			return &s, nil
+		}
+	}
+	return nil, ErrRecordNotFound
+}
+
+func (m *MockStoryStore) Feed(ctx context.Context, userID int64, limit int) ([]Story, error) {
+	stories := []Story{}
+	for _, s := range slices.Backward(m.Stories) {
+		if s.UserID != userID && !slices.Contains(m.Following[userID], s.UserID) {
+			continue
+		}
+		if story, err := m.Get(ctx, s.ID, userID); err == nil {
+			stories = append(stories, *story)
+		}
+	}
+	return stories, nil
+}
+
+func (m *MockStoryStore) View(ctx context.Context, id, viewerID int64) error {
+	story, err := m.Get(ctx, id, viewerID)
+	if err != nil || story.UserID == viewerID || story.Seen {
+		return nil
+	}
+	if m.Viewed == nil {
+		m.Viewed = make(map[int64][]int64)
+	}
+	m.Viewed[id] = append(m.Viewed[id], viewerID)
+	return nil
+}
+
+func (m *MockStoryStore) Viewers(ctx context.Context, id int64, limit, offset int) ([]StoryViewer, error) {
+	viewers := []StoryViewer{}
+	for _, v := range slices.Backward(m.Viewed[id]) {
+		viewers = append(viewers, StoryViewer{UserID: v})
+	}
+	return viewers, nil
+}
+
+// MockCrosspostStore keeps accounts and deliveries in memory.
+type MockCrosspostStore struct {
+	Accts      []CrosspostAccount
+	Deliveries []CrosspostDelivery
+}
+
+func (m *MockCrosspostStore) Accounts(ctx context.Context, userID int64) ([]CrosspostAccount, error) {
+	accounts := []CrosspostAccount{}
+	for _, a := range m.Accts {
+		if a.UserID == userID {
+			accounts = append(accounts, a)
+		}
+	}
+	return accounts, nil
+}
+
+func (m *MockCrosspostStore) Connect(ctx context.Context, acct *CrosspostAccount) error {
+	for i, a := range m.Accts {
+		if a.UserID == acct.UserID && a.Network == acct.Network {
+			acct.ID = a.ID
+			m.Accts[i] = *acct
+			return nil
+		}
+	}
+	acct.ID = int64(len(m.Accts) + 1)
+	m.Accts = append(m.Accts, *acct)
+	return nil
+}
+
+func (m *MockCrosspostStore) account(userID int64, network string) *CrosspostAccount {
+	for i, a := range m.Accts {
+		if a.UserID == userID && a.Network == network {
+			return &m.Accts[i]
+		}
+	}
+	return nil
+}
+
+func (m *MockCrosspostStore) SetEnabled(ctx context.Context, userID int64, network string, enabled bool) error {
+	a := m.account(userID, network)
+	if a == nil {
+		return ErrRecordNotFound
+	}
+	a.Enabled = enabled
+	return nil
+}
+
+func (m *MockCrosspostStore) Disconnect(ctx context.Context, userID int64, network string) error {
+	a := m.account(userID, network)
+	if a == nil {
+		return ErrRecordNotFound
+	}
+	id := a.ID
+	m.Accts = slices.DeleteFunc(m.Accts, func(a CrosspostAccount) bool { return a.ID == id })
+	m.Deliveries = slices.DeleteFunc(m.Deliveries, func(d CrosspostDelivery) bool { return d.Account.ID == id })
+	return nil
+}
+
+func (m *MockCrosspostStore) Enqueue(ctx context.Context, postID, userID int64) (int, error) {
+	n := 0
+	for _, a := range m.Accts {
+		if a.UserID == userID && a.Enabled {
+			m.Deliveries = append(m.Deliveries, CrosspostDelivery{
+				ID: int64(len(m.Deliveries) + 1), PostID: postID, Network: a.Network, Handle: a.Handle,
+				Status: CrosspostPending, NextAttemptAt: time.Now(), Account: a,
+			})
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *MockCrosspostStore) Due(ctx context.Context, limit int) ([]CrosspostDelivery, error) {
+	var due []CrosspostDelivery
+	for _, d := range m.Deliveries {
+		if a := m.account(d.Account.UserID, d.Network); d.Status == CrosspostPending && !d.NextAttemptAt.After(time.Now()) && a != nil && a.Enabled && len(due) < limit {
+			d.Account = *a
+			due = append(due, d)
+		}
+	}
+	return due, nil
+}
+
+func (m *MockCrosspostStore) delivery(id int64) *CrosspostDelivery {
+	for i := range m.Deliveries {
+		if m.Deliveries[i].ID == id {
+			return &m.Deliveries[i]
+		}
+	}
+	return nil
+}
+
+func (m *MockCrosspostStore) Delivered(ctx context.Context, id int64, remoteURL string) error {
+	if d := m.delivery(id); d != nil {
+		d.Status, d.RemoteURL, d.Error = CrosspostDelivered, remoteURL, ""
+		d.Attempts++
+	}
+	return nil
+}
+
+func (m *MockCrosspostStore) Failed(ctx context.Context, id int64, reason string, retryAt *time.Time) error {
+	if d := m.delivery(id); d != nil {
+		d.Attempts++
+		d.Error = reason
+		if retryAt == nil {
+			d.Status = CrosspostFailed
+		} else {
+			d.NextAttemptAt = *retryAt
+		}
+	}
+	return nil
+}
+
+func (m *MockCrosspostStore) ForPosts(ctx context.Context, postIDs []int64) (map[int64][]CrosspostDelivery, error) {
+	deliveries := make(map[int64][]CrosspostDelivery)
+	for _, d := range m.Deliveries {
+		if slices.Contains(postIDs, d.PostID) {
+			deliveries[d.PostID] = append(deliveries[d.PostID], d)
+		}
+	}
+	return deliveries, nil
+}
+
+// MockEmailPostStore keeps one address per user.
+type MockEmailPostStore struct {
+	Addresses map[int64]EmailPostAddress
+}
+
+func (m *MockEmailPostStore) Get(ctx context.Context, userID int64) (*EmailPostAddress, error) {
+	a, ok := m.Addresses[userID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	return &a, nil
+}
+
+func (m *MockEmailPostStore) Rotate(ctx context.Context, userID int64) (*EmailPostAddress, error) {
+	if m.Addresses == nil {
+		m.Addresses = make(map[int64]EmailPostAddress)
+	}
+	token, err := newSecret("", 12)
+	if err != nil {
+		return nil, err
+	}
+	a := EmailPostAddress{UserID: userID, Token: token, CreatedAt: time.Now()}
+	m.Addresses[userID] = a
+	return &a, nil
+}
+
+func (m *MockEmailPostStore) Delete(ctx context.Context, userID int64) error {
+	if _, ok := m.Addresses[userID]; !ok {
+		return ErrRecordNotFound
+	}
+	delete(m.Addresses, userID)
+	return nil
+}
+
+func (m *MockEmailPostStore) UserID(ctx context.Context, token string) (int64, error) {
+	for _, a := range m.Addresses {
+		if a.Token == token {
+			return a.UserID, nil
+		}
+	}
+	return 0, ErrRecordNotFound
+}
+
+// MockSitemapStore serves fixed entries per section.
+type MockSitemapStore struct {
+	Sections map[string][]SitemapEntry
+}
+
+func (m *MockSitemapStore) Counts(ctx context.Context) (map[string]int, error) {
+	counts := map[string]int{SitemapUsers: 0, SitemapPosts: 0, SitemapTags: 0}
+	for section, entries := range m.Sections {
+		counts[section] = len(entries)
+	}
+	return counts, nil
+}
+
+func (m *MockSitemapStore) Entries(ctx context.Context, section string, limit, offset int) ([]SitemapEntry, error) {
+	entries := m.Sections[section]
+	if offset >= len(entries) {
+		return nil, nil
+	}
+	return entries[offset:min(offset+limit, len(entries))], nil
+}
+
+type MockMaintenanceStore struct {
+	Windows []MaintenanceWindow
+}
+
+func (m *MockMaintenanceStore) Create(ctx context.Context, w *MaintenanceWindow) error {
+	w.ID = int64(len(m.Windows) + 1)
+	w.CreatedAt = time.Now()
+	m.Windows = append(m.Windows, *w)
+	return nil
+}
+
+func (m *MockMaintenanceStore) active(w MaintenanceWindow) bool {
+	return w.EndedAt == nil && (w.EndsAt == nil || w.EndsAt.After(time.Now()))
+}
+
+func (m *MockMaintenanceStore) ListActive(ctx context.Context) ([]MaintenanceWindow, error) {
+	windows := []MaintenanceWindow{}
+	for _, w := range m.Windows {
+		if m.active(w) {
+			windows = append(windows, w)
+		}
+	}
+	return windows, nil
+}
+
+func (m *MockMaintenanceStore) List(ctx context.Context, limit, offset int) ([]MaintenanceWindow, error) {
+	windows := []MaintenanceWindow{}
+	for _, w := range slices.Backward(m.Windows) {
+		windows = append(windows, w)
+	}
+	return windows[min(offset, len(windows)):min(offset+limit, len(windows))], nil
+}
+
+func (m *MockMaintenanceStore) End(ctx context.Context, id int64) error {
+	for i, w := range m.Windows {
+		if w.ID == id && m.active(w) {
+			now := time.Now()
+			m.Windows[i].EndedAt = &now
+			return nil
+		}
+	}
+	return ErrRecordNotFound
+}
+
+// MockJobLockStore always hands out the lock.
+type MockJobLockStore struct{}
+
+func (m *MockJobLockStore) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	return func() {}, true, nil
+}
@@ -0,0 +1,188 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Enforcement actions that open a moderation case.
+const (
+	ModerationPostRemoved   = "post_removed"
+	ModerationCommentHidden = "comment_hidden"
+)
+
+// Subjects of a case.
+const (
+	CaseSubjectPost    = "post"
+	CaseSubjectComment = "comment"
+)
+
+// Appeal states of a case. A case without an appeal has an empty status.
+const (
+	AppealPending    = "pending"
+	AppealUpheld     = "upheld"
+	AppealOverturned = "overturned"
+)
+
+var (
+	// ErrAppealExists means the case was already appealed, once is allowed.
+	ErrAppealExists = errors.New("case already appealed")
+	// ErrAppealNotPending means the case has no appeal waiting for review.
+	ErrAppealNotPending = errors.New("appeal is not pending")
+)
+
+// ModerationCase records an enforcement action against a user and the
+// user's appeal of it.
+type ModerationCase struct {
+	ID             int64      `json:"id"`
+	UserID         int64      `json:"user_id"`
+	Action         string     `json:"action"`
+	SubjectType    string     `json:"subject_type"`
+	SubjectID      int64      `json:"subject_id"`
+	ModeratorID    int64      `json:"moderator_id"`
+	Reason         string     `json:"reason"`
+	CreatedAt      time.Time  `json:"created_at"`
+	AppealStatus   string     `json:"appeal_status"`
+	AppealText     string     `json:"appeal_text"`
+	AppealedAt     *time.Time `json:"appealed_at"`
+	ResolvedBy     int64      `json:"resolved_by,omitempty"`
+	ResolutionNote string     `json:"resolution_note"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+type ModerationCaseStore struct {
+	db *sql.DB
+}
+
+const moderationCaseColumns = `id, user_id, action, subject_type, subject_id, COALESCE(moderator_id, 0), reason, created_at,
+	appeal_status, appeal_text, appealed_at, COALESCE(resolved_by, 0), resolution_note, resolved_at`
+
+func scanModerationCase(row interface{ Scan(...any) error }, c *ModerationCase) error {
+	return row.Scan(&c.ID, &c.UserID, &c.Action, &c.SubjectType, &c.SubjectID, &c.ModeratorID, &c.Reason, &c.CreatedAt,
+		&c.AppealStatus, &c.AppealText, &c.AppealedAt, &c.ResolvedBy, &c.ResolutionNote, &c.ResolvedAt)
+}
+
+func (s *ModerationCaseStore) Create(ctx context.Context, c *ModerationCase) error {
+	query := `
+	INSERT INTO moderation_cases (user_id, action, subject_type, subject_id, moderator_id, reason)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, c.UserID, c.Action, c.SubjectType, c.SubjectID, c.ModeratorID, c.Reason).
+		Scan(&c.ID, &c.CreatedAt)
+}
+
+func (s *ModerationCaseStore) GetByID(ctx context.Context, id int64) (*ModerationCase, error) {
+	query := `SELECT ` + moderationCaseColumns + ` FROM moderation_cases WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var c ModerationCase
+	if err := scanModerationCase(s.db.QueryRowContext(ctx, query, id), &c); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// ListByUser returns the cases opened against the user, newest first.
+func (s *ModerationCaseStore) ListByUser(ctx context.Context, userID int64, limit, offset int) ([]ModerationCase, error) {
+	query := `SELECT ` + moderationCaseColumns + ` FROM moderation_cases
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2 OFFSET $3`
+	return s.list(ctx, query, userID, limit, offset)
+}
+
+// ListAppeals is the review queue: appeals in the given status, oldest
+// appeal first so none is left waiting.
+func (s *ModerationCaseStore) ListAppeals(ctx context.Context, status string, limit, offset int) ([]ModerationCase, error) {
+	query := `SELECT ` + moderationCaseColumns + ` FROM moderation_cases
+	WHERE appeal_status = $1
+	ORDER BY appealed_at, id
+	LIMIT $2 OFFSET $3`
+	return s.list(ctx, query, status, limit, offset)
+}
+
+func (s *ModerationCaseStore) list(ctx context.Context, query string, args ...any) ([]ModerationCase, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []ModerationCase{}
+	for rows.Next() {
+		var c ModerationCase
+		if err := scanModerationCase(rows, &c); err != nil {
+			return nil, err
+		}
+		cases = append(cases, c)
+	}
+	return cases, rows.Err()
+}
+
+// Appeal files the user's appeal. ErrRecordNotFound covers cases of other
+// users, so their existence isn't revealed.
+func (s *ModerationCaseStore) Appeal(ctx context.Context, id, userID int64, text string) (*ModerationCase, error) {
+	query := `
+	UPDATE moderation_cases
+	SET appeal_status = $3, appeal_text = $4, appealed_at = NOW()
+	WHERE id = $1 AND user_id = $2 AND appeal_status = ''
+	RETURNING ` + moderationCaseColumns
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var c ModerationCase
+	err := scanModerationCase(s.db.QueryRowContext(ctx, query, id, userID, AppealPending, text), &c)
+	if errors.Is(err, sql.ErrNoRows) {
+		existing, err := s.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if existing.UserID != userID {
+			return nil, ErrRecordNotFound
+		}
+		return nil, ErrAppealExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Resolve decides a pending appeal.
+func (s *ModerationCaseStore) Resolve(ctx context.Context, id, moderatorID int64, status, note string) (*ModerationCase, error) {
+	query := `
+	UPDATE moderation_cases
+	SET appeal_status = $3, resolved_by = $4, resolution_note = $5, resolved_at = NOW()
+	WHERE id = $1 AND appeal_status = $2
+	RETURNING ` + moderationCaseColumns
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var c ModerationCase
+	err := scanModerationCase(s.db.QueryRowContext(ctx, query, id, AppealPending, status, moderatorID, note), &c)
+	if errors.Is(err, sql.ErrNoRows) {
+		if _, err := s.GetByID(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrAppealNotPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Subjects moderator notes attach to.
+const (
+	NoteSubjectUser = "user"
+	NoteSubjectPost = "post"
+)
+
+// ModeratorNote is internal context left by a moderator, never shown to the
+// subject. Notes are append only so the history stays intact.
+type ModeratorNote struct {
+	ID          int64     `json:"id"`
+	SubjectType string    `json:"subject_type"`
+	SubjectID   int64     `json:"subject_id"`
+	AuthorID    int64     `json:"author_id"`
+	Author      string    `json:"author"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ModeratorNoteStore struct {
+	db *sql.DB
+}
+
+// subjectTables maps note subjects to the table they must exist in.
+var subjectTables = map[string]string{
+	NoteSubjectUser: "users",
+	NoteSubjectPost: "posts",
+}
+
+// Create adds a note, ErrRecordNotFound means the subject doesn't exist.
+func (s *ModeratorNoteStore) Create(ctx context.Context, note *ModeratorNote) error {
+	table, ok := subjectTables[note.SubjectType]
+	if !ok {
+		return errors.New("unknown note subject " + note.SubjectType)
+	}
+	query := `
+	INSERT INTO moderator_notes (subject_type, subject_id, author_id, body)
+	SELECT $1, $2, $3, $4
+	WHERE EXISTS (SELECT 1 FROM ` + table + ` WHERE id = $2)
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, note.SubjectType, note.SubjectID, note.AuthorID, note.Body).Scan(&note.ID, &note.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// List returns the notes on a subject, newest first. Notes on a user include
+// the ones on their posts, so the whole account history reads in one place.
+func (s *ModeratorNoteStore) List(ctx context.Context, subjectType string, subjectID int64, limit, offset int) ([]ModeratorNote, error) {
+	query := `
+	SELECT n.id, n.subject_type, n.subject_id, COALESCE(n.author_id, 0), COALESCE(u.username, ''), n.body, n.created_at
+	FROM moderator_notes n
+	LEFT JOIN users u ON u.id = n.author_id
+	WHERE (n.subject_type = $1 AND n.subject_id = $2)
+		OR ($1 = 'user' AND n.subject_type = 'post' AND n.subject_id IN (SELECT id FROM posts WHERE user_id = $2))
+	ORDER BY n.created_at DESC, n.id DESC
+	LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, subjectType, subjectID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []ModeratorNote{}
+	for rows.Next() {
+		var n ModeratorNote
+		if err := rows.Scan(&n.ID, &n.SubjectType, &n.SubjectID, &n.AuthorID, &n.Author, &n.Body, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	NotificationComment    = "comment"
+	NotificationReply      = "reply"
+	NotificationMention    = "mention"
+	NotificationNewPost    = "post"
+	NotificationFollowBack = "follow_back"
+	// Moderation notifications can't be muted, see ModerationCase.
+	NotificationModerationAction = "moderation_action"
+	NotificationAppealResolved   = "appeal_resolved"
+	// The actor of a recovery request is the account being recovered.
+	NotificationRecoveryRequest = "recovery_request"
+	// Event notifications carry the event's ID as PostID, the actor is the
+	// organizer.
+	NotificationEventReminder = "event_reminder"
+	NotificationEventCanceled = "event_canceled"
+	// The actor of a reviewed listing is the author, PostID the listing.
+	NotificationListingReviewed = "listing_reviewed"
+	// The actor of an accepted answer is the question's author.
+	NotificationAnswerAccepted = "answer_accepted"
+	// Space notifications carry the space's ID as PostID, the actor is the
+	// host.
+	NotificationSpaceLive = "space_live"
+)
+
+// Channels a notification reaches the user on. Each gets its own receipt, see
+// RecordDelivery and MarkSeen.
+const (
+	ChannelInApp = "in_app"
+	ChannelPush  = "push"
+	ChannelEmail = "email"
+)
+
+// maxRecentActors is how many of a group's latest actors are kept, enough to
+// show "alice, bob and 12 others".
+const maxRecentActors = 3
+
+// Notification is one event, or a burst of them collapsed into a group. ActorID,
+// PostID and CommentID then describe the latest event.
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	ActorID   int64      `json:"actor_id"`
+	Type      string     `json:"type"`
+	PostID    int64      `json:"post_id,omitempty"`
+	CommentID int64      `json:"comment_id,omitempty"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	// EventCount is how many events the notification stands for and
+	// ActorCount how many people caused them. ActorCount is exact while the
+	// group has at most maxRecentActors actors, beyond that a repeat actor
+	// may be counted again.
+	EventCount     int     `json:"event_count"`
+	ActorCount     int     `json:"actor_count"`
+	RecentActorIDs []int64 `json:"recent_actor_ids"`
+	// Seq grows with every change to the notification, see ChangedAfter.
+	Seq int64 `json:"-"`
+}
+
+// NotificationGroupKey says which unread notification a new one collapses
+// into, empty for types that are never grouped. Comments and replies group by
+// post, new posts by author and follow back suggestions all together.
+func NotificationGroupKey(n Notification) string {
+	switch n.Type {
+	case NotificationComment, NotificationReply:
+		return fmt.Sprintf("%s:%d", n.Type, n.PostID)
+	case NotificationNewPost:
+		return fmt.Sprintf("%s:%d", n.Type, n.ActorID)
+	case NotificationFollowBack:
+		return n.Type
+	}
+	return ""
+}
+
+const notificationColumns = `n.id, n.user_id, n.actor_id, n.type, COALESCE(n.post_id, 0), COALESCE(n.comment_id, 0), n.read_at, n.created_at,
+	n.event_count, n.actor_count, n.recent_actor_ids, n.seq`
+
+func scanNotification(row interface{ Scan(...any) error }, n *Notification) error {
+	return row.Scan(&n.ID, &n.UserID, &n.ActorID, &n.Type, &n.PostID, &n.CommentID, &n.ReadAt, &n.CreatedAt,
+		&n.EventCount, &n.ActorCount, (*pq.Int64Array)(&n.RecentActorIDs), &n.Seq)
+}
+
+type NotificationStore struct {
+	db *sql.DB
+}
+
+// CreateMany inserts a batch of notifications in a single statement.
+// Notifications of a type the recipient has muted are dropped. One that
+// shares its group with an unread notification is collapsed into it, which
+// moves the group to the top of the list.
+func (s *NotificationStore) CreateMany(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	var (
+		userIDs    = make([]int64, 0, len(notifications))
+		actorIDs   = make([]int64, 0, len(notifications))
+		types      = make([]string, 0, len(notifications))
+		postIDs    = make([]int64, 0, len(notifications))
+		commentIDs = make([]int64, 0, len(notifications))
+		groupKeys  = make([]string, 0, len(notifications))
+		// A group can only be hit once per statement.
+		seen = make(map[string]bool)
+	)
+	for _, n := range notifications {
+		key := NotificationGroupKey(n)
+		if key != "" {
+			if seen[fmt.Sprint(n.UserID, key)] {
+				continue
+			}
+			seen[fmt.Sprint(n.UserID, key)] = true
+		}
+		userIDs = append(userIDs, n.UserID)
+		actorIDs = append(actorIDs, n.ActorID)
+		types = append(types, n.Type)
+		postIDs = append(postIDs, n.PostID)
+		commentIDs = append(commentIDs, n.CommentID)
+		groupKeys = append(groupKeys, key)
+	}
+	query := `
+	INSERT INTO notifications (user_id, actor_id, type, post_id, comment_id, group_key, recent_actor_ids)
+	SELECT n.u, n.a, n.t, NULLIF(n.p, 0), NULLIF(n.c, 0), NULLIF(n.g, ''), ARRAY[n.a]
+	FROM unnest($1::bigint[], $2::bigint[], $3::text[], $4::bigint[], $5::bigint[], $6::text[]) AS n(u, a, t, p, c, g)
+	JOIN users r ON r.id = n.u
+	WHERE NOT (n.t = ANY(r.muted_notification_types))
+	ON CONFLICT (user_id, group_key) WHERE read_at IS NULL DO UPDATE SET
+		event_count = notifications.event_count + 1,
+		actor_count = notifications.actor_count +
+			CASE WHEN EXCLUDED.actor_id = ANY(notifications.recent_actor_ids) THEN 0 ELSE 1 END,
+		recent_actor_ids = (EXCLUDED.actor_id || array_remove(notifications.recent_actor_ids, EXCLUDED.actor_id))[1:$7::int],
+		actor_id = EXCLUDED.actor_id,
+		post_id = EXCLUDED.post_id,
+		comment_id = EXCLUDED.comment_id,
+		created_at = NOW(),
+		seq = nextval('notifications_seq')
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query,
+		pq.Array(userIDs),
+		pq.Array(actorIDs),
+		pq.Array(types),
+		pq.Array(postIDs),
+		pq.Array(commentIDs),
+		pq.Array(groupKeys),
+		maxRecentActors)
+	return err
+}
+
+func (s *NotificationStore) GetByUserID(ctx context.Context, userID int64, limit, offset int) ([]Notification, error) {
+	query := `
+	SELECT ` + notificationColumns + `
+	FROM notifications n
+	WHERE n.user_id = $1
+	ORDER BY n.created_at DESC, n.id DESC
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// LatestSeq is the seq of the user's latest notification change, 0 when
+// they have none.
+func (s *NotificationStore) LatestSeq(ctx context.Context, userID int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var seq int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(seq), 0) FROM notifications WHERE user_id = $1`, userID).Scan(&seq)
+	return seq, err
+}
+
+// ChangedAfter returns the user's notifications created or collapsed into
+// since seq, oldest change first.
+func (s *NotificationStore) ChangedAfter(ctx context.Context, userID, seq int64, limit int) ([]Notification, error) {
+	query := `
+	SELECT ` + notificationColumns + `
+	FROM notifications n
+	WHERE n.user_id = $1 AND n.seq > $2
+	ORDER BY n.seq
+	LIMIT $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, seq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkSeen marks the user's notifications read and records that they were
+// seen on the channel, e.g. a push the user opened. It returns the IDs that
+// belong to the user, others are ignored.
+func (s *NotificationStore) MarkSeen(ctx context.Context, userID int64, ids []int64, channel string) ([]int64, error) {
+	var seen []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+		UPDATE notifications SET read_at = COALESCE(read_at, NOW())
+		WHERE id = ANY($1) AND user_id = $2
+		RETURNING id
+		`, pq.Array(ids), userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			seen = append(seen, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		INSERT INTO notification_receipts (notification_id, channel, delivered_at, seen_at)
+		SELECT id, $2, NOW(), NOW() FROM unnest($1::bigint[]) AS id
+		ON CONFLICT (notification_id, channel) DO UPDATE SET
+			delivered_at = COALESCE(notification_receipts.delivered_at, NOW()),
+			seen_at = COALESCE(notification_receipts.seen_at, NOW())
+		`, pq.Array(seen), channel)
+		return err
+	})
+	return seen, err
+}
+
+// RecordDelivery records the attempts to deliver notifications on the
+// channel, delivered are the ones that reached the user.
+func (s *NotificationStore) RecordDelivery(ctx context.Context, channel string, attempted, delivered []int64) error {
+	if len(attempted) == 0 {
+		return nil
+	}
+	query := `
+	INSERT INTO notification_receipts (notification_id, channel, attempted_at, delivered_at)
+	SELECT id, $2, NOW(), CASE WHEN id = ANY($3) THEN NOW() END FROM unnest($1::bigint[]) AS id
+	ON CONFLICT (notification_id, channel) DO UPDATE SET
+		attempted_at = NOW(),
+		delivered_at = COALESCE(notification_receipts.delivered_at, EXCLUDED.delivered_at)
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, pq.Array(attempted), channel, pq.Array(delivered))
+	return err
+}
+
+// PendingDelivery returns unread notifications created between since and
+// until that were never attempted on the channel, oldest first. Notifications
+// read in-app in the meantime are left out so they don't arrive twice.
+func (s *NotificationStore) PendingDelivery(ctx context.Context, channel string, since, until time.Time, limit int) ([]Notification, error) {
+	query := `
+	SELECT ` + notificationColumns + `
+	FROM notifications n
+	WHERE n.read_at IS NULL AND n.created_at > $2 AND n.created_at <= $3
+		AND NOT EXISTS (
+			SELECT 1 FROM notification_receipts r WHERE r.notification_id = n.id AND r.channel = $1
+		)
+	ORDER BY n.created_at, n.id
+	LIMIT $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, channel, since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkAllRead marks every unread notification read, they count as seen
+// in-app.
+func (s *NotificationStore) MarkAllRead(ctx context.Context, userID int64) error {
+	query := `
+	WITH read AS (
+		UPDATE notifications SET read_at = now() WHERE user_id = $1 AND read_at IS NULL
+		RETURNING id
+	)
+	INSERT INTO notification_receipts (notification_id, channel, delivered_at, seen_at)
+	SELECT id, $2, NOW(), NOW() FROM read
+	ON CONFLICT (notification_id, channel) DO UPDATE SET
+		delivered_at = COALESCE(notification_receipts.delivered_at, NOW()),
+		seen_at = COALESCE(notification_receipts.seen_at, NOW())
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, userID, ChannelInApp)
+	return err
+}
+
+// CreateFollowBackSuggestions notifies users about followers they have not
+// followed back yet. Only follows made since the given time are considered,
+// each pair is suggested at most once and no user receives more than dailyCap
+// suggestions in a rolling day. At most limit notifications are inserted per
+// call; it returns how many were.
+func (s *NotificationStore) CreateFollowBackSuggestions(ctx context.Context, since time.Time, dailyCap, limit int) (int, error) {
+	query := `
+	WITH candidates AS (
+		SELECT f.user_id AS recipient, f.follower_id AS actor,
+			ROW_NUMBER() OVER (PARTITION BY f.user_id ORDER BY f.created_at DESC) AS rank
+		FROM followers f
+		JOIN users u ON u.id = f.user_id
+		WHERE f.created_at >= $1
+			AND u.is_active = true
+			AND NOT ($2 = ANY(u.muted_notification_types))
+			AND NOT EXISTS (
+				SELECT 1 FROM followers b WHERE b.user_id = f.follower_id AND b.follower_id = f.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM notifications n WHERE n.user_id = f.user_id AND n.actor_id = f.follower_id AND n.type = $2
+			)
+	), sent AS (
+		SELECT user_id, COUNT(*) AS total
+		FROM notifications
+		WHERE type = $2 AND created_at > NOW() - INTERVAL '1 day'
+		GROUP BY user_id
+	)
+	INSERT INTO notifications (user_id, actor_id, type, recent_actor_ids)
+	SELECT c.recipient, c.actor, $2, ARRAY[c.actor]
+	FROM candidates c
+	LEFT JOIN sent ON sent.user_id = c.recipient
+	WHERE c.rank + COALESCE(sent.total, 0) <= $3
+	LIMIT $4
+	ON CONFLICT (user_id, actor_id) WHERE type = 'follow_back' DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, since, NotificationFollowBack, dailyCap, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,321 @@
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Shorthand scopes, read grants read access to every resource and write full
+// access. See ValidScope for the per resource ones.
+const (
+	OAuthScopeRead  = "read"
+	OAuthScopeWrite = "write"
+)
+
+// ErrInvalidClient means the client doesn't exist, was revoked or sent the
+// wrong secret.
+var ErrInvalidClient = errors.New("invalid client")
+
+// OAuthClient is a third party app that can act for users who authorize it.
+type OAuthClient struct {
+	ID           int64      `json:"-"`
+	ClientID     string     `json:"client_id"`
+	Name         string     `json:"name"`
+	RedirectURIs []string   `json:"redirect_uris"`
+	Scopes       []string   `json:"scopes"`
+	OwnerID      int64      `json:"owner_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	secretHash   string
+}
+
+// OAuthCode is a single use authorization code, exchanged by the client for
+// an access token.
+type OAuthCode struct {
+	ClientID    int64
+	UserID      int64
+	RedirectURI string
+	Scopes      []string
+	// CodeChallenge is the PKCE S256 challenge, empty without PKCE.
+	CodeChallenge string
+	ExpiresAt     time.Time
+	// GrantID is the grant the exchange recorded, set by ExchangeCode.
+	GrantID int64
+}
+
+// OAuthGrant is a user's authorization of an app, it lasts until the user
+// revokes it or the app is revoked.
+type OAuthGrant struct {
+	ID        int64     `json:"-"`
+	ClientID  string    `json:"client_id"`
+	Name      string    `json:"name"`
+	Scopes    []string  `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewOAuthClientCredentials returns a client ID and secret for a new client.
+func NewOAuthClientCredentials() (clientID, secret string, err error) {
+	if clientID, err = newSecret("gsc_", 12); err != nil {
+		return "", "", err
+	}
+	if secret, err = newSecret("gss_", 32); err != nil {
+		return "", "", err
+	}
+	return clientID, secret, nil
+}
+
+// NewOAuthCode returns a random authorization code.
+func NewOAuthCode() (string, error) {
+	return newSecret("", 32)
+}
+
+type OAuthStore struct {
+	db *sql.DB
+}
+
+const oauthClientColumns = `id, client_id, secret_hash, name, redirect_uris, scopes, owner_id, created_at, revoked_at`
+
+func scanOAuthClient(row interface{ Scan(...any) error }, c *OAuthClient) error {
+	return row.Scan(&c.ID, &c.ClientID, &c.secretHash, &c.Name, pq.Array(&c.RedirectURIs), pq.Array(&c.Scopes), &c.OwnerID, &c.CreatedAt, &c.RevokedAt)
+}
+
+func (s *OAuthStore) CreateClient(ctx context.Context, c *OAuthClient, secret string) error {
+	query := `
+	INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris, scopes, owner_id)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, c.ClientID, hashSecret(secret), c.Name, pq.Array(c.RedirectURIs), pq.Array(c.Scopes), c.OwnerID).
+		Scan(&c.ID, &c.CreatedAt)
+}
+
+// GetClient returns an active client, ErrRecordNotFound when it doesn't exist
+// or was revoked.
+func (s *OAuthStore) GetClient(ctx context.Context, clientID string) (*OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE client_id = $1 AND revoked_at IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var c OAuthClient
+	if err := scanOAuthClient(s.db.QueryRowContext(ctx, query, clientID), &c); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &c, nil
+}
+
+// AuthenticateClient checks the client's secret.
+func (s *OAuthStore) AuthenticateClient(ctx context.Context, clientID, secret string) (*OAuthClient, error) {
+	c, err := s.GetClient(ctx, clientID)
+	if errors.Is(err, ErrRecordNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(c.secretHash), []byte(hashSecret(secret))) != 1 {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// ListClients returns the apps the user registered, newest first.
+func (s *OAuthStore) ListClients(ctx context.Context, ownerID int64) ([]OAuthClient, error) {
+	query := `SELECT ` + oauthClientColumns + ` FROM oauth_clients WHERE owner_id = $1 ORDER BY id DESC`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []OAuthClient{}
+	for rows.Next() {
+		var c OAuthClient
+		if err := scanOAuthClient(rows, &c); err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+// RevokeClient disables the owner's client, its codes are dropped and the
+// tokens it holds stop working.
+func (s *OAuthStore) RevokeClient(ctx context.Context, ownerID int64, clientID string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+		UPDATE oauth_clients SET revoked_at = NOW()
+		WHERE client_id = $1 AND owner_id = $2 AND revoked_at IS NULL
+		RETURNING id
+		`, clientID, ownerID).Scan(&id)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM oauth_codes WHERE client_id = $1`, id)
+		return err
+	})
+}
+
+// CreateCode stores an authorization code by its hash.
+func (s *OAuthStore) CreateCode(ctx context.Context, code *OAuthCode, secret string) error {
+	query := `
+	INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, hashSecret(secret), code.ClientID, code.UserID, code.RedirectURI, pq.Array(code.Scopes), code.CodeChallenge, code.ExpiresAt)
+	return err
+}
+
+// ExchangeCode consumes the client's code, it can only be exchanged once,
+// and records the user's grant to the client. A grant that already exists
+// keeps its id and gains the code's scopes. ErrRecordNotFound means the code
+// is unknown, expired, already used or was issued to another client.
+func (s *OAuthStore) ExchangeCode(ctx context.Context, clientID int64, secret string) (*OAuthCode, error) {
+	var code OAuthCode
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+		DELETE FROM oauth_codes
+		WHERE code_hash = $1 AND client_id = $2 AND expires_at > NOW()
+		RETURNING client_id, user_id, redirect_uri, scopes, code_challenge, expires_at
+		`, hashSecret(secret), clientID).
+			Scan(&code.ClientID, &code.UserID, &code.RedirectURI, pq.Array(&code.Scopes), &code.CodeChallenge, &code.ExpiresAt)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		return tx.QueryRowContext(ctx, `
+		INSERT INTO oauth_grants (user_id, client_id, scopes) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT unnest(oauth_grants.scopes || EXCLUDED.scopes) ORDER BY 1), updated_at = NOW()
+		RETURNING id
+		`, code.UserID, code.ClientID, pq.Array(code.Scopes)).Scan(&code.GrantID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// GetGrant returns the user's grant to an active client, ErrRecordNotFound
+// when the user never authorized it, revoked it or the client was revoked.
+func (s *OAuthStore) GetGrant(ctx context.Context, userID int64, clientID string) (*OAuthGrant, error) {
+	query := `
+	SELECT g.id, c.client_id, c.name, g.scopes, g.created_at, g.updated_at
+	FROM oauth_grants g JOIN oauth_clients c ON c.id = g.client_id
+	WHERE g.user_id = $1 AND c.client_id = $2 AND c.revoked_at IS NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var g OAuthGrant
+	err := s.db.QueryRowContext(ctx, query, userID, clientID).
+		Scan(&g.ID, &g.ClientID, &g.Name, pq.Array(&g.Scopes), &g.CreatedAt, &g.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &g, nil
+}
+
+// ListGrants returns the apps the user authorized that are still active,
+// most recently authorized first.
+func (s *OAuthStore) ListGrants(ctx context.Context, userID int64) ([]OAuthGrant, error) {
+	query := `
+	SELECT g.id, c.client_id, c.name, g.scopes, g.created_at, g.updated_at
+	FROM oauth_grants g JOIN oauth_clients c ON c.id = g.client_id
+	WHERE g.user_id = $1 AND c.revoked_at IS NULL
+	ORDER BY g.updated_at DESC, g.id DESC
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []OAuthGrant{}
+	for rows.Next() {
+		var g OAuthGrant
+		if err := rows.Scan(&g.ID, &g.ClientID, &g.Name, pq.Array(&g.Scopes), &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		grants = append(grants, g)
+	}
+	return grants, rows.Err()
+}
+
+// RevokeGrant drops the user's grant to the client along with codes not yet
+// exchanged, the tokens issued under it stop working.
+func (s *OAuthStore) RevokeGrant(ctx context.Context, userID int64, clientID string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+		DELETE FROM oauth_grants g USING oauth_clients c
+		WHERE c.id = g.client_id AND g.user_id = $1 AND c.client_id = $2
+		RETURNING c.id
+		`, userID, clientID).Scan(&id)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM oauth_codes WHERE client_id = $1 AND user_id = $2`, id, userID)
+		return err
+	})
+}
+
+// PruneCodes deletes expired authorization codes.
+func (s *OAuthStore) PruneCodes(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at <= NOW()`)
+	return err
+}
@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TopicSearchPost asks the search indexer to sync a post. The event only
+// carries the ID, the indexer reads the current row and removes the post
+// from the index when it is gone.
+const TopicSearchPost = "search.post"
+
+// TopicBrokerEvent carries a domain event to the message broker. Its
+// payload is the event as published, the relay adds nothing but its ID.
+const TopicBrokerEvent = "broker.event"
+
+// enqueueBatch is how many posts EnqueueAllPosts queues per statement.
+const enqueueBatch = 5000
+
+type OutboxEvent struct {
+	ID          int64
+	Topic       string
+	AggregateID int64
+	Attempts    int
+	// Payload is nil for topics whose events only carry the aggregate ID.
+	Payload []byte
+}
+
+type OutboxStore struct {
+	db *sql.DB
+}
+
+// enqueueOutbox records an event inside the caller's transaction, so it is
+// published exactly when the change it describes commits.
+func enqueueOutbox(ctx context.Context, tx *sql.Tx, topic string, aggregateID int64) error {
+	query := `INSERT INTO outbox_events (topic, aggregate_id) VALUES ($1, $2)`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, topic, aggregateID)
+	return err
+}
+
+// Enqueue records an event on its own, for changes that were saved before
+// the event was raised.
+func (s *OutboxStore) Enqueue(ctx context.Context, topic string, aggregateID int64, payload []byte) error {
+	query := `INSERT INTO outbox_events (topic, aggregate_id, payload) VALUES ($1, $2, $3)`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, topic, aggregateID, payload)
+	return err
+}
+
+// GetPending returns unprocessed events of a topic tried fewer than
+// maxAttempts times, oldest first.
+func (s *OutboxStore) GetPending(ctx context.Context, topic string, maxAttempts, limit int) ([]OutboxEvent, error) {
+	query := `
+	SELECT id, topic, aggregate_id, attempts, payload
+	FROM outbox_events
+	WHERE topic = $1 AND processed_at IS NULL AND attempts < $2
+	ORDER BY id
+	LIMIT $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, topic, maxAttempts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.Topic, &e.AggregateID, &e.Attempts, &e.Payload); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *OutboxStore) MarkProcessed(ctx context.Context, ids []int64) error {
+	query := `UPDATE outbox_events SET processed_at = NOW(), attempts = attempts + 1 WHERE id = ANY($1)`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, pq.Array(ids))
+	return err
+}
+
+func (s *OutboxStore) MarkFailed(ctx context.Context, ids []int64, reason string) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2 WHERE id = ANY($1)`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, pq.Array(ids), reason)
+	return err
+}
+
+// EnqueueAllPosts queues every post for indexing, used to rebuild the search
+// index from scratch. It walks the posts in batches of enqueueBatch, each
+// under its own timeout, and returns the number of events queued.
+func (s *OutboxStore) EnqueueAllPosts(ctx context.Context) (int, error) {
+	total := 0
+	var after int64
+	for {
+		n, last, err := s.enqueuePostsAfter(ctx, after)
+		if err != nil {
+			return total, err
+		}
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+		after = last
+	}
+}
+
+// enqueuePostsAfter queues the next batch of posts with an ID above after and
+// returns how many it queued and the last ID among them.
+func (s *OutboxStore) enqueuePostsAfter(ctx context.Context, after int64) (int, int64, error) {
+	query := `
+	WITH batch AS (
+		SELECT id FROM posts WHERE id > $2 ORDER BY id LIMIT $3
+	), queued AS (
+		INSERT INTO outbox_events (topic, aggregate_id)
+		SELECT $1, id FROM batch ORDER BY id
+		RETURNING aggregate_id
+	)
+	SELECT COUNT(*), COALESCE(MAX(aggregate_id), 0) FROM queued
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var (
+		n    int
+		last int64
+	)
+	err := s.db.QueryRowContext(ctx, query, TopicSearchPost, after, enqueueBatch).Scan(&n, &last)
+	return n, last, err
+}
+
+// Prune deletes events processed before the given time, and unprocessed
+// events of topics nobody consumes.
+func (s *OutboxStore) Prune(ctx context.Context, before time.Time, abandonedTopics []string) error {
+	query := `
+	DELETE FROM outbox_events
+	WHERE created_at < $1 AND (processed_at IS NOT NULL OR topic = ANY($2))
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, before, pq.Array(abandonedTopics))
+	return err
+}
@@ -1,12 +1,34 @@
 package store
 
 import (
+	"encoding/base64"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeFeedCursor returns the opaque cursor of a feed item. Clients hand it
+// back to ask for what came after that item.
+func EncodeFeedCursor(postID int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("p:" + strconv.FormatInt(postID, 10)))
+}
+
+func DecodeFeedCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(raw), "p:"), 10, 64)
+	if err != nil || !strings.HasPrefix(string(raw), "p:") {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
+
 type PaginatedFeedQuery struct {
 	Limit  int      `json:"limit" validate:"gte=1,lte=20"`
 	Offset int      `json:"offset" validate:"gte=0"`
@@ -15,6 +37,13 @@ type PaginatedFeedQuery struct {
 	Search string   `json:"search" validate:"max=100"`
 	Since  string   `json:"since"`
 	Until  string   `json:"until"`
+	Lang   string   `json:"lang" validate:"omitempty,len=2,lowercase"`
+	// HideWarned drops posts carrying a content warning. It comes from the
+	// viewer's preference, not the query string.
+	HideWarned bool `json:"-"`
+	// HideAgeRestricted drops age restricted posts for viewers who aren't
+	// known to be adults.
+	HideAgeRestricted bool `json:"-"`
 }
 
 func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
@@ -53,6 +82,9 @@ func (fq PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error)
 	if since != "" {
 		fq.Since = parseTime(since)
 	}
+	if lang := qs.Get("lang"); lang != "" {
+		fq.Lang = lang
+	}
 	until := qs.Get("until")
 	if until != "" {
 		fq.Until = parseTime(until)
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PartitionedTables are split into monthly partitions on created_at, named
+// <table>_pYYYYMM with UTC bounds.
+var PartitionedTables = []string{"post_impressions"}
+
+var errNotPartitioned = errors.New("table is not partitioned")
+
+// Partition is one month of a partitioned table, From inclusive, To exclusive.
+type Partition struct {
+	Table string    `json:"table"`
+	Name  string    `json:"name"`
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+}
+
+// MonthPartition returns the partition of table holding t.
+func MonthPartition(table string, t time.Time) Partition {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Partition{
+		Table: table,
+		Name:  fmt.Sprintf("%s_p%s", table, from.Format("200601")),
+		From:  from,
+		To:    from.AddDate(0, 1, 0),
+	}
+}
+
+// parsePartition reads a partition back from its name, ok is false for the
+// default partition and anything else not named by MonthPartition.
+func parsePartition(table, name string) (Partition, bool) {
+	month, ok := strings.CutPrefix(name, table+"_p")
+	if !ok {
+		return Partition{}, false
+	}
+	t, err := time.Parse("200601", month)
+	if err != nil {
+		return Partition{}, false
+	}
+	return MonthPartition(table, t), true
+}
+
+type PartitionStore struct {
+	db *sql.DB
+}
+
+// List returns the monthly partitions of table, oldest first.
+func (s *PartitionStore) List(ctx context.Context, table string) ([]Partition, error) {
+	if !slices.Contains(PartitionedTables, table) {
+		return nil, errNotPartitioned
+	}
+	query := `
+	SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+	WHERE i.inhparent = $1::regclass
+	ORDER BY c.relname
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []Partition
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if p, ok := parsePartition(table, name); ok {
+			partitions = append(partitions, p)
+		}
+	}
+	return partitions, rows.Err()
+}
+
+// Create adds the partition unless it already exists. It fails if the
+// default partition already holds rows for that month.
+func (s *PartitionStore) Create(ctx context.Context, p Partition) error {
+	if !slices.Contains(PartitionedTables, p.Table) {
+		return errNotPartitioned
+	}
+	query := fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%s) TO (%s)`,
+		pq.QuoteIdentifier(p.Name), pq.QuoteIdentifier(p.Table),
+		pq.QuoteLiteral(p.From.Format(time.RFC3339)), pq.QuoteLiteral(p.To.Format(time.RFC3339)),
+	)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
+
+// Drop deletes the partition along with every row in it.
+func (s *PartitionStore) Drop(ctx context.Context, p Partition) error {
+	if !slices.Contains(PartitionedTables, p.Table) {
+		return errNotPartitioned
+	}
+	query := fmt.Sprintf(`DROP TABLE IF EXISTS %s`, pq.QuoteIdentifier(p.Name))
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// retentionWeeks is how many weekly signup cohorts are recomputed per run.
+const retentionWeeks = 12
+
+// activityQuery selects (user_id, created_at) for everything that counts as
+// a user being active between $1 and $2: posting, commenting, reacting or
+// opening a post.
+const activityQuery = `
+	SELECT user_id, created_at FROM posts WHERE created_at >= $1 AND created_at < $2
+	UNION ALL
+	SELECT user_id, created_at FROM comments WHERE created_at >= $1 AND created_at < $2
+	UNION ALL
+	SELECT user_id, created_at FROM reactions WHERE created_at >= $1 AND created_at < $2
+	UNION ALL
+	SELECT viewer_id, created_at FROM post_impressions
+	WHERE viewer_id IS NOT NULL AND created_at >= $1 AND created_at < $2
+`
+
+type PlatformDay struct {
+	Date    string `json:"date"`
+	DAU     int    `json:"dau"`
+	MAU     int    `json:"mau"`
+	Signups int    `json:"signups"`
+	Posts   int    `json:"posts"`
+}
+
+type RetentionCohort struct {
+	CohortWeek string `json:"cohort_week"`
+	Size       int    `json:"size"`
+	// Retained holds the number of active cohort members per week since
+	// signup, index 0 being the signup week itself.
+	Retained []int `json:"retained"`
+}
+
+type HashtagCount struct {
+	Tag   string `json:"tag"`
+	Posts int    `json:"posts"`
+}
+
+// AggregatePlatform rolls the UTC day starting at day into the platform
+// summary tables and recomputes the recent retention cohorts. Running it
+// twice for the same day is harmless. Like AggregateFollowers it runs under
+// the caller's deadline.
+func (s *AnalyticsStore) AggregatePlatform(ctx context.Context, day time.Time) error {
+	day = day.UTC().Truncate(24 * time.Hour)
+	end := day.Add(24 * time.Hour)
+
+	dailyQuery := `
+	WITH activity AS (` + activityQuery + `)
+	INSERT INTO platform_daily_stats (day, dau, mau, signups, posts)
+	SELECT $3::date,
+		(SELECT COUNT(DISTINCT user_id) FROM activity WHERE created_at >= $3),
+		(SELECT COUNT(DISTINCT user_id) FROM activity),
+		(SELECT COUNT(*) FROM users WHERE created_at >= $3 AND created_at < $2),
+		(SELECT COUNT(*) FROM posts WHERE created_at >= $3 AND created_at < $2)
+	ON CONFLICT (day) DO UPDATE
+	SET dau = EXCLUDED.dau, mau = EXCLUDED.mau, signups = EXCLUDED.signups, posts = EXCLUDED.posts
+	`
+	hashtagsQuery := `
+	INSERT INTO hashtag_daily_counts (day, tag, posts)
+	SELECT $1::date, lower(tag), COUNT(*)
+	FROM posts, unnest(tags) AS tag
+	WHERE created_at >= $1 AND created_at < $2 AND tag <> ''
+	GROUP BY lower(tag)
+	`
+	retentionQuery := `
+	WITH cohorts AS (
+		SELECT id, date_trunc('week', created_at)::date AS week
+		FROM users WHERE created_at >= $1 AND created_at < $2
+	), sizes AS (
+		SELECT week, COUNT(*) AS size FROM cohorts GROUP BY week
+	), active_weeks AS (
+		SELECT DISTINCT user_id, date_trunc('week', created_at)::date AS week
+		FROM (` + activityQuery + `) a
+	)
+	INSERT INTO retention_cohorts (cohort_week, week_offset, cohort_size, retained)
+	SELECT c.week, (w.week - c.week) / 7, sizes.size, COUNT(*)
+	FROM cohorts c
+	JOIN sizes ON sizes.week = c.week
+	JOIN active_weeks w ON w.user_id = c.id AND w.week >= c.week
+	GROUP BY c.week, w.week, sizes.size
+	`
+	cohortStart := day.AddDate(0, 0, -7*retentionWeeks)
+	cohortStart = cohortStart.AddDate(0, 0, -(int(cohortStart.Weekday())+6)%7)
+
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, dailyQuery, end.AddDate(0, 0, -30), end, day); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM hashtag_daily_counts WHERE day = $1::date`, day); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, hashtagsQuery, day, end); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM retention_cohorts WHERE cohort_week >= $1::date`, cohortStart); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, retentionQuery, cohortStart, end); err != nil {
+			return err
+		}
+		return markAggregated(ctx, tx, AnalyticsPlatform, day)
+	})
+}
+
+func (s *AnalyticsStore) PlatformDaily(ctx context.Context, since time.Time) ([]PlatformDay, error) {
+	query := `
+	SELECT to_char(day, 'YYYY-MM-DD'), dau, mau, signups, posts
+	FROM platform_daily_stats
+	WHERE day >= $1::date
+	ORDER BY day
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := []PlatformDay{}
+	for rows.Next() {
+		var d PlatformDay
+		if err := rows.Scan(&d.Date, &d.DAU, &d.MAU, &d.Signups, &d.Posts); err != nil {
+			return nil, err
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+func (s *AnalyticsStore) RetentionCohorts(ctx context.Context, since time.Time) ([]RetentionCohort, error) {
+	query := `
+	SELECT to_char(cohort_week, 'YYYY-MM-DD'), week_offset, cohort_size, retained
+	FROM retention_cohorts
+	WHERE cohort_week >= $1::date
+	ORDER BY cohort_week, week_offset
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cohorts := []RetentionCohort{}
+	for rows.Next() {
+		var (
+			week              string
+			offset, size, num int
+		)
+		if err := rows.Scan(&week, &offset, &size, &num); err != nil {
+			return nil, err
+		}
+		if len(cohorts) == 0 || cohorts[len(cohorts)-1].CohortWeek != week {
+			cohorts = append(cohorts, RetentionCohort{CohortWeek: week, Size: size, Retained: []int{}})
+		}
+		c := &cohorts[len(cohorts)-1]
+		for len(c.Retained) < offset {
+			c.Retained = append(c.Retained, 0)
+		}
+		c.Retained = append(c.Retained, num)
+	}
+	return cohorts, rows.Err()
+}
+
+func (s *AnalyticsStore) TopHashtags(ctx context.Context, since time.Time, limit int) ([]HashtagCount, error) {
+	query := `
+	SELECT tag, SUM(posts) AS total
+	FROM hashtag_daily_counts
+	WHERE day >= $1::date
+	GROUP BY tag
+	ORDER BY total DESC, tag
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []HashtagCount{}
+	for rows.Next() {
+		var h HashtagCount
+		if err := rows.Scan(&h.Tag, &h.Posts); err != nil {
+			return nil, err
+		}
+		tags = append(tags, h)
+	}
+	return tags, rows.Err()
+}
@@ -2,59 +2,191 @@ package store
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
 
 type Post struct {
-	ID        int64     `json:"id"`
-	Content   string    `json:"content"`
-	Title     string    `json:"title"`
-	UserID    int64     `json:"user_id"`
-	Tags      []string  `json:"tags"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
-	Version   int       `json:"version"`
-	Comments  []Comment `json:"comments"`
-	User      User      `json:"user"`
+	ID          int64     `json:"id"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	Title       string    `json:"title"`
+	UserID      int64     `json:"user_id"`
+	Tags        []string  `json:"tags"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
+	// Lang is the detected ISO 639-1 code of the post, empty when unknown.
+	Lang string `json:"lang"`
+	// ContentWarning is an optional spoiler/CW label, clients collapse the
+	// body behind it.
+	ContentWarning string `json:"content_warning"`
+	// AgeRestricted posts are only shown to adults and their author.
+	AgeRestricted bool `json:"age_restricted"`
+	// Kind is one of the PostKind* constants. For articles
+	// Content holds the summary shown in feeds, the full body is stored
+	// separately.
+	Kind string `json:"kind"`
+	// Fingerprint identifies the normalized content, see PostFingerprint.
+	Fingerprint string `json:"-"`
+	// CommentCount is maintained by a trigger on comments, not counted per
+	// read.
+	CommentCount int       `json:"comment_count"`
+	Comments     []Comment `json:"comments"`
+	// Snippets are created with the post and loaded separately.
+	Snippets []Snippet `json:"snippets,omitempty"`
+	// AcceptedAnswerID is the answer the author of a question accepted.
+	AcceptedAnswerID *int64 `json:"accepted_answer_id,omitempty"`
+	// RepoCard previews the GitHub repository the post links to.
+	RepoCard *RepoCard `json:"repo_card,omitempty"`
+	User     User      `json:"user"`
+	// NoCrosspost keeps a new post from being mirrored to the author's
+	// other networks, see CrosspostStore.
+	NoCrosspost bool `json:"-"`
+	// OnHold is set when the post or its author is under legal hold, such
+	// posts are only shown to admins.
+	OnHold bool `json:"-"`
+	// DuplicateWindow, when set, makes creating the post fail with a
+	// DuplicatePostError if its author published the same fingerprint
+	// within the window.
+	DuplicateWindow time.Duration `json:"-"`
 }
+
+// DuplicatePostError is returned when a post created with DuplicateWindow
+// repeats the author's post ExistingID.
+type DuplicatePostError struct {
+	ExistingID int64
+}
+
+func (e *DuplicatePostError) Error() string {
+	return fmt.Sprintf("duplicate of post %d", e.ExistingID)
+}
+
+const (
+	PostKindNote    = "note"
+	PostKindArticle = "article"
+	// PostKindEvent posts announce the Event with the same ID.
+	PostKindEvent = "event"
+	// PostKindListing posts carry the Listing with the same ID, they are
+	// left out of the home and explore feeds.
+	PostKindListing = "listing"
+	// PostKindQuestion posts take answers, see Comment.Answer.
+	PostKindQuestion = "question"
+	// PostKindSpace posts announce the Space with the same ID.
+	PostKindSpace = "space"
+	// PostKindStory posts expire after a day, see StoryStore. Like listings
+	// they are left out of the home and explore feeds.
+	PostKindStory = "story"
+)
+
+// PostBody is the full markdown body of an article. It is kept out of the
+// posts table so feeds stay small and is fetched on demand.
+type PostBody struct {
+	PostID    int64     `json:"post_id"`
+	Body      string    `json:"body"`
+	BodyHTML  string    `json:"body_html"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type PostWithMetadata struct {
 	Post
-	CommentCount int `json:"comment_count"`
+	// Collapsed tells the client to hide the body behind the content warning,
+	// it follows the viewer's content warning preference.
+	Collapsed bool `json:"collapsed"`
+	// TopComment previews the latest top level comment, nil without comments.
+	TopComment *Comment `json:"top_comment"`
+	// Cursor identifies this item for /users/feed/updates and feed positions.
+	Cursor string `json:"cursor"`
+	// AgeSeconds and CreatedAgo tell how long ago the post was created when
+	// the feed was served, CreatedAgo in short form like 5m or 3d.
+	AgeSeconds int64  `json:"age_seconds"`
+	CreatedAgo string `json:"created_ago"`
 }
 type PostStore struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *stmtCache
 }
 
 func (s *PostStore) Create(ctx context.Context, post *Post) error {
-	query := `INSERT INTO posts (content,title,user_id,tags)
-	VALUES ($1, $2, $3, $4) RETURNING id, created_at, updated_at`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.create(ctx, tx, post)
+	})
+}
+
+// CreateArticle stores an article and its body in one transaction.
+func (s *PostStore) CreateArticle(ctx context.Context, post *Post, body string) error {
+	post.Kind = PostKindArticle
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.create(ctx, tx, post); err != nil {
+			return err
+		}
+		query := `INSERT INTO post_bodies (post_id, body) VALUES ($1, $2)`
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+		_, err := tx.ExecContext(ctx, query, post.ID, body)
+		return err
+	})
+}
+
+// create inserts the post with its snippets and queues it for search
+// indexing.
+func (s *PostStore) create(ctx context.Context, tx *sql.Tx, post *Post) error {
+	if post.Kind == "" {
+		post.Kind = PostKindNote
+	}
+	if post.Fingerprint == "" {
+		post.Fingerprint = PostFingerprint(post.Title, post.Content)
+	}
+	if post.DuplicateWindow > 0 {
+		if err := s.checkDuplicate(ctx, tx, post); err != nil {
+			return err
+		}
+	}
+	query := `INSERT INTO posts (content,title,user_id,tags,lang,content_warning,kind,fingerprint,has_media,age_restricted)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, created_at, updated_at`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(
+	err := tx.QueryRowContext(
 		ctx,
 		query,
 		post.Content,
 		post.Title,
 		post.UserID,
-		pq.Array(post.Tags)).Scan(
+		pq.Array(post.Tags),
+		post.Lang,
+		post.ContentWarning,
+		post.Kind,
+		post.Fingerprint,
+		PostHasMedia(post.Content),
+		post.AgeRestricted).Scan(
 		&post.ID, &post.CreatedAt, &post.UpdatedAt)
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := createSnippets(ctx, tx, post); err != nil {
+		return err
+	}
+	return enqueueOutbox(ctx, tx, TopicSearchPost, post.ID)
 }
 func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
-	query := `SELECT id, content, title, user_id, tags, created_at, updated_at,version
-		FROM posts
-		WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	query := `SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, p.updated_at, p.version, p.lang,
+		p.content_warning, p.age_restricted, p.kind, p.comments_count, p.accepted_answer_id, u.id, u.username, u.is_bot, p.on_hold OR u.on_hold
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 	var post Post
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
+	err := s.stmts.QueryRowContext(ctx, "post_by_id", query, id).Scan(
 		&post.ID,
 		&post.Content,
 		&post.Title,
@@ -62,7 +194,17 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 		pq.Array(&post.Tags),
 		&post.CreatedAt,
 		&post.UpdatedAt,
-		&post.Version)
+		&post.Version,
+		&post.Lang,
+		&post.ContentWarning,
+		&post.AgeRestricted,
+		&post.Kind,
+		&post.CommentCount,
+		&post.AcceptedAnswerID,
+		&post.User.ID,
+		&post.User.Username,
+		&post.User.IsBot,
+		&post.OnHold)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -74,11 +216,161 @@ func (s *PostStore) GetByID(ctx context.Context, id int64) (*Post, error) {
 	}
 	return &post, nil
 }
-func (s *PostStore) Delete(ctx context.Context, id int64) error {
-	query := `DELETE FROM posts WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+
+// GetByIDs fetches several posts in one round trip. Missing and held IDs and
+// stories are skipped and the result follows the order of ids. Authors are
+// left for the caller to hydrate with Users.GetByIDs.
+func (s *PostStore) GetByIDs(ctx context.Context, ids []int64) ([]Post, error) {
+	query := `SELECT p.id, p.content, p.title, p.user_id, p.tags, p.created_at, p.updated_at, p.version, p.lang, p.content_warning, p.age_restricted, p.kind, p.comments_count
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.id = ANY($1) AND NOT p.on_hold AND NOT u.on_hold AND p.kind <> 'story'`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]Post, len(ids))
+	for rows.Next() {
+		var post Post
+		err := rows.Scan(
+			&post.ID,
+			&post.Content,
+			&post.Title,
+			&post.UserID,
+			pq.Array(&post.Tags),
+			&post.CreatedAt,
+			&post.UpdatedAt,
+			&post.Version,
+			&post.Lang,
+			&post.ContentWarning,
+			&post.AgeRestricted,
+			&post.Kind,
+			&post.CommentCount)
+		if err != nil {
+			return nil, err
+		}
+		byID[post.ID] = post
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	posts := make([]Post, 0, len(byID))
+	for _, id := range ids {
+		if post, ok := byID[id]; ok {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+// PostFingerprint hashes the title and content after folding case and
+// whitespace, so resubmissions of the same text compare equal.
+func PostFingerprint(title, content string) string {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+	}
+	sum := sha256.Sum256([]byte(normalize(title) + "\x00" + normalize(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// mediaEmbed matches a markdown image, the only way a post embeds media.
+var mediaEmbed = regexp.MustCompile(`!\[[^\]]*\]\([^)]+\)`)
+
+// PostHasMedia reports whether the content embeds an image. It is stored with
+// the post so search can filter on it.
+func PostHasMedia(content string) bool {
+	return mediaEmbed.MatchString(content)
+}
+
+// duplicateLockClass namespaces the advisory locks serializing the creation
+// of one author's posts with the same fingerprint, the second key is the
+// hash of both.
+const duplicateLockClass = 8302
+
+const recentDuplicateQuery = `SELECT id FROM posts
+	WHERE user_id = $1 AND fingerprint = $2 AND created_at > $3
+	ORDER BY created_at DESC
+	LIMIT 1`
+
+// checkDuplicate fails with a DuplicatePostError when the author published
+// the post's fingerprint within its DuplicateWindow. It holds a transaction
+// lock on the author and fingerprint first, so of two identical submissions
+// racing each other the second sees the first once it commits.
+func (s *PostStore) checkDuplicate(ctx context.Context, tx *sql.Tx, post *Post) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	lock := `SELECT pg_advisory_xact_lock($1, hashtext($2::text || ':' || $3))`
+	if _, err := tx.ExecContext(ctx, lock, duplicateLockClass, post.UserID, post.Fingerprint); err != nil {
+		return err
+	}
+	var id int64
+	err := tx.QueryRowContext(ctx, recentDuplicateQuery, post.UserID, post.Fingerprint, time.Now().Add(-post.DuplicateWindow)).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	}
+	return &DuplicatePostError{ExistingID: id}
+}
+
+// FindRecentDuplicate returns the ID of the user's latest post with the same
+// fingerprint created within the window, or ErrRecordNotFound.
+func (s *PostStore) FindRecentDuplicate(ctx context.Context, userID int64, fingerprint string, window time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+	var id int64
+	err := s.db.QueryRowContext(ctx, recentDuplicateQuery, userID, fingerprint, time.Now().Add(-window)).Scan(&id)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+	return id, nil
+}
+
+func (s *PostStore) GetBody(ctx context.Context, postID int64) (*PostBody, error) {
+	query := `SELECT post_id, body, updated_at FROM post_bodies WHERE post_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+	var body PostBody
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&body.PostID, &body.Body, &body.UpdatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &body, nil
+}
+
+// UpdateArticle updates an article and its body in one transaction.
+func (s *PostStore) UpdateArticle(ctx context.Context, post *Post, body string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.update(ctx, tx, post); err != nil {
+			return err
+		}
+		return s.updateBody(ctx, tx, post.ID, body)
+	})
+}
+
+func (s *PostStore) updateBody(ctx context.Context, tx *sql.Tx, postID int64, body string) error {
+	query := `UPDATE post_bodies SET body = $1, updated_at = now() WHERE post_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
-	res, err := s.db.ExecContext(ctx, query, id)
+	res, err := tx.ExecContext(ctx, query, body, postID)
 	if err != nil {
 		return err
 	}
@@ -91,17 +383,132 @@ func (s *PostStore) Delete(ctx context.Context, id int64) error {
 	}
 	return nil
 }
+
+// Delete removes the post. Comments go with it through the foreign key, the
+// reactions on both are keyed by subject and have to be cleaned up here.
+// Posts under legal hold are kept and ErrUnderLegalHold is returned.
+func (s *PostStore) Delete(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		var held bool
+		holdQuery := `SELECT p.on_hold OR u.on_hold FROM posts p JOIN users u ON u.id = p.user_id WHERE p.id = $1 FOR UPDATE OF p`
+		if err := tx.QueryRowContext(ctx, holdQuery, id).Scan(&held); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		if held {
+			return ErrUnderLegalHold
+		}
+
+		reactionsQuery := `
+		DELETE FROM reactions
+		WHERE (subject_type = 'post' AND subject_id = $1)
+			OR (subject_type = 'comment' AND subject_id IN (SELECT id FROM comments WHERE post_id = $1))
+		`
+		if _, err := tx.ExecContext(ctx, reactionsQuery, id); err != nil {
+			return err
+		}
+		query := `DELETE FROM posts WHERE id = $1`
+		res, err := tx.ExecContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrRecordNotFound
+		}
+		return enqueueOutbox(ctx, tx, TopicSearchPost, id)
+	})
+}
+
+// PurgeExpired deletes up to limit posts older than their authors' retention
+// setting or past their own expiry, like Delete would. Posts under a legal
+// hold are kept, and so are posts the author bookmarked unless they expired.
+// It returns the deleted IDs.
+func (s *PostStore) PurgeExpired(ctx context.Context, limit int) ([]int64, error) {
+	var ids []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		rows, err := tx.QueryContext(ctx, `
+		SELECT p.id
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		WHERE NOT p.on_hold AND NOT u.on_hold
+			AND (p.expires_at < NOW()
+				OR u.post_retention_months IS NOT NULL
+					AND p.created_at < NOW() - make_interval(months => u.post_retention_months)
+					AND NOT EXISTS (SELECT 1 FROM bookmarks b WHERE b.post_id = p.id AND b.user_id = p.user_id))
+		ORDER BY p.id
+		LIMIT $1
+		FOR UPDATE OF p SKIP LOCKED
+		`, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		reactionsQuery := `
+		DELETE FROM reactions
+		WHERE (subject_type = 'post' AND subject_id = ANY($1))
+			OR (subject_type = 'comment' AND subject_id IN (SELECT id FROM comments WHERE post_id = ANY($1)))
+		`
+		if _, err := tx.ExecContext(ctx, reactionsQuery, pq.Array(ids)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id = ANY($1)`, pq.Array(ids)); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := enqueueOutbox(ctx, tx, TopicSearchPost, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ids, err
+}
+
 func (s *PostStore) Update(ctx context.Context, post *Post) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.update(ctx, tx, post)
+	})
+}
+
+func (s *PostStore) update(ctx context.Context, tx *sql.Tx, post *Post) error {
 	query := `
 	UPDATE posts
-	SET title = $1, content = $2, updated_at = now(), version = version + 1
-	WHERE id = $3 AND version = $4
+	SET title = $1, content = $2, lang = $3, content_warning = $4, fingerprint = $5, has_media = $8, age_restricted = $9, updated_at = now(), version = version + 1
+	WHERE id = $6 AND version = $7
 	RETURNING version
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
-	err := s.db.QueryRowContext(ctx, query, post.Title, post.Content, post.ID, post.Version).Scan(&post.Version)
+	err := tx.QueryRowContext(ctx, query, post.Title, post.Content, post.Lang, post.ContentWarning, PostFingerprint(post.Title, post.Content), post.ID, post.Version, PostHasMedia(post.Content), post.AgeRestricted).Scan(&post.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -110,30 +517,34 @@ func (s *PostStore) Update(ctx context.Context, post *Post) error {
 			return err
 		}
 	}
-	return nil
-
+	return enqueueOutbox(ctx, tx, TopicSearchPost, post.ID)
 }
 func (s *PostStore) GetUserFeed(ctx context.Context, user_id int64, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
 	query := `SELECT
- p.id,p.user_id,p.title,p."content",p.created_at,p.version,p.tags,
-	u.username,
-COUNT(C.id) as comments_count
+ p.id,p.user_id,p.title,p."content",p.created_at,p.version,p.tags,p.lang,p.content_warning,p.age_restricted,p.kind,
+p.comments_count
 FROM posts p
-LEFT JOIN comments c ON c.post_id = p."id"
 LEFT JOIN users u ON p.user_id = u.id
 join followers f ON f.follower_id = p.user_id OR p.user_id = $1
 WHERE 
 	f.user_id = $1 AND 
 	(p.title ILIKE '%' || $4 || '%' OR p.content ILIKE '%' || $4 || '%') AND
-	(p.tags && $5 OR $5 = '{}')
+	(p.tags && $5 OR $5 = '{}') AND
+	(p.lang = $6 OR $6 = '') AND
+	(p.content_warning = '' OR NOT $7) AND
+	(NOT p.age_restricted OR NOT $8) AND
+	p.kind NOT IN ('listing', 'story') AND
+	NOT p.on_hold AND NOT u.on_hold
 
-GROUP BY p.id,u.username
+GROUP BY p.id
 ORDER BY p.created_at ` + fq.Sort + `
 LIMIT $2 OFFSET $3
 `
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	args := []any{user_id, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags), fq.Lang, fq.HideWarned, fq.HideAgeRestricted}
+	ctx, cancel := context.WithTimeout(ctx, FeedTimeoutDuration)
 	defer cancel()
-	rows, err := s.db.QueryContext(ctx, query, user_id, fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags))
+	start := time.Now()
+	rows, err := s.stmts.QueryContext(ctx, "feed", query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +553,84 @@ LIMIT $2 OFFSET $3
 	var feed []PostWithMetadata
 	for rows.Next() {
 		var post PostWithMetadata
-		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.Version, pq.Array(&post.Tags), &post.User.Username, &post.CommentCount)
+		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.Version, pq.Array(&post.Tags), &post.Lang, &post.ContentWarning, &post.AgeRestricted, &post.Kind, &post.CommentCount)
 		if err != nil {
 			return nil, err
 		}
+		post.Cursor = EncodeFeedCursor(post.ID)
 		feed = append(feed, post)
 	}
+	explainIfSlow(s.db, "feed", time.Since(start), query, args...)
 
 	return feed, nil
 }
+
+// maxFeedUpdates caps CountFeedSince, clients show "99+" style pills anyway.
+const maxFeedUpdates = 100
+
+// CountFeedSince counts the posts in the user's feed newer than sinceID, up
+// to maxFeedUpdates. It selects nothing but IDs so it stays cheap to poll.
+func (s *PostStore) CountFeedSince(ctx context.Context, userID, sinceID int64) (int, error) {
+	query := `
+	SELECT COUNT(*) FROM (
+		SELECT DISTINCT p.id
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		JOIN followers f ON f.follower_id = p.user_id OR p.user_id = $1
+		WHERE f.user_id = $1 AND p.id > $2 AND p.kind NOT IN ('listing', 'story') AND NOT p.on_hold AND NOT u.on_hold
+		LIMIT $3
+	) updates
+	`
+	ctx, cancel := context.WithTimeout(ctx, FeedTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.stmts.QueryRowContext(ctx, "feed_updates", query, userID, sinceID, maxFeedUpdates).Scan(&count)
+	return count, err
+}
+
+// GetExploreFeed lists recent posts from everyone. Posts written in one of
+// the preferred languages are ranked ahead of the rest, an explicit fq.Lang
+// filter restricts the result to that language only.
+func (s *PostStore) GetExploreFeed(ctx context.Context, preferred []string, fq PaginatedFeedQuery) ([]PostWithMetadata, error) {
+	query := `SELECT
+ p.id,p.user_id,p.title,p."content",p.created_at,p.version,p.tags,p.lang,p.content_warning,p.age_restricted,p.kind,
+	p.comments_count
+FROM posts p
+JOIN users u ON p.user_id = u.id
+WHERE
+	(p.title ILIKE '%' || $3 || '%' OR p.content ILIKE '%' || $3 || '%') AND
+	(p.tags && $4 OR $4 = '{}') AND
+	(p.lang = $5 OR $5 = '') AND
+	(p.content_warning = '' OR NOT $7) AND
+	(NOT p.age_restricted OR NOT $8) AND
+	p.kind NOT IN ('listing', 'story') AND
+	NOT p.on_hold AND NOT u.on_hold
+GROUP BY p.id
+ORDER BY (p.lang = ANY($6)) DESC, p.created_at ` + fq.Sort + `
+LIMIT $1 OFFSET $2
+`
+	args := []any{fq.Limit, fq.Offset, fq.Search, pq.Array(fq.Tags), fq.Lang, pq.Array(preferred), fq.HideWarned, fq.HideAgeRestricted}
+	ctx, cancel := context.WithTimeout(ctx, FeedTimeoutDuration)
+	defer cancel()
+	start := time.Now()
+	rows, err := s.stmts.QueryContext(ctx, "explore", query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var feed []PostWithMetadata
+	for rows.Next() {
+		var post PostWithMetadata
+		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.Version, pq.Array(&post.Tags), &post.Lang, &post.ContentWarning, &post.AgeRestricted, &post.Kind, &post.CommentCount)
+		if err != nil {
+			return nil, err
+		}
+		post.Cursor = EncodeFeedCursor(post.ID)
+		feed = append(feed, post)
+	}
+	explainIfSlow(s.db, "explore", time.Since(start), query, args...)
+
+	return feed, rows.Err()
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// MaxProfileFields is how many custom fields a profile can have.
+const MaxProfileFields = 4
+
+// ProfileField is a custom key/value pair shown on a profile. VerifiedAt is
+// set when Value is a link to a page that links back to the profile with
+// rel="me".
+type ProfileField struct {
+	Name       string     `json:"name"`
+	Value      string     `json:"value"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+}
+
+// ProfileFieldCheck is a link field due for rel="me" verification.
+type ProfileFieldCheck struct {
+	UserID   int64
+	Username string
+	Position int
+	Value    string
+}
+
+type ProfileFieldStore struct {
+	db *sql.DB
+}
+
+// Set replaces the user's fields, in order. Links are verified again.
+func (s *ProfileFieldStore) Set(ctx context.Context, userID int64, fields []ProfileField) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM profile_fields WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for i, f := range fields {
+			_, err := tx.ExecContext(ctx, `
+			INSERT INTO profile_fields (user_id, position, name, value) VALUES ($1, $2, $3, $4)
+			`, userID, i, f.Name, f.Value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *ProfileFieldStore) List(ctx context.Context, userID int64) ([]ProfileField, error) {
+	query := `SELECT name, value, verified_at FROM profile_fields WHERE user_id = $1 ORDER BY position`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := []ProfileField{}
+	for rows.Next() {
+		var f ProfileField
+		if err := rows.Scan(&f.Name, &f.Value, &f.VerifiedAt); err != nil {
+			return nil, err
+		}
+		fields = append(fields, f)
+	}
+	return fields, rows.Err()
+}
+
+// Due returns link fields never checked or last checked more than recheck
+// ago, oldest first.
+func (s *ProfileFieldStore) Due(ctx context.Context, recheck time.Duration, limit int) ([]ProfileFieldCheck, error) {
+	query := `
+	SELECT f.user_id, u.username, f.position, f.value
+	FROM profile_fields f JOIN users u ON u.id = f.user_id
+	WHERE f.value ~* '^https?://'
+		AND (f.checked_at IS NULL OR f.checked_at < NOW() - $1 * interval '1 second')
+	ORDER BY f.checked_at NULLS FIRST
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, recheck.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []ProfileFieldCheck
+	for rows.Next() {
+		var c ProfileFieldCheck
+		if err := rows.Scan(&c.UserID, &c.Username, &c.Position, &c.Value); err != nil {
+			return nil, err
+		}
+		due = append(due, c)
+	}
+	return due, rows.Err()
+}
+
+// Checked records the outcome of verifying the field. A field edited since
+// it was picked up is left alone.
+func (s *ProfileFieldStore) Checked(ctx context.Context, c ProfileFieldCheck, verified bool) error {
+	query := `
+	UPDATE profile_fields SET checked_at = NOW(),
+		verified_at = CASE WHEN $4 THEN COALESCE(verified_at, NOW()) END
+	WHERE user_id = $1 AND position = $2 AND value = $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, c.UserID, c.Position, c.Value, verified)
+	return err
+}
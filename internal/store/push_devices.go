@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PushDevice is a phone registered for mobile push through APNs or FCM. Only
+// native app tokens live here, browser push subscriptions carry keys rather
+// than a token and would need their own table.
+type PushDevice struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"user_id"`
+	Platform   string    `json:"platform"`
+	Token      string    `json:"-"`
+	DeviceName string    `json:"device_name"`
+	AppVersion string    `json:"app_version"`
+	OSVersion  string    `json:"os_version"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type PushDeviceStore struct {
+	db *sql.DB
+}
+
+const pushDeviceColumns = `id, user_id, platform, token, device_name, app_version, os_version, created_at, last_seen_at`
+
+func scanPushDevice(row interface{ Scan(...any) error }, d *PushDevice) error {
+	return row.Scan(&d.ID, &d.UserID, &d.Platform, &d.Token, &d.DeviceName, &d.AppVersion, &d.OSVersion, &d.CreatedAt, &d.LastSeenAt)
+}
+
+// Register stores the device or refreshes it when the token is known. Apps
+// register on every launch, a token that moved to another account on a
+// shared phone follows the latest sign in.
+func (s *PushDeviceStore) Register(ctx context.Context, d *PushDevice) error {
+	query := `
+	INSERT INTO push_devices (user_id, platform, token, device_name, app_version, os_version)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (token) DO UPDATE SET
+		user_id = EXCLUDED.user_id,
+		platform = EXCLUDED.platform,
+		device_name = EXCLUDED.device_name,
+		app_version = EXCLUDED.app_version,
+		os_version = EXCLUDED.os_version,
+		last_seen_at = NOW()
+	RETURNING id, created_at, last_seen_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, d.UserID, d.Platform, d.Token, d.DeviceName, d.AppVersion, d.OSVersion).
+		Scan(&d.ID, &d.CreatedAt, &d.LastSeenAt)
+}
+
+// ListByUser returns the user's devices, most recently seen first.
+func (s *PushDeviceStore) ListByUser(ctx context.Context, userID int64) ([]PushDevice, error) {
+	return s.list(ctx, `SELECT `+pushDeviceColumns+` FROM push_devices WHERE user_id = $1 ORDER BY last_seen_at DESC`, userID)
+}
+
+// ListForNotification returns the devices of the users who should hear about
+// a notification of the type, those who muted it are left out.
+func (s *PushDeviceStore) ListForNotification(ctx context.Context, userIDs []int64, notificationType string) ([]PushDevice, error) {
+	query := `
+	SELECT d.id, d.user_id, d.platform, d.token, d.device_name, d.app_version, d.os_version, d.created_at, d.last_seen_at
+	FROM push_devices d
+	JOIN users u ON u.id = d.user_id
+	WHERE d.user_id = ANY($1) AND u.is_active AND NOT ($2 = ANY(u.muted_notification_types))
+	`
+	return s.list(ctx, query, pq.Array(userIDs), notificationType)
+}
+
+func (s *PushDeviceStore) list(ctx context.Context, query string, args ...any) ([]PushDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []PushDevice{}
+	for rows.Next() {
+		var d PushDevice
+		if err := scanPushDevice(rows, &d); err != nil {
+			return nil, err
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// Delete unregisters one of the user's devices.
+func (s *PushDeviceStore) Delete(ctx context.Context, userID, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM push_devices WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteByToken forgets a token the push provider rejected.
+func (s *PushDeviceStore) DeleteByToken(ctx context.Context, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM push_devices WHERE token = $1`, token)
+	return err
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+type QuestionStore struct {
+	db    *sql.DB
+	posts *PostStore
+}
+
+// Create publishes post as a question.
+func (s *QuestionStore) Create(ctx context.Context, post *Post) error {
+	post.Kind = PostKindQuestion
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return s.posts.create(ctx, tx, post)
+	})
+}
+
+// Accept marks the answer the author of the question accepted, replacing an
+// earlier one, and returns it. The comment must be a visible answer to the
+// question.
+func (s *QuestionStore) Accept(ctx context.Context, postID, commentID int64) (*Comment, error) {
+	query := `
+	WITH answer AS (
+		SELECT id, post_id, user_id, content, created_at
+		FROM comments
+		WHERE id = $2 AND post_id = $1 AND is_answer AND hidden_at IS NULL
+	), accepted AS (
+		UPDATE posts SET accepted_answer_id = (SELECT id FROM answer)
+		WHERE id = $1 AND kind = 'question' AND EXISTS (SELECT 1 FROM answer)
+		RETURNING id
+	)
+	SELECT a.id, a.post_id, a.user_id, a.content, a.created_at
+	FROM answer a JOIN accepted ON accepted.id = a.post_id
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	c := Comment{Answer: true, Accepted: true}
+	err := s.db.QueryRowContext(ctx, query, postID, commentID).Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Unaccept leaves the question without an accepted answer.
+func (s *QuestionStore) Unaccept(ctx context.Context, postID int64) error {
+	query := `UPDATE posts SET accepted_answer_id = NULL WHERE id = $1 AND kind = 'question'`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID)
+	return err
+}
+
+// Unanswered lists the questions without an accepted answer, newest first,
+// optionally only those tagged tag.
+func (s *QuestionStore) Unanswered(ctx context.Context, tag string, limit, offset int) ([]Post, error) {
+	query := `
+	SELECT p.id, p.user_id, p.title, p.content, p.tags, p.created_at, p.lang, p.comments_count, u.id, u.username
+	FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE p.kind = 'question' AND p.accepted_answer_id IS NULL
+		AND ($1 = '' OR $1 = ANY(p.tags))
+		AND NOT p.on_hold AND NOT u.on_hold
+	ORDER BY p.created_at DESC, p.id DESC
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, tag, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []Post{}
+	for rows.Next() {
+		p := Post{Kind: PostKindQuestion}
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Title, &p.Content, pq.Array(&p.Tags), &p.CreatedAt, &p.Lang, &p.CommentCount, &p.User.ID, &p.User.Username); err != nil {
+			return nil, err
+		}
+		questions = append(questions, p)
+	}
+	return questions, rows.Err()
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ReactionTypes = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Things that can be reacted to. Reactions of every kind live in one table
+// keyed by subject type and ID. Direct messages will use
+// ReactionSubjectMessage once they exist.
+const (
+	ReactionSubjectPost    = "post"
+	ReactionSubjectComment = "comment"
+	ReactionSubjectMessage = "message"
+)
+
+// ReactionSummary is the aggregate reaction data of a subject as seen by a
+// specific viewer. Bookmarked only applies to posts.
+type ReactionSummary struct {
+	Counts         map[string]int `json:"counts"`
+	Total          int            `json:"total"`
+	ViewerReaction *string        `json:"viewer_reaction"`
+	Bookmarked     bool           `json:"bookmarked"`
+}
+
+// Reactor is a user who reacted to a subject.
+type Reactor struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Type      string    `json:"type"`
+	ReactedAt time.Time `json:"reacted_at"`
+}
+
+// ReactorPage is one page of reactors plus the total, enough for a "liked by
+// alice and 12 others" line.
+type ReactorPage struct {
+	Total    int       `json:"total"`
+	Reactors []Reactor `json:"reactors"`
+}
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+// Set adds the user's reaction to a subject or replaces their previous one.
+func (s *ReactionStore) Set(ctx context.Context, subjectType string, subjectID, userID int64, reactionType string) error {
+	// posts.reactions_count only moves when the reaction is new, not when
+	// it replaces the user's previous one.
+	query := `
+	WITH upserted AS (
+		INSERT INTO reactions (subject_type, subject_id, user_id, type)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (subject_type, subject_id, user_id) DO UPDATE SET type = EXCLUDED.type, created_at = NOW()
+		RETURNING subject_id, (xmax = 0) AS inserted
+	)
+	`
+	args := []any{subjectType, subjectID, userID, reactionType}
+	if subjectType == ReactionSubjectPost && hotPosts.hit(subjectID) {
+		query += shardUpsert(CounterReactions, `SELECT subject_id, 1 FROM upserted WHERE inserted`, 5)
+		args = append(args, randomShard())
+	} else {
+		query += `
+	UPDATE posts SET reactions_count = reactions_count + 1
+	WHERE $1 = 'post' AND id IN (SELECT subject_id FROM upserted WHERE inserted)
+	`
+	}
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (s *ReactionStore) Remove(ctx context.Context, subjectType string, subjectID, userID int64) error {
+	query := `
+	WITH removed AS (
+		DELETE FROM reactions WHERE subject_type = $1 AND subject_id = $2 AND user_id = $3
+		RETURNING subject_id
+	)
+	`
+	args := []any{subjectType, subjectID, userID}
+	if subjectType == ReactionSubjectPost && hotPosts.hit(subjectID) {
+		query += shardUpsert(CounterReactions, `SELECT subject_id, -1 FROM removed`, 4)
+		args = append(args, randomShard())
+	} else {
+		query += `
+	UPDATE posts SET reactions_count = reactions_count - 1
+	WHERE $1 = 'post' AND id IN (SELECT subject_id FROM removed)
+	`
+	}
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (s *ReactionStore) Summary(ctx context.Context, subjectType string, subjectID, viewerID int64) (*ReactionSummary, error) {
+	query := `
+	SELECT
+		COALESCE((
+			SELECT jsonb_object_agg(type, total) FROM (
+				SELECT type, COUNT(*) AS total FROM reactions
+				WHERE subject_type = $1 AND subject_id = $2 GROUP BY type
+			) r
+		), '{}'),
+		(SELECT type FROM reactions WHERE subject_type = $1 AND subject_id = $2 AND user_id = $3),
+		$1 = 'post' AND EXISTS(SELECT 1 FROM bookmarks WHERE post_id = $2 AND user_id = $3)
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var (
+		counts         []byte
+		viewerReaction sql.NullString
+		summary        ReactionSummary
+	)
+	err := s.db.QueryRowContext(ctx, query, subjectType, subjectID, viewerID).Scan(&counts, &viewerReaction, &summary.Bookmarked)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(counts, &summary.Counts); err != nil {
+		return nil, err
+	}
+	for _, n := range summary.Counts {
+		summary.Total += n
+	}
+	if viewerReaction.Valid {
+		summary.ViewerReaction = &viewerReaction.String
+	}
+	return &summary, nil
+}
+
+// Counts aggregates the reactions of several subjects of the same type in one
+// query, keyed by subject ID. Subjects without reactions are absent.
+func (s *ReactionStore) Counts(ctx context.Context, subjectType string, subjectIDs []int64) (map[int64]map[string]int, error) {
+	query := `
+	SELECT subject_id, type, COUNT(*) FROM reactions
+	WHERE subject_type = $1 AND subject_id = ANY($2)
+	GROUP BY subject_id, type
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, subjectType, pq.Array(subjectIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]map[string]int)
+	for rows.Next() {
+		var (
+			id           int64
+			reactionType string
+			n            int
+		)
+		if err := rows.Scan(&id, &reactionType, &n); err != nil {
+			return nil, err
+		}
+		if counts[id] == nil {
+			counts[id] = make(map[string]int)
+		}
+		counts[id][reactionType] = n
+	}
+	return counts, rows.Err()
+}
+
+// ListReactors pages through the users who reacted to a subject, newest
+// first. An empty reactionType lists every type.
+func (s *ReactionStore) ListReactors(ctx context.Context, subjectType string, subjectID int64, reactionType string, limit, offset int) (*ReactorPage, error) {
+	query := `
+	SELECT u.id, u.username, r.type, r.created_at, COUNT(*) OVER ()
+	FROM reactions r
+	JOIN users u ON u.id = r.user_id
+	WHERE r.subject_type = $1 AND r.subject_id = $2 AND ($3 = '' OR r.type = $3) AND u.is_active = true
+	ORDER BY r.created_at DESC, u.id
+	LIMIT $4 OFFSET $5
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, subjectType, subjectID, reactionType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &ReactorPage{Reactors: []Reactor{}}
+	for rows.Next() {
+		var r Reactor
+		if err := rows.Scan(&r.UserID, &r.Username, &r.Type, &r.ReactedAt, &page.Total); err != nil {
+			return nil, err
+		}
+		page.Reactors = append(page.Reactors, r)
+	}
+	return page, rows.Err()
+}
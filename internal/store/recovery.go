@@ -0,0 +1,359 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RecoveryContact is someone the user trusts to vouch for them when they are
+// locked out of their account.
+type RecoveryContact struct {
+	ContactID int64     `json:"contact_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecoveryRequest asks the user's trusted contacts to confirm that whoever is
+// locked out of the account is its owner. Once enough of them approved and
+// the delay passed, the owner is mailed a reset link.
+type RecoveryRequest struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Approvals int    `json:"approvals"`
+	// ReadyAt is when the reset link goes out, set once the quorum is
+	// reached.
+	ReadyAt   *time.Time `json:"ready_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type RecoveryStore struct {
+	db *sql.DB
+}
+
+const recoveryRequestColumns = `r.id, r.user_id, u.username, r.approvals, r.ready_at, r.expires_at, r.created_at`
+
+func scanRecoveryRequest(row interface{ Scan(...any) error }, req *RecoveryRequest) error {
+	return row.Scan(&req.ID, &req.UserID, &req.Username, &req.Approvals, &req.ReadyAt, &req.ExpiresAt, &req.CreatedAt)
+}
+
+// openRecovery is the condition of requests still in progress.
+const openRecovery = `r.completed_at IS NULL AND r.cancelled_at IS NULL AND r.expires_at > NOW()`
+
+// SetContacts replaces the user's trusted contacts, none turns recovery off.
+// Pending requests are cancelled since they were sent to the old contacts.
+// ErrRecordNotFound means a contact isn't an active member.
+func (s *RecoveryStore) SetContacts(ctx context.Context, userID int64, contactIDs []int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_contacts WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+		INSERT INTO recovery_contacts (user_id, contact_id)
+		SELECT $1, id FROM users WHERE id = ANY($2) AND id <> $1 AND is_active AND NOT is_bot
+		`, userID, pq.Array(contactIDs))
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if int(n) != len(contactIDs) {
+			return ErrRecordNotFound
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE recovery_requests r SET cancelled_at = NOW() WHERE r.user_id = $1 AND `+openRecovery, userID)
+		return err
+	})
+}
+
+func (s *RecoveryStore) ListContacts(ctx context.Context, userID int64) ([]RecoveryContact, error) {
+	query := `
+	SELECT c.contact_id, u.username, c.created_at
+	FROM recovery_contacts c JOIN users u ON u.id = c.contact_id
+	WHERE c.user_id = $1
+	ORDER BY c.created_at, c.contact_id
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []RecoveryContact{}
+	for rows.Next() {
+		var c RecoveryContact
+		if err := rows.Scan(&c.ContactID, &c.Username, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+	return contacts, rows.Err()
+}
+
+// Open starts a recovery of the account and returns it with the contacts to
+// ask. Whoever opened it, the reset link only goes to the account's email.
+// ErrRecordNotFound means the user has fewer than quorum contacts,
+// ErrConflict that a recovery is already in progress.
+func (s *RecoveryStore) Open(ctx context.Context, userID int64, quorum int, expiry time.Duration) (*RecoveryRequest, []int64, error) {
+	var req RecoveryRequest
+	var contacts []int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		// the user's row serializes concurrent openings
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, userID); err != nil {
+			return err
+		}
+		rows, err := tx.QueryContext(ctx, `SELECT contact_id FROM recovery_contacts WHERE user_id = $1 ORDER BY contact_id`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				return err
+			}
+			contacts = append(contacts, id)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(contacts) < quorum {
+			return ErrRecordNotFound
+		}
+
+		var open bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM recovery_requests r WHERE r.user_id = $1 AND `+openRecovery+`)`, userID).Scan(&open); err != nil {
+			return err
+		}
+		if open {
+			return ErrConflict
+		}
+		return scanRecoveryRequest(tx.QueryRowContext(ctx, `
+		WITH r AS (
+			INSERT INTO recovery_requests (user_id, expires_at)
+			VALUES ($1, NOW() + $2 * interval '1 second')
+			RETURNING *
+		)
+		SELECT `+recoveryRequestColumns+` FROM r JOIN users u ON u.id = r.user_id
+		`, userID, expiry.Seconds()), &req)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &req, contacts, nil
+}
+
+// Cancel stops the user's recovery in progress, for owners who didn't ask
+// for it.
+func (s *RecoveryStore) Cancel(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE recovery_requests r SET cancelled_at = NOW() WHERE r.user_id = $1 AND `+openRecovery, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Pending returns the requests the contact is asked to approve and hasn't
+// yet, oldest first.
+func (s *RecoveryStore) Pending(ctx context.Context, contactID int64) ([]RecoveryRequest, error) {
+	query := `
+	SELECT ` + recoveryRequestColumns + `
+	FROM recovery_requests r
+	JOIN recovery_contacts c ON c.user_id = r.user_id AND c.contact_id = $1
+	JOIN users u ON u.id = r.user_id
+	WHERE ` + openRecovery + ` AND r.ready_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM recovery_approvals a WHERE a.request_id = r.id AND a.contact_id = $1)
+	ORDER BY r.id
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, contactID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []RecoveryRequest{}
+	for rows.Next() {
+		var req RecoveryRequest
+		if err := scanRecoveryRequest(rows, &req); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// Approve records the contact vouching for the request. The approval
+// reaching quorum schedules the reset link after delay. ErrRecordNotFound
+// means the request isn't open or the contact isn't one of the user's,
+// ErrConflict that the contact already approved it.
+func (s *RecoveryStore) Approve(ctx context.Context, requestID, contactID int64, quorum int, delay time.Duration) (*RecoveryRequest, error) {
+	var req RecoveryRequest
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		// locking the request makes concurrent approvals count one by one
+		var id int64
+		err := tx.QueryRowContext(ctx, `
+		SELECT r.id
+		FROM recovery_requests r JOIN recovery_contacts c ON c.user_id = r.user_id AND c.contact_id = $2
+		WHERE r.id = $1 AND `+openRecovery+`
+		FOR UPDATE OF r
+		`, requestID, contactID).Scan(&id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		res, err := tx.ExecContext(ctx, `
+		INSERT INTO recovery_approvals (request_id, contact_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		`, requestID, contactID)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrConflict
+		}
+
+		return scanRecoveryRequest(tx.QueryRowContext(ctx, `
+		WITH r AS (
+			UPDATE recovery_requests SET approvals = approvals + 1,
+				ready_at = CASE WHEN approvals + 1 >= $2 AND ready_at IS NULL THEN NOW() + $3 * interval '1 second' ELSE ready_at END
+			WHERE id = $1
+			RETURNING *
+		)
+		SELECT `+recoveryRequestColumns+` FROM r JOIN users u ON u.id = r.user_id
+		`, requestID, quorum, delay.Seconds()), &req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// Ready returns the approved requests whose delay passed and whose reset
+// link hasn't been issued yet.
+func (s *RecoveryStore) Ready(ctx context.Context, limit int) ([]RecoveryRequest, error) {
+	query := `
+	SELECT ` + recoveryRequestColumns + `
+	FROM recovery_requests r JOIN users u ON u.id = r.user_id
+	WHERE ` + openRecovery + ` AND r.ready_at <= NOW() AND r.token_hash IS NULL
+	ORDER BY r.ready_at
+	LIMIT $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []RecoveryRequest
+	for rows.Next() {
+		var req RecoveryRequest
+		if err := scanRecoveryRequest(rows, &req); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// IssueLink returns the secret of the request's reset link, valid for
+// expiry. Only its hash is stored.
+func (s *RecoveryStore) IssueLink(ctx context.Context, requestID int64, expiry time.Duration) (string, error) {
+	secret, err := newSecret("", 32)
+	if err != nil {
+		return "", err
+	}
+	query := `
+	UPDATE recovery_requests r SET token_hash = $2, issued_at = NOW(),
+		expires_at = NOW() + $3 * interval '1 second'
+	WHERE r.id = $1 AND r.token_hash IS NULL AND ` + openRecovery
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, requestID, hashSecret(secret), expiry.Seconds())
+	if err != nil {
+		return "", err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return "", err
+	} else if n == 0 {
+		return "", ErrRecordNotFound
+	}
+	return secret, nil
+}
+
+// RevokeLink withdraws the link IssueLink returned, for when it couldn't be
+// delivered, so the request is ready for another link.
+func (s *RecoveryStore) RevokeLink(ctx context.Context, requestID int64) error {
+	query := `
+	UPDATE recovery_requests r SET token_hash = NULL, issued_at = NULL
+	WHERE r.id = $1 AND r.completed_at IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, requestID)
+	return err
+}
+
+// Redeem uses a reset link: the account gets the new password, password
+// login is turned back on and the request is done. It returns the user.
+// ErrRecordNotFound means the link is unknown, used or expired.
+func (s *RecoveryStore) Redeem(ctx context.Context, secret, newPassword string) (int64, error) {
+	var pw password
+	if err := pw.Set(newPassword); err != nil {
+		return 0, err
+	}
+	var userID int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, `
+		UPDATE recovery_requests r SET completed_at = NOW()
+		WHERE r.token_hash = $1 AND `+openRecovery+`
+		RETURNING r.user_id
+		`, hashSecret(secret)).Scan(&userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE users SET password = $1, passwordless = false WHERE id = $2`, pw.hash, userID)
+		return err
+	})
+	return userID, err
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RepoCard previews the GitHub repository a post links to. Cards are added
+// pending when the post is created and shown once fetched.
+type RepoCard struct {
+	PostID      int64     `json:"-"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Stars       int       `json:"stars"`
+	Language    string    `json:"language"`
+	URL         string    `json:"url"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+type RepoCardStore struct {
+	db *sql.DB
+}
+
+// Add queues the card of a post for fetching. A post has one card, the first
+// repository it links to.
+func (s *RepoCardStore) Add(ctx context.Context, postID int64, owner, name string) error {
+	query := `
+	INSERT INTO post_repo_cards (post_id, owner, name)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (post_id) DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, postID, owner, name)
+	return err
+}
+
+// Due returns cards never fetched, then cards fetched more than refresh ago,
+// oldest first.
+func (s *RepoCardStore) Due(ctx context.Context, refresh time.Duration, limit int) ([]RepoCard, error) {
+	query := `
+	SELECT post_id, owner, name
+	FROM post_repo_cards
+	WHERE fetched_at IS NULL OR fetched_at < NOW() - $1 * interval '1 second'
+	ORDER BY fetched_at NULLS FIRST
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, refresh.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []RepoCard
+	for rows.Next() {
+		var c RepoCard
+		if err := rows.Scan(&c.PostID, &c.Owner, &c.Name); err != nil {
+			return nil, err
+		}
+		due = append(due, c)
+	}
+	return due, rows.Err()
+}
+
+// Fetched stores what GitHub answered for the card.
+func (s *RepoCardStore) Fetched(ctx context.Context, card *RepoCard) error {
+	query := `
+	UPDATE post_repo_cards
+	SET description = $2, stars = $3, language = $4, url = $5, fetched_at = NOW()
+	WHERE post_id = $1
+	RETURNING fetched_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, card.PostID, card.Description, card.Stars, card.Language, card.URL).Scan(&card.FetchedAt)
+	if err == sql.ErrNoRows {
+		return ErrRecordNotFound
+	}
+	return err
+}
+
+// Remove drops the card of a post linking to a repository that doesn't
+// exist or isn't public.
+func (s *RepoCardStore) Remove(ctx context.Context, postID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM post_repo_cards WHERE post_id = $1`, postID)
+	return err
+}
+
+// GetByPostIDs returns the fetched cards of the posts, keyed by post.
+func (s *RepoCardStore) GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64]RepoCard, error) {
+	query := `
+	SELECT post_id, owner, name, description, stars, language, url, fetched_at
+	FROM post_repo_cards
+	WHERE post_id = ANY($1) AND fetched_at IS NOT NULL
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cards := make(map[int64]RepoCard)
+	for rows.Next() {
+		var c RepoCard
+		if err := rows.Scan(&c.PostID, &c.Owner, &c.Name, &c.Description, &c.Stars, &c.Language, &c.URL, &c.FetchedAt); err != nil {
+			return nil, err
+		}
+		cards[c.PostID] = c
+	}
+	return cards, rows.Err()
+}
@@ -0,0 +1,212 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Risk signals recorded against an account.
+const (
+	RiskDisposableEmail  = "disposable_email"
+	RiskBurstPosting     = "burst_posting"
+	RiskDuplicateContent = "duplicate_content"
+)
+
+type RiskSignal struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Signal    string    `json:"signal"`
+	Weight    int       `json:"weight"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserRisk is an account's risk score, the sum of the weights of its
+// signals. Signals is only filled in by Get.
+type UserRisk struct {
+	UserID    int64        `json:"user_id"`
+	Username  string       `json:"username"`
+	Score     int          `json:"score"`
+	UpdatedAt time.Time    `json:"updated_at"`
+	Signals   []RiskSignal `json:"signals,omitempty"`
+	// Birthdate is YYYY-MM-DD when known, Adult is derived from it by the API.
+	Birthdate *string `json:"birthdate"`
+	Adult     *bool   `json:"adult,omitempty"`
+}
+
+type RiskStore struct {
+	db *sql.DB
+}
+
+// Record stores the signal and adds its weight to the user's score. A signal
+// of the same kind recorded within dedupe is not counted again, recorded
+// reports whether this one was.
+func (s *RiskStore) Record(ctx context.Context, signal *RiskSignal, dedupe time.Duration) (recorded bool, err error) {
+	err = withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO risk_signals (user_id, signal, weight, detail)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM risk_signals
+			WHERE user_id = $1 AND signal = $2 AND created_at > $5
+		)
+		RETURNING id, created_at
+		`
+		err := tx.QueryRowContext(ctx, query, signal.UserID, signal.Signal, signal.Weight, signal.Detail, time.Now().Add(-dedupe)).
+			Scan(&signal.ID, &signal.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		recorded = true
+
+		scoreQuery := `
+		INSERT INTO user_risk (user_id, score) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET score = user_risk.score + EXCLUDED.score, updated_at = NOW()
+		`
+		_, err = tx.ExecContext(ctx, scoreQuery, signal.UserID, signal.Weight)
+		return err
+	})
+	return recorded, err
+}
+
+// Score returns the user's risk score, 0 without signals.
+func (s *RiskStore) Score(ctx context.Context, userID int64) (int, error) {
+	query := `SELECT score FROM user_risk WHERE user_id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var score int
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&score)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return score, err
+}
+
+// maxRiskSignals caps the signals returned by Get.
+const maxRiskSignals = 50
+
+// Get returns the user's score with the latest signals. Users without
+// signals get a zero score, ErrRecordNotFound means the user doesn't exist.
+func (s *RiskStore) Get(ctx context.Context, userID int64) (*UserRisk, error) {
+	query := `
+	SELECT u.id, u.username, COALESCE(r.score, 0), COALESCE(r.updated_at, u.created_at), to_char(u.birthdate, 'YYYY-MM-DD')
+	FROM users u
+	LEFT JOIN user_risk r ON r.user_id = u.id
+	WHERE u.id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	risk := &UserRisk{Signals: []RiskSignal{}}
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&risk.UserID, &risk.Username, &risk.Score, &risk.UpdatedAt, &risk.Birthdate)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	signalsQuery := `
+	SELECT id, user_id, signal, weight, detail, created_at
+	FROM risk_signals
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, signalsQuery, userID, maxRiskSignals)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sig RiskSignal
+		if err := rows.Scan(&sig.ID, &sig.UserID, &sig.Signal, &sig.Weight, &sig.Detail, &sig.CreatedAt); err != nil {
+			return nil, err
+		}
+		risk.Signals = append(risk.Signals, sig)
+	}
+	return risk, rows.Err()
+}
+
+// ListRisky lists the accounts scoring at least minScore, riskiest first.
+func (s *RiskStore) ListRisky(ctx context.Context, minScore, limit, offset int) ([]UserRisk, error) {
+	query := `
+	SELECT r.user_id, u.username, r.score, r.updated_at, to_char(u.birthdate, 'YYYY-MM-DD')
+	FROM user_risk r
+	JOIN users u ON u.id = r.user_id
+	WHERE r.score >= $1
+	ORDER BY r.score DESC, r.user_id
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, minScore, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserRisk{}
+	for rows.Next() {
+		var risk UserRisk
+		if err := rows.Scan(&risk.UserID, &risk.Username, &risk.Score, &risk.UpdatedAt, &risk.Birthdate); err != nil {
+			return nil, err
+		}
+		users = append(users, risk)
+	}
+	return users, rows.Err()
+}
+
+// Clear drops the user's signals and resets the score, once a moderator has
+// reviewed the account.
+func (s *RiskStore) Clear(ctx context.Context, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM risk_signals WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM user_risk WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// RecentPostCount counts the user's posts created since the given time.
+func (s *RiskStore) RecentPostCount(ctx context.Context, userID int64, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM posts WHERE user_id = $1 AND created_at > $2`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, userID, since).Scan(&count)
+	return count, err
+}
+
+// CountOtherAuthors counts the other accounts that posted content with the
+// same fingerprint since the given time.
+func (s *RiskStore) CountOtherAuthors(ctx context.Context, userID int64, fingerprint string, since time.Time) (int, error) {
+	query := `
+	SELECT COUNT(DISTINCT user_id) FROM posts
+	WHERE fingerprint = $1 AND created_at > $2 AND user_id <> $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var count int
+	err := s.db.QueryRowContext(ctx, query, fingerprint, since, userID).Scan(&count)
+	return count, err
+}
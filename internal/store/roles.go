@@ -23,7 +23,7 @@ func (s *RoleStore) GetByName(ctx context.Context, name string) (*Role, error) {
 	FROM roles
 	WHERE name = $1
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 
 	var role Role
@@ -0,0 +1,21 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+)
+
+type SchemaStore struct {
+	db *sql.DB
+}
+
+// Version reads the version recorded by golang-migrate. Dirty means the last
+// migration failed half way.
+func (s *SchemaStore) Version(ctx context.Context) (version int, dirty bool, err error) {
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	err = s.db.QueryRowContext(ctx, query).Scan(&version, &dirty)
+	return version, dirty, err
+}
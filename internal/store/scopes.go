@@ -0,0 +1,57 @@
+package store
+
+import (
+	"slices"
+	"strings"
+)
+
+// Resources a scoped token can be limited to. A token needs "read:<resource>"
+// to read and "write:<resource>" to change it, write includes read.
+const (
+	ScopeResourcePosts         = "posts"
+	ScopeResourceUsers         = "users"
+	ScopeResourceNotifications = "notifications"
+	ScopeResourceMedia         = "media"
+)
+
+var scopeResources = []string{ScopeResourcePosts, ScopeResourceUsers, ScopeResourceNotifications, ScopeResourceMedia}
+
+// ValidScope reports whether s is "read:<resource>", "write:<resource>" or
+// one of the shorthands OAuthScopeRead and OAuthScopeWrite.
+func ValidScope(s string) bool {
+	if s == OAuthScopeRead || s == OAuthScopeWrite {
+		return true
+	}
+	access, resource, ok := strings.Cut(s, ":")
+	return ok && (access == "read" || access == "write") && slices.Contains(scopeResources, resource)
+}
+
+// ExpandScopes resolves the shorthands, read grants every read scope and
+// write every scope.
+func ExpandScopes(scopes []string) []string {
+	expanded := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		switch s {
+		case OAuthScopeRead, OAuthScopeWrite:
+			for _, resource := range scopeResources {
+				expanded = append(expanded, "read:"+resource)
+				if s == OAuthScopeWrite {
+					expanded = append(expanded, "write:"+resource)
+				}
+			}
+		default:
+			expanded = append(expanded, s)
+		}
+	}
+	slices.Sort(expanded)
+	return slices.Compact(expanded)
+}
+
+// HasScope reports whether the expanded scopes grant the access to the
+// resource.
+func HasScope(scopes []string, write bool, resource string) bool {
+	if slices.Contains(scopes, "write:"+resource) {
+		return true
+	}
+	return !write && slices.Contains(scopes, "read:"+resource)
+}
@@ -0,0 +1,211 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PostSearchQuery holds the filters of /search/posts. They come either from
+// query parameters or from operators inside the text, see Parse.
+type PostSearchQuery struct {
+	Limit        int    `json:"limit" validate:"gte=1,lte=20"`
+	Offset       int    `json:"offset" validate:"gte=0"`
+	Text         string `json:"text" validate:"max=100"`
+	Author       string `json:"author" validate:"max=100"`
+	Since        time.Time
+	Until        time.Time
+	HasMedia     bool `json:"has_media"`
+	MinReactions int  `json:"min_reactions" validate:"gte=0"`
+	// HideAgeRestricted comes from the viewer's age, not the query string.
+	HideAgeRestricted bool `json:"-"`
+}
+
+// Parse reads the query parameters. The free text in q may carry operators
+// for power users:
+//
+//	from:gopher since:2024-01-01 until:2024-02-01 has:media min_reactions:10
+//
+// Explicit parameters (author, since, until, has_media, min_reactions) take
+// precedence over the operators. Dates are YYYY-MM-DD or RFC 3339, a bare
+// until date includes that whole day.
+func (sq PostSearchQuery) Parse(r *http.Request) (PostSearchQuery, error) {
+	qs := r.URL.Query()
+	if limit := qs.Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return sq, err
+		}
+		sq.Limit = l
+	}
+	if offset := qs.Get("offset"); offset != "" {
+		o, err := strconv.Atoi(offset)
+		if err != nil {
+			return sq, err
+		}
+		sq.Offset = o
+	}
+
+	var text []string
+	for _, term := range strings.Fields(qs.Get("q")) {
+		key, value, ok := strings.Cut(term, ":")
+		if !ok || value == "" {
+			text = append(text, term)
+			continue
+		}
+		if err := sq.set(key, value); err != nil {
+			if errors.Is(err, errUnknownOperator) {
+				text = append(text, term)
+				continue
+			}
+			return sq, err
+		}
+	}
+	sq.Text = strings.Join(text, " ")
+
+	params := map[string]string{
+		"author":        "from",
+		"since":         "since",
+		"until":         "until",
+		"has_media":     "has_media",
+		"min_reactions": "min_reactions",
+	}
+	for param, key := range params {
+		if value := qs.Get(param); value != "" {
+			if err := sq.set(key, value); err != nil {
+				return sq, err
+			}
+		}
+	}
+	return sq, nil
+}
+
+var errUnknownOperator = errors.New("unknown search operator")
+
+func (sq *PostSearchQuery) set(key, value string) error {
+	switch key {
+	case "from":
+		sq.Author = strings.TrimPrefix(value, "@")
+	case "since":
+		t, _, err := parseSearchDate(value)
+		if err != nil {
+			return fmt.Errorf("since: %w", err)
+		}
+		sq.Since = t
+	case "until":
+		t, dateOnly, err := parseSearchDate(value)
+		if err != nil {
+			return fmt.Errorf("until: %w", err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		sq.Until = t
+	case "has":
+		if value != "media" {
+			return errUnknownOperator
+		}
+		sq.HasMedia = true
+	case "has_media":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("has_media: %w", err)
+		}
+		sq.HasMedia = b
+	case "min_reactions":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("min_reactions: %w", err)
+		}
+		sq.MinReactions = n
+	default:
+		return errUnknownOperator
+	}
+	return nil
+}
+
+func parseSearchDate(s string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid date %q, use YYYY-MM-DD", s)
+	}
+	return t, false, nil
+}
+
+// Search lists posts matching every filter, newest first, leaving out held
+// posts and stories. Only the filters in use end up in the WHERE clause, so
+// each one can use its index instead of being planned around as
+// "$n = ” OR ...".
+func (s *PostStore) Search(ctx context.Context, sq PostSearchQuery) ([]PostWithMetadata, error) {
+	var (
+		where = []string{"NOT p.on_hold", "NOT u.on_hold", "p.kind <> 'story'"}
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if sq.Text != "" {
+		p := arg(sq.Text)
+		where = append(where, "(p.title ILIKE '%' || "+p+" || '%' OR p.content ILIKE '%' || "+p+" || '%')")
+	}
+	if sq.Author != "" {
+		where = append(where, "u.username = "+arg(sq.Author))
+	}
+	if !sq.Since.IsZero() {
+		where = append(where, "p.created_at >= "+arg(sq.Since))
+	}
+	if !sq.Until.IsZero() {
+		where = append(where, "p.created_at < "+arg(sq.Until))
+	}
+	if sq.HasMedia {
+		where = append(where, "p.has_media")
+	}
+	if sq.HideAgeRestricted {
+		where = append(where, "NOT p.age_restricted")
+	}
+	if sq.MinReactions > 0 {
+		where = append(where, "p.reactions_count >= "+arg(sq.MinReactions))
+	}
+
+	query := `SELECT
+	p.id,p.user_id,p.title,p."content",p.created_at,p.version,p.tags,p.lang,p.content_warning,p.age_restricted,p.kind,
+	p.comments_count
+FROM posts p
+JOIN users u ON p.user_id = u.id
+`
+	query += "WHERE " + strings.Join(where, " AND ") + "\n"
+	query += "ORDER BY p.created_at DESC, p.id DESC\nLIMIT " + arg(sq.Limit) + " OFFSET " + arg(sq.Offset)
+
+	ctx, cancel := context.WithTimeout(ctx, FeedTimeoutDuration)
+	defer cancel()
+	start := time.Now()
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []PostWithMetadata{}
+	for rows.Next() {
+		var post PostWithMetadata
+		err := rows.Scan(&post.ID, &post.UserID, &post.Title, &post.Content, &post.CreatedAt, &post.Version, pq.Array(&post.Tags), &post.Lang, &post.ContentWarning, &post.AgeRestricted, &post.Kind, &post.CommentCount)
+		if err != nil {
+			return nil, err
+		}
+		post.Cursor = EncodeFeedCursor(post.ID)
+		posts = append(posts, post)
+	}
+	explainIfSlow(s.db, "search", time.Since(start), query, args...)
+	return posts, rows.Err()
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Session is a login. Tokens issued for it carry its ID in the sid claim and
+// are refused once it is revoked.
+type Session struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Device     string     `json:"device"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"-"`
+	// Current marks the session of the request listing them.
+	Current bool `json:"current"`
+}
+
+// Active reports whether tokens of the session are still accepted at now.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+const sessionColumns = `id, user_id, device, ip, user_agent, created_at, last_seen_at, expires_at, revoked_at`
+
+func scanSession(row interface{ Scan(...any) error }, s *Session) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Device, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.RevokedAt)
+}
+
+func (s *SessionStore) Create(ctx context.Context, session *Session) error {
+	query := `
+	INSERT INTO sessions (user_id, device, ip, user_agent, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, last_seen_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, session.UserID, session.Device, session.IP, session.UserAgent, session.ExpiresAt).
+		Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+}
+
+func (s *SessionStore) Get(ctx context.Context, id int64) (*Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var session Session
+	if err := scanSession(s.db.QueryRowContext(ctx, query, id), &session); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &session, nil
+}
+
+// Touch records that the session was just used from ip.
+func (s *SessionStore) Touch(ctx context.Context, id int64, ip string) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = NOW(), ip = $2 WHERE id = $1`, id, ip)
+	return err
+}
+
+// Extend pushes back the expiry of an active session, for the fresh token
+// of a re-authentication.
+func (s *SessionStore) Extend(ctx context.Context, id int64, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, `UPDATE sessions SET expires_at = $2 WHERE id = $1 AND revoked_at IS NULL`, id, expiresAt)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListActive returns the user's sessions that are neither revoked nor
+// expired, most recently seen first.
+func (s *SessionStore) ListActive(ctx context.Context, userID int64) ([]Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	ORDER BY last_seen_at DESC, id DESC`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []Session{}
+	for rows.Next() {
+		var session Session
+		if err := scanSession(rows, &session); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// Revoke ends one of the user's sessions.
+func (s *SessionStore) Revoke(ctx context.Context, userID, id int64) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	query := `UPDATE sessions SET revoked_at = NOW() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Prune deletes the sessions that expired or were revoked. Tokens naming a
+// deleted session are refused like those of a revoked one.
+func (s *SessionStore) Prune(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE expires_at <= NOW() OR revoked_at IS NOT NULL`)
+	return err
+}
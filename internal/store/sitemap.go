@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Sitemap sections, each split in pages of SitemapEntries.
+const (
+	SitemapUsers = "users"
+	SitemapPosts = "posts"
+	SitemapTags  = "tags"
+)
+
+// SitemapEntry is one indexable page: a user or post by ID, a tag by name.
+type SitemapEntry struct {
+	ID       int64
+	Tag      string
+	Modified time.Time
+}
+
+// publicPost matches posts an anonymous visitor can open. Stories expire
+// too quickly to be worth indexing.
+const publicPost = `NOT p.on_hold AND NOT p.age_restricted AND p.kind <> 'story'`
+
+// sitemapQueries list each section in a stable order so pages don't shift
+// while crawled.
+var sitemapQueries = map[string]string{
+	SitemapUsers: `
+	SELECT u.id, '', COALESCE((SELECT MAX(p.updated_at) FROM posts p WHERE p.user_id = u.id), u.created_at)
+	FROM users u
+	WHERE u.is_active AND NOT u.on_hold
+	ORDER BY u.id
+	LIMIT $1 OFFSET $2`,
+	SitemapPosts: `
+	SELECT p.id, '', p.updated_at
+	FROM posts p
+	JOIN users u ON u.id = p.user_id
+	WHERE ` + publicPost + ` AND u.is_active AND NOT u.on_hold
+	ORDER BY p.id
+	LIMIT $1 OFFSET $2`,
+	SitemapTags: `
+	SELECT 0, t.tag, MAX(p.updated_at)
+	FROM posts p, unnest(p.tags) AS t(tag)
+	WHERE ` + publicPost + `
+	GROUP BY t.tag
+	ORDER BY t.tag
+	LIMIT $1 OFFSET $2`,
+}
+
+type SitemapStore struct {
+	db *sql.DB
+}
+
+// Counts returns the number of entries of every section.
+func (s *SitemapStore) Counts(ctx context.Context) (map[string]int, error) {
+	query := `
+	SELECT
+		(SELECT COUNT(*) FROM users u WHERE u.is_active AND NOT u.on_hold),
+		(SELECT COUNT(*) FROM posts p JOIN users u ON u.id = p.user_id WHERE ` + publicPost + ` AND u.is_active AND NOT u.on_hold),
+		(SELECT COUNT(DISTINCT t.tag) FROM posts p, unnest(p.tags) AS t(tag) WHERE ` + publicPost + `)
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var users, posts, tags int
+	if err := s.db.QueryRowContext(ctx, query).Scan(&users, &posts, &tags); err != nil {
+		return nil, err
+	}
+	return map[string]int{SitemapUsers: users, SitemapPosts: posts, SitemapTags: tags}, nil
+}
+
+func (s *SitemapStore) Entries(ctx context.Context, section string, limit, offset int) ([]SitemapEntry, error) {
+	query, ok := sitemapQueries[section]
+	if !ok {
+		return nil, fmt.Errorf("unknown sitemap section %q", section)
+	}
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []SitemapEntry
+	for rows.Next() {
+		var e SitemapEntry
+		if err := rows.Scan(&e.ID, &e.Tag, &e.Modified); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
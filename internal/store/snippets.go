@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// MaxPostSnippets is how many code snippets a post can carry.
+const MaxPostSnippets = 4
+
+// Snippet is a code attachment of a post. HTML is the highlighted code,
+// filled in by the API like Post.ContentHTML. PlaygroundURL runs the snippet
+// on the Go Playground, set for whole Go programs.
+type Snippet struct {
+	ID            int64  `json:"id"`
+	PostID        int64  `json:"-"`
+	Language      string `json:"language"`
+	Content       string `json:"content"`
+	HTML          string `json:"html"`
+	PlaygroundURL string `json:"playground_url,omitempty"`
+}
+
+// createSnippets stores the post's snippets in order, as part of creating
+// the post.
+func createSnippets(ctx context.Context, tx *sql.Tx, post *Post) error {
+	for i := range post.Snippets {
+		s := &post.Snippets[i]
+		s.PostID = post.ID
+		query := `
+		INSERT INTO post_snippets (post_id, position, language, content, playground_url)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+		`
+		if err := tx.QueryRowContext(ctx, query, post.ID, i, s.Language, s.Content, s.PlaygroundURL).Scan(&s.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type SnippetStore struct {
+	db *sql.DB
+}
+
+// GetByPostIDs returns the snippets of the posts in order, keyed by post.
+// Posts without snippets are missing from the map.
+func (s *SnippetStore) GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64][]Snippet, error) {
+	query := `
+	SELECT id, post_id, language, content, playground_url
+	FROM post_snippets
+	WHERE post_id = ANY($1)
+	ORDER BY post_id, position
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(postIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := make(map[int64][]Snippet)
+	for rows.Next() {
+		var sn Snippet
+		if err := rows.Scan(&sn.ID, &sn.PostID, &sn.Language, &sn.Content, &sn.PlaygroundURL); err != nil {
+			return nil, err
+		}
+		snippets[sn.PostID] = append(snippets[sn.PostID], sn)
+	}
+	return snippets, rows.Err()
+}
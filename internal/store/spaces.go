@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Space statuses. A space is scheduled until its host starts it and ended
+// for good once stopped.
+const (
+	SpaceScheduled = "scheduled"
+	SpaceLive      = "live"
+	SpaceEnded     = "ended"
+)
+
+// Roles in a space. Cohosts can start and stop it like the host, listeners
+// are whoever joined.
+const (
+	SpaceRoleHost     = "host"
+	SpaceRoleCohost   = "cohost"
+	SpaceRoleListener = "listener"
+)
+
+// Space is a live audio or video room announced by a post of kind
+// PostKindSpace, like Event. The media is handled by an external RTC
+// provider, the space only holds the room's metadata.
+type Space struct {
+	ID          int64  `json:"id"`
+	HostID      int64  `json:"host_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Media is audio or video.
+	Media        string     `json:"media"`
+	Status       string     `json:"status"`
+	ScheduledFor *time.Time `json:"scheduled_for"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	// RoomURL is where clients connect to the media of a live space.
+	RoomURL string `json:"room_url,omitempty"`
+	RoomID  string `json:"-"`
+	// Listeners counts the participants present.
+	Listeners int `json:"listeners"`
+	// Participants are the hosts and whoever is present, filled by Get.
+	Participants []SpaceParticipant `json:"participants,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type SpaceParticipant struct {
+	UserID   int64  `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Present  bool   `json:"present"`
+}
+
+// Role returns the user's role in the space, empty if they have none.
+func (s *Space) Role(userID int64) string {
+	for _, p := range s.Participants {
+		if p.UserID == userID {
+			return p.Role
+		}
+	}
+	return ""
+}
+
+type SpaceStore struct {
+	db    *sql.DB
+	posts *PostStore
+}
+
+const spaceColumns = `p.id, p.user_id, p.title, p.content, s.media, s.status, s.scheduled_for, s.started_at, s.ended_at,
+	s.room_id, s.room_url,
+	(SELECT COUNT(*) FROM space_participants sp WHERE sp.space_id = s.post_id AND sp.joined_at IS NOT NULL),
+	p.created_at`
+
+// spaceVisible leaves out spaces whose post or host is under legal hold.
+const spaceVisible = `NOT p.on_hold AND NOT u.on_hold`
+
+func scanSpace(row interface{ Scan(...any) error }, s *Space) error {
+	return row.Scan(&s.ID, &s.HostID, &s.Title, &s.Description, &s.Media, &s.Status, &s.ScheduledFor, &s.StartedAt,
+		&s.EndedAt, &s.RoomID, &s.RoomURL, &s.Listeners, &s.CreatedAt)
+}
+
+// Create publishes the post announcing the space and the space itself, with
+// the host as its first participant. The space takes the post's ID.
+func (s *SpaceStore) Create(ctx context.Context, post *Post, space *Space) error {
+	post.Kind = PostKindSpace
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.posts.create(ctx, tx, post); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		query := `
+		INSERT INTO spaces (post_id, media, scheduled_for)
+		VALUES ($1, $2, $3)
+		`
+		if _, err := tx.ExecContext(ctx, query, post.ID, space.Media, space.ScheduledFor); err != nil {
+			return err
+		}
+		query = `INSERT INTO space_participants (space_id, user_id, role) VALUES ($1, $2, 'host')`
+		if _, err := tx.ExecContext(ctx, query, post.ID, post.UserID); err != nil {
+			return err
+		}
+		space.ID, space.HostID, space.Title, space.Description, space.CreatedAt = post.ID, post.UserID, post.Title, post.Content, post.CreatedAt
+		space.Status = SpaceScheduled
+		space.Participants = []SpaceParticipant{{UserID: post.UserID, Username: post.User.Username, Role: SpaceRoleHost}}
+		return nil
+	})
+}
+
+// Get returns the space with its hosts and the participants present.
+func (s *SpaceStore) Get(ctx context.Context, id int64) (*Space, error) {
+	query := `
+	SELECT ` + spaceColumns + `
+	FROM spaces s JOIN posts p ON p.id = s.post_id JOIN users u ON u.id = p.user_id
+	WHERE s.post_id = $1 AND ` + spaceVisible
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var space Space
+	if err := scanSpace(s.db.QueryRowContext(ctx, query, id), &space); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+
+	query = `
+	SELECT sp.user_id, u.username, sp.role, sp.joined_at IS NOT NULL
+	FROM space_participants sp JOIN users u ON u.id = sp.user_id
+	WHERE sp.space_id = $1
+	ORDER BY CASE sp.role WHEN 'host' THEN 0 WHEN 'cohost' THEN 1 ELSE 2 END, sp.joined_at, sp.user_id
+	`
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p SpaceParticipant
+		if err := rows.Scan(&p.UserID, &p.Username, &p.Role, &p.Present); err != nil {
+			return nil, err
+		}
+		space.Participants = append(space.Participants, p)
+	}
+	return &space, rows.Err()
+}
+
+// List returns the live or scheduled spaces, live ones most recently started
+// first, scheduled ones soonest first. A followerID limits them to hosts the
+// user follows.
+func (s *SpaceStore) List(ctx context.Context, status string, followerID int64, limit, offset int) ([]Space, error) {
+	query := `
+	SELECT ` + spaceColumns + `
+	FROM spaces s JOIN posts p ON p.id = s.post_id JOIN users u ON u.id = p.user_id
+	WHERE s.status = $1 AND ` + spaceVisible + `
+		AND ($2 = 0 OR EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $2))
+	ORDER BY s.started_at DESC NULLS LAST, s.scheduled_for NULLS LAST, s.post_id
+	LIMIT $3 OFFSET $4
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, status, followerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	spaces := []Space{}
+	for rows.Next() {
+		var space Space
+		if err := scanSpace(rows, &space); err != nil {
+			return nil, err
+		}
+		spaces = append(spaces, space)
+	}
+	return spaces, rows.Err()
+}
+
+// Start takes a scheduled space live in the given room. ErrConflict means it
+// isn't scheduled anymore.
+func (s *SpaceStore) Start(ctx context.Context, id int64, roomID, roomURL string) error {
+	query := `
+	UPDATE spaces SET status = 'live', started_at = NOW(), room_id = $2, room_url = $3
+	WHERE post_id = $1 AND status = 'scheduled'
+	`
+	return s.transition(ctx, query, id, roomID, roomURL)
+}
+
+// Stop ends a live space: listeners are let go and the hosts marked absent.
+// ErrConflict means it isn't live.
+func (s *SpaceStore) Stop(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `
+		UPDATE spaces SET status = 'ended', ended_at = NOW(), room_url = ''
+		WHERE post_id = $1 AND status = 'live'
+		`, id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return ErrConflict
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM space_participants WHERE space_id = $1 AND role = 'listener'`, id); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE space_participants SET joined_at = NULL WHERE space_id = $1`, id)
+		return err
+	})
+}
+
+func (s *SpaceStore) transition(ctx context.Context, query string, args ...any) error {
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// Join marks the user present in a live space, as a listener unless they
+// host it. ErrConflict means the space isn't live.
+func (s *SpaceStore) Join(ctx context.Context, id, userID int64) error {
+	query := `
+	INSERT INTO space_participants (space_id, user_id, role, joined_at)
+	SELECT post_id, $2, 'listener', NOW() FROM spaces WHERE post_id = $1 AND status = 'live'
+	ON CONFLICT (space_id, user_id) DO UPDATE SET joined_at = COALESCE(space_participants.joined_at, NOW())
+	`
+	return s.transition(ctx, query, id, userID)
+}
+
+// Leave marks the user absent. Listeners leave the space entirely, hosts
+// keep their role.
+func (s *SpaceStore) Leave(ctx context.Context, id, userID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM space_participants WHERE space_id = $1 AND user_id = $2 AND role = 'listener'`, id, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE space_participants SET joined_at = NULL WHERE space_id = $1 AND user_id = $2`, id, userID)
+		return err
+	})
+}
+
+// SetCohost makes the user a cohost of a space that hasn't ended, or turns a
+// cohost back into a listener, or nothing if they aren't present. The host
+// can't be changed.
+func (s *SpaceStore) SetCohost(ctx context.Context, id, userID int64, cohost bool) error {
+	if cohost {
+		query := `
+		INSERT INTO space_participants (space_id, user_id, role)
+		SELECT post_id, $2, 'cohost' FROM spaces WHERE post_id = $1 AND status <> 'ended'
+		ON CONFLICT (space_id, user_id) DO UPDATE SET role = 'cohost' WHERE space_participants.role <> 'host'
+		`
+		return s.transition(ctx, query, id, userID)
+	}
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM space_participants WHERE space_id = $1 AND user_id = $2 AND role = 'cohost' AND joined_at IS NULL`, id, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE space_participants SET role = 'listener' WHERE space_id = $1 AND user_id = $2 AND role = 'cohost'`, id, userID)
+		return err
+	})
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"gopher_social/internal/metrics"
+	"sync"
+	"time"
+)
+
+var (
+	prepareStatements = true
+	hotQueries        *metrics.QueryRecorder
+)
+
+// SetPreparedStatements turns statement preparation for the hot queries on or
+// off. Turn it off behind a pooler that does not keep session state, such as
+// PgBouncer in transaction mode. rec, when not nil, records the latency of
+// those queries per label and mode ("prepared" or "direct"), so the two can
+// be compared. It must be called before the storage is used.
+func SetPreparedStatements(on bool, rec *metrics.QueryRecorder) {
+	prepareStatements = on
+	hotQueries = rec
+}
+
+func observeHotQuery(label string, prepared bool, d time.Duration, rows int) {
+	if hotQueries == nil {
+		return
+	}
+	if prepared {
+		label += "/prepared"
+	} else {
+		label += "/direct"
+	}
+	hotQueries.Observe(label, d, rows)
+}
+
+// stmtCache prepares the hot queries once and reuses them. database/sql
+// prepares a Stmt again on every pool connection it first runs on, so this
+// amounts to one prepared statement per query and connection.
+type stmtCache struct {
+	db    *sql.DB
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newStmtCache(db *sql.DB) *stmtCache {
+	return &stmtCache{db: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// prepared returns the statement for query, nil when preparation is off or
+// failed, in which case the query runs unprepared.
+func (c *stmtCache) prepared(ctx context.Context, query string) *sql.Stmt {
+	if !prepareStatements {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if stmt, ok := c.stmts[query]; ok {
+		return stmt
+	}
+	stmt, err := c.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil
+	}
+	c.stmts[query] = stmt
+	return stmt
+}
+
+// QueryRowContext runs a single row query, label names it in the recorder.
+func (c *stmtCache) QueryRowContext(ctx context.Context, label, query string, args ...any) *sql.Row {
+	start := time.Now()
+	var row *sql.Row
+	if stmt := c.prepared(ctx, query); stmt != nil {
+		row = stmt.QueryRowContext(ctx, args...)
+		observeHotQuery(label, true, time.Since(start), 1)
+	} else {
+		row = c.db.QueryRowContext(ctx, query, args...)
+		observeHotQuery(label, false, time.Since(start), 1)
+	}
+	return row
+}
+
+// QueryContext runs a query returning rows. Only the time to the first row is
+// recorded, rows are not counted.
+func (c *stmtCache) QueryContext(ctx context.Context, label, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	if stmt := c.prepared(ctx, query); stmt != nil {
+		rows, err := stmt.QueryContext(ctx, args...)
+		observeHotQuery(label, true, time.Since(start), 0)
+		return rows, err
+	}
+	rows, err := c.db.QueryContext(ctx, query, args...)
+	observeHotQuery(label, false, time.Since(start), 0)
+	return rows, err
+}
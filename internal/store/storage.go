@@ -5,51 +5,455 @@ import (
 	"database/sql"
 	"errors"
 	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrRecordNotFound = errors.New("record not found")
+	ErrConflict       = errors.New("resource already exists")
+	// ErrQueryTimeout marks the errors of statements cut short by their
+	// context's deadline, the pool's driver wraps them with it.
+	ErrQueryTimeout = errors.New("query timed out")
 )
 
+// Query timeouts per operation class. Feeds and search get their own budget
+// since they are the heaviest reads. Override them with SetQueryTimeouts.
 var (
-	ErrRecordNotFound    = errors.New("record not found")
-	ErrConflict          = errors.New("resource already exists")
-	QueryTimeoutDuration = time.Second * 5
+	ReadTimeoutDuration  = time.Second * 5
+	WriteTimeoutDuration = time.Second * 5
+	FeedTimeoutDuration  = time.Second * 5
 )
 
+// SetQueryTimeouts replaces the per class query timeouts. It must be called
+// before the storage is used, zero values keep the default.
+func SetQueryTimeouts(read, write, feed time.Duration) {
+	if read > 0 {
+		ReadTimeoutDuration = read
+	}
+	if write > 0 {
+		WriteTimeoutDuration = write
+	}
+	if feed > 0 {
+		FeedTimeoutDuration = feed
+	}
+}
+
+// IsQueryTimeout reports whether err comes from a query that ran out of
+// time, either through its context deadline or Postgres cancelling it. Other
+// deadlines, such as the cache's or an outbound request's, don't count.
+func IsQueryTimeout(err error) bool {
+	if errors.Is(err, ErrQueryTimeout) {
+		return true
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "57014"
+}
+
 type Storage struct {
 	Posts interface {
 		GetByID(context.Context, int64) (*Post, error)
+		GetByIDs(context.Context, []int64) ([]Post, error)
 		Create(context.Context, *Post) error
+		CreateArticle(ctx context.Context, post *Post, body string) error
+		FindRecentDuplicate(ctx context.Context, userID int64, fingerprint string, window time.Duration) (int64, error)
+		GetBody(context.Context, int64) (*PostBody, error)
+		UpdateArticle(ctx context.Context, post *Post, body string) error
 		Delete(context.Context, int64) error
+		PurgeExpired(ctx context.Context, limit int) ([]int64, error)
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int64, PaginatedFeedQuery) ([]PostWithMetadata, error)
+		GetExploreFeed(ctx context.Context, preferred []string, fq PaginatedFeedQuery) ([]PostWithMetadata, error)
+		CountFeedSince(ctx context.Context, userID, sinceID int64) (int, error)
+		Search(ctx context.Context, sq PostSearchQuery) ([]PostWithMetadata, error)
 	}
 	Users interface {
 		Create(context.Context, *sql.Tx, *User) error
 		GetByID(context.Context, int64) (*User, error)
+		GetByIDs(context.Context, []int64) ([]User, error)
 		GetByEmail(context.Context, string) (*User, error)
 		CreateAndInvite(ctx context.Context, user *User, token string, exp time.Duration) error
+		CreateServiceAccount(ctx context.Context, user *User) error
 		Activate(ctx context.Context, token string) error
+		RequestEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error
+		ConfirmEmailChange(ctx context.Context, token string) (int64, error)
 		Delete(context.Context, int64) error
+		SetPasswordless(ctx context.Context, userID int64, passwordless bool) error
+		SetPreferredLanguages(ctx context.Context, userID int64, languages []string) error
+		SetLocale(ctx context.Context, userID int64, locale, timezone string) error
+		SetBirthdate(ctx context.Context, userID int64, birthdate string) error
+		SetContentWarningPref(ctx context.Context, userID int64, pref string) error
+		SetMutedNotificationTypes(ctx context.Context, userID int64, types []string) error
+		SetPostRetention(ctx context.Context, userID int64, months *int) error
+		GetProfile(ctx context.Context, userID int64) (*Profile, error)
+		GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]int64, error)
+		GetIDsByEmails(ctx context.Context, emails []string) (map[string]int64, error)
+	}
+	Credentials interface {
+		Create(context.Context, *Credential) error
+		GetByUserID(context.Context, int64) ([]Credential, error)
+		Touch(ctx context.Context, id []byte, data []byte) error
+		Delete(ctx context.Context, userID int64, id []byte) error
+	}
+	Media interface {
+		Create(context.Context, *Media) error
+		GetByID(context.Context, int64) (*Media, error)
+		GetByBlobKey(context.Context, string) (*Media, error)
+		ListByScanStatus(ctx context.Context, status string, limit, offset int) ([]Media, error)
+		Review(ctx context.Context, id int64, status string, reviewerID int64) error
 	}
 	Comments interface {
-		GetByPostID(context.Context, int64) ([]Comment, error)
+		GetByPostID(ctx context.Context, postID, viewerID int64, includeHidden bool) ([]Comment, error)
+		SetHidden(ctx context.Context, commentID int64, hidden bool, hiddenBy int64) error
+		GetByID(context.Context, int64) (*Comment, error)
+		GetPreviewsByPostIDs(ctx context.Context, postIDs []int64) (map[int64]Comment, error)
 		Create(context.Context, *Comment) error
 	}
 	Followers interface {
 		Follow(ctx context.Context, followerID, userID int64) error
 		Unfollow(ctx context.Context, followerID, userID int64) error
 		ExistsFollow(ctx context.Context, followerID, userID int64) (bool, error)
+		SetNotify(ctx context.Context, followerID, userID int64, notify bool) error
+		GetSubscriberIDs(ctx context.Context, userID int64) ([]int64, error)
+		EachFollowing(ctx context.Context, followerID int64, fn func(FollowedUser) error) error
+		FollowMany(ctx context.Context, followerID int64, userIDs []int64, dryRun bool) (int, error)
+	}
+	Reactions interface {
+		Set(ctx context.Context, subjectType string, subjectID, userID int64, reactionType string) error
+		Remove(ctx context.Context, subjectType string, subjectID, userID int64) error
+		Summary(ctx context.Context, subjectType string, subjectID, viewerID int64) (*ReactionSummary, error)
+		Counts(ctx context.Context, subjectType string, subjectIDs []int64) (map[int64]map[string]int, error)
+		ListReactors(ctx context.Context, subjectType string, subjectID int64, reactionType string, limit, offset int) (*ReactorPage, error)
+	}
+	Bookmarks interface {
+		Add(ctx context.Context, postID, userID int64) error
+		Remove(ctx context.Context, postID, userID int64) error
+	}
+	Impressions interface {
+		Record(ctx context.Context, postID, viewerID int64, source string) error
+		Insights(ctx context.Context, postID, authorID int64, since time.Time) (*PostInsights, error)
+	}
+	Analytics interface {
+		AggregateFollowers(ctx context.Context, day time.Time, engagementWindow time.Duration) error
+		FollowerInsights(ctx context.Context, userID int64, since time.Time) (*FollowerInsights, error)
+		AggregatePlatform(ctx context.Context, day time.Time) error
+		LastAggregated(ctx context.Context, name string) (time.Time, error)
+		PlatformDaily(ctx context.Context, since time.Time) ([]PlatformDay, error)
+		RetentionCohorts(ctx context.Context, since time.Time) ([]RetentionCohort, error)
+		TopHashtags(ctx context.Context, since time.Time, limit int) ([]HashtagCount, error)
+	}
+	MailOutbox interface {
+		Enqueue(ctx context.Context, mail *QueuedMail) error
+		Claim(ctx context.Context, maxAttempts, limit int, lease time.Duration) ([]QueuedMail, error)
+		MarkSent(ctx context.Context, id int64) error
+		MarkFailed(ctx context.Context, id int64, reason string) error
+		Purge(ctx context.Context, before time.Time, maxAttempts int) error
+	}
+	Notifications interface {
+		CreateMany(context.Context, []Notification) error
+		GetByUserID(ctx context.Context, userID int64, limit, offset int) ([]Notification, error)
+		LatestSeq(ctx context.Context, userID int64) (int64, error)
+		ChangedAfter(ctx context.Context, userID, seq int64, limit int) ([]Notification, error)
+		MarkAllRead(ctx context.Context, userID int64) error
+		MarkSeen(ctx context.Context, userID int64, ids []int64, channel string) ([]int64, error)
+		RecordDelivery(ctx context.Context, channel string, attempted, delivered []int64) error
+		PendingDelivery(ctx context.Context, channel string, since, until time.Time, limit int) ([]Notification, error)
+		CreateFollowBackSuggestions(ctx context.Context, since time.Time, dailyCap, limit int) (int, error)
+	}
+	FeedPositions interface {
+		Get(ctx context.Context, userID int64) (*FeedPosition, error)
+		Set(ctx context.Context, userID int64, cursor string) (*FeedPosition, error)
 	}
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+	Risk interface {
+		Record(ctx context.Context, signal *RiskSignal, dedupe time.Duration) (bool, error)
+		Score(ctx context.Context, userID int64) (int, error)
+		Get(ctx context.Context, userID int64) (*UserRisk, error)
+		ListRisky(ctx context.Context, minScore, limit, offset int) ([]UserRisk, error)
+		Clear(ctx context.Context, userID int64) error
+		RecentPostCount(ctx context.Context, userID int64, since time.Time) (int, error)
+		CountOtherAuthors(ctx context.Context, userID int64, fingerprint string, since time.Time) (int, error)
+	}
+	ModeratorNotes interface {
+		Create(ctx context.Context, note *ModeratorNote) error
+		List(ctx context.Context, subjectType string, subjectID int64, limit, offset int) ([]ModeratorNote, error)
+	}
+	Terms interface {
+		Publish(ctx context.Context, t *TermsVersion) error
+		Current(ctx context.Context) ([]TermsVersion, error)
+		List(ctx context.Context, limit, offset int) ([]TermsVersion, error)
+		Accepted(ctx context.Context, userID int64) ([]TermsVersion, error)
+		Accept(ctx context.Context, userID, termsID int64) error
+	}
+	Announcements interface {
+		Create(ctx context.Context, a *Announcement) error
+		ListActive(ctx context.Context, userID int64) ([]Announcement, error)
+		List(ctx context.Context, limit, offset int) ([]Announcement, error)
+		Delete(ctx context.Context, id int64) error
+		Dismiss(ctx context.Context, id, userID int64) error
+	}
+	OAuth interface {
+		CreateClient(ctx context.Context, c *OAuthClient, secret string) error
+		GetClient(ctx context.Context, clientID string) (*OAuthClient, error)
+		AuthenticateClient(ctx context.Context, clientID, secret string) (*OAuthClient, error)
+		ListClients(ctx context.Context, ownerID int64) ([]OAuthClient, error)
+		RevokeClient(ctx context.Context, ownerID int64, clientID string) error
+		CreateCode(ctx context.Context, code *OAuthCode, secret string) error
+		ExchangeCode(ctx context.Context, clientID int64, secret string) (*OAuthCode, error)
+		PruneCodes(ctx context.Context) error
+		GetGrant(ctx context.Context, userID int64, clientID string) (*OAuthGrant, error)
+		ListGrants(ctx context.Context, userID int64) ([]OAuthGrant, error)
+		RevokeGrant(ctx context.Context, userID int64, clientID string) error
+	}
+	APIKeys interface {
+		Create(ctx context.Context, key *APIKey, secret string) error
+		Authenticate(ctx context.Context, secret string) (*APIKey, error)
+		ListByUser(ctx context.Context, userID int64) ([]APIKey, error)
+		Revoke(ctx context.Context, userID, id int64) error
+	}
+	Recovery interface {
+		SetContacts(ctx context.Context, userID int64, contactIDs []int64) error
+		ListContacts(ctx context.Context, userID int64) ([]RecoveryContact, error)
+		Open(ctx context.Context, userID int64, quorum int, expiry time.Duration) (*RecoveryRequest, []int64, error)
+		Cancel(ctx context.Context, userID int64) error
+		Pending(ctx context.Context, contactID int64) ([]RecoveryRequest, error)
+		Approve(ctx context.Context, requestID, contactID int64, quorum int, delay time.Duration) (*RecoveryRequest, error)
+		Ready(ctx context.Context, limit int) ([]RecoveryRequest, error)
+		IssueLink(ctx context.Context, requestID int64, expiry time.Duration) (string, error)
+		RevokeLink(ctx context.Context, requestID int64) error
+		Redeem(ctx context.Context, secret, newPassword string) (int64, error)
+	}
+	Events interface {
+		Create(ctx context.Context, post *Post, e *Event) error
+		Get(ctx context.Context, id, viewerID int64) (*Event, error)
+		Upcoming(ctx context.Context, userID int64, limit, offset int) ([]Event, error)
+		RSVP(ctx context.Context, id, userID int64, status string) error
+		RemoveRSVP(ctx context.Context, id, userID int64) error
+		Attendees(ctx context.Context, id int64, limit, offset int) ([]EventAttendee, error)
+		Cancel(ctx context.Context, id, organizerID int64) ([]int64, error)
+		ClaimReminders(ctx context.Context, lead time.Duration, limit int) ([]EventReminder, error)
+	}
+	Snippets interface {
+		GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64][]Snippet, error)
+	}
+	Questions interface {
+		Create(ctx context.Context, post *Post) error
+		Accept(ctx context.Context, postID, commentID int64) (*Comment, error)
+		Unaccept(ctx context.Context, postID int64) error
+		Unanswered(ctx context.Context, tag string, limit, offset int) ([]Post, error)
+	}
+	Spaces interface {
+		Create(ctx context.Context, post *Post, space *Space) error
+		Get(ctx context.Context, id int64) (*Space, error)
+		List(ctx context.Context, status string, followerID int64, limit, offset int) ([]Space, error)
+		Start(ctx context.Context, id int64, roomID, roomURL string) error
+		Stop(ctx context.Context, id int64) error
+		Join(ctx context.Context, id, userID int64) error
+		Leave(ctx context.Context, id, userID int64) error
+		SetCohost(ctx context.Context, id, userID int64, cohost bool) error
+	}
+	Stories interface {
+		Create(ctx context.Context, post *Post, ttl time.Duration) (*Story, error)
+		Get(ctx context.Context, id, viewerID int64) (*Story, error)
+		Feed(ctx context.Context, userID int64, limit int) ([]Story, error)
+		View(ctx context.Context, id, viewerID int64) error
+		Viewers(ctx context.Context, id int64, limit, offset int) ([]StoryViewer, error)
+	}
+	Maintenance interface {
+		Create(ctx context.Context, m *MaintenanceWindow) error
+		ListActive(ctx context.Context) ([]MaintenanceWindow, error)
+		List(ctx context.Context, limit, offset int) ([]MaintenanceWindow, error)
+		End(ctx context.Context, id int64) error
+	}
+	Sitemap interface {
+		Counts(ctx context.Context) (map[string]int, error)
+		Entries(ctx context.Context, section string, limit, offset int) ([]SitemapEntry, error)
+	}
+	EmailPosts interface {
+		Get(ctx context.Context, userID int64) (*EmailPostAddress, error)
+		Rotate(ctx context.Context, userID int64) (*EmailPostAddress, error)
+		Delete(ctx context.Context, userID int64) error
+		UserID(ctx context.Context, token string) (int64, error)
+	}
+	Crossposts interface {
+		Accounts(ctx context.Context, userID int64) ([]CrosspostAccount, error)
+		Connect(ctx context.Context, acct *CrosspostAccount) error
+		SetEnabled(ctx context.Context, userID int64, network string, enabled bool) error
+		Disconnect(ctx context.Context, userID int64, network string) error
+		Enqueue(ctx context.Context, postID, userID int64) (int, error)
+		Due(ctx context.Context, limit int) ([]CrosspostDelivery, error)
+		Delivered(ctx context.Context, id int64, remoteURL string) error
+		Failed(ctx context.Context, id int64, reason string, retryAt *time.Time) error
+		ForPosts(ctx context.Context, postIDs []int64) (map[int64][]CrosspostDelivery, error)
+	}
+	RepoCards interface {
+		Add(ctx context.Context, postID int64, owner, name string) error
+		Due(ctx context.Context, refresh time.Duration, limit int) ([]RepoCard, error)
+		Fetched(ctx context.Context, card *RepoCard) error
+		Remove(ctx context.Context, postID int64) error
+		GetByPostIDs(ctx context.Context, postIDs []int64) (map[int64]RepoCard, error)
+	}
+	Listings interface {
+		Create(ctx context.Context, post *Post, l *Listing) error
+		Get(ctx context.Context, id int64) (*Listing, error)
+		Feed(ctx context.Context, category string, limit, offset int) ([]Listing, error)
+		ListPending(ctx context.Context, limit, offset int) ([]Listing, error)
+		Review(ctx context.Context, id, moderatorID int64, status string) (*Listing, error)
+	}
+	Badges interface {
+		Grant(ctx context.Context, userID int64, badge string, grantedBy int64) error
+		Revoke(ctx context.Context, userID int64, badge string) error
+		Assign(ctx context.Context, staffRole string, earlyAdopters int) ([]int64, error)
+	}
+	ProfileFields interface {
+		Set(ctx context.Context, userID int64, fields []ProfileField) error
+		List(ctx context.Context, userID int64) ([]ProfileField, error)
+		Due(ctx context.Context, recheck time.Duration, limit int) ([]ProfileFieldCheck, error)
+		Checked(ctx context.Context, c ProfileFieldCheck, verified bool) error
+	}
+	Emoji interface {
+		Create(ctx context.Context, e *Emoji) error
+		List(ctx context.Context) ([]Emoji, error)
+		Get(ctx context.Context, shortcode string) (*Emoji, error)
+		Delete(ctx context.Context, shortcode string) (*Emoji, error)
+	}
+	PushDevices interface {
+		Register(ctx context.Context, d *PushDevice) error
+		ListByUser(ctx context.Context, userID int64) ([]PushDevice, error)
+		ListForNotification(ctx context.Context, userIDs []int64, notificationType string) ([]PushDevice, error)
+		Delete(ctx context.Context, userID, id int64) error
+		DeleteByToken(ctx context.Context, token string) error
+	}
+	Archive interface {
+		Candidates(ctx context.Context, cutoff time.Time, limit int) ([]int64, error)
+		Archive(ctx context.Context, postID int64, put func(context.Context, *PostArchive) (string, error)) error
+		Get(ctx context.Context, postID int64) (*ArchivedPost, error)
+		List(ctx context.Context, userID int64, limit, offset int) ([]ArchivedPost, error)
+		Restore(ctx context.Context, postID int64, archive *PostArchive) error
+		PruneOrphans(ctx context.Context, limit int) ([]ArchivedPost, error)
+	}
+	Tombstones interface {
+		Create(ctx context.Context, t *PostTombstone) error
+		Get(ctx context.Context, postID int64) (*PostTombstone, error)
+	}
+	Instance interface {
+		Get(ctx context.Context) (*InstanceSettings, error)
+		Update(ctx context.Context, i *InstanceSettings) error
+	}
+	Sessions interface {
+		Create(ctx context.Context, s *Session) error
+		Get(ctx context.Context, id int64) (*Session, error)
+		Touch(ctx context.Context, id int64, ip string) error
+		Extend(ctx context.Context, id int64, expiresAt time.Time) error
+		ListActive(ctx context.Context, userID int64) ([]Session, error)
+		Revoke(ctx context.Context, userID, id int64) error
+		Prune(ctx context.Context) error
+	}
+	AccountMerges interface {
+		Merge(ctx context.Context, m *AccountMerge) error
+		List(ctx context.Context, limit, offset int) ([]AccountMerge, error)
+	}
+	LegalHolds interface {
+		Place(ctx context.Context, hold *LegalHold) error
+		Release(ctx context.Context, id, releasedBy int64) (*LegalHold, error)
+		GetByID(ctx context.Context, id int64) (*LegalHold, error)
+		List(ctx context.Context, all bool, limit, offset int) ([]LegalHold, error)
+		IsHeld(ctx context.Context, subjectType string, subjectID int64) (bool, error)
+		Export(ctx context.Context, hold *LegalHold) (*HoldExport, error)
+		StreamExport(ctx context.Context, hold *LegalHold, emit func(HoldRecord) error) error
+	}
+	ModerationCases interface {
+		Create(ctx context.Context, c *ModerationCase) error
+		GetByID(ctx context.Context, id int64) (*ModerationCase, error)
+		ListByUser(ctx context.Context, userID int64, limit, offset int) ([]ModerationCase, error)
+		ListAppeals(ctx context.Context, status string, limit, offset int) ([]ModerationCase, error)
+		Appeal(ctx context.Context, id, userID int64, text string) (*ModerationCase, error)
+		Resolve(ctx context.Context, id, moderatorID int64, status, note string) (*ModerationCase, error)
+	}
+	Hashtags interface {
+		Counts(ctx context.Context, from, to time.Time) (map[string]int, error)
+		RefreshRelated(ctx context.Context, since time.Time, perTag int) error
+		Related(ctx context.Context, tag string, limit int) ([]RelatedTag, error)
+	}
+	Outbox interface {
+		Enqueue(ctx context.Context, topic string, aggregateID int64, payload []byte) error
+		GetPending(ctx context.Context, topic string, maxAttempts, limit int) ([]OutboxEvent, error)
+		MarkProcessed(ctx context.Context, ids []int64) error
+		MarkFailed(ctx context.Context, ids []int64, reason string) error
+		EnqueueAllPosts(ctx context.Context) (int, error)
+		Prune(ctx context.Context, before time.Time, abandonedTopics []string) error
+	}
+	Schema interface {
+		Version(ctx context.Context) (version int, dirty bool, err error)
+	}
+	Partitions interface {
+		List(ctx context.Context, table string) ([]Partition, error)
+		Create(ctx context.Context, p Partition) error
+		Drop(ctx context.Context, p Partition) error
+	}
+	Counters interface {
+		Compact(ctx context.Context) (int, error)
+	}
+	JobLocks interface {
+		TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+	}
 }
 
 func NewPostgresStorage(db *sql.DB) Storage {
+	stmts := newStmtCache(db)
+	posts := &PostStore{db: db, stmts: stmts}
 	return Storage{
-		Posts:     &PostStore{db: db},
-		Users:     &UserStore{db: db},
-		Comments:  &CommentStore{db: db},
-		Followers: &FollowerStore{db: db},
-		Roles:     &RoleStore{db: db},
+		Posts:           posts,
+		Users:           &UserStore{db: db, stmts: stmts},
+		Credentials:     &CredentialStore{db: db},
+		Media:           &MediaStore{db: db},
+		Comments:        &CommentStore{db: db},
+		Followers:       &FollowerStore{db: db},
+		Roles:           &RoleStore{db: db},
+		Reactions:       &ReactionStore{db: db},
+		Bookmarks:       &BookmarkStore{db: db},
+		Impressions:     &ImpressionStore{db: db},
+		Analytics:       &AnalyticsStore{db: db},
+		MailOutbox:      &MailOutboxStore{db: db},
+		Schema:          &SchemaStore{db: db},
+		Partitions:      &PartitionStore{db: db},
+		Counters:        &CounterStore{db: db},
+		Outbox:          &OutboxStore{db: db},
+		Hashtags:        &HashtagStore{db: db},
+		Risk:            &RiskStore{db: db},
+		ModeratorNotes:  &ModeratorNoteStore{db: db},
+		ModerationCases: &ModerationCaseStore{db: db},
+		LegalHolds:      &LegalHoldStore{db: db},
+		AccountMerges:   &AccountMergeStore{db: db},
+		APIKeys:         &APIKeyStore{db: db, stmts: stmts},
+		Recovery:        &RecoveryStore{db: db},
+		Emoji:           &EmojiStore{db: db},
+		ProfileFields:   &ProfileFieldStore{db: db},
+		Badges:          &BadgeStore{db: db},
+		Events:          &EventStore{db: db, posts: posts},
+		Listings:        &ListingStore{db: db, posts: posts},
+		Snippets:        &SnippetStore{db: db},
+		RepoCards:       &RepoCardStore{db: db},
+		Crossposts:      &CrosspostStore{db: db},
+		EmailPosts:      &EmailPostStore{db: db},
+		Sitemap:         &SitemapStore{db: db},
+		Maintenance:     &MaintenanceStore{db: db},
+		Questions:       &QuestionStore{db: db, posts: posts},
+		Spaces:          &SpaceStore{db: db, posts: posts},
+		Stories:         &StoryStore{db: db, posts: posts},
+		OAuth:           &OAuthStore{db: db},
+		PushDevices:     &PushDeviceStore{db: db},
+		Archive:         &ArchiveStore{db: db},
+		Tombstones:      &TombstoneStore{db: db},
+		Instance:        &InstanceStore{db: db},
+		Sessions:        &SessionStore{db: db},
+		JobLocks:        &JobLockStore{db: db},
+		Announcements:   &AnnouncementStore{db: db},
+		Terms:           &TermsStore{db: db},
+		Notifications:   &NotificationStore{db: db},
+		FeedPositions:   &FeedPositionStore{db: db},
 	}
 }
 func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
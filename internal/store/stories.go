@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Story is a post of kind PostKindStory, shown in the stories feed until it
+// expires and then purged with the expired posts. ID is the post's ID.
+type Story struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	Username    string    `json:"username"`
+	Content     string    `json:"content"`
+	ContentHTML string    `json:"content_html"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	// Seen is set once the viewer viewed the story.
+	Seen bool `json:"seen"`
+	// Views counts who viewed the story, only shown to its author.
+	Views *int `json:"views,omitempty"`
+}
+
+// StoryViewer is someone who viewed a story.
+type StoryViewer struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	ViewedAt time.Time `json:"viewed_at"`
+}
+
+type StoryStore struct {
+	db    *sql.DB
+	posts *PostStore
+}
+
+const storyColumns = `p.id, p.user_id, u.username, p.content, p.created_at, p.expires_at,
+	EXISTS (SELECT 1 FROM story_views v WHERE v.story_id = p.id AND v.viewer_id = $1) AS seen,
+	CASE WHEN p.user_id = $1 THEN (SELECT COUNT(*) FROM story_views v WHERE v.story_id = p.id) END`
+
+// storyActive leaves out stories past their expiry that weren't purged yet
+// and those under legal hold.
+const storyActive = `p.kind = 'story' AND p.expires_at > NOW() AND NOT p.on_hold AND NOT u.on_hold`
+
+func scanStory(row interface{ Scan(...any) error }, s *Story) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Username, &s.Content, &s.CreatedAt, &s.ExpiresAt, &s.Seen, &s.Views)
+}
+
+// Create publishes post as a story expiring after ttl.
+func (s *StoryStore) Create(ctx context.Context, post *Post, ttl time.Duration) (*Story, error) {
+	post.Kind = PostKindStory
+	story := &Story{UserID: post.UserID, Username: post.User.Username, Content: post.Content}
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.posts.create(ctx, tx, post); err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE posts SET expires_at = created_at + $2 * interval '1 second' WHERE id = $1 RETURNING expires_at`
+		return tx.QueryRowContext(ctx, query, post.ID, ttl.Seconds()).Scan(&story.ExpiresAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	views := 0
+	story.ID, story.CreatedAt, story.Views = post.ID, post.CreatedAt, &views
+	return story, nil
+}
+
+// Get returns an active story as seen by viewerID.
+func (s *StoryStore) Get(ctx context.Context, id, viewerID int64) (*Story, error) {
+	query := `
+	SELECT ` + storyColumns + `
+	FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE p.id = $2 AND ` + storyActive
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var story Story
+	if err := scanStory(s.db.QueryRowContext(ctx, query, viewerID, id), &story); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &story, nil
+}
+
+// Feed returns the active stories of the user and the people they follow:
+// the user's own first, then unseen ones, newest first.
+func (s *StoryStore) Feed(ctx context.Context, userID int64, limit int) ([]Story, error) {
+	query := `
+	SELECT ` + storyColumns + `
+	FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE ` + storyActive + `
+		AND (p.user_id = $1 OR EXISTS (SELECT 1 FROM followers f WHERE f.user_id = p.user_id AND f.follower_id = $1))
+	ORDER BY p.user_id = $1 DESC, seen, p.created_at DESC
+	LIMIT $2
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stories := []Story{}
+	for rows.Next() {
+		var story Story
+		if err := scanStory(rows, &story); err != nil {
+			return nil, err
+		}
+		stories = append(stories, story)
+	}
+	return stories, rows.Err()
+}
+
+// View records that viewerID viewed an active story. Authors viewing their
+// own story aren't counted. ErrRecordNotFound means there is no such active
+// story.
+func (s *StoryStore) View(ctx context.Context, id, viewerID int64) error {
+	query := `
+	INSERT INTO story_views (story_id, viewer_id)
+	SELECT p.id, $2 FROM posts p JOIN users u ON u.id = p.user_id
+	WHERE p.id = $1 AND p.user_id <> $2 AND ` + storyActive + `
+	ON CONFLICT (story_id, viewer_id) DO NOTHING
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, id, viewerID)
+	return err
+}
+
+// Viewers lists who viewed the story, most recent first.
+func (s *StoryStore) Viewers(ctx context.Context, id int64, limit, offset int) ([]StoryViewer, error) {
+	query := `
+	SELECT v.viewer_id, u.username, v.viewed_at
+	FROM story_views v JOIN users u ON u.id = v.viewer_id
+	WHERE v.story_id = $1
+	ORDER BY v.viewed_at DESC, v.viewer_id
+	LIMIT $2 OFFSET $3
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, id, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	viewers := []StoryViewer{}
+	for rows.Next() {
+		var v StoryViewer
+		if err := rows.Scan(&v.UserID, &v.Username, &v.ViewedAt); err != nil {
+			return nil, err
+		}
+		viewers = append(viewers, v)
+	}
+	return viewers, rows.Err()
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Documents users have to accept.
+const (
+	TermsOfService = "terms"
+	PrivacyPolicy  = "privacy"
+)
+
+// ErrTermsChanged means the user accepted terms that are no longer current.
+var ErrTermsChanged = errors.New("the terms have changed, accept the current version")
+
+// TermsVersion is a published version of the terms of service or privacy
+// policy. IDs grow with every publication, a user is up to date when their
+// AcceptedTermsID is the highest current ID.
+type TermsVersion struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Version     string    `json:"version"`
+	URL         string    `json:"url"`
+	Summary     string    `json:"summary"`
+	PublishedBy int64     `json:"published_by,omitempty"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+type TermsStore struct {
+	db *sql.DB
+}
+
+const termsColumns = `id, kind, version, url, summary, COALESCE(published_by, 0), published_at`
+
+func scanTerms(row interface{ Scan(...any) error }, t *TermsVersion) error {
+	return row.Scan(&t.ID, &t.Kind, &t.Version, &t.URL, &t.Summary, &t.PublishedBy, &t.PublishedAt)
+}
+
+// Publish adds a version, ErrConflict means the kind already has it.
+func (s *TermsStore) Publish(ctx context.Context, t *TermsVersion) error {
+	query := `
+	INSERT INTO terms_versions (kind, version, url, summary, published_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, published_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, t.Kind, t.Version, t.URL, t.Summary, t.PublishedBy).Scan(&t.ID, &t.PublishedAt)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code == "23505" {
+		return ErrConflict
+	}
+	return err
+}
+
+// Current returns the latest version of each document.
+func (s *TermsStore) Current(ctx context.Context) ([]TermsVersion, error) {
+	query := `SELECT DISTINCT ON (kind) ` + termsColumns + ` FROM terms_versions ORDER BY kind, id DESC`
+	return s.list(ctx, query)
+}
+
+// List returns every published version, newest first.
+func (s *TermsStore) List(ctx context.Context, limit, offset int) ([]TermsVersion, error) {
+	query := `SELECT ` + termsColumns + ` FROM terms_versions ORDER BY id DESC LIMIT $1 OFFSET $2`
+	return s.list(ctx, query, limit, offset)
+}
+
+// Accepted returns the versions the user accepted, newest first.
+func (s *TermsStore) Accepted(ctx context.Context, userID int64) ([]TermsVersion, error) {
+	query := `SELECT ` + termsColumns + ` FROM terms_versions
+	WHERE id IN (SELECT terms_id FROM terms_acceptances WHERE user_id = $1)
+	ORDER BY id DESC`
+	return s.list(ctx, query, userID)
+}
+
+func (s *TermsStore) list(ctx context.Context, query string, args ...any) ([]TermsVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []TermsVersion{}
+	for rows.Next() {
+		var t TermsVersion
+		if err := scanTerms(rows, &t); err != nil {
+			return nil, err
+		}
+		versions = append(versions, t)
+	}
+	return versions, rows.Err()
+}
+
+// Accept records that the user accepted the current version of every
+// document. termsID is the highest current ID the user was shown, an older
+// one returns ErrTermsChanged.
+func (s *TermsStore) Accept(ctx context.Context, userID, termsID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		var latest int64
+		if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM terms_versions`).Scan(&latest); err != nil {
+			return err
+		}
+		if termsID != latest {
+			return ErrTermsChanged
+		}
+
+		query := `
+		INSERT INTO terms_acceptances (user_id, terms_id)
+		SELECT $1, id FROM (SELECT DISTINCT ON (kind) id FROM terms_versions ORDER BY kind, id DESC) current
+		ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE users SET accepted_terms_id = $2 WHERE id = $1`, userID, latest)
+		return err
+	})
+}
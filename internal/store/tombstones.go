@@ -0,0 +1,58 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// PostTombstone stands in for a post removed by a moderator, it says why and
+// when the post went away and how its appeal, if any, stands.
+type PostTombstone struct {
+	PostID       int64     `json:"post_id"`
+	UserID       int64     `json:"user_id"`
+	Reason       string    `json:"reason"`
+	CaseID       *int64    `json:"case_id"`
+	AppealStatus string    `json:"appeal_status"`
+	RemovedAt    time.Time `json:"removed_at"`
+}
+
+type TombstoneStore struct {
+	db *sql.DB
+}
+
+func (s *TombstoneStore) Create(ctx context.Context, t *PostTombstone) error {
+	query := `
+	INSERT INTO post_tombstones (post_id, user_id, reason, case_id)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (post_id) DO UPDATE SET reason = EXCLUDED.reason, case_id = EXCLUDED.case_id
+	RETURNING removed_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	return s.db.QueryRowContext(ctx, query, t.PostID, t.UserID, t.Reason, t.CaseID).Scan(&t.RemovedAt)
+}
+
+func (s *TombstoneStore) Get(ctx context.Context, postID int64) (*PostTombstone, error) {
+	query := `
+	SELECT t.post_id, t.user_id, t.reason, t.case_id, COALESCE(c.appeal_status, ''), t.removed_at
+	FROM post_tombstones t LEFT JOIN moderation_cases c ON c.id = t.case_id
+	WHERE t.post_id = $1
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var t PostTombstone
+	err := s.db.QueryRowContext(ctx, query, postID).Scan(&t.PostID, &t.UserID, &t.Reason, &t.CaseID, &t.AppealStatus, &t.RemovedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &t, nil
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/lib/pq"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -17,15 +18,53 @@ var (
 )
 
 type User struct {
-	ID        int64    `json:"id"`
-	Username  string   `json:"username"`
-	Email     string   `json:"email"`
-	Password  password `json:"-"`
-	CreatedAt string   `json:"created_at"`
-	IsActive  bool     `json:"is_active"`
-	RoleID    int64    `json:"role_id"`
-	Role      *Role    `json:"role"`
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Password  password  `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	IsActive  bool      `json:"is_active"`
+	// Passwordless accounts can only sign in with an enrolled passkey.
+	Passwordless bool `json:"passwordless"`
+	// IsBot marks service accounts. They are created by admins and only
+	// authenticate with API keys.
+	IsBot bool `json:"is_bot"`
+	// PreferredLanguages are ISO 639-1 codes that bias the explore feed.
+	PreferredLanguages []string `json:"preferred_languages"`
+	// ContentWarningPref is one of the ContentWarning* constants.
+	ContentWarningPref string `json:"content_warning_pref"`
+	// MutedNotificationTypes lists the NotificationTypes the user opted out of.
+	MutedNotificationTypes []string `json:"muted_notification_types"`
+	RoleID                 int64    `json:"role_id"`
+	Role                   *Role    `json:"role"`
+	// AcceptedTermsID is the newest TermsVersion the user accepted, 0 if none.
+	AcceptedTermsID int64 `json:"accepted_terms_id"`
+	// Birthdate is YYYY-MM-DD, nil for accounts created before it was
+	// collected.
+	Birthdate *string `json:"birthdate,omitempty"`
+	// Adult is derived from Birthdate by the API, only shown to the user and
+	// moderators.
+	Adult *bool `json:"adult,omitempty"`
+	// PostRetentionMonths has the user's posts deleted once they are older,
+	// nil keeps them. Posts the user bookmarked are kept regardless.
+	PostRetentionMonths *int `json:"post_retention_months,omitempty"`
+	// Locale is the language of the API's messages and emails to the user,
+	// one of i18n.Locales. Timezone is an IANA name such as Europe/Madrid.
+	Locale   string `json:"locale"`
+	Timezone string `json:"timezone"`
+	// Badges are the Badge* constants the user holds.
+	Badges []string `json:"badges"`
+	// Fields are filled in by the API, they aren't cached with the user.
+	Fields []ProfileField `json:"fields,omitempty"`
 }
+
+// How a user wants posts with a content warning presented.
+const (
+	ContentWarningCollapse = "collapse"
+	ContentWarningExpand   = "expand"
+	ContentWarningHide     = "hide"
+)
+
 type password struct {
 	text *string
 	hash []byte
@@ -45,16 +84,17 @@ func (p *password) Compare(text string) error {
 }
 
 type UserStore struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *stmtCache
 }
 
 func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	query :=
-		`INSERT INTO users (username,password, email,role_id)
-	VALUES ($1, $2, $3, (SELECT id FROM roles WHERE name = $4))
+		`INSERT INTO users (username,password, email,role_id,birthdate,locale,timezone)
+	VALUES ($1, $2, $3, (SELECT id FROM roles WHERE name = $4), $5, COALESCE(NULLIF($6, ''), 'en'), COALESCE(NULLIF($7, ''), 'UTC'))
 	RETURNING id, created_at
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 
 	role := user.Role.Name
@@ -68,6 +108,9 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 		user.Password.hash,
 		user.Email,
 		role,
+		user.Birthdate,
+		user.Locale,
+		user.Timezone,
 	).Scan(&user.ID, &user.CreatedAt)
 	if err != nil {
 		switch {
@@ -81,22 +124,49 @@ func (s *UserStore) Create(ctx context.Context, tx *sql.Tx, user *User) error {
 	}
 	return nil
 }
+
+// GetByIDs loads the public profile of several users in one round trip, for
+// showing them as authors. Missing IDs are skipped.
+func (s *UserStore) GetByIDs(ctx context.Context, ids []int64) ([]User, error) {
+	query := `SELECT id, username, is_bot, created_at, ` + userBadges + ` FROM users WHERE id = ANY($1)`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0, len(ids))
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.IsBot, &u.CreatedAt, pq.Array(&u.Badges)); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
 func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	query := `
-		SELECT users.id, username, email, password, created_at, roles.*
+		SELECT users.id, username, email, password, created_at, passwordless, preferred_languages, content_warning_pref, muted_notification_types, accepted_terms_id, to_char(birthdate, 'YYYY-MM-DD'), is_bot, post_retention_months, locale, timezone, ` + userBadges + `,
+			roles.id, roles.name, roles.level, roles.description
 		FROM users
 		JOIN roles ON (users.role_id = roles.id)
 		WHERE users.id = $1 AND is_active = true
 	`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 
 	user := &User{
 		Role: &Role{},
 	}
-	err := s.db.QueryRowContext(
+	err := s.stmts.QueryRowContext(
 		ctx,
+		"user_by_id",
 		query,
 		userID,
 	).Scan(
@@ -105,6 +175,17 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.CreatedAt,
+		&user.Passwordless,
+		pq.Array(&user.PreferredLanguages),
+		&user.ContentWarningPref,
+		pq.Array(&user.MutedNotificationTypes),
+		&user.AcceptedTermsID,
+		&user.Birthdate,
+		&user.IsBot,
+		&user.PostRetentionMonths,
+		&user.Locale,
+		&user.Timezone,
+		pq.Array(&user.Badges),
 		&user.Role.ID,
 		&user.Role.Name,
 		&user.Role.Level,
@@ -122,6 +203,32 @@ func (s *UserStore) GetByID(ctx context.Context, userID int64) (*User, error) {
 	return user, nil
 }
 
+// CreateServiceAccount creates an active bot account without a password, it
+// can only be used through API keys.
+func (s *UserStore) CreateServiceAccount(ctx context.Context, user *User) error {
+	query := `
+	INSERT INTO users (username, password, email, role_id, is_active, passwordless, is_bot)
+	VALUES ($1, ''::bytea, $2, (SELECT id FROM roles WHERE name = 'user'), true, true, true)
+	RETURNING id, created_at
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	err := s.db.QueryRowContext(ctx, query, user.Username, user.Email).Scan(&user.ID, &user.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_username_key"`:
+			return ErrDuplicateUsername
+		default:
+			return err
+		}
+	}
+	user.IsActive, user.Passwordless, user.IsBot = true, true, true
+	return nil
+}
+
 func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
 
@@ -140,7 +247,7 @@ func (s *UserStore) CreateAndInvite(ctx context.Context, user *User, token strin
 }
 func (s *UserStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, invitationExp time.Duration, userID int64) error {
 	query := `INSERT INTO user_invitations (token,user_id,expiry) VALUES ($1, $2, $3)`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 	_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(invitationExp))
 	if err != nil {
@@ -188,7 +295,7 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 	JOIN user_invitations ui ON ui.user_id = u.id
 	WHERE ui.token = $1 AND ui.expiry > $2
 	`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 	hash := sha256.Sum256([]byte(token))
 	hashToken := hex.EncodeToString(hash[:])
@@ -211,9 +318,61 @@ func (s *UserStore) getUserFromInvitation(ctx context.Context, tx *sql.Tx, token
 	return user, nil
 
 }
+
+// RequestEmailChange replaces the user's pending email change, token is the
+// hash of the link mailed to newEmail.
+func (s *UserStore) RequestEmailChange(ctx context.Context, userID int64, newEmail, token string, exp time.Duration) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		query := `INSERT INTO email_changes (token, user_id, new_email, expiry) VALUES ($1, $2, $3, $4)`
+		_, err := tx.ExecContext(ctx, query, token, userID, newEmail, time.Now().Add(exp))
+		return err
+	})
+}
+
+// ConfirmEmailChange swaps in the address the token was mailed to and
+// returns whose it now is. ErrDuplicateEmail means another account took the
+// address in the meantime.
+func (s *UserStore) ConfirmEmailChange(ctx context.Context, token string) (int64, error) {
+	var userID int64
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+		defer cancel()
+
+		hash := sha256.Sum256([]byte(token))
+		hashToken := hex.EncodeToString(hash[:])
+		var newEmail string
+		query := `SELECT user_id, new_email FROM email_changes WHERE token = $1 AND expiry > $2 FOR UPDATE`
+		if err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&userID, &newEmail); err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				return ErrRecordNotFound
+			default:
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET email = $1 WHERE id = $2`, newEmail, userID); err != nil {
+			switch {
+			case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+				return ErrDuplicateEmail
+			default:
+				return err
+			}
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = $1`, userID)
+		return err
+	})
+	return userID, err
+}
+
 func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 	query := `UPDATE users SET username = $1, email = $2, is_active = $3 WHERE id = $4`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 	_, err := tx.ExecContext(ctx, query, user.Username, user.Email, user.IsActive, user.ID)
 	if err != nil {
@@ -224,7 +383,7 @@ func (s *UserStore) update(ctx context.Context, tx *sql.Tx, user *User) error {
 
 func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM user_invitations WHERE user_id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 	_, err := tx.ExecContext(ctx, query, userID)
 	if err != nil {
@@ -233,9 +392,17 @@ func (s *UserStore) deleteUserInvitations(ctx context.Context, tx *sql.Tx, userI
 	return nil
 }
 
+// Delete removes the user and everything they own. Users under legal hold,
+// or with a held post, are kept and ErrUnderLegalHold is returned.
 func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if err := s.checkHold(ctx, tx, userID); err != nil {
+			return err
+		}
 
+		if err := s.unindexPosts(ctx, tx, userID); err != nil {
+			return err
+		}
 		if err := s.delete(ctx, tx, userID); err != nil {
 			return err
 		}
@@ -246,9 +413,43 @@ func (s *UserStore) Delete(ctx context.Context, userID int64) error {
 		return nil
 	})
 }
+func (s *UserStore) checkHold(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `
+	SELECT u.on_hold OR EXISTS (SELECT 1 FROM posts WHERE user_id = u.id AND on_hold)
+	FROM users u
+	WHERE u.id = $1
+	FOR UPDATE
+	`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	var held bool
+	err := tx.QueryRowContext(ctx, query, userID).Scan(&held)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return nil
+	case err != nil:
+		return err
+	case held:
+		return ErrUnderLegalHold
+	}
+	return nil
+}
+
+// unindexPosts queues the user's posts for the search indexer before the
+// cascade removes them, so they are dropped from the index too.
+func (s *UserStore) unindexPosts(ctx context.Context, tx *sql.Tx, userID int64) error {
+	query := `INSERT INTO outbox_events (topic, aggregate_id) SELECT $1, id FROM posts WHERE user_id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, TopicSearchPost, userID)
+	return err
+}
+
 func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userID int64) error {
 	query := `DELETE FROM users WHERE id = $1`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
 	defer cancel()
 	_, err := tx.ExecContext(ctx, query, userID)
 	if err != nil {
@@ -258,11 +459,11 @@ func (s *UserStore) delete(ctx context.Context, tx *sql.Tx, userID int64) error
 }
 
 func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id,username,email,password,created_at FROM users WHERE email = $1 AND is_active = TRUE`
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	query := `SELECT id,username,email,password,created_at,passwordless,locale FROM users WHERE email = $1 AND is_active = TRUE`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
 	defer cancel()
 	var user User
-	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt)
+	err := s.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Username, &user.Email, &user.Password.hash, &user.CreatedAt, &user.Passwordless, &user.Locale)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -273,3 +474,174 @@ func (s *UserStore) GetByEmail(ctx context.Context, email string) (*User, error)
 	}
 	return &user, nil
 }
+
+func (s *UserStore) SetPasswordless(ctx context.Context, userID int64, passwordless bool) error {
+	query := `UPDATE users SET passwordless = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, passwordless, userID)
+	return err
+}
+
+func (s *UserStore) SetLocale(ctx context.Context, userID int64, locale, timezone string) error {
+	query := `UPDATE users SET locale = $1, timezone = $2 WHERE id = $3`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, locale, timezone, userID)
+	return err
+}
+
+func (s *UserStore) SetPreferredLanguages(ctx context.Context, userID int64, languages []string) error {
+	query := `UPDATE users SET preferred_languages = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, pq.Array(languages), userID)
+	return err
+}
+
+// SetBirthdate records the birthdate of an account that has none yet, it
+// can't be changed afterwards. ErrConflict means it is already set.
+func (s *UserStore) SetBirthdate(ctx context.Context, userID int64, birthdate string) error {
+	query := `UPDATE users SET birthdate = $2 WHERE id = $1 AND birthdate IS NULL`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, birthdate)
+	if err != nil {
+		return err
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+func (s *UserStore) SetContentWarningPref(ctx context.Context, userID int64, pref string) error {
+	query := `UPDATE users SET content_warning_pref = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, pref, userID)
+	return err
+}
+
+// SetPostRetention sets how many months the user's posts are kept, nil keeps
+// them for good.
+func (s *UserStore) SetPostRetention(ctx context.Context, userID int64, months *int) error {
+	query := `UPDATE users SET post_retention_months = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, months, userID)
+	return err
+}
+
+func (s *UserStore) SetMutedNotificationTypes(ctx context.Context, userID int64, types []string) error {
+	query := `UPDATE users SET muted_notification_types = $1 WHERE id = $2`
+	ctx, cancel := context.WithTimeout(ctx, WriteTimeoutDuration)
+	defer cancel()
+	_, err := s.db.ExecContext(ctx, query, pq.Array(types), userID)
+	return err
+}
+
+// GetIDsByUsernames resolves usernames of active users to their IDs. Unknown
+// names are left out of the result.
+func (s *UserStore) GetIDsByUsernames(ctx context.Context, usernames []string) (map[string]int64, error) {
+	query := `SELECT username, id FROM users WHERE username = ANY($1) AND is_active = true`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64, len(usernames))
+	for rows.Next() {
+		var (
+			username string
+			id       int64
+		)
+		if err := rows.Scan(&username, &id); err != nil {
+			return nil, err
+		}
+		ids[username] = id
+	}
+	return ids, rows.Err()
+}
+
+// GetIDsByEmails resolves emails of active users to their IDs, keyed by the
+// email as given. Matching ignores case.
+func (s *UserStore) GetIDsByEmails(ctx context.Context, emails []string) (map[string]int64, error) {
+	query := `
+	SELECT e, u.id
+	FROM unnest($1::text[]) AS e
+	JOIN users u ON lower(u.email) = lower(e)
+	WHERE u.is_active = true
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(emails))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64, len(emails))
+	for rows.Next() {
+		var (
+			email string
+			id    int64
+		)
+		if err := rows.Scan(&email, &id); err != nil {
+			return nil, err
+		}
+		ids[email] = id
+	}
+	return ids, rows.Err()
+}
+
+// Profile is the public view of a user, safe to show to anonymous visitors.
+type Profile struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+	Followers int       `json:"followers"`
+	Following int       `json:"following"`
+	Posts     int       `json:"posts"`
+	IsBot     bool      `json:"is_bot"`
+	Badges    []string  `json:"badges"`
+	// Fields are filled in by the API.
+	Fields []ProfileField `json:"fields"`
+}
+
+func (s *UserStore) GetProfile(ctx context.Context, userID int64) (*Profile, error) {
+	query := `
+	SELECT u.id, u.username, u.created_at,
+		(SELECT COUNT(*) FROM followers WHERE user_id = u.id),
+		(SELECT COUNT(*) FROM followers WHERE follower_id = u.id),
+		(SELECT COUNT(*) FROM posts WHERE user_id = u.id),
+		u.is_bot,
+		ARRAY(SELECT badge FROM user_badges WHERE user_id = u.id ORDER BY badge)
+	FROM users u
+	WHERE u.id = $1 AND u.is_active = true AND NOT u.on_hold
+	`
+	ctx, cancel := context.WithTimeout(ctx, ReadTimeoutDuration)
+	defer cancel()
+
+	var p Profile
+	err := s.db.QueryRowContext(ctx, query, userID).Scan(&p.ID, &p.Username, &p.CreatedAt, &p.Followers, &p.Following, &p.Posts, &p.IsBot, pq.Array(&p.Badges))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &p, nil
+}